@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/audit"
+)
+
+// maxAuditResults caps a single audit query
+const maxAuditResults = 1000
+
+// auditIfOK records an event when the audited operation succeeded
+func (s *Server) auditIfOK(r *http.Request, err error, action, resource string, details map[string]string) {
+	if err == nil {
+		s.Audit.Record(r.Context(), action, resource, details)
+	}
+}
+
+// auditHandler answers GET /admin/audit?actor=&action=&resource=&since=&until=&limit=
+// with matching events (newest first) and the integrity of each audit file
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+		Resource: query.Get("resource"),
+		Limit:    100,
+	}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				apperrors.Write(w, apperrors.ErrInvalidInput, name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*target = parsed
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditResults {
+			apperrors.Write(w, apperrors.ErrInvalidInput, "limit must be between 1 and 1000")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := audit.Query(s.AuditDir, filter)
+	if err != nil {
+		log.Printf("Audit query error: %v", err)
+		apperrors.Write(w, err, "Failed to read audit log")
+		return
+	}
+	integrity, err := audit.Verify(s.AuditDir)
+	if err != nil {
+		log.Printf("Audit verify error: %v", err)
+		apperrors.Write(w, err, "Failed to read audit log")
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":    events,
+		"integrity": integrity,
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/bulkdelete"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/pkg/data"
+)
+
+// BulkDeleteRequest deletes the indexed articles matching Filters, with
+// their passages and sections. Without ConfirmationToken it is a dry run
+// that counts them and returns the token; sending the same filters with
+// the token deletes them.
+type BulkDeleteRequest struct {
+	Filters           *SearchFilters `json:"filters"`
+	ConfirmationToken string         `json:"confirmation_token,omitempty"`
+}
+
+// bulkDeleteTargets are the collections bulk deletes apply to
+func bulkDeleteTargets() []string {
+	return []string{tiering.HistoricalCollection(), tiering.RecentCollection(), data.ChunksCollection, data.SectionsCollection}
+}
+
+// bulkDeleteHandler answers POST /admin/points/delete
+func (s *Server) bulkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	filter, err := req.Filters.qdrantFilter()
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return
+	}
+
+	var plan *bulkdelete.Plan
+	if req.ConfirmationToken == "" {
+		plan, err = s.BulkDelete.DryRun(r.Context(), filter)
+	} else {
+		plan, err = s.BulkDelete.Delete(r.Context(), filter, req.ConfirmationToken)
+	}
+	if err != nil {
+		log.Printf("Bulk delete error: %v", err)
+		message := "Bulk delete failed"
+		if apperrors.StatusCode(err) < http.StatusInternalServerError {
+			message = err.Error()
+		}
+		apperrors.Write(w, err, message)
+		return
+	}
+	if !plan.DryRun {
+		filters, _ := json.Marshal(req.Filters)
+		s.Audit.Record(r.Context(), "points.delete", "articles", map[string]string{
+			"filters": string(filters),
+			"deleted": strconv.FormatUint(plan.Total, 10),
+		})
+		log.Printf("🗑️  Bulk delete removed %d points matching %s", plan.Total, filters)
+	}
+	json.NewEncoder(w).Encode(plan)
+}
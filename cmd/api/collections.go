@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// collectionField tags the payload of a multi-collection hit with the
+// source tag of its collection
+const collectionField = "_source_collection"
+
+// validateCollections rejects source tags the environment does not define
+// and repeated ones
+func (req *SearchRequest) validateCollections(collections config.Collections) error {
+	if len(req.Collections) > 0 && req.Mode == SearchModeMaxSim {
+		return apperrors.Invalid("collections", "maxsim search covers the abstracts only")
+	}
+	for i, tag := range req.Collections {
+		if _, ok := collections.Collection(tag); !ok {
+			return apperrors.Invalid("collections", "unknown collection %q; this server has %v", tag, collections.Tags())
+		}
+		if slices.Contains(req.Collections[:i], tag) {
+			return apperrors.Invalid("collections", "collection %q is listed twice", tag)
+		}
+	}
+	return nil
+}
+
+// searchCollections searches the collections tagged tags with query and
+// merges their hits by score, tagging each with its source in
+// collectionField. The article collection is searched as by runSearch;
+// filter applies to every collection.
+func (s *Server) searchCollections(ctx context.Context, query string, tags []string, limit, offset int, filter *qdrant.Filter, withPayload *qdrant.WithPayloadSelector) (*qdrant.SearchResponse, error) {
+	collections := config.CurrentCollections()
+	var vector []float32
+	merged := &qdrant.SearchResponse{}
+	for _, tag := range tags {
+		var result *qdrant.SearchResponse
+		var err error
+		if tag == config.ArticlesTag {
+			result, err = s.runSearch(ctx, query, offset+limit, 0, filter, withPayload)
+		} else {
+			if vector == nil {
+				if vector, err = ai.EmbedContext(ctx, s.Embedder, s.rewriteQuery(query)); err != nil {
+					return nil, err
+				}
+			}
+			name, _ := collections.Collection(tag)
+			result, err = s.QdrantClient.Search(ctx, &qdrant.SearchPoints{
+				CollectionName: name,
+				Vector:         vector,
+				Limit:          uint64(offset + limit),
+				Filter:         filter,
+				WithPayload:    withPayload,
+			})
+			if err != nil {
+				err = fmt.Errorf("%w: %s: %w", apperrors.ErrSearchUnavailable, name, err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range result.GetResult() {
+			if point.Payload == nil {
+				point.Payload = make(map[string]*qdrant.Value)
+			}
+			point.Payload[collectionField] = qdrant.NewValueString(tag)
+		}
+		merged.Result = append(merged.Result, result.GetResult()...)
+		merged.Time += result.GetTime()
+	}
+
+	sort.SliceStable(merged.Result, func(i, j int) bool { return merged.Result[i].Score > merged.Result[j].Score })
+	if offset >= len(merged.Result) {
+		merged.Result = nil
+	} else {
+		merged.Result = merged.Result[offset:min(len(merged.Result), offset+limit)]
+	}
+	return merged, nil
+}
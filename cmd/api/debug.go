@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/rerank"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SearchDebug explains how /search ranked its results for a query. The
+// embedding and hit fields describe the abstracts search; maxsim searches
+// leave them empty.
+type SearchDebug struct {
+	Query         string          `json:"query"`
+	Mode          string          `json:"mode,omitempty"`
+	Collections   []string        `json:"collections,omitempty"`
+	EnhancedQuery string          `json:"enhanced_query"` // the text that was embedded
+	EmbeddingNorm float64         `json:"embedding_norm"`
+	EmbeddingDims int             `json:"embedding_dims"`
+	Historical    bool            `json:"include_historical"`
+	Filters       []DebugFilter   `json:"filters"`
+	VectorHits    []DebugHit      `json:"vector_hits"` // unfiltered search, raw cosine scores
+	Reranked      bool            `json:"reranked"`    // the request asked for reranking and it succeeded
+	Rerank        []DebugRerank   `json:"rerank,omitempty"`
+	RerankError   string          `json:"rerank_error,omitempty"`
+	Final         []DebugRankItem `json:"final"`
+}
+
+// DebugFilter is an exact-match filter search applied and what it found
+type DebugFilter struct {
+	Name   string     `json:"name"`
+	Field  string     `json:"field"`
+	Values []string   `json:"values"`
+	Hits   []DebugHit `json:"hits"`
+}
+
+// DebugHit is one raw search result
+type DebugHit struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Score float32 `json:"score"`
+}
+
+// DebugRerank compares a result's fused rank with the rank the reranker
+// gives it; a positive delta means the reranker would move it up
+type DebugRerank struct {
+	ID         string  `json:"id"`
+	Score      float32 `json:"score"`
+	FusedRank  int     `json:"fused_rank"`
+	RerankRank int     `json:"rerank_rank"`
+	Delta      int     `json:"delta"`
+}
+
+// DebugRankItem is one result of the final ranking and where it came from
+type DebugRankItem struct {
+	Rank   int     `json:"rank"`
+	ID     string  `json:"id"`
+	Title  string  `json:"title"`
+	Score  float32 `json:"score"`
+	Source string  `json:"source"` // the filter name, or "vector"
+}
+
+// searchTrace collects a SearchDebug as runSearch runs; a nil trace
+// records nothing
+type searchTrace struct {
+	debug SearchDebug
+}
+
+type searchTraceKey struct{}
+
+// withSearchTrace makes searches with ctx record their steps in trace
+func withSearchTrace(ctx context.Context, trace *searchTrace) context.Context {
+	return context.WithValue(ctx, searchTraceKey{}, trace)
+}
+
+// searchTraceFrom returns the trace set by withSearchTrace, or nil
+func searchTraceFrom(ctx context.Context) *searchTrace {
+	trace, _ := ctx.Value(searchTraceKey{}).(*searchTrace)
+	return trace
+}
+
+func (t *searchTrace) embedded(enhanced string, vector []float32) {
+	if t == nil {
+		return
+	}
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	t.debug.EnhancedQuery = enhanced
+	t.debug.EmbeddingNorm = math.Sqrt(sum)
+	t.debug.EmbeddingDims = len(vector)
+}
+
+func (t *searchTrace) filtered(query string, hits []*qdrant.ScoredPoint) {
+	if t == nil {
+		return
+	}
+	field, values := notationTerms(query)
+	t.debug.Filters = append(t.debug.Filters, DebugFilter{Name: "notation", Field: field, Values: values, Hits: debugHits(hits)})
+}
+
+func (t *searchTrace) searched(hits []*qdrant.ScoredPoint) {
+	if t == nil {
+		return
+	}
+	t.debug.VectorHits = debugHits(hits)
+}
+
+func debugHits(points []*qdrant.ScoredPoint) []DebugHit {
+	hits := make([]DebugHit, len(points))
+	for i, point := range points {
+		hits[i] = DebugHit{ID: formatPointID(point.Id), Title: payload.String(point.Payload, "title"), Score: point.Score}
+	}
+	return hits
+}
+
+// debugSearchHandler runs a search through the /search pipeline and reports
+// every step. POST takes a /search request body; GET is a shorthand for
+// plain queries: GET /debug/search?q=...&limit=10&include_historical=true
+func (s *Server) debugSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req SearchRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("q")
+		if raw := query.Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				apperrors.Write(w, apperrors.ErrInvalidInput, "limit must be a number")
+				return
+			}
+			req.Limit = n
+		}
+		req.IncludeHistorical, _ = strconv.ParseBool(query.Get("include_historical"))
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	filter, offset, ok := s.validateSearch(w, &req)
+	if !ok {
+		return
+	}
+	ctx, _, err := s.searchContext(r.Context(), &req)
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return
+	}
+
+	trace := &searchTrace{debug: SearchDebug{
+		Query:       req.Query,
+		Mode:        req.Mode,
+		Collections: req.Collections,
+		Historical:  req.IncludeHistorical,
+		Filters:     []DebugFilter{},
+		VectorHits:  []DebugHit{},
+	}}
+	withPayload := &qdrant.WithPayloadSelector{
+		SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: []string{"title", "abstract"}},
+		},
+	}
+	result, reranked, err := s.retrieve(withSearchTrace(ctx, trace), &req, offset, filter, withPayload)
+	if err != nil {
+		log.Printf("Debug search error: %v", err)
+		apperrors.Write(w, err, "Search failed")
+		return
+	}
+
+	filteredIDs := make(map[string]bool)
+	for _, filter := range trace.debug.Filters {
+		for _, hit := range filter.Hits {
+			filteredIDs[hit.ID] = true
+		}
+	}
+	for i, point := range result.GetResult() {
+		id := formatPointID(point.Id)
+		source := "vector"
+		if filteredIDs[id] {
+			source = "notation"
+		}
+		trace.debug.Final = append(trace.debug.Final, DebugRankItem{
+			Rank: i + 1, ID: id, Title: payload.String(point.Payload, "title"), Score: point.Score, Source: source,
+		})
+	}
+	trace.debug.Reranked = reranked
+	if !req.Rerank {
+		s.traceRerank(r, trace, req.Query, result.GetResult())
+	}
+
+	if err := json.NewEncoder(w).Encode(trace.debug); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+// traceRerank scores the final results of a search that did not ask for
+// reranking and records how far the reranker would move each one
+func (s *Server) traceRerank(r *http.Request, trace *searchTrace, query string, points []*qdrant.ScoredPoint) {
+	if s.Reranker == nil || len(points) == 0 {
+		return
+	}
+	passages := make([]string, len(points))
+	for i, point := range points {
+		passages[i] = rerank.Passage(point.Payload)
+	}
+	scores, err := s.Reranker.Rerank(r.Context(), query, passages)
+	if err != nil {
+		trace.debug.RerankError = err.Error()
+		return
+	}
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	rerankRank := make([]int, len(points))
+	for rank, index := range order {
+		rerankRank[index] = rank + 1
+	}
+	for i, point := range points {
+		trace.debug.Rerank = append(trace.debug.Rerank, DebugRerank{
+			ID:         formatPointID(point.Id),
+			Score:      scores[i],
+			FusedRank:  i + 1,
+			RerankRank: rerankRank[i],
+			Delta:      i + 1 - rerankRank[i],
+		})
+	}
+}
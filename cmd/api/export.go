@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/gorilla/mux"
+)
+
+// maxExportArticles bounds a single bulk export
+const maxExportArticles = 500
+
+// ExportRequest selects articles by PMID or by a saved search
+type ExportRequest struct {
+	PMIDs         []string `json:"pmids,omitempty"`
+	SavedSearchID string   `json:"saved_search_id,omitempty"`
+	Format        string   `json:"format"` // "ris" (default), "csl-json" or "bibtex"
+}
+
+type SaveSearchRequest struct {
+	Name  string `json:"name,omitempty"`
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+func isExportFormat(format string) bool {
+	return format == data.CitationRIS || format == data.CitationCSL || format == data.CitationBibTeX
+}
+
+func (s *Server) saveSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req SaveSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = s.Config.Current().SearchTopK
+	}
+	if req.Limit < 0 || req.Limit > maxExportArticles {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Limit is out of range")
+		return
+	}
+
+	search, err := s.SavedSearches.Save(identity.UserFromContext(r.Context()), req.Name, strings.TrimSpace(req.Query), req.Limit)
+	if err != nil {
+		log.Printf("Save search error: %v", err)
+		apperrors.Write(w, err, "Failed to save search")
+		return
+	}
+	s.Audit.Record(r.Context(), "saved_search.create", search.ID, nil)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(search)
+}
+
+func (s *Server) getSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	search, err := s.SavedSearches.Get(mux.Vars(r)["id"])
+	if err != nil {
+		apperrors.Write(w, err, "Saved search not found")
+		return
+	}
+	json.NewEncoder(w).Encode(search)
+}
+
+// exportHandler writes a reference manager file (RIS, CSL-JSON or BibTeX) for
+// an explicit list of PMIDs or for the current results of a saved search
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if req.Format == "" {
+		req.Format = data.CitationRIS
+	}
+	if !isExportFormat(req.Format) {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Format must be ris, csl-json or bibtex")
+		return
+	}
+	if (len(req.PMIDs) == 0) == (req.SavedSearchID == "") {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Provide either pmids or saved_search_id")
+		return
+	}
+	if len(req.PMIDs) > maxExportArticles {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Too many pmids")
+		return
+	}
+
+	var (
+		articles []*models.MedicalArticle
+		err      error
+		basename = "medatlas-export"
+	)
+	if req.SavedSearchID != "" {
+		articles, err = s.savedSearchArticles(r, req.SavedSearchID)
+		basename = "medatlas-" + req.SavedSearchID
+	} else {
+		articles, err = ai.GetArticles(r.Context(), s.Points, req.PMIDs)
+	}
+	if err != nil {
+		log.Printf("Export error: %v", err)
+		apperrors.Write(w, err, "Failed to load articles")
+		return
+	}
+
+	s.writeCitationFile(w, articles, req.Format, basename)
+}
+
+// savedSearchArticles re-runs a saved search so the export reflects the current index
+func (s *Server) savedSearchArticles(r *http.Request, id string) ([]*models.MedicalArticle, error) {
+	search, err := s.SavedSearches.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	searchResult, err := s.runSearch(r.Context(), search.Query, search.Limit, 0, nil, fullPayload)
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]*models.MedicalArticle, len(searchResult.Result))
+	for i, point := range searchResult.Result {
+		articles[i] = ai.ArticleFromPayload(point.Payload)
+	}
+	return articles, nil
+}
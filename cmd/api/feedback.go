@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/pkg/search"
+)
+
+// FeedbackRequest marks a search result or chat citation as relevant to the
+// query it was returned for, or not. Score and Scale are as the result
+// reported them.
+type FeedbackRequest struct {
+	Query    string  `json:"query"`
+	ID       string  `json:"id"`
+	Score    float32 `json:"score"`
+	Scale    string  `json:"scale"`
+	Relevant *bool   `json:"relevant"`
+}
+
+// maxFeedbackQueryLength bounds the query stored with a judgment
+const maxFeedbackQueryLength = 1000
+
+// searchScale is the scale of the scores a search request returns
+func searchScale(req *SearchRequest, reranked bool) string {
+	switch {
+	case req.Mode == SearchModeMaxSim:
+		return calibration.ScaleMaxSim
+	case reranked:
+		return calibration.ScaleRerank
+	case req.Mode == SearchModeHybrid && len(search.Terms(req.Query)) > 0:
+		return calibration.ScaleRRF
+	default:
+		return calibration.ScaleCosine
+	}
+}
+
+// feedbackHandler records a relevance judgment with POST /feedback. The
+// relevance labels of results are learned from these judgments, so only
+// signed-in users may give them.
+func (s *Server) feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to give feedback")
+		return
+	}
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	req.Query, req.ID = strings.TrimSpace(req.Query), strings.TrimSpace(req.ID)
+	if req.Query == "" || req.ID == "" || req.Relevant == nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "query, id and relevant are required")
+		return
+	}
+	if len(req.Query) > maxFeedbackQueryLength {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Query is too long")
+		return
+	}
+	err = s.Calibration.Add(calibration.Judgment{
+		Scale:    req.Scale,
+		Score:    req.Score,
+		Relevant: *req.Relevant,
+		ID:       req.ID,
+		Query:    req.Query,
+		UserID:   userID,
+	})
+	if err != nil {
+		log.Printf("Feedback error: %v", err)
+		message := "Failed to record feedback"
+		if apperrors.StatusCode(err) < http.StatusInternalServerError {
+			message = err.Error()
+		}
+		apperrors.Write(w, err, message)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// calibrationHandler answers GET /admin/calibration with the relevance
+// bands in use for each score scale
+func (s *Server) calibrationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"bands": s.Calibration.Bands()})
+}
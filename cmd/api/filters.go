@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SearchFilters restricts a search to articles matching every set field.
+// List fields match articles with any of the listed values; dates are
+// YYYY-MM-DD, and dates and years are inclusive.
+type SearchFilters struct {
+	Journal          []string `json:"journal,omitempty"`
+	Source           []string `json:"source,omitempty"`
+	PublishedFrom    string   `json:"published_from,omitempty"`
+	PublishedTo      string   `json:"published_to,omitempty"`
+	YearFrom         int      `json:"year_from,omitempty"`
+	YearTo           int      `json:"year_to,omitempty"`
+	MeshHeadings     []string `json:"mesh_headings,omitempty"`
+	PublicationTypes []string `json:"publication_types,omitempty"`
+}
+
+// qdrantFilter translates f into payload conditions. It returns nil when no
+// filter is set, and an ErrInvalidInput error for malformed dates. Dates
+// and years are range conditions on published_ts and published_year.
+func (f *SearchFilters) qdrantFilter() (*qdrant.Filter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	var must []*qdrant.Condition
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{
+		{"journal", f.Journal},
+		{"source", f.Source},
+		{"mesh_headings", f.MeshHeadings},
+		{"publication_types", f.PublicationTypes},
+	} {
+		var values []string
+		for _, value := range list.values {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) > 0 {
+			must = append(must, qdrant.NewMatchKeywords(list.field, values...))
+		}
+	}
+
+	if f.PublishedFrom != "" || f.PublishedTo != "" {
+		var from, to time.Time
+		var err error
+		if f.PublishedFrom != "" {
+			if from, err = time.Parse("2006-01-02", f.PublishedFrom); err != nil {
+				return nil, fmt.Errorf("%w: published_from must be YYYY-MM-DD", apperrors.ErrInvalidInput)
+			}
+		}
+		if f.PublishedTo != "" {
+			if to, err = time.Parse("2006-01-02", f.PublishedTo); err != nil {
+				return nil, fmt.Errorf("%w: published_to must be YYYY-MM-DD", apperrors.ErrInvalidInput)
+			}
+		}
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			return nil, fmt.Errorf("%w: published_to is before published_from", apperrors.ErrInvalidInput)
+		}
+		must = append(must, publishedRange("published_ts", from, to, from.Unix(), to.Unix()))
+	}
+
+	if f.YearFrom != 0 || f.YearTo != 0 {
+		if f.YearFrom < 0 || f.YearTo < 0 {
+			return nil, fmt.Errorf("%w: year_from and year_to must be years", apperrors.ErrInvalidInput)
+		}
+		if f.YearFrom != 0 && f.YearTo != 0 && f.YearTo < f.YearFrom {
+			return nil, fmt.Errorf("%w: year_to is before year_from", apperrors.ErrInvalidInput)
+		}
+		var from, to time.Time
+		if f.YearFrom != 0 {
+			from = time.Date(f.YearFrom, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if f.YearTo != 0 {
+			to = time.Date(f.YearTo, time.December, 31, 0, 0, 0, 0, time.UTC)
+		}
+		must = append(must, publishedRange("published_year", from, to, int64(f.YearFrom), int64(f.YearTo)))
+	}
+
+	if len(must) == 0 {
+		return nil, nil
+	}
+	return &qdrant.Filter{Must: must}, nil
+}
+
+// publishedRange matches points whose numeric publication field is between
+// low and high, bounds left zero being open. Points indexed before the
+// numeric fields were stored only have the date string, so they are matched
+// on it between from and to instead.
+func publishedRange(field string, from, to time.Time, low, high int64) *qdrant.Condition {
+	numbers, dates := &qdrant.Range{}, &qdrant.DatetimeRange{}
+	if !from.IsZero() {
+		gte := float64(low)
+		numbers.Gte, dates.Gte = &gte, timestamppb.New(from)
+	}
+	if !to.IsZero() {
+		lte := float64(high)
+		numbers.Lte, dates.Lte = &lte, timestamppb.New(to)
+	}
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{Should: []*qdrant.Condition{
+		qdrant.NewRange(field, numbers),
+		qdrant.NewFilterAsCondition(&qdrant.Filter{Must: []*qdrant.Condition{
+			qdrant.NewIsEmpty(field),
+			qdrant.NewDatetimeRange("published_date", dates),
+		}}),
+	}})
+}
+
+// andFilter returns a filter matching both a and b; either may be nil
+func andFilter(a, b *qdrant.Filter) *qdrant.Filter {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewFilterAsCondition(a), qdrant.NewFilterAsCondition(b)}}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Values accepted by SearchRequest.GroupBy
+const (
+	GroupByRegion = "region"
+	GroupByStudy  = "study"
+)
+
+// nearDuplicateTitle is the title word overlap above which two records are
+// taken to describe the same study
+const nearDuplicateTitle = 0.9
+
+func isGroupBy(groupBy string) bool {
+	return groupBy == GroupByRegion || groupBy == GroupByStudy
+}
+
+// FeatureCollection is a GeoJSON-style response for grouped search results
+type FeatureCollection struct {
+	Type     string    `json:"type"` // always "FeatureCollection"
+	Features []Feature `json:"features"`
+}
+
+// Feature is one group of results located at a representative point
+type Feature struct {
+	Type       string      `json:"type"`     // always "Feature"
+	Geometry   *PointGeom  `json:"geometry"` // null when the location is unknown
+	Properties RegionGroup `json:"properties"`
+}
+
+// PointGeom is a GeoJSON Point with [longitude, latitude] coordinates
+type PointGeom struct {
+	Type        string     `json:"type"` // always "Point"
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// RegionGroup lists the results whose study population is in one region
+type RegionGroup struct {
+	Region    string           `json:"region"`
+	Count     int              `json:"count"`
+	Countries []string         `json:"countries,omitempty"`
+	Results   []SearchResponse `json:"results"`
+}
+
+// groupByRegion buckets results by study region. An article spanning
+// several regions appears in each; articles without one go under Unknown.
+// Groups are ordered by size, largest first.
+func groupByRegion(points []*qdrant.ScoredPoint, results []SearchResponse) FeatureCollection {
+	groups := make(map[string]*RegionGroup)
+	countriesSeen := make(map[string]map[string]bool)
+	var order []string
+
+	for i, point := range points {
+		article := ai.ArticleFromPayload(point.Payload)
+		regions := article.Regions
+		if len(regions) == 0 {
+			regions = []string{data.RegionUnknown}
+		}
+		for _, region := range regions {
+			group, ok := groups[region]
+			if !ok {
+				group = &RegionGroup{Region: region}
+				groups[region] = group
+				countriesSeen[region] = make(map[string]bool)
+				order = append(order, region)
+			}
+			group.Results = append(group.Results, results[i])
+			group.Count++
+			for _, country := range data.RegionCountries(region, article.Countries) {
+				if !countriesSeen[region][country] {
+					countriesSeen[region][country] = true
+					group.Countries = append(group.Countries, country)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return groups[order[i]].Count > groups[order[j]].Count })
+	collection := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(order))}
+	for _, region := range order {
+		feature := Feature{Type: "Feature", Properties: *groups[region]}
+		if centroid, ok := data.RegionCentroids[region]; ok {
+			feature.Geometry = &PointGeom{Type: "Point", Coordinates: centroid}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return collection
+}
+
+// StudyGroup is one study with every record found for it. Primary is the
+// best-scoring record; MatchedOn says why the variants were merged into it.
+type StudyGroup struct {
+	Primary   SearchResponse   `json:"primary"`
+	MatchedOn []string         `json:"matched_on,omitempty"` // "doi", "nct" and/or "title"
+	Variants  []SearchResponse `json:"variants,omitempty"`
+}
+
+// studyKeys identifies the study behind one result
+type studyKeys struct {
+	doi    string
+	nctIDs []string
+	title  string
+}
+
+// groupByStudy collapses results that share a DOI, a trial registration
+// number or a near-identical title, keeping the ranking of each group's
+// best result
+func groupByStudy(points []*qdrant.ScoredPoint, results []SearchResponse) []StudyGroup {
+	keys := make([]studyKeys, len(points))
+	for i, point := range points {
+		article := ai.ArticleFromPayload(point.Payload)
+		nctIDs := article.NCTIDs
+		if len(nctIDs) == 0 {
+			nctIDs = data.ExtractNCTIDs(article.Abstract)
+		}
+		keys[i] = studyKeys{
+			doi:    strings.ToLower(strings.TrimSpace(article.DOI)),
+			nctIDs: nctIDs,
+			title:  data.NormalizeTitle(article.Title),
+		}
+	}
+
+	// Union-find over results; the root is always the lowest index, i.e.
+	// the best-scoring record of the group
+	parent := make([]int, len(points))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	matched := make([]map[string]bool, len(points))
+	union := func(i, j int, reason string) {
+		ri, rj := find(i), find(j)
+		if ri > rj {
+			ri, rj = rj, ri
+		}
+		if ri != rj {
+			parent[rj] = ri
+			for r := range matched[rj] {
+				addReason(matched, ri, r)
+			}
+		}
+		addReason(matched, ri, reason)
+	}
+
+	for i := range keys {
+		for j := 0; j < i; j++ {
+			switch {
+			case keys[i].doi != "" && keys[i].doi == keys[j].doi:
+				union(j, i, "doi")
+			case sharesAny(keys[i].nctIDs, keys[j].nctIDs):
+				union(j, i, "nct")
+			case keys[i].title != "" && data.TitleSimilarity(keys[i].title, keys[j].title) >= nearDuplicateTitle:
+				union(j, i, "title")
+			}
+		}
+	}
+
+	groupIndex := make(map[int]int)
+	var groups []StudyGroup
+	for i := range results {
+		root := find(i)
+		if root == i {
+			groupIndex[i] = len(groups)
+			groups = append(groups, StudyGroup{Primary: results[i]})
+			continue
+		}
+		group := &groups[groupIndex[root]]
+		group.Variants = append(group.Variants, results[i])
+	}
+	for root, index := range groupIndex {
+		for _, reason := range []string{"doi", "nct", "title"} {
+			if matched[root][reason] {
+				groups[index].MatchedOn = append(groups[index].MatchedOn, reason)
+			}
+		}
+	}
+	return groups
+}
+
+func addReason(matched []map[string]bool, i int, reason string) {
+	if matched[i] == nil {
+		matched[i] = make(map[string]bool)
+	}
+	matched[i][reason] = true
+}
+
+func sharesAny(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
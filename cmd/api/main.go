@@ -1,37 +1,161 @@
 package main
 
 import (
+	"MedAtlasAIServer/internal/access"
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/alert"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/audit"
+	"MedAtlasAIServer/internal/buildinfo"
+	"MedAtlasAIServer/internal/bulkdelete"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/contentstore"
+	"MedAtlasAIServer/internal/docstore"
+	"MedAtlasAIServer/internal/drift"
 	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/encryption"
+	"MedAtlasAIServer/internal/exclusion"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/logging"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/middleware"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/multivector"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/points"
+	"MedAtlasAIServer/internal/recordlog"
+	"MedAtlasAIServer/internal/rerank"
+	"MedAtlasAIServer/internal/retention"
+	"MedAtlasAIServer/internal/safety"
+	"MedAtlasAIServer/internal/savedsearch"
+	"MedAtlasAIServer/internal/screening"
+	"MedAtlasAIServer/internal/shutdown"
+	"MedAtlasAIServer/internal/snapshots"
+	"MedAtlasAIServer/internal/synonyms"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/internal/tracing"
+	"MedAtlasAIServer/internal/trials"
+	"MedAtlasAIServer/internal/warmup"
+	"MedAtlasAIServer/internal/workspace"
+	"MedAtlasAIServer/pkg/data"
+	"MedAtlasAIServer/pkg/search"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/qdrant/go-client/qdrant"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
 )
 
 type SearchRequest struct {
-	Query string `json:"query"`
-	Limit int    `json:"limit"`
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"`
+	Format string `json:"format,omitempty"` // "json" (default), or "bibtex", "ris" or "csl-json" to download the results
+
+	// IncludeHistorical searches the full corpus instead of consulting older
+	// literature only when recent articles cannot fill the page
+	IncludeHistorical bool `json:"include_historical,omitempty"`
+	// GroupBy returns the results as groups instead of a flat list: "region"
+	// answers with a GeoJSON FeatureCollection, one feature per study region;
+	// "study" merges records of the same study (shared DOI, trial number or
+	// near-identical title) into one entry listing its variants
+	GroupBy string `json:"group_by,omitempty"`
+	// Page (1-based), Offset or PageToken, the next_page_token of the
+	// previous page, select a page of results. Setting any of them wraps the
+	// results in a SearchPage.
+	Page      int    `json:"page,omitempty"`
+	Offset    int    `json:"offset,omitempty"`
+	PageToken string `json:"page_token,omitempty"`
+	// Filters restricts the search by article metadata, e.g. clinical
+	// trials published since 2020
+	Filters *SearchFilters `json:"filters,omitempty"`
+	// Mode "hybrid" fuses keyword matches of the query's terms with the
+	// vector hits, so exact drug names and gene symbols are found. Mode
+	// "maxsim" (experimental) scores articles sentence by sentence against
+	// each clause of the query; only the articles built into the
+	// multivector collection are searched.
+	Mode string `json:"mode,omitempty"`
+	// Rerank re-scores the top retrieved articles with the cross-encoder and
+	// orders the page by its scores, which replace the similarity scores
+	Rerank bool `json:"rerank,omitempty"`
+	// Collections searches several sources in one request, e.g.
+	// ["abstracts", "trials", "guidelines"], merging their results by score
+	// and tagging each with its source. Empty searches the abstracts.
+	Collections []string `json:"collections,omitempty"`
+	// AsOf searches the corpus as it was indexed at an earlier time: the
+	// name of a snapshot, or a date or RFC 3339 time selecting the latest
+	// snapshot taken by then. The X-Snapshot header names the snapshot used.
+	AsOf string `json:"as_of,omitempty"`
+}
+
+// SearchModeHybrid selects vector search fused with keyword matching
+const SearchModeHybrid = "hybrid"
+
+// SearchModeMaxSim selects the late-interaction multivector search
+const SearchModeMaxSim = "maxsim"
+
+type CitationResponse struct {
+	ID       string `json:"id"`
+	Style    string `json:"style"`
+	Citation string `json:"citation"`
 }
 
+// SearchResponse is one hit; the tagged fields are read from its payload
 type SearchResponse struct {
 	ID            string  `json:"id"`
-	Title         string  `json:"title"`
-	Abstract      string  `json:"abstract"`
-	Authors       string  `json:"authors"`
-	PublishedDate string  `json:"published_date"`
-	DOI           string  `json:"doi"`
+	Title         string  `json:"title" payload:"title"`
+	Abstract      string  `json:"abstract" payload:"abstract"`
+	Authors       string  `json:"authors" payload:"authors"`
+	PublishedDate string  `json:"published_date" payload:"published_date"`
+	DOI           string  `json:"doi" payload:"doi"`
 	Score         float32 `json:"score"`
+	Relevance     string  `json:"relevance,omitempty"`                               // calibrated label of Score: high, moderate or weak
+	ScoreScale    string  `json:"score_scale"`                                       // what Score measures, see calibration.Scale*
+	Collection    string  `json:"collection,omitempty" payload:"_source_collection"` // source tag, in multi-collection searches, see collectionField
 }
 
 type Server struct {
-	QdrantClient qdrant.PointsClient
-	Embedder     *embeddingClient.Client
+	QdrantClient  ai.Searcher
+	Embedder      ai.Embedder
+	Points        ai.PointGetter
+	Trials        trials.Points
+	SavedSearches *savedsearch.Store
+	Workspaces    *workspace.Store
+	Audit         *audit.Log // nil disables auditing
+	AuditDir      string
+	QueryLog      *recordlog.Log // nil disables query logging
+	Config        *config.Store
+	Warmup        *warmup.Gate            // nil skips the warm-up check in /ready
+	Reranker      ai.Reranker             // optional, scores results for /debug/search
+	Shadow        *Shadow                 // nil disables shadow traffic
+	Counter       Counter                 // optional, estimates result totals for paginated searches
+	MultiVector   multivector.Points      // nil disables the maxsim search mode
+	Synonyms      *synonyms.Store         // admin-defined query synonyms; nil expands none
+	Calibration   *calibration.Calibrator // labels scores from relevance feedback; nil labels none
+	Snapshots     *snapshots.Manager      // frozen copies for as_of searches; nil disables them
+	BulkDelete    *bulkdelete.Deleter
+}
+
+func NewServer(embedder ai.Embedder, searcher ai.Searcher, cfg *config.Store) *Server {
+	if cfg == nil {
+		cfg, _ = config.NewStore("")
+	}
+	return &Server{
+		QdrantClient: searcher,
+		Embedder:     embedder,
+		Config:       cfg,
+	}
 }
 
 func formatPointID(pointID *qdrant.PointId) string {
@@ -49,11 +173,75 @@ func formatPointID(pointID *qdrant.PointId) string {
 	}
 }
 
-func safeGetString(payload map[string]*qdrant.Value, key string) string {
-	if value, exists := payload[key]; exists && value != nil {
-		return value.GetStringValue()
+// fullPayload requests every stored payload field
+var fullPayload = &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}}
+
+// runSearch embeds query and returns limit of the nearest indexed articles
+// matching filter, which may be nil, skipping the first offset. Queries
+// naming genes or variants return the articles that match them exactly
+// first, followed by the nearest other articles. Each step is recorded in
+// the trace carried by ctx, if any.
+func (s *Server) runSearch(ctx context.Context, query string, limit, offset int, filter *qdrant.Filter, withPayload *qdrant.WithPayloadSelector) (*qdrant.SearchResponse, error) {
+	return s.searchWith(ctx, s.Embedder, query, limit, offset, filter, withPayload, searchTraceFrom(ctx))
+}
+
+// searchWith runs the search pipeline with embedder, recording each step in
+// trace when it is not nil
+func (s *Server) searchWith(ctx context.Context, embedder ai.Embedder, query string, limit, offset int, filter *qdrant.Filter, withPayload *qdrant.WithPayloadSelector, trace *searchTrace) (*qdrant.SearchResponse, error) {
+	// Convert User query to a vector, naming drugs by ingredient as well as brand
+	enhanced := s.rewriteQuery(query)
+	queryVector, err := ai.EmbedContext(ctx, embedder, enhanced)
+	if err != nil {
+		return nil, err
 	}
-	return ""
+	trace.embedded(enhanced, queryVector)
+	request := &qdrant.SearchPoints{
+		CollectionName: tiering.HistoricalCollection(),
+		Vector:         queryVector,
+		Limit:          uint64(limit),
+		Filter:         filter,
+		WithPayload:    withPayload,
+	}
+
+	notation := notationFilter(query)
+	if notation == nil {
+		if offset > 0 {
+			skip := uint64(offset)
+			request.Offset = &skip
+		}
+		searchResult, err := s.QdrantClient.Search(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+		}
+		trace.searched(searchResult.GetResult())
+		return searchResult, nil
+	}
+
+	// Exact matches come before the other results, so a later page is cut
+	// from the combined list rather than fetched with an offset
+	request.Limit = uint64(offset + limit)
+	filtered := proto.Clone(request).(*qdrant.SearchPoints)
+	filtered.Filter = andFilter(filter, notation)
+	exact, err := s.QdrantClient.Search(ctx, filtered)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+	trace.filtered(query, exact.GetResult())
+	searchResult := exact
+	if len(exact.GetResult()) < offset+limit {
+		searchResult, err = s.QdrantClient.Search(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+		}
+		trace.searched(searchResult.GetResult())
+		searchResult.Result = appendUnseen(exact.GetResult(), searchResult.GetResult(), offset+limit)
+	}
+	if len(searchResult.Result) <= offset {
+		searchResult.Result = nil
+	} else {
+		searchResult.Result = searchResult.Result[offset:]
+	}
+	return searchResult, nil
 }
 
 func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
@@ -62,61 +250,257 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
 		return
 	}
-	if req.Query == "" {
-		http.Error(w, `{"error": "Query parameter is required"}`, http.StatusBadRequest)
-		return
+	exportStyle := ""
+	if req.Format != "" && req.Format != "json" {
+		if !isExportFormat(req.Format) {
+			apperrors.Write(w, apperrors.ErrInvalidInput, "Format must be json, bibtex, ris or csl-json")
+			return
+		}
+		exportStyle = req.Format
 	}
-	if req.Limit == 0 {
-		req.Limit = 10
+	filter, offset, ok := s.validateSearch(w, &req)
+	if !ok {
+		return
 	}
 
-	// Convert User query to a vector
-	queryVector, err := s.Embedder.GetEmbedding(req.Query)
+	withPayload := &qdrant.WithPayloadSelector{
+		SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: []string{"title", "abstract", "authors", "published_date", "doi"}},
+		},
+	}
+	if exportStyle != "" || req.GroupBy != "" {
+		// Citations and grouping need the full metadata
+		withPayload = fullPayload
+	}
+	ctx, snapshot, err := s.searchContext(r.Context(), &req)
 	if err != nil {
-		log.Printf("Embedding error: %v", err)
-		http.Error(w, `{"error": "Error processing query"}`, http.StatusInternalServerError)
+		apperrors.Write(w, err, err.Error())
 		return
 	}
-	searchResult, err := s.QdrantClient.Search(r.Context(), &qdrant.SearchPoints{
-		CollectionName: "medical_abstracts",
-		Vector:         queryVector,
-		Limit:          uint64(req.Limit),
-		WithPayload: &qdrant.WithPayloadSelector{
-			SelectorOptions: &qdrant.WithPayloadSelector_Include{
-				Include: &qdrant.PayloadIncludeSelector{Fields: []string{"title", "abstract", "authors", "published_date", "doi"}},
-			},
-		},
-	})
-
+	if snapshot != nil {
+		w.Header().Set(SnapshotHeader, snapshot.Name)
+	}
+	start := time.Now()
+	searchResult, reranked, err := s.retrieve(ctx, &req, offset, filter, withPayload)
 	if err != nil {
-		log.Printf("Qdrant search error: %v", err)
-		http.Error(w, `{"error": "Search failed"}`, http.StatusInternalServerError)
+		log.Printf("Search error: %v", err)
+		apperrors.Write(w, err, "Search failed")
+		return
+	}
+	scale := searchScale(&req, reranked)
+	if offset == 0 && !req.Rerank && len(req.Collections) == 0 && snapshot == nil && (req.Mode == "" || req.Mode == "dense") {
+		s.Shadow.Mirror(r, req.Query, req.Limit, req.IncludeHistorical, filter, searchResult.Result, time.Since(start))
+	}
+
+	if exportStyle != "" {
+		articles := make([]*models.MedicalArticle, len(searchResult.Result))
+		for i, point := range searchResult.Result {
+			articles[i] = ai.ArticleFromPayload(point.Payload)
+		}
+		s.writeCitationFile(w, articles, exportStyle, "medatlas-results")
 		return
 	}
 
+	s.logQuery(r, req.Query, req.Limit, len(searchResult.Result))
+
 	results := make([]SearchResponse, len(searchResult.Result))
 	for i, point := range searchResult.Result {
-		payload := point.Payload
 		results[i] = SearchResponse{
-			ID:            formatPointID(point.Id),
-			Title:         safeGetString(payload, "title"),
-			Abstract:      safeGetString(payload, "abstract"),
-			Authors:       safeGetString(payload, "authors"),
-			PublishedDate: safeGetString(payload, "published_date"),
-			DOI:           safeGetString(payload, "doi"),
-			Score:         point.Score,
+			ID:         formatPointID(point.Id),
+			Score:      point.Score,
+			Relevance:  s.Calibration.Label(scale, point.Score),
+			ScoreScale: scale,
 		}
+		payload.Decode(point.Payload, &results[i])
 	}
 
-	if err := json.NewEncoder(w).Encode(results); err != nil {
+	var body any = results
+	switch req.GroupBy {
+	case GroupByRegion:
+		body = groupByRegion(searchResult.Result, results)
+	case GroupByStudy:
+		body = groupByStudy(searchResult.Result, results)
+	default:
+		if req.paginated() {
+			body = s.searchPage(ctx, results, offset, req.Limit, filter)
+		}
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, `{"error": "Error formatting response"}`, http.StatusInternalServerError)
 	}
 }
 
+// validateSearch checks a /search request and resolves its limit, filter and
+// offset, writing the error and returning false when it is not valid
+func (s *Server) validateSearch(w http.ResponseWriter, req *SearchRequest) (*qdrant.Filter, int, bool) {
+	if strings.TrimSpace(req.Query) == "" {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Query parameter is required")
+		return nil, 0, false
+	}
+	if err := req.resolveLimit(s.Config.Current()); err != nil {
+		apperrors.Write(w, err, err.Error())
+		return nil, 0, false
+	}
+	if topic, ok := safety.ExcludedTopicIn(req.Query, s.Config.Current().Safety.ExcludedTopics); ok {
+		apperrors.Write(w, apperrors.ErrUnsafeContent, fmt.Sprintf("Searches about %s are not supported by this service", topic))
+		return nil, 0, false
+	}
+	if req.GroupBy != "" && !isGroupBy(req.GroupBy) {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "group_by must be region or study")
+		return nil, 0, false
+	}
+	switch req.Mode {
+	case "", "dense", SearchModeHybrid:
+	case SearchModeMaxSim:
+		if s.MultiVector == nil {
+			apperrors.Write(w, apperrors.ErrSearchUnavailable, "maxsim search is not enabled on this server")
+			return nil, 0, false
+		}
+	default:
+		apperrors.Write(w, apperrors.ErrInvalidInput, "mode must be dense, hybrid or maxsim")
+		return nil, 0, false
+	}
+	if err := req.validateCollections(config.CurrentCollections()); err != nil {
+		apperrors.Write(w, err, err.Error())
+		return nil, 0, false
+	}
+	if req.Rerank && s.Reranker == nil {
+		apperrors.Write(w, apperrors.ErrSearchUnavailable, "reranking is not enabled on this server")
+		return nil, 0, false
+	}
+	filter, err := req.Filters.qdrantFilter()
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return nil, 0, false
+	}
+	offset, err := req.offset()
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return nil, 0, false
+	}
+	return filter, offset, true
+}
+
+// searchContext returns ctx reading the snapshot, tiers and keywords req asks
+// for, along with the snapshot, if any
+func (s *Server) searchContext(ctx context.Context, req *SearchRequest) (context.Context, *snapshots.Snapshot, error) {
+	ctx, snapshot, err := s.withAsOf(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.IncludeHistorical {
+		ctx = tiering.WithHistorical(ctx)
+	}
+	if req.Mode == SearchModeHybrid {
+		ctx = search.WithKeywords(ctx, req.Query)
+	}
+	return ctx, snapshot, nil
+}
+
+// retrieve runs a validated request through the search mode, collections
+// and reranking it asks for and returns the page at offset. It reports
+// whether the page was reranked.
+func (s *Server) retrieve(ctx context.Context, req *SearchRequest, offset int, filter *qdrant.Filter, withPayload *qdrant.WithPayloadSelector) (*qdrant.SearchResponse, bool, error) {
+	// Reranking orders the top candidates, and the page is cut from them
+	limit, skip := req.Limit, offset
+	if req.Rerank {
+		limit, skip = rerank.Candidates(offset+req.Limit), 0
+		withPayload = withPassageFields(withPayload)
+	}
+	var result *qdrant.SearchResponse
+	var err error
+	if req.Mode == SearchModeMaxSim {
+		result, err = multivector.Search(ctx, s.MultiVector, s.Embedder, req.Query, limit, skip, filter, withPayload)
+	} else if len(req.Collections) > 0 {
+		result, err = s.searchCollections(ctx, req.Query, req.Collections, limit, skip, filter, withPayload)
+	} else {
+		result, err = s.runSearch(ctx, req.Query, limit, skip, filter, withPayload)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !req.Rerank {
+		return result, false, nil
+	}
+	return result, s.rerankPage(ctx, req.Query, result, offset, req.Limit), nil
+}
+
+// rerankPage orders result by the reranker and cuts the page at offset.
+// It reports whether the points were reranked and so carry reranker scores.
+// When reranking fails the retrieval order is kept, as chat does.
+func (s *Server) rerankPage(ctx context.Context, query string, result *qdrant.SearchResponse, offset, limit int) bool {
+	points, err := rerank.Points(ctx, s.Reranker, query, result.Result)
+	if err != nil {
+		log.Printf("⚠️  Rerank failed, keeping retrieval order: %v", err)
+		points = result.Result
+	}
+	if offset >= len(points) {
+		points = nil
+	} else {
+		points = points[offset:min(len(points), offset+limit)]
+	}
+	result.Result = points
+	return err == nil
+}
+
+// withPassageFields adds the fields the reranker reads to a payload selector
+func withPassageFields(selector *qdrant.WithPayloadSelector) *qdrant.WithPayloadSelector {
+	include := selector.GetInclude()
+	if include == nil {
+		return selector
+	}
+	fields := append([]string(nil), include.GetFields()...)
+	for _, field := range rerank.PayloadFields {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+		Include: &qdrant.PayloadIncludeSelector{Fields: fields},
+	}}
+}
+
+func (s *Server) citationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	style, err := data.ParseCitationStyle(r.URL.Query().Get("style"))
+	if err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, err.Error())
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	article, err := ai.GetArticle(r.Context(), s.Points, id)
+	if err != nil {
+		log.Printf("Article lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load article")
+		return
+	}
+
+	citation, err := data.FormatCitation(article, style)
+	if err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(CitationResponse{ID: id, Style: style, Citation: citation})
+}
+
+// writeCitationFile sends articles as a downloadable reference manager file
+func (s *Server) writeCitationFile(w http.ResponseWriter, articles []*models.MedicalArticle, style, basename string) {
+	body, err := data.FormatCitations(articles, style)
+	if err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, err.Error())
+		return
+	}
+	contentType, extension := data.CitationFileInfo(style)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", basename+"."+extension))
+	w.Write([]byte(body))
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "service": "medical-Atlas-api"})
@@ -125,9 +509,15 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	if s.Warmup != nil && !s.Warmup.Done() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "warming up"})
+		return
+	}
+
 	ctx := r.Context()
 	_, err := s.QdrantClient.Search(ctx, &qdrant.SearchPoints{
-		CollectionName: "medical_abstracts",
+		CollectionName: tiering.HistoricalCollection(),
 		Vector:         make([]float32, 384), //Dummy vector
 		Limit:          1,
 	})
@@ -147,33 +537,234 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	embeddedHost := os.Getenv("EMBEDDING_SERVICE_HOST")
-	if embeddedHost == "" { //Keep localhost for now
-		embeddedHost = "http://localhost:8000"
+	configStore, err := config.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
-	embedder := embeddingClient.NewClient(embeddedHost)
-	qdrantHost := os.Getenv("QDRANT_HOST")
-	if qdrantHost == "" { //Keep localhost for now
-		qdrantHost = "localhost:6334"
+	if _, err := config.LoadCollections(""); err != nil {
+		log.Fatalf("Invalid collection settings: %v", err)
+	}
+	if err := tracing.Setup("medatlas-api"); err != nil {
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+	shutdownTimeout, err := shutdown.TimeoutFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid shutdown settings: %v", err)
 	}
+	// Cancelled on SIGINT or SIGTERM, which also stops background work
+	ctx, stop := shutdown.Context()
+	defer stop()
+	rateLimiter := middleware.NewRateLimiter(0, 0)
+	quota := middleware.AnonymousQuotaFromEnv()
+	configStore.OnChange(func(t *config.Tunables) {
+		logging.SetLevel(logging.ParseLevel(t.LogLevel))
+		rateLimiter.Update(t.RateLimit.RequestsPerMinute, t.RateLimit.Burst)
+		quota.Update(t.Quota.DailyQueries, t.Quota.DailyPerIP)
+	})
+	go configStore.Watch(ctx, config.ReloadInterval)
+
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
 
-	conn, err := grpc.Dial(qdrantHost, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()))
 	if err != nil {
 		log.Fatalf("Could not connect to Qdrant: %v", err)
 	}
 	defer conn.Close()
-	qdrantClient := qdrant.NewPointsClient(conn)
+	// Abstracts indexed with -payload-refs are read back from the content store
+	content := contentstore.FromEnv()
+	// Searches made as of a snapshot are redirected to its frozen collections
+	// below the tiering client, so each tier is redirected on its own
+	var qdrantClient points.Reader = contentstore.NewClient(tiering.NewClient(snapshots.NewClient(metrics.NewClient(qdrant.NewPointsClient(conn)))), content)
+	// With DOC_STORE set, result payloads are completed from the full records
+	docs, err := docstore.FromEnv()
+	if err != nil {
+		log.Fatalf("Could not open document store: %v", err)
+	}
+	if docs != nil {
+		qdrantClient = docstore.NewClient(qdrantClient, docs)
+	}
+	// Articles on the configured excluded topics are never returned
+	qdrantClient = exclusion.NewClient(qdrantClient, configStore)
+	// and sensitive passages in the rest are masked or dropped
+	qdrantClient = screening.NewClient(qdrantClient, configStore)
+	// Each request only reads the access labels its API key is entitled to
+	qdrantClient = access.NewClient(qdrantClient)
+	apiKeys, err := access.OpenKeys(access.KeysPathFromEnv())
+	if err != nil {
+		log.Fatalf("Could not load API keys: %v", err)
+	}
+	go apiKeys.Watch(ctx, config.ReloadInterval)
 
-	server := &Server{
-		QdrantClient: qdrantClient,
-		Embedder:     embedder,
+	server := NewServer(embedder, search.NewHybrid(qdrantClient, tiering.HistoricalCollection()), configStore)
+	server.AuditDir = os.Getenv("AUDIT_DIR")
+	if server.AuditDir == "" {
+		server.AuditDir = audit.DefaultDir
+	}
+	server.Audit, err = audit.Open(server.AuditDir, "api")
+	if err != nil {
+		log.Fatalf("Could not open audit log: %v", err)
+	}
+	defer server.Audit.Close()
+	server.Audit.TrackConfig(configStore)
+
+	queryLogPath := os.Getenv("QUERY_LOG_FILE")
+	if queryLogPath == "" {
+		queryLogPath = recordlog.DefaultQueryLogPath
+	}
+	recordKeys, err := encryption.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid encryption keys: %v", err)
+	}
+	if recordKeys == nil {
+		log.Printf("⚠️  ENCRYPTION_KEYS not set; %s is stored unencrypted", queryLogPath)
+	}
+	server.QueryLog, err = recordlog.Open(queryLogPath, recordKeys)
+	if err != nil {
+		log.Fatalf("Could not open query log: %v", err)
+	}
+	defer server.QueryLog.Close()
+	go retention.NewScheduler(retention.Policy{
+		Name:   "query logs",
+		Target: server.QueryLog,
+		MaxAge: func() time.Duration { return retention.Days(configStore.Current().Retention.QueryLogDays) },
+	}).Run(ctx, retention.PurgeInterval)
+	driftMonitor := drift.NewMonitor(embedder, alert.FromEnv())
+	if path := os.Getenv("DRIFT_BASELINE_FILE"); path != "" {
+		driftMonitor.BaselinePath = path
+	}
+	go driftMonitor.Run(ctx, drift.CheckInterval)
+
+	server.Points = qdrantClient
+	server.Trials = qdrantClient
+	server.Counter = qdrantClient
+	collections := qdrant.NewCollectionsClient(conn)
+	if exists, err := collections.CollectionExists(context.Background(), &qdrant.CollectionExistsRequest{CollectionName: multivector.Collection}); err == nil && exists.GetResult().GetExists() {
+		server.MultiVector = qdrantClient
+		log.Printf("🧪 Experimental maxsim search enabled over %s", multivector.Collection)
+	}
+	if exists, err := collections.CollectionExists(context.Background(), &qdrant.CollectionExistsRequest{CollectionName: data.ChunksCollection}); err == nil && exists.GetResult().GetExists() {
+		// Passages of long abstracts are searched too, and grouped into their articles
+		server.QdrantClient = search.NewChunked(server.QdrantClient, qdrantClient, tiering.HistoricalCollection())
+		log.Printf("🧩 Searching abstract passages in %s", data.ChunksCollection)
+	}
+	server.Reranker = embedder
+
+	// The shadow pipeline uses its own embedding service when one is set,
+	// e.g. to try a new embedding model against a copy of the index
+	shadowEmbedder := embedder
+	if host := os.Getenv("SHADOW_EMBEDDING_SERVICE_HOST"); host != "" {
+		shadowEmbedder = embeddingClient.NewClient(host)
+	}
+	shadowLogPath := os.Getenv("SHADOW_LOG_FILE")
+	if shadowLogPath == "" {
+		shadowLogPath = recordlog.DefaultShadowLogPath
+	}
+	shadowLog, err := recordlog.Open(shadowLogPath, recordKeys)
+	if err != nil {
+		log.Fatalf("Could not open shadow log: %v", err)
+	}
+	defer shadowLog.Close()
+	go retention.NewScheduler(retention.Policy{
+		Name:   "shadow diffs",
+		Target: shadowLog,
+		MaxAge: func() time.Duration { return retention.Days(configStore.Current().Retention.QueryLogDays) },
+	}).Run(ctx, retention.PurgeInterval)
+	server.Shadow = &Shadow{Server: server, Embedder: shadowEmbedder, Reranker: embedder, Log: shadowLog}
+
+	savedSearchPath := os.Getenv("SAVED_SEARCHES_FILE")
+	if savedSearchPath == "" {
+		savedSearchPath = savedsearch.DefaultPath
+	}
+	server.SavedSearches, err = savedsearch.NewStore(savedSearchPath)
+	if err != nil {
+		log.Fatalf("Could not load saved searches: %v", err)
+	}
+
+	server.Synonyms, err = synonyms.NewStore(synonyms.PathFromEnv())
+	if err != nil {
+		log.Fatalf("Could not load synonyms: %v", err)
+	}
+	go server.Synonyms.Watch(ctx, config.ReloadInterval)
+
+	server.Calibration, err = calibration.Open(calibration.PathFromEnv())
+	if err != nil {
+		log.Fatalf("Could not load relevance feedback: %v", err)
+	}
+	go server.Calibration.Watch(ctx, config.ReloadInterval)
+
+	snapshotStore, err := snapshots.NewStore(snapshots.PathFromEnv())
+	if err != nil {
+		log.Fatalf("Could not load snapshots: %v", err)
+	}
+	server.Snapshots = snapshots.NewManager(snapshotStore, conn, snapshots.RESTURLFromEnv())
+	// Bulk deletes bypass the read wrappers, so excluded and screened points
+	// are counted and deleted too
+	server.BulkDelete = bulkdelete.NewDeleter(qdrant.NewPointsClient(conn), collections, bulkDeleteTargets(), bulkdelete.SecretFromEnv())
+
+	workspacesPath := os.Getenv("WORKSPACES_FILE")
+	if workspacesPath == "" {
+		workspacesPath = workspace.DefaultPath
+	}
+	server.Workspaces, err = workspace.NewStore(workspacesPath)
+	if err != nil {
+		log.Fatalf("Could not load workspaces: %v", err)
+	}
+
+	adminAccess, err := middleware.AdminAccessFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid admin access settings: %v", err)
+	}
+	tlsSettings := tlsSettingsFromEnv()
+	tlsConfig, err := tlsSettings.config()
+	if err != nil {
+		log.Fatalf("Invalid TLS settings: %v", err)
+	}
+	if adminAccess.RequireClientCert && tlsSettings.ClientCAFile == "" {
+		log.Fatal("ADMIN_REQUIRE_CLIENT_CERT requires TLS_CLIENT_CA_FILE")
 	}
 
 	// Routing
 	r := mux.NewRouter()
-	r.HandleFunc("/search", server.searchHandler).Methods("POST")
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Handle("/search", quota.Middleware(http.HandlerFunc(server.searchHandler))).Methods("POST")
+	r.HandleFunc("/quota", quota.StatusHandler).Methods("GET")
+	r.HandleFunc("/articles/{id}/citation", server.citationHandler).Methods("GET")
+	r.HandleFunc("/articles/{id}/trials", server.articleTrialsHandler).Methods("GET")
+	r.HandleFunc("/articles/{id}/similar", server.similarHandler).Methods("GET")
+	r.HandleFunc("/trials/{nct}/articles", server.trialArticlesHandler).Methods("GET")
+	r.HandleFunc("/saved-searches", server.saveSearchHandler).Methods("POST")
+	r.HandleFunc("/saved-searches/{id}", server.getSavedSearchHandler).Methods("GET")
+	r.HandleFunc("/export", server.exportHandler).Methods("POST")
+	r.HandleFunc("/feedback", server.feedbackHandler).Methods("POST")
+	server.registerWorkspaceRoutes(r)
+
+	// Admin routes can read audit data or change the corpus, so they are
+	// additionally restricted by network and, optionally, client certificate
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(adminAccess.Middleware)
+	admin.HandleFunc("/audit", server.auditHandler).Methods("GET")
+	admin.HandleFunc("/synonyms", server.listSynonymsHandler).Methods("GET")
+	admin.HandleFunc("/synonyms", server.createSynonymsHandler).Methods("POST")
+	admin.HandleFunc("/synonyms/{id}", server.updateSynonymsHandler).Methods("PUT")
+	admin.HandleFunc("/synonyms/{id}", server.deleteSynonymsHandler).Methods("DELETE")
+	admin.HandleFunc("/snapshots", server.listSnapshotsHandler).Methods("GET")
+	admin.HandleFunc("/snapshots", server.createSnapshotHandler).Methods("POST")
+	admin.HandleFunc("/snapshots/{name}", server.deleteSnapshotHandler).Methods("DELETE")
+	admin.HandleFunc("/points/delete", server.bulkDeleteHandler).Methods("POST")
+	admin.HandleFunc("/calibration", server.calibrationHandler).Methods("GET")
+
+	// Search diagnostics reveal scores and corpus internals, so they sit
+	// behind the same restrictions as the admin routes
+	debug := r.PathPrefix("/debug").Subrouter()
+	debug.Use(adminAccess.Middleware)
+	debug.HandleFunc("/search", server.debugSearchHandler).Methods("GET", "POST")
+
+	r.HandleFunc("/me/data", server.deleteMyDataHandler).Methods("DELETE")
 	r.HandleFunc("/health", server.healthHandler).Methods("GET")
+	r.HandleFunc("/version", buildinfo.Handler("api", embedder, "")).Methods("GET")
 	r.HandleFunc("/ready", server.readyHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -184,8 +775,9 @@ func main() {
 	corsMiddleware := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+identity.UserHeader)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Quota-Limit, X-Quota-Remaining, "+SnapshotHeader)
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -195,5 +787,26 @@ func main() {
 		})
 	}
 	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, corsMiddleware(r)))
+	handler := middleware.Chain(r, middleware.Recover, middleware.AccessLog, corsMiddleware, identity.Middleware, apiKeys.Middleware, rateLimiter.Middleware, middleware.LimitBody(middleware.DefaultMaxBodyBytes))
+	server.Warmup = &warmup.Gate{}
+	go server.Warmup.Run(ctx, warmup.DefaultTimeout, server.warmupSteps()...)
+
+	httpServer := &http.Server{Addr: ":" + port, Handler: handler, TLSConfig: tlsConfig}
+	listen := httpServer.ListenAndServe
+	if tlsSettings.enabled() {
+		log.Printf("🔒 Serving HTTPS (client CA: %t)", tlsSettings.ClientCAFile != "")
+		listen = func() error { return httpServer.ListenAndServeTLS(tlsSettings.CertFile, tlsSettings.KeyFile) }
+	}
+	if err := shutdown.Serve(ctx, httpServer, listen, shutdownTimeout); err != nil {
+		if ctx.Err() == nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+		log.Printf("⚠️  %v", err)
+	}
+
+	// Deferred closes of the logs and the Qdrant connection run on return
+	embedder.HTTPClient.CloseIdleConnections()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tracing.Flush(flushCtx)
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"MedAtlasAIServer/internal/apperrors"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// fakeEmbedder returns vector, or err when it is set
+type fakeEmbedder struct {
+	vector []float32
+	err    error
+}
+
+func (e fakeEmbedder) GetEmbedding(text string) ([]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.vector, nil
+}
+
+// fakeSearcher returns points, or err when it is set, and records the last
+// request
+type fakeSearcher struct {
+	points []*qdrant.ScoredPoint
+	err    error
+	last   *qdrant.SearchPoints
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	s.last = in
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &qdrant.SearchResponse{Result: s.points}, nil
+}
+
+func testPoint(id uint64, score float32, title string) *qdrant.ScoredPoint {
+	return &qdrant.ScoredPoint{
+		Id:      qdrant.NewIDNum(id),
+		Score:   score,
+		Payload: qdrant.NewValueMap(map[string]any{"title": title, "abstract": "An abstract."}),
+	}
+}
+
+func newTestServer(searcher *fakeSearcher) *Server {
+	return NewServer(fakeEmbedder{vector: []float32{0.1, 0.2, 0.3}}, searcher, nil)
+}
+
+func postSearch(s *Server, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.searchHandler(rec, httptest.NewRequest(http.MethodPost, "/search", bytes.NewReader(body)))
+	return rec
+}
+
+func TestSearchHandler(t *testing.T) {
+	searcher := &fakeSearcher{points: []*qdrant.ScoredPoint{testPoint(1, 0.91, "First study"), testPoint(2, 0.84, "Second study")}}
+	rec := postSearch(newTestServer(searcher), []byte(`{"query": "statins and dementia", "limit": 2}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var results []SearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "1" || results[0].Title != "First study" || results[1].Score != 0.84 {
+		t.Errorf("results = %+v", results)
+	}
+	if searcher.last.GetLimit() != 2 || len(searcher.last.GetVector()) != 3 {
+		t.Errorf("search request = %v", searcher.last)
+	}
+}
+
+func TestSearchHandlerValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"malformed JSON", `{"query": `, http.StatusBadRequest},
+		{"missing query", `{"limit": 5}`, http.StatusBadRequest},
+		{"blank query", `{"query": "   "}`, http.StatusBadRequest},
+		{"negative limit", `{"query": "aspirin", "limit": -1}`, http.StatusUnprocessableEntity},
+		{"limit over the maximum", `{"query": "aspirin", "limit": 100000}`, http.StatusUnprocessableEntity},
+		{"unknown format", `{"query": "aspirin", "format": "pdf"}`, http.StatusBadRequest},
+		{"unknown group_by", `{"query": "aspirin", "group_by": "author"}`, http.StatusBadRequest},
+		{"unknown mode", `{"query": "aspirin", "mode": "sparse"}`, http.StatusBadRequest},
+		{"negative page", `{"query": "aspirin", "page": -1}`, http.StatusUnprocessableEntity},
+		{"page and offset", `{"query": "aspirin", "page": 2, "offset": 10}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searcher := &fakeSearcher{}
+			rec := postSearch(newTestServer(searcher), []byte(tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if searcher.last != nil {
+				t.Errorf("searched for an invalid request")
+			}
+		})
+	}
+}
+
+func TestDebugSearchHandler(t *testing.T) {
+	searcher := &fakeSearcher{points: []*qdrant.ScoredPoint{testPoint(1, 0.91, "First study")}}
+	s := newTestServer(searcher)
+
+	rec := httptest.NewRecorder()
+	body := `{"query": "statins and dementia", "limit": 3, "filters": {"journal": ["Lancet"]}}`
+	s.debugSearchHandler(rec, httptest.NewRequest(http.MethodPost, "/debug/search", bytes.NewReader([]byte(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var debug SearchDebug
+	if err := json.Unmarshal(rec.Body.Bytes(), &debug); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(debug.Final) != 1 || len(debug.VectorHits) != 1 || debug.EmbeddingDims != 3 {
+		t.Errorf("debug = %+v", debug)
+	}
+	if searcher.last.GetLimit() != 3 || searcher.last.GetFilter() == nil {
+		t.Errorf("search request = %v, want the request's limit and filters", searcher.last)
+	}
+
+	rec = httptest.NewRecorder()
+	s.debugSearchHandler(rec, httptest.NewRequest(http.MethodGet, "/debug/search?q=aspirin&limit=100000", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("limit over the maximum: status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSearchHandlerBackendErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		embedder fakeEmbedder
+		searcher *fakeSearcher
+	}{
+		{"embedding fails", fakeEmbedder{err: fmt.Errorf("%w: connection refused", apperrors.ErrEmbeddingUnavailable)}, &fakeSearcher{}},
+		{"search fails", fakeEmbedder{vector: []float32{0.1}}, &fakeSearcher{err: errors.New("qdrant unavailable")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postSearch(NewServer(tt.embedder, tt.searcher, nil), []byte(`{"query": "aspirin"}`))
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 503: %s", rec.Code, rec.Body)
+			}
+			var resp apperrors.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+				t.Errorf("error response = %s", rec.Body)
+			}
+		})
+	}
+}
+
+func FuzzSearchRequest(f *testing.F) {
+	for _, seed := range []string{
+		`{"query": "statins and dementia", "limit": 5}`,
+		`{"query": "BRCA1 c.68_69delAG", "filters": {"year_from": 2015}}`,
+		`{"query": "aspirin", "format": "ris", "group_by": "region"}`,
+		`{"query": "aspirin", "page": 2, "limit": 10}`,
+		`{"query": "aspirin", "mode": "maxsim"}`,
+		`{"query": "aspirin", "as_of": "2024-01-01"}`,
+		`{"query": null}`,
+		`[]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		s := newTestServer(&fakeSearcher{points: []*qdrant.ScoredPoint{testPoint(1, 0.9, "A study")}})
+		rec := postSearch(s, body)
+		if rec.Code >= 500 && rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status %d for %q: %s", rec.Code, body, rec.Body)
+		}
+		if rec.Header().Get("Content-Type") == "application/json" && !json.Valid(rec.Body.Bytes()) {
+			t.Fatalf("invalid JSON for %q: %s", body, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// notationFilter restricts a search to articles naming the variants in
+// query, or, for queries naming genes but no variant, the genes. Embeddings
+// blur "p.V600E" and "p.V600K" together, so these must match exactly.
+// It returns nil for queries without genetic notation.
+func notationFilter(query string) *qdrant.Filter {
+	field, values := notationTerms(query)
+	if field == "" {
+		return nil
+	}
+	return &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeywords(field, values...)}}
+}
+
+// notationTerms returns the payload field and values notationFilter matches
+func notationTerms(query string) (field string, values []string) {
+	if variants := data.ExtractVariants(query); len(variants) > 0 {
+		return "variants", variants
+	}
+	if genes := data.ExtractGeneSymbols(query); len(genes) > 0 {
+		return "genes", genes
+	}
+	return "", nil
+}
+
+// appendUnseen adds the hits of more that are not already in hits, up to limit
+func appendUnseen(hits, more []*qdrant.ScoredPoint, limit int) []*qdrant.ScoredPoint {
+	seen := make(map[string]bool, len(hits))
+	for _, hit := range hits {
+		seen[hit.GetId().String()] = true
+	}
+	for _, hit := range more {
+		if len(hits) >= limit {
+			break
+		}
+		if !seen[hit.GetId().String()] {
+			seen[hit.GetId().String()] = true
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/tiering"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// maxSearchOffset bounds how deep clients can page. Every page re-runs the
+// search for the results before it, so deep pages get expensive.
+const maxSearchOffset = 1000
+
+// pageTokenPrefix marks a next_page_token; the rest is the offset
+const pageTokenPrefix = "o:"
+
+// Counter counts the points matching a filter (implemented by
+// qdrant.PointsClient and tiering.Client)
+type Counter interface {
+	Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error)
+}
+
+// SearchPage is the /search response when the request asks for a page: the
+// results plus what a client needs to fetch the next one
+type SearchPage struct {
+	Results       []SearchResponse `json:"results"`
+	Offset        int              `json:"offset"`
+	NextPageToken string           `json:"next_page_token,omitempty"` // empty on the last page
+	TotalEstimate *uint64          `json:"total_estimate,omitempty"`  // approximate number of matching articles
+}
+
+// paginated reports whether req asked for a page rather than the top results
+func (req *SearchRequest) paginated() bool {
+	return req.Page != 0 || req.Offset != 0 || req.PageToken != ""
+}
+
+// resolveLimit applies the default limit to req, and rejects limits below
+// one or above the server's maximum, so no request pulls the collection
+func (req *SearchRequest) resolveLimit(tunables *config.Tunables) error {
+	if req.Limit == 0 {
+		req.Limit = tunables.SearchTopK
+	}
+	if req.Limit < 1 || req.Limit > tunables.MaxSearchLimit {
+		return apperrors.Invalid("limit", "limit must be between 1 and %d, got %d", tunables.MaxSearchLimit, req.Limit)
+	}
+	return nil
+}
+
+// offset resolves the page, offset or page token in req to a result offset
+func (req *SearchRequest) offset() (int, error) {
+	set := 0
+	for _, given := range []bool{req.Page != 0, req.Offset != 0, req.PageToken != ""} {
+		if given {
+			set++
+		}
+	}
+	if set > 1 {
+		return 0, fmt.Errorf("%w: use only one of page, offset and page_token", apperrors.ErrInvalidInput)
+	}
+
+	offset := req.Offset
+	switch {
+	case req.Page < 0:
+		return 0, apperrors.Invalid("page", "page must be at least 1, got %d", req.Page)
+	case req.Page > 0:
+		offset = (req.Page - 1) * req.Limit
+	case req.PageToken != "":
+		var err error
+		if offset, err = decodePageToken(req.PageToken); err != nil {
+			return 0, err
+		}
+	}
+	if offset < 0 || offset > maxSearchOffset {
+		return 0, apperrors.Invalid("offset", "offset must be between 0 and %d, got %d", maxSearchOffset, offset)
+	}
+	return offset, nil
+}
+
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil && strings.HasPrefix(string(raw), pageTokenPrefix) {
+		if offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), pageTokenPrefix)); err == nil {
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: invalid page_token", apperrors.ErrInvalidInput)
+}
+
+// estimateTotal approximately counts the articles a search with filter can
+// return, or nil when no Counter is configured or the count fails
+func (s *Server) estimateTotal(ctx context.Context, filter *qdrant.Filter) *uint64 {
+	if s.Counter == nil {
+		return nil
+	}
+	exact := false
+	resp, err := s.Counter.Count(ctx, &qdrant.CountPoints{
+		CollectionName: tiering.HistoricalCollection(),
+		Filter:         filter,
+		Exact:          &exact,
+	})
+	if err != nil {
+		log.Printf("⚠️  Result count failed: %v", err)
+		return nil
+	}
+	total := resp.GetResult().GetCount()
+	return &total
+}
+
+// searchPage wraps the results found at offset for a request of limit
+func (s *Server) searchPage(ctx context.Context, results []SearchResponse, offset, limit int, filter *qdrant.Filter) SearchPage {
+	page := SearchPage{Results: results, Offset: offset, TotalEstimate: s.estimateTotal(ctx, filter)}
+	next := offset + limit
+	more := len(results) == limit && next <= maxSearchOffset
+	if page.TotalEstimate != nil && uint64(next) >= *page.TotalEstimate {
+		more = false
+	}
+	if more {
+		page.NextPageToken = encodePageToken(next)
+	}
+	return page
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/retention"
+)
+
+// logQuery records a search for analytics; failures never affect the response
+func (s *Server) logQuery(r *http.Request, query string, limit, results int) {
+	err := s.QueryLog.Append(identity.UserFromContext(r.Context()), map[string]interface{}{
+		"query":   query,
+		"limit":   limit,
+		"results": results,
+	})
+	if err != nil {
+		log.Printf("⚠️  Query log write failed: %v", err)
+	}
+}
+
+// deleteMyDataHandler erases everything this service stores about the
+// signed-in user: query logs, shadow diffs, saved searches and workspace
+// content
+func (s *Server) deleteMyDataHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to delete your data")
+		return
+	}
+
+	deleted, err := retention.EraseUser(userID, map[string]retention.Eraser{
+		"query_logs":         s.QueryLog,
+		"shadow_diffs":       s.Shadow,
+		"relevance_feedback": s.Calibration,
+		"saved_searches": retention.EraserFunc(func(userID string) (int, error) {
+			searchIDs, err := s.SavedSearches.DeleteUser(userID)
+			if err != nil {
+				return 0, err
+			}
+			return len(searchIDs), s.Workspaces.ForgetSavedSearches(searchIDs)
+		}),
+		"workspaces": s.Workspaces,
+	})
+
+	details := make(map[string]string, len(deleted))
+	for name, n := range deleted {
+		details[name] = fmt.Sprint(n)
+	}
+	s.Audit.Record(r.Context(), "user_data.delete", userID, details)
+
+	if err != nil {
+		log.Printf("User data deletion incomplete for %s: %v", userID, err)
+		apperrors.Write(w, err, "Some data could not be deleted; please retry")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
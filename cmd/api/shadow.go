@@ -0,0 +1,155 @@
+package main
+
+import (
+	"MedAtlasAIServer/internal/access"
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/recordlog"
+	"MedAtlasAIServer/internal/rerank"
+	"MedAtlasAIServer/internal/tiering"
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// shadowTimeout bounds a mirrored search; it runs after the response is sent
+const shadowTimeout = 30 * time.Second
+
+// Shadow mirrors a sample of searches to an experimental pipeline, set by
+// the "shadow" tunables and SHADOW_EMBEDDING_SERVICE_HOST, and logs how its
+// results differ from what the client received for offline evaluation
+type Shadow struct {
+	Server   *Server
+	Embedder ai.Embedder    // embedding model of the experimental pipeline
+	Reranker ai.Reranker    // used when the shadow.rerank tunable is set
+	Log      *recordlog.Log // receives one ShadowDiff per mirrored query
+}
+
+// ShadowHit is one result of either pipeline
+type ShadowHit struct {
+	ID    string  `json:"id"`
+	Score float32 `json:"score"`
+}
+
+// ShadowDiff compares the primary and experimental results for one query.
+// Rank deltas are positive when the experimental pipeline ranks a result
+// higher; results it did not return are listed in Dropped.
+type ShadowDiff struct {
+	Query               string         `json:"query"`
+	Limit               int            `json:"limit"`
+	TopK                int            `json:"shadow_top_k"`
+	Rerank              bool           `json:"shadow_rerank"`
+	Primary             []ShadowHit    `json:"primary"`
+	Experimental        []ShadowHit    `json:"experimental"`
+	Overlap             float64        `json:"overlap"` // Jaccard overlap of the result IDs
+	Added               []string       `json:"added,omitempty"`
+	Dropped             []string       `json:"dropped,omitempty"`
+	RankDeltas          map[string]int `json:"rank_deltas,omitempty"`
+	PrimaryLatency      time.Duration  `json:"primary_latency_ns"`
+	ExperimentalError   string         `json:"experimental_error,omitempty"`
+	ExperimentalLatency time.Duration  `json:"experimental_latency_ns"`
+}
+
+// Mirror runs the experimental pipeline for a sampled query in the
+// background. It never affects the primary response.
+func (sh *Shadow) Mirror(r *http.Request, query string, limit int, historical bool, filter *qdrant.Filter, primary []*qdrant.ScoredPoint, latency time.Duration) {
+	if sh == nil {
+		return
+	}
+	settings := sh.Server.Config.Current().Shadow
+	if settings.SampleRate <= 0 || rand.Float64() >= settings.SampleRate {
+		return
+	}
+	userID := identity.UserFromContext(r.Context())
+	labels, restricted := access.EntitlementsFromContext(r.Context())
+	primaryHits := shadowHits(primary)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+		if historical {
+			ctx = tiering.WithHistorical(ctx)
+		}
+		if restricted {
+			ctx = access.WithEntitlements(ctx, labels)
+		}
+		diff := sh.compare(ctx, query, limit, filter, settings.TopK, settings.Rerank, primaryHits)
+		diff.PrimaryLatency = latency
+		if err := sh.Log.Append(userID, diff); err != nil {
+			log.Printf("⚠️  Shadow log write failed: %v", err)
+		}
+	}()
+}
+
+// DeleteUser erases the diffs logged for userID's queries. A nil Shadow has
+// none.
+func (sh *Shadow) DeleteUser(userID string) (int, error) {
+	if sh == nil {
+		return 0, nil
+	}
+	return sh.Log.DeleteUser(userID)
+}
+
+// compare runs the experimental pipeline and diffs it against primary
+func (sh *Shadow) compare(ctx context.Context, query string, limit int, filter *qdrant.Filter, topK int, reranked bool, primary []ShadowHit) ShadowDiff {
+	if topK == 0 {
+		topK = limit
+	}
+	diff := ShadowDiff{Query: query, Limit: limit, TopK: topK, Rerank: reranked, Primary: primary}
+
+	start := time.Now()
+	withPayload := &qdrant.WithPayloadSelector{
+		SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: []string{"title", "abstract"}},
+		},
+	}
+	result, err := sh.Server.searchWith(ctx, sh.Embedder, query, topK, 0, filter, withPayload, nil)
+	if err == nil && reranked && sh.Reranker != nil {
+		result.Result, err = rerank.Points(ctx, sh.Reranker, query, result.Result)
+	}
+	diff.ExperimentalLatency = time.Since(start)
+	if err != nil {
+		diff.ExperimentalError = err.Error()
+		return diff
+	}
+	diff.Experimental = shadowHits(result.Result)
+
+	primaryRank := make(map[string]int, len(primary))
+	for i, hit := range primary {
+		primaryRank[hit.ID] = i
+	}
+	experimentalRank := make(map[string]int, len(diff.Experimental))
+	for i, hit := range diff.Experimental {
+		experimentalRank[hit.ID] = i
+		if rank, ok := primaryRank[hit.ID]; !ok {
+			diff.Added = append(diff.Added, hit.ID)
+		} else if rank != i {
+			if diff.RankDeltas == nil {
+				diff.RankDeltas = make(map[string]int)
+			}
+			diff.RankDeltas[hit.ID] = rank - i
+		}
+	}
+	for _, hit := range primary {
+		if _, ok := experimentalRank[hit.ID]; !ok {
+			diff.Dropped = append(diff.Dropped, hit.ID)
+		}
+	}
+	union := len(primary) + len(diff.Added)
+	diff.Overlap = 1
+	if union > 0 {
+		diff.Overlap = float64(len(primary)-len(diff.Dropped)) / float64(union)
+	}
+	return diff
+}
+
+func shadowHits(points []*qdrant.ScoredPoint) []ShadowHit {
+	hits := make([]ShadowHit, len(points))
+	for i, point := range points {
+		hits[i] = ShadowHit{ID: formatPointID(point.Id), Score: point.Score}
+	}
+	return hits
+}
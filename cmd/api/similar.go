@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/gorilla/mux"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SimilarResponse lists the articles nearest to an indexed article
+type SimilarResponse struct {
+	ArticleID string           `json:"article_id"`
+	Articles  []SearchResponse `json:"articles"`
+}
+
+// similarHandler answers GET /articles/{id}/similar?limit=N with the
+// articles whose vectors are nearest to the article's own, across both
+// tiers. Rather than Qdrant's recommend API, the stored vector is read and
+// searched with, so both reads go through the same clients as /search and
+// honor exclusions and access labels.
+func (s *Server) similarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// The limit is resolved as /search resolves it
+	var req SearchRequest
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			apperrors.Write(w, apperrors.ErrInvalidInput, "limit must be a number")
+			return
+		}
+		req.Limit = n
+	}
+	if err := req.resolveLimit(s.Config.Current()); err != nil {
+		apperrors.Write(w, err, err.Error())
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	ctx := tiering.WithHistorical(r.Context())
+	vector, err := s.articleVector(ctx, id)
+	if err != nil {
+		log.Printf("Article lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load article")
+		return
+	}
+
+	// The article's passages carry its id, and are left out with it
+	pointID := &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(id)}}
+	searchResult, err := s.QdrantClient.Search(ctx, &qdrant.SearchPoints{
+		CollectionName: tiering.HistoricalCollection(),
+		Vector:         vector,
+		Limit:          uint64(req.Limit),
+		Filter:         &qdrant.Filter{MustNot: []*qdrant.Condition{qdrant.NewHasID(pointID), qdrant.NewMatch("id", id)}},
+		WithPayload: &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: []string{"title", "abstract", "authors", "published_date", "doi"}},
+		}},
+	})
+	if err != nil {
+		log.Printf("Similar search error: %v", err)
+		apperrors.Write(w, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err), "Search failed")
+		return
+	}
+
+	resp := SimilarResponse{ArticleID: id, Articles: make([]SearchResponse, len(searchResult.Result))}
+	for i, point := range searchResult.Result {
+		resp.Articles[i] = SearchResponse{
+			ID:         formatPointID(point.Id),
+			Score:      point.Score,
+			Relevance:  s.Calibration.Label(calibration.ScaleCosine, point.Score),
+			ScoreScale: calibration.ScaleCosine,
+		}
+		payload.Decode(point.Payload, &resp.Articles[i])
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// articleVector returns the stored vector of the article with id. The
+// payload is read as well, so excluded articles are not found.
+func (s *Server) articleVector(ctx context.Context, id string) ([]float32, error) {
+	resp, err := s.Points.Get(ctx, &qdrant.GetPoints{
+		CollectionName: tiering.HistoricalCollection(),
+		Ids:            []*qdrant.PointId{{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(id)}}},
+		WithPayload:    fullPayload,
+		WithVectors:    qdrant.NewWithVectors(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+	for _, point := range resp.GetResult() {
+		vector := point.GetVectors().GetVector()
+		values := vector.GetData()
+		if dense := vector.GetDense(); dense != nil {
+			values = dense.GetData() // newer servers return dense vectors here
+		}
+		if len(values) > 0 {
+			return values, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: article %s is not indexed", apperrors.ErrNotFound, id)
+}
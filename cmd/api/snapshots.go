@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/snapshots"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/gorilla/mux"
+)
+
+// SnapshotHeader names the snapshot an as_of search was answered from
+const SnapshotHeader = "X-Snapshot"
+
+// CreateSnapshotRequest freezes the searched collections under Name
+type CreateSnapshotRequest struct {
+	Name string `json:"name"`
+	Note string `json:"note,omitempty"` // e.g. the decision the snapshot documents
+}
+
+// snapshotCollections are the collections a snapshot freezes: both tiers of
+// the article collection and the passages of long abstracts
+func snapshotCollections() []string {
+	return []string{tiering.HistoricalCollection(), tiering.RecentCollection(), data.ChunksCollection}
+}
+
+// withAsOf resolves req.AsOf and returns ctx reading from that snapshot
+func (s *Server) withAsOf(ctx context.Context, req *SearchRequest) (context.Context, *snapshots.Snapshot, error) {
+	if req.AsOf == "" {
+		return ctx, nil, nil
+	}
+	if s.Snapshots == nil {
+		return nil, nil, fmt.Errorf("%w: snapshots are not enabled on this server", apperrors.ErrSearchUnavailable)
+	}
+	if req.Mode == SearchModeMaxSim || len(req.Collections) > 0 {
+		return nil, nil, apperrors.Invalid("as_of", "snapshots cover the abstracts only; remove mode maxsim and collections")
+	}
+	snapshot, err := s.Snapshots.Store.Resolve(req.AsOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshots.WithSnapshot(ctx, snapshot), snapshot, nil
+}
+
+// listSnapshotsHandler answers GET /admin/snapshots with every snapshot,
+// oldest first
+func (s *Server) listSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"snapshots": s.Snapshots.Store.List()})
+}
+
+// createSnapshotHandler freezes the searched collections with POST
+// /admin/snapshots. It returns once the copies are searchable, which takes
+// as long as copying the collections.
+func (s *Server) createSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req CreateSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	snapshot, err := s.Snapshots.Create(r.Context(), req.Name, req.Note, snapshotCollections())
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	s.Audit.Record(r.Context(), "snapshot.create", snapshot.Name, map[string]string{"note": snapshot.Note})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// deleteSnapshotHandler removes a snapshot and its frozen collections with
+// DELETE /admin/snapshots/{name}
+func (s *Server) deleteSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := s.Snapshots.Delete(r.Context(), name); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	s.Audit.Record(r.Context(), "snapshot.delete", name, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	log.Printf("Snapshot error: %v", err)
+	message := "Snapshot operation failed"
+	if apperrors.StatusCode(err) < http.StatusInternalServerError {
+		message = err.Error()
+	}
+	apperrors.Write(w, err, message)
+}
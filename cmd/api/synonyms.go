@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/synonyms"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/gorilla/mux"
+)
+
+// SynonymGroupRequest creates or replaces a synonym group
+type SynonymGroupRequest struct {
+	Terms []string `json:"terms"`
+}
+
+// rewriteQuery is the text embedded for query: drugs are named by
+// ingredient as well as brand, and terms with admin-defined synonyms by
+// their synonyms too
+func (s *Server) rewriteQuery(query string) string {
+	return s.Synonyms.Expand(data.ExpandDrugNames(query))
+}
+
+// listSynonymsHandler answers GET /admin/synonyms with every group
+func (s *Server) listSynonymsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"groups": s.Synonyms.List()})
+}
+
+// createSynonymsHandler adds a group from POST /admin/synonyms
+func (s *Server) createSynonymsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req SynonymGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	group, err := s.Synonyms.Create(req.Terms)
+	if err == nil {
+		s.Audit.Record(r.Context(), "synonyms.create", group.ID, map[string]string{"terms": strings.Join(group.Terms, ", ")})
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeSynonymGroup(w, group, err)
+}
+
+// updateSynonymsHandler replaces the terms of a group with PUT /admin/synonyms/{id}
+func (s *Server) updateSynonymsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req SynonymGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	group, err := s.Synonyms.Update(mux.Vars(r)["id"], req.Terms)
+	if err == nil {
+		s.Audit.Record(r.Context(), "synonyms.update", group.ID, map[string]string{"terms": strings.Join(group.Terms, ", ")})
+	}
+	writeSynonymGroup(w, group, err)
+}
+
+// deleteSynonymsHandler removes a group with DELETE /admin/synonyms/{id}
+func (s *Server) deleteSynonymsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := s.Synonyms.Delete(id); err != nil {
+		writeSynonymGroup(w, nil, err)
+		return
+	}
+	s.Audit.Record(r.Context(), "synonyms.delete", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSynonymGroup(w http.ResponseWriter, group *synonyms.Group, err error) {
+	if err != nil {
+		log.Printf("Synonym error: %v", err)
+		message := "Synonym operation failed"
+		if apperrors.StatusCode(err) < http.StatusInternalServerError {
+			message = err.Error()
+		}
+		apperrors.Write(w, err, message)
+		return
+	}
+	json.NewEncoder(w).Encode(group)
+}
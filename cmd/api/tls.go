@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsSettings describes how the server terminates TLS, read from
+// TLS_CERT_FILE, TLS_KEY_FILE and TLS_CLIENT_CA_FILE
+type tlsSettings struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+func tlsSettingsFromEnv() tlsSettings {
+	return tlsSettings{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+	}
+}
+
+// enabled reports whether the server should serve HTTPS
+func (t tlsSettings) enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// config builds the server TLS config. With a client CA, certificates are
+// verified when offered but not demanded, so public endpoints keep working
+// and the admin middleware decides which routes require one.
+func (t tlsSettings) config() (*tls.Config, error) {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if t.ClientCAFile != "" && !t.enabled() {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.ClientCAFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(t.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", t.ClientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return cfg, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/trials"
+
+	"github.com/gorilla/mux"
+)
+
+// ArticleTrialsResponse links an article to the trials it reports on
+type ArticleTrialsResponse struct {
+	ArticleID string         `json:"article_id"`
+	Trials    []trials.Trial `json:"trials"`
+}
+
+// TrialArticlesResponse links a trial to the articles that cite it
+type TrialArticlesResponse struct {
+	Trial    trials.Trial    `json:"trial"`
+	Articles []LinkedArticle `json:"articles"`
+}
+
+// LinkedArticle is the short form of an article in a cross-link
+type LinkedArticle struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Journal       string `json:"journal,omitempty"`
+	PublishedDate string `json:"published_date,omitempty"`
+	DOI           string `json:"doi,omitempty"`
+}
+
+func (s *Server) articleTrialsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	linked, err := trials.ForArticle(r.Context(), s.Trials, id)
+	if err != nil {
+		log.Printf("Trial lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load trials")
+		return
+	}
+	json.NewEncoder(w).Encode(ArticleTrialsResponse{ArticleID: id, Trials: linked})
+}
+
+func (s *Server) trialArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	nctID, err := trials.NormalizeID(mux.Vars(r)["nct"])
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return
+	}
+	found, err := trials.Get(r.Context(), s.Trials, []string{nctID})
+	if err != nil {
+		log.Printf("Trial lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load trial")
+		return
+	}
+	articles, err := trials.Articles(r.Context(), s.Trials, nctID)
+	if err != nil {
+		log.Printf("Trial article lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load articles")
+		return
+	}
+
+	resp := TrialArticlesResponse{Trial: found[0], Articles: make([]LinkedArticle, 0, len(articles))}
+	for _, article := range articles {
+		linked := LinkedArticle{ID: article.ID, Title: article.Title, Journal: article.Journal, DOI: article.DOI}
+		if !article.PublishedDate.IsZero() {
+			linked.PublishedDate = article.PublishedDate.Format("2006-01-02")
+		}
+		resp.Articles = append(resp.Articles, linked)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"MedAtlasAIServer/internal/warmup"
+	"MedAtlasAIServer/pkg/data"
+)
+
+// warmupQuery is a representative search used to open the embedding and
+// Qdrant connections and page in the collection before real traffic
+const warmupQuery = "hypertension treatment outcomes"
+
+func (s *Server) warmupSteps() []warmup.Step {
+	return []warmup.Step{
+		{Name: "search", Run: func(ctx context.Context) error {
+			_, err := s.runSearch(ctx, warmupQuery, 1, 0, nil, nil)
+			return err
+		}},
+		{Name: "term dictionaries", Run: func(ctx context.Context) error {
+			data.WarmUp()
+			return nil
+		}},
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/workspace"
+
+	"github.com/gorilla/mux"
+)
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type BookmarkRequest struct {
+	ArticleID string `json:"article_id"`
+	Note      string `json:"note,omitempty"`
+}
+
+type ShareSearchRequest struct {
+	SavedSearchID string `json:"saved_search_id"`
+}
+
+func (s *Server) registerWorkspaceRoutes(r *mux.Router) {
+	r.HandleFunc("/workspaces", s.createWorkspaceHandler).Methods("POST")
+	r.HandleFunc("/workspaces", s.listWorkspacesHandler).Methods("GET")
+	r.HandleFunc("/workspaces/{id}", s.getWorkspaceHandler).Methods("GET")
+	r.HandleFunc("/workspaces/{id}", s.deleteWorkspaceHandler).Methods("DELETE")
+	r.HandleFunc("/workspaces/{id}/members/{userID}", s.setMemberHandler).Methods("PUT")
+	r.HandleFunc("/workspaces/{id}/members/{userID}", s.removeMemberHandler).Methods("DELETE")
+	r.HandleFunc("/workspaces/{id}/bookmarks", s.addBookmarkHandler).Methods("POST")
+	r.HandleFunc("/workspaces/{id}/bookmarks/{articleID}", s.removeBookmarkHandler).Methods("DELETE")
+	r.HandleFunc("/workspaces/{id}/saved-searches", s.shareSearchHandler).Methods("POST")
+	r.HandleFunc("/workspaces/{id}/saved-searches/{searchID}", s.unshareSearchHandler).Methods("DELETE")
+}
+
+// workspaceUser returns the acting user or writes a 401
+func workspaceUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to use workspaces")
+		return "", false
+	}
+	return userID, true
+}
+
+func writeWorkspace(w http.ResponseWriter, ws *workspace.Workspace, err error) {
+	if err != nil {
+		log.Printf("Workspace error: %v", err)
+		message := "Workspace operation failed"
+		if apperrors.StatusCode(err) < http.StatusInternalServerError {
+			message = err.Error()
+		}
+		apperrors.Write(w, err, message)
+		return
+	}
+	json.NewEncoder(w).Encode(ws)
+}
+
+func (s *Server) createWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	ws, err := s.Workspaces.Create(userID, req.Name)
+	if err == nil {
+		s.Audit.Record(r.Context(), "workspace.create", ws.ID, map[string]string{"name": ws.Name})
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) listWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	list := s.Workspaces.ListForUser(userID)
+	if list == nil {
+		list = []*workspace.Workspace{}
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+func (s *Server) getWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	ws, err := s.Workspaces.Get(userID, mux.Vars(r)["id"])
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) deleteWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := s.Workspaces.Delete(userID, id); err != nil {
+		writeWorkspace(w, nil, err)
+		return
+	}
+	s.Audit.Record(r.Context(), "workspace.delete", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	role, err := workspace.ParseRole(req.Role)
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
+		return
+	}
+
+	vars := mux.Vars(r)
+	ws, err := s.Workspaces.SetMember(userID, vars["id"], vars["userID"], role)
+	s.auditIfOK(r, err, "workspace.member.set", vars["id"], map[string]string{"member": vars["userID"], "role": string(role)})
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	ws, err := s.Workspaces.RemoveMember(userID, vars["id"], vars["userID"])
+	s.auditIfOK(r, err, "workspace.member.remove", vars["id"], map[string]string{"member": vars["userID"]})
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) addBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req BookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	ws, err := s.Workspaces.AddBookmark(userID, mux.Vars(r)["id"], req.ArticleID, req.Note)
+	s.auditIfOK(r, err, "workspace.bookmark.add", mux.Vars(r)["id"], map[string]string{"article_id": req.ArticleID})
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	ws, err := s.Workspaces.RemoveBookmark(userID, vars["id"], vars["articleID"])
+	s.auditIfOK(r, err, "workspace.bookmark.remove", vars["id"], map[string]string{"article_id": vars["articleID"]})
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) shareSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ShareSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if _, err := s.SavedSearches.Get(req.SavedSearchID); err != nil {
+		apperrors.Write(w, err, "Saved search not found")
+		return
+	}
+	ws, err := s.Workspaces.AddSavedSearch(userID, mux.Vars(r)["id"], req.SavedSearchID)
+	s.auditIfOK(r, err, "workspace.saved_search.add", mux.Vars(r)["id"], map[string]string{"saved_search_id": req.SavedSearchID})
+	writeWorkspace(w, ws, err)
+}
+
+func (s *Server) unshareSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, ok := workspaceUser(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	ws, err := s.Workspaces.RemoveSavedSearch(userID, vars["id"], vars["searchID"])
+	s.auditIfOK(r, err, "workspace.saved_search.remove", vars["id"], map[string]string{"saved_search_id": vars["searchID"]})
+	writeWorkspace(w, ws, err)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/annotations"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+
+	"github.com/gorilla/mux"
+)
+
+// maxReaderNotes caps how many annotations are added to a chat prompt
+const maxReaderNotes = 10
+
+type AnnotationRequest struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Kind  string `json:"kind,omitempty"` // "highlight" or "note"; inferred from note when empty
+	Note  string `json:"note,omitempty"`
+}
+
+type AnnotationUpdateRequest struct {
+	Note string `json:"note"`
+}
+
+func (cs *ChatServer) createAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to annotate articles")
+		return
+	}
+
+	var req AnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+
+	articleID := mux.Vars(r)["id"]
+	article, err := ai.GetArticle(r.Context(), cs.Points, articleID)
+	if err != nil {
+		log.Printf("Article lookup error: %v", err)
+		apperrors.Write(w, err, "Failed to load article")
+		return
+	}
+
+	annotation, err := cs.Annotations.Add(userID, articleID, article.Abstract, req.Kind, req.Start, req.End, req.Note)
+	if err != nil {
+		log.Printf("Annotation error: %v", err)
+		apperrors.Write(w, err, errorMessage(err, "Failed to save annotation"))
+		return
+	}
+	cs.Audit.Record(r.Context(), "annotation.create", annotation.ID, map[string]string{"article_id": articleID})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(annotation)
+}
+
+func (cs *ChatServer) listAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to view annotations")
+		return
+	}
+
+	list := cs.Annotations.List(userID, mux.Vars(r)["id"])
+	if list == nil {
+		list = []annotations.Annotation{}
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+func (cs *ChatServer) updateAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to edit annotations")
+		return
+	}
+
+	var req AnnotationUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+
+	annotation, err := cs.Annotations.UpdateNote(userID, mux.Vars(r)["annotationID"], req.Note)
+	if err != nil {
+		apperrors.Write(w, err, errorMessage(err, "Failed to update annotation"))
+		return
+	}
+	cs.Audit.Record(r.Context(), "annotation.update", annotation.ID, map[string]string{"article_id": annotation.ArticleID})
+	json.NewEncoder(w).Encode(annotation)
+}
+
+func (cs *ChatServer) deleteAnnotationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to delete annotations")
+		return
+	}
+
+	id := mux.Vars(r)["annotationID"]
+	if err := cs.Annotations.Delete(userID, id); err != nil {
+		apperrors.Write(w, err, errorMessage(err, "Failed to delete annotation"))
+		return
+	}
+	cs.Audit.Record(r.Context(), "annotation.delete", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withReaderNotes adds the user's annotations on articleID to ctx for the prompt
+func (cs *ChatServer) withReaderNotes(ctx context.Context, articleID string) context.Context {
+	userID := identity.UserFromContext(ctx)
+	if userID == "" || articleID == "" || cs.Annotations == nil {
+		return ctx
+	}
+
+	var notes []string
+	for _, a := range cs.Annotations.List(userID, articleID) {
+		if len(notes) == maxReaderNotes {
+			break
+		}
+		if a.Note != "" {
+			notes = append(notes, fmt.Sprintf("%q — %s", a.Quote, a.Note))
+		} else {
+			notes = append(notes, fmt.Sprintf("Highlighted: %q", a.Quote))
+		}
+	}
+	if len(notes) == 0 {
+		return ctx
+	}
+	return ai.WithReaderNotes(ctx, notes)
+}
+
+// errorMessage exposes validation details to the client and hides everything else
+func errorMessage(err error, fallback string) string {
+	if apperrors.StatusCode(err) < http.StatusInternalServerError {
+		return err.Error()
+	}
+	return fallback
+}
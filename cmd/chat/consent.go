@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/session"
+)
+
+// AcceptConsentRequest records acceptance of a terms/disclaimer version.
+// Anonymous clients pass the session_id from an earlier acceptance, or omit
+// it to be issued a new one.
+type AcceptConsentRequest struct {
+	Version   string `json:"version"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// ConsentStatus tells a client whether it must show the disclaimer
+type ConsentStatus struct {
+	Required        bool       `json:"required"`
+	RequiredVersion string     `json:"required_version"`
+	AcceptedVersion string     `json:"accepted_version,omitempty"`
+	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
+	Current         bool       `json:"current"`
+	SessionID       string     `json:"session_id,omitempty"`
+}
+
+// consentSettings returns the live consent configuration
+func (cs *ChatServer) consentSettings() config.Consent {
+	if cs.Config == nil {
+		return config.DefaultTunables().Consent
+	}
+	return cs.Config.Current().Consent
+}
+
+// consentSubject identifies who accepted: the signed-in user, else the
+// session. The namespaces are prefixed so a session ID can never stand in for
+// a user ID with the same value.
+func consentSubject(r *http.Request, sessionID string) string {
+	if userID := identity.UserFromContext(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	if sessionID = strings.TrimSpace(sessionID); sessionID != "" {
+		return "session:" + sessionID
+	}
+	return ""
+}
+
+// needsConsent reports whether message must be refused until the current
+// terms are accepted. Greetings and questions about the assistant are allowed.
+func (cs *ChatServer) needsConsent(r *http.Request, sessionID, message string) bool {
+	settings := cs.consentSettings()
+	if !settings.Required || ai.IsSmallTalk(message) {
+		return false
+	}
+	return cs.Consent == nil || !cs.Consent.HasAccepted(consentSubject(r, sessionID), settings.Version)
+}
+
+func (cs *ChatServer) consentStatus(subject string) ConsentStatus {
+	settings := cs.consentSettings()
+	status := ConsentStatus{Required: settings.Required, RequiredVersion: settings.Version}
+	if cs.Consent == nil || subject == "" {
+		return status
+	}
+	if latest, ok := cs.Consent.Latest(subject); ok {
+		status.AcceptedVersion = latest.Version
+		status.AcceptedAt = &latest.AcceptedAt
+	}
+	status.Current = cs.Consent.HasAccepted(subject, settings.Version)
+	return status
+}
+
+func (cs *ChatServer) acceptConsentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if cs.Consent == nil {
+		apperrors.Write(w, apperrors.ErrNotFound, "Consent tracking is not enabled")
+		return
+	}
+
+	var req AcceptConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if req.Version == "" {
+		req.Version = cs.consentSettings().Version
+	}
+
+	sessionID := ""
+	if identity.UserFromContext(r.Context()) == "" {
+		sessionID = strings.TrimSpace(req.SessionID)
+		if sessionID == "" {
+			sessionID = ids.Sessions.New()
+			// Chat only continues sessions the server issued, so the new
+			// ID is stored before the client can send it back
+			if cs.Sessions != nil {
+				if err := cs.Sessions.Save(r.Context(), &session.Session{ID: sessionID}); err != nil {
+					apperrors.Write(w, err, "Failed to start session")
+					return
+				}
+			}
+		}
+	}
+	subject := consentSubject(r, sessionID)
+
+	ack, err := cs.Consent.Accept(subject, req.Version)
+	if err != nil {
+		apperrors.Write(w, err, "Failed to record consent")
+		return
+	}
+	cs.Audit.Record(r.Context(), "consent.accept", subject, map[string]string{"version": ack.Version})
+
+	status := cs.consentStatus(subject)
+	status.SessionID = sessionID
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(status)
+}
+
+func (cs *ChatServer) consentStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cs.consentStatus(consentSubject(r, r.URL.Query().Get("session_id"))))
+}
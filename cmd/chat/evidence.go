@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/recordlog"
+
+	"github.com/gorilla/mux"
+)
+
+// EvidenceResponse shows an earlier answer with the evidence it was given,
+// re-rendered from its record
+type EvidenceResponse struct {
+	MessageID  string           `json:"message_id"`
+	AnsweredAt time.Time        `json:"answered_at"`
+	Response   string           `json:"response"`
+	Record     *ai.AnswerRecord `json:"record"`
+	Evidence   []EvidenceItem   `json:"evidence"`
+}
+
+// EvidenceItem is one recorded passage with its article as indexed now
+type EvidenceItem struct {
+	ai.RecordPassage
+	Journal   string `json:"journal,omitempty"`
+	DOI       string `json:"doi,omitempty"`
+	Abstract  string `json:"abstract,omitempty"`
+	Available bool   `json:"available"` // false when the article is no longer indexed
+}
+
+// transcriptEntry is the part of a logged exchange the evidence view reads
+type transcriptEntry struct {
+	MessageID string           `json:"message_id"`
+	Response  string           `json:"response"`
+	Record    *ai.AnswerRecord `json:"record"`
+}
+
+// evidenceHandler answers GET /api/answers/{messageID}/evidence for the user
+// who asked, or an admin. Answers are kept as long as chat transcripts are.
+func (cs *ChatServer) evidenceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	messageID := mux.Vars(r)["messageID"]
+
+	var entry transcriptEntry
+	logged, found := cs.Transcripts.Find(func(record recordlog.Record) bool {
+		if !bytes.Contains(record.Data, []byte(messageID)) {
+			return false
+		}
+		return json.Unmarshal(record.Data, &entry) == nil && entry.MessageID == messageID
+	})
+	admin := cs.AdminAccess != nil && cs.AdminAccess.Permits(r)
+	if found && logged.UserID != "" && logged.UserID != identity.UserFromContext(r.Context()) && !admin {
+		found = false
+	}
+	if !found || entry.Record == nil {
+		apperrors.Write(w, apperrors.ErrNotFound, "No evidence recorded for this answer")
+		return
+	}
+
+	response := EvidenceResponse{
+		MessageID:  messageID,
+		AnsweredAt: logged.Time,
+		Response:   entry.Response,
+		Record:     entry.Record,
+		Evidence:   make([]EvidenceItem, len(entry.Record.Passages)),
+	}
+	articleIDs := make([]string, len(entry.Record.Passages))
+	for i, passage := range entry.Record.Passages {
+		response.Evidence[i] = EvidenceItem{RecordPassage: passage}
+		articleIDs[i] = passage.ID
+	}
+	if cs.Points != nil && len(articleIDs) > 0 {
+		articles, err := ai.GetArticles(r.Context(), cs.Points, articleIDs)
+		if err != nil {
+			log.Printf("Evidence lookup error: %v", err)
+			apperrors.Write(w, err, "Failed to load evidence")
+			return
+		}
+		for _, article := range articles {
+			for i := range response.Evidence {
+				if item := &response.Evidence[i]; item.ID == article.ID {
+					item.Journal, item.DOI, item.Abstract, item.Available = article.Journal, article.DOI, article.Abstract, true
+				}
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,17 +1,49 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"MedAtlasAIServer/internal/access"
 	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/annotations"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/audit"
+	"MedAtlasAIServer/internal/buildinfo"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/consent"
+	"MedAtlasAIServer/internal/contentstore"
+	"MedAtlasAIServer/internal/docstore"
 	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/encryption"
+	"MedAtlasAIServer/internal/exclusion"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/locale"
+	"MedAtlasAIServer/internal/logging"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/middleware"
+	"MedAtlasAIServer/internal/points"
+	"MedAtlasAIServer/internal/recordlog"
+	"MedAtlasAIServer/internal/retention"
 	"MedAtlasAIServer/internal/safety"
+	"MedAtlasAIServer/internal/screening"
+	"MedAtlasAIServer/internal/session"
+	"MedAtlasAIServer/internal/shutdown"
+	"MedAtlasAIServer/internal/suggestions"
+	"MedAtlasAIServer/internal/synonyms"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/internal/tracing"
+	"MedAtlasAIServer/internal/warmup"
+	"MedAtlasAIServer/pkg/data"
+	"MedAtlasAIServer/pkg/search"
 
 	"github.com/gorilla/mux"
 	"github.com/qdrant/go-client/qdrant"
@@ -20,79 +52,386 @@ import (
 )
 
 type ChatRequest struct {
-	Message string           `json:"message"`
-	History []ai.ChatMessage `json:"history,omitempty"`
+	Message  string           `json:"message"`
+	History  []ai.ChatMessage `json:"history,omitempty"`
+	Language string           `json:"language,omitempty"` // overrides Accept-Language
+	Persona  string           `json:"persona,omitempty"`  // "patient" (default) or "clinician"
+
+	// ArticleID names the paper a follow-up question is about; with
+	// IncludeAnnotations the user's own highlights and notes are added as context
+	ArticleID          string `json:"article_id,omitempty"`
+	IncludeAnnotations bool   `json:"include_annotations,omitempty"`
+
+	// SessionID names the conversation. With a session store the server
+	// keeps the history and History is ignored; a new ID is returned when
+	// it is empty, and IDs the server did not issue are not found. It also
+	// identifies an anonymous client that accepted the disclaimer.
+	SessionID string `json:"session_id,omitempty"`
+
+	// HighConfidence samples the answer several times and returns the one
+	// the samples and the retrieved studies agree on, with a note on any
+	// disagreement. It costs three model calls and more latency, and is only
+	// honoured for the clinician persona.
+	HighConfidence bool `json:"high_confidence,omitempty"`
+
+	// Debug adds a trace of the retrieval and generation pipeline to the
+	// response. It is only honoured for requests that pass the admin
+	// restrictions.
+	Debug bool `json:"debug,omitempty"`
+
+	// Model overrides the server's model for this request. It must be one
+	// of the allowed models listed by /api/models and needs a signed-in user.
+	Model string `json:"model,omitempty"`
+}
+
+// ExplainRequest names an article by PMID or supplies the abstract directly
+type ExplainRequest struct {
+	PMID     string `json:"pmid,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Abstract string `json:"abstract,omitempty"`
+}
+
+// maxExplainAbstractLength bounds raw abstracts sent to /api/explain
+const maxExplainAbstractLength = 10000
+
+// ChatProcessor answers a user message given the prior conversation
+type ChatProcessor interface {
+	ProcessMessage(ctx context.Context, userMessage string, chatHistory []ai.ChatMessage) (*ai.ChatResponse, error)
+}
+
+// QueryPrefetcher is implemented by processors that can start embedding a
+// message before ProcessMessage is called
+type QueryPrefetcher interface {
+	PrefetchQuery(userMessage string, chatHistory []ai.ChatMessage)
+}
+
+// ModelCatalog reports the active provider and model and the models the
+// provider offers
+type ModelCatalog interface {
+	ProviderName() string
+	ModelName() string
+	GetAvailableModels() ([]string, error)
 }
 
 type ChatServer struct {
-	MedicalChat   *ai.LLMMedicalChat
+	MedicalChat   ChatProcessor
 	SafetyChecker *safety.MedicalSafetyChecker
-	LLMClient     *ai.LLMClient
+	LLMClient     ModelCatalog
+	Clock         clock.Clock
+	MessageIDs    ids.Generator
+	Explainer     ai.Explainer
+	Points        ai.PointGetter     // optional, looks up indexed abstracts by PMID
+	PubMed        *data.PubMedClient // optional, fetches abstracts that are not indexed
+	Annotations   *annotations.Store
+	Audit         *audit.Log     // nil disables auditing
+	Transcripts   *recordlog.Log // nil disables transcript storage
+	Consent       *consent.Store
+	Config        *config.Store           // live settings; nil uses the defaults
+	Warmup        *warmup.Gate            // nil reports ready immediately
+	AdminAccess   *middleware.AdminAccess // decides who may request debug traces; nil allows nobody
+	Sessions      session.Store           // server-side history; nil trusts the history in each request
+	Summarizer    ai.Summarizer           // condenses older session turns; nil summarizes them locally
+	Collections   []string                // the index searched, recorded with each answer
+
+	providerModels providerModels
+}
+
+// queryEmbeddingCacheSize bounds the cache of recent query embeddings
+const queryEmbeddingCacheSize = 1024
+
+func NewChatServer(medicalChat ChatProcessor, safetyChecker *safety.MedicalSafetyChecker, models ModelCatalog) *ChatServer {
+	return &ChatServer{
+		MedicalChat:   medicalChat,
+		SafetyChecker: safetyChecker,
+		LLMClient:     models,
+		Clock:         clock.System,
+		MessageIDs:    ids.Messages,
+	}
+}
+
+type ExplainResponse struct {
+	PMID        string          `json:"pmid,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Explanation *ai.Explanation `json:"explanation"`
+	Timestamp   time.Time       `json:"timestamp"`
 }
 
 type ChatResponse struct {
-	Response    string    `json:"response"`
-	Timestamp   time.Time `json:"timestamp"`
-	MessageID   string    `json:"message_id"`
-	Suggestions []string  `json:"suggestions,omitempty"`
+	Response    string      `json:"response"`
+	Timestamp   time.Time   `json:"timestamp"`
+	MessageID   string      `json:"message_id"`
+	Suggestions []string    `json:"suggestions,omitempty"`
+	Sources     []ai.Source `json:"sources,omitempty"`
+	Partial     bool        `json:"partial,omitempty"` // sources found but the summary timed out
+	Model       string      `json:"model,omitempty"`   // set when the request chose a model
+	SessionID   string      `json:"session_id,omitempty"`
+
+	Consensus  *ai.ConsensusReport `json:"consensus,omitempty"`  // set for high_confidence requests
+	Confidence *ai.Confidence      `json:"confidence,omitempty"` // how well the sources support the answer
+	Usage      *ai.TokenUsage      `json:"usage,omitempty"`      // set when the model was called
+	Debug      *ai.ChatTrace       `json:"debug,omitempty"`      // set for debug requests from admins
+	Record     *ai.AnswerRecord    `json:"record,omitempty"`     // how the answer was produced, kept for audits
+
+	// ConsentRequired is set when the question was refused because the
+	// current terms and disclaimer have not been accepted
+	ConsentRequired bool `json:"consent_required,omitempty"`
 }
 
 func main() {
+	configStore, err := config.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if _, err := config.LoadCollections(""); err != nil {
+		log.Fatalf("Invalid collection settings: %v", err)
+	}
+	if err := tracing.Setup("medatlas-chat"); err != nil {
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+	shutdownTimeout, err := shutdown.TimeoutFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid shutdown settings: %v", err)
+	}
+	// Cancelled on SIGINT or SIGTERM, which also stops background work
+	ctx, stop := shutdown.Context()
+	defer stop()
+
 	// Initialize clients
-	embedder := embeddingClient.NewClient("http://localhost:8000")
-	qdrantConn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
+	qdrantConn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()))
 	if err != nil {
 		log.Fatalf("Could not connect to Qdrant: %v", err)
 	}
 	defer qdrantConn.Close()
 
-	qdrantClient := qdrant.NewPointsClient(qdrantConn)
+	var qdrantClient points.Reader = contentstore.NewClient(tiering.NewClient(metrics.NewClient(qdrant.NewPointsClient(qdrantConn))), contentstore.FromEnv())
+	docs, err := docstore.FromEnv()
+	if err != nil {
+		log.Fatalf("Could not open document store: %v", err)
+	}
+	if docs != nil {
+		qdrantClient = docstore.NewClient(qdrantClient, docs)
+	}
+	// Articles on the configured excluded topics never reach a prompt
+	qdrantClient = exclusion.NewClient(qdrantClient, configStore)
+	// and sensitive passages in the rest are masked or dropped
+	qdrantClient = screening.NewClient(qdrantClient, configStore)
+	// Each request only reads the access labels its API key is entitled to
+	qdrantClient = access.NewClient(qdrantClient)
+	apiKeys, err := access.OpenKeys(access.KeysPathFromEnv())
+	if err != nil {
+		log.Fatalf("Could not load API keys: %v", err)
+	}
+	go apiKeys.Watch(ctx, config.ReloadInterval)
+
 	safetyChecker := safety.NewMedicalSafetyChecker()
 
-	// Initialize OpenRouter.ai client
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	model := os.Getenv("OPENROUTER_MODEL")
-	if model == "" {
-		model = "mistralai/mistral-7b-instruct" // Default model
+	// LLM_PROVIDER selects the provider, LLM_FALLBACK_PROVIDER the one
+	// answering when it fails
+	providers, err := ai.ProvidersFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid LLM provider settings: %v", err)
+	}
+	llmClient := ai.NewProviderClient(providers...)
+	if llmClient.AttemptTimeout, err = ai.AttemptTimeoutFromEnv(); err != nil {
+		log.Fatalf("Invalid LLM provider settings: %v", err)
 	}
+	model := llmClient.ModelName()
+	llmClient.Config = configStore
+	llmClient.Limiter = ai.NewLLMLimiter(0, 0, 0)
 
-	if apiKey == "" {
-		log.Fatal("OPENROUTER_API_KEY environment variable is required")
+	auditLog, err := audit.OpenFromEnv("chat")
+	if err != nil {
+		log.Fatalf("Could not open audit log: %v", err)
+	}
+	defer auditLog.Close()
+	for i, provider := range providers {
+		details := map[string]string{"provider": provider.Name(), "role": "primary"}
+		if i > 0 {
+			details["role"] = "fallback"
+		}
+		auditLog.Record(context.Background(), "model.configure", provider.DefaultModel(), details)
 	}
 
-	llmClient := ai.NewLLMClient(apiKey, model)
+	rateLimiter := middleware.NewRateLimiter(0, 0)
+	quota := middleware.AnonymousQuotaFromEnv()
+	configStore.OnChange(func(t *config.Tunables) {
+		logging.SetLevel(logging.ParseLevel(t.LogLevel))
+		rateLimiter.Update(t.RateLimit.RequestsPerMinute, t.RateLimit.Burst)
+		quota.Update(t.Quota.DailyQueries, t.Quota.DailyPerIP)
+		llmClient.Limiter.Update(t.LLM.MaxConcurrent, t.LLM.MaxQueue, time.Duration(t.LLM.QueueTimeoutSeconds)*time.Second)
+		safetyChecker.SetRules(t.Safety.BlockedTopics, t.Safety.HighRiskKeywords, t.Safety.MediumRiskKeywords)
+		safetyChecker.SetExcludedTopics(t.Safety.ExcludedTopics)
+	})
+	auditLog.TrackConfig(configStore)
+	go configStore.Watch(ctx, config.ReloadInterval)
 
 	// Test model availability
-	log.Printf("🔍 Testing OpenRouter.ai connection with model: %s", model)
+	log.Printf("🔍 Testing %s connection with model: %s", llmClient.ProviderName(), model)
 
-	chatServer := &ChatServer{
-		MedicalChat:   ai.NewLLMMedicalChat(embedder, qdrantClient, llmClient),
-		SafetyChecker: safetyChecker,
-		LLMClient:     llmClient,
+	queryEmbedder := ai.NewCachingEmbedder(embedder, queryEmbeddingCacheSize)
+	// Keyword matches are fused in when hybrid_search is configured
+	medicalChat := ai.NewLLMMedicalChat(queryEmbedder, search.NewHybrid(qdrantClient, tiering.HistoricalCollection()), llmClient)
+	medicalChat.Config = configStore
+	medicalChat.Reranker = embedder
+	if medicalChat.Synonyms, err = synonyms.NewStore(synonyms.PathFromEnv()); err != nil {
+		log.Fatalf("Could not load synonyms: %v", err)
+	}
+	go medicalChat.Synonyms.Watch(ctx, config.ReloadInterval)
+	if medicalChat.Suggestions, err = suggestions.Open(suggestions.PathFromEnv()); err != nil {
+		log.Fatalf("Could not load suggestion templates: %v", err)
+	}
+	go medicalChat.Suggestions.Watch(ctx, config.ReloadInterval)
+	// Feedback is given through the API server; its judgments are read here
+	if medicalChat.Calibration, err = calibration.Open(calibration.PathFromEnv()); err != nil {
+		log.Fatalf("Could not load relevance feedback: %v", err)
+	}
+	go medicalChat.Calibration.Watch(ctx, config.ReloadInterval)
+	collections := []string{tiering.RecentCollection(), tiering.HistoricalCollection()}
+	if exists, err := qdrant.NewCollectionsClient(qdrantConn).CollectionExists(context.Background(),
+		&qdrant.CollectionExistsRequest{CollectionName: data.ChunksCollection}); err == nil && exists.GetResult().GetExists() {
+		medicalChat.QdrantClient = search.NewChunked(medicalChat.QdrantClient, qdrantClient, tiering.HistoricalCollection())
+		collections = append(collections, data.ChunksCollection)
+		log.Printf("🧩 Retrieval includes abstract passages in %s", data.ChunksCollection)
+	}
+	if exists, err := qdrant.NewCollectionsClient(qdrantConn).CollectionExists(context.Background(),
+		&qdrant.CollectionExistsRequest{CollectionName: ai.ConsumerHealthCollection}); err == nil && exists.GetResult().GetExists() {
+		medicalChat.ConsumerHealth = true
+		log.Printf("🩺 Patient answers will prefer the %s collection", ai.ConsumerHealthCollection)
+	}
+	start := time.Now()
+	if medicalChat.IntentVectors, err = ai.PrecomputeIntentVectors(embedder); err != nil {
+		log.Printf("⚠️  Intent vectors unavailable, appending intent text to queries instead: %v", err)
+	} else {
+		log.Printf("🧭 Precomputed intent vectors in %v", time.Since(start))
+	}
+	chatServer := NewChatServer(medicalChat, safetyChecker, llmClient)
+	chatServer.Collections = collections
+	chatServer.Explainer = llmClient
+	chatServer.Summarizer = llmClient
+	chatServer.Points = qdrantClient
+	chatServer.PubMed = data.NewPubMedClient()
+	chatServer.Audit = auditLog
+	chatServer.Config = configStore
+	chatServer.AdminAccess, err = middleware.AdminAccessFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid admin access settings: %v", err)
+	}
+
+	transcriptPath := os.Getenv("TRANSCRIPTS_FILE")
+	if transcriptPath == "" {
+		transcriptPath = recordlog.DefaultTranscriptPath
+	}
+	recordKeys, err := encryption.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid encryption keys: %v", err)
+	}
+	if recordKeys == nil {
+		log.Printf("⚠️  ENCRYPTION_KEYS not set; %s is stored unencrypted", transcriptPath)
+	}
+	chatServer.Transcripts, err = recordlog.Open(transcriptPath, recordKeys)
+	if err != nil {
+		log.Fatalf("Could not open chat transcripts: %v", err)
+	}
+	defer chatServer.Transcripts.Close()
+	go retention.NewScheduler(retention.Policy{
+		Name:   "chat transcripts",
+		Target: chatServer.Transcripts,
+		MaxAge: func() time.Duration { return retention.Days(configStore.Current().Retention.ChatTranscriptDays) },
+	}).Run(ctx, retention.PurgeInterval)
+
+	annotationsPath := os.Getenv("ANNOTATIONS_FILE")
+	if annotationsPath == "" {
+		annotationsPath = annotations.DefaultPath
+	}
+	chatServer.Annotations, err = annotations.NewStore(annotationsPath)
+	if err != nil {
+		log.Fatalf("Could not load annotations: %v", err)
 	}
 
+	consentPath := os.Getenv("CONSENT_FILE")
+	if consentPath == "" {
+		consentPath = consent.DefaultPath
+	}
+	chatServer.Consent, err = consent.NewStore(consentPath)
+	if err != nil {
+		log.Fatalf("Could not load consent records: %v", err)
+	}
+
+	sessionTTL, err := session.TTLFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid session settings: %v", err)
+	}
+	chatServer.Sessions, err = session.FromEnv(recordKeys)
+	if err != nil {
+		log.Fatalf("Could not open session store: %v", err)
+	}
+	go retention.NewScheduler(retention.Policy{
+		Name:   "chat sessions",
+		Target: chatServer.Sessions,
+		MaxAge: func() time.Duration { return sessionTTL },
+	}).Run(ctx, retention.PurgeInterval)
+
 	r := mux.NewRouter()
-	r.HandleFunc("/api/chat", chatServer.chatHandler).Methods("POST")
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Handle("/api/chat", quota.Middleware(http.HandlerFunc(chatServer.chatHandler))).Methods("POST")
+	r.Handle("/api/chat/stream", quota.Middleware(http.HandlerFunc(chatServer.chatStreamHandler))).Methods("POST")
+	r.HandleFunc("/api/quota", quota.StatusHandler).Methods("GET")
+	r.HandleFunc("/api/explain", chatServer.explainHandler).Methods("POST")
+	r.HandleFunc("/api/articles/{id}/annotations", chatServer.createAnnotationHandler).Methods("POST")
+	r.HandleFunc("/api/articles/{id}/annotations", chatServer.listAnnotationsHandler).Methods("GET")
+	r.HandleFunc("/api/annotations/{annotationID}", chatServer.updateAnnotationHandler).Methods("PUT")
+	r.HandleFunc("/api/annotations/{annotationID}", chatServer.deleteAnnotationHandler).Methods("DELETE")
+	r.HandleFunc("/api/consent", chatServer.acceptConsentHandler).Methods("POST")
+	r.HandleFunc("/api/consent", chatServer.consentStatusHandler).Methods("GET")
+	r.HandleFunc("/api/sessions/{id}", chatServer.getSessionHandler).Methods("GET")
+	r.HandleFunc("/api/sessions/{id}", chatServer.deleteSessionHandler).Methods("DELETE")
+	r.HandleFunc("/api/answers/{messageID}/evidence", chatServer.evidenceHandler).Methods("GET")
+	r.HandleFunc("/api/me/data", chatServer.deleteMyDataHandler).Methods("DELETE")
 	r.HandleFunc("/api/health", chatServer.healthHandler).Methods("GET")
+	r.HandleFunc("/api/version", buildinfo.Handler("chat", embedder, model)).Methods("GET")
+	r.HandleFunc("/api/ready", chatServer.readyHandler).Methods("GET")
 	r.HandleFunc("/api/capabilities", chatServer.capabilitiesHandler).Methods("GET")
 	r.HandleFunc("/api/models", chatServer.modelsHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// Serve static files
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/static/")))
 
 	log.Printf("🤖 Medical Chat App starting on :8080")
-	log.Printf("🚀 AI Provider: OpenRouter.ai")
+	log.Printf("🚀 AI Provider: %s", llmClient.ProviderName())
+	for _, fallback := range providers[1:] {
+		log.Printf("🛟 Fallback provider: %s (%s)", fallback.Name(), fallback.DefaultModel())
+	}
 	log.Printf("📦 Model: %s", model)
-	log.Fatal(http.ListenAndServe(":8080", r))
+	chatServer.Warmup = &warmup.Gate{}
+	go chatServer.Warmup.Run(ctx, warmup.DefaultTimeout, warmupSteps(medicalChat, llmClient)...)
+
+	handler := middleware.Chain(r, middleware.Recover, middleware.AccessLog, identity.Middleware, apiKeys.Middleware, rateLimiter.Middleware, middleware.LimitBody(middleware.DefaultMaxBodyBytes))
+	httpServer := &http.Server{Addr: ":8080", Handler: handler}
+	if err := shutdown.Serve(ctx, httpServer, httpServer.ListenAndServe, shutdownTimeout); err != nil {
+		if ctx.Err() == nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+		log.Printf("⚠️  %v", err)
+	}
+
+	// Deferred closes of the logs and the Qdrant connection run on return
+	embedder.HTTPClient.CloseIdleConnections()
+	llmClient.CloseIdleConnections()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tracing.Flush(flushCtx)
 }
 
 func (cs *ChatServer) capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"ai_enabled":   true,
-		"model":        cs.LLMClient.Model,
-		"provider":     "OpenRouter.ai",
-		"capabilities": []string{"real_ai_responses", "medical_knowledge", "safety_checks"},
+		"model":        cs.LLMClient.ModelName(),
+		"provider":     cs.LLMClient.ProviderName(),
+		"capabilities": []string{"real_ai_responses", "medical_knowledge", "safety_checks", "high_confidence"},
 		"features":     []string{"multiple_models", "free_tier_available", "high_availability"},
 	})
 }
@@ -100,67 +439,222 @@ func (cs *ChatServer) capabilitiesHandler(w http.ResponseWriter, r *http.Request
 func (cs *ChatServer) modelsHandler(w http.ResponseWriter, r *http.Request) {
 	models, err := cs.LLMClient.GetAvailableModels()
 	if err != nil {
-		http.Error(w, `{"error": "Failed to fetch models"}`, http.StatusInternalServerError)
+		log.Printf("Model listing error: %v", err)
+		apperrors.Write(w, err, "Failed to fetch models")
 		return
 	}
 
-	// Filter for Mistral models
+	// Filter OpenRouter's catalog for Mistral models
 	var mistralModels []string
 	for _, model := range models {
-		if strings.Contains(model, "mistral") {
+		if cs.LLMClient.ProviderName() != ai.ProviderOpenRouter || strings.Contains(model, "mistral") {
 			mistralModels = append(mistralModels, model)
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"available_models": mistralModels,
-		"current_model":    cs.LLMClient.Model,
+		"available_models":  mistralModels,
+		"current_model":     cs.LLMClient.ModelName(),
+		"selectable_models": cs.modelOverrides().Allowed,
 	})
 }
 
 func (cs *ChatServer) chatHandler(w http.ResponseWriter, r *http.Request) {
+	cs.handleChat(w, r, nil)
+}
+
+// handleChat answers a chat request, as one JSON response or, with stream,
+// as server-sent events
+func (cs *ChatServer) handleChat(w http.ResponseWriter, r *http.Request, stream *eventStream) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Message is required")
+		return
+	}
+
+	persona, err := ai.ParsePersona(req.Persona)
+	if err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, err.Error())
+		return
+	}
+	if req.Debug && (cs.AdminAccess == nil || !cs.AdminAccess.Permits(r)) {
+		apperrors.Write(w, apperrors.ErrForbidden, "Debug traces are restricted to admins")
 		return
 	}
-	if req.Message == "" {
-		http.Error(w, `{"error": "Message is required"}`, http.StatusBadRequest)
+	model, err := cs.resolveModel(r, strings.TrimSpace(req.Model))
+	if err != nil {
+		apperrors.Write(w, err, err.Error())
 		return
 	}
+	loc := locale.FromRequest(r, req.Language)
+
+	history := req.History
+	var conversation *session.Session
+	if cs.Sessions != nil {
+		conversation, err = cs.loadSession(r.Context(), req.SessionID)
+		if err != nil {
+			apperrors.Write(w, err, "Session not found")
+			return
+		}
+		history = conversation.Unsummarized()
+	}
+
+	// Embed the query while the safety and consent checks run; a blocked
+	// message just leaves an unused embedding in the cache
+	if prefetcher, ok := cs.MedicalChat.(QueryPrefetcher); ok {
+		go prefetcher.PrefetchQuery(req.Message, history)
+	}
 
 	safetyResult := cs.SafetyChecker.CheckMessage(req.Message)
 	if !safetyResult.IsSafe {
 		response := ChatResponse{
-			Response:  cs.SafetyChecker.GenerateSafetyResponse(safetyResult.RiskLevel, safetyResult.Reasons),
-			Timestamp: time.Now(),
-			MessageID: generateMessageID(),
+			Response:  cs.SafetyChecker.GenerateSafetyResponse(safetyResult, loc),
+			Timestamp: cs.Clock.Now(),
+			MessageID: cs.MessageIDs.New(),
+		}
+		cs.logTranscript(r, req.Message, response, true)
+		cs.reply(w, stream, response)
+		return
+	}
+
+	if cs.needsConsent(r, req.SessionID, req.Message) {
+		response := ChatResponse{
+			Response:        loc.T(locale.ConsentRequired),
+			Timestamp:       cs.Clock.Now(),
+			MessageID:       cs.MessageIDs.New(),
+			ConsentRequired: true,
 		}
-		json.NewEncoder(w).Encode(response)
+		cs.reply(w, stream, response)
 		return
 	}
 
-	ctx := r.Context()
-	chatResponse, err := cs.MedicalChat.ProcessMessage(ctx, req.Message, req.History)
+	ctx := ai.WithPersona(locale.WithLocalizer(r.Context(), loc), persona)
+	if conversation != nil {
+		ctx = ai.WithConversationSummary(ctx, conversation.Summary)
+	}
+	if req.IncludeAnnotations {
+		ctx = cs.withReaderNotes(ctx, req.ArticleID)
+	}
+	if req.HighConfidence && persona == ai.PersonaClinician {
+		ctx = ai.WithHighConfidence(ctx)
+	}
+	if model != "" {
+		ctx = ai.WithModel(ctx, model)
+	}
+	// Every answer is traced for its record; admins asking for debug output
+	// get the trace itself too
+	trace := &ai.ChatTrace{}
+	ctx = ai.WithTrace(ctx, trace)
+	var debug *ai.ChatTrace
+	if req.Debug {
+		debug = trace
+	}
+	var guard *answerGuard
+	if stream != nil {
+		guard = newAnswerGuard(stream, cs.SafetyChecker)
+		ctx = ai.WithAnswerStream(ctx, guard.Write)
+		ctx = ai.WithProgress(ctx, func(progress ai.Progress) {
+			stream.Send(EventStage, progress)
+		})
+	}
+	chatResponse, err := cs.MedicalChat.ProcessMessage(ctx, req.Message, history)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to process message"}`, http.StatusInternalServerError)
+		log.Printf("Chat processing error: %v", err)
+		if stream != nil {
+			stream.Fail(err, "Failed to process message")
+		} else {
+			apperrors.Write(w, err, "Failed to process message")
+		}
 		return
 	}
+	// An answer that drifted onto an excluded topic is replaced by the refusal
+	if generated := cs.SafetyChecker.CheckGenerated(chatResponse.Response); !generated.IsSafe {
+		log.Printf("🚫 Withheld an answer about excluded topic %q", generated.Topic)
+		chatResponse = &ai.ChatResponse{Response: cs.SafetyChecker.GenerateSafetyResponse(generated, loc)}
+	} else if guard != nil {
+		guard.Flush()
+	}
 	response := ChatResponse{
 		Response:    chatResponse.Response,
 		Suggestions: chatResponse.Suggestions,
-		Timestamp:   time.Now(),
-		MessageID:   generateMessageID(),
+		Sources:     chatResponse.Sources,
+		Partial:     chatResponse.Partial,
+		Consensus:   chatResponse.Consensus,
+		Confidence:  chatResponse.Confidence,
+		Usage:       trace.Usage(),
+		Model:       model,
+		Debug:       debug,
+		Record:      ai.NewAnswerRecord(trace, cs.Collections),
+		Timestamp:   cs.Clock.Now(),
+		MessageID:   cs.MessageIDs.New(),
+	}
+	if conversation != nil {
+		response.SessionID = conversation.ID
+		cs.saveTurn(r.Context(), conversation, req.Message, response)
+	}
+	cs.logTranscript(r, req.Message, response, false)
+	cs.reply(w, stream, response)
+}
+
+// reply sends response as the body, or as the done event of stream
+func (cs *ChatServer) reply(w http.ResponseWriter, stream *eventStream, response ChatResponse) {
+	if stream != nil {
+		stream.Send(EventDone, response)
+		return
 	}
 	json.NewEncoder(w).Encode(response)
 }
 
-func generateMessageID() string {
-	return fmt.Sprintf("msg_%d", time.Now().UnixNano())
+func (cs *ChatServer) explainHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid JSON")
+		return
+	}
+	if req.PMID == "" && strings.TrimSpace(req.Abstract) == "" {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Either pmid or abstract is required")
+		return
+	}
+	if len(req.Abstract) > maxExplainAbstractLength {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Abstract is too long")
+		return
+	}
+
+	article := &ai.ArticleText{Title: req.Title, Abstract: req.Abstract}
+	if req.PMID != "" {
+		found, err := ai.LookupArticle(r.Context(), cs.Points, cs.PubMed, req.PMID)
+		if err != nil {
+			log.Printf("Article lookup error: %v", err)
+			apperrors.Write(w, err, "Failed to load article")
+			return
+		}
+		article = found
+	}
+
+	explanation, err := cs.Explainer.ExplainAbstract(r.Context(), article.Title, article.Abstract)
+	if err != nil {
+		log.Printf("Explain error: %v", err)
+		apperrors.Write(w, err, "Failed to explain article")
+		return
+	}
+
+	json.NewEncoder(w).Encode(ExplainResponse{
+		PMID:        article.ID,
+		Title:       article.Title,
+		Explanation: explanation,
+		Timestamp:   cs.Clock.Now(),
+	})
 }
 
 func (cs *ChatServer) healthHandler(w http.ResponseWriter, r *http.Request) {
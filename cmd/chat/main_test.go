@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/safety"
+	"MedAtlasAIServer/internal/session"
+)
+
+// fakeChat answers with response, or fails with err when it is set, and
+// records the last message. The history is formatted as LLMMedicalChat
+// formats it, so fuzzed histories reach that code too.
+type fakeChat struct {
+	response *ai.ChatResponse
+	err      error
+	message  string
+	history  []ai.ChatMessage
+}
+
+func (c *fakeChat) ProcessMessage(ctx context.Context, userMessage string, chatHistory []ai.ChatMessage) (*ai.ChatResponse, error) {
+	c.message, c.history = userMessage, chatHistory
+	(&ai.LLMMedicalChat{}).BuildConversationContext(chatHistory)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.response, nil
+}
+
+// fakeModels offers a single model
+type fakeModels struct{}
+
+func (fakeModels) ProviderName() string                  { return "fake" }
+func (fakeModels) ModelName() string                     { return "fake-model" }
+func (fakeModels) GetAvailableModels() ([]string, error) { return []string{"fake-model"}, nil }
+
+func newTestChatServer(chat *fakeChat) *ChatServer {
+	return NewChatServer(chat, safety.NewMedicalSafetyChecker(), fakeModels{})
+}
+
+func postChat(cs *ChatServer, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	cs.chatHandler(rec, httptest.NewRequest(http.MethodPost, "/api/chat", bytes.NewReader(body)))
+	return rec
+}
+
+func TestChatHandler(t *testing.T) {
+	chat := &fakeChat{response: &ai.ChatResponse{Response: "Statins may lower the risk.", Suggestions: []string{"What about side effects?"}}}
+	rec := postChat(newTestChatServer(chat), []byte(`{"message": "Do statins prevent dementia?", "history": [{"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello"}]}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Response != "Statins may lower the risk." || len(resp.Suggestions) != 1 || resp.MessageID == "" {
+		t.Errorf("response = %+v", resp)
+	}
+	if chat.message != "Do statins prevent dementia?" || len(chat.history) != 2 {
+		t.Errorf("processed message %q with history %v", chat.message, chat.history)
+	}
+}
+
+func TestChatHandlerValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"malformed JSON", `{"message": `, http.StatusBadRequest},
+		{"missing message", `{"history": []}`, http.StatusBadRequest},
+		{"blank message", `{"message": " \n "}`, http.StatusBadRequest},
+		{"unknown persona", `{"message": "Hi there", "persona": "pirate"}`, http.StatusBadRequest},
+		{"debug without admin access", `{"message": "Hi there", "debug": true}`, http.StatusForbidden},
+		{"model without signing in", `{"message": "Hi there", "model": "other-model"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := &fakeChat{response: &ai.ChatResponse{Response: "An answer."}}
+			rec := postChat(newTestChatServer(chat), []byte(tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if chat.message != "" {
+				t.Errorf("processed an invalid request")
+			}
+		})
+	}
+}
+
+func TestChatHandlerSessions(t *testing.T) {
+	cs := newTestChatServer(&fakeChat{response: &ai.ChatResponse{Response: "An answer."}})
+	cs.Sessions = session.NewMemoryStore(time.Hour)
+
+	if rec := postChat(cs, []byte(`{"message": "Do statins prevent dementia?", "session_id": "chosen-by-client"}`)); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want 404: %s", rec.Code, rec.Body)
+	}
+
+	rec := postChat(cs, []byte(`{"message": "Do statins prevent dementia?"}`))
+	var first ChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || rec.Code != http.StatusOK || first.SessionID == "" {
+		t.Fatalf("new session: status = %d: %s", rec.Code, rec.Body)
+	}
+	body, _ := json.Marshal(ChatRequest{Message: "What about side effects?", SessionID: first.SessionID})
+	if rec := postChat(cs, body); rec.Code != http.StatusOK {
+		t.Errorf("issued session: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestChatHandlerBackendErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"model unavailable", fmt.Errorf("%w: provider returned 502", apperrors.ErrLLMUnavailable), http.StatusServiceUnavailable},
+		{"overloaded", apperrors.ErrOverloaded, http.StatusServiceUnavailable},
+		{"search unavailable", fmt.Errorf("%w: qdrant unavailable", apperrors.ErrSearchUnavailable), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postChat(newTestChatServer(&fakeChat{err: tt.err}), []byte(`{"message": "Do statins prevent dementia?"}`))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var resp apperrors.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+				t.Errorf("error response = %s", rec.Body)
+			}
+		})
+	}
+}
+
+func FuzzChatRequest(f *testing.F) {
+	for _, seed := range []string{
+		`{"message": "What helps with migraines?"}`,
+		`{"message": "And in children?", "history": [{"role": "user", "content": "What helps with migraines?"}]}`,
+		`{"message": "Explain this", "persona": "clinician", "language": "es"}`,
+		`{"message": "Explain this", "model": "other-model"}`,
+		`{"message": "hi", "debug": true, "high_confidence": true}`,
+		`{"history": null}`,
+		`"message"`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		cs := newTestChatServer(&fakeChat{response: &ai.ChatResponse{Response: "An answer."}})
+		rec := postChat(cs, body)
+		if rec.Code >= 500 {
+			t.Fatalf("status %d for %q: %s", rec.Code, body, rec.Body)
+		}
+		if !json.Valid(rec.Body.Bytes()) {
+			t.Fatalf("invalid JSON for %q: %s", body, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/identity"
+)
+
+// modelListTTL is how long the provider's model list is trusted before it
+// is fetched again
+const modelListTTL = 10 * time.Minute
+
+// providerModels caches the models the provider currently offers
+type providerModels struct {
+	mu      sync.Mutex
+	models  []string
+	fetched time.Time
+}
+
+// available reports whether the provider offers model. When the list cannot
+// be fetched the allowlist alone decides, so a provider hiccup does not turn
+// every override into an error.
+func (p *providerModels) available(catalog ModelCatalog, model string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.models == nil || now.Sub(p.fetched) > modelListTTL {
+		models, err := catalog.GetAvailableModels()
+		if err != nil {
+			log.Printf("⚠️  Could not refresh the provider model list: %v", err)
+			return p.models == nil || slices.Contains(p.models, model)
+		}
+		p.models, p.fetched = models, now
+	}
+	return slices.Contains(p.models, model)
+}
+
+// resolveModel validates a per-request model override. Overrides need an
+// authenticated user and a model from the configured allowlist that the
+// provider offers; premium models may be restricted to premium users. It
+// returns "" when no override was requested.
+func (cs *ChatServer) resolveModel(r *http.Request, requested string) (string, error) {
+	if requested == "" || requested == cs.LLMClient.ModelName() {
+		return "", nil
+	}
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		return "", fmt.Errorf("%w: choosing a model requires signing in", apperrors.ErrUnauthorized)
+	}
+	overrides := cs.modelOverrides()
+	choice, ok := overrides.Find(requested)
+	if !ok {
+		return "", fmt.Errorf("%w: model %q is not available for selection", apperrors.ErrInvalidInput, requested)
+	}
+	if choice.Tier == config.TierPremium && len(overrides.PremiumUsers) > 0 && !slices.Contains(overrides.PremiumUsers, userID) {
+		return "", fmt.Errorf("%w: model %q is limited to premium users", apperrors.ErrForbidden, requested)
+	}
+	if !cs.providerModels.available(cs.LLMClient, requested, cs.Clock.Now()) {
+		return "", fmt.Errorf("%w: model %q is not currently offered by the provider", apperrors.ErrInvalidInput, requested)
+	}
+	return choice.ID, nil
+}
+
+// modelOverrides returns the live model allowlist
+func (cs *ChatServer) modelOverrides() config.ModelOverrides {
+	if cs.Config == nil {
+		return config.DefaultTunables().Models
+	}
+	return cs.Config.Current().Models
+}
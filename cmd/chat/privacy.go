@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/retention"
+)
+
+// logTranscript stores one exchange; failures never affect the response
+func (cs *ChatServer) logTranscript(r *http.Request, message string, response ChatResponse, blocked bool) {
+	entry := map[string]interface{}{
+		"message_id": response.MessageID,
+		"message":    message,
+		"response":   response.Response,
+		"blocked":    blocked,
+	}
+	if response.Record != nil {
+		entry["record"] = response.Record
+	}
+	if err := cs.Transcripts.Append(identity.UserFromContext(r.Context()), entry); err != nil {
+		log.Printf("⚠️  Transcript write failed: %v", err)
+	}
+}
+
+// deleteMyDataHandler erases everything this service stores about the
+// signed-in user: chat transcripts, sessions and annotations
+func (cs *ChatServer) deleteMyDataHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := identity.RequireUser(r.Context())
+	if err != nil {
+		apperrors.Write(w, err, "Sign in to delete your data")
+		return
+	}
+
+	erasers := map[string]retention.Eraser{"chat_transcripts": cs.Transcripts}
+	if cs.Annotations != nil {
+		erasers["annotations"] = cs.Annotations
+	}
+	if cs.Sessions != nil {
+		erasers["chat_sessions"] = cs.Sessions
+	}
+	deleted, err := retention.EraseUser(userID, erasers)
+
+	details := make(map[string]string, len(deleted))
+	for name, n := range deleted {
+		details[name] = fmt.Sprint(n)
+	}
+	cs.Audit.Record(r.Context(), "user_data.delete", userID, details)
+
+	if err != nil {
+		log.Printf("User data deletion incomplete for %s: %v", userID, err)
+		apperrors.Write(w, err, "Some data could not be deleted; please retry")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/session"
+
+	"github.com/gorilla/mux"
+)
+
+// loadSession returns the conversation named by sessionID, or a new one with
+// a server-issued ID when sessionID is empty. Unknown IDs are not found, so
+// clients cannot choose their own. A signed-in user's session is not found
+// for anyone else; an anonymous session is claimed by the first user to sign
+// in with it.
+func (cs *ChatServer) loadSession(ctx context.Context, sessionID string) (*session.Session, error) {
+	userID := identity.UserFromContext(ctx)
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return &session.Session{ID: ids.Sessions.New(), UserID: userID}, nil
+	}
+	existing, err := cs.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing.UserID != "" && existing.UserID != userID {
+		return nil, fmt.Errorf("%w: session %s", apperrors.ErrNotFound, sessionID)
+	}
+	if existing.UserID == "" {
+		existing.UserID = userID
+	}
+	return existing, nil
+}
+
+// saveTurn appends one question and answer to the session and rolls its
+// summary forward; failures are logged and never affect the response
+func (cs *ChatServer) saveTurn(ctx context.Context, s *session.Session, message string, response ChatResponse) {
+	s.Append(
+		ai.ChatMessage{Role: "user", Content: message, Timestamp: response.Timestamp},
+		ai.ChatMessage{Role: "assistant", Content: response.Response, Timestamp: response.Timestamp},
+	)
+	s.Summarize(ctx, cs.Summarizer)
+	if err := cs.Sessions.Save(ctx, s); err != nil {
+		log.Printf("⚠️  Session write failed: %v", err)
+	}
+}
+
+// getSessionHandler returns the stored history of a conversation
+func (cs *ChatServer) getSessionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s, err := cs.ownedSession(r)
+	if err != nil {
+		apperrors.Write(w, err, "Session not found")
+		return
+	}
+	json.NewEncoder(w).Encode(s)
+}
+
+// deleteSessionHandler forgets a conversation
+func (cs *ChatServer) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s, err := cs.ownedSession(r)
+	if err != nil {
+		apperrors.Write(w, err, "Session not found")
+		return
+	}
+	if err := cs.Sessions.Delete(r.Context(), s.ID); err != nil {
+		log.Printf("Session deletion error: %v", err)
+		apperrors.Write(w, err, "Failed to delete session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedSession loads the session in the URL if the caller may see it
+func (cs *ChatServer) ownedSession(r *http.Request) (*session.Session, error) {
+	if cs.Sessions == nil {
+		return nil, apperrors.ErrNotFound
+	}
+	s, err := cs.Sessions.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return nil, err
+	}
+	if s.UserID != "" && s.UserID != identity.UserFromContext(r.Context()) {
+		return nil, apperrors.ErrNotFound
+	}
+	return s, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/safety"
+)
+
+// Events of /api/chat/stream
+const (
+	// EventDelta carries the next piece of the answer as the model writes
+	// it: {"text": "..."}. The pieces are provisional, and stop at an
+	// excluded topic.
+	EventDelta = "delta"
+	// EventStage reports the stage the answer has reached, as an
+	// ai.Progress: {"stage": "found", "message": "Found 8 relevant
+	// sources", "count": 8}
+	EventStage = "stage"
+	// EventDone carries the final ChatResponse, whose response replaces the
+	// streamed text: citations are aligned and safety checks run on the
+	// whole answer
+	EventDone = "done"
+	// EventError carries an ErrorResponse when the request fails after the
+	// stream started
+	EventError = "error"
+)
+
+// eventStream writes server-sent events. The response headers are sent
+// with the first event, so a request can still fail with a plain JSON
+// error until then. It is safe for concurrent use.
+type eventStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	started bool
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{w: w}
+}
+
+// Send writes one event with data encoded as JSON and flushes it
+func (s *eventStream) Send(event string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Event encoding error: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, encoded)
+	http.NewResponseController(s.w).Flush()
+}
+
+// Fail reports err as an error event once the stream has started, and as
+// a JSON error response before
+func (s *eventStream) Fail(err error, message string) {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		apperrors.Write(s.w, err, message)
+		return
+	}
+	s.Send(EventError, apperrors.ErrorResponse{Error: message})
+}
+
+// chatStreamHandler answers POST /api/chat/stream like /api/chat, but as
+// server-sent events: stage events report progress, the answer streams in
+// delta events as the model writes it, and a done event carries the final
+// response
+func (cs *ChatServer) chatStreamHandler(w http.ResponseWriter, r *http.Request) {
+	cs.handleChat(w, r, newEventStream(w))
+}
+
+// answerGuard forwards streamed answer text as delta events once it is
+// known not to name an excluded topic, so an answer the safety check will
+// withhold is not streamed first. Without excluded topics every delta is
+// sent as it arrives; with them, the last LongestExcludedTerm bytes are
+// held back until later text or Flush, as a term may still be completing,
+// and nothing more is sent once a term appears.
+type answerGuard struct {
+	stream   *eventStream
+	checker  *safety.MedicalSafetyChecker
+	holdback int
+	text     strings.Builder
+	sent     int
+	blocked  bool
+}
+
+func newAnswerGuard(stream *eventStream, checker *safety.MedicalSafetyChecker) *answerGuard {
+	holdback := checker.LongestExcludedTerm()
+	if holdback > 0 {
+		holdback++ // the boundary after the term
+	}
+	return &answerGuard{stream: stream, checker: checker, holdback: holdback}
+}
+
+// Write takes the next piece of the answer
+func (g *answerGuard) Write(delta string) {
+	if g.holdback == 0 {
+		g.stream.Send(EventDelta, map[string]string{"text": delta})
+		return
+	}
+	if g.blocked {
+		return
+	}
+	g.text.WriteString(delta)
+	text := g.text.String()
+	if !g.checker.CheckGenerated(text).IsSafe {
+		g.blocked = true
+		return
+	}
+	end := len(text) - g.holdback
+	for end > g.sent && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	g.send(text, end)
+}
+
+// Flush sends the text held back, once the whole answer passed the check
+func (g *answerGuard) Flush() {
+	if g.holdback == 0 || g.blocked {
+		return
+	}
+	text := g.text.String()
+	g.send(text, len(text))
+}
+
+func (g *answerGuard) send(text string, end int) {
+	if end <= g.sent {
+		return
+	}
+	g.stream.Send(EventDelta, map[string]string{"text": text[g.sent:end]})
+	g.sent = end
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/warmup"
+	"MedAtlasAIServer/pkg/data"
+)
+
+// warmupQuestion exercises retrieval end to end (embedding, Qdrant search,
+// term normalization) before the first user question
+const warmupQuestion = "hypertension treatment"
+
+func warmupSteps(medicalChat *ai.LLMMedicalChat, models ModelCatalog) []warmup.Step {
+	return []warmup.Step{
+		{Name: "term dictionaries", Run: func(ctx context.Context) error {
+			data.WarmUp()
+			return nil
+		}},
+		{Name: "retrieval", Run: func(ctx context.Context) error {
+			_, err := medicalChat.SearchMedicalKnowledge(ctx, warmupQuestion, "treatment_info")
+			return err
+		}},
+		// Listing models opens the TLS connection to the LLM provider
+		// without spending tokens on a completion
+		{Name: "LLM connection", Run: func(ctx context.Context) error {
+			_, err := models.GetAvailableModels()
+			return err
+		}},
+	}
+}
+
+func (cs *ChatServer) readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if cs.Warmup != nil && !cs.Warmup.Done() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "warming up"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
@@ -0,0 +1,218 @@
+// Command clinicaltrials collects studies from the ClinicalTrials.gov API v2
+// and writes them as articles (source "clinicaltrials") into data/raw, where
+// the indexer picks them up alongside PubMed files. With -registry the
+// studies are also upserted into the clinical_trials collection, so the
+// trials cited by abstracts resolve to their registry records.
+//
+//	go run ./cmd/clinicaltrials -terms "breast cancer,type 2 diabetes" -per-term 200
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/trials"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// defaultTerms match the PubMed collector's research topics
+const defaultTerms = "cancer immunotherapy,cardiovascular disease,neurology,artificial intelligence,precision medicine," +
+	"genomic medicine,infectious diseases,mental health,surgery"
+
+func main() {
+	terms := flag.String("terms", defaultTerms, "comma-separated search terms")
+	perTerm := flag.Int("per-term", 100, "studies collected per search term")
+	outDir := flag.String("out-dir", "data/raw", "directory the JSONL file is written to")
+	compress := flag.String("compress", "gz", "compress the output file: gz, zst or none")
+	registry := flag.Bool("registry", true, "also embed the studies into the clinical_trials collection")
+	flag.Parse()
+
+	extension := ".jsonl"
+	switch *compress {
+	case "none", "":
+	case "gz", "zst":
+		extension += "." + *compress
+	default:
+		log.Fatalf("❌ -compress must be gz, zst or none")
+	}
+	if *perTerm < 1 {
+		log.Fatalf("❌ -per-term must be at least 1")
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("❌ Failed to create %s: %v", *outDir, err)
+	}
+
+	studies := collect(data.NewClinicalTrialsClient(), splitList(*terms), *perTerm)
+	if len(studies) == 0 {
+		log.Fatalf("❌ No studies collected")
+	}
+	path := filepath.Join(*outDir, fmt.Sprintf("clinicaltrials_%s%s", time.Now().Format("20060102_150405"), extension))
+	written, err := writeStudies(path, studies)
+	if err != nil {
+		log.Fatalf("❌ Could not write %s: %v", path, err)
+	}
+	log.Printf("💾 Wrote %d studies to %s", written, path)
+
+	if *registry {
+		if err := indexRegistry(context.Background(), studies); err != nil {
+			log.Fatalf("❌ Registry indexing failed: %v", err)
+		}
+	}
+}
+
+// collect searches every term and returns the distinct studies found
+func collect(client *data.ClinicalTrialsClient, terms []string, perTerm int) []models.MedicalArticle {
+	seen := make(map[string]bool)
+	var studies []models.MedicalArticle
+	for i, term := range terms {
+		if i > 0 {
+			time.Sleep(client.Delay)
+		}
+		found, err := client.SearchStudies(term, perTerm)
+		if err != nil {
+			log.Printf("❌ %s: %v", term, err)
+		}
+		added := 0
+		for _, study := range found {
+			if study.ID != "" && !seen[study.ID] {
+				seen[study.ID] = true
+				studies = append(studies, study)
+				added++
+			}
+		}
+		log.Printf("✅ %s: %d studies, %d new", term, len(found), added)
+	}
+	return studies
+}
+
+// writeStudies writes the valid studies under a hidden name and renames the
+// file when complete, so a watching indexer never reads a partial file
+func writeStudies(path string, studies []models.MedicalArticle) (int, error) {
+	partial := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	out, err := data.OpenOutput(partial)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for _, study := range studies {
+		if !data.ValidateArticle(study) {
+			continue
+		}
+		line, err := json.Marshal(study)
+		if err != nil {
+			out.Close()
+			os.Remove(partial)
+			return 0, err
+		}
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			out.Close()
+			os.Remove(partial)
+			return 0, err
+		}
+		written++
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return 0, err
+	}
+	return written, os.Rename(partial, path)
+}
+
+// indexRegistry embeds each study's title and conditions and upserts it into
+// trials.Collection, creating the collection when needed
+func indexRegistry(ctx context.Context, studies []models.MedicalArticle) error {
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
+	qdrantConn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("could not connect to Qdrant: %w", err)
+	}
+	defer qdrantConn.Close()
+	collections := qdrant.NewCollectionsClient(qdrantConn)
+	points := qdrant.NewPointsClient(qdrantConn)
+
+	var batch []*qdrant.PointStruct
+	for _, study := range studies {
+		vector, err := embedder.GetEmbedding(study.Title + ". " + strings.Join(study.Conditions, ". "))
+		if err != nil {
+			log.Printf("❌ Embedding %s failed: %v", study.ID, err)
+			continue
+		}
+		if len(batch) == 0 {
+			if err := ensureCollection(ctx, collections, len(vector)); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, &qdrant.PointStruct{
+			Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(study.ID)}},
+			Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
+			Payload: payload.Encode(trials.StudyPayload{
+				NCTID:      study.ID,
+				Title:      study.Title,
+				Status:     study.TrialStatus,
+				Phase:      study.Phase,
+				Enrollment: study.Enrollment,
+				Conditions: study.Conditions,
+			}),
+		})
+	}
+	if len(batch) == 0 {
+		return fmt.Errorf("no studies could be embedded")
+	}
+
+	wait := true
+	if _, err := points.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: trials.Collection,
+		Points:         batch,
+		Wait:           &wait,
+	}); err != nil {
+		return err
+	}
+	log.Printf("🎉 Indexed %d studies into %s", len(batch), trials.Collection)
+	return nil
+}
+
+func ensureCollection(ctx context.Context, client qdrant.CollectionsClient, vectorSize int) error {
+	exists, err := client.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: trials.Collection})
+	if err != nil {
+		return err
+	}
+	if exists.GetResult().GetExists() {
+		return nil
+	}
+	log.Printf("🆕 Creating collection %s with vector size %d", trials.Collection, vectorSize)
+	_, err = client.Create(ctx, &qdrant.CreateCollection{
+		CollectionName: trials.Collection,
+		VectorsConfig: &qdrant.VectorsConfig{Config: &qdrant.VectorsConfig_Params{
+			Params: &qdrant.VectorParams{
+				Size:     uint64(vectorSize),
+				Distance: qdrant.Distance_Cosine,
+			},
+		}},
+	})
+	return err
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,75 @@
+// Command collect runs the data sources listed in the source config
+// (SOURCES_FILE, default data/sources.json) and writes each source's
+// articles into data/raw as <source>_<time>.jsonl, where the indexer picks
+// them up. -only limits the run to some of the configured sources.
+//
+//	go run ./cmd/collect -only pubmed,europepmc -compress gz
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"MedAtlasAIServer/pkg/sources"
+)
+
+func main() {
+	configPath := flag.String("config", sources.PathFromEnv(), "JSON file listing the sources to run and their queries")
+	only := flag.String("only", "", "comma-separated names of the configured sources to run, all when empty")
+	outDir := flag.String("out-dir", "data/raw", "directory the JSONL files are written to")
+	compress := flag.String("compress", "none", "compress the output files: gz, zst or none")
+	list := flag.Bool("list", false, "list the registered sources and exit")
+	flag.Parse()
+
+	if *list {
+		for _, name := range sources.Names() {
+			log.Printf("📚 %s", name)
+		}
+		return
+	}
+	extension := ".jsonl"
+	switch *compress {
+	case "none", "":
+	case "gz", "zst":
+		extension += "." + *compress
+	default:
+		log.Fatalf("❌ -compress must be gz, zst or none")
+	}
+	file, err := sources.Load(*configPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	total := 0
+	for _, cfg := range file.Sources {
+		if len(selected) > 0 && !selected[cfg.Name] {
+			continue
+		}
+		log.Printf("🔍 Collecting from %s (%d queries)", cfg.Name, len(cfg.Queries))
+		path, count, err := sources.Run(ctx, cfg, *outDir, extension, time.Now())
+		if err != nil {
+			log.Fatalf("❌ %s: %v", cfg.Name, err)
+		}
+		if path == "" {
+			log.Printf("⚠️  %s: no articles collected", cfg.Name)
+			continue
+		}
+		log.Printf("💾 Wrote %d %s articles to %s", count, cfg.Name, path)
+		total += count
+	}
+	log.Printf("🎉 Collection complete! Total articles written: %d", total)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// cache remembers embeddings for every client of the proxy. Texts being
+// embedded are shared: a request for a text already on its way to a
+// replica waits for that call instead of sending its own, whether it came
+// in alone or in a batch. Eviction is first-in, first-out, like
+// ai.CachingEmbedder.
+type cache struct {
+	size int
+
+	mu       sync.Mutex
+	vectors  map[string][]float32
+	order    []string
+	inflight map[string]*embeddingCall
+	hits     int64
+	misses   int64
+}
+
+// embeddingCall is a text being embedded
+type embeddingCall struct {
+	done   chan struct{}
+	vector []float32
+	err    error
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:     size,
+		vectors:  make(map[string][]float32),
+		inflight: make(map[string]*embeddingCall),
+	}
+}
+
+// embed returns the vectors of texts, in order, calling fetch once for the
+// texts neither cached nor in flight
+func (c *cache) embed(ctx context.Context, texts []string, fetch func(context.Context, []string) ([][]float32, error)) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	waiting := make(map[int]*embeddingCall)
+	var missing []string
+	var calls []*embeddingCall
+
+	c.mu.Lock()
+	for i, text := range texts {
+		if vector, ok := c.vectors[text]; ok {
+			vectors[i] = vector
+			c.hits++
+			continue
+		}
+		call, ok := c.inflight[text]
+		if ok {
+			c.hits++
+		} else {
+			call = &embeddingCall{done: make(chan struct{})}
+			c.inflight[text] = call
+			missing = append(missing, text)
+			calls = append(calls, call)
+			c.misses++
+		}
+		waiting[i] = call
+	}
+	c.mu.Unlock()
+
+	if len(missing) > 0 {
+		// Detached from the request: other requests may be waiting for
+		// these texts after this one gives up
+		fetched, err := fetch(context.WithoutCancel(ctx), missing)
+		c.mu.Lock()
+		for i, call := range calls {
+			if err != nil {
+				call.err = err
+			} else {
+				call.vector = fetched[i]
+				c.store(missing[i], call.vector)
+			}
+			delete(c.inflight, missing[i])
+			close(call.done)
+		}
+		c.mu.Unlock()
+	}
+
+	for i, call := range waiting {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		vectors[i] = call.vector
+	}
+	return vectors, nil
+}
+
+// store adds an embedding, evicting the oldest when the cache is full.
+// c.mu must be held.
+func (c *cache) store(text string, vector []float32) {
+	if c.size <= 0 {
+		return
+	}
+	if _, ok := c.vectors[text]; ok {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.vectors, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.vectors[text] = vector
+	c.order = append(c.order, text)
+}
+
+// stats returns the number of cached embeddings, hits and misses
+func (c *cache) stats() (entries int, hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.vectors), c.hits, c.misses
+}
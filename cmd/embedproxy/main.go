@@ -0,0 +1,182 @@
+// Command embedproxy fronts several embedding service replicas with the
+// embedding service's own API, so the indexer and servers scale embedding
+// throughput by pointing EMBEDDING_SERVICE_HOST at it instead of at one
+// replica. It sends each call to the least loaded healthy replica, fails
+// over when a replica cannot be reached, checks every replica's health in
+// the background, and keeps one embedding cache for all of its clients in
+// which identical texts in flight share a single call. EMBEDDING_AUTH_TOKEN
+// and EMBEDDING_HMAC_SECRET, when set, are required of its clients and sent
+// to the replicas:
+//
+//	go run ./cmd/embedproxy -replicas http://embed-1:8000,http://embed-2:8000 -addr :8001
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/middleware"
+	"MedAtlasAIServer/internal/shutdown"
+	"MedAtlasAIServer/internal/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodyBytes bounds request bodies, which carry whole indexing batches
+const maxBodyBytes = 16 << 20 // 16 MiB
+
+// proxy serves the embedding API from a pool of replicas
+type proxy struct {
+	pool  *pool
+	cache *cache
+}
+
+func main() {
+	addr := flag.String("addr", ":8001", "address to listen on")
+	replicas := flag.String("replicas", os.Getenv("EMBEDDING_REPLICAS"), "comma-separated embedding service URLs (default $EMBEDDING_REPLICAS)")
+	cacheSize := flag.Int("cache-size", 100000, "embeddings kept in the shared cache (0 disables caching)")
+	healthInterval := flag.Duration("health-interval", 10*time.Second, "how often replicas are health checked")
+	timeout := flag.Duration("timeout", 60*time.Second, "timeout of one call to a replica")
+	shutdownTimeout := flag.Duration("shutdown-timeout", shutdown.DefaultTimeout, "how long requests in flight are given to finish on SIGTERM")
+	flag.Parse()
+
+	var urls []string
+	for _, replica := range strings.Split(*replicas, ",") {
+		if replica = strings.TrimRight(strings.TrimSpace(replica), "/"); replica != "" {
+			urls = append(urls, replica)
+		}
+	}
+	if len(urls) == 0 {
+		log.Fatal("No embedding replicas: set -replicas or EMBEDDING_REPLICAS")
+	}
+	if *timeout <= 0 || *healthInterval <= 0 || *shutdownTimeout <= 0 {
+		log.Fatal("-timeout, -health-interval and -shutdown-timeout must be positive")
+	}
+
+	if err := tracing.Setup("medatlas-embedproxy"); err != nil {
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+
+	p := &proxy{pool: newPool(urls, *timeout), cache: newCache(*cacheSize)}
+	ctx, stop := shutdown.Context()
+	defer stop()
+	go p.pool.checkHealth(ctx, *healthInterval)
+
+	r := mux.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/embed", p.embedHandler).Methods("POST")
+	r.HandleFunc("/embed/batch", p.embedBatchHandler).Methods("POST")
+	r.HandleFunc("/rerank", p.rerankHandler).Methods("POST")
+	r.HandleFunc("/model-info", p.modelInfoHandler).Methods("GET")
+	r.HandleFunc("/health", p.healthHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	log.Printf("🔀 Embedding proxy for %d replicas listening on %s", len(urls), *addr)
+	// Clients are held to the credentials the proxy itself sends replicas
+	handler := middleware.Chain(r, middleware.Recover, middleware.LimitBody(maxBodyBytes), embeddingClient.AuthFromEnv().Middleware)
+	server := &http.Server{Addr: *addr, Handler: handler}
+	if err := shutdown.Serve(ctx, server, server.ListenAndServe, *shutdownTimeout); err != nil {
+		if ctx.Err() == nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+		log.Printf("⚠️  %v", err)
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tracing.Flush(flushCtx)
+}
+
+func (p *proxy) embedHandler(w http.ResponseWriter, r *http.Request) {
+	var req embeddingClient.EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid request body")
+		return
+	}
+	vectors, err := p.cache.embed(r.Context(), []string{req.Text}, p.pool.GetEmbeddings)
+	if err != nil {
+		log.Printf("Embedding error: %v", err)
+		apperrors.Write(w, err, "Embedding failed")
+		return
+	}
+	model, _ := p.pool.modelInfo()
+	writeJSON(w, embeddingClient.EmbedResponse{Vector: vectors[0], Model: model, Dims: len(vectors[0])})
+}
+
+func (p *proxy) embedBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req embeddingClient.EmbedBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid request body")
+		return
+	}
+	vectors, err := p.cache.embed(r.Context(), req.Texts, p.pool.GetEmbeddings)
+	if err != nil {
+		log.Printf("Batch embedding error: %v", err)
+		apperrors.Write(w, err, "Embedding failed")
+		return
+	}
+	model, dims := p.pool.modelInfo()
+	if len(vectors) > 0 {
+		dims = len(vectors[0])
+	}
+	writeJSON(w, embeddingClient.EmbedBatchResponse{Vectors: vectors, Model: model, Dims: dims})
+}
+
+func (p *proxy) rerankHandler(w http.ResponseWriter, r *http.Request) {
+	var req embeddingClient.RerankRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Write(w, apperrors.ErrInvalidInput, "Invalid request body")
+		return
+	}
+	scores, err := p.pool.Rerank(r.Context(), req.Query, req.Passages)
+	if err != nil {
+		log.Printf("Rerank error: %v", err)
+		apperrors.Write(w, err, "Rerank failed")
+		return
+	}
+	writeJSON(w, embeddingClient.RerankResponse{Scores: scores})
+}
+
+func (p *proxy) modelInfoHandler(w http.ResponseWriter, r *http.Request) {
+	model, dims := p.pool.modelInfo()
+	if model == "" {
+		apperrors.Write(w, apperrors.ErrEmbeddingUnavailable, "No replica has reported its model yet")
+		return
+	}
+	writeJSON(w, embeddingClient.ModelInfoResponse{ModelName: model, EmbeddingDimension: dims, Status: "loaded"})
+}
+
+// healthHandler reports the replicas in rotation and the cache, and fails
+// when no replica is healthy
+func (p *proxy) healthHandler(w http.ResponseWriter, r *http.Request) {
+	healthy := p.pool.healthyCount()
+	entries, hits, misses := p.cache.stats()
+	status := "healthy"
+	if healthy == 0 {
+		status = "unhealthy"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":           status,
+		"replicas":         len(p.pool.replicas),
+		"healthy_replicas": healthy,
+		"cache_entries":    entries,
+		"cache_hits":       hits,
+		"cache_misses":     misses,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
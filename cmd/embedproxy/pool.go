@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/embeddingClient"
+)
+
+// replica is one embedding service instance
+type replica struct {
+	url      string
+	client   *embeddingClient.Client
+	healthy  atomic.Bool
+	inflight atomic.Int64
+}
+
+// pool spreads calls over the healthy replicas, sending each to the one
+// with the fewest calls in flight. A call that fails because its replica
+// is unreachable marks the replica down and is retried on another one.
+type pool struct {
+	replicas []*replica
+	next     atomic.Uint64 // breaks ties between equally loaded replicas
+
+	mu    sync.RWMutex
+	model string
+	dims  int
+}
+
+func newPool(urls []string, timeout time.Duration) *pool {
+	p := &pool{}
+	for _, base := range urls {
+		client := embeddingClient.NewClient(base)
+		client.HTTPClient.Timeout = timeout
+		r := &replica{url: base, client: client}
+		r.healthy.Store(true) // until the first check says otherwise
+		p.replicas = append(p.replicas, r)
+	}
+	return p
+}
+
+// pick returns the least loaded healthy replica not in tried, or any
+// untried replica when none is healthy
+func (p *pool) pick(tried map[*replica]bool) *replica {
+	var best *replica
+	start := int(p.next.Add(1))
+	for _, healthyOnly := range []bool{true, false} {
+		for i := range p.replicas {
+			r := p.replicas[(start+i)%len(p.replicas)]
+			if tried[r] || (healthyOnly && !r.healthy.Load()) {
+				continue
+			}
+			if best == nil || r.inflight.Load() < best.inflight.Load() {
+				best = r
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+	return nil
+}
+
+// do runs call on replicas until one answers or every replica was tried.
+// Calls failing with a client error are not retried, since every replica
+// would reject them; replicas that cannot be reached are marked down until
+// their next health check.
+func (p *pool) do(call func(*replica) error) error {
+	tried := make(map[*replica]bool, len(p.replicas))
+	var err error
+	for r := p.pick(tried); r != nil; r = p.pick(tried) {
+		tried[r] = true
+		r.inflight.Add(1)
+		err = call(r)
+		r.inflight.Add(-1)
+		if err == nil || !errors.Is(err, apperrors.ErrEmbeddingUnavailable) {
+			return err
+		}
+		var unreachable *url.Error
+		if errors.As(err, &unreachable) && r.healthy.Swap(false) {
+			log.Printf("⚠️  Embedding replica %s is down: %v", r.url, err)
+		}
+	}
+	return err
+}
+
+func (p *pool) GetEmbedding(text string) ([]float32, error) {
+	var vector []float32
+	err := p.do(func(r *replica) (err error) {
+		vector, err = r.client.GetEmbedding(text)
+		return err
+	})
+	return vector, err
+}
+
+// GetEmbeddings embeds texts with one batch request, or one request per
+// text on replicas without the batch endpoint
+func (p *pool) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := p.do(func(r *replica) (err error) {
+		vectors, err = r.client.GetEmbeddings(ctx, texts)
+		if !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+		vectors = make([][]float32, len(texts))
+		for i, text := range texts {
+			if vectors[i], err = r.client.GetEmbedding(text); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return vectors, err
+}
+
+func (p *pool) Rerank(ctx context.Context, query string, passages []string) ([]float32, error) {
+	var scores []float32
+	err := p.do(func(r *replica) (err error) {
+		scores, err = r.client.Rerank(ctx, query, passages)
+		return err
+	})
+	return scores, err
+}
+
+// modelInfo is the embedding model the replicas last reported
+func (p *pool) modelInfo() (string, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.model, p.dims
+}
+
+// healthyCount is how many replicas passed their last check
+func (p *pool) healthyCount() int {
+	n := 0
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// checkHealth asks every replica for its model info every interval,
+// marking replicas up or down, until ctx is done. All replicas must serve
+// the same model; one that reports another is kept out of rotation.
+func (p *pool) checkHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range p.replicas {
+			p.check(ctx, r)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *pool) check(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.client.HTTPClient.Timeout)
+	defer cancel()
+	model, dims, err := r.client.ModelInfo(ctx)
+	if err == nil {
+		err = p.agree(model, dims)
+	}
+	if err != nil {
+		if r.healthy.Swap(false) {
+			log.Printf("⚠️  Embedding replica %s failed its health check: %v", r.url, err)
+		}
+		return
+	}
+	if !r.healthy.Swap(true) {
+		log.Printf("✅ Embedding replica %s is back", r.url)
+	}
+}
+
+// agree records the model of the first replica to answer and rejects
+// replicas serving a different one
+func (p *pool) agree(model string, dims int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.model == "" {
+		p.model, p.dims = model, dims
+		return nil
+	}
+	if model != p.model || dims != p.dims {
+		return fmt.Errorf("serves %s (%d dims), the pool serves %s (%d dims)", model, dims, p.model, p.dims)
+	}
+	return nil
+}
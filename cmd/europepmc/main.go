@@ -0,0 +1,119 @@
+// Command europepmc collects articles from Europe PMC and writes them as
+// JSONL into data/raw, where the indexer picks them up. With -full-text only
+// open-access articles are collected, together with the sections of their
+// full text; the indexer embeds methods, results and conclusions
+// separately into the article_sections collection.
+//
+//	go run ./cmd/europepmc -terms "sepsis,heart failure" -per-term 100 -full-text
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/pkg/data"
+)
+
+// defaultTerms match the PubMed collector's research topics
+const defaultTerms = "cancer immunotherapy,cardiovascular disease treatment,neurology research,medical artificial intelligence," +
+	"precision medicine,genomic medicine,infectious diseases,mental health treatment,surgery innovations"
+
+func main() {
+	terms := flag.String("terms", defaultTerms, "comma-separated Europe PMC queries")
+	perTerm := flag.Int("per-term", 50, "articles collected per query")
+	fullText := flag.Bool("full-text", true, "collect only open-access articles and fetch their full-text sections")
+	outDir := flag.String("out-dir", "data/raw", "directory the JSONL file is written to")
+	compress := flag.String("compress", "gz", "compress the output file: gz, zst or none")
+	flag.Parse()
+
+	extension := ".jsonl"
+	switch *compress {
+	case "none", "":
+	case "gz", "zst":
+		extension += "." + *compress
+	default:
+		log.Fatalf("❌ -compress must be gz, zst or none")
+	}
+	if *perTerm < 1 {
+		log.Fatalf("❌ -per-term must be at least 1")
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("❌ Failed to create %s: %v", *outDir, err)
+	}
+
+	client := data.NewEuropePMCClient()
+	seen := make(map[string]bool)
+	var articles []models.MedicalArticle
+	withSections := 0
+	for i, term := range splitList(*terms) {
+		if i > 0 {
+			time.Sleep(client.Delay)
+		}
+		found, err := client.Search(term, *perTerm, *fullText)
+		if err != nil {
+			log.Printf("❌ %s: %v", term, err)
+		}
+		added := 0
+		for _, article := range found {
+			if seen[article.ID] || !data.ValidateArticle(article) {
+				continue
+			}
+			seen[article.ID] = true
+			articles = append(articles, article)
+			added++
+			if len(article.Sections) > 0 {
+				withSections++
+			}
+		}
+		log.Printf("✅ %s: %d articles, %d new", term, len(found), added)
+	}
+	if len(articles) == 0 {
+		log.Fatalf("❌ No articles collected")
+	}
+
+	path := filepath.Join(*outDir, fmt.Sprintf("europepmc_%s%s", time.Now().Format("20060102_150405"), extension))
+	if err := writeArticles(path, articles); err != nil {
+		log.Fatalf("❌ Could not write %s: %v", path, err)
+	}
+	log.Printf("💾 Wrote %d articles (%d with full text) to %s", len(articles), withSections, path)
+}
+
+// writeArticles writes under a hidden name and renames the file when
+// complete, so a watching indexer never reads a partial file
+func writeArticles(path string, articles []models.MedicalArticle) error {
+	partial := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	out, err := data.OpenOutput(partial)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	for _, article := range articles {
+		if err := encoder.Encode(article); err != nil {
+			out.Close()
+			os.Remove(partial)
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"MedAtlasAIServer/pkg/sources"
+)
+
+// collectPeriodically runs the configured data sources into dir right away
+// and then every interval, until ctx is cancelled. The watcher indexes the
+// files they write like any other collector's.
+func collectPeriodically(ctx context.Context, file sources.File, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, cfg := range file.Sources {
+			path, count, err := sources.Run(ctx, cfg, dir, ".jsonl", time.Now())
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Printf("❌ Collecting from %s failed: %v", cfg.Name, err)
+			case path != "":
+				log.Printf("💾 Collected %d %s articles into %s", count, cfg.Name, path)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,32 +3,136 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/audit"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/contentstore"
+	"MedAtlasAIServer/internal/docstore"
 	"MedAtlasAIServer/internal/embeddingClient"
 	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/tiering"
 	"MedAtlasAIServer/pkg/data"
+	"MedAtlasAIServer/pkg/search"
+	"MedAtlasAIServer/pkg/sources"
 
+	"github.com/gorilla/mux"
 	"github.com/qdrant/go-client/qdrant"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// dataFilePatterns name the collector outputs the indexer reads, one per
+// registered data source
+var dataFilePatterns = sources.FilePatterns()
+
 // Global counter for processed documents
 var totalProcessed int64
 
+// The collections of points derived from articles; a -reindex run versions
+// them along with the article tiers
+var (
+	sectionsCollection = data.SectionsCollection
+	chunksCollection   = data.ChunksCollection
+)
+
 func main() {
+	reportPath := flag.String("validation-report", "data/reports/validation_report.json", "path of the JSON validation report written after the run")
+	apiAddr := flag.String("api-addr", "", "address for the indexer status API (e.g. :9090), disabled when empty")
+	pipelineConfig := flag.String("pipeline-config", "", "JSON file mapping sources to enrichment stages, defaults to clean/enhance")
+	recentWindow := flag.Duration("recent-window", tiering.DefaultWindow, "articles published within this window are indexed into the recent tier")
+	migrateTiers := flag.Bool("migrate-tiers", false, "move articles that aged out of the recent tier into the historical tier, then exit (run nightly)")
+	watch := flag.Bool("watch", false, "after indexing the existing files, keep running and index new or modified files in -watch-dir")
+	watchDir := flag.String("watch-dir", "data/raw", "directory watched in -watch mode")
+	collectConfig := flag.String("collect", "", "in -watch mode, also run the data sources listed in this source config into -watch-dir every -collect-every")
+	collectEvery := flag.Duration("collect-every", 24*time.Hour, "how often -collect runs the data sources")
+	workers := flag.Int("workers", 4, "articles enriched and embedded concurrently")
+	uploaders := flag.Int("upload-workers", 2, "batches upserted to Qdrant concurrently")
+	payloadRefs := flag.Bool("payload-refs", true, "store abstracts and section texts once in the content store (CONTENT_STORE_DIR) and keep only snippets and hashes in Qdrant payloads")
+	embedBatch := flag.Int("embed-batch", 0, "texts per embedding request; 0 probes the service for its best batch size, 1 sends one text per request")
+	chunkWords := flag.Int("chunk-words", data.DefaultChunkWords, "abstracts longer than this many words are also indexed as overlapping passages in the article_chunks collection, 0 disables chunking")
+	ensureIndexes := flag.Bool("ensure-indexes", false, "create any missing payload indexes on the existing collections, then exit")
+	reconcileSample := flag.Int("reconcile-sample", 5, "points per uploaded batch looked up by ID after each file to find missing documents, 0 checks every point")
+	quantization := flag.String("quantization", "", "quantization of the vectors of new collections: none, scalar or product (overrides collections.storage in the config)")
+	onDiskVectors := flag.Bool("on-disk-vectors", false, "keep the original vectors of new collections on disk (overrides collections.storage in the config)")
+	reindex := flag.Bool("reindex", false, "index into new versioned article collections, verify their counts and switch the configured collection names, kept as aliases, to them")
+	reindexReplace := flag.Bool("reindex-replace", false, "with -reindex, delete a collection holding the configured name so the alias can take its place (first blue/green run only)")
+	reindexMinRatio := flag.Float64("reindex-min-ratio", 0.9, "with -reindex, least share of the articles served now the new collections must hold to be switched to")
+	migrateStorage := flag.Bool("migrate-storage", false, "apply the configured quantization and on-disk setting to the existing collections, then exit")
+	collection := config.CollectionFlag()
+	flag.Parse()
+	collections, err := config.LoadCollections(*collection)
+	if err != nil {
+		log.Fatalf("❌ Invalid collection settings: %v", err)
+	}
+	storage := collections.Storage
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "quantization":
+			storage.Quantization = *quantization
+		case "on-disk-vectors":
+			storage.OnDisk = *onDiskVectors
+		}
+	})
+	if err := storage.Validate(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if *reindex && (*watch || *migrateTiers || *ensureIndexes || *migrateStorage) {
+		log.Fatalf("❌ -reindex cannot be combined with -watch, -migrate-tiers, -ensure-indexes or -migrate-storage")
+	}
+
+	router := tiering.NewRouter()
+	router.Window = *recentWindow
+
+	if *workers < 1 || *uploaders < 1 {
+		log.Fatalf("❌ -workers and -upload-workers must be at least 1")
+	}
+	if *reconcileSample < 0 {
+		log.Fatalf("❌ -reconcile-sample must not be negative")
+	}
+	var collectSources sources.File
+	if *collectConfig != "" {
+		if !*watch || *collectEvery <= 0 {
+			log.Fatalf("❌ -collect needs -watch and a positive -collect-every")
+		}
+		var err error
+		if collectSources, err = sources.Load(*collectConfig); err != nil {
+			log.Fatalf("❌ Invalid source config: %v", err)
+		}
+	}
+	if *embedBatch < 0 {
+		log.Fatalf("❌ -embed-batch must not be negative")
+	}
+
+	pipelines, err := data.LoadSourcePipelines(*pipelineConfig)
+	if err != nil {
+		log.Fatalf("❌ Invalid pipeline config: %v", err)
+	}
+	configStore, err := config.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+	writes := configStore.Current().QdrantWrites
+	log.Printf("✍️  Qdrant writes: wait=%t ordering=%s", writes.Wait, writes.Ordering)
+
 	log.Println("🚀 Starting Medical Document Indexer...")
 	log.Println("📊 Initializing services...")
 
 	// Initialize clients
-	embedder := embeddingClient.NewClient("http://localhost:8000")
-	qdrantConn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
+	qdrantConn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		log.Fatalf("❌ Could not connect to Qdrant: %v", err)
 	}
@@ -37,6 +141,19 @@ func main() {
 	collectionsClient := qdrant.NewCollectionsClient(qdrantConn)
 	pointsClient := qdrant.NewPointsClient(qdrantConn)
 
+	if *migrateTiers {
+		runTierMigration(pointsClient, router, writes)
+		return
+	}
+	if *ensureIndexes {
+		ensurePayloadIndexes(context.Background(), pointsClient, *chunkWords > 0)
+		return
+	}
+	if *migrateStorage {
+		migrateVectorStorage(context.Background(), collectionsClient, storage, *chunkWords > 0)
+		return
+	}
+
 	// Test embedding service and get dimension
 	log.Println("🔍 Testing embedding service...")
 	testVector, err := embedder.GetEmbedding("medical research treatment cancer immunotherapy")
@@ -45,15 +162,37 @@ func main() {
 	}
 	vectorSize := len(testVector)
 	fmt.Printf("✅ Embedding dimension: %d\n", vectorSize)
+	batcher := embeddingClient.NewBatcher(embedder, *embedBatch)
+	if *embedBatch == 0 {
+		batcher.Size = probeBatchSize(embedder)
+	}
+	if batcher.Size > *workers {
+		// Each worker embeds one text at a time, so filling a batch takes
+		// as many workers as the batch has texts
+		*workers = batcher.Size
+		log.Printf("🧵 Running %d workers to fill embedding batches", *workers)
+	}
 
-	// Setup collection
+	// Setup one collection per tier
 	ctx := context.Background()
-	setupCollection(ctx, collectionsClient, vectorSize)
+	var blueGreenRun blueGreen
+	if *reindex {
+		blueGreenRun = startReindex(time.Now(), *chunkWords > 0)
+		log.Printf("🔵 Reindexing into %s; %s keeps serving until the switch", blueGreenRun.version, blueGreenRun.alias)
+	}
+	for _, name := range vectorCollections(*chunkWords > 0) {
+		setupCollection(ctx, collectionsClient, name, vectorSize, storage, writes.ShardKey)
+	}
+	ensurePayloadIndexes(ctx, pointsClient, *chunkWords > 0)
 
 	// Find all PubMed data files
-	dataFiles, err := filepath.Glob("data/raw/pubmed_*.jsonl")
-	if err != nil {
-		log.Fatalf("❌ Error finding data files: %v", err)
+	var dataFiles []string
+	for _, pattern := range dataFilePatterns {
+		matches, err := data.GlobJSONL(filepath.Join("data/raw", pattern))
+		if err != nil {
+			log.Fatalf("❌ Error finding data files: %v", err)
+		}
+		dataFiles = append(dataFiles, matches...)
 	}
 
 	// Add sample data if exists
@@ -61,50 +200,215 @@ func main() {
 		dataFiles = append(dataFiles, "data/medical_sample_large.jsonl")
 	}
 
-	if len(dataFiles) == 0 {
+	if len(dataFiles) == 0 && !*watch {
 		log.Fatalf("❌ No data files found in data/raw/ directory")
 	}
 
 	log.Printf("📁 Found %d data files to process", len(dataFiles))
 
-	// Track seen IDs to avoid duplicates
-	seenIDs := make(map[string]bool)
+	auditLog, err := audit.OpenFromEnv("indexer")
+	if err != nil {
+		log.Fatalf("❌ Could not open audit log: %v", err)
+	}
+	defer auditLog.Close()
+	auditLog.Record(ctx, "reindex.start", tiering.HistoricalCollection(), map[string]string{"files": fmt.Sprint(len(dataFiles))})
+
+	report := data.NewValidationReport()
+	if *apiAddr != "" {
+		startStatusAPI(*apiAddr, report)
+	}
+
+	var content *contentstore.Store
+	if *payloadRefs {
+		content = contentstore.FromEnv()
+		log.Printf("🗜️  Long payload texts are stored in %s", content.Dir)
+	}
+	docs, err := docstore.FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Could not open document store: %v", err)
+	}
+	if docs != nil {
+		log.Printf("🗄️  Full article records are kept in the %s document store", os.Getenv("DOC_STORE"))
+	}
+
+	// Track which file indexed each ID to avoid duplicates, and the DOIs and
+	// titles indexed so far to catch the same paper under another source's ID
+	seenIDs := make(map[string]string)
+	dedup := data.NewDeduplicator()
 	duplicateCount := 0
 
-	// Process each file
-	for _, dataFile := range dataFiles {
+	// Process each file, then check its uploads really reached Qdrant
+	uploads := newReconciler(*reconcileSample, writes.Wait)
+	indexFile := func(dataFile string) {
 		log.Printf("📄 Processing file: %s", dataFile)
-		fileProcessed, fileDuplicates := processFile(ctx, dataFile, embedder, pointsClient, vectorSize, seenIDs)
+		fileProcessed, fileDuplicates := processFile(ctx, dataFile, batcher, pointsClient, vectorSize, seenIDs, dedup, report, pipelines, router, content, docs, *chunkWords, *workers, *uploaders, writes, uploads)
 		atomic.AddInt64(&totalProcessed, int64(fileProcessed))
 		duplicateCount += fileDuplicates
 		log.Printf("✅ Processed %d documents from %s (%d duplicates skipped)",
 			fileProcessed, dataFile, fileDuplicates)
+		reconciliation := uploads.Verify(ctx, pointsClient)
+		logReconciliation(reconciliation)
+		report.AddReconciliation(reconciliation)
+	}
+	for _, dataFile := range dataFiles {
+		indexFile(dataFile)
+	}
+
+	if *watch {
+		// Collector runs add files to the watched directory; index each as
+		// it lands and keep the report current for the status API
+		if len(collectSources.Sources) > 0 {
+			go collectPeriodically(ctx, collectSources, *watchDir, *collectEvery)
+		}
+		err := watchDirectory(ctx, *watchDir, dataFilePatterns, func(dataFile string) {
+			indexFile(dataFile)
+			auditLog.Record(ctx, "reindex.file", tiering.HistoricalCollection(), map[string]string{"file": dataFile})
+			if err := report.WriteJSON(*reportPath); err != nil {
+				log.Printf("⚠️  Error writing validation report: %v", err)
+			}
+		})
+		if err != nil {
+			log.Fatalf("❌ Watching %s failed: %v", *watchDir, err)
+		}
+		return
 	}
 
 	log.Printf("🎉 Indexing complete! Total documents processed: %d", totalProcessed)
 	log.Printf("🔁 Duplicates skipped: %d", duplicateCount)
 
-	// Verify the final count
-	countResp, err := pointsClient.Count(ctx, &qdrant.CountPoints{
-		CollectionName: "medical_abstracts",
-		// Exact:          &qdrant.Exact{Exact: true},
+	report.Finish()
+	snapshot := report.Snapshot()
+	missing := 0
+	if snapshot.Reconciliation != nil {
+		missing = len(snapshot.Reconciliation.Missing)
+	}
+	auditLog.Record(ctx, "reindex.finish", tiering.HistoricalCollection(), map[string]string{
+		"processed":  fmt.Sprint(totalProcessed),
+		"duplicates": fmt.Sprint(duplicateCount),
+		"rejected":   fmt.Sprint(snapshot.Rejected),
+		"missing":    fmt.Sprint(missing),
 	})
-	if err != nil {
-		log.Printf("⚠️  Error counting points: %v", err)
+	logValidationSummary(report)
+	logPipelineMetrics(pipelines)
+	if err := report.WriteJSON(*reportPath); err != nil {
+		log.Printf("⚠️  Error writing validation report: %v", err)
 	} else {
-		log.Printf("📈 Total points in collection: %d", countResp.Result.Count)
+		log.Printf("📝 Validation report written to %s", *reportPath)
 	}
 
-	// Check for discrepancy
-	if countResp.Result.Count != uint64(totalProcessed) {
-		log.Printf("⚠️  WARNING: Collection count (%d) doesn't match processed count (%d)",
-			countResp.Result.Count, totalProcessed)
-		log.Printf("💡 Some documents may have failed to index or were duplicates")
+	// Collection sizes include documents from earlier runs, so they are
+	// informational; the reconciliation above is what finds missing documents
+	exact := writes.Wait
+	for _, collection := range []string{tiering.RecentCollection(), tiering.HistoricalCollection()} {
+		countResp, err := pointsClient.Count(ctx, &qdrant.CountPoints{
+			CollectionName: collection,
+			Exact:          &exact,
+		})
+		if err != nil {
+			log.Printf("⚠️  Error counting points in %s: %v", collection, err)
+			continue
+		}
+		log.Printf("📈 Total points in %s: %d", collection, countResp.Result.Count)
+	}
+	if missing > 0 {
+		log.Printf("⚠️  WARNING: %d uploaded documents are missing from Qdrant; see the reconciliation in %s", missing, *reportPath)
+	}
+
+	if *reindex {
+		if missing > 0 {
+			log.Fatalf("❌ Not switching %s to %s: documents are missing", blueGreenRun.alias, blueGreenRun.version)
+		}
+		if err := blueGreenRun.verify(ctx, pointsClient, totalProcessed, *reindexMinRatio); err != nil {
+			log.Fatalf("❌ Not switching %s to %s: %v", blueGreenRun.alias, blueGreenRun.version, err)
+		}
+		previous, err := blueGreenRun.switchAliases(ctx, collectionsClient, *reindexReplace)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		auditLog.Record(ctx, "reindex.switch", blueGreenRun.alias, map[string]string{"collection": blueGreenRun.version})
+		log.Printf("🟢 %s now serves %s", blueGreenRun.alias, blueGreenRun.version)
+		if len(previous) > 0 {
+			log.Printf("💡 The previous collections (%s) are kept for a rollback; delete them once the new ones are trusted", strings.Join(previous, ", "))
+		}
 	}
 }
 
-func setupCollection(ctx context.Context, client qdrant.CollectionsClient, vectorSize int) {
-	log.Println("🔄 Setting up Qdrant collection...")
+// runTierMigration moves aged articles from the recent to the historical tier
+func runTierMigration(pointsClient qdrant.PointsClient, router tiering.Router, writes config.QdrantWrites) {
+	ctx := context.Background()
+	auditLog, err := audit.OpenFromEnv("indexer")
+	if err != nil {
+		log.Fatalf("❌ Could not open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	log.Printf("🗄️  Migrating articles published before %s to %s...", router.Cutoff().Format("2006-01-02"), tiering.HistoricalCollection())
+	start := time.Now()
+	moved, err := tiering.Migrate(ctx, configuredWrites{pointsClient, writes}, router)
+	auditLog.Record(ctx, "tiers.migrate", tiering.RecentCollection(), map[string]string{
+		"moved":  fmt.Sprint(moved),
+		"cutoff": router.Cutoff().Format("2006-01-02"),
+	})
+	if err != nil {
+		log.Fatalf("❌ Tier migration stopped after %d articles: %v", moved, err)
+	}
+	log.Printf("✅ Moved %d articles in %v", moved, time.Since(start))
+}
+
+// ensurePayloadIndexes creates the payload indexes searches rely on: full
+// text on the article tiers for hybrid search, and the filter fields on
+// every collection carrying them. Existing indexes are left alone.
+func ensurePayloadIndexes(ctx context.Context, points qdrant.PointsClient, chunks bool) {
+	for _, collection := range []string{tiering.HistoricalCollection(), tiering.RecentCollection()} {
+		// Hybrid search matches query terms in titles and abstracts
+		if err := search.EnsureTextIndexes(ctx, points, collection); err != nil {
+			log.Printf("⚠️  Could not create text indexes on %s: %v", collection, err)
+		}
+	}
+	for _, collection := range vectorCollections(chunks) {
+		if err := search.EnsureFilterIndexes(ctx, points, collection); err != nil {
+			log.Printf("⚠️  Could not create filter indexes on %s: %v", collection, err)
+			continue
+		}
+		log.Printf("🗂️  Filter indexes ready on %s", collection)
+	}
+}
+
+// vectorCollections are the collections the indexer writes: the article
+// tiers, the sections and, when chunking, the passages
+func vectorCollections(chunks bool) []string {
+	collections := []string{tiering.HistoricalCollection(), tiering.RecentCollection(), sectionsCollection}
+	if chunks {
+		collections = append(collections, chunksCollection)
+	}
+	return collections
+}
+
+// migrateVectorStorage moves the existing collections to storage. Qdrant
+// converts them in the background while they keep serving searches.
+func migrateVectorStorage(ctx context.Context, client qdrant.CollectionsClient, storage config.VectorStorage, chunks bool) {
+	for _, name := range vectorCollections(chunks) {
+		info, err := client.Get(ctx, &qdrant.GetCollectionInfoRequest{CollectionName: name})
+		if err != nil {
+			log.Printf("⚠️  Skipping %s: %v", name, err)
+			continue
+		}
+		if search.SameVectorStorage(search.VectorStorageOf(info.GetResult()), storage) {
+			log.Printf("✅ %s already stores vectors as configured", name)
+			continue
+		}
+		if err := search.ApplyVectorStorage(ctx, client, name, storage); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("🗜️  %s now converting to quantization=%s on_disk=%t; Qdrant rebuilds its segments in the background", name, storage.Quantization, storage.OnDisk)
+	}
+}
+
+// setupCollection creates the collection if needed, storing its vectors as
+// storage says. With a shardKey it is created with custom sharding and the
+// key is added to it.
+func setupCollection(ctx context.Context, client qdrant.CollectionsClient, name string, vectorSize int, storage config.VectorStorage, shardKey string) {
+	log.Printf("🔄 Setting up Qdrant collection %s...", name)
 
 	// First, check if collection exists
 	listResp, err := client.List(ctx, &qdrant.ListCollectionsRequest{})
@@ -115,189 +419,499 @@ func setupCollection(ctx context.Context, client qdrant.CollectionsClient, vecto
 
 	collectionExists := false
 	for _, coll := range listResp.Collections {
-		if coll.Name == "medical_abstracts" {
+		if coll.Name == name {
 			collectionExists = true
 			break
 		}
 	}
+	if !collectionExists {
+		// After a blue/green reindex the configured names are aliases
+		if aliases, err := client.ListAliases(ctx, &qdrant.ListAliasesRequest{}); err == nil {
+			for _, alias := range aliases.GetAliases() {
+				collectionExists = collectionExists || alias.GetAliasName() == name
+			}
+		}
+	}
 
 	if collectionExists {
 		log.Println("📊 Collection already exists. Checking if we need to recreate...")
 		// For now, let's keep the existing collection to avoid data loss
 		log.Println("💡 Using existing collection - new documents will be added/updated")
+		if info, err := client.Get(ctx, &qdrant.GetCollectionInfoRequest{CollectionName: name}); err == nil &&
+			!search.SameVectorStorage(search.VectorStorageOf(info.GetResult()), storage) {
+			log.Printf("⚠️  %s stores its vectors differently from the configured storage; run the indexer with -migrate-storage to convert it", name)
+		}
+		if shardKey != "" {
+			ensureShardKey(ctx, client, name, shardKey)
+		}
 		return
 	}
 
 	// Create new collection if it doesn't exist
 	log.Printf("🆕 Creating new collection with vector size: %d", vectorSize)
-	_, err = client.Create(ctx, &qdrant.CreateCollection{
-		CollectionName: "medical_abstracts",
+	create := &qdrant.CreateCollection{
+		CollectionName: name,
 		VectorsConfig: &qdrant.VectorsConfig{Config: &qdrant.VectorsConfig_Params{
-			Params: &qdrant.VectorParams{
-				Size:     uint64(vectorSize),
-				Distance: qdrant.Distance_Cosine,
-			},
+			Params: search.VectorParams(vectorSize, storage),
 		}},
-	})
+	}
+	if shardKey != "" {
+		create.ShardingMethod = qdrant.ShardingMethod_Custom.Enum()
+	}
+	_, err = client.Create(ctx, create)
 	if err != nil {
 		log.Fatalf("❌ Failed to create collection: %v", err)
 	}
+	if shardKey != "" {
+		ensureShardKey(ctx, client, name, shardKey)
+	}
 	log.Println("✅ Collection created successfully")
 }
 
-func processFile(ctx context.Context, filename string, embedder *embeddingClient.Client,
-	pointsClient qdrant.PointsClient, vectorSize int, seenIDs map[string]bool) (int, int) {
+// textEmbedder embeds one text; the indexer's is an embeddingClient.Batcher
+// that sends the texts of concurrent workers together
+type textEmbedder interface {
+	GetEmbedding(text string) ([]float32, error)
+}
+
+// maxEmbedBatch bounds the batch sizes probed
+const maxEmbedBatch = 64
 
-	file, err := os.Open(filename)
+// probeBatchSize finds the embedding batch size with the best throughput,
+// falling back to one text per request when the service cannot batch
+func probeBatchSize(embedder *embeddingClient.Client) int {
+	size, timings, err := embedder.ProbeBatchSize(context.Background(), maxEmbedBatch)
+	for _, timing := range timings {
+		log.Printf("   batch %3d: %v (%.0f texts/s)", timing.Size, timing.Latency.Round(time.Millisecond), timing.Throughput())
+	}
+	if err != nil {
+		log.Printf("⚠️  Embedding batch probe failed, sending one text per request: %v", err)
+		return 1
+	}
+	log.Printf("🧮 Embedding batch size: %d", size)
+	return size
+}
+
+// indexJob is one decoded article, numbered in file order
+type indexJob struct {
+	seq       int
+	article   models.MedicalArticle
+	decodeErr error
+}
+
+// indexResult is what a worker made of an indexJob. Log lines are held back
+// until the result's turn so each file's log reads in input order.
+type indexResult struct {
+	seq        int
+	id         string
+	point      *qdrant.PointStruct // nil when the article is not indexed
+	collection string
+	sections   []*qdrant.PointStruct // full-text sections, for sectionsCollection
+	chunks     []*qdrant.PointStruct // abstract passages, for chunksCollection
+	accepted   bool
+	rejected   string // validation reason, when rejected
+	logs       []string
+}
+
+// uploadJob is one batch of points for a tier collection
+type uploadJob struct {
+	number     int
+	collection string
+	points     []*qdrant.PointStruct
+}
+
+// processFile indexes one data file. Articles are decoded in order, then
+// enriched and embedded by workers goroutines while uploaders goroutines
+// upsert full batches. It returns the number of articles that reached
+// Qdrant and the number skipped as duplicates, by ID or by DOI and title.
+func processFile(ctx context.Context, filename string, embedder textEmbedder,
+	pointsClient qdrant.PointsClient, vectorSize int, seenIDs map[string]string, dedup *data.Deduplicator, report *data.ValidationReport,
+	pipelines *data.SourcePipelines, router tiering.Router, content *contentstore.Store, docs docstore.Store, chunkWords, workers, uploaders int, writes config.QdrantWrites, reconcile *reconciler) (int, int) {
+
+	file, err := data.OpenInput(filename)
 	if err != nil {
 		log.Printf("❌ Error opening file %s: %v", filename, err)
 		return 0, 0
 	}
 	defer file.Close()
 
-	decoder := json.NewDecoder(file)
 	batchSize := 10 // Increased batch size for efficiency
-	processed := 0
 	duplicateCount := 0
+
+	// Decode sequentially: duplicate detection depends on file order
+	jobs := make(chan indexJob, workers*2)
+	go func() {
+		defer close(jobs)
+		decoder := json.NewDecoder(file)
+		seq := 0
+		for decoder.More() {
+			var article models.MedicalArticle
+			if err := decoder.Decode(&article); err != nil {
+				jobs <- indexJob{seq: seq, decodeErr: err}
+				seq++
+				continue
+			}
+
+			// Skip duplicates across files. A file processed again after it
+			// changed reindexes its own articles.
+			if owner, seen := seenIDs[article.ID]; seen && owner != filename {
+				duplicateCount++
+				continue
+			}
+			if original, match := dedup.Duplicate(article); original != "" {
+				log.Printf("🔁 %s duplicates %s (same %s), skipping", article.ID, original, match)
+				duplicateCount++
+				continue
+			}
+			seenIDs[article.ID] = filename
+			jobs <- indexJob{seq: seq, article: article}
+			seq++
+		}
+	}()
+
+	results := make(chan indexResult, workers*2)
+	var workerGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerGroup.Add(1)
+		go func() {
+			defer workerGroup.Done()
+			for job := range jobs {
+				results <- prepareArticle(job, filename, embedder, vectorSize, pipelines, router, content, docs, chunkWords)
+			}
+		}()
+	}
+	go func() {
+		workerGroup.Wait()
+		close(results)
+	}()
+
+	var processed int64
+	uploads := make(chan uploadJob, uploaders)
+	var uploadGroup sync.WaitGroup
+	for i := 0; i < uploaders; i++ {
+		uploadGroup.Add(1)
+		go func() {
+			defer uploadGroup.Done()
+			for batch := range uploads {
+				if uploadBatchWithRetry(ctx, pointsClient, batch.collection, batch.points, batch.number, 3, writes) { // 3 retries
+					// Sections and passages are not documents of their own
+					if batch.collection != sectionsCollection && batch.collection != chunksCollection {
+						atomic.AddInt64(&processed, int64(len(batch.points)))
+					}
+					reconcile.Uploaded(batch)
+				} else {
+					log.Printf("❌ Batch %d failed after retries, skipping %d documents", batch.number, len(batch.points))
+					reconcile.Failed(batch)
+				}
+			}
+		}()
+	}
+
+	// Collect results in file order, batching points per tier collection
 	batchCount := 0
-	var points []*qdrant.PointStruct
+	batches := make(map[string][]*qdrant.PointStruct)
+	pending := make(map[int]indexResult)
+	next := 0
+	enqueue := func(collection string, point *qdrant.PointStruct) {
+		batches[collection] = append(batches[collection], point)
+		if points := batches[collection]; len(points) >= batchSize {
+			batchCount++
+			uploads <- uploadJob{number: batchCount, collection: collection, points: points}
+			batches[collection] = make([]*qdrant.PointStruct, 0, batchSize)
+		}
+	}
+	for result := range results {
+		pending[result.seq] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
 
-	for decoder.More() {
-		var article models.MedicalArticle
-		if err := decoder.Decode(&article); err != nil {
-			log.Printf("❌ Error decoding JSON in %s: %v", filename, err)
-			continue
+			for _, line := range result.logs {
+				log.Print(line)
+			}
+			if result.rejected != "" {
+				report.RecordRejected(result.id, result.rejected)
+			}
+			if result.accepted {
+				report.RecordAccepted()
+			}
+			if result.point == nil {
+				continue
+			}
+			enqueue(result.collection, result.point)
+			for _, section := range result.sections {
+				enqueue(sectionsCollection, section)
+			}
+			for _, chunk := range result.chunks {
+				enqueue(chunksCollection, chunk)
+			}
 		}
+	}
 
-		// Skip duplicates across files
-		if seenIDs[article.ID] {
-			duplicateCount++
+	// Upload final batches
+	for collection, points := range batches {
+		if len(points) == 0 {
 			continue
 		}
-		seenIDs[article.ID] = true
+		batchCount++
+		uploads <- uploadJob{number: batchCount, collection: collection, points: points}
+	}
+	close(uploads)
+	uploadGroup.Wait()
 
-		// Clean and enhance the data first
-		article.Title = data.CleanMedicalText(article.Title)
-		article.Abstract = data.CleanMedicalText(article.Abstract)
-		article.Abstract = data.NormalizeMedicalTerms(article.Abstract)
+	return int(processed), duplicateCount
+}
 
-		// ENHANCE: Extract medical concepts and detect medical terminology
-		article = *data.EnhanceArticle(&article)
+// prepareArticle enriches, validates and embeds one article and builds its
+// Qdrant point. With a content store, long texts are moved out of the
+// payloads into it; with a document store, the article is recorded there
+// and its point keeps docstore.PayloadFields only. Abstracts longer than
+// chunkWords are also split into passage points.
+func prepareArticle(job indexJob, filename string, embedder textEmbedder, vectorSize int,
+	pipelines *data.SourcePipelines, router tiering.Router, content *contentstore.Store, docs docstore.Store, chunkWords int) indexResult {
+
+	result := indexResult{seq: job.seq}
+	if job.decodeErr != nil {
+		result.logs = append(result.logs, fmt.Sprintf("❌ Error decoding JSON in %s: %v", filename, job.decodeErr))
+		return result
+	}
+	article := job.article
+	result.id = article.ID
 
-		// Only process if it contains medical content
-		if !article.HasMedicalTerms {
-			log.Printf("⚠️  Skipping non-medical article: %s", article.ID)
-			continue
-		}
+	// Clean and enhance via the source's enrichment pipeline
+	if err := pipelines.For(article.Source).Run(&article); err != nil {
+		result.logs = append(result.logs, fmt.Sprintf("❌ Enrichment failed for %s: %v", article.ID, err))
+		return result
+	}
 
-		// Validate after cleaning
-		if valid, reason := data.ValidateArticleWithReason(article); !valid {
-			log.Printf("⚠️  Skipping article %s: %s", article.ID, reason)
-			continue
-		}
+	// Only process if it contains medical content
+	if !article.HasMedicalTerms {
+		result.rejected = data.ReasonNonMedical
+		return result
+	}
 
-		// Create embedding from title and abstract
-		textToEmbed := article.Title + ". " + article.Abstract
-		vector, err := embedder.GetEmbedding(textToEmbed)
-		if err != nil {
-			log.Printf("❌ Error creating embedding for %s: %v", article.ID, err)
-			continue
-		}
+	// Validate after cleaning
+	if valid, reason := data.ValidateArticleWithReason(article); !valid {
+		result.rejected = reason
+		return result
+	}
+	result.accepted = true
 
-		// Verify vector dimension matches our collection
-		if len(vector) != vectorSize {
-			log.Printf("⚠️  Vector dimension mismatch for %s. Expected %d, got %d",
-				article.ID, vectorSize, len(vector))
-			// Skip this article if dimension doesn't match
-			continue
-		}
+	// Create embedding from title and abstract
+	textToEmbed := data.ExpandDrugNames(article.Title + ". " + article.Abstract)
+	vector, err := embedder.GetEmbedding(textToEmbed)
+	if err != nil {
+		result.logs = append(result.logs, fmt.Sprintf("❌ Error creating embedding for %s: %v", article.ID, err))
+		return result
+	}
 
-		// Prepare payload for Qdrant
-		payload := map[string]*qdrant.Value{
-			"title":          {Kind: &qdrant.Value_StringValue{StringValue: article.Title}},
-			"abstract":       {Kind: &qdrant.Value_StringValue{StringValue: article.Abstract}},
-			"authors":        {Kind: &qdrant.Value_StringValue{StringValue: data.FormatAuthors(article.Authors)}},
-			"published_date": {Kind: &qdrant.Value_StringValue{StringValue: article.PublishedDate.Format("2006-01-02")}},
-			"doi":            {Kind: &qdrant.Value_StringValue{StringValue: article.DOI}},
-			"journal":        {Kind: &qdrant.Value_StringValue{StringValue: article.Journal}},
-			"source":         {Kind: &qdrant.Value_StringValue{StringValue: article.Source}},
-			"id":             {Kind: &qdrant.Value_StringValue{StringValue: article.ID}},
-		}
-
-		// Add MeSH headings if available
-		if len(article.MeshHeadings) > 0 {
-			payload["mesh_headings"] = &qdrant.Value{
-				Kind: &qdrant.Value_ListValue{
-					ListValue: &qdrant.ListValue{
-						Values: convertToValueList(article.MeshHeadings),
-					},
-				},
+	// Verify vector dimension matches our collection
+	if len(vector) != vectorSize {
+		result.logs = append(result.logs, fmt.Sprintf("⚠️  Vector dimension mismatch for %s. Expected %d, got %d",
+			article.ID, vectorSize, len(vector)))
+		// Skip this article if dimension doesn't match
+		return result
+	}
+
+	result.point = articlePoint(&article, vector)
+	if docs != nil {
+		if err := docs.Put(context.Background(), []models.MedicalArticle{article}); err != nil {
+			result.logs = append(result.logs, fmt.Sprintf("❌ Document store failed for %s: %v", article.ID, err))
+			result.point = nil
+			return result
+		}
+		docstore.Minimize(result.point.Payload)
+	}
+	if content != nil {
+		if err := content.Compact(result.point.Payload); err != nil {
+			result.logs = append(result.logs, fmt.Sprintf("❌ Content store failed for %s: %v", article.ID, err))
+			result.point = nil
+			return result
+		}
+	}
+	result.collection = router.CollectionFor(article.PublishedDate)
+
+	if chunkWords > 0 {
+		if passages := data.ChunkText(article.Abstract, chunkWords, data.DefaultChunkOverlap); len(passages) > 1 {
+			for i, passage := range passages {
+				vector, err := embedder.GetEmbedding(data.ExpandDrugNames(article.Title + ". " + passage))
+				if err != nil || len(vector) != vectorSize {
+					result.logs = append(result.logs, fmt.Sprintf("⚠️  Skipping passage %d of %s: embedding failed", i, article.ID))
+					continue
+				}
+				point := chunkPoint(&article, i, passage, vector, docs != nil)
+				if content != nil {
+					if err := content.Compact(point.Payload); err != nil {
+						result.logs = append(result.logs, fmt.Sprintf("⚠️  Skipping passage %d of %s: %v", i, article.ID, err))
+						continue
+					}
+				}
+				result.chunks = append(result.chunks, point)
 			}
 		}
+	}
 
-		// Add key concepts if available
-		if len(article.KeyConcepts) > 0 {
-			payload["key_concepts"] = &qdrant.Value{
-				Kind: &qdrant.Value_ListValue{
-					ListValue: &qdrant.ListValue{
-						Values: convertToValueList(article.KeyConcepts),
-					},
-				},
+	for i, section := range article.Sections {
+		if section.Kind == data.SectionOther {
+			continue // acknowledgements, funding, abbreviations and the like
+		}
+		text := section.Text
+		if len(text) > maxSectionEmbedChars {
+			cut := maxSectionEmbedChars
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
 			}
+			text = text[:cut]
 		}
-
-		point := &qdrant.PointStruct{
-			Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: parseID(article.ID)}},
-			Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
-			Payload: payload,
+		heading := section.Heading
+		if heading == "" {
+			heading = section.Kind
 		}
-
-		points = append(points, point)
-		processed++
-
-		// Upload batch when full
-		if len(points) >= batchSize {
-			batchCount++
-			success := uploadBatchWithRetry(ctx, pointsClient, points, batchCount, 3) // 3 retries
-			if !success {
-				log.Printf("❌ Batch %d failed after retries, skipping %d documents", batchCount, len(points))
-				// Reset points but don't count them as processed
-				processed -= len(points)
+		vector, err := embedder.GetEmbedding(article.Title + ". " + heading + ". " + text)
+		if err != nil || len(vector) != vectorSize {
+			result.logs = append(result.logs, fmt.Sprintf("⚠️  Skipping %s section of %s: embedding failed", section.Kind, article.ID))
+			continue
+		}
+		point := sectionPoint(&article, i, vector)
+		if content != nil {
+			if err := content.Compact(point.Payload); err != nil {
+				result.logs = append(result.logs, fmt.Sprintf("⚠️  Skipping %s section of %s: %v", section.Kind, article.ID, err))
+				continue
 			}
-			points = make([]*qdrant.PointStruct, 0, batchSize)
 		}
+		result.sections = append(result.sections, point)
 	}
+	return result
+}
 
-	// Upload final batch
-	if len(points) > 0 {
-		batchCount++
-		success := uploadBatchWithRetry(ctx, pointsClient, points, batchCount, 3)
-		if !success {
-			log.Printf("❌ Final batch failed after retries, skipping %d documents", len(points))
-			processed -= len(points)
+// maxSectionEmbedChars bounds the section text embedded; longer sections
+// are represented by their beginning
+const maxSectionEmbedChars = 2000
+
+// sectionPayload is the payload of a data.SectionsCollection point
+type sectionPayload struct {
+	ID            string    `payload:"id"`
+	Title         string    `payload:"title"`
+	Journal       string    `payload:"journal"`
+	PublishedDate time.Time `payload:"published_date,date"`
+	PublishedTS   int64     `payload:"published_ts,omitempty"`
+	PublishedYear int       `payload:"published_year,omitempty"`
+	Source        string    `payload:"source"`
+	Section       string    `payload:"section"`
+	Heading       string    `payload:"heading"`
+	Text          string    `payload:"text"`
+	Access        string    `payload:"access_label,omitempty"`
+}
+
+// sectionPoint builds the data.SectionsCollection point for one section
+func sectionPoint(article *models.MedicalArticle, index int, vector []float32) *qdrant.PointStruct {
+	section := article.Sections[index]
+	fields := sectionPayload{
+		ID:            article.ID,
+		Title:         article.Title,
+		Journal:       article.Journal,
+		PublishedDate: article.PublishedDate,
+		Source:        article.Source,
+		Section:       section.Kind,
+		Heading:       section.Heading,
+		Text:          section.Text,
+		Access:        article.Access,
+	}
+	if !article.PublishedDate.IsZero() {
+		fields.PublishedTS, fields.PublishedYear = article.PublishedDate.Unix(), article.PublishedDate.Year()
+	}
+	return &qdrant.PointStruct{
+		Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.SectionPointID(article.ID, index)}},
+		Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
+		Payload: payload.Encode(fields),
+	}
+}
+
+// chunkPoint builds the data.ChunksCollection point for one passage of the
+// abstract. It carries the article's filterable fields, so filtered
+// searches match its passages as they match the article, and parent_id,
+// which search.Chunked groups hits by. The abstract itself stays with the
+// article point.
+func chunkPoint(article *models.MedicalArticle, index int, passage string, vector []float32, minimal bool) *qdrant.PointStruct {
+	fields := ai.ArticlePayload(article)
+	if minimal {
+		docstore.Minimize(fields)
+	}
+	delete(fields, "abstract")
+	fields["parent_id"] = qdrant.NewValueString(article.ID)
+	fields["chunk_index"] = qdrant.NewValueInt(int64(index))
+	fields["text"] = qdrant.NewValueString(passage)
+	return &qdrant.PointStruct{
+		Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.ChunkPointID(article.ID, index)}},
+		Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
+		Payload: fields,
+	}
+}
+
+// articlePoint builds the Qdrant point for an article and its embedding
+func articlePoint(article *models.MedicalArticle, vector []float32) *qdrant.PointStruct {
+	return &qdrant.PointStruct{
+		Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(article.ID)}},
+		Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
+		Payload: ai.ArticlePayload(article),
+	}
+}
+
+// startStatusAPI serves the live validation report while the indexer runs
+func startStatusAPI(addr string, report *data.ValidationReport) {
+	r := mux.NewRouter()
+	r.HandleFunc("/validation-report", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report.Snapshot())
+	}).Methods("GET")
+
+	go func() {
+		log.Printf("🌐 Indexer status API listening on %s", addr)
+		if err := http.ListenAndServe(addr, r); err != nil {
+			log.Printf("⚠️  Indexer status API stopped: %v", err)
 		}
+	}()
+}
+
+func logValidationSummary(report *data.ValidationReport) {
+	snapshot := report.Snapshot()
+	log.Printf("🧪 Validation: %d accepted, %d rejected", snapshot.Accepted, snapshot.Rejected)
+	for reason, stats := range snapshot.Reasons {
+		log.Printf("   - %s: %d (e.g. %v)", reason, stats.Count, stats.SampleIDs)
 	}
+}
 
-	return processed, duplicateCount
+func logPipelineMetrics(pipelines *data.SourcePipelines) {
+	logOne := func(label string, pipeline *data.Pipeline) {
+		metrics := pipeline.Metrics()
+		for _, name := range pipeline.Stages() {
+			m := metrics[name]
+			log.Printf("⏱️  [%s] stage %s: %d runs, %d errors, avg %v", label, name, m.Runs, m.Errors, m.AverageTime())
+		}
+	}
+	logOne("default", pipelines.Default)
+	for _, source := range pipelines.Sources() {
+		logOne(source, pipelines.For(source))
+	}
 }
 
-func uploadBatchWithRetry(ctx context.Context, client qdrant.PointsClient,
-	points []*qdrant.PointStruct, batchNumber int, maxRetries int) bool {
+func uploadBatchWithRetry(ctx context.Context, client qdrant.PointsClient, collection string,
+	points []*qdrant.PointStruct, batchNumber int, maxRetries int, writes config.QdrantWrites) bool {
 
 	if len(points) == 0 {
 		return true
 	}
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		log.Printf("📤 Uploading batch %d (attempt %d/%d) with %d points...",
-			batchNumber, attempt, maxRetries, len(points))
+		log.Printf("📤 Uploading batch %d to %s (attempt %d/%d) with %d points...",
+			batchNumber, collection, attempt, maxRetries, len(points))
 
 		start := time.Now()
-		_, err := client.Upsert(ctx, &qdrant.UpsertPoints{
-			CollectionName: "medical_abstracts",
-			Points:         points,
-			// Wait:           &qdrant.Wait{Enabled: true},
-		})
+		_, err := client.Upsert(ctx, upsertRequest(collection, points, writes))
 
 		if err != nil {
 			log.Printf("❌ Batch %d attempt %d failed: %v", batchNumber, attempt, err)
@@ -315,22 +929,6 @@ func uploadBatchWithRetry(ctx context.Context, client qdrant.PointsClient,
 	return false
 }
 
-func parseID(idStr string) uint64 {
-	// First try to parse as number
-	var idNum uint64
-	_, err := fmt.Sscanf(idStr, "%d", &idNum)
-	if err == nil {
-		return idNum
-	}
-
-	// If not numeric, create a hash-based ID
-	hash := uint64(0)
-	for _, char := range idStr {
-		hash = hash*31 + uint64(char)
-	}
-	return hash
-}
-
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -338,11 +936,3 @@ func fileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
-
-func convertToValueList(strings []string) []*qdrant.Value {
-	values := make([]*qdrant.Value, len(strings))
-	for i, s := range strings {
-		values[i] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: s}}
-	}
-	return values
-}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// reconcileRecheckDelay is how long to wait before looking again for points
+// that were not found after unwaited writes
+const reconcileRecheckDelay = 2 * time.Second
+
+// uploadedPoint is one article sent to Qdrant
+type uploadedPoint struct {
+	articleID  string
+	collection string
+	pointID    uint64
+}
+
+// reconciler remembers what the upload workers sent so it can be checked
+// against Qdrant afterwards. It is safe for concurrent use.
+type reconciler struct {
+	perBatch int  // points looked up per batch; zero looks up all of them
+	waited   bool // whether upserts waited to be applied
+
+	mu       sync.Mutex
+	uploaded [][]uploadedPoint // one entry per successful batch
+	failed   []data.MissingDocument
+}
+
+func newReconciler(perBatch int, waited bool) *reconciler {
+	return &reconciler{perBatch: perBatch, waited: waited}
+}
+
+// Uploaded records a batch Qdrant accepted
+func (r *reconciler) Uploaded(batch uploadJob) {
+	points := make([]uploadedPoint, 0, len(batch.points))
+	for _, point := range batch.points {
+		points = append(points, uploadedPoint{
+			articleID:  point.GetPayload()["id"].GetStringValue(),
+			collection: batch.collection,
+			pointID:    point.GetId().GetNum(),
+		})
+	}
+	r.mu.Lock()
+	r.uploaded = append(r.uploaded, points)
+	r.mu.Unlock()
+}
+
+// Failed records a batch given up on after retries
+func (r *reconciler) Failed(batch uploadJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, point := range batch.points {
+		r.failed = append(r.failed, data.MissingDocument{
+			ID:         point.GetPayload()["id"].GetStringValue(),
+			Collection: batch.collection,
+			Reason:     "upload_failed",
+		})
+	}
+}
+
+// Verify looks up a sample of every recorded batch by ID and reports the
+// articles that are not in Qdrant. The recorded batches are cleared, so
+// each call covers the uploads since the previous one.
+func (r *reconciler) Verify(ctx context.Context, client qdrant.PointsClient) data.Reconciliation {
+	r.mu.Lock()
+	batches, failed := r.uploaded, r.failed
+	r.uploaded, r.failed = nil, nil
+	r.mu.Unlock()
+
+	result := data.Reconciliation{Missing: failed}
+	var sample []uploadedPoint
+	for _, batch := range batches {
+		result.Uploaded += len(batch)
+		sample = append(sample, r.sampleOf(batch)...)
+	}
+	result.Checked = len(sample)
+
+	missing := findMissing(ctx, client, sample)
+	if len(missing) > 0 && !r.waited {
+		// Unwaited writes may still be applying
+		time.Sleep(reconcileRecheckDelay)
+		missing = findMissing(ctx, client, missing)
+	}
+	for _, point := range missing {
+		result.Missing = append(result.Missing, data.MissingDocument{
+			ID:         point.articleID,
+			Collection: point.collection,
+			Reason:     "not_found",
+		})
+	}
+	return result
+}
+
+func (r *reconciler) sampleOf(batch []uploadedPoint) []uploadedPoint {
+	if r.perBatch == 0 || len(batch) <= r.perBatch {
+		return batch
+	}
+	sample := make([]uploadedPoint, 0, r.perBatch)
+	for _, i := range rand.Perm(len(batch))[:r.perBatch] {
+		sample = append(sample, batch[i])
+	}
+	return sample
+}
+
+// findMissing retrieves points by ID, one request per collection, and
+// returns those Qdrant does not have. A failed lookup counts its points as
+// missing rather than hiding them.
+func findMissing(ctx context.Context, client qdrant.PointsClient, points []uploadedPoint) []uploadedPoint {
+	byCollection := make(map[string][]uploadedPoint)
+	for _, point := range points {
+		byCollection[point.collection] = append(byCollection[point.collection], point)
+	}
+
+	var missing []uploadedPoint
+	for collection, expected := range byCollection {
+		ids := make([]*qdrant.PointId, len(expected))
+		for i, point := range expected {
+			ids[i] = qdrant.NewIDNum(point.pointID)
+		}
+		resp, err := client.Get(ctx, &qdrant.GetPoints{
+			CollectionName: collection,
+			Ids:            ids,
+			WithPayload:    qdrant.NewWithPayload(false),
+			WithVectors:    qdrant.NewWithVectors(false),
+		})
+		if err != nil {
+			log.Printf("⚠️  Could not look up %d points in %s: %v", len(expected), collection, err)
+			missing = append(missing, expected...)
+			continue
+		}
+		found := make(map[uint64]bool, len(resp.GetResult()))
+		for _, point := range resp.GetResult() {
+			found[point.GetId().GetNum()] = true
+		}
+		for _, point := range expected {
+			if !found[point.pointID] {
+				missing = append(missing, point)
+			}
+		}
+	}
+	return missing
+}
+
+// logReconciliation summarises a Verify result
+func logReconciliation(rc data.Reconciliation) {
+	if len(rc.Missing) == 0 {
+		log.Printf("🔎 Reconciliation: %d of %d uploaded documents checked, none missing", rc.Checked, rc.Uploaded)
+		return
+	}
+	log.Printf("⚠️  Reconciliation: %d documents missing (%d of %d uploaded checked)", len(rc.Missing), rc.Checked, rc.Uploaded)
+	for _, doc := range rc.Missing {
+		log.Printf("   - %s in %s: %s", doc.ID, doc.Collection, doc.Reason)
+	}
+}
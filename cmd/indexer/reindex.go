@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/tiering"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// blueGreen is a -reindex run: the article tiers, sections and passages
+// are rebuilt under versioned names while the configured names, which the
+// API and chat servers search, keep serving the current copy as aliases.
+// Once the new copy is verified, the aliases switch to it in one request.
+type blueGreen struct {
+	alias   string // the configured article collection
+	version string // the new historical tier; its recent tier adds "_recent"
+	chunks  bool   // whether passages are rebuilt too
+}
+
+// startReindex points this process's collections at new versions named
+// after them and now, e.g. medical_abstracts_v20240501120000
+func startReindex(now time.Time, chunks bool) blueGreen {
+	suffix := "_v" + now.UTC().Format("20060102150405")
+	collections := config.CurrentCollections()
+	run := blueGreen{alias: collections.Articles, version: collections.Articles + suffix, chunks: chunks}
+	collections.Articles = run.version
+	config.SetCollections(collections)
+	sectionsCollection = data.SectionsCollection + suffix
+	chunksCollection = data.ChunksCollection + suffix
+	return run
+}
+
+// articleTiers pairs each article tier's alias with the collection it
+// switches to
+func (b blueGreen) articleTiers() [][2]string {
+	return [][2]string{
+		{b.alias, tiering.HistoricalCollection()},
+		{b.alias + "_recent", tiering.RecentCollection()},
+	}
+}
+
+// tiers pairs every alias the run switches with its new collection
+func (b blueGreen) tiers() [][2]string {
+	tiers := append(b.articleTiers(), [2]string{data.SectionsCollection, sectionsCollection})
+	if b.chunks {
+		tiers = append(tiers, [2]string{data.ChunksCollection, chunksCollection})
+	}
+	return tiers
+}
+
+// verifyAttempts bounds how long verify waits for unwaited writes to land
+const verifyAttempts = 15
+
+// verify checks the new tiers hold the indexed articles, expected in all,
+// and at least minRatio as many as the aliases serve now, so a run over
+// missing or truncated files is not switched to
+func (b blueGreen) verify(ctx context.Context, points qdrant.PointsClient, expected int64, minRatio float64) error {
+	if expected == 0 {
+		return fmt.Errorf("no articles were indexed")
+	}
+	var indexed int64
+	for attempt := 0; ; attempt++ {
+		indexed = 0
+		for _, tier := range b.articleTiers() {
+			count, err := countPoints(ctx, points, tier[1])
+			if err != nil {
+				return err
+			}
+			indexed += count
+		}
+		if indexed >= expected || attempt == verifyAttempts-1 {
+			break
+		}
+		time.Sleep(reconcileRecheckDelay)
+	}
+	if indexed != expected {
+		return fmt.Errorf("the new collections hold %d articles, %d were indexed", indexed, expected)
+	}
+
+	var serving int64
+	for _, tier := range b.articleTiers() {
+		// A tier that does not exist yet serves nothing
+		if count, err := countPoints(ctx, points, tier[0]); err == nil {
+			serving += count
+		}
+	}
+	if float64(indexed) < minRatio*float64(serving) {
+		return fmt.Errorf("the new collections hold %d articles, under %.0f%% of the %d served now", indexed, minRatio*100, serving)
+	}
+	log.Printf("✅ Verified %d articles in %s (%d served now)", indexed, b.version, serving)
+	return nil
+}
+
+func countPoints(ctx context.Context, points qdrant.PointsClient, collection string) (int64, error) {
+	resp, err := points.Count(ctx, &qdrant.CountPoints{CollectionName: collection, Exact: qdrant.PtrOf(true)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", collection, err)
+	}
+	return int64(resp.GetResult().GetCount()), nil
+}
+
+// switchAliases points every alias at its new collection in one request and
+// returns the collections they pointed to before, which are kept for a
+// rollback. A collection still holding an alias's name, as before the first
+// blue/green run, has to be deleted before the alias can be created; that
+// takes replace, and searches fail until the alias exists a moment later.
+func (b blueGreen) switchAliases(ctx context.Context, collections qdrant.CollectionsClient, replace bool) ([]string, error) {
+	aliases, err := collections.ListAliases(ctx, &qdrant.ListAliasesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	current := make(map[string]string)
+	for _, alias := range aliases.GetAliases() {
+		current[alias.GetAliasName()] = alias.GetCollectionName()
+	}
+
+	var previous []string
+	var actions []*qdrant.AliasOperations
+	for _, tier := range b.tiers() {
+		alias, collection := tier[0], tier[1]
+		if old, ok := current[alias]; ok {
+			previous = append(previous, old)
+			actions = append(actions, &qdrant.AliasOperations{Action: &qdrant.AliasOperations_DeleteAlias{
+				DeleteAlias: &qdrant.DeleteAlias{AliasName: alias},
+			}})
+		} else if exists, err := collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: alias}); err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", alias, err)
+		} else if exists.GetResult().GetExists() {
+			if !replace {
+				return nil, fmt.Errorf("%s is a collection, not an alias; run with -reindex-replace to delete it and alias %s in its place", alias, collection)
+			}
+			log.Printf("🗑️  Deleting collection %s to alias %s in its place", alias, collection)
+			if _, err := collections.Delete(ctx, &qdrant.DeleteCollection{CollectionName: alias}); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", alias, err)
+			}
+		}
+		actions = append(actions, &qdrant.AliasOperations{Action: &qdrant.AliasOperations_CreateAlias{
+			CreateAlias: &qdrant.CreateAlias{CollectionName: collection, AliasName: alias},
+		}})
+	}
+	if _, err := collections.UpdateAliases(ctx, &qdrant.ChangeAliases{Actions: actions}); err != nil {
+		return nil, fmt.Errorf("failed to switch aliases: %w", err)
+	}
+	return previous, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long a file must go unmodified before it is indexed,
+// so files still being written by a collector are not read half-finished
+const watchSettle = 5 * time.Second
+
+// watchDirectory calls index with each JSONL file (plain or compressed) in
+// dir matching one of patterns that is created or modified, once it has settled. It returns when ctx is done or
+// the watcher fails. Calls to index never overlap.
+func watchDirectory(ctx context.Context, dir string, patterns []string, index func(path string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+	log.Printf("👀 Watching %s for new or modified %s data files", dir, strings.Join(patterns, ", "))
+
+	pending := make(map[string]time.Time) // path -> last change
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			if matchesAny(patterns, filepath.Base(event.Name)) && data.IsJSONLFile(event.Name) {
+				pending[event.Name] = time.Now()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("⚠️  File watcher error: %v", err)
+		case now := <-ticker.C:
+			for path, changed := range pending {
+				if now.Sub(changed) < watchSettle {
+					continue
+				}
+				delete(pending, path)
+				if fileExists(path) {
+					index(path)
+				}
+			}
+		}
+	}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
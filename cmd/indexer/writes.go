@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"MedAtlasAIServer/internal/config"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+var writeOrderings = map[string]qdrant.WriteOrderingType{
+	config.OrderingWeak:   qdrant.WriteOrderingType_Weak,
+	config.OrderingMedium: qdrant.WriteOrderingType_Medium,
+	config.OrderingStrong: qdrant.WriteOrderingType_Strong,
+}
+
+// upsertRequest builds an upsert of points with the configured write options
+func upsertRequest(collection string, points []*qdrant.PointStruct, writes config.QdrantWrites) *qdrant.UpsertPoints {
+	wait := writes.Wait
+	req := &qdrant.UpsertPoints{
+		CollectionName: collection,
+		Points:         points,
+		Wait:           &wait,
+		Ordering:       &qdrant.WriteOrdering{Type: writeOrderings[writes.Ordering]},
+	}
+	if writes.ShardKey != "" {
+		req.ShardKeySelector = &qdrant.ShardKeySelector{ShardKeys: []*qdrant.ShardKey{qdrant.NewShardKey(writes.ShardKey)}}
+	}
+	return req
+}
+
+// configuredWrites applies the write options to upserts made by code that
+// does not know about them, such as tier migration. A caller that asks to
+// wait, as migration does before deleting the originals, still waits.
+type configuredWrites struct {
+	qdrant.PointsClient
+	writes config.QdrantWrites
+}
+
+func (c configuredWrites) Upsert(ctx context.Context, in *qdrant.UpsertPoints, opts ...grpc.CallOption) (*qdrant.PointsOperationResponse, error) {
+	req := upsertRequest(in.GetCollectionName(), in.GetPoints(), c.writes)
+	if in.GetWait() {
+		req.Wait = in.Wait
+	}
+	return c.PointsClient.Upsert(ctx, req, opts...)
+}
+
+// ensureShardKey creates the shard key in a custom-sharded collection; an
+// existing key is left alone
+func ensureShardKey(ctx context.Context, client qdrant.CollectionsClient, collection, key string) {
+	_, err := client.CreateShardKey(ctx, &qdrant.CreateShardKeyRequest{
+		CollectionName: collection,
+		Request:        &qdrant.CreateShardKey{ShardKey: qdrant.NewShardKey(key)},
+	})
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		log.Fatalf("❌ Failed to create shard key %q in %s: %v", key, collection, err)
+	}
+}
@@ -0,0 +1,135 @@
+// Command medline loads MEDLINE/PubMed bulk files (the annual baseline and
+// daily updates, pubmed*.xml.gz from the NLM FTP/HTTPS distribution) and
+// writes the matching records as JSONL into data/raw, where the indexer
+// picks them up. E-utilities cannot realistically fetch a corpus of
+// millions of records; the bulk files can be loaded in hours.
+//
+//	go run ./cmd/medline -input 'data/baseline/pubmed25n*.xml.gz' -mesh Neoplasms,D003920
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/pkg/data"
+)
+
+func main() {
+	input := flag.String("input", "data/baseline/pubmed*.xml.gz", "glob of bulk files to load; .gz files are decompressed while reading")
+	terms := flag.String("terms", "", "comma-separated topic terms; records mentioning any of them in the title or abstract are kept")
+	mesh := flag.String("mesh", "", "comma-separated MeSH descriptor names or IDs; records indexed with any of them are kept")
+	outDir := flag.String("out-dir", "data/raw", "directory the JSONL files are written to")
+	compress := flag.String("compress", "gz", "compress output files: gz, zst or none")
+	flag.Parse()
+
+	extension := ".jsonl"
+	switch *compress {
+	case "none", "":
+	case "gz", "zst":
+		extension += "." + *compress
+	default:
+		log.Fatalf("❌ -compress must be gz, zst or none")
+	}
+	filter := data.MedlineFilter{Terms: splitList(*terms), Mesh: splitList(*mesh)}
+	if filter.Empty() {
+		log.Printf("⚠️  No -terms or -mesh filter: every record with an abstract will be loaded")
+	}
+
+	files, err := filepath.Glob(*input)
+	if err != nil {
+		log.Fatalf("❌ Invalid -input pattern: %v", err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("❌ No bulk files match %s", *input)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("❌ Failed to create %s: %v", *outDir, err)
+	}
+
+	client := data.NewPubMedClient()
+	var total data.MedlineStats
+	written := 0
+	for _, file := range files {
+		start := time.Now()
+		stats, fileWritten, err := loadFile(client, file, *outDir, extension, filter)
+		if err != nil {
+			log.Printf("❌ %s: %v", file, err)
+			continue
+		}
+		total.Records += stats.Records
+		total.Matched += stats.Matched
+		total.Deleted = append(total.Deleted, stats.Deleted...)
+		written += fileWritten
+		log.Printf("✅ %s: %d records, %d matched, %d written in %v", filepath.Base(file), stats.Records, stats.Matched, fileWritten, time.Since(start))
+	}
+
+	log.Printf("🎉 Loaded %d files: %d records, %d matched, %d written", len(files), total.Records, total.Matched, written)
+	if len(total.Deleted) > 0 {
+		log.Printf("🗑️  Update files delete %d PMIDs; remove them from the index (e.g. %v)", len(total.Deleted), total.Deleted[:min(5, len(total.Deleted))])
+	}
+}
+
+// loadFile converts one bulk file into one JSONL file named after it. The
+// output is written under a hidden name and renamed when complete, so an
+// indexer watching the directory never reads a partial file.
+func loadFile(client *data.PubMedClient, path, outDir, extension string, filter data.MedlineFilter) (data.MedlineStats, int, error) {
+	in, err := data.OpenInput(path)
+	if err != nil {
+		return data.MedlineStats{}, 0, err
+	}
+	defer in.Close()
+
+	base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), data.ExtGzip), ".xml")
+	final := filepath.Join(outDir, fmt.Sprintf("pubmed_medline_%s%s", base, extension))
+	partial := filepath.Join(outDir, "."+filepath.Base(final))
+	os.Remove(partial)
+	out, err := data.OpenOutput(partial)
+	if err != nil {
+		return data.MedlineStats{}, 0, err
+	}
+
+	written := 0
+	stats, err := data.StreamMedline(in, filter, func(record models.PubMedArticle) error {
+		article := client.NormalizeArticle(record)
+		if !data.ValidateArticle(article) {
+			return nil
+		}
+		line, err := json.Marshal(article)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return stats, 0, err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		return stats, 0, err
+	}
+	return stats, written, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
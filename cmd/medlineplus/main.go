@@ -0,0 +1,209 @@
+// Command medlineplus collects MedlinePlus health topics, the NLM's
+// plain-language pages for patients, and indexes them into the
+// consumer_health collection that patient chat answers prefer over research
+// abstracts. The topics are also written as JSONL so the corpus can be
+// reindexed without fetching it again.
+//
+//	go run ./cmd/medlineplus -topics "diabetes,asthma,high blood pressure"
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// defaultTopics are common patient questions, matching the collector's
+// default research topics where they overlap
+const defaultTopics = "diabetes,high blood pressure,heart disease,stroke,asthma,COPD,cancer,breast cancer," +
+	"depression,anxiety,arthritis,back pain,migraine,obesity,high cholesterol,kidney disease,Alzheimer's disease," +
+	"influenza,COVID-19,pregnancy,vaccines,allergy,osteoporosis,thyroid diseases,sleep disorders"
+
+func main() {
+	topicList := flag.String("topics", defaultTopics, "comma-separated search terms; the best matching health topics for each are collected")
+	perTopic := flag.Int("per-topic", 5, "health topics kept per search term")
+	out := flag.String("out", "data/raw/medlineplus_topics.jsonl", "JSONL file the collected topics are written to")
+	input := flag.String("input", "", "index the topics in this JSONL file instead of fetching them")
+	index := flag.Bool("index", true, "embed the topics and upsert them into the consumer_health collection")
+	flag.Parse()
+
+	var topics []models.HealthTopic
+	var err error
+	if *input != "" {
+		topics, err = readTopics(*input)
+		if err != nil {
+			log.Fatalf("❌ Could not read %s: %v", *input, err)
+		}
+	} else {
+		topics = collect(data.NewMedlinePlusClient(), splitList(*topicList), *perTopic)
+		if err := writeTopics(*out, topics); err != nil {
+			log.Fatalf("❌ Could not write %s: %v", *out, err)
+		}
+		log.Printf("💾 Wrote %d health topics to %s", len(topics), *out)
+	}
+
+	if !*index || len(topics) == 0 {
+		return
+	}
+	if err := indexTopics(context.Background(), topics); err != nil {
+		log.Fatalf("❌ Indexing failed: %v", err)
+	}
+}
+
+// collect searches every term and returns the distinct topics found, in
+// search order
+func collect(client *data.MedlinePlusClient, terms []string, perTopic int) []models.HealthTopic {
+	seen := make(map[string]bool)
+	var topics []models.HealthTopic
+	for i, term := range terms {
+		if i > 0 {
+			time.Sleep(client.Delay)
+		}
+		found, err := client.SearchHealthTopics(term, perTopic)
+		if err != nil {
+			log.Printf("❌ %s: %v", term, err)
+			continue
+		}
+		added := 0
+		for _, topic := range found {
+			if !seen[topic.ID] {
+				seen[topic.ID] = true
+				topics = append(topics, topic)
+				added++
+			}
+		}
+		log.Printf("✅ %s: %d topics, %d new", term, len(found), added)
+	}
+	return topics
+}
+
+func writeTopics(path string, topics []models.HealthTopic) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, topic := range topics {
+		if err := encoder.Encode(topic); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+func readTopics(path string) ([]models.HealthTopic, error) {
+	file, err := data.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var topics []models.HealthTopic
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var topic models.HealthTopic
+		if err := decoder.Decode(&topic); err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// indexTopics embeds each topic's title, other names and summary and
+// upserts it into ai.ConsumerHealthCollection, creating the collection
+// when needed
+func indexTopics(ctx context.Context, topics []models.HealthTopic) error {
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
+	qdrantConn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("could not connect to Qdrant: %w", err)
+	}
+	defer qdrantConn.Close()
+	collections := qdrant.NewCollectionsClient(qdrantConn)
+	points := qdrant.NewPointsClient(qdrantConn)
+
+	var batch []*qdrant.PointStruct
+	for _, topic := range topics {
+		text := topic.Title + ". " + strings.Join(topic.AltTitles, ". ") + ". " + topic.Summary
+		vector, err := embedder.GetEmbedding(text)
+		if err != nil {
+			log.Printf("❌ Embedding %s failed: %v", topic.Title, err)
+			continue
+		}
+		if len(batch) == 0 {
+			if err := ensureCollection(ctx, collections, len(vector)); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, &qdrant.PointStruct{
+			Id:      &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(topic.ID)}},
+			Vectors: &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: vector}}},
+			Payload: payload.Encode(topic),
+		})
+	}
+	if len(batch) == 0 {
+		return fmt.Errorf("no topics could be embedded")
+	}
+
+	if _, err := points.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: ai.ConsumerHealthCollection,
+		Points:         batch,
+	}); err != nil {
+		return err
+	}
+	log.Printf("🎉 Indexed %d health topics into %s", len(batch), ai.ConsumerHealthCollection)
+	return nil
+}
+
+func ensureCollection(ctx context.Context, client qdrant.CollectionsClient, vectorSize int) error {
+	exists, err := client.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: ai.ConsumerHealthCollection})
+	if err != nil {
+		return err
+	}
+	if exists.GetResult().GetExists() {
+		return nil
+	}
+	log.Printf("🆕 Creating collection %s with vector size %d", ai.ConsumerHealthCollection, vectorSize)
+	_, err = client.Create(ctx, &qdrant.CreateCollection{
+		CollectionName: ai.ConsumerHealthCollection,
+		VectorsConfig: &qdrant.VectorsConfig{Config: &qdrant.VectorsConfig_Params{
+			Params: &qdrant.VectorParams{
+				Size:     uint64(vectorSize),
+				Distance: qdrant.Distance_Cosine,
+			},
+		}},
+	})
+	return err
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,186 @@
+// Command multivector builds the experimental multivector_abstracts
+// collection for a subset of the indexed corpus: each selected article is
+// re-embedded sentence by sentence and stored as a multivector, so the API
+// can search it with {"mode": "maxsim"}. Articles are selected by MeSH
+// heading and source; rerunning the command refreshes them.
+//
+//	go run ./cmd/multivector -mesh "Heart Failure,Sepsis" -limit 2000
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/contentstore"
+	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/multivector"
+	"MedAtlasAIServer/internal/tiering"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// scrollPageSize is the number of articles read and upserted at a time
+const scrollPageSize = 64
+
+func main() {
+	mesh := flag.String("mesh", "", "comma-separated MeSH headings; articles with any of them are selected")
+	source := flag.String("source", "", "only select articles from this source, e.g. pubmed or europepmc")
+	limit := flag.Int("limit", 1000, "most articles to build multivectors for")
+	embedderHost := flag.String("embedder", config.EmbeddingServiceHost(), "embedding service URL")
+	qdrantHost := flag.String("qdrant", config.QdrantHost(), "Qdrant gRPC address")
+	collection := config.CollectionFlag()
+	flag.Parse()
+	if _, err := config.LoadCollections(*collection); err != nil {
+		log.Fatalf("❌ Invalid collection settings: %v", err)
+	}
+
+	if *limit < 1 {
+		log.Fatalf("❌ -limit must be at least 1")
+	}
+	conn, err := grpc.Dial(*qdrantHost, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("❌ Could not connect to Qdrant: %v", err)
+	}
+	defer conn.Close()
+
+	b := &builder{
+		embedder:    embeddingClient.NewClient(*embedderHost),
+		content:     contentstore.FromEnv(),
+		collections: qdrant.NewCollectionsClient(conn),
+		points:      qdrant.NewPointsClient(conn),
+		filter:      selection(splitList(*mesh), *source),
+		remaining:   *limit,
+	}
+	ctx := context.Background()
+	for _, collection := range []string{tiering.RecentCollection(), tiering.HistoricalCollection()} {
+		if err := b.build(ctx, collection); err != nil {
+			log.Fatalf("❌ %s: %v", collection, err)
+		}
+	}
+	log.Printf("🎉 Built multivectors for %d articles in %s (%d skipped)", b.built, multivector.Collection, b.skipped)
+}
+
+// selection filters the articles to those with any of meshHeadings and
+// from source, each when set
+func selection(meshHeadings []string, source string) *qdrant.Filter {
+	var must []*qdrant.Condition
+	if len(meshHeadings) > 0 {
+		must = append(must, qdrant.NewMatchKeywords("mesh_headings", meshHeadings...))
+	}
+	if source != "" {
+		must = append(must, qdrant.NewMatchKeyword("source", source))
+	}
+	if len(must) == 0 {
+		return nil
+	}
+	return &qdrant.Filter{Must: must}
+}
+
+type builder struct {
+	embedder    *embeddingClient.Client
+	content     *contentstore.Store // full abstracts of points indexed with -payload-refs
+	collections qdrant.CollectionsClient
+	points      qdrant.PointsClient
+	filter      *qdrant.Filter
+	remaining   int
+	created     bool
+	built       int
+	skipped     int
+}
+
+// build scrolls the selected articles of collection and upserts their
+// multivectors until the limit is reached
+func (b *builder) build(ctx context.Context, collection string) error {
+	exists, err := b.collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: collection})
+	if err != nil {
+		return err
+	}
+	if !exists.GetResult().GetExists() {
+		return nil
+	}
+
+	var offset *qdrant.PointId
+	for b.remaining > 0 {
+		pageSize := uint32(min(b.remaining, scrollPageSize))
+		page, err := b.points.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: collection,
+			Filter:         b.filter,
+			Offset:         offset,
+			Limit:          &pageSize,
+			WithPayload:    qdrant.NewWithPayload(true),
+		})
+		if err != nil {
+			return err
+		}
+		if err := b.upsert(ctx, page.GetResult()); err != nil {
+			return err
+		}
+		offset = page.GetNextPageOffset()
+		if offset == nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (b *builder) upsert(ctx context.Context, articles []*qdrant.RetrievedPoint) error {
+	b.remaining -= len(articles)
+	var batch []*qdrant.PointStruct
+	for _, article := range articles {
+		title := article.Payload["title"].GetStringValue()
+		vectors, err := multivector.DocumentVectors(b.embedder, title, b.content.Text(article.Payload, "abstract"))
+		if err != nil {
+			log.Printf("⚠️  Skipping %s: %v", formatID(article.Id), err)
+			b.skipped++
+			continue
+		}
+		if !b.created {
+			if err := multivector.EnsureCollection(ctx, b.collections, len(vectors[0])); err != nil {
+				return err
+			}
+			b.created = true
+		}
+		batch = append(batch, &qdrant.PointStruct{
+			Id:      article.Id,
+			Vectors: qdrant.NewVectorsMulti(vectors),
+			Payload: article.Payload,
+		})
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	wait := true
+	if _, err := b.points.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: multivector.Collection,
+		Points:         batch,
+		Wait:           &wait,
+	}); err != nil {
+		return err
+	}
+	b.built += len(batch)
+	log.Printf("✅ %d articles built", b.built)
+	return nil
+}
+
+func formatID(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return uuid
+	}
+	return fmt.Sprint(id.GetNum())
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,375 @@
+// Command replay sends recorded search queries or chat messages to a target
+// environment at a fixed rate and reports how the results and latency
+// compare, either with a baseline environment or with what was recorded.
+// Use it to validate index migrations and config changes before rollout:
+//
+//	go run ./cmd/replay -log data/logs/query_log.jsonl \
+//	    -baseline http://search-prod:8080 -target http://search-canary:8080 -rate 5
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/encryption"
+	"MedAtlasAIServer/internal/recordlog"
+)
+
+// Kinds of recorded log
+const (
+	kindQuery = "query" // API query log: POST /search
+	kindChat  = "chat"  // chat transcripts: POST /api/chat
+)
+
+// recorded is one replayable request taken from a log record
+type recorded struct {
+	Text    string `json:"text"`
+	Limit   int    `json:"limit,omitempty"`
+	Results int    `json:"recorded_results"` // result count when it was recorded; query logs only
+}
+
+// outcome is what one environment returned for a request
+type outcome struct {
+	IDs     []string      `json:"ids"`
+	Latency time.Duration `json:"latency_ns"`
+	Err     string        `json:"error,omitempty"`
+}
+
+// comparison is the report line for one replayed request
+type comparison struct {
+	Request  recorded `json:"request"`
+	Target   outcome  `json:"target"`
+	Baseline *outcome `json:"baseline,omitempty"`
+	Overlap  *float64 `json:"overlap,omitempty"` // Jaccard overlap of result IDs with the baseline
+}
+
+// latencySummary describes the latency of one environment
+type latencySummary struct {
+	P50    time.Duration `json:"p50_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	Max    time.Duration `json:"max_ns"`
+	Errors int           `json:"errors"`
+}
+
+// summary is the aggregate written at the end of the report
+type summary struct {
+	Kind            string          `json:"kind"`
+	Requests        int             `json:"requests"`
+	Target          latencySummary  `json:"target"`
+	Baseline        *latencySummary `json:"baseline,omitempty"`
+	MeanOverlap     *float64        `json:"mean_overlap,omitempty"`
+	LowOverlap      int             `json:"low_overlap"`      // requests below -min-overlap
+	CountMismatches int             `json:"count_mismatches"` // query logs without a baseline: result count differs from the recording
+}
+
+func main() {
+	logPath := flag.String("log", recordlog.DefaultQueryLogPath, "recorded query log or chat transcript log to replay")
+	kind := flag.String("kind", "", "query or chat; detected from the records when empty")
+	target := flag.String("target", "http://localhost:8080", "base URL of the environment under test")
+	baseline := flag.String("baseline", "", "base URL of the environment to compare against; without it, query results are compared with the recorded counts")
+	rate := flag.Float64("rate", 2, "requests per second sent to each environment")
+	concurrency := flag.Int("concurrency", 4, "maximum requests in flight")
+	maxRecords := flag.Int("max", 0, "replay at most this many records, newest last; 0 replays them all")
+	since := flag.Duration("since", 0, "only replay records newer than this, e.g. 24h; 0 replays them all")
+	minOverlap := flag.Float64("min-overlap", 0.5, "report requests whose result overlap with the baseline is below this")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-request timeout")
+	reportPath := flag.String("report", "data/reports/replay_report.jsonl", "JSONL file with one comparison per request and the summary last")
+	flag.Parse()
+
+	if *rate <= 0 || *concurrency <= 0 {
+		log.Fatal("❌ -rate and -concurrency must be positive")
+	}
+	keys, err := encryption.FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Invalid encryption keys: %v", err)
+	}
+	records, err := recordlog.Read(*logPath, keys)
+	if err != nil {
+		log.Fatalf("❌ Could not read %s: %v", *logPath, err)
+	}
+	if *kind == "" {
+		*kind = detectKind(records)
+	}
+	if *kind != kindQuery && *kind != kindChat {
+		log.Fatalf("❌ -kind must be %s or %s", kindQuery, kindChat)
+	}
+
+	requests := replayable(records, *kind, *since, *maxRecords)
+	log.Printf("🔁 Replaying %d %s records from %s against %s at %.1f/s", len(requests), *kind, *logPath, *target, *rate)
+
+	client := &http.Client{Timeout: *timeout}
+	results := make([]comparison, len(requests))
+	interval := time.Duration(float64(time.Second) / *rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	slots := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		if i > 0 {
+			<-ticker.C
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, request recorded) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = compare(client, *kind, request, *target, *baseline)
+		}(i, request)
+	}
+	wg.Wait()
+
+	report := summarize(*kind, results, *minOverlap)
+	if err := writeReport(*reportPath, results, report); err != nil {
+		log.Fatalf("❌ Could not write report: %v", err)
+	}
+	printSummary(report, *reportPath)
+}
+
+// detectKind tells query logs from chat transcripts by their fields
+func detectKind(records []recordlog.Record) string {
+	for _, record := range records {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(record.Data, &fields) != nil {
+			continue
+		}
+		if _, ok := fields["query"]; ok {
+			return kindQuery
+		}
+		if _, ok := fields["message"]; ok {
+			return kindChat
+		}
+	}
+	return ""
+}
+
+// replayable extracts the requests to send, skipping blocked chat messages
+// (they never reached the pipeline) and records older than since
+func replayable(records []recordlog.Record, kind string, since time.Duration, maxRecords int) []recorded {
+	var requests []recorded
+	for _, record := range records {
+		if since > 0 && time.Since(record.Time) > since {
+			continue
+		}
+		var fields struct {
+			Query   string `json:"query"`
+			Limit   int    `json:"limit"`
+			Results int    `json:"results"`
+			Message string `json:"message"`
+			Blocked bool   `json:"blocked"`
+		}
+		if err := json.Unmarshal(record.Data, &fields); err != nil {
+			continue
+		}
+		switch {
+		case kind == kindQuery && fields.Query != "":
+			requests = append(requests, recorded{Text: fields.Query, Limit: fields.Limit, Results: fields.Results})
+		case kind == kindChat && fields.Message != "" && !fields.Blocked:
+			requests = append(requests, recorded{Text: fields.Message})
+		}
+	}
+	if maxRecords > 0 && len(requests) > maxRecords {
+		requests = requests[len(requests)-maxRecords:]
+	}
+	return requests
+}
+
+// compare sends request to target and, when set, to baseline concurrently
+func compare(client *http.Client, kind string, request recorded, target, baseline string) comparison {
+	result := comparison{Request: request}
+	var wg sync.WaitGroup
+	if baseline != "" {
+		result.Baseline = &outcome{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			*result.Baseline = send(client, kind, baseline, request)
+		}()
+	}
+	result.Target = send(client, kind, target, request)
+	wg.Wait()
+
+	if result.Baseline != nil && result.Target.Err == "" && result.Baseline.Err == "" {
+		overlap := jaccard(result.Target.IDs, result.Baseline.IDs)
+		result.Overlap = &overlap
+	}
+	return result
+}
+
+// send replays one request and returns the IDs of the results or sources
+func send(client *http.Client, kind, baseURL string, request recorded) outcome {
+	var path string
+	var body any
+	if kind == kindQuery {
+		path, body = "/search", map[string]any{"query": request.Text, "limit": request.Limit}
+	} else {
+		path, body = "/api/chat", map[string]any{"message": request.Text}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return outcome{Err: err.Error()}
+	}
+	req, err := http.NewRequestWithContext(context.Background(), "POST", baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return outcome{Err: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return outcome{Latency: time.Since(start), Err: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	var ids []string
+	if kind == kindQuery {
+		var results []struct {
+			ID string `json:"id"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&results)
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+	} else {
+		var chat struct {
+			Sources []struct {
+				ID string `json:"id"`
+			} `json:"sources"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&chat)
+		for _, source := range chat.Sources {
+			ids = append(ids, source.ID)
+		}
+	}
+	result := outcome{IDs: ids, Latency: time.Since(start)}
+	if resp.StatusCode != http.StatusOK {
+		result.Err = resp.Status
+	} else if err != nil {
+		result.Err = fmt.Sprintf("invalid response: %v", err)
+	}
+	return result
+}
+
+// jaccard is the overlap of two ID lists; two empty lists agree fully
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	set := make(map[string]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+	shared := 0
+	union := len(set)
+	seen := make(map[string]bool, len(b))
+	for _, id := range b {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if set[id] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+func summarize(kind string, results []comparison, minOverlap float64) summary {
+	report := summary{Kind: kind, Requests: len(results)}
+	var target, baseline []outcome
+	var overlapSum float64
+	overlaps := 0
+	for _, result := range results {
+		target = append(target, result.Target)
+		if result.Baseline != nil {
+			baseline = append(baseline, *result.Baseline)
+		}
+		if result.Overlap != nil {
+			overlapSum += *result.Overlap
+			overlaps++
+			if *result.Overlap < minOverlap {
+				report.LowOverlap++
+			}
+		}
+		if result.Baseline == nil && kind == kindQuery && result.Target.Err == "" && len(result.Target.IDs) != result.Request.Results {
+			report.CountMismatches++
+		}
+	}
+	report.Target = latencies(target)
+	if len(baseline) > 0 {
+		summary := latencies(baseline)
+		report.Baseline = &summary
+	}
+	if overlaps > 0 {
+		mean := overlapSum / float64(overlaps)
+		report.MeanOverlap = &mean
+	}
+	return report
+}
+
+func latencies(outcomes []outcome) latencySummary {
+	var summary latencySummary
+	var durations []time.Duration
+	for _, o := range outcomes {
+		if o.Err != "" {
+			summary.Errors++
+			continue
+		}
+		durations = append(durations, o.Latency)
+	}
+	if len(durations) == 0 {
+		return summary
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	percentile := func(p float64) time.Duration { return durations[int(p*float64(len(durations)-1))] }
+	summary.P50, summary.P95, summary.Max = percentile(0.50), percentile(0.95), durations[len(durations)-1]
+	return summary
+}
+
+func writeReport(path string, results []comparison, report summary) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := encoder.Encode(map[string]summary{"summary": report}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func printSummary(report summary, reportPath string) {
+	log.Printf("📊 Replayed %d %s requests", report.Requests, report.Kind)
+	log.Printf("   target:   p50 %v, p95 %v, max %v, %d errors", report.Target.P50, report.Target.P95, report.Target.Max, report.Target.Errors)
+	if report.Baseline != nil {
+		log.Printf("   baseline: p50 %v, p95 %v, max %v, %d errors", report.Baseline.P50, report.Baseline.P95, report.Baseline.Max, report.Baseline.Errors)
+	}
+	if report.MeanOverlap != nil {
+		log.Printf("   result overlap: mean %.2f, %d requests below threshold", *report.MeanOverlap, report.LowOverlap)
+	} else if report.Kind == kindQuery {
+		log.Printf("   %d queries returned a different number of results than recorded", report.CountMismatches)
+	}
+	log.Printf("📄 Report written to %s", reportPath)
+}
@@ -0,0 +1,143 @@
+// Command selftest measures the live index's recall on known
+// query→expected-PMID pairs and alerts (ALERT_WEBHOOK_URL) when it falls
+// below a minimum or drops since the last healthy run. Run it after
+// indexing runs and tier migrations; it exits non-zero on failure so it can
+// gate a deployment.
+//
+//	go run ./cmd/selftest -k 10 -min-recall 0.8
+//
+// Without a cases file, -bootstrap N writes one from N indexed articles,
+// each searched by its own title, which catches broken embeddings, payloads
+// and tiers even before curated cases exist.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"MedAtlasAIServer/internal/alert"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/jsonfile"
+	"MedAtlasAIServer/internal/selftest"
+	"MedAtlasAIServer/internal/tiering"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	casesPath := flag.String("cases", selftest.DefaultCasesPath, "JSON file of {\"query\", \"expected\": [PMIDs]} cases")
+	reportPath := flag.String("report", selftest.DefaultReportPath, "report of the last healthy run, compared against and then replaced")
+	k := flag.Int("k", 10, "results per query that count towards recall")
+	minRecall := flag.Float64("min-recall", 0.8, "alert when mean recall@k is below this")
+	maxDrop := flag.Float64("max-drop", 0.05, "alert when mean recall@k drops by more than this since the last healthy run")
+	bootstrap := flag.Int("bootstrap", 0, "write a cases file from this many indexed articles searched by title, then exit")
+	collection := config.CollectionFlag()
+	flag.Parse()
+	if _, err := config.LoadCollections(*collection); err != nil {
+		log.Fatalf("❌ Invalid collection settings: %v", err)
+	}
+
+	embedder := embeddingClient.NewClient(config.EmbeddingServiceHost())
+	conn, err := grpc.Dial(config.QdrantHost(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("❌ Could not connect to Qdrant: %v", err)
+	}
+	defer conn.Close()
+	points := tiering.NewClient(qdrant.NewPointsClient(conn))
+	ctx := context.Background()
+
+	if *bootstrap > 0 {
+		cases, err := bootstrapCases(ctx, points, *bootstrap)
+		if err != nil {
+			log.Fatalf("❌ Bootstrap failed: %v", err)
+		}
+		if err := jsonfile.WriteAtomic(*casesPath, cases); err != nil {
+			log.Fatalf("❌ Could not write %s: %v", *casesPath, err)
+		}
+		log.Printf("📝 Wrote %d cases to %s", len(cases), *casesPath)
+		return
+	}
+
+	cases, err := selftest.LoadCases(*casesPath)
+	if err != nil {
+		log.Fatalf("❌ %v (create cases with -bootstrap)", err)
+	}
+
+	search := func(ctx context.Context, query string, k int) ([]string, error) {
+		vector, err := embedder.GetEmbedding(query)
+		if err != nil {
+			return nil, err
+		}
+		result, err := points.Search(tiering.WithHistorical(ctx), &qdrant.SearchPoints{
+			CollectionName: tiering.HistoricalCollection(),
+			Vector:         vector,
+			Limit:          uint64(k),
+			WithPayload: &qdrant.WithPayloadSelector{
+				SelectorOptions: &qdrant.WithPayloadSelector_Include{
+					Include: &qdrant.PayloadIncludeSelector{Fields: []string{"id"}},
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(result.GetResult()))
+		for _, point := range result.GetResult() {
+			ids = append(ids, point.GetPayload()["id"].GetStringValue())
+		}
+		return ids, nil
+	}
+
+	start := time.Now()
+	report := selftest.Run(ctx, cases, search, *k)
+	for _, c := range report.Cases {
+		if c.Error != "" {
+			log.Printf("❌ %q: %s", c.Query, c.Error)
+		} else if len(c.Missing) > 0 {
+			log.Printf("⚠️  %q: recall %.2f, missing %v", c.Query, c.Recall, c.Missing)
+		}
+	}
+	log.Printf("📊 recall@%d over %d cases: %.3f (%d failed) in %v", report.K, len(report.Cases), report.Recall, report.Failed, time.Since(start))
+
+	problem, err := selftest.Check(ctx, report, *reportPath, selftest.Thresholds{MinRecall: *minRecall, MaxDrop: *maxDrop}, alert.FromEnv())
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	if problem != "" {
+		os.Exit(1)
+	}
+	log.Printf("✅ Recall self-test passed")
+}
+
+// bootstrapCases turns n indexed articles into known-item cases: searching
+// an article's title must find the article
+func bootstrapCases(ctx context.Context, points *tiering.Client, n int) ([]selftest.Case, error) {
+	limit := uint32(n)
+	result, err := points.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: tiering.HistoricalCollection(),
+		Limit:          &limit,
+		WithPayload: &qdrant.WithPayloadSelector{
+			SelectorOptions: &qdrant.WithPayloadSelector_Include{
+				Include: &qdrant.PayloadIncludeSelector{Fields: []string{"id", "title"}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var cases []selftest.Case
+	for _, point := range result.GetResult() {
+		id := point.GetPayload()["id"].GetStringValue()
+		title := point.GetPayload()["title"].GetStringValue()
+		if id != "" && title != "" {
+			cases = append(cases, selftest.Case{Query: title, Expected: []string{id}})
+		}
+	}
+	return cases, nil
+}
@@ -1,12 +1,44 @@
-from fastapi import FastAPI, HTTPException
+from fastapi import Depends, FastAPI, HTTPException, Request
 from fastapi.middleware.cors import CORSMiddleware
 from pydantic import BaseModel
 import numpy as np
 import logging
 from typing import List
 import hashlib
+import hmac
+import os
+import time
 
-app = FastAPI(title="Embedding Service")
+# Credentials required of callers, set to the same values as the Go clients'
+# environment: a bearer token, an HMAC-SHA256 signing secret, or both
+AUTH_TOKEN = os.environ.get("EMBEDDING_AUTH_TOKEN", "").strip()
+HMAC_SECRET = os.environ.get("EMBEDDING_HMAC_SECRET", "").strip()
+MAX_SIGNATURE_AGE = 300  # seconds, as embeddingClient.MaxSignatureAge
+OPEN_PATHS = {"/health"}
+
+async def authenticate(request: Request):
+    """Check a request's credentials the way embeddingClient.Auth.Verify does"""
+    if request.url.path in OPEN_PATHS:
+        return
+    if AUTH_TOKEN:
+        given = request.headers.get("authorization", "").removeprefix("Bearer ")
+        if not hmac.compare_digest(given.encode(), AUTH_TOKEN.encode()):
+            raise HTTPException(status_code=401, detail="invalid bearer token")
+    if HMAC_SECRET:
+        timestamp = request.headers.get("x-signature-timestamp", "")
+        try:
+            age = abs(time.time() - int(timestamp))
+        except ValueError:
+            raise HTTPException(status_code=401, detail="missing signature timestamp")
+        if age > MAX_SIGNATURE_AGE:
+            raise HTTPException(status_code=401, detail="signature expired")
+        body_hash = hashlib.sha256(await request.body()).hexdigest()
+        message = f"{request.method}\n{request.url.path}\n{timestamp}\n{body_hash}"
+        expected = hmac.new(HMAC_SECRET.encode(), message.encode(), hashlib.sha256).hexdigest()
+        if not hmac.compare_digest(request.headers.get("x-signature", "").encode(), expected.encode()):
+            raise HTTPException(status_code=401, detail="invalid signature")
+
+app = FastAPI(title="Embedding Service", dependencies=[Depends(authenticate)])
 
 app.add_middleware(
     CORSMiddleware,
@@ -52,6 +84,25 @@ except Exception as e:
     print(f"✗ Error loading model: {e}")
     MODEL = None
 
+# The cross-encoder is optional: without it /rerank returns 503 and clients
+# keep the cosine ordering from the vector search
+RERANK_MODEL_NAME = "cross-encoder/ms-marco-MiniLM-L-6-v2"
+try:
+    from sentence_transformers import CrossEncoder
+    RERANKER = CrossEncoder(RERANK_MODEL_NAME)
+    print("✓ Loaded cross-encoder reranker")
+except Exception as e:
+    print(f"✗ Could not load cross-encoder reranker: {e}")
+    RERANKER = None
+
+class RerankRequest(BaseModel):
+    query: str
+    passages: List[str]
+
+class RerankResponse(BaseModel):
+    scores: List[float]
+    model: str
+
 @app.post("/embed", response_model=EmbedResponse)
 async def embed_text(request: EmbedRequest):
     try:
@@ -79,12 +130,50 @@ async def embed_text(request: EmbedRequest):
             dims=len(vector)
         )
 
+class EmbedBatchRequest(BaseModel):
+    texts: List[str]
+
+class EmbedBatchResponse(BaseModel):
+    vectors: List[List[float]]
+    model: str
+    dims: int
+
+@app.post("/embed/batch", response_model=EmbedBatchResponse)
+async def embed_batch(request: EmbedBatchRequest):
+    """Embed several texts in one forward pass; clients probe the batch size
+    that gives the best throughput on this hardware"""
+    if not request.texts:
+        return EmbedBatchResponse(vectors=[], model="none", dims=0)
+    try:
+        if MODEL is not None:
+            vectors = MODEL.encode(request.texts, batch_size=len(request.texts)).tolist()
+            model_name = "all-MiniLM-L6-v2"
+        else:
+            vectors = [universal_embedding(text) for text in request.texts]
+            model_name = "universal-hash-embedding"
+    except Exception as e:
+        print(f"Batch embedding error: {e}, using fallback")
+        vectors = [universal_embedding(text) for text in request.texts]
+        model_name = "fallback-universal"
+    return EmbedBatchResponse(vectors=vectors, model=model_name, dims=len(vectors[0]))
+
+@app.post("/rerank", response_model=RerankResponse)
+async def rerank(request: RerankRequest):
+    """Score each passage's relevance to the query with the cross-encoder"""
+    if RERANKER is None:
+        raise HTTPException(status_code=503, detail="reranker not loaded")
+    if not request.passages:
+        return RerankResponse(scores=[], model=RERANK_MODEL_NAME)
+    scores = RERANKER.predict([(request.query, passage) for passage in request.passages])
+    return RerankResponse(scores=[float(score) for score in scores], model=RERANK_MODEL_NAME)
+
 @app.get("/health")
 async def health():
     return {
         "status": "healthy", 
         "model_loaded": MODEL is not None,
-        "model_type": "sentence-transformers" if MODEL else "universal-fallback"
+        "model_type": "sentence-transformers" if MODEL else "universal-fallback",
+        "reranker_loaded": RERANKER is not None
     }
 
 @app.get("/test")
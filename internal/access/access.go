@@ -0,0 +1,194 @@
+// Package access labels indexed documents with who may read them: public
+// (open abstracts, the default), licensed (content under subscription, such
+// as licensed full text) or internal. Each request is entitled to some
+// labels by its API key, and a Client keeps every read from Qdrant to the
+// documents carrying one of them, so neither search results nor chat
+// prompts can contain documents the caller may not see.
+package access
+
+import (
+	"context"
+	"slices"
+
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Labels
+const (
+	LabelPublic   = "public"
+	LabelLicensed = "licensed"
+	LabelInternal = "internal"
+)
+
+// Labels lists every label
+var Labels = []string{LabelPublic, LabelLicensed, LabelInternal}
+
+// PayloadField holds a point's label. Points without one, such as those
+// indexed before labels existed, are public.
+const PayloadField = "access_label"
+
+// Valid reports whether label is a known label; "" stands for public
+func Valid(label string) bool {
+	return label == "" || slices.Contains(Labels, label)
+}
+
+type entitlementsKey struct{}
+
+// WithEntitlements limits the reads made with ctx to documents carrying one
+// of labels. Public documents are always readable.
+func WithEntitlements(ctx context.Context, labels []string) context.Context {
+	entitled := []string{LabelPublic}
+	for _, label := range labels {
+		if label != "" && !slices.Contains(entitled, label) {
+			entitled = append(entitled, label)
+		}
+	}
+	return context.WithValue(ctx, entitlementsKey{}, entitled)
+}
+
+// EntitlementsFromContext returns the labels ctx may read. ok is false for
+// contexts WithEntitlements did not set, such as the servers' own background
+// work, which is not restricted; Middleware sets them on every request.
+func EntitlementsFromContext(ctx context.Context) (labels []string, ok bool) {
+	labels, ok = ctx.Value(entitlementsKey{}).([]string)
+	return labels, ok
+}
+
+// restricted returns the labels ctx may read, or nil when ctx may read all
+func restricted(ctx context.Context) []string {
+	labels, ok := EntitlementsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for _, label := range Labels {
+		if !slices.Contains(labels, label) {
+			return labels
+		}
+	}
+	return nil
+}
+
+// Filter returns filter narrowed to the points carrying one of labels
+func Filter(filter *qdrant.Filter, labels []string) *qdrant.Filter {
+	if filter == nil {
+		filter = &qdrant.Filter{}
+	} else {
+		filter = proto.Clone(filter).(*qdrant.Filter)
+	}
+	allowed := []*qdrant.Condition{qdrant.NewMatchKeywords(PayloadField, labels...)}
+	if slices.Contains(labels, LabelPublic) {
+		allowed = append(allowed, qdrant.NewIsEmpty(PayloadField))
+	}
+	filter.Must = append(filter.Must, qdrant.NewFilterAsCondition(&qdrant.Filter{Should: allowed}))
+	return filter
+}
+
+// Allowed reports whether a point with payload may be read with labels
+func Allowed(payload map[string]*qdrant.Value, labels []string) bool {
+	label := payload[PayloadField].GetStringValue()
+	if label == "" {
+		label = LabelPublic
+	}
+	return slices.Contains(labels, label)
+}
+
+// Client adds the entitlements of the request to the filter of every
+// search, query, scroll and count, and drops the points it may not read from gets.
+// Requests entitled to every label pass through unchanged.
+type Client struct {
+	Points points.Reader
+}
+
+func NewClient(points points.Reader) *Client {
+	return &Client{Points: points}
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	if labels := restricted(ctx); labels != nil {
+		in = proto.Clone(in).(*qdrant.SearchPoints)
+		in.Filter = Filter(in.Filter, labels)
+	}
+	return c.Points.Search(ctx, in, opts...)
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	if labels := restricted(ctx); labels != nil {
+		in = proto.Clone(in).(*qdrant.ScrollPoints)
+		in.Filter = Filter(in.Filter, labels)
+	}
+	return c.Points.Scroll(ctx, in, opts...)
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	if labels := restricted(ctx); labels != nil {
+		in = proto.Clone(in).(*qdrant.CountPoints)
+		in.Filter = Filter(in.Filter, labels)
+	}
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	if labels := restricted(ctx); labels != nil {
+		in = proto.Clone(in).(*qdrant.QueryPoints)
+		in.Filter = Filter(in.Filter, labels)
+	}
+	return c.Points.Query(ctx, in, opts...)
+}
+
+// Get drops the points the request may not read, so articles fetched by ID
+// are held to the same rule as searched ones
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	labels := restricted(ctx)
+	if labels == nil {
+		return c.Points.Get(ctx, in, opts...)
+	}
+	selector, added := withLabel(in.GetWithPayload())
+	if added {
+		in = proto.Clone(in).(*qdrant.GetPoints)
+		in.WithPayload = selector
+	}
+	resp, err := c.Points.Get(ctx, in, opts...)
+	if err != nil {
+		return resp, err
+	}
+	kept := resp.Result[:0]
+	for _, point := range resp.Result {
+		if !Allowed(point.Payload, labels) {
+			continue
+		}
+		if added {
+			delete(point.Payload, PayloadField)
+		}
+		kept = append(kept, point)
+	}
+	resp.Result = kept
+	return resp, nil
+}
+
+// withLabel returns selector changed to return the label, and whether it
+// had to be changed, in which case the label is not the caller's to see
+func withLabel(selector *qdrant.WithPayloadSelector) (*qdrant.WithPayloadSelector, bool) {
+	switch {
+	case selector.GetInclude() != nil:
+		fields := selector.GetInclude().GetFields()
+		if slices.Contains(fields, PayloadField) {
+			return selector, false
+		}
+		return qdrant.NewWithPayloadInclude(append(slices.Clone(fields), PayloadField)...), true
+	case selector.GetExclude() != nil:
+		fields := selector.GetExclude().GetFields()
+		if !slices.Contains(fields, PayloadField) {
+			return selector, false
+		}
+		kept := slices.DeleteFunc(slices.Clone(fields), func(field string) bool { return field == PayloadField })
+		return qdrant.NewWithPayloadExclude(kept...), true
+	case selector.GetEnable():
+		return selector, false
+	default:
+		return qdrant.NewWithPayloadInclude(PayloadField), true
+	}
+}
@@ -0,0 +1,152 @@
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/identity"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultKeysPath is where API keys are kept when API_KEYS_FILE is unset
+const DefaultKeysPath = "data/api_keys.json"
+
+// KeyHeader carries the caller's API key
+const KeyHeader = "X-API-Key"
+
+// APIKey entitles its holder to labels beyond public. Only the key's
+// SHA-256 is stored, so the file does not give the keys away.
+type APIKey struct {
+	Name   string   `json:"name"`
+	SHA256 string   `json:"sha256"` // hex
+	Labels []string `json:"labels"`
+}
+
+// keysFile is the API_KEYS_FILE format
+type keysFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// Keys holds the API keys of a JSON file, which servers pick up changes to
+// with Watch. It is safe for concurrent use, and a nil *Keys knows no key.
+type Keys struct {
+	mu      sync.RWMutex
+	path    string
+	modTime time.Time
+	byHash  map[string]APIKey
+}
+
+// KeysPathFromEnv returns API_KEYS_FILE, or DefaultKeysPath
+func KeysPathFromEnv() string {
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		return path
+	}
+	return DefaultKeysPath
+}
+
+// OpenKeys loads the keys in path if it exists
+func OpenKeys(path string) (*Keys, error) {
+	k := &Keys{path: path, byHash: map[string]APIKey{}}
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// load replaces the keys with the file's. Callers must hold k.mu or own k.
+func (k *Keys) load() error {
+	info, statErr := os.Stat(k.path)
+	var file keysFile
+	if _, err := jsonfile.Read(k.path, &file); err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+	byHash := make(map[string]APIKey, len(file.Keys))
+	for i, key := range file.Keys {
+		key.SHA256 = strings.ToLower(strings.TrimSpace(key.SHA256))
+		if raw, err := hex.DecodeString(key.SHA256); err != nil || len(raw) != sha256.Size {
+			return fmt.Errorf("keys[%d]: %w", i, apperrors.Invalid("sha256", "must be the hex SHA-256 of the key"))
+		}
+		for _, label := range key.Labels {
+			if !Valid(label) {
+				return fmt.Errorf("keys[%d]: %w", i, apperrors.Invalid("labels", "must be %s", strings.Join(Labels, ", ")))
+			}
+		}
+		byHash[key.SHA256] = key
+	}
+	k.byHash = byHash
+	if statErr == nil {
+		k.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Lookup returns the key whose hash matches key
+func (k *Keys) Lookup(key string) (APIKey, bool) {
+	if k == nil {
+		return APIKey{}, false
+	}
+	sum := sha256.Sum256([]byte(key))
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	found, ok := k.byHash[hex.EncodeToString(sum[:])]
+	return found, ok
+}
+
+// Watch reloads the keys when the file changes, checking every interval,
+// so keys are issued and revoked without a restart. It returns when ctx is
+// cancelled.
+func (k *Keys) Watch(ctx context.Context, interval time.Duration) {
+	if k == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(k.path)
+			if err != nil {
+				continue
+			}
+			k.mu.Lock()
+			if !info.ModTime().Equal(k.modTime) {
+				if err := k.load(); err != nil {
+					log.Printf("⚠️  API key reload rejected: %v", err)
+				}
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+// Middleware sets the entitlements of every request: the labels of its API
+// key, or public only without one. An unknown key is refused rather than
+// served as public, so a mistyped or revoked key is noticed. Requests with
+// a known key are marked identity.Verified.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var labels []string
+		if header := strings.TrimSpace(r.Header.Get(KeyHeader)); header != "" {
+			key, ok := k.Lookup(header)
+			if !ok {
+				apperrors.Write(w, apperrors.ErrUnauthorized, "Unknown API key")
+				return
+			}
+			labels = key.Labels
+			ctx = identity.WithVerified(ctx)
+		}
+		next.ServeHTTP(w, r.WithContext(WithEntitlements(ctx, labels)))
+	})
+}
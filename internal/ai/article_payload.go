@@ -0,0 +1,203 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// articlePayload is the payload schema of article points
+type articlePayload struct {
+	ID            string          `payload:"id"`
+	Title         string          `payload:"title"`
+	Abstract      string          `payload:"abstract"`
+	Authors       string          `payload:"authors"` // display string, see data.FormatAuthors
+	AuthorList    []authorPayload `payload:"author_list,omitempty"`
+	PublishedDate time.Time       `payload:"published_date,date"`
+	DOI           string          `payload:"doi"`
+	Journal       string          `payload:"journal"`
+	JournalAbbr   string          `payload:"journal_abbr"`
+	Source        string          `payload:"source"`
+
+	// The publication date again as numbers, for range filters
+	PublishedTS   int64 `payload:"published_ts,omitempty"` // Unix seconds
+	PublishedYear int   `payload:"published_year,omitempty"`
+
+	MeshHeadings     []string `payload:"mesh_headings,omitempty"`
+	PublicationTypes []string `payload:"publication_types,omitempty"`
+	KeyConcepts      []string `payload:"key_concepts,omitempty"`
+	Countries        []string `payload:"countries,omitempty"`
+	Regions          []string `payload:"regions,omitempty"`
+	NCTIDs           []string `payload:"nct_ids,omitempty"`
+	Genes            []string `payload:"genes,omitempty"`
+	Variants         []string `payload:"variants,omitempty"`
+	Drugs            []string `payload:"drugs,omitempty"`
+	DrugBrands       []string `payload:"drug_brands,omitempty"`
+	Conditions       []string `payload:"conditions,omitempty"`
+
+	// Set for trial registrations
+	Phase       string `payload:"phase,omitempty"`
+	TrialStatus string `payload:"trial_status,omitempty"`
+	Enrollment  int    `payload:"enrollment,omitempty"`
+
+	// access.PayloadField; absent on public articles
+	Access string `payload:"access_label,omitempty"`
+}
+
+// authorPayload keeps structured author names for citation formatting
+type authorPayload struct {
+	LastName string `payload:"last_name"`
+	ForeName string `payload:"fore_name"`
+	Initials string `payload:"initials"`
+}
+
+// ArticleFromPayload rebuilds the stored article metadata from a Qdrant payload
+func ArticleFromPayload(stored map[string]*qdrant.Value) *models.MedicalArticle {
+	var p articlePayload
+	payload.Decode(stored, &p)
+	article := &models.MedicalArticle{
+		ID:               p.ID,
+		Title:            p.Title,
+		Abstract:         p.Abstract,
+		DOI:              p.DOI,
+		Journal:          p.Journal,
+		JournalAbbr:      p.JournalAbbr,
+		PublishedDate:    p.PublishedDate,
+		Source:           p.Source,
+		MeshHeadings:     p.MeshHeadings,
+		PublicationTypes: p.PublicationTypes,
+		KeyConcepts:      p.KeyConcepts,
+		Countries:        p.Countries,
+		Regions:          p.Regions,
+		NCTIDs:           p.NCTIDs,
+		Genes:            p.Genes,
+		Variants:         p.Variants,
+		Drugs:            p.Drugs,
+		DrugBrands:       p.DrugBrands,
+		Conditions:       p.Conditions,
+		Phase:            p.Phase,
+		TrialStatus:      p.TrialStatus,
+		Enrollment:       p.Enrollment,
+		Access:           p.Access,
+	}
+	for _, author := range p.AuthorList {
+		article.Authors = append(article.Authors, models.Author{
+			LastName: author.LastName,
+			ForeName: author.ForeName,
+			Initials: author.Initials,
+			FullName: strings.TrimSpace(author.ForeName + " " + author.LastName),
+		})
+	}
+	if len(p.AuthorList) == 0 {
+		// Points indexed before author_list existed only have the display string
+		article.Authors = parseAuthorString(p.Authors)
+	}
+	return article
+}
+
+// ArticlePayload builds the Qdrant payload the indexer stores for article;
+// ArticleFromPayload reads it back
+func ArticlePayload(article *models.MedicalArticle) map[string]*qdrant.Value {
+	p := articlePayload{
+		ID:               article.ID,
+		Title:            article.Title,
+		Abstract:         article.Abstract,
+		Authors:          data.FormatAuthors(article.Authors),
+		PublishedDate:    article.PublishedDate,
+		DOI:              article.DOI,
+		Journal:          article.Journal,
+		JournalAbbr:      article.JournalAbbr,
+		Source:           article.Source,
+		MeshHeadings:     article.MeshHeadings,
+		PublicationTypes: article.PublicationTypes,
+		KeyConcepts:      article.KeyConcepts,
+		Countries:        article.Countries,
+		Regions:          article.Regions,
+		NCTIDs:           article.NCTIDs,
+		Genes:            article.Genes,
+		Variants:         article.Variants,
+		Drugs:            article.Drugs,
+		DrugBrands:       article.DrugBrands,
+		Conditions:       article.Conditions,
+		Phase:            article.Phase,
+		TrialStatus:      article.TrialStatus,
+		Enrollment:       article.Enrollment,
+		Access:           article.Access,
+	}
+	if !article.PublishedDate.IsZero() {
+		p.PublishedTS, p.PublishedYear = article.PublishedDate.Unix(), article.PublishedDate.Year()
+	}
+	for _, author := range article.Authors {
+		p.AuthorList = append(p.AuthorList, authorPayload{LastName: author.LastName, ForeName: author.ForeName, Initials: author.Initials})
+	}
+	return payload.Encode(p)
+}
+
+// parseAuthorString splits the output of data.FormatAuthors back into names
+func parseAuthorString(authors string) []models.Author {
+	var result []models.Author
+	for _, name := range strings.Split(strings.ReplaceAll(authors, " and ", ", "), ", ") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "Unknown Author" {
+			continue
+		}
+		result = append(result, models.Author{FullName: name})
+	}
+	return result
+}
+
+// GetArticle loads one indexed article by its article ID
+func GetArticle(ctx context.Context, points PointGetter, articleID string) (*models.MedicalArticle, error) {
+	articles, err := GetArticles(ctx, points, []string{articleID})
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("%w: article %s is not indexed", apperrors.ErrNotFound, articleID)
+	}
+	return articles[0], nil
+}
+
+// GetArticles loads indexed articles by article ID, in the requested order.
+// IDs that are not indexed are skipped.
+func GetArticles(ctx context.Context, points PointGetter, articleIDs []string) ([]*models.MedicalArticle, error) {
+	pointIDs := make([]*qdrant.PointId, len(articleIDs))
+	for i, id := range articleIDs {
+		pointIDs[i] = &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(id)}}
+	}
+
+	resp, err := points.Get(ctx, &qdrant.GetPoints{
+		CollectionName: config.ArticleCollection(),
+		Ids:            pointIDs,
+		WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+
+	byPoint := make(map[uint64]*models.MedicalArticle, len(resp.GetResult()))
+	for _, point := range resp.GetResult() {
+		byPoint[point.GetId().GetNum()] = ArticleFromPayload(point.Payload)
+	}
+
+	articles := make([]*models.MedicalArticle, 0, len(byPoint))
+	for _, id := range articleIDs {
+		if article, ok := byPoint[data.PointID(id)]; ok {
+			if article.ID == "" {
+				article.ID = id
+			}
+			articles = append(articles, article)
+			delete(byPoint, data.PointID(id)) // repeated IDs are returned once
+		}
+	}
+	return articles, nil
+}
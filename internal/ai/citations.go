@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/budget"
+	"MedAtlasAIServer/internal/logging"
+)
+
+// citationMinScore is the cross-encoder score a passage needs to be cited
+// for a sentence; ms-marco scores are logits, so 0 is an even match
+const citationMinScore = 0
+
+// citationMinOverlap is the share of a sentence's words a passage must
+// contain to be cited when no cross-encoder is available
+const citationMinOverlap = 0.5
+
+// citationMarker matches the [n] markers the model sometimes adds itself
+var citationMarker = regexp.MustCompile(`\[\d+\]`)
+
+// citedSentence is a sentence of the answer and where its marker goes
+type citedSentence struct {
+	text string
+	end  int // byte offset the marker is inserted at, before the punctuation
+}
+
+// AlignCitations marks each claim in answer with the passage that best
+// supports it, as "[n]" where n is the passage's 1-based position (the
+// position of its study in ChatResponse.Sources). Passages are scored with
+// reranker when it is set and the budget has time for it, and by word
+// overlap otherwise. Sentences that no passage supports, or that the model
+// already cited, are left unmarked.
+func AlignCitations(ctx context.Context, reranker Reranker, answer string, passages []string) string {
+	if len(passages) == 0 {
+		return answer
+	}
+	sentences := claimSentences(answer)
+	if len(sentences) == 0 {
+		return answer
+	}
+
+	best := make([]int, len(sentences))
+	aligned := false
+	if reranker != nil {
+		alignCtx, cancel, ok := budget.FromContext(ctx).Context(ctx, budget.StageCitations)
+		if ok {
+			start := time.Now()
+			var err error
+			best, err = crossEncoderCitations(alignCtx, reranker, sentences, passages)
+			logging.Debugf("citation alignment of %d sentences took %v", len(sentences), time.Since(start))
+			if err != nil {
+				log.Printf("⚠️  Citation alignment failed, matching words instead: %v", err)
+			} else {
+				aligned = true
+			}
+		}
+		cancel()
+	}
+	if !aligned {
+		best = overlapCitations(sentences, passages)
+	}
+
+	var builder strings.Builder
+	last := 0
+	for i, sentence := range sentences {
+		if best[i] < 0 {
+			continue
+		}
+		builder.WriteString(answer[last:sentence.end])
+		builder.WriteString(" [" + strconv.Itoa(best[i]+1) + "]")
+		last = sentence.end
+	}
+	builder.WriteString(answer[last:])
+	return builder.String()
+}
+
+// claimSentences finds the sentences of answer worth citing: the claims
+// keyClaims would pick, outside tables and headings and not already cited
+func claimSentences(answer string) []citedSentence {
+	var sentences []citedSentence
+	start := 0
+	add := func(end int) {
+		raw := answer[start:end]
+		text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(raw), "-*•#0123456789) "))
+		trimmedEnd := start + len(strings.TrimRight(raw, " \t"))
+		if strings.Contains(raw, "|") || strings.HasPrefix(strings.TrimSpace(raw), "#") ||
+			citationMarker.MatchString(raw) || len(keyClaims(text)) == 0 {
+			return
+		}
+		sentences = append(sentences, citedSentence{text: text, end: trimmedEnd})
+	}
+	for _, loc := range sentenceEnd.FindAllStringIndex(answer, -1) {
+		add(loc[0])
+		start = loc[1]
+	}
+	if start < len(answer) {
+		add(len(answer))
+	}
+	return sentences
+}
+
+// crossEncoderCitations scores every passage against every sentence and
+// returns the best passage index per sentence, or -1 when none is relevant
+func crossEncoderCitations(ctx context.Context, reranker Reranker, sentences []citedSentence, passages []string) ([]int, error) {
+	best := make([]int, len(sentences))
+	errs := make([]error, len(sentences))
+	var wg sync.WaitGroup
+	for i, sentence := range sentences {
+		wg.Add(1)
+		go func(i int, sentence citedSentence) {
+			defer wg.Done()
+			scores, err := reranker.Rerank(ctx, sentence.text, passages)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			best[i] = -1
+			for j, score := range scores {
+				if score > citationMinScore && (best[i] < 0 || score > scores[best[i]]) {
+					best[i] = j
+				}
+			}
+		}(i, sentence)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return best, nil
+}
+
+// overlapCitations picks, per sentence, the passage containing most of its
+// words, or -1 when none contains enough of them
+func overlapCitations(sentences []citedSentence, passages []string) []int {
+	passageWords := make([]map[string]bool, len(passages))
+	for i, passage := range passages {
+		passageWords[i] = wordSet(passage)
+	}
+	best := make([]int, len(sentences))
+	for i, sentence := range sentences {
+		best[i] = -1
+		words := wordSet(sentence.text)
+		if len(words) == 0 {
+			continue
+		}
+		bestShare := 0.0
+		for j, inPassage := range passageWords {
+			found := 0
+			for word := range words {
+				if inPassage[word] {
+					found++
+				}
+			}
+			share := float64(found) / float64(len(words))
+			if share >= citationMinOverlap && share > bestShare {
+				best[i], bestShare = j, share
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ComparisonSides names the two entities of an "A vs B" question
+type ComparisonSides struct {
+	A string
+	B string
+}
+
+var comparisonPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)difference(?:s)? between (.+?) and (.+)`),
+	regexp.MustCompile(`(?i)compare (.+?) (?:and|with|to|versus|vs\.?) (.+)`),
+	regexp.MustCompile(`(?i)(.+?)\s+(?:vs\.?|versus|compared (?:to|with))\s+(.+)`),
+	regexp.MustCompile(`(?i)(?:is|are) (.+?) (?:better|worse|safer|more effective) than (.+)`),
+}
+
+var comparisonLeadIn = regexp.MustCompile(`(?i)^(what(?:'s| is| are)?|which is better[,:]?|how does|how do|tell me about|the)\s+`)
+
+// ExtractComparisonSides detects "A vs B" style questions and returns both entities
+func ExtractComparisonSides(message string) (ComparisonSides, bool) {
+	for _, pattern := range comparisonPatterns {
+		match := pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		a, b := cleanComparisonSide(match[1]), cleanComparisonSide(match[2])
+		if a != "" && b != "" && !strings.EqualFold(a, b) {
+			return ComparisonSides{A: a, B: b}, true
+		}
+	}
+	return ComparisonSides{}, false
+}
+
+func cleanComparisonSide(side string) string {
+	side = strings.TrimSpace(side)
+	for {
+		trimmed := comparisonLeadIn.ReplaceAllString(side, "")
+		if trimmed == side {
+			break
+		}
+		side = trimmed
+	}
+	return strings.Trim(side, " ?.!,;:\"'")
+}
+
+// SearchComparison retrieves evidence for each side separately and labels
+// every passage with the side it supports
+func (llm *LLMMedicalChat) SearchComparison(ctx context.Context, sides ComparisonSides, intent string) ([]string, error) {
+	labeled, _, err := llm.retrieveComparison(ctx, sides, intent)
+	return labeled, err
+}
+
+// retrieveComparison is SearchComparison that also returns the studies found
+func (llm *LLMMedicalChat) retrieveComparison(ctx context.Context, sides ComparisonSides, intent string) ([]string, []Source, error) {
+	var labeled []string
+	var sources []Source
+	for _, side := range []struct{ label, entity string }{{"A", sides.A}, {"B", sides.B}} {
+		results, sideSources, err := llm.retrieve(ctx, side.entity, intent)
+		if err != nil {
+			return nil, nil, fmt.Errorf("retrieval for %s failed: %w", side.entity, err)
+		}
+		for _, result := range results {
+			labeled = append(labeled, fmt.Sprintf("[Side %s: %s] %s", side.label, side.entity, result))
+		}
+		sources = append(sources, sideSources...)
+	}
+	return labeled, sources, nil
+}
@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/payload"
+)
+
+// Evidence levels of an answer
+const (
+	EvidenceHigh   = "high"
+	EvidenceMedium = "medium"
+	EvidenceLow    = "low"
+)
+
+// Confidence is how well the sources of an answer support it, so readers
+// can tell an answer drawn from one weak match from one drawn from several
+// strong, recent studies
+type Confidence struct {
+	Level string  `json:"level"` // high, medium or low evidence
+	Score float64 `json:"score"` // 0 to 1
+	// Sources is how many sources the answer drew on, Supporting how many
+	// of them are of at least moderate relevance, and Recent how many were
+	// published in the last RecentYears years
+	Sources    int `json:"sources"`
+	Supporting int `json:"supporting"`
+	Recent     int `json:"recent"`
+}
+
+// RecentYears is how old a study may be and still count as recent
+const RecentYears = 5
+
+// confidenceSources is how many of the strongest sources the score
+// averages over; an answer needs that many strong ones to score 1
+const confidenceSources = 3
+
+// Score thresholds of the evidence levels. High evidence also needs at
+// least two supporting sources.
+const (
+	highEvidenceScore   = 0.6
+	mediumEvidenceScore = 0.3
+)
+
+// relevanceWeights is what a source of each relevance label contributes;
+// sources on a scale without bands count as moderately relevant
+var relevanceWeights = map[string]float64{
+	calibration.LabelHigh:     1,
+	calibration.LabelModerate: 0.6,
+	calibration.LabelWeak:     0.2,
+	"":                        0.6,
+}
+
+// AssessConfidence scores the evidence sources give an answer at now. Each
+// source is weighted by its relevance label and discounted for age: studies
+// older than RecentYears count for 80%, older than twice that for 60%.
+// Sources without a publication date, such as health topics, count for
+// 80%. The score averages the strongest sources, so more weak matches do not
+// add up to a strong one.
+func AssessConfidence(sources []Source, now time.Time) *Confidence {
+	confidence := &Confidence{Level: EvidenceLow, Sources: len(sources)}
+	weights := make([]float64, 0, len(sources))
+	for _, source := range sources {
+		weight := relevanceWeights[source.Relevance]
+		if source.Relevance != calibration.LabelWeak {
+			confidence.Supporting++
+		}
+		published, err := time.Parse(payload.DateLayout, source.Published)
+		switch {
+		case err != nil:
+			weight *= 0.8
+		case published.After(now.AddDate(-RecentYears, 0, 0)):
+			confidence.Recent++
+		case published.After(now.AddDate(-2*RecentYears, 0, 0)):
+			weight *= 0.8
+		default:
+			weight *= 0.6
+		}
+		weights = append(weights, weight)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(weights)))
+	for _, weight := range weights[:min(len(weights), confidenceSources)] {
+		confidence.Score += weight / confidenceSources
+	}
+	confidence.Score = math.Round(confidence.Score*100) / 100
+
+	switch {
+	case confidence.Score >= highEvidenceScore && confidence.Supporting >= 2:
+		confidence.Level = EvidenceHigh
+	case confidence.Score >= mediumEvidenceScore:
+		confidence.Level = EvidenceMedium
+	}
+	return confidence
+}
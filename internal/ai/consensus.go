@@ -0,0 +1,214 @@
+package ai
+
+import (
+	"MedAtlasAIServer/pkg/data"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// consensusTemperatures are the sampling temperatures of a high-confidence
+// answer: one conservative, one default and one exploratory sample
+var consensusTemperatures = []float64{0.2, 0.7, 1.0}
+
+// sameClaim is the word overlap at which two sentences from different
+// samples are taken to make the same claim
+const sameClaim = 0.5
+
+type highConfidenceKey struct{}
+
+// WithHighConfidence requests the self-consistency answer mode for ctx
+func WithHighConfidence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, highConfidenceKey{}, true)
+}
+
+// HighConfidenceFromContext reports whether WithHighConfidence was applied
+func HighConfidenceFromContext(ctx context.Context) bool {
+	on, _ := ctx.Value(highConfidenceKey{}).(bool)
+	return on
+}
+
+// ConsensusGenerator answers by sampling the model several times and
+// keeping the answer the samples and the evidence agree on most
+// (implemented by LLMClient)
+type ConsensusGenerator interface {
+	GenerateConsensus(ctx context.Context, req GenerationRequest) (*ConsensusAnswer, error)
+}
+
+// ConsensusAnswer is the result of a high-confidence generation
+type ConsensusAnswer struct {
+	Answer string          `json:"-"`
+	Report ConsensusReport `json:"report"`
+}
+
+// ConsensusReport tells the client how far the sampled answers agreed
+type ConsensusReport struct {
+	Samples   int     `json:"samples"`    // answers that were generated successfully
+	KeyClaims int     `json:"key_claims"` // claims in the returned answer
+	Agreed    int     `json:"agreed"`     // of those, claims made by a majority of samples
+	Supported int     `json:"supported"`  // of those, numbers found in the retrieved studies
+	Agreement float64 `json:"agreement"`  // Agreed / KeyClaims, 1 when there are no claims
+
+	// Disputed lists the claims of the returned answer that the other
+	// samples did not make or the retrieved studies do not contain
+	Disputed []string `json:"disputed,omitempty"`
+	Note     string   `json:"note"`
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]+(?:\s+|$)|\n+`)
+
+// keyClaims splits an answer into the sentences that carry a claim:
+// anything long enough to say something, minus headings and disclaimers
+func keyClaims(answer string) []string {
+	var claims []string
+	for _, sentence := range sentenceEnd.Split(answer, -1) {
+		sentence = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(sentence), "-*•#0123456789) "))
+		if len(strings.Fields(sentence)) < 5 {
+			continue
+		}
+		lower := strings.ToLower(sentence)
+		if strings.Contains(lower, "consult") || strings.Contains(lower, "not medical advice") {
+			continue
+		}
+		claims = append(claims, sentence)
+	}
+	return claims
+}
+
+// claimAgreed reports whether another sample makes the same claim
+func claimAgreed(claim string, other []string) bool {
+	for _, candidate := range other {
+		if data.TitleSimilarity(claim, candidate) >= sameClaim {
+			return true
+		}
+	}
+	return false
+}
+
+// numbersSupported reports whether every number in claim also appears in
+// the retrieved evidence, and whether the claim had any numbers at all
+func numbersSupported(claim string, evidence []data.NumericClaim) (supported, numeric bool) {
+	numbers := data.ExtractNumericClaims(claim)
+	if len(numbers) == 0 {
+		return true, false
+	}
+	for _, number := range numbers {
+		found := false
+		for _, fact := range evidence {
+			if fact.Kind == number.Kind && math.Abs(fact.Value-number.Value) < 1e-9 && math.Abs(fact.High-number.High) < 1e-9 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+// numericEvidence collects the numbers quoted in the retrieved passages
+func numericEvidence(passages []string) []data.NumericClaim {
+	var evidence []data.NumericClaim
+	for _, passage := range passages {
+		evidence = append(evidence, data.ExtractNumericClaims(passage)...)
+	}
+	return evidence
+}
+
+// GenerateConsensus samples the answer at each of consensusTemperatures,
+// checks each sample's key claims against the other samples and the numbers
+// in the retrieved passages, and returns the sample that is best supported
+// with a note on where the samples disagreed
+func (lc *LLMClient) GenerateConsensus(ctx context.Context, genReq GenerationRequest) (*ConsensusAnswer, error) {
+	messages := []ChatMessage{
+		{Role: "system", Content: lc.systemPrompt()},
+		{Role: "user", Content: lc.buildMedicalPrompt(ctx, genReq)},
+	}
+
+	answers := make([]string, len(consensusTemperatures))
+	errs := make([]error, len(consensusTemperatures))
+	var wg sync.WaitGroup
+	for i, temperature := range consensusTemperatures {
+		wg.Add(1)
+		go func(i int, temperature float64) {
+			defer wg.Done()
+			answers[i], errs[i] = lc.complete(ctx, messages, temperature, lc.promptBudget().CompletionTokens)
+		}(i, temperature)
+	}
+	wg.Wait()
+
+	var samples [][]string
+	var texts []string
+	var firstErr error
+	for i, answer := range answers {
+		if errs[i] != nil {
+			log.Printf("⚠️  Consensus sample at temperature %.1f failed: %v", consensusTemperatures[i], errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		texts = append(texts, answer)
+		samples = append(samples, keyClaims(answer))
+	}
+	if len(texts) == 0 {
+		return nil, firstErr
+	}
+
+	evidence := numericEvidence(genReq.MedicalData)
+
+	best, bestScore := 0, -1.0
+	var bestReport ConsensusReport
+	for i, claims := range samples {
+		report := ConsensusReport{Samples: len(texts), KeyClaims: len(claims)}
+		for _, claim := range claims {
+			votes := 1
+			for j, other := range samples {
+				if j != i && claimAgreed(claim, other) {
+					votes++
+				}
+			}
+			agreed := votes*2 > len(samples)
+			supported, numeric := numbersSupported(claim, evidence)
+			if agreed {
+				report.Agreed++
+			}
+			if numeric && supported {
+				report.Supported++
+			}
+			if !agreed || !supported {
+				report.Disputed = append(report.Disputed, claim)
+			}
+		}
+		report.Agreement = 1
+		if report.KeyClaims > 0 {
+			report.Agreement = float64(report.Agreed) / float64(report.KeyClaims)
+		}
+		// Unsupported numbers weigh as much as disagreement; ties go to the
+		// lower temperature sample, which comes first
+		score := report.Agreement - float64(len(report.Disputed)-(report.KeyClaims-report.Agreed))/float64(max(report.KeyClaims, 1))
+		if score > bestScore {
+			best, bestScore, bestReport = i, score, report
+		}
+	}
+	bestReport.Note = consensusNote(bestReport)
+	return &ConsensusAnswer{Answer: texts[best], Report: bestReport}, nil
+}
+
+// consensusNote summarizes a report for display next to the answer
+func consensusNote(report ConsensusReport) string {
+	if report.Samples < 2 {
+		return "Only one answer could be generated, so it could not be cross-checked."
+	}
+	if len(report.Disputed) == 0 {
+		return fmt.Sprintf("All %d independently generated answers agreed on the key points, and the figures quoted appear in the cited studies.", report.Samples)
+	}
+	return fmt.Sprintf("%d of %d key points were made consistently across %d independently generated answers. "+
+		"Treat the %d point(s) listed as disputed with caution: the other answers did not make them or the cited studies do not contain the figures quoted.",
+		report.Agreed, report.KeyClaims, report.Samples, len(report.Disputed))
+}
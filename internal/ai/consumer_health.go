@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"MedAtlasAIServer/internal/budget"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ConsumerHealthCollection holds plain-language health topics (MedlinePlus).
+// Points are keyed by data.PointID of the page URL and carry the payload
+// fields of models.HealthTopic.
+const ConsumerHealthCollection = "consumer_health"
+
+// consumerHealthMinScore is the similarity a health topic needs to be put in
+// a patient prompt ahead of the research abstracts. Topic pages are broad, so
+// weak matches ("Diabetes" for a question about insulin pumps) are left to
+// the abstracts.
+const consumerHealthMinScore = 0.55
+
+// retrieveHealthTopics returns up to limit consumer health passages relevant
+// to vector. The corpus is optional: a missing collection or failed search
+// yields no passages rather than an error.
+func (llm *LLMMedicalChat) retrieveHealthTopics(ctx context.Context, query string, vector []float32, limit int) ([]string, []Source) {
+	searchCtx, cancel, _ := budget.FromContext(ctx).Context(ctx, budget.StageSearch)
+	defer cancel()
+	threshold := float32(consumerHealthMinScore)
+	searchResult, err := llm.QdrantClient.Search(searchCtx, &qdrant.SearchPoints{
+		CollectionName: ConsumerHealthCollection,
+		Vector:         vector,
+		Limit:          uint64(limit),
+		ScoreThreshold: &threshold,
+		WithPayload: &qdrant.WithPayloadSelector{
+			SelectorOptions: &qdrant.WithPayloadSelector_Include{
+				Include: &qdrant.PayloadIncludeSelector{
+					Fields: []string{"id", "title", "summary", "url"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("⚠️  Consumer health search failed, using abstracts only: %v", err)
+		return nil, nil
+	}
+
+	var results []string
+	var sources []Source
+	for _, point := range searchResult.Result {
+		var topic models.HealthTopic
+		payload.Decode(point.Payload, &topic)
+		if topic.Summary == "" {
+			continue
+		}
+		results = append(results, fmt.Sprintf("Health topic: %s (MedlinePlus) - %s", topic.Title, topic.Summary))
+		TraceFromContext(ctx).addPassage(query, topic.ID, topic.Title, point.Score)
+		sources = append(sources, Source{
+			ID:      topic.URL,
+			Title:   topic.Title,
+			Journal: "MedlinePlus",
+
+			Score:      point.Score,
+			ScoreScale: calibration.ScaleCosine,
+			Relevance:  llm.Calibration.Label(calibration.ScaleCosine, point.Score),
+		})
+	}
+	return results, sources
+}
@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// PointGetter fetches points by ID (implemented by qdrant.PointsClient)
+type PointGetter interface {
+	Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error)
+}
+
+// Explanation is a structured lay summary of a single study
+type Explanation struct {
+	WhatWasStudied string `json:"what_was_studied"`
+	WhoWasStudied  string `json:"who_was_studied"`
+	WhatWasFound   string `json:"what_was_found"`
+	Limitations    string `json:"limitations"`
+	Summary        string `json:"summary,omitempty"` // raw model output when it could not be structured
+}
+
+// Explainer turns an abstract into a lay explanation (implemented by LLMClient)
+type Explainer interface {
+	ExplainAbstract(ctx context.Context, title, abstract string) (*Explanation, error)
+}
+
+// ArticleText is the title and abstract of an article to explain
+type ArticleText struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+}
+
+const explainPromptTemplate = `Explain the following medical research abstract to a member of the public with no medical training.
+
+TITLE: %s
+
+ABSTRACT:
+%s
+
+Reply with a single JSON object and nothing else, using exactly these keys:
+{
+  "what_was_studied": "the question the researchers tried to answer",
+  "who_was_studied": "the people, animals or samples involved and how many",
+  "what_was_found": "the main results in plain language, keeping any numbers from the abstract",
+  "limitations": "what the study cannot tell us, or \"Not stated in the abstract\""
+}
+
+RULES:
+1. Use short sentences and explain any technical term you cannot avoid
+2. Only use information that appears in the abstract; do not add outside facts
+3. Do not give medical advice`
+
+// explanationSchema is what a structured explanation must contain
+var explanationSchema = jsonSchema{
+	Required: []string{"what_was_studied", "who_was_studied", "what_was_found", "limitations"},
+	Types: map[string]string{
+		"what_was_studied": "string",
+		"who_was_studied":  "string",
+		"what_was_found":   "string",
+		"limitations":      "string",
+	},
+}
+
+// ExplainAbstract asks the model for a structured lay explanation of one
+// abstract. Replies that are not valid JSON are repaired by the model; if
+// they never are, the raw reply is returned as the summary.
+func (lc *LLMClient) ExplainAbstract(ctx context.Context, title, abstract string) (*Explanation, error) {
+	messages := []ChatMessage{
+		{Role: "system", Content: lc.systemPrompt()},
+		{Role: "user", Content: fmt.Sprintf(explainPromptTemplate, title, abstract)},
+	}
+
+	var explanation Explanation
+	raw, err := lc.completeJSON(ctx, messages, 0.2, 800, explanationSchema, &explanation)
+	if errors.Is(err, errMalformedOutput) {
+		log.Printf("⚠️  Returning unstructured explanation: %v", err)
+		return &Explanation{Summary: strings.TrimSpace(raw)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// LookupArticle loads an article by PMID, first from the vector index and then
+// from PubMed if it has not been indexed. Either source may be nil.
+func LookupArticle(ctx context.Context, points PointGetter, pubmed *data.PubMedClient, pmid string) (*ArticleText, error) {
+	pmid = strings.TrimSpace(pmid)
+	if pmid == "" || strings.Trim(pmid, "0123456789") != "" {
+		return nil, fmt.Errorf("%w: PMID must be numeric", apperrors.ErrInvalidInput)
+	}
+
+	if points != nil {
+		articles, err := GetArticles(ctx, points, []string{pmid})
+		if err != nil && pubmed == nil {
+			return nil, err
+		}
+		for _, article := range articles {
+			if article.Abstract != "" {
+				return &ArticleText{ID: pmid, Title: article.Title, Abstract: article.Abstract}, nil
+			}
+		}
+	}
+
+	if pubmed != nil {
+		articles, err := pubmed.FetchArticleDetails(ctx, []string{pmid})
+		if err != nil {
+			return nil, err
+		}
+		for _, article := range articles {
+			normalized := pubmed.NormalizeArticle(article)
+			if normalized.ID == pmid && normalized.Abstract != "" {
+				return &ArticleText{ID: pmid, Title: normalized.Title, Abstract: normalized.Abstract}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no abstract found for PMID %s", apperrors.ErrNotFound, pmid)
+}
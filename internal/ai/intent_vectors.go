@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// intentModifiers are the retrieval hints added to a query for each intent
+var intentModifiers = map[string]string{
+	"symptom_inquiry": "symptoms clinical presentation signs",
+	"treatment_info":  "treatment therapy management clinical trial",
+	"prevention":      "prevention risk reduction prophylaxis",
+	"causes":          "causes etiology risk factors",
+	"diagnosis":       "diagnosis testing assessment criteria",
+	"risks":           "risks complications side effects",
+	"comparison":      "comparison differences versus",
+	"how_to":          "procedure steps process",
+}
+
+// enhanceQuery appends the intent's modifier text to query
+func enhanceQuery(query, intent string) string {
+	if modifier := intentModifiers[intent]; modifier != "" {
+		return query + " " + modifier
+	}
+	return query
+}
+
+// intentBlendWeight is how strongly an intent's vector pulls the query vector.
+// The modifier text is a handful of words against a full question, so it
+// should steer retrieval without dominating it.
+const intentBlendWeight = 0.35
+
+// IntentVectors holds embeddings of the intent modifiers, computed once at
+// startup, so each request embeds only the user's query. The query vector is
+// blended with the intent vector instead of embedding "query + modifier".
+type IntentVectors struct {
+	vectors map[string][]float32
+}
+
+// PrecomputeIntentVectors embeds every intent modifier
+func PrecomputeIntentVectors(embedder Embedder) (*IntentVectors, error) {
+	iv := &IntentVectors{vectors: make(map[string][]float32, len(intentModifiers))}
+	for intent, modifier := range intentModifiers {
+		vector, err := embedder.GetEmbedding(modifier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed %s modifier: %w", intent, err)
+		}
+		iv.vectors[intent] = normalized(vector)
+	}
+	return iv, nil
+}
+
+// Blend returns the query vector steered towards intent. Unknown intents,
+// a nil receiver or mismatched dimensions return query unchanged.
+func (iv *IntentVectors) Blend(query []float32, intent string) []float32 {
+	if iv == nil {
+		return query
+	}
+	modifier, ok := iv.vectors[intent]
+	if !ok || len(modifier) != len(query) {
+		return query
+	}
+	q := normalized(query)
+	blended := make([]float32, len(q))
+	for i := range q {
+		blended[i] = q[i] + intentBlendWeight*modifier[i]
+	}
+	return normalized(blended)
+}
+
+// normalized returns v scaled to unit length
+func normalized(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * norm
+	}
+	return out
+}
+
+// CachingEmbedder remembers the embeddings of recently seen texts, so
+// repeated queries and follow-ups that reuse a comparison side skip the
+// embedding service. Concurrent requests for the same text share one call,
+// which lets a prefetch started early be picked up by the request that
+// needs it. Eviction is first-in, first-out.
+type CachingEmbedder struct {
+	Embedder Embedder
+
+	mu       sync.Mutex
+	size     int
+	vectors  map[string][]float32
+	order    []string
+	inflight map[string]*embeddingCall
+}
+
+// embeddingCall is an embedding request in progress
+type embeddingCall struct {
+	done   chan struct{}
+	vector []float32
+	err    error
+}
+
+// NewCachingEmbedder caches up to size embeddings in front of embedder
+func NewCachingEmbedder(embedder Embedder, size int) *CachingEmbedder {
+	return &CachingEmbedder{
+		Embedder: embedder,
+		size:     size,
+		vectors:  make(map[string][]float32, size),
+		inflight: make(map[string]*embeddingCall),
+	}
+}
+
+func (c *CachingEmbedder) GetEmbedding(text string) ([]float32, error) {
+	return c.GetEmbeddingContext(context.Background(), text)
+}
+
+// GetEmbeddingContext is GetEmbedding passing ctx on to the embedder.
+// Callers sharing a call wait for it under the first caller's context.
+func (c *CachingEmbedder) GetEmbeddingContext(ctx context.Context, text string) ([]float32, error) {
+	c.mu.Lock()
+	if vector, ok := c.vectors[text]; ok {
+		c.mu.Unlock()
+		return vector, nil
+	}
+	if call, ok := c.inflight[text]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.vector, call.err
+	}
+	call := &embeddingCall{done: make(chan struct{})}
+	c.inflight[text] = call
+	c.mu.Unlock()
+
+	call.vector, call.err = EmbedContext(ctx, c.Embedder, text)
+	close(call.done)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, text)
+	if call.err != nil {
+		return nil, call.err
+	}
+	if _, ok := c.vectors[text]; !ok && c.size > 0 {
+		if len(c.order) >= c.size {
+			delete(c.vectors, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.vectors[text] = call.vector
+		c.order = append(c.order, text)
+	}
+	return call.vector, nil
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Embedder turns text into a query vector (implemented by embeddingClient.Client)
+type Embedder interface {
+	GetEmbedding(text string) ([]float32, error)
+}
+
+// ContextEmbedder is an Embedder that carries a request context, and the
+// trace in it, to the embedding service (implemented by
+// embeddingClient.Client and CachingEmbedder)
+type ContextEmbedder interface {
+	GetEmbeddingContext(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbedContext embeds text with ctx when embedder takes a context
+func EmbedContext(ctx context.Context, embedder Embedder, text string) ([]float32, error) {
+	if withContext, ok := embedder.(ContextEmbedder); ok {
+		return withContext.GetEmbeddingContext(ctx, text)
+	}
+	return embedder.GetEmbedding(text)
+}
+
+// Reranker scores passages for relevance to a query, higher first
+// (implemented by embeddingClient.Client)
+type Reranker interface {
+	Rerank(ctx context.Context, query string, passages []string) ([]float32, error)
+}
+
+// Searcher runs vector searches (implemented by qdrant.PointsClient)
+type Searcher interface {
+	Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error)
+}
+
+// GenerationRequest carries everything the generator needs for one answer
+type GenerationRequest struct {
+	Context     string   // prior conversation, already formatted
+	Summary     string   // summary of the conversation before Context
+	UserMessage string   // the question being answered
+	MedicalData []string // retrieved research passages
+	Persona     Persona
+	Comparison  *ComparisonSides // set for "A vs B" questions; passages are labeled by side
+	Facts       []SourcedFact    // numeric claims extracted from MedicalData
+	ReaderNotes []string         // the user's own annotations on the paper being discussed
+}
+
+// Generator produces an answer from conversation context and retrieved research
+// (implemented by LLMClient)
+type Generator interface {
+	GenerateResponse(ctx context.Context, req GenerationRequest) (string, error)
+}
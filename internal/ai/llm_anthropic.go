@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/tracing"
+)
+
+// DefaultAnthropicModel is the Anthropic model used when none is configured
+const DefaultAnthropicModel = "claude-3-5-haiku-latest"
+
+// anthropicVersion is the Messages API version requests are written against
+const anthropicVersion = "2023-06-01"
+
+// jsonReplyInstruction stands in for response_format, which the Anthropic
+// API does not offer; completeJSON validates the reply either way
+const jsonReplyInstruction = "Reply with a single JSON object and nothing else."
+
+// AnthropicProvider speaks the Anthropic Messages API
+type AnthropicProvider struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewAnthropicProvider creates an Anthropic provider. An empty model
+// selects DefaultAnthropicModel.
+func NewAnthropicProvider(apiKey, model string) *AnthropicProvider {
+	if model == "" {
+		model = DefaultAnthropicModel
+	}
+	return &AnthropicProvider{
+		APIKey:     apiKey,
+		BaseURL:    "https://api.anthropic.com/v1",
+		Model:      model,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (p *AnthropicProvider) Name() string         { return ProviderAnthropic }
+func (p *AnthropicProvider) DefaultModel() string { return p.Model }
+
+// CloseIdleConnections closes the client's idle keep-alive connections
+func (p *AnthropicProvider) CloseIdleConnections() { p.HTTPClient.CloseIdleConnections() }
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicProvider) Complete(ctx context.Context, in CompletionRequest) (*Completion, error) {
+	request := anthropicRequest{Model: in.Model, MaxTokens: in.MaxTokens, Temperature: in.Temperature}
+	// The API takes the system prompt separately and temperatures up to 1
+	var system []string
+	for _, message := range in.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		request.Messages = append(request.Messages, anthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	if in.Format != nil {
+		system = append(system, jsonReplyInstruction)
+	}
+	request.System = strings.Join(system, "\n\n")
+	request.Temperature = min(request.Temperature, 1)
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: anthropic request failed: %w", apperrors.ErrLLMUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: anthropic returned status %d", apperrors.ErrRateLimited, resp.StatusCode)
+	}
+	var response anthropicResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&response)
+	if resp.StatusCode != http.StatusOK {
+		if response.Error.Message != "" {
+			return nil, fmt.Errorf("%w: anthropic returned status %d: %s", apperrors.ErrLLMUnavailable, resp.StatusCode, response.Error.Message)
+		}
+		return nil, fmt.Errorf("%w: anthropic returned status %d", apperrors.ErrLLMUnavailable, resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+
+	var content strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("%w: empty response from anthropic", apperrors.ErrLLMUnavailable)
+	}
+	return &Completion{
+		Content:          content.String(),
+		Model:            response.Model,
+		PromptTokens:     response.Usage.InputTokens,
+		CompletionTokens: response.Usage.OutputTokens,
+	}, nil
+}
+
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.BaseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrLLMUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: anthropic returned status %d", apperrors.ErrLLMUnavailable, resp.StatusCode)
+	}
+
+	var modelsResponse struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResponse); err != nil {
+		return nil, err
+	}
+	var models []string
+	for _, model := range modelsResponse.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
@@ -1,65 +1,92 @@
 package ai
 
 import (
-	"bytes"
-	"encoding/json"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/logging"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/tracing"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"strings"
 	"time"
 )
 
-// LLMClient handles communication with OpenRouter.ai
+// LLMClient generates answers with an LLMProvider. It bounds concurrent
+// calls, records traces and metrics, and fails over to the next provider
+// when one returns an error or times out.
 type LLMClient struct {
-	APIKey     string
-	BaseURL    string
-	Model      string
-	HTTPClient *http.Client
+	// Providers are tried in order; the first is the primary
+	Providers []LLMProvider
+	Config    *config.Store // optional, supplies the reloadable system prompt
+	Limiter   *LLMLimiter   // optional, bounds concurrent calls
+	// AttemptTimeout bounds each provider attempt but the last, leaving
+	// time for failover. Zero leaves attempts bounded by ctx alone.
+	AttemptTimeout time.Duration
+	// JSONRepairs is how many times a structured reply that fails
+	// validation is sent back to the model for repair
+	JSONRepairs int
 }
 
-// NewLLMClient creates a new OpenRouter.ai client
+// NewLLMClient creates a client of an OpenRouter.ai model
 func NewLLMClient(apiKey, model string) *LLMClient {
+	return NewProviderClient(NewOpenRouterProvider(apiKey, model))
+}
+
+// NewProviderClient creates a client of providers, primary first
+func NewProviderClient(providers ...LLMProvider) *LLMClient {
 	return &LLMClient{
-		APIKey:     apiKey,
-		BaseURL:    "https://openrouter.ai/api/v1",
-		Model:      model,
-		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		Providers:      providers,
+		AttemptTimeout: DefaultAttemptTimeout,
+		JSONRepairs:    DefaultJSONRepairs,
 	}
 }
 
-// OpenRouterRequest represents the request to OpenRouter.ai
-type OpenRouterRequest struct {
-	Model       string            `json:"model"`
-	Messages    []ChatMessage     `json:"messages"`
-	Temperature float64           `json:"temperature"`
-	MaxTokens   int               `json:"max_tokens"`
-	Stream      bool              `json:"stream"`
-	Headers     map[string]string `json:"headers,omitempty"`
+// ModelName returns the primary provider's model
+func (lc *LLMClient) ModelName() string {
+	return lc.Providers[0].DefaultModel()
 }
 
-// OpenRouterResponse represents the response from OpenRouter.ai
-type OpenRouterResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-	Error struct {
-		Message string `json:"message"`
-	} `json:"error"`
-	Model string `json:"model"`
+// CloseIdleConnections closes the idle connections of the providers that
+// keep any, for use at shutdown
+func (lc *LLMClient) CloseIdleConnections() {
+	for _, provider := range lc.Providers {
+		if closer, ok := provider.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+}
+
+// ProviderName returns the primary provider's name
+func (lc *LLMClient) ProviderName() string {
+	return lc.Providers[0].Name()
+}
+
+type modelKey struct{}
+
+// WithModel makes completions for ctx use model instead of the client's
+// default. Callers check model against the allowlist first.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFromContext returns the model requested for ctx, or ""
+func ModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(modelKey{}).(string)
+	return model
 }
 
 // GenerateResponse generates AI-powered response using OpenRouter.ai
-func (lc *LLMClient) GenerateResponse(context string, userMessage string, medicalData []string) (string, error) {
+func (lc *LLMClient) GenerateResponse(ctx context.Context, genReq GenerationRequest) (string, error) {
 	// Build the prompt with medical context
-	prompt := lc.buildMedicalPrompt(context, userMessage, medicalData)
+	prompt := lc.buildMedicalPrompt(ctx, genReq)
 
 	messages := []ChatMessage{
 		{
 			Role:    "system",
-			Content: "You are a medical AI assistant that provides general health information and suggestions based on medical research. You are helpful, cautious, and always recommend consulting healthcare professionals for personal medical advice. Never provide prescriptions or specific dosage advice.",
+			Content: lc.systemPrompt(),
 		},
 		{
 			Role:    "user",
@@ -67,137 +94,267 @@ func (lc *LLMClient) GenerateResponse(context string, userMessage string, medica
 		},
 	}
 
-	request := OpenRouterRequest{
-		Model:       lc.Model,
-		Messages:    messages,
-		Temperature: 0.7,
-		MaxTokens:   1024,
-		Stream:      false,
-		Headers: map[string]string{
-			"HTTP-Referer": "https://medical-chat-app.com",
-			"X-Title":      "Medical AI Assistant",
-		},
-	}
+	return lc.complete(ctx, messages, 0.7, lc.promptBudget().CompletionTokens)
+}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+// GenerateResponseStream is GenerateResponse passing the answer to onDelta
+// as it is generated, from providers that stream; others deliver it in one
+// piece. A provider that fails after streaming part of its answer is not
+// failed over, since the caller already has that part.
+func (lc *LLMClient) GenerateResponseStream(ctx context.Context, genReq GenerationRequest, onDelta func(string)) (string, error) {
+	messages := []ChatMessage{
+		{Role: "system", Content: lc.systemPrompt()},
+		{Role: "user", Content: lc.buildMedicalPrompt(ctx, genReq)},
 	}
+	return lc.send(ctx, messages, 0.7, lc.promptBudget().CompletionTokens, nil, onDelta)
+}
 
-	req, err := http.NewRequest("POST", lc.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
+// complete sends a chat completion request and returns the first choice
+func (lc *LLMClient) complete(ctx context.Context, messages []ChatMessage, temperature float64, maxTokens int) (string, error) {
+	return lc.send(ctx, messages, temperature, maxTokens, nil, nil)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+lc.APIKey)
-	req.Header.Set("HTTP-Referer", "https://medical-chat-app.com")
-	req.Header.Set("X-Title", "Medical AI Assistant")
+// send performs one chat completion, optionally constraining the reply
+// format and streaming it to onDelta. A model chosen with WithModel applies
+// to the primary provider; fallbacks answer with their own model.
+func (lc *LLMClient) send(ctx context.Context, messages []ChatMessage, temperature float64, maxTokens int, format *ResponseFormat, onDelta func(string)) (content string, err error) {
+	model := lc.ModelName()
+	if override := ModelFromContext(ctx); override != "" {
+		model = override
+	}
 
-	log.Printf("🤖 Sending request to OpenRouter.ai with model: %s", lc.Model)
+	// The span includes waiting for a free slot under the limiter
+	ctx, span := tracing.Start(ctx, "llm chat_completion", tracing.KindClient)
+	span.SetAttr("gen_ai.request.model", model)
+	defer func() { span.End(err) }()
 
-	resp, err := lc.HTTPClient.Do(req)
+	release, err := lc.Limiter.Acquire(ctx)
 	if err != nil {
-		return "", fmt.Errorf("API request failed: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("OpenRouter.ai returned status %d", resp.StatusCode)
+	defer release()
+
+	streamed := false
+	if onDelta != nil {
+		deliver := onDelta
+		onDelta = func(delta string) {
+			streamed = true
+			deliver(delta)
+		}
 	}
-
-	var response OpenRouterResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+	for i, provider := range lc.Providers {
+		if i > 0 {
+			log.Printf("⚠️  %s failed, failing over to %s: %v", lc.Providers[i-1].Name(), provider.Name(), err)
+			model = provider.DefaultModel()
+		}
+		request := CompletionRequest{Model: model, Messages: messages, Temperature: temperature, MaxTokens: maxTokens, Format: format}
+		var completion *Completion
+		completion, err = lc.attempt(ctx, provider, request, i == len(lc.Providers)-1, onDelta)
+		if err == nil {
+			span.SetAttr("gen_ai.system", provider.Name())
+			return lc.finish(ctx, span, provider, request, completion), nil
+		}
+		if ctx.Err() != nil || streamed {
+			break // the caller gave up, or already has part of this answer
+		}
 	}
+	return "", err
+}
 
-	if response.Error.Message != "" {
-		return "", fmt.Errorf("OpenRouter.ai error: %s", response.Error.Message)
+// attempt runs one completion on provider, within AttemptTimeout unless it
+// is the last provider left. A streamed reply only has to start within
+// AttemptTimeout, as long answers from local models take longer than that
+// to finish.
+func (lc *LLMClient) attempt(ctx context.Context, provider LLMProvider, request CompletionRequest, last bool, onDelta func(string)) (completion *Completion, err error) {
+	streamer, streaming := provider.(StreamingProvider)
+	streaming = streaming && onDelta != nil
+	if !last && lc.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		if streaming {
+			ctx, cancel = context.WithCancel(ctx)
+			timer := time.AfterFunc(lc.AttemptTimeout, cancel)
+			deliver := onDelta
+			onDelta = func(delta string) {
+				timer.Stop()
+				deliver(delta)
+			}
+		} else {
+			ctx, cancel = context.WithTimeout(ctx, lc.AttemptTimeout)
+		}
+		defer cancel()
+	}
+	start := time.Now()
+	defer func() {
+		metrics.LLMDuration.Since(start, request.Model)
+		metrics.LLMRequests.Inc(request.Model, metrics.Outcome(err))
+	}()
+	logging.Debugf("🤖 Sending request to %s with model: %s", provider.Name(), request.Model)
+	if streaming {
+		completion, err = streamer.Stream(ctx, request, onDelta)
+	} else if completion, err = provider.Complete(ctx, request); err == nil && onDelta != nil {
+		onDelta(completion.Content)
 	}
+	if err != nil && ctx.Err() != nil && !errors.Is(err, apperrors.ErrLLMUnavailable) {
+		err = fmt.Errorf("%w: %s timed out: %w", apperrors.ErrLLMUnavailable, provider.Name(), err)
+	}
+	return completion, err
+}
 
-	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
-		return "", fmt.Errorf("empty response from AI model")
+// finish records a successful completion and returns its content
+func (lc *LLMClient) finish(ctx context.Context, span *tracing.Span, provider LLMProvider, request CompletionRequest, completion *Completion) string {
+	log.Printf("✅ Received response from %s model: %s", provider.Name(), completion.Model)
+	generation := TraceGenerate{
+		Provider:         provider.Name(),
+		Model:            completion.Model,
+		Temperature:      request.Temperature,
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+		PromptVersion:    PromptVersion(request.Messages),
 	}
+	if generation.PromptTokens == 0 {
+		generation.PromptTokens, generation.Estimated = estimateTokens(request.Messages), true
+	}
+	if generation.CompletionTokens == 0 && completion.Content != "" {
+		generation.CompletionTokens, generation.Estimated = CountTokens(completion.Content), true
+	}
+	TraceFromContext(ctx).addGeneration(generation)
+	metrics.LLMTokens.Add(float64(generation.PromptTokens), request.Model, "prompt")
+	metrics.LLMTokens.Add(float64(generation.CompletionTokens), request.Model, "completion")
+	span.SetAttr("gen_ai.usage.input_tokens", generation.PromptTokens)
+	span.SetAttr("gen_ai.usage.output_tokens", generation.CompletionTokens)
+	return completion.Content
+}
 
-	log.Printf("✅ Received response from model: %s", response.Model)
-	return response.Choices[0].Message.Content, nil
+func (lc *LLMClient) systemPrompt() string {
+	if lc.Config == nil {
+		return config.DefaultSystemPrompt
+	}
+	return lc.Config.Current().SystemPrompt
 }
 
-// buildMedicalPrompt creates a comprehensive prompt for medical conversations
-func (lc *LLMClient) buildMedicalPrompt(context, userMessage string, medicalData []string) string {
-	var prompt strings.Builder
+// buildMedicalPrompt creates a comprehensive prompt for medical conversations.
+// The prompt is fitted to the prompt budget: the conversation history may
+// take a quarter of the room the fixed parts leave, dropping its oldest
+// lines, and the findings share the rest, shortened to whole sentences. The
+// summary of earlier turns may take half of the history's share.
+func (lc *LLMClient) buildMedicalPrompt(ctx context.Context, genReq GenerationRequest) string {
+	userMessage := genReq.UserMessage
+	var head, middle, tail strings.Builder
+
+	head.WriteString("MEDICAL AI ASSISTANT ROLE:\n")
+	head.WriteString("You are a helpful medical AI assistant. Provide evidence-based health information while being cautious and ethical.\n")
+	head.WriteString("KEY RULES:\n")
+	head.WriteString("1. NEVER give prescriptions, dosages, or specific medical advice\n")
+	head.WriteString("2. ALWAYS recommend consulting healthcare professionals\n")
+	head.WriteString("3. Base responses on medical research when available\n")
+	head.WriteString("4. Be empathetic and clear in your communication\n")
+	head.WriteString("5. If unsure, say so and suggest professional consultation\n\n")
+
+	if len(genReq.ReaderNotes) > 0 {
+		middle.WriteString("THE USER'S OWN ANNOTATIONS ON THIS PAPER:\n")
+		for _, note := range genReq.ReaderNotes {
+			middle.WriteString("- " + note + "\n")
+		}
+		middle.WriteString("Take these highlights and notes into account; they show what the user cares about.\n\n")
+	}
 
-	prompt.WriteString("MEDICAL AI ASSISTANT ROLE:\n")
-	prompt.WriteString("You are a helpful medical AI assistant. Provide evidence-based health information while being cautious and ethical.\n")
-	prompt.WriteString("KEY RULES:\n")
-	prompt.WriteString("1. NEVER give prescriptions, dosages, or specific medical advice\n")
-	prompt.WriteString("2. ALWAYS recommend consulting healthcare professionals\n")
-	prompt.WriteString("3. Base responses on medical research when available\n")
-	prompt.WriteString("4. Be empathetic and clear in your communication\n")
-	prompt.WriteString("5. If unsure, say so and suggest professional consultation\n\n")
+	middle.WriteString("USER'S QUESTION: ")
+	middle.WriteString(userMessage)
+	middle.WriteString("\n\n")
 
-	if context != "" {
-		prompt.WriteString("CONVERSATION CONTEXT:\n")
-		prompt.WriteString(context)
-		prompt.WriteString("\n\n")
+	maxFindings := 3 // Limit to top 3 findings
+	if genReq.Comparison != nil {
+		maxFindings = 6 // Up to 3 per side
 	}
+	findings := genReq.MedicalData[:min(len(genReq.MedicalData), maxFindings)]
 
-	prompt.WriteString("USER'S QUESTION: ")
-	prompt.WriteString(userMessage)
-	prompt.WriteString("\n\n")
-
-	if len(medicalData) > 0 {
-		prompt.WriteString("RELEVANT MEDICAL RESEARCH FINDINGS:\n")
-		for i, data := range medicalData {
-			if i < 3 { // Limit to top 3 findings
-				prompt.WriteString(fmt.Sprintf("%d. %s\n", i+1, data))
+	if len(genReq.Facts) > 0 {
+		tail.WriteString("NUMERIC FACTS FROM THE FINDINGS (normalized units):\n")
+		for _, fact := range genReq.Facts {
+			if fact.Source <= maxFindings {
+				tail.WriteString("- " + fact.String() + "\n")
 			}
 		}
-		prompt.WriteString("\n")
+		tail.WriteString("Only quote numbers that appear in this list, and cite their finding number.\n\n")
 	}
 
-	prompt.WriteString("INSTRUCTIONS:\n")
-	prompt.WriteString("1. Provide helpful information based on the context above\n")
-	prompt.WriteString("2. Be cautious and avoid giving medical advice\n")
-	prompt.WriteString("3. Suggest consulting healthcare professionals\n")
-	prompt.WriteString("4. Keep responses conversational and empathetic\n")
-	prompt.WriteString("5. If research findings are available, reference them appropriately\n")
-	prompt.WriteString("6. Use plain language, avoid overly technical terms\n\n")
-
-	prompt.WriteString("YOUR RESPONSE:")
-
-	return prompt.String()
-}
+	if genReq.Comparison != nil {
+		tail.WriteString("COMPARISON TASK:\n")
+		tail.WriteString(fmt.Sprintf("Compare %s (Side A) with %s (Side B).\n", genReq.Comparison.A, genReq.Comparison.B))
+		tail.WriteString("Present a side-by-side markdown table covering efficacy, safety and evidence quality.\n")
+		tail.WriteString("Cite the finding number, e.g. [2], after every claim. Only use Side A findings for Side A claims and Side B findings for Side B claims.\n")
+		tail.WriteString("If a side has no findings, say the evidence for it was not found rather than guessing.\n\n")
+	}
 
-// GetAvailableModels returns available models from OpenRouter.ai
-func (lc *LLMClient) GetAvailableModels() ([]string, error) {
-	req, err := http.NewRequest("GET", lc.BaseURL+"/models", nil)
-	if err != nil {
-		return nil, err
+	tail.WriteString("INSTRUCTIONS:\n")
+	if genReq.Persona == PersonaClinician {
+		tail.WriteString("The reader is a clinician.\n")
+		tail.WriteString("1. Provide evidence-focused information based on the context above\n")
+		tail.WriteString("2. Use precise clinical terminology and keep standard abbreviations\n")
+		tail.WriteString("3. Report study design, population, endpoints and effect sizes when available\n")
+		tail.WriteString("4. Note limitations and the strength of the evidence\n")
+		tail.WriteString("5. Be concise; skip lay explanations\n\n")
+	} else {
+		tail.WriteString("1. Provide helpful information based on the context above\n")
+		tail.WriteString("2. Be cautious and avoid giving medical advice\n")
+		tail.WriteString("3. Suggest consulting healthcare professionals\n")
+		tail.WriteString("4. Keep responses conversational and empathetic\n")
+		tail.WriteString("5. If research findings are available, reference them appropriately\n")
+		tail.WriteString("6. Use plain language, avoid overly technical terms\n\n")
 	}
 
-	req.Header.Set("Authorization", "Bearer "+lc.APIKey)
+	tail.WriteString("YOUR RESPONSE:")
 
-	resp, err := lc.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
+	// Fit the history and findings into what the fixed parts leave
+	budget := lc.promptBudget()
+	report := PromptBudgetReport{LimitTokens: budget.ContextTokens - budget.CompletionTokens}
+	room := report.LimitTokens - estimateTokens([]ChatMessage{{Content: lc.systemPrompt()}, {Content: head.String() + middle.String() + tail.String()}})
+	if genReq.Context != "" || genReq.Summary != "" {
+		room -= CountTokens("CONVERSATION CONTEXT:\n\n\n")
 	}
-	defer resp.Body.Close()
-
-	var modelsResponse struct {
-		Data []struct {
-			ID string `json:"id"`
-		} `json:"data"`
+	if len(findings) > 0 {
+		room -= CountTokens("RELEVANT MEDICAL RESEARCH FINDINGS:\n\n")
+		for i := range findings {
+			room -= CountTokens(fmt.Sprintf("[%d] \n", i+1))
+		}
 	}
+	historyRoom := max(room, 0) / historyShare
+	summary := fitSummary(genReq.Summary, historyRoom/2)
+	history, dropped := fitHistory(genReq.Context, historyRoom-CountTokens(summary))
+	findings, truncated := fitPassages(findings, room-CountTokens(summary)-CountTokens(history))
+	report.HistoryLinesDropped, report.PassagesTruncated = dropped, truncated
+	report.Truncated = history != genReq.Context || truncated > 0 ||
+		(genReq.Summary != "" && summary != summaryHeading+genReq.Summary+"\n")
 
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResponse); err != nil {
-		return nil, err
+	var prompt strings.Builder
+	prompt.WriteString(head.String())
+	if summary != "" || history != "" {
+		prompt.WriteString("CONVERSATION CONTEXT:\n")
+		prompt.WriteString(summary)
+		prompt.WriteString(history)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString(middle.String())
+	if len(findings) > 0 {
+		prompt.WriteString("RELEVANT MEDICAL RESEARCH FINDINGS:\n")
+		for i, data := range findings {
+			prompt.WriteString(fmt.Sprintf("[%d] %s\n", i+1, data))
+		}
+		prompt.WriteString("\n")
 	}
+	prompt.WriteString(tail.String())
 
-	var models []string
-	for _, model := range modelsResponse.Data {
-		models = append(models, model.ID)
+	report.PromptTokens = estimateTokens([]ChatMessage{{Content: lc.systemPrompt()}, {Content: prompt.String()}})
+	if report.Truncated {
+		logging.Debugf("✂️  Fitted prompt to %d tokens: dropped %d history lines, shortened %d findings", report.LimitTokens, dropped, truncated)
 	}
+	TraceFromContext(ctx).setBudget(report)
+	return prompt.String()
+}
 
-	return models, nil
+// GetAvailableModels returns the models the primary provider offers
+func (lc *LLMClient) GetAvailableModels() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return lc.Providers[0].ListModels(ctx)
 }
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+)
+
+// LLMLimiter caps the number of concurrent model calls. Calls beyond the cap
+// wait in a bounded queue for up to MaxWait; when the queue is full or the
+// wait runs out they fail with apperrors.ErrOverloaded, so a traffic spike
+// is turned away quickly instead of piling up against the provider's rate
+// limit. Limits can be changed at runtime.
+type LLMLimiter struct {
+	mu       sync.Mutex
+	limit    int // zero disables limiting
+	maxQueue int
+	maxWait  time.Duration
+	active   int
+	waiting  []chan struct{}
+}
+
+// NewLLMLimiter creates a limiter; maxConcurrent == 0 disables limiting
+func NewLLMLimiter(maxConcurrent, maxQueue int, maxWait time.Duration) *LLMLimiter {
+	l := &LLMLimiter{}
+	l.Update(maxConcurrent, maxQueue, maxWait)
+	return l
+}
+
+// Update swaps in new limits. Waiting calls that now fit are let through.
+func (l *LLMLimiter) Update(maxConcurrent, maxQueue int, maxWait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.maxQueue, l.maxWait = maxConcurrent, maxQueue, maxWait
+	l.admitLocked()
+}
+
+// Acquire waits for a slot and returns the function that gives it back.
+// A nil limiter admits every call.
+func (l *LLMLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	if l.limit <= 0 || l.active < l.limit {
+		l.active++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if len(l.waiting) >= l.maxQueue {
+		queued, retry := len(l.waiting), retryAfter(l.maxWait)
+		l.mu.Unlock()
+		return nil, apperrors.WithRetryAfter(
+			fmt.Errorf("%w: %d model calls running and %d queued", apperrors.ErrOverloaded, l.limit, queued), retry)
+	}
+	admitted := make(chan struct{})
+	l.waiting = append(l.waiting, admitted)
+	maxWait := l.maxWait
+	l.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case <-admitted:
+		return l.release, nil
+	case <-timer.C:
+		err = apperrors.WithRetryAfter(
+			fmt.Errorf("%w: no model slot free after %v", apperrors.ErrOverloaded, maxWait), retryAfter(maxWait))
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, ch := range l.waiting {
+		if ch == admitted {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			return nil, err
+		}
+	}
+	// Admitted while giving up: hand the slot on
+	l.active--
+	l.admitLocked()
+	return nil, err
+}
+
+func (l *LLMLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.admitLocked()
+}
+
+// admitLocked lets queued calls through while there is room. Callers must hold l.mu.
+func (l *LLMLimiter) admitLocked() {
+	for len(l.waiting) > 0 && (l.limit <= 0 || l.active < l.limit) {
+		l.active++
+		close(l.waiting[0])
+		l.waiting = l.waiting[1:]
+	}
+}
+
+// retryAfter suggests how long a rejected client should wait: about one
+// queue wait, at least a second
+func retryAfter(maxWait time.Duration) time.Duration {
+	return max(maxWait, time.Second)
+}
@@ -1,23 +1,62 @@
 package ai
 
 import (
-	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/budget"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/locale"
+	"MedAtlasAIServer/internal/logging"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/rerank"
+	"MedAtlasAIServer/internal/suggestions"
+	"MedAtlasAIServer/internal/synonyms"
+	"MedAtlasAIServer/pkg/data"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
 type LLMMedicalChat struct {
-	Embedder     *embeddingClient.Client
-	QdrantClient qdrant.PointsClient
-	LLMClient    *LLMClient
+	Embedder     Embedder
+	QdrantClient Searcher
+	LLMClient    Generator
 	UseRealAI    bool
+	Config       *config.Store // optional, supplies the reloadable chat top-k
+
+	// IntentVectors, when set, replaces the intent modifier text appended to
+	// each query with precomputed vectors blended into the query embedding
+	IntentVectors *IntentVectors
+
+	// Reranker, when set, reorders retrieved studies with a cross-encoder
+	// before they are put in the prompt. Without it, or when it fails or the
+	// budget has no time for it, the vector search's cosine order is kept.
+	Reranker Reranker
+
+	// ConsumerHealth enables the plain-language health topics in
+	// ConsumerHealthCollection, which patient answers draw on before
+	// research abstracts
+	ConsumerHealth bool
+
+	// Synonyms, when set, expands queries with the admin-defined synonyms
+	// of the terms they name
+	Synonyms *synonyms.Store
+
+	// Calibration, when set, labels the relevance of each source's score
+	Calibration *calibration.Calibrator
+
+	// Suggestions supplies the suggestion templates; nil uses the built-in
+	// ones
+	Suggestions *suggestions.Engine
 }
 
-func NewLLMMedicalChat(embedder *embeddingClient.Client, qdrantClient qdrant.PointsClient, llmClient *LLMClient) *LLMMedicalChat {
+func NewLLMMedicalChat(embedder Embedder, qdrantClient Searcher, llmClient Generator) *LLMMedicalChat {
 	return &LLMMedicalChat{
 		Embedder:     embedder,
 		QdrantClient: qdrantClient,
@@ -26,35 +65,129 @@ func NewLLMMedicalChat(embedder *embeddingClient.Client, qdrantClient qdrant.Poi
 	}
 }
 
+// PrefetchQuery embeds the query ProcessMessage will search for, so the
+// embedding can run while the caller does other work (e.g. the safety
+// check). It only helps when Embedder deduplicates concurrent requests, as
+// CachingEmbedder does; errors are left for ProcessMessage to report.
+func (llm *LLMMedicalChat) PrefetchQuery(userMessage string, chatHistory []ChatMessage) {
+	if sides, ok := ExtractComparisonSides(userMessage); ok {
+		llm.embedQuery(context.Background(), sides.A, "comparison")
+		llm.embedQuery(context.Background(), sides.B, "comparison")
+		return
+	}
+	llm.embedQuery(context.Background(), userMessage, llm.UnderstandIntent(userMessage, chatHistory))
+}
+
 func (llm *LLMMedicalChat) ProcessMessage(ctx context.Context, userMessage string, chatHistory []ChatMessage) (*ChatResponse, error) {
+	loc := locale.FromContext(ctx)
+	persona := PersonaFromContext(ctx)
+	plan := budget.FromContext(ctx)
+	if plan == nil {
+		plan = budget.ForChat(clock.System, chatTimeouts(llm.Config))
+		ctx = budget.WithBudget(ctx, plan)
+	}
 	intent := llm.UnderstandIntent(userMessage, chatHistory)
-
-	// Search for relevant medical information
-	searchResults, err := llm.SearchMedicalKnowledge(ctx, userMessage, intent)
+	sides, isComparison := ExtractComparisonSides(userMessage)
+	if isComparison {
+		intent = "comparison"
+	}
+	trace := TraceFromContext(ctx)
+	trace.setIntent(intent)
+
+	// Suggestions depend only on the question, so build them off the
+	// critical path while retrieval and generation run
+	suggestionsReady := make(chan []string, 1)
+	go func() { suggestionsReady <- llm.GenerateHelpfulSuggestions(ctx, intent, userMessage) }()
+
+	// Search for relevant medical information, retrieving each side
+	// separately for comparison questions
+	var searchResults []string
+	var sources []Source
+	var err error
+	searchStart := time.Now()
+	reportProgress(ctx, StageSearching, 0)
+	if isComparison {
+		searchResults, sources, err = llm.retrieveComparison(ctx, sides, intent)
+	} else {
+		searchResults, sources, err = llm.retrieve(ctx, userMessage, intent)
+	}
+	logging.Debugf("chat retrieval took %v", time.Since(searchStart))
 	if err != nil {
 		log.Printf("Search failed: %v, using fallback", err)
 		searchResults = []string{} // Empty results for fallback
 	}
+	reportProgress(ctx, StageFound, len(sources))
 	conversationContext := llm.BuildConversationContext(chatHistory)
 
 	var response string
-	var suggestions []string
+	var consensus *ConsensusReport
+	partial := false
 
 	if llm.UseRealAI && llm.LLMClient != nil {
-		aiResponse, err := llm.LLMClient.GenerateResponse(conversationContext, userMessage, searchResults)
-		if err != nil {
+		genReq := GenerationRequest{
+			Context:     conversationContext,
+			Summary:     ConversationSummary(ctx, chatHistory),
+			UserMessage: userMessage,
+			MedicalData: searchResults,
+			Persona:     persona,
+			Facts:       ExtractSourcedFacts(searchResults),
+			ReaderNotes: ReaderNotesFromContext(ctx),
+		}
+		if isComparison {
+			genReq.Comparison = &sides
+		}
+		generateStart := time.Now()
+		reportProgress(ctx, StageGenerating, 0)
+		genCtx, cancel, _ := plan.Context(ctx, budget.StageGeneration)
+		var aiResponse string
+		if consensusGen, ok := llm.LLMClient.(ConsensusGenerator); ok && HighConfidenceFromContext(ctx) {
+			var answer *ConsensusAnswer
+			if answer, err = consensusGen.GenerateConsensus(genCtx, genReq); err == nil {
+				aiResponse, consensus = answer.Answer, &answer.Report
+			}
+		} else if streamer, ok := llm.LLMClient.(StreamingGenerator); ok && AnswerStreamFromContext(ctx) != nil {
+			aiResponse, err = streamer.GenerateResponseStream(genCtx, genReq, AnswerStreamFromContext(ctx))
+		} else {
+			aiResponse, err = llm.LLMClient.GenerateResponse(genCtx, genReq)
+		}
+		timedOut := errors.Is(genCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+		logging.Debugf("chat generation took %v", time.Since(generateStart))
+		if errors.Is(err, apperrors.ErrOverloaded) {
+			// Answering from the local fallback would hide the overload from
+			// clients that should back off
+			return nil, err
+		} else if err != nil && timedOut && len(sources) > 0 {
+			// Retrieval succeeded, so return what was found rather than
+			// discarding it along with the unfinished summary
+			log.Printf("AI generation timed out after %v, returning sources only", time.Since(generateStart))
+			response = loc.T(locale.SummaryUnavailable)
+			partial = true
+		} else if err != nil {
 			log.Printf("AI generation failed: %v, using local fallback", err)
-			response = llm.GenerateLocalResponse(userMessage, searchResults, intent)
+			response = llm.GenerateLocalResponse(userMessage, searchResults, intent, loc, persona)
 		} else {
-			response = aiResponse
+			trace.setGrounding(aiResponse, searchResults)
+			response = AlignCitations(ctx, llm.Reranker, aiResponse, searchResults)
 		}
 	} else {
-		response = llm.GenerateLocalResponse(userMessage, searchResults, intent)
+		response = llm.GenerateLocalResponse(userMessage, searchResults, intent, loc, persona)
+	}
+	// Suggestions are optional: skip them rather than run past the deadline
+	var suggestions []string
+	if wait, ok := plan.Allot(budget.StageSuggestions); ok {
+		select {
+		case suggestions = <-suggestionsReady:
+		case <-time.After(wait):
+		}
 	}
-	suggestions = llm.GenerateHelpfulSuggestions(intent)
 	return &ChatResponse{
 		Response:    response,
 		Suggestions: suggestions,
+		Sources:     sources,
+		Partial:     partial,
+		Consensus:   consensus,
+		Confidence:  AssessConfidence(sources, clock.System.Now()),
 	}, nil
 }
 
@@ -65,13 +198,14 @@ func (llm *LLMMedicalChat) BuildConversationContext(history []ChatMessage) strin
 	var context strings.Builder
 	context.WriteString("Previous conversation:\n")
 
-	start := len(history) - 3
+	start := len(history) - RecentMessages
 	if start < 0 {
 		start = 0
 	}
-	for i := start; i < len(history); i++ {
-		msg := history[i]
-		context.WriteString(fmt.Sprintf("%s: %s\n", strings.ToUpper(msg.Role[:1]), msg.Content))
+	for _, msg := range history[start:] {
+		if line, ok := transcriptLine(msg); ok {
+			context.WriteString(line)
+		}
 	}
 
 	return context.String()
@@ -106,101 +240,225 @@ func (llm *LLMMedicalChat) UnderstandIntent(message string, history []ChatMessag
 }
 
 func (llm *LLMMedicalChat) SearchMedicalKnowledge(ctx context.Context, query string, intent string) ([]string, error) {
-	enhancedQuery := llm.EnhanceQueryForIntent(query, intent)
+	passages, _, err := llm.retrieve(ctx, query, intent)
+	return passages, err
+}
 
-	vector, err := llm.Embedder.GetEmbedding(enhancedQuery)
+// retrieve searches for query and returns the formatted passages for the
+// prompt together with the studies they came from
+func (llm *LLMMedicalChat) retrieve(ctx context.Context, query string, intent string) ([]string, []Source, error) {
+	vector, err := llm.embedQuery(ctx, query, intent)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Clinicians get more studies and the technical metadata (publication
+	// types, DOI); patients get fewer, plain-language passages.
+	persona := PersonaFromContext(ctx)
+	limit := chatTopK(llm.Config)
+	fields := []string{"id", "title", "abstract", "journal", "doi", "published_date"}
+	if persona == PersonaClinician {
+		if limit < clinicianMinTopK {
+			limit = clinicianMinTopK
+		}
+		fields = append(fields, "publication_types")
 	}
 
-	searchResult, err := llm.QdrantClient.Search(ctx, &qdrant.SearchPoints{
-		CollectionName: "medical_abstracts",
+	// Patients are better served by a plain-language health topic than a raw
+	// abstract; abstracts fill whatever slots the topics leave
+	var results []string
+	var sources []Source
+	if persona == PersonaPatient && llm.ConsumerHealth {
+		results, sources = llm.retrieveHealthTopics(ctx, query, vector, limit)
+		if len(results) >= limit {
+			return results, sources, nil
+		}
+		limit -= len(results)
+	}
+
+	// The reranker needs more candidates than end up in the prompt
+	candidates := limit
+	if llm.Reranker != nil {
+		candidates = rerank.Candidates(limit)
+	}
+
+	searchCtx, cancel, _ := budget.FromContext(ctx).Context(ctx, budget.StageSearch)
+	defer cancel()
+	searchResult, err := llm.QdrantClient.Search(withHybridSearch(searchCtx, llm.Config, query), &qdrant.SearchPoints{
+		CollectionName: config.ArticleCollection(),
 		Vector:         vector,
-		Limit:          1, // Fewer, more focused results for chat
+		Limit:          uint64(candidates), // Fewer, more focused results for chat
 		WithPayload: &qdrant.WithPayloadSelector{
 			SelectorOptions: &qdrant.WithPayloadSelector_Include{
 				Include: &qdrant.PayloadIncludeSelector{
-					Fields: []string{"title", "abstract", "journal"},
+					Fields: fields,
 				},
 			},
 		},
 	})
 	if err != nil {
-		return nil, err
+		if len(results) > 0 {
+			log.Printf("⚠️  Abstract search failed, answering from health topics: %v", err)
+			return results, sources, nil
+		}
+		return nil, nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
 	}
 
-	var results []string
-	for _, point := range searchResult.Result {
-		payload := point.Payload
-		abstract := safeGetString(payload, "abstract")
-		title := safeGetString(payload, "title")
-		journal := safeGetString(payload, "journal")
-
-		if abstract != "" {
-			results = append(results, fmt.Sprintf("Study: %s (%s) - %s", title, journal, abstract))
+	points, scale := llm.rerank(ctx, query, searchResult.Result, limit, searchScale(llm.Config, query))
+	for _, point := range points {
+		fields := point.Payload
+		abstract := payload.String(fields, "abstract")
+		title := payload.String(fields, "title")
+		journal := payload.String(fields, "journal")
+
+		if abstract == "" {
+			continue
+		}
+		if persona == PersonaClinician {
+			results = append(results, fmt.Sprintf("Study: %s (%s; %s; doi:%s) - %s", title, journal,
+				strings.Join(payload.Strings(fields, "publication_types"), ", "), payload.String(fields, "doi"), abstract))
+		} else {
+			results = append(results, fmt.Sprintf("Study: %s (%s) - %s", title, journal, data.NormalizeMedicalTerms(abstract)))
 		}
+		TraceFromContext(ctx).addPassage(query, payload.String(fields, "id"), title, point.Score)
+		sources = append(sources, Source{
+			ID:        payload.String(fields, "id"),
+			Title:     title,
+			Journal:   journal,
+			DOI:       payload.String(fields, "doi"),
+			Published: payload.String(fields, "published_date"),
+
+			Score:      point.Score,
+			ScoreScale: scale,
+			Relevance:  llm.Calibration.Label(scale, point.Score),
+		})
+	}
+	return results, sources, nil
+}
 
+// rerank orders points, whose scores are on scale, by cross-encoder
+// relevance to query and keeps the first limit. It returns the scale of
+// their scores afterwards. Points without an abstract are never used, so
+// they are not sent for scoring.
+func (llm *LLMMedicalChat) rerank(ctx context.Context, query string, points []*qdrant.ScoredPoint, limit int, scale string) ([]*qdrant.ScoredPoint, string) {
+	var usable []*qdrant.ScoredPoint
+	for _, point := range points {
+		if payload.String(point.Payload, "abstract") != "" {
+			usable = append(usable, point)
+		}
+	}
+	if len(usable) > limit && llm.Reranker != nil {
+		rerankCtx, cancel, ok := budget.FromContext(ctx).Context(ctx, budget.StageRerank)
+		if ok {
+			start := time.Now()
+			reranked, err := rerank.Points(rerankCtx, llm.Reranker, query, usable)
+			logging.Debugf("chat rerank of %d passages took %v", len(usable), time.Since(start))
+			if err == nil {
+				usable, scale = reranked, calibration.ScaleRerank
+			} else {
+				log.Printf("⚠️  Rerank failed, keeping cosine order: %v", err)
+			}
+		}
+		cancel()
+	}
+	if len(usable) > limit {
+		usable = usable[:limit]
 	}
-	return results, nil
+	return usable, scale
 }
 
-func (llm *LLMMedicalChat) EnhanceQueryForIntent(query string, intent string) string {
-	intentModifiers := map[string]string{
-		"symptom_inquiry": "symptoms clinical presentation signs",
-		"treatment_info":  "treatment therapy management clinical trial",
-		"prevention":      "prevention risk reduction prophylaxis",
-		"causes":          "causes etiology risk factors",
-		"diagnosis":       "diagnosis testing assessment criteria",
-		"risks":           "risks complications side effects",
-		"comparison":      "comparison differences versus",
-		"how_to":          "procedure steps process",
+// embedQuery embeds query steered towards intent, using the precomputed
+// intent vectors when available
+func (llm *LLMMedicalChat) embedQuery(ctx context.Context, query, intent string) ([]float32, error) {
+	start := time.Now()
+	// Brand names ("Tylenol") retrieve literature written about the ingredient,
+	// and lay terms ("heart attack") the literature using their synonyms
+	query = llm.Synonyms.Expand(data.ExpandDrugNames(query))
+	if llm.IntentVectors == nil {
+		enhanced := llm.EnhanceQueryForIntent(query, intent)
+		TraceFromContext(ctx).addQuery(enhanced, false)
+		vector, err := llm.embedWithin(ctx, enhanced)
+		logging.Debugf("query embedding (text modifier) took %v", time.Since(start))
+		return vector, err
 	}
 
-	modifier := intentModifiers[intent]
-	if modifier != "" {
-		return query + " " + modifier
+	TraceFromContext(ctx).addQuery(query, true)
+	vector, err := llm.embedWithin(ctx, query)
+	if err != nil {
+		return nil, err
 	}
-	return query
+	logging.Debugf("query embedding (precomputed intent) took %v", time.Since(start))
+	return llm.IntentVectors.Blend(vector, intent), nil
+}
+
+// embedWithin stops waiting for the embedding once the request's embedding
+// allotment is spent. The call itself runs on in the background, with the
+// request's trace but not its deadline; with a CachingEmbedder its result
+// is still cached.
+func (llm *LLMMedicalChat) embedWithin(ctx context.Context, text string) ([]float32, error) {
+	embedCtx, cancel, _ := budget.FromContext(ctx).Context(ctx, budget.StageEmbedding)
+	defer cancel()
+
+	type result struct {
+		vector []float32
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		vector, err := EmbedContext(context.WithoutCancel(ctx), llm.Embedder, text)
+		done <- result{vector, err}
+	}()
+	select {
+	case r := <-done:
+		return r.vector, r.err
+	case <-embedCtx.Done():
+		return nil, fmt.Errorf("%w: query embedding: %w", apperrors.ErrEmbeddingUnavailable, embedCtx.Err())
+	}
+}
+
+func (llm *LLMMedicalChat) EnhanceQueryForIntent(query string, intent string) string {
+	return enhanceQuery(query, intent)
 }
 
 // GenerateLocalResponse creates responses without external AI
-func (llm *LLMMedicalChat) GenerateLocalResponse(userMessage string, medicalData []string, intent string) string {
+func (llm *LLMMedicalChat) GenerateLocalResponse(userMessage string, medicalData []string, intent string, loc *locale.Localizer, persona Persona) string {
 	// Enhanced local response generation with medical data
 	if len(medicalData) > 0 {
-		return llm.GenerateDataDrivenResponse(userMessage, medicalData, intent)
+		return llm.GenerateDataDrivenResponse(userMessage, medicalData, intent, loc, persona)
 	}
 
 	// Fallback responses
 	switch intent {
 	case "symptom_inquiry":
-		return "I understand you're asking about symptoms. Symptoms can provide important clues about health, but they need to be evaluated in context. Have you discussed these symptoms with a healthcare provider?"
+		return loc.T(locale.FallbackSymptom)
 	case "treatment_info":
-		return "Treatment approaches vary based on many factors including the specific condition, its severity, and individual health considerations. Medical research emphasizes personalized treatment plans developed with healthcare professionals."
+		return loc.T(locale.FallbackTreatment)
 	case "prevention":
-		return "Prevention strategies are most effective when tailored to individual risk factors. Research shows that lifestyle modifications, regular screenings, and proactive health management can significantly reduce risks for many conditions."
+		return loc.T(locale.FallbackPrevention)
 	default:
-		return "I'd be happy to help you with health information. For personalized medical advice, consulting with a healthcare professional who can consider your specific situation would be most appropriate."
+		return loc.T(locale.FallbackDefault)
 	}
 }
 
 // GenerateDataDrivenResponse creates responses based on actual medical data
-func (llm *LLMMedicalChat) GenerateDataDrivenResponse(userMessage string, medicalData []string, intent string) string {
+func (llm *LLMMedicalChat) GenerateDataDrivenResponse(userMessage string, medicalData []string, intent string, loc *locale.Localizer, persona Persona) string {
 	var response strings.Builder
 
-	response.WriteString("Based on medical research, ")
+	response.WriteString(loc.T(locale.ResearchIntro))
 
 	switch intent {
 	case "symptom_inquiry":
-		response.WriteString("here's what I found about those symptoms:\n\n")
+		response.WriteString(loc.T(locale.ResearchIntroSymptom))
 	case "treatment_info":
-		response.WriteString("here are some treatment approaches discussed in recent studies:\n\n")
+		response.WriteString(loc.T(locale.ResearchIntroTreatment))
 	case "prevention":
-		response.WriteString("these prevention strategies show promise according to research:\n\n")
+		response.WriteString(loc.T(locale.ResearchIntroPrevention))
 	case "causes":
-		response.WriteString("research has identified these potential causes and risk factors:\n\n")
+		response.WriteString(loc.T(locale.ResearchIntroCauses))
 	default:
-		response.WriteString("here's relevant information from medical literature:\n\n")
+		response.WriteString(loc.T(locale.ResearchIntroDefault))
 	}
+	response.WriteString("\n\n")
 
 	// Include top medical findings
 	for i, data := range medicalData {
@@ -210,7 +468,12 @@ func (llm *LLMMedicalChat) GenerateDataDrivenResponse(userMessage string, medica
 		response.WriteString(fmt.Sprintf("• %s\n", llm.SummarizeMedicalFinding(data)))
 	}
 
-	response.WriteString("\n💡 This information comes from published medical research. For personalized advice, please consult with a healthcare professional.")
+	response.WriteString("\n")
+	if persona == PersonaClinician {
+		response.WriteString(loc.T(locale.DisclaimerResearchBrief))
+	} else {
+		response.WriteString(loc.T(locale.DisclaimerResearch))
+	}
 
 	return response.String()
 }
@@ -234,61 +497,3 @@ func (llm *LLMMedicalChat) SummarizeMedicalFinding(data string) string {
 	}
 	return data
 }
-
-func (llm *LLMMedicalChat) GenerateHelpfulSuggestions(intent string) []string {
-	suggestions := []string{
-		"Consult with a healthcare professional for personalized advice",
-		"Keep track of your questions for your next medical appointment",
-	}
-
-	// Intent-specific suggestions
-	switch intent {
-	case "symptom_inquiry":
-		suggestions = append(suggestions,
-			"Consider noting when symptoms occur and what makes them better or worse",
-			"Research shows that symptom diaries can be very helpful for medical consultations",
-		)
-	case "treatment_info":
-		suggestions = append(suggestions,
-			"Discuss potential treatment options and their benefits/risks with your doctor",
-			"Ask about both traditional and newer approaches that might be available",
-		)
-	case "prevention":
-		suggestions = append(suggestions,
-			"Consider working with a healthcare provider on a personalized prevention plan",
-			"Ask about screening tests that might be appropriate for your situation",
-		)
-	case "causes":
-		suggestions = append(suggestions,
-			"Discuss your specific risk factors with a healthcare provider",
-			"Ask about lifestyle modifications that might address underlying causes",
-		)
-	case "diagnosis":
-		suggestions = append(suggestions,
-			"Prepare a list of your symptoms and concerns before your appointment",
-			"Ask your doctor about the diagnostic process and what to expect",
-		)
-	case "risks":
-		suggestions = append(suggestions,
-			"Discuss your personal risk profile with a healthcare provider",
-			"Ask about risk reduction strategies tailored to your situation",
-		)
-	case "comparison":
-		suggestions = append(suggestions,
-			"Discuss the pros and cons of different options with your doctor",
-			"Consider which factors are most important for your specific situation",
-		)
-	case "how_to":
-		suggestions = append(suggestions,
-			"Ask a healthcare professional to demonstrate the procedure",
-			"Request written instructions or resources for proper technique",
-		)
-	case "general_info":
-		suggestions = append(suggestions,
-			"Ask your doctor for reliable resources to learn more",
-			"Consider discussing this information at your next check-up",
-		)
-	}
-
-	return suggestions
-}
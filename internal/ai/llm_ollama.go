@@ -0,0 +1,203 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/tracing"
+)
+
+// Defaults of the Ollama provider
+const (
+	DefaultOllamaHost  = "http://localhost:11434"
+	DefaultOllamaModel = "llama3.1:8b"
+)
+
+// OllamaProvider runs completions on a local Ollama server, so no query
+// leaves the network
+type OllamaProvider struct {
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewOllamaProvider creates an Ollama provider. Empty arguments select
+// DefaultOllamaHost and DefaultOllamaModel.
+func NewOllamaProvider(host, model string) *OllamaProvider {
+	if host == "" {
+		host = DefaultOllamaHost
+	}
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	return &OllamaProvider{
+		BaseURL: strings.TrimRight(host, "/"),
+		Model:   model,
+		// Local models on modest hardware answer slower than hosted ones
+		HTTPClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (p *OllamaProvider) Name() string         { return ProviderOllama }
+func (p *OllamaProvider) DefaultModel() string { return p.Model }
+
+// CloseIdleConnections closes the client's idle keep-alive connections
+func (p *OllamaProvider) CloseIdleConnections() { p.HTTPClient.CloseIdleConnections() }
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+		NumPredict  int     `json:"num_predict,omitempty"`
+	} `json:"options"`
+}
+
+type ollamaResponse struct {
+	Model   string        `json:"model"`
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"` // set on the last line of a stream
+	// Token counts of the prompt and the reply
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, in CompletionRequest) (*Completion, error) {
+	resp, err := p.post(ctx, in, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if response.Message.Content == "" {
+		return nil, fmt.Errorf("%w: empty response from ollama", apperrors.ErrLLMUnavailable)
+	}
+	return &Completion{
+		Content:          response.Message.Content,
+		Model:            response.Model,
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+	}, nil
+}
+
+// Stream implements StreamingProvider. Ollama streams one JSON object per
+// line; the last one is marked done and carries the token counts.
+func (p *OllamaProvider) Stream(ctx context.Context, in CompletionRequest, onDelta func(string)) (*Completion, error) {
+	resp, err := p.post(ctx, in, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{}
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line ollamaResponse
+		if err := decoder.Decode(&line); err != nil {
+			return nil, fmt.Errorf("%w: ollama stream interrupted: %w", apperrors.ErrLLMUnavailable, err)
+		}
+		if line.Error != "" {
+			return nil, fmt.Errorf("%w: ollama error: %s", apperrors.ErrLLMUnavailable, line.Error)
+		}
+		if delta := line.Message.Content; delta != "" {
+			content.WriteString(delta)
+			onDelta(delta)
+		}
+		if line.Done {
+			completion.Model, completion.PromptTokens, completion.CompletionTokens = line.Model, line.PromptEvalCount, line.EvalCount
+			break
+		}
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("%w: empty response from ollama", apperrors.ErrLLMUnavailable)
+	}
+	completion.Content = content.String()
+	return completion, nil
+}
+
+// post sends a chat request and returns the response once its status is OK
+func (p *OllamaProvider) post(ctx context.Context, in CompletionRequest, stream bool) (*http.Response, error) {
+	request := ollamaRequest{Model: in.Model, Stream: stream}
+	for _, message := range in.Messages {
+		request.Messages = append(request.Messages, ollamaMessage{Role: message.Role, Content: message.Content})
+	}
+	if in.Format != nil {
+		request.Format = "json"
+	}
+	request.Options.Temperature = in.Temperature
+	request.Options.NumPredict = in.MaxTokens
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ollama request failed: %w", apperrors.ErrLLMUnavailable, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var response ollamaResponse
+		if json.NewDecoder(resp.Body).Decode(&response) == nil && response.Error != "" {
+			return nil, fmt.Errorf("%w: ollama returned status %d: %s", apperrors.ErrLLMUnavailable, resp.StatusCode, response.Error)
+		}
+		return nil, fmt.Errorf("%w: ollama returned status %d", apperrors.ErrLLMUnavailable, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// ListModels returns the models pulled on the Ollama server
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrLLMUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: ollama returned status %d", apperrors.ErrLLMUnavailable, resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	var models []string
+	for _, model := range tags.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
@@ -0,0 +1,307 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/tracing"
+)
+
+// Default models of the OpenAI-compatible providers
+const (
+	DefaultOpenRouterModel = "mistralai/mistral-7b-instruct"
+	DefaultOpenAIModel     = "gpt-4o-mini"
+)
+
+// DefaultVLLMBaseURL is where the vllm provider expects vLLM's OpenAI
+// compatible server. Port 8000, vLLM's own default, is taken by the
+// embedding service.
+const DefaultVLLMBaseURL = "http://localhost:8001/v1"
+
+// OpenAIProvider speaks the OpenAI chat completions API, which OpenRouter.ai
+// and many self-hosted servers offer too
+type OpenAIProvider struct {
+	ProviderName string
+	APIKey       string
+	BaseURL      string
+	Model        string
+	Headers      map[string]string // sent with every request
+	HTTPClient   *http.Client
+}
+
+// NewOpenRouterProvider creates an OpenRouter.ai provider. An empty model
+// selects DefaultOpenRouterModel.
+func NewOpenRouterProvider(apiKey, model string) *OpenAIProvider {
+	if model == "" {
+		model = DefaultOpenRouterModel
+	}
+	return &OpenAIProvider{
+		ProviderName: ProviderOpenRouter,
+		APIKey:       apiKey,
+		BaseURL:      "https://openrouter.ai/api/v1",
+		Model:        model,
+		Headers: map[string]string{
+			"HTTP-Referer": "https://medical-chat-app.com",
+			"X-Title":      "Medical AI Assistant",
+		},
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewOpenAIProvider creates an OpenAI provider. An empty model selects
+// DefaultOpenAIModel.
+func NewOpenAIProvider(apiKey, model string) *OpenAIProvider {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIProvider{
+		ProviderName: ProviderOpenAI,
+		APIKey:       apiKey,
+		BaseURL:      "https://api.openai.com/v1",
+		Model:        model,
+		HTTPClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewVLLMProvider creates a provider of a model served by vLLM, or any
+// other OpenAI compatible server, on the local network. An empty baseURL
+// selects DefaultVLLMBaseURL; apiKey is only sent when set.
+func NewVLLMProvider(baseURL, model, apiKey string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = DefaultVLLMBaseURL
+	}
+	return &OpenAIProvider{
+		ProviderName: ProviderVLLM,
+		APIKey:       apiKey,
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Model:        model,
+		// Local models on modest hardware answer slower than hosted ones
+		HTTPClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (p *OpenAIProvider) Name() string         { return p.ProviderName }
+func (p *OpenAIProvider) DefaultModel() string { return p.Model }
+
+// CloseIdleConnections closes the client's idle keep-alive connections
+func (p *OpenAIProvider) CloseIdleConnections() { p.HTTPClient.CloseIdleConnections() }
+
+// OpenRouterRequest represents the request to OpenRouter.ai
+type OpenRouterRequest struct {
+	Model          string            `json:"model"`
+	Messages       []ChatMessage     `json:"messages"`
+	Temperature    float64           `json:"temperature"`
+	MaxTokens      int               `json:"max_tokens"`
+	Stream         bool              `json:"stream"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	ResponseFormat *ResponseFormat   `json:"response_format,omitempty"`
+	StreamOptions  *StreamOptions    `json:"stream_options,omitempty"`
+}
+
+// StreamOptions asks a streamed reply to end with the token usage
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenRouterResponse represents the response from OpenRouter.ai
+type OpenRouterResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, in CompletionRequest) (*Completion, error) {
+	resp, err := p.post(ctx, in, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response OpenRouterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if response.Error.Message != "" {
+		return nil, fmt.Errorf("%w: %s error: %s", apperrors.ErrLLMUnavailable, p.ProviderName, response.Error.Message)
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("%w: empty response from %s", apperrors.ErrLLMUnavailable, p.ProviderName)
+	}
+	return &Completion{
+		Content:          response.Choices[0].Message.Content,
+		Model:            response.Model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+	}, nil
+}
+
+// openAIStreamChunk is one server-sent event of a streamed completion. The
+// last one carries the usage and no choices.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Model string `json:"model"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Stream implements StreamingProvider over server-sent events
+func (p *OpenAIProvider) Stream(ctx context.Context, in CompletionRequest, onDelta func(string)) (*Completion, error) {
+	resp, err := p.post(ctx, in, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Lines starting with ':' are keep-alive comments
+		payload, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+		if payload == "[DONE]" {
+			break
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		if chunk.Error.Message != "" {
+			return nil, fmt.Errorf("%w: %s error: %s", apperrors.ErrLLMUnavailable, p.ProviderName, chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			completion.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			completion.PromptTokens, completion.CompletionTokens = chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices[:min(len(chunk.Choices), 1)] {
+			if delta := choice.Delta.Content; delta != "" {
+				content.WriteString(delta)
+				onDelta(delta)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s stream interrupted: %w", apperrors.ErrLLMUnavailable, p.ProviderName, err)
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("%w: empty response from %s", apperrors.ErrLLMUnavailable, p.ProviderName)
+	}
+	completion.Content = content.String()
+	return completion, nil
+}
+
+// post sends a chat completion request and returns the response once its
+// status is OK
+func (p *OpenAIProvider) post(ctx context.Context, in CompletionRequest, stream bool) (*http.Response, error) {
+	request := OpenRouterRequest{
+		Model:          in.Model,
+		Messages:       in.Messages,
+		Temperature:    in.Temperature,
+		MaxTokens:      in.MaxTokens,
+		Stream:         stream,
+		Headers:        p.Headers,
+		ResponseFormat: in.Format,
+	}
+	if stream {
+		request.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req)
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s request failed: %w", apperrors.ErrLLMUnavailable, p.ProviderName, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s returned status %d", apperrors.ErrRateLimited, p.ProviderName, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s returned status %d", apperrors.ErrLLMUnavailable, p.ProviderName, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// authorize adds the API key, which local servers may not need
+func (p *OpenAIProvider) authorize(req *http.Request) {
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+}
+
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.BaseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	p.authorize(req)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrLLMUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned status %d", apperrors.ErrLLMUnavailable, p.ProviderName, resp.StatusCode)
+	}
+
+	var modelsResponse struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResponse); err != nil {
+		return nil, err
+	}
+	var models []string
+	for _, model := range modelsResponse.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// LLMProvider sends chat completions to one LLM service. LLMClient puts
+// the limiter, tracing, metrics and failover around it.
+type LLMProvider interface {
+	// Name identifies the provider in logs, metrics and answer records
+	Name() string
+	// DefaultModel is the model used when a request names none
+	DefaultModel() string
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// StreamingProvider is implemented by providers that can return a reply as
+// it is generated. Stream calls onDelta with each piece of the content, in
+// order, and returns the whole completion as Complete would.
+type StreamingProvider interface {
+	LLMProvider
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error)
+}
+
+// CompletionRequest is one chat completion
+type CompletionRequest struct {
+	Model       string
+	Messages    []ChatMessage
+	Temperature float64
+	MaxTokens   int
+	Format      *ResponseFormat // optional, asks for a JSON object reply
+}
+
+// Completion is a provider's reply. Token counts are zero when the
+// provider does not report usage.
+type Completion struct {
+	Content          string
+	Model            string // as reported by the provider
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Supported LLM_PROVIDER values
+const (
+	ProviderOpenRouter = "openrouter"
+	ProviderOpenAI     = "openai"
+	ProviderAnthropic  = "anthropic"
+	ProviderOllama     = "ollama"
+	ProviderVLLM       = "vllm"
+)
+
+// DefaultAttemptTimeout bounds each provider attempt when a fallback
+// provider is configured, so a hung primary leaves time for the fallback
+const DefaultAttemptTimeout = 12 * time.Second
+
+// ProvidersFromEnv returns the provider named by LLM_PROVIDER (default
+// openrouter) followed by LLM_FALLBACK_PROVIDER, if set. Each is configured
+// from its own variables:
+//
+//	openrouter  OPENROUTER_API_KEY, OPENROUTER_MODEL
+//	openai      OPENAI_API_KEY, OPENAI_MODEL, OPENAI_BASE_URL
+//	anthropic   ANTHROPIC_API_KEY, ANTHROPIC_MODEL
+//	ollama      OLLAMA_HOST, OLLAMA_MODEL
+//	vllm        VLLM_BASE_URL, VLLM_MODEL, VLLM_API_KEY (optional)
+//
+// ollama and vllm run on the local network, so chat can work air-gapped.
+func ProvidersFromEnv() ([]LLMProvider, error) {
+	primary := strings.TrimSpace(os.Getenv("LLM_PROVIDER"))
+	if primary == "" {
+		primary = ProviderOpenRouter
+	}
+	names := []string{primary}
+	if fallback := strings.TrimSpace(os.Getenv("LLM_FALLBACK_PROVIDER")); fallback != "" {
+		if fallback == primary {
+			return nil, fmt.Errorf("LLM_FALLBACK_PROVIDER must differ from LLM_PROVIDER (%s)", primary)
+		}
+		names = append(names, fallback)
+	}
+	providers := make([]LLMProvider, len(names))
+	for i, name := range names {
+		provider, err := providerFromEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		providers[i] = provider
+	}
+	return providers, nil
+}
+
+// AttemptTimeoutFromEnv returns LLM_ATTEMPT_TIMEOUT (e.g. "10s"), or
+// DefaultAttemptTimeout
+func AttemptTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("LLM_ATTEMPT_TIMEOUT")
+	if value == "" {
+		return DefaultAttemptTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("LLM_ATTEMPT_TIMEOUT must be a positive duration, got %q", value)
+	}
+	return timeout, nil
+}
+
+func providerFromEnv(name string) (LLMProvider, error) {
+	switch name {
+	case ProviderOpenRouter:
+		apiKey := os.Getenv("OPENROUTER_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("OPENROUTER_API_KEY environment variable is required for the openrouter provider")
+		}
+		return NewOpenRouterProvider(apiKey, os.Getenv("OPENROUTER_MODEL")), nil
+	case ProviderOpenAI:
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY environment variable is required for the openai provider")
+		}
+		provider := NewOpenAIProvider(apiKey, os.Getenv("OPENAI_MODEL"))
+		if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+			provider.BaseURL = strings.TrimRight(baseURL, "/")
+		}
+		return provider, nil
+	case ProviderAnthropic:
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable is required for the anthropic provider")
+		}
+		return NewAnthropicProvider(apiKey, os.Getenv("ANTHROPIC_MODEL")), nil
+	case ProviderOllama:
+		return NewOllamaProvider(os.Getenv("OLLAMA_HOST"), os.Getenv("OLLAMA_MODEL")), nil
+	case ProviderVLLM:
+		model := os.Getenv("VLLM_MODEL")
+		if model == "" {
+			return nil, fmt.Errorf("VLLM_MODEL environment variable is required for the vllm provider")
+		}
+		return NewVLLMProvider(os.Getenv("VLLM_BASE_URL"), model, os.Getenv("VLLM_API_KEY")), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q: use openrouter, openai, anthropic, ollama or vllm", name)
+	}
+}
@@ -1,7 +1,13 @@
 package ai
 
 import (
-	"MedAtlasAIServer/internal/embeddingClient"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/calibration"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/internal/suggestions"
+	"MedAtlasAIServer/pkg/data"
+	"MedAtlasAIServer/pkg/search"
 	"context"
 	"fmt"
 	"math/rand"
@@ -12,8 +18,10 @@ import (
 )
 
 type MedicalChat struct {
-	Embedder     *embeddingClient.Client
-	QdrantClient qdrant.PointsClient
+	Embedder     Embedder
+	QdrantClient Searcher
+	Config       *config.Store       // optional, supplies the reloadable chat top-k
+	Suggestions  *suggestions.Engine // optional, supplies the suggestion templates
 }
 
 type ChatMessage struct {
@@ -25,9 +33,37 @@ type ChatMessage struct {
 type ChatResponse struct {
 	Response    string   `json:"response"`
 	Suggestions []string `json:"suggestions,omitempty"`
+	Sources     []Source `json:"sources,omitempty"`
+
+	// Partial is set when retrieval finished but the answer could not be
+	// generated in time; Response then only introduces Sources
+	Partial bool `json:"partial,omitempty"`
+
+	// Consensus is set for high-confidence answers and reports how far the
+	// sampled answers agreed
+	Consensus *ConsensusReport `json:"consensus,omitempty"`
+
+	// Confidence is how well Sources support the answer
+	Confidence *Confidence `json:"confidence,omitempty"`
+}
+
+// Source is a retrieved study an answer draws on
+type Source struct {
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title"`
+	Journal string `json:"journal,omitempty"`
+	DOI     string `json:"doi,omitempty"`
+	// Published is the publication date as 2006-01-02, when known
+	Published string `json:"published,omitempty"`
+
+	// Score is the retrieval score, on ScoreScale (see calibration.Scale*);
+	// Relevance is its calibrated label: high, moderate or weak
+	Score      float32 `json:"score,omitempty"`
+	ScoreScale string  `json:"score_scale,omitempty"`
+	Relevance  string  `json:"relevance,omitempty"`
 }
 
-func NewMedicalChat(embedder *embeddingClient.Client, qdrantClient qdrant.PointsClient) *MedicalChat {
+func NewMedicalChat(embedder Embedder, qdrantClient Searcher) *MedicalChat {
 	return &MedicalChat{
 		Embedder:     embedder,
 		QdrantClient: qdrantClient,
@@ -80,15 +116,15 @@ func (mc *MedicalChat) GenerateAIResponse(userMessage string, searchResults []st
 func (mc *MedicalChat) SearchMedicalKnowledge(ctx context.Context, query string, intent string) ([]string, error) {
 	enhancedQuery := mc.EnhanceQueryForIntent(query, intent)
 
-	vector, err := mc.Embedder.GetEmbedding(enhancedQuery)
+	vector, err := EmbedContext(ctx, mc.Embedder, enhancedQuery)
 	if err != nil {
 		return nil, err
 	}
 
-	searchResult, err := mc.QdrantClient.Search(ctx, &qdrant.SearchPoints{
-		CollectionName: "medical_abstracts",
+	searchResult, err := mc.QdrantClient.Search(withHybridSearch(ctx, mc.Config, query), &qdrant.SearchPoints{
+		CollectionName: config.ArticleCollection(),
 		Vector:         vector,
-		Limit:          1, // Fewer, more focused results for chat
+		Limit:          uint64(chatTopK(mc.Config)), // Fewer, more focused results for chat
 		WithPayload: &qdrant.WithPayloadSelector{
 			SelectorOptions: &qdrant.WithPayloadSelector_Include{
 				Include: &qdrant.PayloadIncludeSelector{
@@ -98,17 +134,22 @@ func (mc *MedicalChat) SearchMedicalKnowledge(ctx context.Context, query string,
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
 	}
 
+	// Abbreviations are stored as written and spelled out for patients only
+	expand := PersonaFromContext(ctx) == PersonaPatient
 	var results []string
 	for _, point := range searchResult.Result {
-		payload := point.Payload
-		abstract := safeGetString(payload, "abstract")
-		title := safeGetString(payload, "title")
-		journal := safeGetString(payload, "journal")
+		fields := point.Payload
+		abstract := payload.String(fields, "abstract")
+		title := payload.String(fields, "title")
+		journal := payload.String(fields, "journal")
 
 		if abstract != "" {
+			if expand {
+				abstract = data.NormalizeMedicalTerms(abstract)
+			}
 			results = append(results, fmt.Sprintf("Study: %s (%s) - %s", title, journal, abstract))
 		}
 
@@ -117,22 +158,7 @@ func (mc *MedicalChat) SearchMedicalKnowledge(ctx context.Context, query string,
 }
 
 func (mc *MedicalChat) EnhanceQueryForIntent(query string, intent string) string {
-	intentModifiers := map[string]string{
-		"symptom_inquiry": "symptoms clinical presentation signs",
-		"treatment_info":  "treatment therapy management clinical trial",
-		"prevention":      "prevention risk reduction prophylaxis",
-		"causes":          "causes etiology risk factors",
-		"diagnosis":       "diagnosis testing assessment criteria",
-		"risks":           "risks complications side effects",
-		"comparison":      "comparison differences versus",
-		"how_to":          "procedure steps process",
-	}
-
-	modifier := intentModifiers[intent]
-	if modifier != "" {
-		return query + " " + modifier
-	}
-	return query
+	return enhanceQuery(query, intent)
 }
 
 func (mc *MedicalChat) UnderstandIntent(message string, history []ChatMessage) string {
@@ -164,10 +190,10 @@ func (mc *MedicalChat) UnderstandIntent(message string, history []ChatMessage) s
 }
 
 func (mc *MedicalChat) ExtractRelevantHistory(history []ChatMessage) []ChatMessage {
-	if len(history) <= 3 {
+	if len(history) <= RecentMessages {
 		return history
 	}
-	return history[len(history)-3:]
+	return history[len(history)-RecentMessages:]
 }
 
 func containsAny(s string, substrs []string) bool {
@@ -179,13 +205,43 @@ func containsAny(s string, substrs []string) bool {
 	return false
 }
 
-func safeGetString(payload map[string]*qdrant.Value, key string) string {
-	if value, exists := payload[key]; exists && value != nil {
-		return value.GetStringValue()
+// chatTopK returns the configured number of passages to retrieve for chat
+// withHybridSearch asks a hybrid searcher to match the terms of query
+// alongside the vector when the configuration enables hybrid search
+func withHybridSearch(ctx context.Context, store *config.Store, query string) context.Context {
+	if store == nil || !store.Current().HybridSearch {
+		return ctx
 	}
-	return ""
+	return search.WithKeywords(ctx, query)
+}
+
+// searchScale is the scale of the scores of a search made with
+// withHybridSearch: searches fused with keyword hits are scored by rank
+func searchScale(store *config.Store, query string) string {
+	if store == nil || !store.Current().HybridSearch || len(search.Terms(query)) == 0 {
+		return calibration.ScaleCosine
+	}
+	return calibration.ScaleRRF
+}
+
+func chatTopK(store *config.Store) int {
+	if store == nil {
+		return config.DefaultTunables().ChatTopK
+	}
+	return store.Current().ChatTopK
+}
+
+// chatTimeouts returns the configured chat deadline and stage timeouts
+func chatTimeouts(store *config.Store) config.Timeouts {
+	if store == nil {
+		return config.DefaultTunables().Timeouts
+	}
+	return store.Current().Timeouts
 }
 
+// clinicianMinTopK is the minimum number of studies retrieved for clinicians
+const clinicianMinTopK = 3
+
 func (mc *MedicalChat) GenerateSymptomResponse(userMessage string, results []string) string {
 	if len(results) == 0 {
 		return "I don't have specific information about those symptoms yet. Could you describe them in more detail?"
@@ -235,61 +291,7 @@ func (mc *MedicalChat) GenerateTreatmentResponse(userMessage string, results []s
 }
 
 func (mc *MedicalChat) GenerateHelpfulSuggestions(userMessage string, intent string, results []string) []string {
-	suggestions := []string{
-		"Consult with a healthcare professional for personalized advice",
-		"Keep track of your questions for your next medical appointment",
-	}
-
-	// Intent-specific suggestions
-	switch intent {
-	case "symptom_inquiry":
-		suggestions = append(suggestions,
-			"Consider noting when symptoms occur and what makes them better or worse",
-			"Research shows that symptom diaries can be very helpful for medical consultations",
-		)
-	case "treatment_info":
-		suggestions = append(suggestions,
-			"Discuss potential treatment options and their benefits/risks with your doctor",
-			"Ask about both traditional and newer approaches that might be available",
-		)
-	case "prevention":
-		suggestions = append(suggestions,
-			"Consider working with a healthcare provider on a personalized prevention plan",
-			"Ask about screening tests that might be appropriate for your situation",
-		)
-	case "causes":
-		suggestions = append(suggestions,
-			"Discuss your specific risk factors with a healthcare provider",
-			"Ask about lifestyle modifications that might address underlying causes",
-		)
-	case "diagnosis":
-		suggestions = append(suggestions,
-			"Prepare a list of your symptoms and concerns before your appointment",
-			"Ask your doctor about the diagnostic process and what to expect",
-		)
-	case "risks":
-		suggestions = append(suggestions,
-			"Discuss your personal risk profile with a healthcare provider",
-			"Ask about risk reduction strategies tailored to your situation",
-		)
-	case "comparison":
-		suggestions = append(suggestions,
-			"Discuss the pros and cons of different options with your doctor",
-			"Consider which factors are most important for your specific situation",
-		)
-	case "how_to":
-		suggestions = append(suggestions,
-			"Ask a healthcare professional to demonstrate the procedure",
-			"Request written instructions or resources for proper technique",
-		)
-	case "general_info":
-		suggestions = append(suggestions,
-			"Ask your doctor for reliable resources to learn more",
-			"Consider discussing this information at your next check-up",
-		)
-	}
-
-	return suggestions
+	return mc.Suggestions.Suggest(intent, userMessage)
 }
 
 func (mc *MedicalChat) GenerateFallbackResponse(userMessage string, intent string) *ChatResponse {
@@ -322,15 +324,10 @@ func (mc *MedicalChat) GenerateFallbackResponse(userMessage string, intent strin
 	}
 }
 
+// extractKeyInfo returns the first sentence of text, at most maxLength characters
 func extractKeyInfo(text string, maxLength int) string {
-	sentences := strings.Split(text, ".")
-	if len(sentences) > 0 {
-		return strings.TrimSpace(sentences[0])
-	}
-	if len(text) > maxLength {
-		return text[:maxLength] + "..."
-	}
-	return text
+	firstSentence, _, _ := strings.Cut(text, ".")
+	return data.TruncateText(strings.TrimSpace(firstSentence), maxLength)
 }
 
 func extractTreatmentInfo(text string) string {
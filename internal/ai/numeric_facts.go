@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"MedAtlasAIServer/pkg/data"
+	"fmt"
+)
+
+// maxPromptFacts caps the structured facts added to a prompt
+const maxPromptFacts = 15
+
+// SourcedFact is a numeric claim tied to the 1-based index of the passage it came from
+type SourcedFact struct {
+	Source int               `json:"source"`
+	Claim  data.NumericClaim `json:"claim"`
+}
+
+func (f SourcedFact) String() string {
+	return fmt.Sprintf("[%d] %s (\"%s\")", f.Source, f.Claim.String(), f.Claim.Text)
+}
+
+// ExtractSourcedFacts pulls numeric claims out of retrieved passages so the
+// model can quote numbers from a structured list instead of recalling them
+func ExtractSourcedFacts(passages []string) []SourcedFact {
+	var facts []SourcedFact
+	for i, passage := range passages {
+		for _, claim := range data.ExtractNumericClaims(passage) {
+			facts = append(facts, SourcedFact{Source: i + 1, Claim: claim})
+			if len(facts) >= maxPromptFacts {
+				return facts
+			}
+		}
+	}
+	return facts
+}
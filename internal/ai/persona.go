@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Persona selects how answers are pitched: plain language for patients,
+// technical detail for clinicians
+type Persona string
+
+const (
+	PersonaPatient   Persona = "patient"
+	PersonaClinician Persona = "clinician"
+)
+
+// ParsePersona validates a request value; empty means patient
+func ParsePersona(s string) (Persona, error) {
+	switch Persona(strings.ToLower(strings.TrimSpace(s))) {
+	case "", PersonaPatient:
+		return PersonaPatient, nil
+	case PersonaClinician:
+		return PersonaClinician, nil
+	default:
+		return "", fmt.Errorf("unknown persona %q (expected patient or clinician)", s)
+	}
+}
+
+type personaKey struct{}
+
+// WithPersona attaches the request persona to ctx
+func WithPersona(ctx context.Context, p Persona) context.Context {
+	return context.WithValue(ctx, personaKey{}, p)
+}
+
+// PersonaFromContext returns the request persona, defaulting to patient
+func PersonaFromContext(ctx context.Context) Persona {
+	if p, ok := ctx.Value(personaKey{}).(Persona); ok && p != "" {
+		return p
+	}
+	return PersonaPatient
+}
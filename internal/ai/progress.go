@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"context"
+	"strconv"
+
+	"MedAtlasAIServer/internal/locale"
+)
+
+// Stages ProcessMessage reports to a progress function, in order
+const (
+	StageSearching  = "searching"  // retrieving studies
+	StageFound      = "found"      // retrieval finished; Count is how many sources were found
+	StageGenerating = "generating" // the model is writing the answer
+)
+
+// Progress is one stage of answering a message
+type Progress struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"` // for display, in the request's language
+	Count   int    `json:"count,omitempty"`
+}
+
+type progressKey struct{}
+
+// WithProgress makes ProcessMessage pass each stage to onProgress as it
+// starts, so clients waiting on a long answer can show what is happening
+func WithProgress(ctx context.Context, onProgress func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, onProgress)
+}
+
+// reportProgress passes stage to the function set by WithProgress, if any
+func reportProgress(ctx context.Context, stage string, count int) {
+	onProgress, _ := ctx.Value(progressKey{}).(func(Progress))
+	if onProgress == nil {
+		return
+	}
+	loc := locale.FromContext(ctx)
+	progress := Progress{Stage: stage, Count: count}
+	switch {
+	case stage == StageSearching:
+		progress.Message = loc.T(locale.ProgressSearching)
+	case stage == StageFound && count == 0:
+		progress.Message = loc.T(locale.ProgressFoundNone)
+	case stage == StageFound && count == 1:
+		progress.Message = loc.T(locale.ProgressFoundOne)
+	case stage == StageFound:
+		progress.Message = loc.TData(locale.ProgressFound, map[string]string{"Count": strconv.Itoa(count)})
+	case stage == StageGenerating:
+		progress.Message = loc.T(locale.ProgressGenerating)
+	}
+	onProgress(progress)
+}
@@ -0,0 +1,165 @@
+package ai
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"MedAtlasAIServer/internal/config"
+)
+
+// tokenPieces splits text the way byte-pair tokenizers of the GPT family
+// pre-tokenize it: contractions, words with their leading space, runs of
+// digits, runs of symbols and runs of whitespace. Each piece becomes one or
+// more tokens.
+var tokenPieces = regexp.MustCompile(`'(?:s|t|re|ve|m|ll|d)| ?\pL+| ?\pN+| ?[^\s\pL\pN]+|\s+`)
+
+// messageOverheadTokens is what a chat message costs beyond its content
+// (role and separators)
+const messageOverheadTokens = 4
+
+// CountTokens estimates how many tokens text takes. Models use different
+// vocabularies, so the count is an estimate: words cost a token per four
+// bytes, digits a token per three, and symbols a token each. It errs high
+// on medical vocabulary, which is the safe side for budgeting.
+func CountTokens(text string) int {
+	tokens := 0
+	for _, piece := range tokenPieces.FindAllString(text, -1) {
+		if trimmed := strings.TrimPrefix(piece, " "); trimmed != "" {
+			piece = trimmed
+		}
+		r, _ := utf8.DecodeRuneInString(piece)
+		switch {
+		case unicode.IsSpace(r):
+			tokens++
+		case unicode.IsLetter(r):
+			tokens += (len(piece) + 3) / 4
+		case unicode.IsNumber(r):
+			tokens += (utf8.RuneCountInString(piece) + 2) / 3
+		default:
+			tokens += utf8.RuneCountInString(piece)
+		}
+	}
+	return tokens
+}
+
+// PromptBudgetReport is how a prompt was fitted into the context window
+type PromptBudgetReport struct {
+	LimitTokens         int  `json:"limit_tokens"`  // room for the prompt, system prompt included
+	PromptTokens        int  `json:"prompt_tokens"` // as estimated by CountTokens
+	HistoryLinesDropped int  `json:"history_lines_dropped,omitempty"`
+	PassagesTruncated   int  `json:"passages_truncated,omitempty"`
+	Truncated           bool `json:"truncated"` // anything was dropped or shortened
+}
+
+// historyShare is the most of the room left for history and findings that
+// the conversation history may take; the findings matter more
+const historyShare = 4
+
+// promptBudget returns the configured prompt budget, or the default one
+func (lc *LLMClient) promptBudget() config.PromptBudget {
+	if lc.Config == nil {
+		return config.DefaultTunables().PromptBudget
+	}
+	return lc.Config.Current().PromptBudget
+}
+
+// fitHistory drops the oldest lines of history, keeping its first line (the
+// "Previous conversation:" heading), until it takes at most room tokens.
+// When even the latest line does not fit, that line is shortened instead.
+// It returns the fitted history and how many lines were dropped.
+func fitHistory(history string, room int) (string, int) {
+	if history == "" || CountTokens(history) <= room {
+		return history, 0
+	}
+	lines := strings.Split(strings.TrimRight(history, "\n"), "\n")
+	heading, lines := lines[0], lines[1:]
+	room -= CountTokens(heading) + 1
+	kept := 0
+	for kept < len(lines) && CountTokens(lines[len(lines)-kept-1])+1 <= room {
+		room -= CountTokens(lines[len(lines)-kept-1]) + 1
+		kept++
+	}
+	recent := lines[len(lines)-kept:]
+	if kept == 0 && len(lines) > 0 && room > 0 {
+		recent = []string{truncateTokens(lines[len(lines)-1], room-1)}
+	}
+	if len(recent) == 0 {
+		return "", len(lines)
+	}
+	return heading + "\n" + strings.Join(recent, "\n") + "\n", len(lines) - len(recent)
+}
+
+// summaryHeading introduces the conversation summary in the prompt
+const summaryHeading = "Summary of earlier conversation: "
+
+// fitSummary formats summary as a line of the conversation context of at
+// most room tokens, shortening it if needed, or drops it when there is no
+// room for the heading
+func fitSummary(summary string, room int) string {
+	if summary == "" {
+		return ""
+	}
+	room -= CountTokens(summaryHeading) + 1
+	if room <= CountTokens(" …") {
+		return ""
+	}
+	if CountTokens(summary) > room {
+		summary = truncateTokens(summary, room)
+	}
+	return summaryHeading + summary + "\n"
+}
+
+// fitPassages shortens passages so that together they take at most room
+// tokens. Each gets an even share; passages shorter than theirs keep their
+// text and leave the rest to the others. It returns the fitted passages and
+// how many were shortened.
+func fitPassages(passages []string, room int) ([]string, int) {
+	fitted := slices.Clone(passages)
+	costs := make([]int, len(passages))
+	order := make([]int, len(passages))
+	for i, passage := range passages {
+		costs[i], order[i] = CountTokens(passage), i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return costs[a] - costs[b] })
+
+	truncated := 0
+	for n, i := range order {
+		share := max(room, 0) / (len(order) - n)
+		if costs[i] > share {
+			fitted[i] = truncateTokens(passages[i], share)
+			truncated++
+		}
+		room -= CountTokens(fitted[i])
+	}
+	return fitted, truncated
+}
+
+// truncateTokens shortens text to at most limit tokens, cutting after the
+// last whole sentence that fits, or after the last whole word when even the
+// first sentence does not, and marks the cut with an ellipsis
+func truncateTokens(text string, limit int) string {
+	const ellipsis = " …"
+	limit -= CountTokens(ellipsis)
+	cut := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if CountTokens(text[:loc[0]+1]) > limit {
+			break
+		}
+		cut = loc[0] + 1
+	}
+	if cut == 0 {
+		for i, r := range text {
+			if !unicode.IsSpace(r) {
+				continue
+			}
+			if CountTokens(text[:i]) > limit {
+				break
+			}
+			cut = i
+		}
+	}
+	return strings.TrimSpace(text[:cut]) + ellipsis
+}
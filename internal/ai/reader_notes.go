@@ -0,0 +1,17 @@
+package ai
+
+import "context"
+
+type readerNotesKey struct{}
+
+// WithReaderNotes attaches the user's own highlights and notes on the paper
+// they are asking about, so follow-up answers can refer to them
+func WithReaderNotes(ctx context.Context, notes []string) context.Context {
+	return context.WithValue(ctx, readerNotesKey{}, notes)
+}
+
+// ReaderNotesFromContext returns the notes attached with WithReaderNotes
+func ReaderNotesFromContext(ctx context.Context) []string {
+	notes, _ := ctx.Value(readerNotesKey{}).([]string)
+	return notes
+}
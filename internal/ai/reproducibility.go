@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"MedAtlasAIServer/pkg/data"
+)
+
+// PromptTemplateVersion identifies the prompts built in code, such as
+// buildMedicalPrompt. Bump it whenever their wording changes so answer
+// records tell the templates apart.
+const PromptTemplateVersion = "1"
+
+// PromptVersion identifies the prompt of a completion: the template version
+// and a hash of the configured system message, e.g. "1:3f9a0c2b71de"
+func PromptVersion(messages []ChatMessage) string {
+	system := ""
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = message.Content
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(system))
+	return PromptTemplateVersion + ":" + hex.EncodeToString(sum[:6])
+}
+
+// AnswerRecord is what is needed to audit a chat answer later: which index
+// it searched, the passages its prompt received with their scores, and how
+// each completion was generated. Together with the answer it is stored in
+// the chat transcripts.
+type AnswerRecord struct {
+	Collections []string        `json:"collections"` // the index searched, current tier first
+	Passages    []RecordPassage `json:"passages"`
+	Generations []TraceGenerate `json:"generations"` // model, temperature and prompt version of each completion
+}
+
+// RecordPassage is one retrieved passage of an answer
+type RecordPassage struct {
+	TracePassage
+	PointID uint64 `json:"point_id"` // the Qdrant point of the article
+}
+
+// NewAnswerRecord builds the record of the answer trace was filled in for.
+// It returns nil for a nil trace.
+func NewAnswerRecord(trace *ChatTrace, collections []string) *AnswerRecord {
+	if trace == nil {
+		return nil
+	}
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	record := &AnswerRecord{
+		Collections: slices.Clone(collections),
+		Passages:    make([]RecordPassage, len(trace.Passages)),
+		Generations: slices.Clone(trace.Generations),
+	}
+	for i, passage := range trace.Passages {
+		record.Passages[i] = RecordPassage{TracePassage: passage, PointID: data.PointID(passage.ID)}
+	}
+	return record
+}
@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"strings"
+	"unicode"
+)
+
+// smallTalkPhrases are whole messages that do not ask for medical information
+var smallTalkPhrases = []string{
+	"hi", "hello", "hey", "good morning", "good afternoon", "good evening",
+	"thanks", "thank you", "bye", "goodbye", "ok", "okay",
+	"who are you", "what are you", "what can you do", "how does this work", "help",
+}
+
+// IsSmallTalk reports whether a message is a greeting, thanks or a question
+// about the assistant itself rather than a substantive medical question. Only
+// whole messages match, so "hi, I have chest pain" is not small talk.
+func IsSmallTalk(message string) bool {
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return false
+	}
+	normalized := strings.Join(words, " ")
+	for _, phrase := range smallTalkPhrases {
+		if normalized == phrase {
+			return true
+		}
+	}
+	return false
+}
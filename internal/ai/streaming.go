@@ -0,0 +1,26 @@
+package ai
+
+import "context"
+
+// StreamingGenerator is a Generator that can pass the answer on as it is
+// generated (implemented by LLMClient)
+type StreamingGenerator interface {
+	Generator
+	GenerateResponseStream(ctx context.Context, req GenerationRequest, onDelta func(string)) (string, error)
+}
+
+type answerStreamKey struct{}
+
+// WithAnswerStream makes ProcessMessage pass the model's answer to onDelta
+// as it is generated. The streamed text is provisional: citations are
+// aligned and safety checks run on the whole answer afterwards, so callers
+// show the final response in its place.
+func WithAnswerStream(ctx context.Context, onDelta func(string)) context.Context {
+	return context.WithValue(ctx, answerStreamKey{}, onDelta)
+}
+
+// AnswerStreamFromContext returns the function set by WithAnswerStream, or nil
+func AnswerStreamFromContext(ctx context.Context) func(string) {
+	onDelta, _ := ctx.Value(answerStreamKey{}).(func(string))
+	return onDelta
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"MedAtlasAIServer/internal/logging"
+)
+
+// DefaultJSONRepairs is how many times a malformed structured reply is sent
+// back to the model for repair before the caller falls back
+const DefaultJSONRepairs = 2
+
+// errMalformedOutput marks structured replies that never passed validation
+var errMalformedOutput = errors.New("model output did not match the requested JSON schema")
+
+// ResponseFormat asks OpenRouter for a JSON object reply on models that support it
+type ResponseFormat struct {
+	Type string `json:"type"` // "json_object"
+}
+
+// jsonSchema is the subset of JSON Schema structured replies are checked
+// against: the keys that must be present and the JSON type of each key
+type jsonSchema struct {
+	Required []string
+	Types    map[string]string // "string", "number", "boolean", "array" or "object"
+}
+
+// validate extracts the JSON object from raw and checks it against the
+// schema. Models often wrap JSON in code fences or add a sentence around
+// it, so text outside the outermost braces is ignored.
+func (s jsonSchema) validate(raw string) (json.RawMessage, error) {
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("the reply contains no JSON object")
+	}
+	object := json.RawMessage(raw[start : end+1])
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(object, &fields); err != nil {
+		return nil, fmt.Errorf("the JSON object is invalid: %v", err)
+	}
+	for _, key := range s.Required {
+		value, ok := fields[key]
+		if !ok || string(value) == "null" {
+			return nil, fmt.Errorf("the key %q is missing", key)
+		}
+		if s.Types[key] == "string" && strings.TrimSpace(string(value)) == `""` {
+			return nil, fmt.Errorf("the key %q is empty", key)
+		}
+	}
+	for key, want := range s.Types {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if got := jsonType(value); got != want && got != "null" {
+			return nil, fmt.Errorf("the key %q must be a %s, not a %s", key, want, got)
+		}
+	}
+	return object, nil
+}
+
+// jsonType names the JSON type of a raw value
+func jsonType(value json.RawMessage) string {
+	switch trimmed := strings.TrimSpace(string(value)); {
+	case trimmed == "null":
+		return "null"
+	case strings.HasPrefix(trimmed, `"`):
+		return "string"
+	case strings.HasPrefix(trimmed, "{"):
+		return "object"
+	case strings.HasPrefix(trimmed, "["):
+		return "array"
+	case trimmed == "true" || trimmed == "false":
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+// repairPrompt asks the model to fix its previous reply
+const repairPrompt = `Your previous reply could not be used: %s.
+Reply again with only the corrected JSON object, using exactly the keys requested, and no other text.`
+
+// completeJSON requests a JSON object reply, validates it against schema and
+// decodes it into dst. Invalid replies are sent back with a repair prompt up
+// to lc.JSONRepairs times. When every attempt fails, the last raw reply is
+// returned with an error wrapping errMalformedOutput so callers can fall back.
+func (lc *LLMClient) completeJSON(ctx context.Context, messages []ChatMessage, temperature float64, maxTokens int, schema jsonSchema, dst any) (string, error) {
+	format := &ResponseFormat{Type: "json_object"}
+	var raw string
+	var problem error
+	for attempt := 0; attempt <= lc.JSONRepairs; attempt++ {
+		if attempt > 0 {
+			logging.Debugf("🔧 Structured reply invalid (%v), repair attempt %d/%d", problem, attempt, lc.JSONRepairs)
+			messages = append(messages,
+				ChatMessage{Role: "assistant", Content: raw},
+				ChatMessage{Role: "user", Content: fmt.Sprintf(repairPrompt, problem)})
+		}
+
+		var err error
+		raw, err = lc.send(ctx, messages, temperature, maxTokens, format, nil)
+		if err != nil {
+			return "", err
+		}
+		object, err := schema.validate(raw)
+		if err == nil {
+			if err = json.Unmarshal(object, dst); err == nil {
+				return raw, nil
+			}
+		}
+		problem = err
+	}
+	return raw, fmt.Errorf("%w after %d repairs: %v", errMalformedOutput, lc.JSONRepairs, problem)
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"MedAtlasAIServer/internal/logging"
+	"MedAtlasAIServer/internal/suggestions"
+)
+
+// FollowUpSuggester proposes questions a user might ask next (implemented
+// by LLMClient)
+type FollowUpSuggester interface {
+	SuggestFollowUps(ctx context.Context, question string, n int) ([]string, error)
+}
+
+// followUpCount is how many follow-up questions the model is asked for
+const followUpCount = 2
+
+// maxFollowUpLength bounds a suggested follow-up question, in bytes
+const maxFollowUpLength = 150
+
+const followUpPromptTemplate = `A user asked a medical research assistant:
+
+%s
+
+Suggest %d short follow-up questions the user could ask next to understand the topic better. Do not ask for diagnoses, prescriptions or dosages. Write one question per line, with nothing else.`
+
+// SuggestFollowUps asks the model for n follow-up questions to question
+func (lc *LLMClient) SuggestFollowUps(ctx context.Context, question string, n int) ([]string, error) {
+	reply, err := lc.complete(ctx, []ChatMessage{
+		{Role: "system", Content: lc.systemPrompt()},
+		{Role: "user", Content: fmt.Sprintf(followUpPromptTemplate, question, n)},
+	}, 0.5, 40*n)
+	if err != nil {
+		return nil, err
+	}
+	var followUps []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.) "))
+		if line == "" || len(line) > maxFollowUpLength {
+			continue
+		}
+		if followUps = append(followUps, line); len(followUps) == n {
+			break
+		}
+	}
+	return followUps, nil
+}
+
+// GenerateHelpfulSuggestions returns the suggestions for a message of
+// intent: the model's follow-up questions when llm_suggestions is
+// configured, then the templates of the suggestion engine
+func (llm *LLMMedicalChat) GenerateHelpfulSuggestions(ctx context.Context, intent, userMessage string) []string {
+	templated := llm.Suggestions.Suggest(intent, userMessage)
+	suggester, ok := llm.LLMClient.(FollowUpSuggester)
+	if !ok || !llm.UseRealAI || llm.Config == nil || !llm.Config.Current().LLMSuggestions {
+		return templated
+	}
+	// The call is not part of the answer, so it stays out of its trace
+	followUps, err := suggester.SuggestFollowUps(WithTrace(ctx, nil), userMessage, followUpCount)
+	if err != nil {
+		logging.Debugf("follow-up suggestions failed: %v", err)
+		return templated
+	}
+	merged := make([]string, 0, len(followUps)+len(templated))
+	seen := make(map[string]bool)
+	for _, suggestion := range append(followUps, templated...) {
+		if key := strings.ToLower(suggestion); !seen[key] && len(merged) < suggestions.MaxSuggestions {
+			seen[key] = true
+			merged = append(merged, suggestion)
+		}
+	}
+	return merged
+}
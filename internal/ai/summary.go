@@ -0,0 +1,153 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// RecentMessages is how many of the latest messages the prompt quotes
+// verbatim; older ones reach it only through the conversation summary
+const RecentMessages = 3
+
+// SummaryTokens bounds a conversation summary
+const SummaryTokens = 200
+
+// Summarizer condenses conversation messages into a summary, extending the
+// summary of the messages before them (implemented by LLMClient)
+type Summarizer interface {
+	SummarizeConversation(ctx context.Context, previous string, messages []ChatMessage) (string, error)
+}
+
+type summaryKey struct{}
+
+// WithConversationSummary passes the rolling summary of the messages before
+// the history given to ProcessMessage
+func WithConversationSummary(ctx context.Context, summary string) context.Context {
+	return context.WithValue(ctx, summaryKey{}, summary)
+}
+
+// ConversationSummaryFromContext returns the summary passed with
+// WithConversationSummary, or ""
+func ConversationSummaryFromContext(ctx context.Context) string {
+	summary, _ := ctx.Value(summaryKey{}).(string)
+	return summary
+}
+
+// ConversationSummary returns the summary of everything before the latest
+// RecentMessages of history: the summary in ctx, extended with a local
+// summary of the older messages of history it does not cover yet
+func ConversationSummary(ctx context.Context, history []ChatMessage) string {
+	summary := ConversationSummaryFromContext(ctx)
+	if older := len(history) - RecentMessages; older > 0 {
+		summary = SummarizeLocally(summary, history[:older])
+	}
+	return summary
+}
+
+const summarizePromptTemplate = `Update the summary of a conversation between a user and a medical research assistant.
+
+SUMMARY SO FAR:
+%s
+
+MESSAGES TO ADD:
+%s
+Write the updated summary in at most %d words. Keep what the user said about themselves (conditions, symptoms, medications, age, concerns) and the topics they asked about; leave out the assistant's explanations. Reply with the summary only.`
+
+// summaryWords is the length asked of the model, leaving SummaryTokens room
+// for medical vocabulary
+const summaryWords = 120
+
+// SummarizeConversation asks the model to fold messages into previous
+func (lc *LLMClient) SummarizeConversation(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	if previous == "" {
+		previous = "(none)"
+	}
+	var transcript strings.Builder
+	for _, msg := range messages {
+		if line, ok := transcriptLine(msg); ok {
+			transcript.WriteString(line)
+		}
+	}
+	prompt := fmt.Sprintf(summarizePromptTemplate, previous, transcript.String(), summaryWords)
+	summary, err := lc.complete(ctx, []ChatMessage{
+		{Role: "system", Content: lc.systemPrompt()},
+		{Role: "user", Content: prompt},
+	}, 0.2, SummaryTokens)
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", errors.New("model returned an empty conversation summary")
+	}
+	if CountTokens(summary) > SummaryTokens {
+		summary = truncateTokens(summary, SummaryTokens)
+	}
+	return summary, nil
+}
+
+// firstPerson matches sentences in which the user speaks about themselves
+var firstPerson = regexp.MustCompile(`(?i)\b(?:i|i'm|i've|i'd|me|my|mine|we|our)\b`)
+
+// questionTokens bounds each question a local summary lists
+const questionTokens = 24
+
+// SummarizeLocally is the summarizer used without a model, or when it
+// fails: it keeps what the user said about themselves (their sentences in
+// the first person) and the other questions they asked, after previous. A
+// summary past SummaryTokens loses its latest parts, since the first turns
+// usually set out the user's situation.
+func SummarizeLocally(previous string, messages []ChatMessage) string {
+	var said, asked []string
+	for _, msg := range messages {
+		if !strings.EqualFold(strings.TrimSpace(msg.Role), "user") {
+			continue
+		}
+		start := 0
+		for _, loc := range sentenceEnd.FindAllStringIndex(msg.Content+"\n", -1) {
+			sentence := strings.TrimSpace(msg.Content[start:min(loc[1], len(msg.Content))])
+			start = min(loc[1], len(msg.Content))
+			switch {
+			case sentence == "":
+			case firstPerson.MatchString(sentence):
+				said = append(said, sentence)
+			case strings.HasSuffix(sentence, "?"):
+				if CountTokens(sentence) > questionTokens {
+					sentence = truncateTokens(sentence, questionTokens)
+				}
+				asked = append(asked, sentence)
+			}
+		}
+	}
+
+	parts := []string{}
+	if previous != "" {
+		parts = append(parts, previous)
+	}
+	if len(said) > 0 {
+		parts = append(parts, "The user said: "+strings.Join(said, " "))
+	}
+	if len(asked) > 0 {
+		parts = append(parts, "They asked: "+strings.Join(asked, " "))
+	}
+	summary := strings.Join(parts, " ")
+	if CountTokens(summary) > SummaryTokens {
+		summary = truncateTokens(summary, SummaryTokens)
+	}
+	return summary
+}
+
+// transcriptLine formats msg as a line of the conversation context, such as
+// "U: ...". History comes from the client, so a message without a role is
+// skipped rather than trusted.
+func transcriptLine(msg ChatMessage) (string, bool) {
+	role, _ := utf8.DecodeRuneInString(strings.TrimSpace(msg.Role))
+	if role == utf8.RuneError {
+		return "", false
+	}
+	return fmt.Sprintf("%s: %s\n", strings.ToUpper(string(role)), msg.Content), true
+}
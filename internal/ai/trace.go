@@ -0,0 +1,208 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ChatTrace records how one chat answer was produced, for tuning the
+// retrieval and generation pipeline. Attach one with WithTrace; the
+// pipeline fills it in as it runs.
+type ChatTrace struct {
+	mu sync.Mutex
+
+	Intent           string              `json:"intent"`
+	RewrittenQueries []string            `json:"rewritten_queries"` // the text embedded for each search
+	IntentVector     bool                `json:"intent_vector"`     // intent blended as a vector rather than appended as text
+	Passages         []TracePassage      `json:"passages"`
+	Generations      []TraceGenerate     `json:"generations,omitempty"`
+	Budget           *PromptBudgetReport `json:"budget,omitempty"`
+	GroundingScore   *float64            `json:"grounding_score,omitempty"` // share of answer claims found in the passages
+}
+
+// TracePassage is one retrieved study as the prompt received it
+type TracePassage struct {
+	Rank  int     `json:"rank"`
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Score float32 `json:"score"` // vector similarity
+	Query string  `json:"query"` // the query that retrieved it
+}
+
+// TraceGenerate is one call to the model
+type TraceGenerate struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"` // as reported by the provider
+	Temperature      float64 `json:"temperature"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	Estimated        bool    `json:"estimated,omitempty"` // token counts estimated locally; the provider sent none
+	PromptVersion    string  `json:"prompt_version"`      // see PromptVersion
+}
+
+type traceKey struct{}
+
+// WithTrace attaches trace to ctx so the chat pipeline records into it
+func WithTrace(ctx context.Context, trace *ChatTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext returns the trace attached to ctx, or nil
+func TraceFromContext(ctx context.Context) *ChatTrace {
+	trace, _ := ctx.Value(traceKey{}).(*ChatTrace)
+	return trace
+}
+
+// The record methods do nothing on a nil trace, so the pipeline can call
+// them unconditionally
+
+func (t *ChatTrace) setIntent(intent string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Intent = intent
+}
+
+func (t *ChatTrace) addQuery(text string, intentVector bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.RewrittenQueries = append(t.RewrittenQueries, text)
+	t.IntentVector = intentVector
+}
+
+func (t *ChatTrace) addPassage(query, id, title string, score float32) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Passages = append(t.Passages, TracePassage{Rank: len(t.Passages) + 1, ID: id, Title: title, Score: score, Query: query})
+}
+
+func (t *ChatTrace) addGeneration(g TraceGenerate) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Generations = append(t.Generations, g)
+}
+
+func (t *ChatTrace) setBudget(report PromptBudgetReport) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Budget = &report
+}
+
+func (t *ChatTrace) setGrounding(answer string, passages []string) {
+	if t == nil {
+		return
+	}
+	score := GroundingScore(answer, passages)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.GroundingScore = &score
+}
+
+// TokenUsage is the tokens an answer took, over every model call made for
+// it (e.g. each consensus sample)
+type TokenUsage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	Estimated        bool `json:"estimated,omitempty"` // some counts were estimated locally
+	Truncated        bool `json:"truncated,omitempty"` // history or findings were cut to fit the context window
+}
+
+// Usage sums the token counts of the recorded generations, or returns nil
+// when the model was not called
+func (t *ChatTrace) Usage() *TokenUsage {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.Generations) == 0 {
+		return nil
+	}
+	usage := &TokenUsage{Truncated: t.Budget != nil && t.Budget.Truncated}
+	for _, generation := range t.Generations {
+		usage.PromptTokens += generation.PromptTokens
+		usage.CompletionTokens += generation.CompletionTokens
+		usage.Estimated = usage.Estimated || generation.Estimated
+	}
+	return usage
+}
+
+// estimateTokens counts the tokens of messages with CountTokens, for
+// providers that do not report usage
+func estimateTokens(messages []ChatMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += CountTokens(message.Content) + messageOverheadTokens
+	}
+	return tokens
+}
+
+// groundedWords is the share of a claim's words that must appear in one
+// passage for the claim to count as grounded in it
+const groundedWords = 0.6
+
+// GroundingScore is the share of the answer's key claims that are backed by
+// the passages: most of their words appear in one passage, and any numbers
+// they quote appear in the passages too. An answer without claims scores 1.
+func GroundingScore(answer string, passages []string) float64 {
+	claims := keyClaims(answer)
+	if len(claims) == 0 {
+		return 1
+	}
+	passageWords := make([]map[string]bool, len(passages))
+	for i, passage := range passages {
+		passageWords[i] = wordSet(passage)
+	}
+	var evidence = numericEvidence(passages)
+
+	grounded := 0
+	for _, claim := range claims {
+		if supported, _ := numbersSupported(claim, evidence); !supported {
+			continue
+		}
+		words := wordSet(claim)
+		for _, inPassage := range passageWords {
+			found := 0
+			for word := range words {
+				if inPassage[word] {
+					found++
+				}
+			}
+			if len(words) > 0 && float64(found) >= groundedWords*float64(len(words)) {
+				grounded++
+				break
+			}
+		}
+	}
+	return float64(grounded) / float64(len(claims))
+}
+
+// wordSet returns the lowercase words of text longer than three letters,
+// which skips most function words
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > utf8.RuneSelf)
+	}) {
+		if utf8.RuneCountInString(word) > 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
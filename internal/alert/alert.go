@@ -0,0 +1,77 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// WebhookEnv names the environment variable holding the alert webhook URL
+const WebhookEnv = "ALERT_WEBHOOK_URL"
+
+// Alert is one notification about a problem a background job found
+type Alert struct {
+	Source  string    `json:"source"` // the job raising it, e.g. "embedding-drift"
+	Summary string    `json:"summary"`
+	Details any       `json:"details,omitempty"`
+	Time    time.Time `json:"time"`
+	// Text repeats source and summary for chat webhooks (Slack, Mattermost)
+	// that only display a "text" field
+	Text string `json:"text"`
+}
+
+// Notifier logs alerts and posts them as JSON to a webhook. A nil Notifier
+// or one without a URL only logs.
+type Notifier struct {
+	WebhookURL string
+	HTTPClient *http.Client
+}
+
+// FromEnv returns a Notifier posting to ALERT_WEBHOOK_URL when it is set
+func FromEnv() *Notifier {
+	return &Notifier{
+		WebhookURL: os.Getenv(WebhookEnv),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify reports a. The alert is always logged; webhook failures are
+// returned so callers can log them, but never retried.
+func (n *Notifier) Notify(ctx context.Context, a Alert) error {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	a.Text = fmt.Sprintf("[%s] %s", a.Source, a.Summary)
+	log.Printf("🚨 %s", a.Text)
+	if n == nil || n.WebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert webhook failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package annotations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where annotations are kept when ANNOTATIONS_FILE is unset
+const DefaultPath = "data/annotations.json"
+
+// Annotation kinds
+const (
+	KindHighlight = "highlight"
+	KindNote      = "note"
+)
+
+// maxNoteLength bounds the free text attached to an annotation
+const maxNoteLength = 2000
+
+// Annotation marks a span of an article's abstract. Start and End are
+// character (rune) offsets into the abstract, End exclusive.
+type Annotation struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	ArticleID string    `json:"article_id"`
+	Kind      string    `json:"kind"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Quote     string    `json:"quote"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps annotations in memory and persists them to a JSON file.
+// It is safe for concurrent use.
+type Store struct {
+	mu          sync.RWMutex
+	path        string
+	annotations map[string]*Annotation
+	Clock       clock.Clock
+	IDs         ids.Generator
+}
+
+// NewStore loads path if it exists. An empty path keeps annotations in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:        path,
+		annotations: make(map[string]*Annotation),
+		Clock:       clock.System,
+		IDs:         ids.UUIDGenerator{Prefix: "ann_"},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	var annotations []*Annotation
+	if _, err := jsonfile.Read(path, &annotations); err != nil {
+		return nil, fmt.Errorf("failed to load annotations: %w", err)
+	}
+	for _, a := range annotations {
+		s.annotations[a.ID] = a
+	}
+	return s, nil
+}
+
+// Quote returns the text between character offsets start and end of abstract
+func Quote(abstract string, start, end int) (string, error) {
+	runes := []rune(abstract)
+	if start < 0 || end <= start || end > len(runes) {
+		return "", fmt.Errorf("%w: offsets %d-%d are outside the abstract (length %d)",
+			apperrors.ErrInvalidInput, start, end, len(runes))
+	}
+	return string(runes[start:end]), nil
+}
+
+// Add anchors a new annotation to abstract and stores it
+func (s *Store) Add(userID, articleID, abstract, kind string, start, end int, note string) (*Annotation, error) {
+	if kind == "" {
+		kind = KindHighlight
+		if note != "" {
+			kind = KindNote
+		}
+	}
+	if kind != KindHighlight && kind != KindNote {
+		return nil, fmt.Errorf("%w: unknown annotation kind %q", apperrors.ErrInvalidInput, kind)
+	}
+	if len(note) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note is too long", apperrors.ErrInvalidInput)
+	}
+	quote, err := Quote(abstract, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.Clock.Now()
+	a := &Annotation{
+		ID:        s.IDs.New(),
+		UserID:    userID,
+		ArticleID: articleID,
+		Kind:      kind,
+		Start:     start,
+		End:       end,
+		Quote:     quote,
+		Note:      strings.TrimSpace(note),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.annotations[a.ID] = a
+	if err := s.persist(); err != nil {
+		delete(s.annotations, a.ID)
+		return nil, err
+	}
+	copied := *a
+	return &copied, nil
+}
+
+// List returns a user's annotations on one article, in reading order
+func (s *Store) List(userID, articleID string) []Annotation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Annotation
+	for _, a := range s.annotations {
+		if a.UserID == userID && a.ArticleID == articleID {
+			result = append(result, *a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Start != result[j].Start {
+			return result[i].Start < result[j].Start
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// UpdateNote replaces the note on one of the user's annotations
+func (s *Store) UpdateNote(userID, id, note string) (*Annotation, error) {
+	if len(note) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note is too long", apperrors.ErrInvalidInput)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.owned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := *a
+	a.Note = strings.TrimSpace(note)
+	if a.Note != "" {
+		a.Kind = KindNote
+	}
+	a.UpdatedAt = s.Clock.Now()
+	if err := s.persist(); err != nil {
+		*a = previous
+		return nil, err
+	}
+	copied := *a
+	return &copied, nil
+}
+
+// Delete removes one of the user's annotations
+func (s *Store) Delete(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	delete(s.annotations, id)
+	if err := s.persist(); err != nil {
+		s.annotations[id] = a
+		return err
+	}
+	return nil
+}
+
+// DeleteUser removes all of a user's annotations
+func (s *Store) DeleteUser(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]*Annotation)
+	for id, a := range s.annotations {
+		if a.UserID == userID {
+			removed[id] = a
+			delete(s.annotations, id)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	if err := s.persist(); err != nil {
+		for id, a := range removed {
+			s.annotations[id] = a
+		}
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// owned returns the annotation if it belongs to userID. Other users'
+// annotations are reported as missing so IDs cannot be probed.
+// Callers must hold s.mu.
+func (s *Store) owned(userID, id string) (*Annotation, error) {
+	a, ok := s.annotations[id]
+	if !ok || a.UserID != userID {
+		return nil, fmt.Errorf("%w: annotation %s", apperrors.ErrNotFound, id)
+	}
+	return a, nil
+}
+
+// persist writes all annotations to disk. Callers must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	annotations := make([]*Annotation, 0, len(s.annotations))
+	for _, a := range s.annotations {
+		annotations = append(annotations, a)
+	}
+	return jsonfile.WriteAtomic(s.path, annotations)
+}
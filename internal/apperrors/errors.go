@@ -0,0 +1,128 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors shared across packages. Wrap them with %w so callers can
+// match with errors.Is and the HTTP layer can pick a status code.
+var (
+	ErrInvalidInput         = errors.New("invalid input")
+	ErrNotFound             = errors.New("not found")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrUnsafeContent        = errors.New("unsafe content")
+	ErrUnprocessable        = errors.New("unprocessable request")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrEmbeddingUnavailable = errors.New("embedding service unavailable")
+	ErrSearchUnavailable    = errors.New("vector search unavailable")
+	ErrLLMUnavailable       = errors.New("language model unavailable")
+	ErrOverloaded           = errors.New("server overloaded")
+)
+
+// FieldError is one request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the fields of a well-formed request whose values
+// are out of range. It matches ErrUnprocessable, and Write returns the
+// fields as the response details.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Invalid returns a ValidationError for one field
+func Invalid(field, format string, args ...any) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error { return ErrUnprocessable }
+
+// retryAfterError tells the client when to try again
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// WithRetryAfter annotates err so Write sends a Retry-After header
+func WithRetryAfter(err error, after time.Duration) error {
+	return &retryAfterError{err: err, after: after}
+}
+
+// RetryAfter returns the wait attached to err with WithRetryAfter, or zero
+func RetryAfter(err error) time.Duration {
+	var retry *retryAfterError
+	if errors.As(err, &retry) {
+		return retry.after
+	}
+	return 0
+}
+
+// StatusCode maps an error to the HTTP status it should produce
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnsafeContent), errors.Is(err, ErrUnprocessable):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrEmbeddingUnavailable),
+		errors.Is(err, ErrSearchUnavailable),
+		errors.Is(err, ErrLLMUnavailable),
+		errors.Is(err, ErrOverloaded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorResponse is the JSON body written for failed requests
+type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// Write sends a JSON error response with the status derived from err.
+// message is the client-facing text; err details stay in the server logs.
+func Write(w http.ResponseWriter, err error, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	if after := RetryAfter(err); after > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(after.Seconds()))))
+	}
+	w.WriteHeader(StatusCode(err))
+	response := ErrorResponse{Error: message}
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		response.Details = validation.Fields
+	}
+	json.NewEncoder(w).Encode(response)
+}
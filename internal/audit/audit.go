@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/identity"
+)
+
+// DefaultDir is where audit files are written when AUDIT_DIR is unset
+const DefaultDir = "data/audit"
+
+// SystemActor is recorded for changes not made by a signed-in user
+const SystemActor = "system"
+
+// Event is one audit record. Each event carries the hash of the previous
+// event in the same file so edits or deletions break the chain.
+type Event struct {
+	Seq      uint64            `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Service  string            `json:"service"`
+	Actor    string            `json:"actor"`
+	Action   string            `json:"action"`
+	Resource string            `json:"resource,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+func (e *Event) computeHash() string {
+	unhashed := *e
+	unhashed.Hash = ""
+	raw, _ := json.Marshal(unhashed)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends events for one service to <dir>/<service>.jsonl.
+// A nil *Log discards events. It is safe for concurrent use.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	service  string
+	seq      uint64
+	lastHash string
+	Clock    clock.Clock
+}
+
+// Open opens (or creates) the audit file for service and resumes its hash chain
+func Open(dir, service string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	path := filepath.Join(dir, service+".jsonl")
+
+	l := &Log{service: service, Clock: clock.System}
+	events, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		l.seq, l.lastHash = last.Seq, last.Hash
+	}
+
+	l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return l, nil
+}
+
+// OpenFromEnv opens the audit log in AUDIT_DIR (or DefaultDir)
+func OpenFromEnv(service string) (*Log, error) {
+	dir := os.Getenv("AUDIT_DIR")
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return Open(dir, service)
+}
+
+// Record appends an event. The actor is taken from ctx, falling back to
+// SystemActor. Write failures are logged rather than returned so auditing
+// never masks the result of the operation being audited.
+func (l *Log) Record(ctx context.Context, action, resource string, details map[string]string) {
+	if l == nil {
+		return
+	}
+	actor := identity.UserFromContext(ctx)
+	if actor == "" {
+		actor = SystemActor
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event := Event{
+		Seq:      l.seq + 1,
+		Time:     l.Clock.Now().UTC(),
+		Service:  l.service,
+		Actor:    actor,
+		Action:   action,
+		Resource: resource,
+		Details:  details,
+		PrevHash: l.lastHash,
+	}
+	event.Hash = event.computeHash()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️  Audit event %s not recorded: %v", action, err)
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.Printf("⚠️  Audit event %s not recorded: %v", action, err)
+		return
+	}
+	if err := l.file.Sync(); err != nil {
+		log.Printf("⚠️  Audit event %s may not be durable: %v", action, err)
+	}
+	l.seq, l.lastHash = event.Seq, event.Hash
+}
+
+// Close closes the underlying file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Filter selects events in Query. Zero values match everything.
+type Filter struct {
+	Actor    string
+	Action   string // prefix match, e.g. "workspace." for all workspace events
+	Resource string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (f Filter) matches(e Event) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || strings.HasPrefix(e.Action, f.Action)) &&
+		(f.Resource == "" || e.Resource == f.Resource) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Query reads every service's audit file in dir and returns matching events,
+// newest first
+func Query(dir string, f Filter) ([]Event, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Event
+	for _, path := range paths {
+		events, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if f.matches(e) {
+				matched = append(matched, e)
+			}
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.After(matched[j].Time) })
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched, nil
+}
+
+// Verify checks the hash chain of every audit file in dir and returns the
+// problems found per file; files that verify cleanly map to "ok"
+func Verify(dir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]string, len(paths))
+	for _, path := range paths {
+		name := filepath.Base(path)
+		events, err := readFile(path)
+		if err != nil {
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "ok"
+		prev := ""
+		for i, e := range events {
+			if e.PrevHash != prev || e.computeHash() != e.Hash || e.Seq != uint64(i+1) {
+				results[name] = fmt.Sprintf("chain broken at seq %d", e.Seq)
+				break
+			}
+			prev = e.Hash
+		}
+	}
+	return results, nil
+}
+
+func readFile(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filepath.Base(path), line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
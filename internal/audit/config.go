@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+	"strings"
+
+	"MedAtlasAIServer/internal/config"
+)
+
+// TrackConfig records an event for every config reload that changes a
+// setting, plus a dedicated event when the safety rules change
+func (l *Log) TrackConfig(store *config.Store) {
+	if l == nil {
+		return
+	}
+	var previous *config.Tunables
+	store.OnChange(func(t *config.Tunables) {
+		defer func() { previous = t }()
+		if previous == nil {
+			return // initial snapshot, not a change
+		}
+		changed := config.Changes(previous, t)
+		if len(changed) == 0 {
+			return
+		}
+		ctx := context.Background()
+		l.Record(ctx, "config.reload", "", map[string]string{"changed": strings.Join(changed, ",")})
+		for _, name := range changed {
+			if name == "safety" {
+				l.Record(ctx, "safety_rules.update", "", map[string]string{
+					"blocked_topics":       strings.Join(t.Safety.BlockedTopics, ","),
+					"high_risk_keywords":   strings.Join(t.Safety.HighRiskKeywords, ","),
+					"medium_risk_keywords": strings.Join(t.Safety.MediumRiskKeywords, ","),
+				})
+			}
+		}
+	})
+}
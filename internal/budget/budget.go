@@ -0,0 +1,140 @@
+package budget
+
+import (
+	"context"
+	"time"
+
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/config"
+)
+
+// Stage names used by the chat pipeline, in the order they run
+const (
+	StageEmbedding   = "embedding"
+	StageSearch      = "search"
+	StageRerank      = "rerank"
+	StageGeneration  = "generation"
+	StageCitations   = "citations"
+	StageSuggestions = "suggestions"
+)
+
+// suggestionsMax bounds how long a response waits for its suggestions
+const suggestionsMax = 500 * time.Millisecond
+
+// Stage is one step of a request and the most time it may take
+type Stage struct {
+	Name     string
+	Max      time.Duration
+	Optional bool // skipped rather than shortened when time is tight
+}
+
+// min is the least time worth giving a required stage; later required
+// stages keep this much in reserve while earlier ones run
+func (s Stage) min() time.Duration {
+	return s.Max / 4
+}
+
+// Budget splits a request deadline across ordered stages. Each stage gets
+// its own maximum, cut short so the required stages after it keep their
+// reserve. Optional stages are skipped when their full maximum no longer
+// fits. A nil *Budget places no limits.
+type Budget struct {
+	clock    clock.Clock
+	deadline time.Time
+	stages   []Stage
+}
+
+// New starts a budget of total from now
+func New(c clock.Clock, total time.Duration, stages ...Stage) *Budget {
+	return &Budget{clock: c, deadline: c.Now().Add(total), stages: stages}
+}
+
+// ForChat builds the chat pipeline budget from the configured timeouts.
+// A zero rerank timeout leaves reranking out, along with the cross-encoder
+// citation alignment that shares its timeout.
+func ForChat(c clock.Clock, t config.Timeouts) *Budget {
+	seconds := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	stages := []Stage{
+		{Name: StageEmbedding, Max: seconds(t.EmbeddingSeconds)},
+		{Name: StageSearch, Max: seconds(t.SearchSeconds)},
+	}
+	if t.RerankSeconds > 0 {
+		stages = append(stages, Stage{Name: StageRerank, Max: seconds(t.RerankSeconds), Optional: true})
+	}
+	stages = append(stages, Stage{Name: StageGeneration, Max: seconds(t.GenerationSeconds)})
+	if t.RerankSeconds > 0 {
+		stages = append(stages, Stage{Name: StageCitations, Max: seconds(t.RerankSeconds), Optional: true})
+	}
+	stages = append(stages, Stage{Name: StageSuggestions, Max: suggestionsMax, Optional: true})
+	return New(c, seconds(t.TotalSeconds), stages...)
+}
+
+// Remaining returns the time left before the overall deadline
+func (b *Budget) Remaining() time.Duration {
+	return b.deadline.Sub(b.clock.Now())
+}
+
+// Allot returns how long the named stage may run now. ok is false when an
+// optional stage should be skipped, or the stage is not part of the plan.
+func (b *Budget) Allot(name string) (allowed time.Duration, ok bool) {
+	index := -1
+	for i, stage := range b.stages {
+		if stage.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, false
+	}
+	stage := b.stages[index]
+
+	var reserve time.Duration
+	for _, later := range b.stages[index+1:] {
+		if !later.Optional {
+			reserve += later.min()
+		}
+	}
+	available := b.Remaining() - reserve
+
+	if stage.Optional {
+		if available < stage.Max {
+			return 0, false
+		}
+		return stage.Max, true
+	}
+	if available > stage.Max {
+		available = stage.Max
+	}
+	if available < 0 {
+		available = 0
+	}
+	return available, true
+}
+
+// Context returns ctx limited to the named stage's allotment. ok is false
+// when the stage should be skipped; the returned context is then ctx itself.
+func (b *Budget) Context(ctx context.Context, name string) (context.Context, context.CancelFunc, bool) {
+	if b == nil {
+		return ctx, func() {}, true
+	}
+	allowed, ok := b.Allot(name)
+	if !ok {
+		return ctx, func() {}, false
+	}
+	stageCtx, cancel := context.WithTimeout(ctx, allowed)
+	return stageCtx, cancel, true
+}
+
+type contextKey struct{}
+
+// WithBudget attaches b to ctx
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the budget attached to ctx, or nil
+func FromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(contextKey{}).(*Budget)
+	return b
+}
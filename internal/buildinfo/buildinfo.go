@@ -0,0 +1,110 @@
+// Package buildinfo describes the running build, so a support request can
+// name exactly what is deployed. Release builds set the variables with
+//
+//	go build -ldflags "-X MedAtlasAIServer/internal/buildinfo.Version=v1.4.0 \
+//	  -X MedAtlasAIServer/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X MedAtlasAIServer/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them fall back to the VCS stamp the go tool embeds.
+package buildinfo
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// IndexSchemaVersion names the layout of the Qdrant payloads and collections
+// this build reads and writes. Bump it when the indexer's output changes
+// incompatibly; INDEX_SCHEMA_VERSION overrides it for an index built by
+// another release.
+var IndexSchemaVersion = "1"
+
+// Info is the /version response
+type Info struct {
+	Service            string `json:"service"`
+	Version            string `json:"version"`
+	Commit             string `json:"commit,omitempty"`
+	Modified           bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+	BuildTime          string `json:"build_time,omitempty"`
+	GoVersion          string `json:"go_version"`
+	EmbeddingModel     string `json:"embedding_model,omitempty"`
+	EmbeddingDims      int    `json:"embedding_dims,omitempty"`
+	LLMModel           string `json:"llm_model,omitempty"`
+	IndexSchemaVersion string `json:"index_schema_version"`
+}
+
+// Get returns the build information of service
+func Get(service string) Info {
+	info := Info{
+		Service:            service,
+		Version:            Version,
+		Commit:             Commit,
+		BuildTime:          BuildTime,
+		GoVersion:          runtime.Version(),
+		IndexSchemaVersion: IndexSchemaVersion,
+	}
+	if schema := os.Getenv("INDEX_SCHEMA_VERSION"); schema != "" {
+		info.IndexSchemaVersion = schema
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// ModelInfoer reports the embedding model (implemented by
+// *embeddingClient.Client)
+type ModelInfoer interface {
+	ModelInfo(ctx context.Context) (name string, dims int, err error)
+}
+
+// modelInfoTimeout keeps /version fast when the embedding service is down
+const modelInfoTimeout = 2 * time.Second
+
+// Handler serves Get(service), with the embedding model asked of embedder
+// when it is not nil and llmModel, the configured language model, when set
+func Handler(service string, embedder ModelInfoer, llmModel string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := Get(service)
+		info.LLMModel = llmModel
+		if embedder != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), modelInfoTimeout)
+			name, dims, err := embedder.ModelInfo(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  Embedding model info unavailable: %v", err)
+			} else {
+				info.EmbeddingModel, info.EmbeddingDims = name, dims
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			log.Printf("JSON encoding error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package bulkdelete removes the points matching a payload filter from the
+// article collections, e.g. every preprint from one source or the articles
+// of a list of journals. A deletion must be preceded by a dry run, whose
+// confirmation token only deletes what that dry run counted.
+package bulkdelete
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultTokenTTL is how long a dry run's confirmation token is accepted
+const DefaultTokenTTL = 10 * time.Minute
+
+// Points is the part of qdrant.PointsClient a Deleter uses
+type Points interface {
+	Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error)
+	Delete(ctx context.Context, in *qdrant.DeletePoints, opts ...grpc.CallOption) (*qdrant.PointsOperationResponse, error)
+}
+
+// Collections is the part of qdrant.CollectionsClient a Deleter uses
+type Collections interface {
+	CollectionExists(ctx context.Context, in *qdrant.CollectionExistsRequest, opts ...grpc.CallOption) (*qdrant.CollectionExistsResponse, error)
+}
+
+// Plan is the outcome of a dry run or a deletion: the matching points of
+// each collection. ConfirmationToken and ExpiresAt are set by dry runs.
+type Plan struct {
+	DryRun            bool              `json:"dry_run"`
+	Counts            map[string]uint64 `json:"counts"`
+	Total             uint64            `json:"total"`
+	ConfirmationToken string            `json:"confirmation_token,omitempty"`
+	ExpiresAt         *time.Time        `json:"expires_at,omitempty"`
+}
+
+// Deleter deletes matching points from Targets. Tokens are signed over the
+// filter and the counts with Secret rather than stored, so any replica
+// sharing the secret can confirm a dry run made on another, and no token
+// can be made without a dry run.
+type Deleter struct {
+	Points      Points
+	Collections Collections
+	Targets     []string
+	TokenTTL    time.Duration
+	Clock       clock.Clock
+	Secret      []byte
+}
+
+// NewDeleter deletes from targets, the collections that hold article
+// points or points derived from them (passages, sections), signing tokens
+// with secret
+func NewDeleter(points Points, collections Collections, targets []string, secret []byte) *Deleter {
+	return &Deleter{Points: points, Collections: collections, Targets: targets, TokenTTL: DefaultTokenTTL, Clock: clock.System, Secret: secret}
+}
+
+// SecretFromEnv returns BULK_DELETE_SECRET. Without it a random secret is
+// used, and a dry run can only be confirmed on the replica that made it,
+// before it restarts.
+func SecretFromEnv() []byte {
+	secret := []byte(os.Getenv("BULK_DELETE_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Could not generate bulk delete secret: %v", err)
+		}
+	}
+	return secret
+}
+
+// DryRun counts the points filter matches and returns the token that
+// confirms deleting them
+func (d *Deleter) DryRun(ctx context.Context, filter *qdrant.Filter) (*Plan, error) {
+	plan, err := d.count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	expires := d.Clock.Now().Add(d.TokenTTL).Truncate(time.Second).UTC()
+	plan.DryRun = true
+	plan.ExpiresAt = &expires
+	plan.ConfirmationToken = d.token(filter, plan.Total, expires)
+	return plan, nil
+}
+
+// Delete deletes the points filter matches, provided confirmation is the
+// unexpired token of a dry run of filter and the points still match as
+// they did then
+func (d *Deleter) Delete(ctx context.Context, filter *qdrant.Filter, confirmation string) (*Plan, error) {
+	encoded, _, ok := strings.Cut(confirmation, ".")
+	unix, err := strconv.ParseInt(encoded, 10, 64)
+	if !ok || err != nil {
+		return nil, apperrors.Invalid("confirmation_token", "is not a dry-run token")
+	}
+	expires := time.Unix(unix, 0).UTC()
+	now := d.Clock.Now()
+	if now.After(expires) {
+		return nil, apperrors.Invalid("confirmation_token", "has expired; run the dry run again")
+	}
+	// No dry run issues tokens lasting longer, whatever the token claims
+	if expires.After(now.Add(d.TokenTTL)) {
+		return nil, apperrors.Invalid("confirmation_token", "is not a dry-run token")
+	}
+	plan, err := d.count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(d.token(filter, plan.Total, expires)), []byte(confirmation)) {
+		return nil, apperrors.Invalid("confirmation_token", "does not match this filter, or the matching points changed since the dry run; run it again")
+	}
+
+	wait := true
+	for _, collection := range d.Targets {
+		if plan.Counts[collection] == 0 {
+			continue
+		}
+		if _, err := d.Points.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collection,
+			Wait:           &wait,
+			Points:         qdrant.NewPointsSelectorFilter(filter),
+		}); err != nil {
+			return nil, fmt.Errorf("%w: failed to delete from %s: %w", apperrors.ErrSearchUnavailable, collection, err)
+		}
+	}
+	return plan, nil
+}
+
+// count counts the points filter matches in each existing target
+func (d *Deleter) count(ctx context.Context, filter *qdrant.Filter) (*Plan, error) {
+	if filter == nil || (len(filter.Must) == 0 && len(filter.Should) == 0 && len(filter.MustNot) == 0) {
+		return nil, apperrors.Invalid("filters", "at least one filter is required; bulk deletes never empty a collection")
+	}
+	exact := true
+	plan := &Plan{Counts: make(map[string]uint64, len(d.Targets))}
+	for _, collection := range d.Targets {
+		exists, err := d.Collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: collection})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", apperrors.ErrSearchUnavailable, collection, err)
+		}
+		if !exists.GetResult().GetExists() {
+			continue
+		}
+		count, err := d.Points.Count(ctx, &qdrant.CountPoints{CollectionName: collection, Filter: filter, Exact: &exact})
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to count %s: %w", apperrors.ErrSearchUnavailable, collection, err)
+		}
+		plan.Counts[collection] = count.GetResult().GetCount()
+		plan.Total += count.GetResult().GetCount()
+	}
+	return plan, nil
+}
+
+// token binds a confirmation to filter, the number of points it matched and
+// the expiry, which it carries in the clear, with an HMAC under d.Secret
+func (d *Deleter) token(filter *qdrant.Filter, total uint64, expires time.Time) string {
+	encoded, _ := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	mac := hmac.New(sha256.New, d.Secret)
+	mac.Write(encoded)
+	fmt.Fprintf(mac, "|%d|%d", total, expires.Unix())
+	return strconv.FormatInt(expires.Unix(), 10) + "." + hex.EncodeToString(mac.Sum(nil))
+}
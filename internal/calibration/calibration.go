@@ -0,0 +1,345 @@
+// Package calibration turns raw relevance scores into labels people can
+// read: high, moderate or weak relevance. The score at which each label
+// starts is learned from relevance feedback, where users mark results as
+// relevant or not; until a scale has enough feedback, default bands apply.
+package calibration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where feedback is kept when RELEVANCE_FEEDBACK_FILE is unset
+const DefaultPath = "data/relevance_feedback.json"
+
+// Scales are the kinds of score a result can carry. Each is calibrated on
+// its own, since the same number means different things on each.
+const (
+	ScaleCosine = "cosine" // dense vector similarity
+	ScaleRerank = "rerank" // cross-encoder logit
+	ScaleMaxSim = "maxsim" // late-interaction sum over query tokens
+	ScaleRRF    = "rrf"    // reciprocal rank fusion of vector and keyword hits
+)
+
+// Labels
+const (
+	LabelHigh     = "high"
+	LabelModerate = "moderate"
+	LabelWeak     = "weak"
+)
+
+// Share of results at a score that must have been judged relevant for the
+// score to start a band
+const (
+	HighPrecision     = 0.7
+	ModeratePrecision = 0.4
+)
+
+// MinJudgments is how much feedback a scale needs, with both relevant and
+// irrelevant judgments among it, before its bands are learned
+const MinJudgments = 50
+
+// MaxJudgments bounds the feedback kept; the oldest is dropped first
+const MaxJudgments = 20000
+
+// Judgment is one user's verdict on one result
+type Judgment struct {
+	Scale    string    `json:"scale"`
+	Score    float32   `json:"score"`
+	Relevant bool      `json:"relevant"`
+	ID       string    `json:"id,omitempty"` // the judged article
+	Query    string    `json:"query,omitempty"`
+	UserID   string    `json:"user_id,omitempty"`
+	JudgedAt time.Time `json:"judged_at"`
+}
+
+// Bands are where the labels of a scale start: scores of at least High are
+// high relevance, of at least Moderate moderate, and weak below. A nil
+// bound means feedback never reached its precision, so the label is unused.
+type Bands struct {
+	High      *float32 `json:"high"`
+	Moderate  *float32 `json:"moderate"`
+	Judgments int      `json:"judgments"` // feedback they were learned from; 0 for defaults
+}
+
+// DefaultBands apply to scales without enough feedback. The cosine bands
+// suit sentence-transformer models; ms-marco cross-encoders score 0 for an
+// even match. Max-sim scores grow with query length and fused scores only
+// reflect ranks, so they get no default.
+func DefaultBands() map[string]Bands {
+	return map[string]Bands{
+		ScaleCosine: {High: bound(0.75), Moderate: bound(0.6)},
+		ScaleRerank: {High: bound(3), Moderate: bound(0)},
+	}
+}
+
+func bound(score float32) *float32 { return &score }
+
+// Label returns the label of score under b
+func (b Bands) Label(score float32) string {
+	switch {
+	case b.High != nil && score >= *b.High:
+		return LabelHigh
+	case b.Moderate != nil && score >= *b.Moderate:
+		return LabelModerate
+	default:
+		return LabelWeak
+	}
+}
+
+// Calibrator keeps relevance feedback in a JSON file and the bands learned
+// from it. Servers sharing the file pick up each other's feedback with
+// Watch. It is safe for concurrent use, and a nil *Calibrator labels
+// nothing.
+type Calibrator struct {
+	mu        sync.RWMutex
+	path      string
+	modTime   time.Time
+	judgments []Judgment
+	bands     map[string]Bands
+	Clock     clock.Clock
+}
+
+// PathFromEnv returns RELEVANCE_FEEDBACK_FILE, or DefaultPath
+func PathFromEnv() string {
+	if path := os.Getenv("RELEVANCE_FEEDBACK_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Open loads the feedback in path if it exists. An empty path keeps
+// feedback in memory only.
+func Open(path string) (*Calibrator, error) {
+	c := &Calibrator{path: path, Clock: clock.System}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load replaces the feedback with the file's and refits the bands. Callers
+// must hold c.mu or own c.
+func (c *Calibrator) load() error {
+	if c.path != "" {
+		info, statErr := os.Stat(c.path)
+		var judgments []Judgment
+		if _, err := jsonfile.Read(c.path, &judgments); err != nil {
+			return fmt.Errorf("failed to load relevance feedback: %w", err)
+		}
+		c.judgments = judgments
+		if statErr == nil {
+			c.modTime = info.ModTime()
+		}
+	}
+	c.refit()
+	return nil
+}
+
+// refit learns the bands of every scale. Callers must hold c.mu or own c.
+func (c *Calibrator) refit() {
+	byScale := make(map[string][]Judgment)
+	for _, judgment := range c.judgments {
+		byScale[judgment.Scale] = append(byScale[judgment.Scale], judgment)
+	}
+	c.bands = DefaultBands()
+	for scale, judgments := range byScale {
+		if learned, ok := Fit(judgments); ok {
+			c.bands[scale] = learned
+		}
+	}
+}
+
+// Label returns the label of score on scale, or "" when the scale has no
+// bands yet
+func (c *Calibrator) Label(scale string, score float32) string {
+	if c == nil {
+		return ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bands, ok := c.bands[scale]
+	if !ok {
+		return ""
+	}
+	return bands.Label(score)
+}
+
+// Bands returns the bands in use, by scale
+func (c *Calibrator) Bands() map[string]Bands {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	copied := make(map[string]Bands, len(c.bands))
+	for scale, bands := range c.bands {
+		copied[scale] = bands
+	}
+	return copied
+}
+
+// Add records judgment and refits the bands. A user judging the same result
+// for the same query again replaces their earlier verdict, so no one user
+// can outweigh the others on a result.
+func (c *Calibrator) Add(judgment Judgment) error {
+	switch judgment.Scale {
+	case ScaleCosine, ScaleRerank, ScaleMaxSim, ScaleRRF:
+	default:
+		return apperrors.Invalid("scale", "must be %s, %s, %s or %s", ScaleCosine, ScaleRerank, ScaleMaxSim, ScaleRRF)
+	}
+	if judgment.JudgedAt.IsZero() {
+		judgment.JudgedAt = c.Clock.Now().UTC()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.judgments
+	c.judgments = make([]Judgment, 0, len(previous)+1)
+	for _, earlier := range previous {
+		if judgment.UserID == "" || earlier.UserID != judgment.UserID || earlier.ID != judgment.ID ||
+			earlier.Query != judgment.Query || earlier.Scale != judgment.Scale {
+			c.judgments = append(c.judgments, earlier)
+		}
+	}
+	c.judgments = append(c.judgments, judgment)
+	if len(c.judgments) > MaxJudgments {
+		c.judgments = c.judgments[len(c.judgments)-MaxJudgments:]
+	}
+	if err := c.persist(); err != nil {
+		c.judgments = previous
+		return err
+	}
+	c.refit()
+	return nil
+}
+
+// DeleteUser forgets the feedback userID gave and returns how many
+// judgments were removed
+func (c *Calibrator) DeleteUser(userID string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.judgments
+	kept := make([]Judgment, 0, len(c.judgments))
+	for _, judgment := range c.judgments {
+		if judgment.UserID != userID {
+			kept = append(kept, judgment)
+		}
+	}
+	removed := len(c.judgments) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	c.judgments = kept
+	if err := c.persist(); err != nil {
+		c.judgments = previous
+		return 0, err
+	}
+	c.refit()
+	return removed, nil
+}
+
+// Watch reloads the feedback when the file changes, checking every
+// interval, so feedback given through another server applies without a
+// restart. It returns when ctx is cancelled.
+func (c *Calibrator) Watch(ctx context.Context, interval time.Duration) {
+	if c == nil || c.path == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(c.path)
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			if !info.ModTime().Equal(c.modTime) {
+				if err := c.load(); err != nil {
+					log.Printf("⚠️  Relevance feedback reload rejected: %v", err)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// persist writes the feedback to the file. Callers must hold c.mu.
+func (c *Calibrator) persist() error {
+	if c.path == "" {
+		return nil
+	}
+	if err := jsonfile.WriteAtomic(c.path, c.judgments); err != nil {
+		return err
+	}
+	if info, err := os.Stat(c.path); err == nil {
+		c.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Fit learns bands from the judgments of one scale. The share of relevant
+// results is fitted as a non-decreasing function of the score (isotonic
+// regression), and each band starts at the lowest score whose fitted share
+// reaches the band's precision. ok is false when there is too little
+// feedback, or it is all one verdict.
+func Fit(judgments []Judgment) (bands Bands, ok bool) {
+	relevant := 0
+	for _, judgment := range judgments {
+		if judgment.Relevant {
+			relevant++
+		}
+	}
+	if len(judgments) < MinJudgments || relevant == 0 || relevant == len(judgments) {
+		return Bands{}, false
+	}
+
+	sorted := make([]Judgment, len(judgments))
+	copy(sorted, judgments)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+
+	// Pool adjacent violators: merge neighbouring blocks until the share of
+	// relevant judgments never falls as the score rises
+	type block struct {
+		low      float32 // lowest score in the block
+		relevant float64
+		count    float64
+	}
+	var blocks []block
+	for _, judgment := range sorted {
+		b := block{low: judgment.Score, count: 1}
+		if judgment.Relevant {
+			b.relevant = 1
+		}
+		blocks = append(blocks, b)
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.relevant/prev.count < last.relevant/last.count {
+				break
+			}
+			blocks = blocks[:len(blocks)-2]
+			blocks = append(blocks, block{low: prev.low, relevant: prev.relevant + last.relevant, count: prev.count + last.count})
+		}
+	}
+
+	bands.Judgments = len(judgments)
+	for _, b := range blocks {
+		share := b.relevant / b.count
+		if bands.Moderate == nil && share >= ModeratePrecision {
+			bands.Moderate = bound(b.low)
+		}
+		if bands.High == nil && share >= HighPrecision {
+			bands.High = bound(b.low)
+		}
+	}
+	return bands, true
+}
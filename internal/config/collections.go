@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultArticleCollection is the article collection when none is configured
+const DefaultArticleCollection = "medical_abstracts"
+
+// ArticlesTag is the /search source tag of the article collection
+const ArticlesTag = "abstracts"
+
+// Collections names the Qdrant collections of one environment. Articles is
+// the article collection; its recent tier is Articles + "_recent".
+// Searchable maps further source tags /search accepts to their collections,
+// e.g. {"trials": "clinical_trials", "guidelines": "guidelines"}. Storage
+// is how the indexer stores the vectors of the collections it writes.
+// Unlike Tunables, collections are read once at startup.
+type Collections struct {
+	Articles   string            `json:"articles"`
+	Searchable map[string]string `json:"searchable,omitempty"`
+	Storage    VectorStorage     `json:"storage,omitempty"`
+}
+
+// Quantization modes of VectorStorage
+const (
+	QuantizationNone    = "none"
+	QuantizationScalar  = "scalar"  // int8 vectors, a quarter of the memory
+	QuantizationProduct = "product" // 16 times smaller, at some cost in recall
+)
+
+// VectorStorage says how vectors are stored. With quantization, searches
+// scan compressed copies of the vectors kept in RAM and rescore the best
+// candidates with the originals, which OnDisk leaves on disk, so corpora
+// of millions of abstracts fit in memory.
+type VectorStorage struct {
+	Quantization string `json:"quantization,omitempty"` // none (default), scalar or product
+	OnDisk       bool   `json:"on_disk,omitempty"`
+}
+
+var collections atomic.Pointer[Collections]
+
+// CurrentCollections returns the collections set by SetCollections, or the
+// defaults
+func CurrentCollections() Collections {
+	if current := collections.Load(); current != nil {
+		return *current
+	}
+	return Collections{Articles: DefaultArticleCollection}
+}
+
+// ArticleCollection is the name of the article collection
+func ArticleCollection() string {
+	return CurrentCollections().Articles
+}
+
+// SetCollections makes c the collections of this process. Call it at
+// startup, before any client is built.
+func SetCollections(c Collections) {
+	collections.Store(&c)
+}
+
+// Collection returns the collection tagged tag, "abstracts" being the
+// article collection
+func (c Collections) Collection(tag string) (string, bool) {
+	if tag == ArticlesTag {
+		return c.Articles, true
+	}
+	name, ok := c.Searchable[tag]
+	return name, ok
+}
+
+// Tags lists the source tags, "abstracts" first
+func (c Collections) Tags() []string {
+	tags := []string{ArticlesTag}
+	for tag := range c.Searchable {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags[1:])
+	return tags
+}
+
+// CollectionFlag registers -collection on the default flag set; pass its
+// value to LoadCollections
+func CollectionFlag() *string {
+	return flag.String("collection", "", "Qdrant article collection (overrides QDRANT_COLLECTION and the config file)")
+}
+
+// LoadCollections reads the collections of this environment and sets them.
+// Later sources override earlier ones: the defaults, the "collections"
+// object of CONFIG_FILE, QDRANT_COLLECTION and QDRANT_SEARCHABLE_COLLECTIONS
+// ("trials=clinical_trials,guidelines=guidelines"), QDRANT_QUANTIZATION and
+// QDRANT_ON_DISK_VECTORS, then override, the -collection flag.
+func LoadCollections(override string) (Collections, error) {
+	c := Collections{Articles: DefaultArticleCollection}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return c, fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+		var file struct {
+			Collections *Collections `json:"collections"`
+		}
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return c, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		if file.Collections != nil {
+			if file.Collections.Articles != "" {
+				c.Articles = file.Collections.Articles
+			}
+			c.Searchable = file.Collections.Searchable
+			c.Storage = file.Collections.Storage
+		}
+	}
+	if name := os.Getenv("QDRANT_COLLECTION"); name != "" {
+		c.Articles = name
+	}
+	if spec := os.Getenv("QDRANT_SEARCHABLE_COLLECTIONS"); spec != "" {
+		c.Searchable = make(map[string]string)
+		for _, entry := range strings.Split(spec, ",") {
+			tag, name, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || tag == "" || name == "" {
+				return c, fmt.Errorf("QDRANT_SEARCHABLE_COLLECTIONS entries must be tag=collection, got %q", entry)
+			}
+			c.Searchable[tag] = name
+		}
+	}
+	if mode := os.Getenv("QDRANT_QUANTIZATION"); mode != "" {
+		c.Storage.Quantization = mode
+	}
+	if value := os.Getenv("QDRANT_ON_DISK_VECTORS"); value != "" {
+		onDisk, err := strconv.ParseBool(value)
+		if err != nil {
+			return c, fmt.Errorf("QDRANT_ON_DISK_VECTORS must be true or false, got %q", value)
+		}
+		c.Storage.OnDisk = onDisk
+	}
+	if override != "" {
+		c.Articles = override
+	}
+	if err := c.validate(); err != nil {
+		return c, err
+	}
+	SetCollections(c)
+	return c, nil
+}
+
+func (c Collections) validate() error {
+	if strings.TrimSpace(c.Articles) == "" {
+		return fmt.Errorf("collections.articles must not be empty")
+	}
+	for tag, name := range c.Searchable {
+		if tag == ArticlesTag {
+			return fmt.Errorf("collections.searchable must not redefine %q, the article collection", ArticlesTag)
+		}
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("collections.searchable.%s must name a collection", tag)
+		}
+	}
+	return c.Storage.Validate()
+}
+
+// Validate checks the quantization mode
+func (s VectorStorage) Validate() error {
+	switch s.Quantization {
+	case "", QuantizationNone, QuantizationScalar, QuantizationProduct:
+		return nil
+	}
+	return fmt.Errorf("collections.storage.quantization must be %s, %s or %s, got %q",
+		QuantizationNone, QuantizationScalar, QuantizationProduct, s.Quantization)
+}
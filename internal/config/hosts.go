@@ -0,0 +1,29 @@
+package config
+
+import "os"
+
+// Default addresses of the embedding service and Qdrant's gRPC API
+const (
+	DefaultEmbeddingServiceHost = "http://localhost:8000"
+	DefaultQdrantHost           = "localhost:6334"
+)
+
+// EmbeddingServiceHost is the embedding service URL from
+// EMBEDDING_SERVICE_HOST, or DefaultEmbeddingServiceHost. Point it at
+// cmd/embedproxy to spread calls across several replicas.
+func EmbeddingServiceHost() string {
+	return envOr("EMBEDDING_SERVICE_HOST", DefaultEmbeddingServiceHost)
+}
+
+// QdrantHost is the Qdrant gRPC address from QDRANT_HOST, or
+// DefaultQdrantHost
+func QdrantHost() string {
+	return envOr("QDRANT_HOST", DefaultQdrantHost)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
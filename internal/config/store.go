@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Store holds the current Tunables snapshot and swaps it atomically on reload
+type Store struct {
+	path      string
+	current   atomic.Pointer[Tunables]
+	mu        sync.Mutex
+	listeners []func(*Tunables)
+	modTime   time.Time
+}
+
+// NewStore loads path (if set) on top of the defaults
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	s.current.Store(DefaultTunables())
+	if path == "" {
+		return s, nil
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the active snapshot. Callers must not modify it.
+func (s *Store) Current() *Tunables {
+	return s.current.Load()
+}
+
+// OnChange registers fn to run with the new snapshot after every successful reload.
+// fn is also invoked immediately with the current snapshot.
+func (s *Store) OnChange(fn func(*Tunables)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+	fn(s.Current())
+}
+
+// Reload re-reads the config file, validates it and swaps it in.
+// An invalid file leaves the previous snapshot active.
+func (s *Store) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config: %w", err)
+	}
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	next := DefaultTunables()
+	if err := json.Unmarshal(raw, next); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	s.current.Store(next)
+	s.modTime = info.ModTime()
+	for _, fn := range s.listeners {
+		fn(next)
+	}
+	return nil
+}
+
+// Watch reloads on SIGHUP and, when interval > 0, whenever the file's
+// modification time changes. It returns when ctx is cancelled.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.reloadAndLog("SIGHUP")
+		case <-tick:
+			if s.changedOnDisk() {
+				s.reloadAndLog("file change")
+			}
+		}
+	}
+}
+
+func (s *Store) changedOnDisk() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !info.ModTime().Equal(s.modTime)
+}
+
+func (s *Store) reloadAndLog(trigger string) {
+	if err := s.Reload(); err != nil {
+		log.Printf("⚠️  Config reload (%s) rejected: %v", trigger, err)
+		return
+	}
+	log.Printf("🔄 Config reloaded from %s (%s)", s.path, trigger)
+}
+
+// ReloadInterval is how often servers poll the config file for changes
+const ReloadInterval = 15 * time.Second
+
+// LoadFromEnv creates a Store from the file named by CONFIG_FILE, if any
+func LoadFromEnv() (*Store, error) {
+	return NewStore(os.Getenv("CONFIG_FILE"))
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultSystemPrompt is the LLM system message used when no template is configured
+const DefaultSystemPrompt = "You are a medical AI assistant that provides general health information and suggestions based on medical research. You are helpful, cautious, and always recommend consulting healthcare professionals for personal medical advice. Never provide prescriptions or specific dosage advice."
+
+// SafetyRules overrides the safety checker keyword lists. Empty lists keep the built-in rules.
+type SafetyRules struct {
+	BlockedTopics      []string `json:"blocked_topics,omitempty"`
+	HighRiskKeywords   []string `json:"high_risk_keywords,omitempty"`
+	MediumRiskKeywords []string `json:"medium_risk_keywords,omitempty"`
+	// ExcludedTopics are subjects this deployment must not cover, e.g. by
+	// jurisdiction. There are none by default.
+	ExcludedTopics []ExcludedTopic `json:"excluded_topics,omitempty"`
+	// SensitivePassages is what happens to retrieved text describing
+	// graphic injuries, self-harm methods or illicit drug synthesis before
+	// it reaches a prompt or a response: mask (default), filter or allow
+	SensitivePassages string `json:"sensitive_passages"`
+}
+
+// Handling of sensitive retrieved passages
+const (
+	// PassagesMask replaces the offending sentences with a placeholder
+	PassagesMask = "mask"
+	// PassagesFilter drops the whole article or passage
+	PassagesFilter = "filter"
+	// PassagesAllow leaves retrieved text untouched
+	PassagesAllow = "allow"
+)
+
+// ExcludedTopic is a subject a deployment does not cover. Articles indexed
+// under one of its MeSH headings, or naming one of its terms in the title or
+// abstract, are never retrieved, and questions and answers naming a term are
+// declined with a message naming the topic.
+type ExcludedTopic struct {
+	Name         string   `json:"name"`
+	Terms        []string `json:"terms"`
+	MeshHeadings []string `json:"mesh_headings,omitempty"`
+}
+
+// RateLimit configures per-client request throttling. Zero disables it.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst"`
+}
+
+// AnonymousQuota caps the requests unauthenticated clients make per UTC
+// day: DailyQueries per browser (told apart by a signed cookie, or by IP
+// address without one) and DailyPerIP across all browsers behind one
+// address. DailyQueries of zero disables quotas; DailyPerIP of zero allows
+// ten browsers' worth.
+type AnonymousQuota struct {
+	DailyQueries int `json:"daily_queries"`
+	DailyPerIP   int `json:"daily_per_ip"`
+}
+
+// Retention sets how long personal records are kept, in days. Zero keeps them indefinitely.
+type Retention struct {
+	ChatTranscriptDays int `json:"chat_transcript_days"`
+	QueryLogDays       int `json:"query_log_days"`
+}
+
+// Timeouts set the chat request deadline and the most each stage may use
+// of it, in seconds. Stages are shortened or skipped to fit the total.
+// RerankSeconds of zero disables reranking.
+type Timeouts struct {
+	TotalSeconds      int `json:"total_seconds"`
+	EmbeddingSeconds  int `json:"embedding_seconds"`
+	SearchSeconds     int `json:"search_seconds"`
+	RerankSeconds     int `json:"rerank_seconds"`
+	GenerationSeconds int `json:"generation_seconds"`
+}
+
+// Consent controls whether chat requires an accepted disclaimer before
+// answering medical questions, and which version of the terms is current
+type Consent struct {
+	Required bool   `json:"required"`
+	Version  string `json:"version"`
+}
+
+// LLMConcurrency bounds concurrent calls to the language model provider.
+// Calls beyond MaxConcurrent queue for up to QueueTimeoutSeconds; when
+// MaxQueue calls are already waiting, new ones are rejected with 503.
+// MaxConcurrent of zero disables the limit.
+type LLMConcurrency struct {
+	MaxConcurrent       int `json:"max_concurrent"`
+	MaxQueue            int `json:"max_queue"`
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+}
+
+// PromptBudget fits chat prompts into the model's context window.
+// ContextTokens is the window; CompletionTokens of it are kept for the
+// answer, and the prompt is trimmed to the rest: older conversation first,
+// then the retrieved abstracts, which are shortened to whole sentences.
+type PromptBudget struct {
+	ContextTokens    int `json:"context_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// MinPromptTokens is the least room a prompt budget may leave for the prompt
+const MinPromptTokens = 512
+
+// Shadow mirrors a sample of search queries to an experimental pipeline and
+// logs how its results differ, without changing what clients receive.
+// SampleRate is the share of queries mirrored; zero turns shadowing off.
+// TopK of zero uses each request's limit.
+type Shadow struct {
+	SampleRate float64 `json:"sample_rate"`
+	TopK       int     `json:"top_k"`
+	Rerank     bool    `json:"rerank"`
+}
+
+// Model price tiers, cheapest first
+const (
+	TierFree     = "free"
+	TierStandard = "standard"
+	TierPremium  = "premium"
+)
+
+// ModelChoice is a model chat clients may ask for by ID. Tier is its price
+// class: free, standard or premium.
+type ModelChoice struct {
+	ID   string `json:"id"`
+	Tier string `json:"tier"`
+}
+
+// ModelOverrides lists the models authenticated chat clients may choose per
+// request instead of the server's default. Premium models are limited to
+// PremiumUsers when that list is set. An empty Allowed list disables
+// overrides.
+type ModelOverrides struct {
+	Allowed      []ModelChoice `json:"allowed"`
+	PremiumUsers []string      `json:"premium_users,omitempty"`
+}
+
+// Find returns the allowed model with id
+func (m ModelOverrides) Find(id string) (ModelChoice, bool) {
+	for _, choice := range m.Allowed {
+		if choice.ID == id {
+			return choice, true
+		}
+	}
+	return ModelChoice{}, false
+}
+
+// Qdrant write orderings, fastest first
+const (
+	OrderingWeak   = "weak"
+	OrderingMedium = "medium"
+	OrderingStrong = "strong"
+)
+
+// QdrantWrites sets how the indexer's upserts are acknowledged. Wait makes
+// Qdrant apply each batch before replying, so the count checked after a run
+// is exact instead of eventually consistent; turning it off speeds up bulk
+// loads. Ordering is weak, medium or strong and only matters on a cluster.
+// ShardKey, when set, creates new collections with custom sharding and
+// writes every point under that key.
+type QdrantWrites struct {
+	Wait     bool   `json:"wait"`
+	Ordering string `json:"ordering"`
+	ShardKey string `json:"shard_key,omitempty"`
+}
+
+// Tunables are the settings that can change without restarting a server
+type Tunables struct {
+	SearchTopK int `json:"search_top_k"`
+	// MaxSearchLimit is the most results one search request may ask for
+	MaxSearchLimit int `json:"max_search_limit"`
+	ChatTopK       int `json:"chat_top_k"`
+	// HybridSearch fuses keyword matches into the chat's vector search,
+	// so exact drug names and gene symbols are retrieved
+	HybridSearch bool `json:"hybrid_search"`
+	// LLMSuggestions adds follow-up questions written by the model to the
+	// templated suggestions, at the cost of a model call per message
+	LLMSuggestions bool           `json:"llm_suggestions"`
+	SystemPrompt   string         `json:"system_prompt"`
+	Safety         SafetyRules    `json:"safety"`
+	RateLimit      RateLimit      `json:"rate_limit"`
+	Quota          AnonymousQuota `json:"anonymous_quota"`
+	LogLevel       string         `json:"log_level"`
+	Retention      Retention      `json:"retention"`
+	Consent        Consent        `json:"consent"`
+	Timeouts       Timeouts       `json:"timeouts"`
+	LLM            LLMConcurrency `json:"llm_concurrency"`
+	PromptBudget   PromptBudget   `json:"prompt_budget"`
+	Shadow         Shadow         `json:"shadow"`
+	Models         ModelOverrides `json:"models"`
+	QdrantWrites   QdrantWrites   `json:"qdrant_writes"`
+}
+
+// DefaultTunables returns the values used when no config file is present
+func DefaultTunables() *Tunables {
+	return &Tunables{
+		SearchTopK:     10,
+		MaxSearchLimit: 100,
+		ChatTopK:       1,
+		SystemPrompt:   DefaultSystemPrompt,
+		LogLevel:       "info",
+		Consent:        Consent{Version: "1"},
+		Safety:         SafetyRules{SensitivePassages: PassagesMask},
+		Timeouts: Timeouts{
+			TotalSeconds:      25,
+			EmbeddingSeconds:  3,
+			SearchSeconds:     3,
+			RerankSeconds:     2,
+			GenerationSeconds: 20,
+		},
+		LLM: LLMConcurrency{
+			MaxConcurrent:       8,
+			MaxQueue:            32,
+			QueueTimeoutSeconds: 10,
+		},
+		PromptBudget: PromptBudget{ContextTokens: 8192, CompletionTokens: 1024},
+		QdrantWrites: QdrantWrites{Wait: true, Ordering: OrderingWeak},
+	}
+}
+
+// Validate rejects snapshots that would break the servers
+func (t *Tunables) Validate() error {
+	if t.SearchTopK < 1 || t.SearchTopK > 100 {
+		return fmt.Errorf("search_top_k must be between 1 and 100, got %d", t.SearchTopK)
+	}
+	if t.MaxSearchLimit < t.SearchTopK || t.MaxSearchLimit > 1000 {
+		return fmt.Errorf("max_search_limit must be between search_top_k and 1000, got %d", t.MaxSearchLimit)
+	}
+	if t.ChatTopK < 1 || t.ChatTopK > 20 {
+		return fmt.Errorf("chat_top_k must be between 1 and 20, got %d", t.ChatTopK)
+	}
+	if strings.TrimSpace(t.SystemPrompt) == "" {
+		return fmt.Errorf("system_prompt must not be empty")
+	}
+	for _, topic := range t.Safety.ExcludedTopics {
+		if strings.TrimSpace(topic.Name) == "" {
+			return fmt.Errorf("safety.excluded_topics entries need a name")
+		}
+		if len(topic.Terms) == 0 && len(topic.MeshHeadings) == 0 {
+			return fmt.Errorf("safety.excluded_topics %q needs terms or mesh_headings", topic.Name)
+		}
+		for _, term := range topic.Terms {
+			if strings.TrimSpace(term) == "" {
+				return fmt.Errorf("safety.excluded_topics %q has an empty term", topic.Name)
+			}
+		}
+	}
+	switch t.Safety.SensitivePassages {
+	case PassagesMask, PassagesFilter, PassagesAllow:
+	default:
+		return fmt.Errorf("safety.sensitive_passages must be one of mask, filter, allow, got %q", t.Safety.SensitivePassages)
+	}
+	if t.RateLimit.RequestsPerMinute < 0 || t.RateLimit.Burst < 0 {
+		return fmt.Errorf("rate_limit values must not be negative")
+	}
+	if t.Quota.DailyQueries < 0 || t.Quota.DailyPerIP < 0 {
+		return fmt.Errorf("anonymous_quota values must not be negative")
+	}
+	if t.Quota.DailyPerIP > 0 && t.Quota.DailyPerIP < t.Quota.DailyQueries {
+		return fmt.Errorf("anonymous_quota.daily_per_ip must be at least daily_queries, got %d", t.Quota.DailyPerIP)
+	}
+	if t.Retention.ChatTranscriptDays < 0 || t.Retention.QueryLogDays < 0 {
+		return fmt.Errorf("retention values must not be negative")
+	}
+	if err := t.Timeouts.validate(); err != nil {
+		return err
+	}
+	if t.LLM.MaxConcurrent < 0 || t.LLM.MaxQueue < 0 || t.LLM.QueueTimeoutSeconds < 0 {
+		return fmt.Errorf("llm_concurrency values must not be negative")
+	}
+	if t.PromptBudget.CompletionTokens < 1 {
+		return fmt.Errorf("prompt_budget.completion_tokens must be positive, got %d", t.PromptBudget.CompletionTokens)
+	}
+	if t.PromptBudget.ContextTokens-t.PromptBudget.CompletionTokens < MinPromptTokens {
+		return fmt.Errorf("prompt_budget.context_tokens must leave at least %d tokens beyond completion_tokens, got %d", MinPromptTokens, t.PromptBudget.ContextTokens)
+	}
+	if t.Shadow.SampleRate < 0 || t.Shadow.SampleRate > 1 {
+		return fmt.Errorf("shadow.sample_rate must be between 0 and 1, got %g", t.Shadow.SampleRate)
+	}
+	if t.Shadow.TopK < 0 || t.Shadow.TopK > 100 {
+		return fmt.Errorf("shadow.top_k must be between 0 and 100, got %d", t.Shadow.TopK)
+	}
+	seenModels := make(map[string]bool, len(t.Models.Allowed))
+	for _, choice := range t.Models.Allowed {
+		if strings.TrimSpace(choice.ID) == "" {
+			return fmt.Errorf("models.allowed entries need an id")
+		}
+		if seenModels[choice.ID] {
+			return fmt.Errorf("models.allowed lists %q twice", choice.ID)
+		}
+		seenModels[choice.ID] = true
+		switch choice.Tier {
+		case TierFree, TierStandard, TierPremium:
+		default:
+			return fmt.Errorf("models.allowed tier for %q must be one of free, standard, premium, got %q", choice.ID, choice.Tier)
+		}
+	}
+	switch t.QdrantWrites.Ordering {
+	case OrderingWeak, OrderingMedium, OrderingStrong:
+	default:
+		return fmt.Errorf("qdrant_writes.ordering must be one of weak, medium, strong, got %q", t.QdrantWrites.Ordering)
+	}
+	if t.Consent.Required && strings.TrimSpace(t.Consent.Version) == "" {
+		return fmt.Errorf("consent.version must be set when consent is required")
+	}
+	switch t.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("log_level must be one of debug, info, warn, error, got %q", t.LogLevel)
+	}
+	return nil
+}
+
+// Changes lists the JSON names of the top-level settings that differ between
+// two snapshots, e.g. ["safety", "system_prompt"]
+func Changes(old, next *Tunables) []string {
+	if old == nil || next == nil {
+		return nil
+	}
+	var changed []string
+	oldValue, nextValue := reflect.ValueOf(*old), reflect.ValueOf(*next)
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+func (t Timeouts) validate() error {
+	if t.TotalSeconds < 1 || t.TotalSeconds > 300 {
+		return fmt.Errorf("timeouts.total_seconds must be between 1 and 300, got %d", t.TotalSeconds)
+	}
+	if t.EmbeddingSeconds < 1 || t.SearchSeconds < 1 || t.GenerationSeconds < 1 {
+		return fmt.Errorf("timeouts for embedding, search and generation must be at least 1 second")
+	}
+	if t.RerankSeconds < 0 {
+		return fmt.Errorf("timeouts.rerank_seconds must not be negative")
+	}
+	stages := []struct {
+		name    string
+		seconds int
+	}{
+		{"embedding_seconds", t.EmbeddingSeconds},
+		{"search_seconds", t.SearchSeconds},
+		{"rerank_seconds", t.RerankSeconds},
+		{"generation_seconds", t.GenerationSeconds},
+	}
+	for _, stage := range stages {
+		if stage.seconds > t.TotalSeconds {
+			return fmt.Errorf("timeouts.%s (%d) exceeds total_seconds (%d)", stage.name, stage.seconds, t.TotalSeconds)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package consent
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where acknowledgments are kept when CONSENT_FILE is unset
+const DefaultPath = "data/consent.json"
+
+// Acknowledgment records that a subject accepted a version of the terms and
+// medical disclaimer. Subject is "user:<id>", or "session:<id>" for anonymous use.
+type Acknowledgment struct {
+	Subject    string    `json:"subject"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// Store keeps every acknowledgment ever recorded, since older versions remain
+// evidence of what a user agreed to at the time. It is safe for concurrent use.
+type Store struct {
+	mu    sync.RWMutex
+	path  string
+	acks  []Acknowledgment
+	Clock clock.Clock
+}
+
+// NewStore loads path if it exists. An empty path keeps acknowledgments in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, Clock: clock.System}
+	if path == "" {
+		return s, nil
+	}
+	if _, err := jsonfile.Read(path, &s.acks); err != nil {
+		return nil, fmt.Errorf("failed to load consent records: %w", err)
+	}
+	return s, nil
+}
+
+// Accept records that subject accepted version
+func (s *Store) Accept(subject, version string) (*Acknowledgment, error) {
+	subject, version = strings.TrimSpace(subject), strings.TrimSpace(version)
+	if subject == "" || version == "" {
+		return nil, fmt.Errorf("%w: subject and version are required", apperrors.ErrInvalidInput)
+	}
+
+	ack := Acknowledgment{Subject: subject, Version: version, AcceptedAt: s.Clock.Now().UTC()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acks = append(s.acks, ack)
+	if s.path != "" {
+		if err := jsonfile.WriteAtomic(s.path, s.acks); err != nil {
+			s.acks = s.acks[:len(s.acks)-1]
+			return nil, err
+		}
+	}
+	return &ack, nil
+}
+
+// Latest returns the subject's most recent acknowledgment, if any
+func (s *Store) Latest(subject string) (Acknowledgment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.acks) - 1; i >= 0; i-- {
+		if s.acks[i].Subject == subject {
+			return s.acks[i], true
+		}
+	}
+	return Acknowledgment{}, false
+}
+
+// HasAccepted reports whether subject has accepted exactly version
+func (s *Store) HasAccepted(subject, version string) bool {
+	if subject == "" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ack := range s.acks {
+		if ack.Subject == subject && ack.Version == version {
+			return true
+		}
+	}
+	return false
+}
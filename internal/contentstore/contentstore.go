@@ -0,0 +1,272 @@
+// Package contentstore keeps long payload texts, such as abstracts and
+// full-text sections, outside Qdrant. The indexer stores each text once,
+// keyed by its SHA-256, and leaves a snippet and the hash in the payload;
+// readers wrap their points client in a Client, which puts the full text
+// back into the payloads it returns.
+package contentstore
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultDir is used when CONTENT_STORE_DIR is not set
+const DefaultDir = "data/content"
+
+// SnippetChars is the length of the text kept in the payload; texts no
+// longer than this are not moved to the store
+const SnippetChars = 300
+
+// RefFields maps each payload text field moved to the store to the field
+// holding its hash
+var RefFields = map[string]string{
+	"abstract": "abstract_hash",
+	"text":     "text_hash",
+}
+
+// Store is a content-addressed directory of gzipped texts, laid out like
+// git objects: <dir>/<first two hex digits>/<rest of the hash>.gz
+type Store struct {
+	Dir string
+}
+
+func Open(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// FromEnv opens the store in CONTENT_STORE_DIR, or DefaultDir
+func FromEnv() *Store {
+	dir := os.Getenv("CONTENT_STORE_DIR")
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return Open(dir)
+}
+
+// Hash returns the key text is stored under
+func Hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash[2:]+".gz")
+}
+
+// Put stores text and returns its hash. Texts already stored are not
+// written again.
+func (s *Store) Put(text string) (string, error) {
+	hash := Hash(text)
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	gz := gzip.NewWriter(tmp)
+	if _, err := io.WriteString(gz, text); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+	return hash, nil
+}
+
+// Get returns the text stored under hash, or an error wrapping
+// apperrors.ErrNotFound
+func (s *Store) Get(hash string) (string, error) {
+	if len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: invalid content hash %q", apperrors.ErrInvalidInput, hash)
+	}
+	file, err := os.Open(s.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: content %s", apperrors.ErrNotFound, hash)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read content: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read content %s: %w", hash, err)
+	}
+	text, err := io.ReadAll(gz)
+	if err != nil {
+		return "", fmt.Errorf("failed to read content %s: %w", hash, err)
+	}
+	return string(text), nil
+}
+
+// Compact moves the long texts of payload into the store, leaving a snippet
+// and the hash in their place
+func (s *Store) Compact(payload map[string]*qdrant.Value) error {
+	for field, hashField := range RefFields {
+		text := payload[field].GetStringValue()
+		if len(text) <= SnippetChars {
+			continue
+		}
+		hash, err := s.Put(text)
+		if err != nil {
+			return err
+		}
+		payload[field] = qdrant.NewValueString(Snippet(text))
+		payload[hashField] = qdrant.NewValueString(hash)
+	}
+	return nil
+}
+
+// Text returns the full text of a payload field, from the store when the
+// payload only holds a snippet. It falls back to the snippet when the
+// stored text is missing.
+func (s *Store) Text(payload map[string]*qdrant.Value, field string) string {
+	text := payload[field].GetStringValue()
+	hash := payload[RefFields[field]].GetStringValue()
+	if hash == "" {
+		return text
+	}
+	full, err := s.Get(hash)
+	if err != nil {
+		log.Printf("⚠️  Content store: %v", err)
+		return text
+	}
+	return full
+}
+
+// Hydrate replaces the snippets in payload with their full texts
+func (s *Store) Hydrate(payload map[string]*qdrant.Value) {
+	for field, hashField := range RefFields {
+		if payload[hashField].GetStringValue() == "" || payload[field] == nil {
+			continue
+		}
+		payload[field] = qdrant.NewValueString(s.Text(payload, field))
+	}
+}
+
+// Snippet returns the first SnippetChars bytes of text, cut at a rune
+// boundary and marked as shortened
+func Snippet(text string) string {
+	if len(text) <= SnippetChars {
+		return text
+	}
+	cut := SnippetChars
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// Client returns the payloads read through Points with their full texts
+type Client struct {
+	Points points.Reader
+	Store  *Store
+}
+
+func NewClient(points points.Reader, store *Store) *Client {
+	return &Client{Points: points, Store: store}
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	in = proto.Clone(in).(*qdrant.SearchPoints)
+	in.WithPayload = withHashes(in.WithPayload)
+	resp, err := c.Points.Search(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range resp.GetResult() {
+		c.Store.Hydrate(point.Payload)
+	}
+	return resp, nil
+}
+
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	in = proto.Clone(in).(*qdrant.GetPoints)
+	in.WithPayload = withHashes(in.WithPayload)
+	resp, err := c.Points.Get(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range resp.GetResult() {
+		c.Store.Hydrate(point.Payload)
+	}
+	return resp, nil
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	in = proto.Clone(in).(*qdrant.ScrollPoints)
+	in.WithPayload = withHashes(in.WithPayload)
+	resp, err := c.Points.Scroll(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range resp.GetResult() {
+		c.Store.Hydrate(point.Payload)
+	}
+	return resp, nil
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	in = proto.Clone(in).(*qdrant.QueryPoints)
+	in.WithPayload = withHashes(in.WithPayload)
+	resp, err := c.Points.Query(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, point := range resp.GetResult() {
+		c.Store.Hydrate(point.Payload)
+	}
+	return resp, nil
+}
+
+// withHashes adds the hash fields to a payload selector that includes the
+// text fields they belong to, so those can be hydrated
+func withHashes(selector *qdrant.WithPayloadSelector) *qdrant.WithPayloadSelector {
+	include := selector.GetInclude()
+	if include == nil {
+		return selector
+	}
+	fields := append([]string(nil), include.GetFields()...)
+	for _, field := range include.GetFields() {
+		if hashField, ok := RefFields[field]; ok {
+			fields = append(fields, hashField)
+		}
+	}
+	return &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+		Include: &qdrant.PayloadIncludeSelector{Fields: fields},
+	}}
+}
@@ -0,0 +1,222 @@
+// Package docstore keeps the full normalized article records outside
+// Qdrant, keyed by article ID. With a document store the indexer leaves
+// only the fields searches filter and match on in the Qdrant payload
+// (PayloadFields); readers wrap their points client in a Client, which
+// fills the payloads it returns from the stored records.
+package docstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// Store holds articles by ID
+type Store interface {
+	// Put adds or replaces articles
+	Put(ctx context.Context, articles []models.MedicalArticle) error
+	// Get returns the stored articles among ids, by ID; unknown IDs are
+	// left out
+	Get(ctx context.Context, ids []string) (map[string]models.MedicalArticle, error)
+	// Delete removes the articles with ids, ignoring unknown IDs
+	Delete(ctx context.Context, ids []string) error
+}
+
+// PayloadFields are the payload fields kept in Qdrant when a document store
+// holds the full records: the ones filters, keyword matching, tiering and
+// chat retrieval read without hydration
+var PayloadFields = []string{
+	"id", "title", "abstract", "abstract_hash", "published_date", "published_ts", "published_year", "source", "journal", "doi",
+	"mesh_headings", "publication_types", "nct_ids", "genes", "variants", "drugs", "access_label",
+}
+
+// DefaultDir is where the file store keeps its records
+const DefaultDir = "data/documents"
+
+// FromEnv opens the store selected by DOC_STORE: "file" (in DOC_STORE_DIR,
+// default DefaultDir) or "sqlite" (DOC_STORE_SQL_DRIVER, default the
+// pure-Go sqlite driver linked in here, and DOC_STORE_SQL_DSN, default
+// data/documents.db). It returns nil when
+// DOC_STORE is unset, and Qdrant payloads keep every field.
+func FromEnv() (Store, error) {
+	switch backend := os.Getenv("DOC_STORE"); backend {
+	case "", "none":
+		return nil, nil
+	case "file":
+		return NewFileStore(envOr("DOC_STORE_DIR", DefaultDir)), nil
+	case "sqlite":
+		db, err := sql.Open(envOr("DOC_STORE_SQL_DRIVER", "sqlite"), envOr("DOC_STORE_SQL_DSN", "data/documents.db"))
+		if err != nil {
+			return nil, fmt.Errorf("document store: %w", err)
+		}
+		return NewSQLStore(db)
+	default:
+		return nil, fmt.Errorf("DOC_STORE must be file or sqlite, got %q", backend)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Minimize removes the payload fields not in PayloadFields, before the
+// point is upserted with the full record in a store
+func Minimize(payload map[string]*qdrant.Value) {
+	for field := range payload {
+		if !slices.Contains(PayloadFields, field) {
+			delete(payload, field)
+		}
+	}
+}
+
+// Client fills the payloads read through Points from the stored records,
+// so callers see every article field whatever Qdrant keeps. Points whose
+// article is not in the store keep their Qdrant payload, and a failing
+// store only costs the extra fields.
+type Client struct {
+	Points points.Reader
+	Store  Store
+}
+
+func NewClient(points points.Reader, store Store) *Client {
+	return &Client{Points: points, Store: store}
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	selector := in.WithPayload
+	in = proto.Clone(in).(*qdrant.SearchPoints)
+	in.WithPayload = withID(selector)
+	resp, err := c.Points.Search(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]*qdrant.Value, len(resp.GetResult()))
+	for i, point := range resp.GetResult() {
+		payloads[i] = point.Payload
+	}
+	c.hydrate(ctx, selector, payloads)
+	return resp, nil
+}
+
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	selector := in.WithPayload
+	in = proto.Clone(in).(*qdrant.GetPoints)
+	in.WithPayload = withID(selector)
+	resp, err := c.Points.Get(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]*qdrant.Value, len(resp.GetResult()))
+	for i, point := range resp.GetResult() {
+		payloads[i] = point.Payload
+	}
+	c.hydrate(ctx, selector, payloads)
+	return resp, nil
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	selector := in.WithPayload
+	in = proto.Clone(in).(*qdrant.ScrollPoints)
+	in.WithPayload = withID(selector)
+	resp, err := c.Points.Scroll(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]*qdrant.Value, len(resp.GetResult()))
+	for i, point := range resp.GetResult() {
+		payloads[i] = point.Payload
+	}
+	c.hydrate(ctx, selector, payloads)
+	return resp, nil
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	selector := in.WithPayload
+	in = proto.Clone(in).(*qdrant.QueryPoints)
+	in.WithPayload = withID(selector)
+	resp, err := c.Points.Query(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]map[string]*qdrant.Value, len(resp.GetResult()))
+	for i, point := range resp.GetResult() {
+		payloads[i] = point.Payload
+	}
+	c.hydrate(ctx, selector, payloads)
+	return resp, nil
+}
+
+// hydrate adds the fields of the stored records to payloads, limited to
+// the fields selector asks for. Fields Qdrant returned are kept: they may
+// be newer than the record, and hold the content store hashes.
+func (c *Client) hydrate(ctx context.Context, selector *qdrant.WithPayloadSelector, payloads []map[string]*qdrant.Value) {
+	if selector == nil || selector.GetExclude() != nil || (selector.GetInclude() == nil && !selector.GetEnable()) {
+		return
+	}
+	var ids []string
+	for _, payload := range payloads {
+		if id := payload["id"].GetStringValue(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	articles, err := c.Store.Get(ctx, ids)
+	if err != nil {
+		log.Printf("⚠️  Document store: %v", err)
+		return
+	}
+	include := selector.GetInclude().GetFields()
+	for _, payload := range payloads {
+		article, ok := articles[payload["id"].GetStringValue()]
+		if !ok {
+			continue
+		}
+		for field, value := range ai.ArticlePayload(&article) {
+			if _, returned := payload[field]; returned {
+				continue
+			}
+			if include != nil && !slices.Contains(include, field) {
+				continue
+			}
+			payload[field] = value
+		}
+	}
+	if include != nil && !slices.Contains(include, "id") {
+		for _, payload := range payloads {
+			delete(payload, "id")
+		}
+	}
+}
+
+// withID adds the article ID, which records are looked up by, to a payload
+// selector that includes some fields only
+func withID(selector *qdrant.WithPayloadSelector) *qdrant.WithPayloadSelector {
+	include := selector.GetInclude()
+	if include == nil || slices.Contains(include.GetFields(), "id") {
+		return selector
+	}
+	return &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+		Include: &qdrant.PayloadIncludeSelector{Fields: append(append([]string(nil), include.GetFields()...), "id")},
+	}}
+}
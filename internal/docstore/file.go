@@ -0,0 +1,70 @@
+package docstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"MedAtlasAIServer/internal/jsonfile"
+	"MedAtlasAIServer/internal/models"
+)
+
+// FileStore keeps one JSON file per article, at
+// <dir>/<first two hex digits>/<SHA-256 of the ID>.json, so IDs with
+// slashes or colons are safe and no directory grows too large
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(s.Dir, hash[:2], hash[2:]+".json")
+}
+
+func (s *FileStore) Put(ctx context.Context, articles []models.MedicalArticle) error {
+	for i := range articles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := jsonfile.WriteAtomic(s.path(articles[i].ID), &articles[i]); err != nil {
+			return fmt.Errorf("failed to store article %s: %w", articles[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) Get(ctx context.Context, ids []string) (map[string]models.MedicalArticle, error) {
+	articles := make(map[string]models.MedicalArticle, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var article models.MedicalArticle
+		found, err := jsonfile.Read(s.path(id), &article)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			articles[id] = article
+		}
+	}
+	return articles, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete article %s: %w", id, err)
+		}
+	}
+	return nil
+}
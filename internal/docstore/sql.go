@@ -0,0 +1,113 @@
+package docstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/models"
+)
+
+const createDocumentsTable = `CREATE TABLE IF NOT EXISTS documents (
+	id TEXT PRIMARY KEY,
+	article TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+)`
+
+// maxSQLParams keeps IN lists under SQLite's default bound parameter limit
+const maxSQLParams = 500
+
+// SQLStore keeps articles in a SQLite database (any database/sql driver
+// accepting SQLite syntax), one row per article with the record as JSON
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore creates the documents table in db if needed
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	if _, err := db.Exec(createDocumentsTable); err != nil {
+		return nil, fmt.Errorf("failed to create documents table: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+func (s *SQLStore) Put(ctx context.Context, articles []models.MedicalArticle) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to store articles: %w", err)
+	}
+	defer tx.Rollback() // no-op after a successful commit
+
+	now := time.Now().Unix()
+	for i := range articles {
+		record, err := json.Marshal(&articles[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal article %s: %w", articles[i].ID, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO documents (id, article, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET article = excluded.article, updated_at = excluded.updated_at`,
+			articles[i].ID, string(record), now)
+		if err != nil {
+			return fmt.Errorf("failed to store article %s: %w", articles[i].ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store articles: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, ids []string) (map[string]models.MedicalArticle, error) {
+	articles := make(map[string]models.MedicalArticle, len(ids))
+	for start := 0; start < len(ids); start += maxSQLParams {
+		chunk := ids[start:min(len(ids), start+maxSQLParams)]
+		rows, err := s.db.QueryContext(ctx, `SELECT id, article FROM documents WHERE id IN (`+placeholders(len(chunk))+`)`, args(chunk)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load articles: %w", err)
+		}
+		for rows.Next() {
+			var id, record string
+			if err := rows.Scan(&id, &record); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to load articles: %w", err)
+			}
+			var article models.MedicalArticle
+			if err := json.Unmarshal([]byte(record), &article); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to parse article %s: %w", id, err)
+			}
+			articles[id] = article
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load articles: %w", err)
+		}
+	}
+	return articles, nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, ids []string) error {
+	for start := 0; start < len(ids); start += maxSQLParams {
+		chunk := ids[start:min(len(ids), start+maxSQLParams)]
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM documents WHERE id IN (`+placeholders(len(chunk))+`)`, args(chunk)...); err != nil {
+			return fmt.Errorf("failed to delete articles: %w", err)
+		}
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func args(ids []string) []any {
+	values := make([]any, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return values
+}
@@ -0,0 +1,205 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"MedAtlasAIServer/internal/alert"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/jsonfile"
+	"MedAtlasAIServer/internal/logging"
+)
+
+// DefaultBaselinePath is where the probe vectors are stored on first run
+const DefaultBaselinePath = "data/drift/embedding_baseline.json"
+
+// CheckInterval is how often servers re-embed the probes
+const CheckInterval = time.Hour
+
+// DefaultMinSimilarity is the cosine similarity every probe must keep to its
+// baseline vector. The same model on different hardware stays well above
+// it; a different model or revision falls far below.
+const DefaultMinSimilarity = 0.99
+
+// Probes are fixed sentences spanning the vocabulary the index covers. They
+// must never change, or every deployment's baseline becomes stale.
+var Probes = []string{
+	"Randomized controlled trial of metformin in adults with type 2 diabetes",
+	"Pembrolizumab immunotherapy for advanced non-small cell lung cancer",
+	"Risk factors for postpartum depression in first-time mothers",
+	"Statin therapy and cardiovascular events in elderly patients",
+	"BRCA1 c.68_69delAG carriers and breast cancer screening",
+	"Antibiotic resistance in community-acquired pneumonia",
+	"Cognitive behavioural therapy for chronic insomnia",
+	"Vaccine effectiveness against influenza hospitalization in children",
+	"What are the side effects of ibuprofen?",
+	"Meta-analysis of exercise interventions for knee osteoarthritis pain",
+}
+
+// Embedder is the service being watched (implemented by embeddingClient.Client)
+type Embedder interface {
+	GetEmbedding(text string) ([]float32, error)
+}
+
+// Baseline is the probe vectors recorded when monitoring started
+type Baseline struct {
+	Recorded time.Time   `json:"recorded"`
+	Probes   []string    `json:"probes"`
+	Vectors  [][]float32 `json:"vectors"`
+}
+
+// ProbeResult compares one probe with its baseline
+type ProbeResult struct {
+	Probe      string  `json:"probe"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Report is the outcome of one check
+type Report struct {
+	Checked       time.Time     `json:"checked"`
+	Drifted       bool          `json:"drifted"`
+	Reason        string        `json:"reason,omitempty"`
+	MinSimilarity float64       `json:"min_similarity"`
+	Probes        []ProbeResult `json:"probes,omitempty"`
+}
+
+// Monitor compares fresh probe embeddings with the stored baseline and
+// alerts when they differ, which means the embedding service's model changed
+// underneath an index built with the old one
+type Monitor struct {
+	Embedder      Embedder
+	BaselinePath  string
+	MinSimilarity float64
+	Alerts        *alert.Notifier
+	Clock         clock.Clock
+
+	drifted bool // the last check drifted, so the alert has been sent
+}
+
+func NewMonitor(embedder Embedder, alerts *alert.Notifier) *Monitor {
+	return &Monitor{
+		Embedder:      embedder,
+		BaselinePath:  DefaultBaselinePath,
+		MinSimilarity: DefaultMinSimilarity,
+		Alerts:        alerts,
+		Clock:         clock.System,
+	}
+}
+
+// Check embeds the probes and compares them with the baseline, recording
+// the baseline first if there is none. After reindexing with a new model,
+// delete the baseline file so the next check records a new one. It returns
+// an error when the embedding service or baseline file cannot be used; drift
+// is reported in the Report, not as an error, and alerted once until the
+// output matches the baseline again.
+func (m *Monitor) Check(ctx context.Context) (*Report, error) {
+	vectors := make([][]float32, len(Probes))
+	for i, probe := range Probes {
+		vector, err := m.Embedder.GetEmbedding(probe)
+		if err != nil {
+			return nil, fmt.Errorf("embedding probe %d: %w", i, err)
+		}
+		vectors[i] = vector
+	}
+
+	var baseline Baseline
+	found, err := jsonfile.Read(m.BaselinePath, &baseline)
+	if err != nil {
+		return nil, err
+	}
+	now := m.Clock.Now()
+	if !found {
+		baseline = Baseline{Recorded: now, Probes: Probes, Vectors: vectors}
+		if err := jsonfile.WriteAtomic(m.BaselinePath, baseline); err != nil {
+			return nil, err
+		}
+		log.Printf("📌 Recorded embedding baseline of %d probes in %s", len(Probes), m.BaselinePath)
+		return &Report{Checked: now, MinSimilarity: 1}, nil
+	}
+
+	report := compare(baseline, vectors, m.MinSimilarity)
+	report.Checked = now
+	alreadyAlerted := m.drifted
+	m.drifted = report.Drifted
+	if report.Drifted && !alreadyAlerted {
+		err := m.Alerts.Notify(ctx, alert.Alert{
+			Source:  "embedding-drift",
+			Summary: fmt.Sprintf("Embedding service output no longer matches the baseline from %s: %s", baseline.Recorded.Format(time.RFC3339), report.Reason),
+			Details: report,
+			Time:    now,
+		})
+		if err != nil {
+			log.Printf("⚠️  Drift alert delivery failed: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// compare scores vectors against the baseline
+func compare(baseline Baseline, vectors [][]float32, minSimilarity float64) *Report {
+	report := &Report{MinSimilarity: 1}
+	if !slices.Equal(baseline.Probes, Probes) || len(baseline.Vectors) != len(vectors) {
+		report.Drifted = true
+		report.Reason = "the probe set changed; delete the baseline to record a new one"
+		return report
+	}
+	for i, vector := range vectors {
+		if len(vector) != len(baseline.Vectors[i]) {
+			report.Drifted = true
+			report.Reason = fmt.Sprintf("dimension changed from %d to %d", len(baseline.Vectors[i]), len(vector))
+			report.MinSimilarity = 0
+			return report
+		}
+		similarity := cosine(vector, baseline.Vectors[i])
+		report.Probes = append(report.Probes, ProbeResult{Probe: Probes[i], Similarity: similarity})
+		report.MinSimilarity = math.Min(report.MinSimilarity, similarity)
+	}
+	if report.MinSimilarity < minSimilarity {
+		report.Drifted = true
+		report.Reason = fmt.Sprintf("lowest probe similarity %.4f is below %.4f", report.MinSimilarity, minSimilarity)
+	}
+	return report
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Run checks immediately and then every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	m.runOnce(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.runOnce(ctx)
+		}
+	}
+}
+
+func (m *Monitor) runOnce(ctx context.Context) {
+	report, err := m.Check(ctx)
+	if err != nil {
+		log.Printf("⚠️  Embedding drift check failed: %v", err)
+		return
+	}
+	if !report.Drifted {
+		logging.Debugf("embedding drift check passed, lowest probe similarity %.4f", report.MinSimilarity)
+	}
+}
@@ -0,0 +1,160 @@
+package embeddingClient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+)
+
+// Headers of signed requests
+const (
+	SignatureHeader = "X-Signature"           // hex HMAC-SHA256 of the canonical request
+	TimestampHeader = "X-Signature-Timestamp" // Unix seconds the request was signed at
+)
+
+// MaxSignatureAge bounds how far a signed request's timestamp may be from
+// the server's clock, so a captured request cannot be replayed for long
+const MaxSignatureAge = 5 * time.Minute
+
+// OpenPaths are served without credentials, for health checks and scrapes
+var OpenPaths = []string{"/health", "/metrics"}
+
+// Auth is how an environment authenticates calls to its embedding service,
+// so the service can listen beyond localhost. With Token set, requests carry
+// it as a bearer token; with Secret set, they are signed with HMAC-SHA256
+// over the method, path, timestamp and body hash, which also keeps bodies
+// from being altered in transit. Both may be set, and servers then require
+// both. The zero Auth sends and requires nothing.
+type Auth struct {
+	Token  string
+	Secret string
+}
+
+// AuthFromEnv reads EMBEDDING_AUTH_TOKEN and EMBEDDING_HMAC_SECRET; clients
+// and the services they call are given the same values
+func AuthFromEnv() Auth {
+	return Auth{
+		Token:  strings.TrimSpace(os.Getenv("EMBEDDING_AUTH_TOKEN")),
+		Secret: strings.TrimSpace(os.Getenv("EMBEDDING_HMAC_SECRET")),
+	}
+}
+
+// Enabled reports whether a credential is set
+func (a Auth) Enabled() bool {
+	return a.Token != "" || a.Secret != ""
+}
+
+// signature returns the hex HMAC of a request
+func (a Auth) signature(method, path, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(a.Secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, path, timestamp, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign adds the credentials to req, whose body is body
+func (a Auth) Sign(req *http.Request, body []byte, now time.Time) {
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	if a.Secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, a.signature(req.Method, req.URL.Path, timestamp, body))
+	}
+}
+
+// Verify checks the credentials of r, whose body is body, against a
+func (a Auth) Verify(r *http.Request, body []byte, now time.Time) error {
+	if a.Token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(a.Token)) != 1 {
+			return fmt.Errorf("%w: invalid bearer token", apperrors.ErrUnauthorized)
+		}
+	}
+	if a.Secret != "" {
+		timestamp := r.Header.Get(TimestampHeader)
+		signed, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: missing signature timestamp", apperrors.ErrUnauthorized)
+		}
+		if age := now.Sub(time.Unix(signed, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+			return fmt.Errorf("%w: signature expired", apperrors.ErrUnauthorized)
+		}
+		expected := a.signature(r.Method, r.URL.Path, timestamp, body)
+		if !hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(expected)) {
+			return fmt.Errorf("%w: invalid signature", apperrors.ErrUnauthorized)
+		}
+	}
+	return nil
+}
+
+// Middleware refuses requests whose credentials do not match a, except on
+// OpenPaths
+func (a Auth) Middleware(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(OpenPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			apperrors.Write(w, apperrors.ErrInvalidInput, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := a.Verify(r, body, time.Now()); err != nil {
+			apperrors.Write(w, err, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// signingTransport adds an Auth's credentials to every request
+type signingTransport struct {
+	auth Auth
+	base http.RoundTripper
+}
+
+// NewTransport returns a RoundTripper sending requests through base, or
+// http.DefaultTransport when base is nil, with auth's credentials
+func NewTransport(auth Auth, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{auth: auth, base: base}
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		copied, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for signing: %w", err)
+		}
+		body, err = io.ReadAll(copied)
+		copied.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for signing: %w", err)
+		}
+	}
+	signed := req.Clone(req.Context())
+	t.auth.Sign(signed, body, time.Now())
+	return t.base.RoundTrip(signed)
+}
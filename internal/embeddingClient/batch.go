@@ -0,0 +1,207 @@
+package embeddingClient
+
+import (
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbedBatchRequest embeds several texts in one request
+type EmbedBatchRequest struct {
+	Texts []string `json:"texts"`
+}
+
+type EmbedBatchResponse struct {
+	Vectors [][]float32 `json:"vectors"`
+	Model   string      `json:"model"`
+	Dims    int         `json:"dims"`
+}
+
+// GetEmbeddings embeds texts in one request to the service's batch
+// endpoint; the vectors are in text order. Services without the endpoint
+// answer with an error wrapping apperrors.ErrNotFound.
+func (c *Client) GetEmbeddings(ctx context.Context, texts []string) (vectors [][]float32, err error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	ctx, done := instrument(ctx, "embed_batch")
+	defer done(&err)
+	jsonData, err := json.Marshal(EmbedBatchRequest{Texts: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/embed/batch", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: HTTP request failed: %w", apperrors.ErrEmbeddingUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: embedding service has no batch endpoint", apperrors.ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w: batch embedding returned error: %s - %s", statusError(resp.StatusCode), resp.Status, string(body))
+	}
+	var batchResp EmbedBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batchResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(batchResp.Vectors) != len(texts) {
+		return nil, fmt.Errorf("%w: batch embedding returned %d vectors for %d texts", apperrors.ErrEmbeddingUnavailable, len(batchResp.Vectors), len(texts))
+	}
+	return batchResp.Vectors, nil
+}
+
+// BatchTiming is the latency of one probe request of Size texts
+type BatchTiming struct {
+	Size    int
+	Latency time.Duration
+}
+
+// Throughput is the texts embedded per second at this batch size
+func (t BatchTiming) Throughput() float64 {
+	return float64(t.Size) / t.Latency.Seconds()
+}
+
+// probeText stands in for a typical title and abstract
+var probeText = strings.Repeat("Randomized controlled trial of adjuvant immunotherapy in patients with resected melanoma; overall survival and adverse events. ", 10)
+
+// minProbeGain is the throughput gain a doubled batch must bring for the
+// probe to keep doubling
+const minProbeGain = 1.1
+
+// maxProbeLatency stops the probe before batches get slow enough to time
+// out under load
+const maxProbeLatency = 5 * time.Second
+
+// ProbeBatchSize measures the service's latency for batches of 1, 2, 4, ...
+// up to maxSize texts and returns the batch size with the best throughput,
+// with the timings measured. Doubling stops once it no longer gains
+// minProbeGain, where the GPU (or CPU) is saturated. Services without the
+// batch endpoint return size 1 and the error.
+func (c *Client) ProbeBatchSize(ctx context.Context, maxSize int) (int, []BatchTiming, error) {
+	// Warm up, so model loading and connection setup are not measured
+	if _, err := c.GetEmbeddings(ctx, []string{probeText}); err != nil {
+		return 1, nil, err
+	}
+
+	var timings []BatchTiming
+	best := BatchTiming{Size: 1}
+	for size := 1; size <= maxSize; size *= 2 {
+		texts := make([]string, size)
+		for i := range texts {
+			texts[i] = fmt.Sprintf("%s (%d)", probeText, i) // distinct, so nothing is served from a cache
+		}
+		start := time.Now()
+		if _, err := c.GetEmbeddings(ctx, texts); err != nil {
+			return best.Size, timings, err
+		}
+		timing := BatchTiming{Size: size, Latency: time.Since(start)}
+		timings = append(timings, timing)
+
+		if best.Latency != 0 && timing.Throughput() < best.Throughput()*minProbeGain {
+			break // past the knee of the latency curve
+		}
+		best = timing
+		if timing.Latency > maxProbeLatency {
+			break
+		}
+	}
+	return best.Size, timings, nil
+}
+
+// Batcher groups GetEmbedding calls from concurrent goroutines into batch
+// requests of up to Size texts. A batch is sent when full or MaxWait after
+// its first text arrived. When a batch request fails its texts are embedded
+// one by one, so one bad text does not fail the others.
+type Batcher struct {
+	Client  *Client
+	Size    int
+	MaxWait time.Duration
+
+	once     sync.Once
+	requests chan batchRequest
+}
+
+type batchRequest struct {
+	text  string
+	reply chan batchReply
+}
+
+type batchReply struct {
+	vector []float32
+	err    error
+}
+
+// DefaultBatchWait is how long a partial batch waits for more texts
+const DefaultBatchWait = 10 * time.Millisecond
+
+func NewBatcher(client *Client, size int) *Batcher {
+	return &Batcher{Client: client, Size: size, MaxWait: DefaultBatchWait}
+}
+
+// GetEmbedding implements ai.Embedder, waiting for the batch the text is sent in
+func (b *Batcher) GetEmbedding(text string) ([]float32, error) {
+	if b.Size <= 1 {
+		return b.Client.GetEmbedding(text)
+	}
+	b.once.Do(func() {
+		b.requests = make(chan batchRequest)
+		go b.run()
+	})
+	reply := make(chan batchReply, 1)
+	b.requests <- batchRequest{text: text, reply: reply}
+	result := <-reply
+	return result.vector, result.err
+}
+
+func (b *Batcher) run() {
+	for first := range b.requests {
+		batch := []batchRequest{first}
+		timer := time.NewTimer(b.MaxWait)
+	fill:
+		for len(batch) < b.Size {
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+		go b.send(batch)
+	}
+}
+
+func (b *Batcher) send(batch []batchRequest) {
+	texts := make([]string, len(batch))
+	for i, req := range batch {
+		texts[i] = req.text
+	}
+	vectors, err := b.Client.GetEmbeddings(context.Background(), texts)
+	for i, req := range batch {
+		if err != nil {
+			vector, err := b.Client.GetEmbedding(req.text)
+			req.reply <- batchReply{vector: vector, err: err}
+			continue
+		}
+		req.reply <- batchReply{vector: vectors[i]}
+	}
+}
@@ -1,11 +1,16 @@
 package embeddingClient
 
 import (
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/tracing"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 type EmbedRequest struct {
@@ -18,40 +23,66 @@ type EmbedResponse struct {
 	Dims   int       `json:"dims"`
 }
 
+// RerankRequest asks for cross-encoder relevance scores of passages for a query
+type RerankRequest struct {
+	Query    string   `json:"query"`
+	Passages []string `json:"passages"`
+}
+
+type RerankResponse struct {
+	Scores []float32 `json:"scores"`
+	Model  string    `json:"model"`
+}
+
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
 }
 
+// NewClient returns a client of the service at baseURL, sending the
+// credentials of AuthFromEnv
 func NewClient(baseURL string) *Client {
+	httpClient := &http.Client{}
+	if auth := AuthFromEnv(); auth.Enabled() {
+		httpClient.Transport = NewTransport(auth, nil)
+	}
 	return &Client{
 		BaseURL:    baseURL,
-		HTTPClient: &http.Client{},
+		HTTPClient: httpClient,
 	}
 }
 
 func (c *Client) GetEmbedding(text string) ([]float32, error) {
+	return c.GetEmbeddingContext(context.Background(), text)
+}
+
+// GetEmbeddingContext is GetEmbedding carrying ctx, and the trace in it, to
+// the service
+func (c *Client) GetEmbeddingContext(ctx context.Context, text string) (vector []float32, err error) {
+	ctx, done := instrument(ctx, "embed")
+	defer done(&err)
 	reqBody := EmbedRequest{Text: text}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.BaseURL+"/embed", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/embed", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("%w: HTTP request failed: %w", apperrors.ErrEmbeddingUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("embedding service returned error: %s - %s", resp.Status, string(body))
+		return nil, fmt.Errorf("%w: embedding service returned error: %s - %s", statusError(resp.StatusCode), resp.Status, string(body))
 	}
 	var embedResp EmbedResponse
 	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
@@ -59,3 +90,94 @@ func (c *Client) GetEmbedding(text string) ([]float32, error) {
 	}
 	return embedResp.Vector, nil
 }
+
+// Rerank scores each passage's relevance to query with the service's
+// cross-encoder; higher is more relevant. The scores are in passage order.
+func (c *Client) Rerank(ctx context.Context, query string, passages []string) (scores []float32, err error) {
+	ctx, done := instrument(ctx, "rerank")
+	defer done(&err)
+	jsonData, err := json.Marshal(RerankRequest{Query: query, Passages: passages})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/rerank", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: HTTP request failed: %w", apperrors.ErrEmbeddingUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w: rerank returned error: %s - %s", statusError(resp.StatusCode), resp.Status, string(body))
+	}
+	var rerankResp RerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rerankResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(rerankResp.Scores) != len(passages) {
+		return nil, fmt.Errorf("%w: rerank returned %d scores for %d passages", apperrors.ErrEmbeddingUnavailable, len(rerankResp.Scores), len(passages))
+	}
+	return rerankResp.Scores, nil
+}
+
+// ModelInfoResponse describes the service's embedding model
+type ModelInfoResponse struct {
+	ModelName          string `json:"model_name"`
+	EmbeddingDimension int    `json:"embedding_dimension"`
+	Status             string `json:"status"` // "loaded", or "fallback" when the model failed to load
+}
+
+// ModelInfo returns the name and dimension of the service's embedding model
+func (c *Client) ModelInfo(ctx context.Context) (string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/model-info", nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: HTTP request failed: %w", apperrors.ErrEmbeddingUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", 0, fmt.Errorf("%w: model info returned error: %s - %s", statusError(resp.StatusCode), resp.Status, string(body))
+	}
+	var info ModelInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return info.ModelName, info.EmbeddingDimension, nil
+}
+
+// instrument starts the trace span and timer of an embedding service call.
+// Call the returned func with the call's error when it is done.
+func instrument(ctx context.Context, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "embedding "+operation, tracing.KindClient)
+	return ctx, func(err *error) {
+		span.End(*err)
+		metrics.EmbeddingDuration.Since(start, operation)
+		metrics.EmbeddingRequests.Inc(operation, metrics.Outcome(*err))
+	}
+}
+
+// statusError maps an embedding service HTTP status to a sentinel error
+func statusError(status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return apperrors.ErrRateLimited
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return apperrors.ErrInvalidInput
+	default:
+		return apperrors.ErrEmbeddingUnavailable
+	}
+}
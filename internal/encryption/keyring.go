@@ -0,0 +1,139 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrUnknownKey is returned when data was sealed with a key that is not loaded
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds AES-256-GCM keys by ID. New data is sealed with the active
+// key; older keys are kept so data sealed before a rotation can still be read.
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from 32-byte keys. active names the key used to
+// seal new data and must be one of keys.
+func NewKeyring(keys map[string][]byte, active string) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not among the configured keys", active)
+	}
+	k := &Keyring{active: active, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeys parses "id:base64key,id:base64key". The first key is active
+// unless active is set.
+func ParseKeys(spec, active string) (*Keyring, error) {
+	keys := make(map[string][]byte)
+	first := ""
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("key entry must be id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("key %q is configured twice", id)
+		}
+		keys[id] = key
+		if first == "" {
+			first = id
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys configured")
+	}
+	if active == "" {
+		active = first
+	}
+	return NewKeyring(keys, active)
+}
+
+// FromEnv loads keys from ENCRYPTION_KEYS, or from the file named by
+// ENCRYPTION_KEYS_FILE (e.g. a secret mounted by a KMS or secrets manager).
+// ENCRYPTION_ACTIVE_KEY picks the key for new data. It returns nil when no
+// keys are configured, which leaves storage unencrypted.
+func FromEnv() (*Keyring, error) {
+	spec := os.Getenv("ENCRYPTION_KEYS")
+	if path := os.Getenv("ENCRYPTION_KEYS_FILE"); path != "" {
+		if spec != "" {
+			return nil, fmt.Errorf("set only one of ENCRYPTION_KEYS and ENCRYPTION_KEYS_FILE")
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption keys: %w", err)
+		}
+		spec = string(raw)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	return ParseKeys(spec, os.Getenv("ENCRYPTION_ACTIVE_KEY"))
+}
+
+// ActiveKeyID returns the ID of the key used by Seal
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Seal encrypts plaintext with the active key. additionalData is
+// authenticated but not encrypted and must be passed again to Open.
+func (k *Keyring) Seal(plaintext, additionalData []byte) (keyID string, sealed []byte, err error) {
+	aead := k.aeads[k.active]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.active, aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts data sealed with keyID
+func (k *Keyring) Open(keyID string, sealed, additionalData []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed data is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with key %q: %w", keyID, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,135 @@
+// Package exclusion keeps the articles of the topics a deployment does not
+// cover (the safety.excluded_topics tunable) out of every read from Qdrant,
+// so neither search results nor chat prompts can contain them.
+package exclusion
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/points"
+	"MedAtlasAIServer/internal/safety"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// textFields are the payload fields matched against excluded terms: article
+// titles and abstracts, and the passage text of chunk points
+var textFields = []string{"title", "abstract", "text"}
+
+// Client adds the excluded topics of the current tunables to the filter of
+// every search, scroll and count, and drops excluded points from gets.
+// Without excluded topics requests pass through unchanged.
+type Client struct {
+	Points points.Reader
+	Config *config.Store
+}
+
+func NewClient(points points.Reader, cfg *config.Store) *Client {
+	return &Client{Points: points, Config: cfg}
+}
+
+func (c *Client) topics() []config.ExcludedTopic {
+	if c.Config == nil {
+		return nil
+	}
+	return c.Config.Current().Safety.ExcludedTopics
+}
+
+// Filter returns filter with conditions excluding topics added to MustNot
+func Filter(filter *qdrant.Filter, topics []config.ExcludedTopic) *qdrant.Filter {
+	if len(topics) == 0 {
+		return filter
+	}
+	if filter == nil {
+		filter = &qdrant.Filter{}
+	} else {
+		filter = proto.Clone(filter).(*qdrant.Filter)
+	}
+	for _, topic := range topics {
+		if len(topic.MeshHeadings) > 0 {
+			filter.MustNot = append(filter.MustNot, qdrant.NewMatchKeywords("mesh_headings", topic.MeshHeadings...))
+		}
+		for _, term := range topic.Terms {
+			for _, field := range textFields {
+				filter.MustNot = append(filter.MustNot, qdrant.NewMatchText(field, term))
+			}
+		}
+	}
+	return filter
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	if topics := c.topics(); len(topics) > 0 {
+		in = proto.Clone(in).(*qdrant.SearchPoints)
+		in.Filter = Filter(in.Filter, topics)
+	}
+	return c.Points.Search(ctx, in, opts...)
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	if topics := c.topics(); len(topics) > 0 {
+		in = proto.Clone(in).(*qdrant.ScrollPoints)
+		in.Filter = Filter(in.Filter, topics)
+	}
+	return c.Points.Scroll(ctx, in, opts...)
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	if topics := c.topics(); len(topics) > 0 {
+		in = proto.Clone(in).(*qdrant.CountPoints)
+		in.Filter = Filter(in.Filter, topics)
+	}
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	if topics := c.topics(); len(topics) > 0 {
+		in = proto.Clone(in).(*qdrant.QueryPoints)
+		in.Filter = Filter(in.Filter, topics)
+	}
+	return c.Points.Query(ctx, in, opts...)
+}
+
+// Get drops the points whose returned payload names an excluded topic, so
+// articles fetched by ID are held to the same rule as searched ones
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	resp, err := c.Points.Get(ctx, in, opts...)
+	topics := c.topics()
+	if err != nil || len(topics) == 0 {
+		return resp, err
+	}
+	kept := resp.Result[:0]
+	for _, point := range resp.Result {
+		if !Excluded(point.Payload, topics) {
+			kept = append(kept, point)
+		}
+	}
+	resp.Result = kept
+	return resp, nil
+}
+
+// Excluded reports whether payload belongs to one of topics
+func Excluded(payload map[string]*qdrant.Value, topics []config.ExcludedTopic) bool {
+	var headings []string
+	for _, value := range payload["mesh_headings"].GetListValue().GetValues() {
+		headings = append(headings, strings.ToLower(value.GetStringValue()))
+	}
+	for _, topic := range topics {
+		for _, heading := range topic.MeshHeadings {
+			if slices.Contains(headings, strings.ToLower(heading)) {
+				return true
+			}
+		}
+	}
+	for _, field := range textFields {
+		if _, named := safety.ExcludedTopicIn(payload[field].GetStringValue(), topics); named {
+			return true
+		}
+	}
+	return false
+}
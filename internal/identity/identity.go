@@ -0,0 +1,64 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+)
+
+// UserHeader carries the authenticated user ID. The service sits behind an
+// auth proxy that sets it; clients must not be able to reach the service directly.
+const UserHeader = "X-User-ID"
+
+// maxUserIDLength rejects obviously bogus header values
+const maxUserIDLength = 128
+
+type userKey struct{}
+
+// WithUser attaches a user ID to ctx
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+type verifiedKey struct{}
+
+// WithVerified marks the caller of ctx as having proved who it is, with a
+// credential the service checked itself rather than a forwarded header
+func WithVerified(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedKey{}, true)
+}
+
+// Verified reports whether the caller of ctx was marked by WithVerified
+func Verified(ctx context.Context) bool {
+	verified, _ := ctx.Value(verifiedKey{}).(bool)
+	return verified
+}
+
+// UserFromContext returns the user ID, or "" for anonymous requests
+func UserFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+// Middleware copies the user header into the request context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := strings.TrimSpace(r.Header.Get(UserHeader))
+		if userID != "" && len(userID) <= maxUserIDLength {
+			r = r.WithContext(WithUser(r.Context(), userID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireUser returns the request user or an ErrUnauthorized error
+func RequireUser(ctx context.Context) (string, error) {
+	userID := UserFromContext(ctx)
+	if userID == "" {
+		return "", fmt.Errorf("%w: missing %s header", apperrors.ErrUnauthorized, UserHeader)
+	}
+	return userID, nil
+}
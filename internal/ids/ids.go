@@ -0,0 +1,48 @@
+package ids
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+)
+
+// Generator produces unique identifiers
+type Generator interface {
+	New() string
+}
+
+// UUIDGenerator produces random (version 4) UUIDs with an optional prefix
+type UUIDGenerator struct {
+	Prefix string
+}
+
+func (g UUIDGenerator) New() string {
+	return g.Prefix + NewUUID()
+}
+
+// Sequence produces predictable IDs (prefix_1, prefix_2, ...) for tests
+type Sequence struct {
+	Prefix string
+	n      atomic.Uint64
+}
+
+func (s *Sequence) New() string {
+	return fmt.Sprintf("%s%d", s.Prefix, s.n.Add(1))
+}
+
+// Default generators for chat messages and sessions
+var (
+	Messages Generator = UUIDGenerator{Prefix: "msg_"}
+	Sessions Generator = UUIDGenerator{Prefix: "sess_"}
+)
+
+// NewUUID returns a random RFC 4122 version 4 UUID
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("ids: crypto/rand failed: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
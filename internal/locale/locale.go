@@ -0,0 +1,157 @@
+package locale
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+// Message IDs for user-facing canned text
+const (
+	SafetyResponseHigh   = "SafetyResponseHigh"
+	SafetyResponseMedium = "SafetyResponseMedium"
+	// SafetyResponseExcluded takes the topic's name as {{.Topic}}
+	SafetyResponseExcluded = "SafetyResponseExcluded"
+
+	FallbackSymptom    = "FallbackSymptom"
+	FallbackTreatment  = "FallbackTreatment"
+	FallbackPrevention = "FallbackPrevention"
+	FallbackDefault    = "FallbackDefault"
+
+	ResearchIntro           = "ResearchIntro"
+	ResearchIntroSymptom    = "ResearchIntroSymptom"
+	ResearchIntroTreatment  = "ResearchIntroTreatment"
+	ResearchIntroPrevention = "ResearchIntroPrevention"
+	ResearchIntroCauses     = "ResearchIntroCauses"
+	ResearchIntroDefault    = "ResearchIntroDefault"
+
+	DisclaimerResearch      = "DisclaimerResearch"
+	DisclaimerResearchBrief = "DisclaimerResearchBrief"
+
+	ConsentRequired = "ConsentRequired"
+
+	SummaryUnavailable = "SummaryUnavailable"
+
+	ProgressSearching = "ProgressSearching"
+	ProgressFoundNone = "ProgressFoundNone"
+	ProgressFoundOne  = "ProgressFoundOne"
+	// ProgressFound takes the number of sources as {{.Count}}
+	ProgressFound      = "ProgressFound"
+	ProgressGenerating = "ProgressGenerating"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Catalog holds the translated message bundle
+type Catalog struct {
+	bundle *i18n.Bundle
+}
+
+// NewCatalog loads the embedded message files, with English as the fallback
+func NewCatalog() (*Catalog, error) {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locales: %w", err)
+	}
+	for _, entry := range entries {
+		file := path.Join("locales", entry.Name())
+		raw, err := localeFS.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if _, err := bundle.ParseMessageFileBytes(raw, file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+	}
+	return &Catalog{bundle: bundle}, nil
+}
+
+// Default is the catalog built from the embedded message files
+var Default = mustNewCatalog()
+
+func mustNewCatalog() *Catalog {
+	catalog, err := NewCatalog()
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// Languages lists the languages that have a message file
+func (c *Catalog) Languages() []string {
+	tags := c.bundle.LanguageTags()
+	languages := make([]string, len(tags))
+	for i, tag := range tags {
+		languages[i] = tag.String()
+	}
+	return languages
+}
+
+// Localizer resolves message IDs for an ordered list of language preferences.
+// Entries may be plain tags ("es") or Accept-Language values ("es-MX,es;q=0.9").
+func (c *Catalog) Localizer(langs ...string) *Localizer {
+	return &Localizer{localizer: i18n.NewLocalizer(c.bundle, langs...)}
+}
+
+// Localizer translates messages for one request
+type Localizer struct {
+	localizer *i18n.Localizer
+}
+
+var english = Default.Localizer("en")
+
+// T returns the translation for id, falling back to English and then to the ID itself.
+// A nil Localizer translates to English.
+func (l *Localizer) T(id string) string {
+	if l == nil {
+		l = english
+	}
+	text, err := l.localizer.Localize(&i18n.LocalizeConfig{MessageID: id})
+	if err != nil || text == "" {
+		return id
+	}
+	return text
+}
+
+// TData is T with template data for the message's {{.Field}} placeholders
+func (l *Localizer) TData(id string, data map[string]string) string {
+	if l == nil {
+		l = english
+	}
+	text, err := l.localizer.Localize(&i18n.LocalizeConfig{MessageID: id, TemplateData: data})
+	if err != nil || text == "" {
+		return id
+	}
+	return text
+}
+
+// FromRequest picks the language from an explicit request field first,
+// then from the Accept-Language header
+func FromRequest(r *http.Request, requested string) *Localizer {
+	return Default.Localizer(requested, r.Header.Get("Accept-Language"))
+}
+
+type contextKey struct{}
+
+// WithLocalizer attaches a Localizer to ctx
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Localizer attached to ctx, or English
+func FromContext(ctx context.Context) *Localizer {
+	if l, ok := ctx.Value(contextKey{}).(*Localizer); ok && l != nil {
+		return l
+	}
+	return english
+}
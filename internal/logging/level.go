@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level orders log verbosity
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// ParseLevel converts a config string into a Level, defaulting to info
+func ParseLevel(s string) Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug
+	case "warn":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// SetLevel changes the global log level
+func SetLevel(level Level) {
+	current.Store(int32(level))
+}
+
+// Enabled reports whether messages at level should be logged
+func Enabled(level Level) bool {
+	return level >= Level(current.Load())
+}
+
+// Debugf logs only when the level is debug
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+	}
+}
+
+// Infof logs at info level
+func Infof(format string, args ...interface{}) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
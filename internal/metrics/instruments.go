@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"MedAtlasAIServer/internal/middleware"
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/gorilla/mux"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// The metrics both servers export. Error rates are the requests with an
+// error outcome, or a 4xx/5xx status, over all requests.
+var (
+	HTTPRequests = NewCounter("medatlas_http_requests_total",
+		"HTTP requests by route template, method and status code.", "route", "method", "status")
+	HTTPDuration = NewHistogram("medatlas_http_request_duration_seconds",
+		"HTTP request latency by route template and method.", DefaultBuckets, "route", "method")
+
+	EmbeddingRequests = NewCounter("medatlas_embedding_requests_total",
+		"Embedding service calls by operation and outcome.", "operation", "outcome")
+	EmbeddingDuration = NewHistogram("medatlas_embedding_request_duration_seconds",
+		"Embedding service call latency by operation.", DefaultBuckets, "operation")
+
+	QdrantRequests = NewCounter("medatlas_qdrant_requests_total",
+		"Qdrant point requests by operation, collection and outcome.", "operation", "collection", "outcome")
+	QdrantDuration = NewHistogram("medatlas_qdrant_request_duration_seconds",
+		"Qdrant point request latency by operation and collection.", DefaultBuckets, "operation", "collection")
+
+	LLMRequests = NewCounter("medatlas_llm_requests_total",
+		"Language model completions by model and outcome.", "model", "outcome")
+	LLMDuration = NewHistogram("medatlas_llm_request_duration_seconds",
+		"Language model completion latency by model.", DefaultBuckets, "model")
+	LLMTokens = NewCounter("medatlas_llm_tokens_total",
+		"Language model tokens used, by model and type (prompt or completion).", "model", "type")
+
+	SensitivePassages = NewCounter("medatlas_sensitive_passages_total",
+		"Retrieved articles or passages with sensitive content, by category and action (mask or filter).", "category", "action")
+)
+
+// Outcome is the outcome label for a call that returned err
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Middleware records HTTPRequests and HTTPDuration. Use it on the mux
+// router (router.Use) so the matched route template, not the raw path,
+// labels the series; unmatched requests are not counted.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		start := time.Now()
+		rec := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		HTTPDuration.Since(start, route, r.Method)
+		HTTPRequests.Inc(route, r.Method, strconv.Itoa(rec.Status))
+	})
+}
+
+// Client records QdrantRequests and QdrantDuration for the requests it
+// passes to Points. Wrap the gRPC client directly so each tier and
+// collection is timed separately.
+type Client struct {
+	Points points.Reader
+}
+
+func NewClient(points points.Reader) *Client {
+	return &Client{Points: points}
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	start := time.Now()
+	resp, err := c.Points.Search(ctx, in, opts...)
+	observeQdrant("search", in.GetCollectionName(), start, err)
+	return resp, err
+}
+
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	start := time.Now()
+	resp, err := c.Points.Get(ctx, in, opts...)
+	observeQdrant("get", in.GetCollectionName(), start, err)
+	return resp, err
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	start := time.Now()
+	resp, err := c.Points.Scroll(ctx, in, opts...)
+	observeQdrant("scroll", in.GetCollectionName(), start, err)
+	return resp, err
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	start := time.Now()
+	resp, err := c.Points.Count(ctx, in, opts...)
+	observeQdrant("count", in.GetCollectionName(), start, err)
+	return resp, err
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	start := time.Now()
+	resp, err := c.Points.Query(ctx, in, opts...)
+	observeQdrant("query", in.GetCollectionName(), start, err)
+	return resp, err
+}
+
+func observeQdrant(operation, collection string, start time.Time, err error) {
+	QdrantDuration.Since(start, operation, collection)
+	QdrantRequests.Inc(operation, collection, Outcome(err))
+}
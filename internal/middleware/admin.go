@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"MedAtlasAIServer/internal/apperrors"
+)
+
+// AdminAccess guards endpoints that can read audit data or mutate the corpus.
+// Requests must come from an allowed network and, when RequireClientCert is
+// set, present a client certificate verified by the server's TLS config.
+type AdminAccess struct {
+	AllowedNets       []*net.IPNet // empty allows no address
+	RequireClientCert bool
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs; bare IPs match one address
+func ParseCIDRs(spec string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// loopbackCIDRs are allowed when ADMIN_ALLOWED_CIDRS is unset
+const loopbackCIDRs = "127.0.0.0/8,::1"
+
+// AdminAccessFromEnv reads ADMIN_ALLOWED_CIDRS and ADMIN_REQUIRE_CLIENT_CERT.
+// An unset allowlist admits loopback only; one that is set but lists no
+// networks is rejected rather than read as unset.
+func AdminAccessFromEnv() (*AdminAccess, error) {
+	spec := os.Getenv("ADMIN_ALLOWED_CIDRS")
+	nets, err := ParseCIDRs(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ADMIN_ALLOWED_CIDRS: %w", err)
+	}
+	if len(nets) == 0 && strings.TrimSpace(spec) != "" {
+		return nil, fmt.Errorf("ADMIN_ALLOWED_CIDRS: no networks in %q", spec)
+	}
+	if len(nets) == 0 {
+		nets, _ = ParseCIDRs(loopbackCIDRs)
+		log.Printf("🔒 Admin endpoints are limited to loopback; set ADMIN_ALLOWED_CIDRS to allow other networks")
+	}
+	return &AdminAccess{
+		AllowedNets:       nets,
+		RequireClientCert: os.Getenv("ADMIN_REQUIRE_CLIENT_CERT") == "true",
+	}, nil
+}
+
+// Allowed reports whether ip may reach admin endpoints
+func (a *AdminAccess) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range a.AllowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests from outside the allowlist or without a
+// verified client certificate. The peer address is used as-is; forwarding
+// headers are ignored so they cannot be spoofed to bypass the allowlist.
+func (a *AdminAccess) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := a.denial(r); reason != "" {
+			apperrors.Write(w, apperrors.ErrForbidden, reason)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Permits reports whether r passes the admin restrictions. Handlers use it
+// for admin-only options on otherwise public endpoints.
+func (a *AdminAccess) Permits(r *http.Request) bool {
+	return a.denial(r) == ""
+}
+
+// denial returns why r fails the admin restrictions, or "" when it passes
+func (a *AdminAccess) denial(r *http.Request) string {
+	if !a.Allowed(net.ParseIP(ClientIP(r))) {
+		log.Printf("🚫 Admin request from %s to %s rejected by allowlist", ClientIP(r), r.URL.Path)
+		return "Forbidden"
+	}
+	if a.RequireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		log.Printf("🚫 Admin request from %s to %s rejected: no verified client certificate", ClientIP(r), r.URL.Path)
+		return "Client certificate required"
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/logging"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// DefaultMaxBodyBytes bounds request bodies when no explicit limit is configured
+const DefaultMaxBodyBytes = 1 << 20 // 1 MiB
+
+// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain applies middlewares so that the first one listed is the outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Recover turns handler panics into 500 responses and logs the stack trace
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.Printf("🔥 panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+			apperrors.Write(w, errors.New("handler panic"), "Internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLog logs method, path, status, response size and latency for every request
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		logging.Infof("%s %s %d %dB %v %s", r.Method, r.URL.Path, rec.Status, rec.Bytes, time.Since(start), r.RemoteAddr)
+	})
+}
+
+// LimitBody rejects request bodies larger than maxBytes
+func LimitBody(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				apperrors.Write(w, apperrors.ErrInvalidInput, "Request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StatusRecorder captures the status code and bytes written by a handler
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int
+	wroteHeader bool
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (sr *StatusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.Status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *StatusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	n, err := sr.ResponseWriter.Write(b)
+	sr.Bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (sr *StatusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (sr *StatusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
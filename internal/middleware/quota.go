@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/identity"
+)
+
+// QuotaCookie identifies an anonymous browser, so people sharing an IP
+// address behind a NAT each get their own daily quota
+const QuotaCookie = "medatlas_quota"
+
+// quotaCookieMaxAge keeps the cookie across days; quotas reset daily
+const quotaCookieMaxAge = 365 * 24 * time.Hour
+
+// defaultIPMultiple sets the per-IP cap, when not configured, to this many
+// client quotas: room for a shared office or campus address, while clients
+// that drop their cookie to get a fresh quota still hit it
+const defaultIPMultiple = 10
+
+// AnonymousQuota limits the requests unauthenticated clients make per UTC
+// day. A client is its signed quota cookie, issued on its first request,
+// or its IP address until it sends one back. Every IP address is also
+// capped across all its clients. Requests with a verified identity, such
+// as a known API key, are not counted; neither are those with a user
+// header when TrustUserHeader is set.
+type AnonymousQuota struct {
+	Clock clock.Clock
+	// TrustUserHeader exempts requests carrying identity.UserHeader. Only
+	// set it behind a proxy that authenticates users and sets the header,
+	// as clients otherwise send any user ID to skip the quota.
+	TrustUserHeader bool
+
+	mu        sync.Mutex
+	secret    []byte
+	perClient int
+	perIP     int
+	day       string
+	clients   map[string]int // cookie ID or IP address -> requests today
+	ips       map[string]int
+}
+
+// NewAnonymousQuota creates a disabled quota signing its cookies with secret
+func NewAnonymousQuota(secret []byte) *AnonymousQuota {
+	return &AnonymousQuota{
+		Clock:   clock.System,
+		secret:  secret,
+		clients: make(map[string]int),
+		ips:     make(map[string]int),
+	}
+}
+
+// AnonymousQuotaFromEnv signs cookies with QUOTA_COOKIE_SECRET. Without it
+// a random secret is used, and cookies issued before a restart are
+// ignored, so their clients are counted by IP until they get a new one.
+// QUOTA_TRUST_USER_HEADER=true sets TrustUserHeader.
+func AnonymousQuotaFromEnv() *AnonymousQuota {
+	secret := []byte(os.Getenv("QUOTA_COOKIE_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Could not generate quota cookie secret: %v", err)
+		}
+	}
+	quota := NewAnonymousQuota(secret)
+	quota.TrustUserHeader = os.Getenv("QUOTA_TRUST_USER_HEADER") == "true"
+	return quota
+}
+
+// Update sets the daily requests per client and per IP address. perClient
+// of zero disables the quota; perIP of zero uses ten client quotas.
+func (q *AnonymousQuota) Update(perClient, perIP int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if perIP <= 0 {
+		perIP = perClient * defaultIPMultiple
+	}
+	q.perClient, q.perIP = perClient, perIP
+}
+
+// QuotaStatus is the /quota response: how many requests the caller has
+// left today
+type QuotaStatus struct {
+	Limited   bool       `json:"limited"` // false for exempt callers and when quotas are off
+	Limit     int        `json:"limit,omitempty"`
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining"`
+	ResetsAt  *time.Time `json:"resets_at,omitempty"`
+}
+
+// Middleware counts anonymous requests and rejects those over quota with
+// 429 until the next UTC day
+func (q *AnonymousQuota) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q.exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := q.client(w, r)
+		status, ok := q.take(client, ClientIP(r))
+		if status.Limited {
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(status.Limit))
+			w.Header().Set("X-Quota-Remaining", strconv.Itoa(status.Remaining))
+		}
+		if !ok {
+			err := apperrors.WithRetryAfter(apperrors.ErrRateLimited, status.ResetsAt.Sub(q.Clock.Now()))
+			apperrors.Write(w, err, "Daily free query limit reached; sign in or try again tomorrow")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StatusHandler reports the caller's quota without using any of it
+func (q *AnonymousQuota) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := QuotaStatus{}
+	if !q.exempt(r) {
+		status = q.status(q.client(w, r), ClientIP(r))
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+// exempt reports whether r is authenticated well enough to skip the quota
+func (q *AnonymousQuota) exempt(r *http.Request) bool {
+	ctx := r.Context()
+	return identity.Verified(ctx) || (q.TrustUserHeader && identity.UserFromContext(ctx) != "")
+}
+
+// take counts one request of client from ip, unless it is over quota
+func (q *AnonymousQuota) take(client, ip string) (QuotaStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status := q.statusLocked(client, ip)
+	if !status.Limited {
+		return status, true
+	}
+	if status.Remaining <= 0 {
+		return status, false
+	}
+	q.clients[client]++
+	q.ips[ip]++
+	status.Used++
+	status.Remaining--
+	return status, true
+}
+
+func (q *AnonymousQuota) status(client, ip string) QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.statusLocked(client, ip)
+}
+
+func (q *AnonymousQuota) statusLocked(client, ip string) QuotaStatus {
+	if q.perClient <= 0 {
+		return QuotaStatus{}
+	}
+	now := q.Clock.Now().UTC()
+	if day := now.Format("2006-01-02"); day != q.day {
+		q.day = day
+		q.clients = make(map[string]int)
+		q.ips = make(map[string]int)
+	}
+	resets := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	used := q.clients[client]
+	return QuotaStatus{
+		Limited:   true,
+		Limit:     q.perClient,
+		Used:      used,
+		Remaining: max(0, min(q.perClient-used, q.perIP-q.ips[ip])),
+		ResetsAt:  &resets,
+	}
+}
+
+// client returns the caller's quota key: the ID in a valid quota cookie,
+// or its IP address. Callers without a valid cookie are sent a new one.
+func (q *AnonymousQuota) client(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(QuotaCookie); err == nil {
+		if id, ok := q.verify(cookie.Value); ok {
+			return "cookie:" + id
+		}
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err == nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:     QuotaCookie,
+			Value:    q.sign(hex.EncodeToString(id)),
+			Path:     "/",
+			MaxAge:   int(quotaCookieMaxAge.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return "ip:" + ClientIP(r)
+}
+
+func (q *AnonymousQuota) sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(q.mac(id))
+}
+
+func (q *AnonymousQuota) verify(value string) (string, bool) {
+	id, signature, found := strings.Cut(value, ".")
+	if !found || id == "" {
+		return "", false
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, q.mac(id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func (q *AnonymousQuota) mac(id string) []byte {
+	mac := hmac.New(sha256.New, q.secret)
+	fmt.Fprint(mac, id)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"MedAtlasAIServer/internal/apperrors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients bounds the bucket map before idle clients are pruned
+const maxTrackedClients = 10000
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is a per-client token bucket whose limits can be changed at runtime
+type RateLimiter struct {
+	mu      sync.Mutex
+	perSec  float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter creates a limiter; requestsPerMinute == 0 disables limiting
+func NewRateLimiter(requestsPerMinute, burst int) *RateLimiter {
+	rl := &RateLimiter{buckets: make(map[string]*bucket)}
+	rl.Update(requestsPerMinute, burst)
+	return rl
+}
+
+// Update swaps in new limits without dropping existing buckets
+func (rl *RateLimiter) Update(requestsPerMinute, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.perSec = float64(requestsPerMinute) / 60
+	if burst <= 0 {
+		burst = requestsPerMinute
+	}
+	rl.burst = float64(burst)
+}
+
+// Allow consumes a token for key and reports whether the request may proceed
+func (rl *RateLimiter) Allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.perSec <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	b, ok := rl.buckets[key]
+	if !ok {
+		if len(rl.buckets) >= maxTrackedClients {
+			rl.prune(now)
+		}
+		b = &bucket{tokens: rl.burst, lastSeen: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.lastSeen).Seconds() * rl.perSec
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops buckets that have refilled completely
+func (rl *RateLimiter) prune(now time.Time) {
+	for key, b := range rl.buckets {
+		if b.tokens+now.Sub(b.lastSeen).Seconds()*rl.perSec >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests from clients that exceeded their budget
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.Allow(ClientIP(r)) {
+			w.Header().Set("Retry-After", strconv.Itoa(1))
+			apperrors.Write(w, apperrors.ErrRateLimited, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP returns the caller's IP without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,15 @@
+package models
+
+// HealthTopic is a plain-language consumer health page, such as a MedlinePlus
+// health topic. ID is the page URL, which is stable across releases. The
+// fields tagged payload are stored with its point.
+type HealthTopic struct {
+	ID        string   `json:"id" payload:"id"`
+	Title     string   `json:"title" payload:"title"`
+	URL       string   `json:"url" payload:"url"`
+	Summary   string   `json:"summary" payload:"summary"`
+	AltTitles []string `json:"alt_titles,omitempty"` // other names people search for
+	MeshTerms []string `json:"mesh_terms,omitempty"`
+	Groups    []string `json:"groups,omitempty"` // MedlinePlus topic groups, e.g. "Diabetes Mellitus"
+	Source    string   `json:"source" payload:"source"`
+}
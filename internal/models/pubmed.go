@@ -29,12 +29,7 @@ type PubMedArticle struct {
 				} `xml:"AbstractText"`
 			} `xml:"Abstract"`
 			AuthorList struct {
-				Authors []struct {
-					LastName    string `xml:"LastName"`
-					ForeName    string `xml:"ForeName"`
-					Initials    string `xml:"Initials"`
-					Affiliation string `xml:"Affiliation"`
-				} `xml:"Author"`
+				Authors []PubMedAuthor `xml:"Author"`
 			} `xml:"AuthorList"`
 			PublicationTypeList struct {
 				PublicationTypes []string `xml:"PublicationType"`
@@ -64,6 +59,24 @@ type PubMedArticle struct {
 	} `xml:"PubmedData"`
 }
 
+// PubMedAuthor is one author in an EFetch record. Older records carry the
+// affiliation directly; current ones nest it under AffiliationInfo.
+type PubMedAuthor struct {
+	LastName        string   `xml:"LastName"`
+	ForeName        string   `xml:"ForeName"`
+	Initials        string   `xml:"Initials"`
+	Affiliation     string   `xml:"Affiliation"`
+	AffiliationInfo []string `xml:"AffiliationInfo>Affiliation"`
+}
+
+// Affiliations returns every affiliation listed for the author
+func (a PubMedAuthor) Affiliations() []string {
+	if a.Affiliation == "" {
+		return a.AffiliationInfo
+	}
+	return append([]string{a.Affiliation}, a.AffiliationInfo...)
+}
+
 // Normalized Article Structure
 type MedicalArticle struct {
 	ID               string    `json:"id"`
@@ -78,8 +91,37 @@ type MedicalArticle struct {
 	MeshHeadings     []string  `json:"mesh_headings"`
 	PublicationTypes []string  `json:"publication_types"`
 	Affiliation      string    `json:"affiliation"`
+	Affiliations     []string  `json:"affiliations,omitempty"`
+	Countries        []string  `json:"countries,omitempty"` // where the study took place or its authors work
+	Regions          []string  `json:"regions,omitempty"`
+	NCTIDs           []string  `json:"nct_ids,omitempty"` // ClinicalTrials.gov registrations cited by the article
+	Genes            []string  `json:"genes,omitempty"`
+	Variants         []string  `json:"variants,omitempty"`     // normalized by data.NormalizeVariant
+	Drugs            []string  `json:"drugs,omitempty"`        // RxNorm ingredients, however the text names them
+	DrugBrands       []string  `json:"drug_brands,omitempty"`  // brand names as mentioned
 	KeyConcepts      []string  `json:"key_concepts,omitempty"` // Now used!
 	HasMedicalTerms  bool      `json:"has_medical_terms"`
+
+	// Registry fields, set on ClinicalTrials.gov studies
+	Conditions  []string `json:"conditions,omitempty"`
+	Phase       string   `json:"phase,omitempty"`        // e.g. "Phase 2/Phase 3"
+	TrialStatus string   `json:"trial_status,omitempty"` // e.g. "RECRUITING"
+	Enrollment  int      `json:"enrollment,omitempty"`   // actual or anticipated participants
+
+	// Sections holds the body of open-access full-text articles
+	Sections []ArticleSection `json:"sections,omitempty"`
+
+	// Access is who may read the article, one of the access labels; empty
+	// is public
+	Access string `json:"access,omitempty"`
+}
+
+// ArticleSection is one top-level section of a full-text article. Kind is
+// one of the data.Section* kinds; Heading is the title as printed.
+type ArticleSection struct {
+	Kind    string `json:"kind"`
+	Heading string `json:"heading,omitempty"`
+	Text    string `json:"text"`
 }
 
 type Author struct {
@@ -0,0 +1,201 @@
+// Package multivector is an experimental late-interaction retrieval mode.
+// Each document is stored as one vector per sentence in a multivector
+// collection, and a query, embedded clause by clause, is scored with MaxSim:
+// every query vector is matched to its most similar sentence and the
+// similarities are summed. Nuanced clinical questions ("in elderly patients
+// with renal impairment, ...") then have to be answered by the sentences of
+// an abstract rather than by its averaged meaning.
+//
+// Only the subset of the corpus built with cmd/multivector is searchable
+// this way.
+package multivector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Collection holds the sentence multivectors. Points share the ID and
+// payload of their article in the single-vector collections.
+const Collection = "multivector_abstracts"
+
+// MaxDocumentVectors bounds the sentences stored per document; long
+// abstracts keep their title and first sentences
+const MaxDocumentVectors = 32
+
+// maxQueryVectors bounds the clauses a query is split into
+const maxQueryVectors = 8
+
+// minSentenceWords drops fragments such as section labels ("METHODS:")
+// that would match every structured abstract
+const minSentenceWords = 3
+
+// Points is the part of qdrant.PointsClient the MaxSim search needs
+type Points interface {
+	Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error)
+}
+
+// abbreviations end in a period without ending the sentence
+var abbreviations = map[string]bool{
+	"al.": true, "e.g.": true, "i.e.": true, "vs.": true, "approx.": true,
+	"fig.": true, "no.": true, "dr.": true, "ca.": true, "resp.": true,
+}
+
+// Sentences splits text into sentences, skipping fragments shorter than
+// minSentenceWords. Decimal numbers and common abbreviations do not end a
+// sentence.
+func Sentences(text string) []string {
+	var sentences []string
+	var current []string
+	flush := func() {
+		if len(current) >= minSentenceWords {
+			sentences = append(sentences, strings.Join(current, " "))
+		}
+		current = current[:0]
+	}
+	words := strings.Fields(text)
+	for i, word := range words {
+		current = append(current, word)
+		if !endsSentence(word) {
+			continue
+		}
+		if i+1 < len(words) && !startsSentence(words[i+1]) {
+			continue
+		}
+		flush()
+	}
+	flush()
+	return sentences
+}
+
+func endsSentence(word string) bool {
+	if abbreviations[strings.ToLower(word)] {
+		return false
+	}
+	trimmed := strings.TrimRight(word, `)"'`)
+	return strings.HasSuffix(trimmed, ".") || strings.HasSuffix(trimmed, "?") || strings.HasSuffix(trimmed, "!")
+}
+
+func startsSentence(word string) bool {
+	for _, r := range word {
+		return unicode.IsUpper(r) || unicode.IsDigit(r) || r == '(' || r == '"'
+	}
+	return false
+}
+
+// Clauses splits a query into its sentences and comma- or
+// semicolon-separated clauses, keeping the whole query first so short
+// queries still match as a unit
+func Clauses(query string) []string {
+	query = strings.TrimSpace(query)
+	clauses := []string{query}
+	for _, sentence := range splitAny(query, ".?!;,") {
+		if sentence != query && len(strings.Fields(sentence)) >= 2 {
+			clauses = append(clauses, sentence)
+		}
+		if len(clauses) == maxQueryVectors {
+			break
+		}
+	}
+	return clauses
+}
+
+func splitAny(text, separators string) []string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// DocumentVectors embeds an article's title and the sentences of its
+// abstract, at most MaxDocumentVectors of them
+func DocumentVectors(embedder ai.Embedder, title, abstract string) ([][]float32, error) {
+	texts := []string{}
+	if title = strings.TrimSpace(title); title != "" {
+		texts = append(texts, title)
+	}
+	texts = append(texts, Sentences(abstract)...)
+	if len(texts) > MaxDocumentVectors {
+		texts = texts[:MaxDocumentVectors]
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: document has no text to embed", apperrors.ErrInvalidInput)
+	}
+	return embedAll(embedder, texts)
+}
+
+func embedAll(embedder ai.Embedder, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := embedder.GetEmbedding(text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// Search embeds each clause of query and returns limit of the articles in
+// Collection with the highest MaxSim score, skipping the first offset.
+// Scores are sums over the query clauses, so they are not comparable with
+// single-vector similarities.
+func Search(ctx context.Context, points Points, embedder ai.Embedder, query string, limit, offset int, filter *qdrant.Filter, withPayload *qdrant.WithPayloadSelector) (*qdrant.SearchResponse, error) {
+	queryVectors, err := embedAll(embedder, Clauses(query))
+	if err != nil {
+		return nil, err
+	}
+	pageSize := uint64(limit)
+	request := &qdrant.QueryPoints{
+		CollectionName: Collection,
+		Query:          qdrant.NewQueryMulti(queryVectors),
+		Limit:          &pageSize,
+		Filter:         filter,
+		WithPayload:    withPayload,
+	}
+	if offset > 0 {
+		skip := uint64(offset)
+		request.Offset = &skip
+	}
+	resp, err := points.Query(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+	return &qdrant.SearchResponse{Result: resp.GetResult(), Time: resp.GetTime()}, nil
+}
+
+// EnsureCollection creates Collection for vectors of vectorSize with MaxSim
+// comparison unless it exists
+func EnsureCollection(ctx context.Context, collections qdrant.CollectionsClient, vectorSize int) error {
+	exists, err := collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: Collection})
+	if err != nil {
+		return err
+	}
+	if exists.GetResult().GetExists() {
+		return nil
+	}
+	_, err = collections.Create(ctx, &qdrant.CreateCollection{
+		CollectionName: Collection,
+		VectorsConfig: &qdrant.VectorsConfig{Config: &qdrant.VectorsConfig_Params{
+			Params: &qdrant.VectorParams{
+				Size:     uint64(vectorSize),
+				Distance: qdrant.Distance_Cosine,
+				MultivectorConfig: &qdrant.MultiVectorConfig{
+					Comparator: qdrant.MultiVectorComparator_MaxSim,
+				},
+			},
+		}},
+	})
+	return err
+}
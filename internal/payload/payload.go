@@ -0,0 +1,217 @@
+// Package payload converts between Go structs and Qdrant point payloads.
+// Struct fields name their payload key in a `payload` tag, as encoding/json
+// does with `json`:
+//
+//	type Study struct {
+//		Title      string    `payload:"title"`
+//		Started    time.Time `payload:"started,date"`
+//		Conditions []string  `payload:"conditions,omitempty"`
+//	}
+//
+// Options after the key: omitempty leaves zero values out of the payload,
+// and date stores a time.Time as 2006-01-02 rather than RFC 3339. Untagged
+// fields are not stored. Supported field types are strings, booleans,
+// numbers, time.Time, structs, and slices and pointers of these.
+package payload
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// DateLayout is how fields tagged date are stored
+const DateLayout = "2006-01-02"
+
+// String returns the string stored under key, or "" when payload has none
+func String(payload map[string]*qdrant.Value, key string) string {
+	return payload[key].GetStringValue()
+}
+
+// Strings returns the non-empty strings of the list stored under key
+func Strings(payload map[string]*qdrant.Value, key string) []string {
+	var items []string
+	for _, value := range payload[key].GetListValue().GetValues() {
+		if s := value.GetStringValue(); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// Int returns the integer stored under key, or 0 when payload has none
+func Int(payload map[string]*qdrant.Value, key string) int64 {
+	return payload[key].GetIntegerValue()
+}
+
+// Encode builds the payload of v, a struct or a pointer to one. It panics
+// on fields of unsupported types, which are programming errors.
+func Encode(v any) map[string]*qdrant.Value {
+	return encodeStruct(reflect.Indirect(reflect.ValueOf(v))).Fields
+}
+
+// Decode sets the tagged fields of the struct v points to from payload.
+// Fields the payload lacks, or holds as another kind of value, are left
+// unchanged.
+func Decode(payload map[string]*qdrant.Value, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("payload: Decode needs a pointer to a struct, got %T", v)
+	}
+	decodeStruct(payload, target.Elem())
+	return nil
+}
+
+// field is a tagged struct field
+type field struct {
+	index     int
+	key       string
+	omitEmpty bool
+	date      bool
+}
+
+func fields(t reflect.Type) []field {
+	var tagged []field
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("payload")
+		if !ok || tag == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		key, options, _ := strings.Cut(tag, ",")
+		f := field{index: i, key: key}
+		for _, option := range strings.Split(options, ",") {
+			f.omitEmpty = f.omitEmpty || option == "omitempty"
+			f.date = f.date || option == "date"
+		}
+		tagged = append(tagged, f)
+	}
+	return tagged
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func encodeStruct(v reflect.Value) *qdrant.Struct {
+	encoded := &qdrant.Struct{Fields: make(map[string]*qdrant.Value)}
+	for _, f := range fields(v.Type()) {
+		value := v.Field(f.index)
+		if f.omitEmpty && (value.IsZero() || (value.Kind() == reflect.Slice && value.Len() == 0)) {
+			continue
+		}
+		if encodedValue := encode(value, f.date); encodedValue != nil {
+			encoded.Fields[f.key] = encodedValue
+		}
+	}
+	return encoded
+}
+
+// encode converts v, returning nil for a nil pointer
+func encode(v reflect.Value, date bool) *qdrant.Value {
+	if v.Type() == timeType {
+		if date {
+			return qdrant.NewValueString(v.Interface().(time.Time).Format(DateLayout))
+		}
+		return qdrant.NewValueString(v.Interface().(time.Time).Format(time.RFC3339))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return qdrant.NewValueString(v.String())
+	case reflect.Bool:
+		return qdrant.NewValueBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return qdrant.NewValueInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return qdrant.NewValueInt(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return qdrant.NewValueDouble(v.Float())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return encode(v.Elem(), date)
+	case reflect.Struct:
+		return &qdrant.Value{Kind: &qdrant.Value_StructValue{StructValue: encodeStruct(v)}}
+	case reflect.Slice:
+		values := make([]*qdrant.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if item := encode(v.Index(i), date); item != nil {
+				values = append(values, item)
+			}
+		}
+		return &qdrant.Value{Kind: &qdrant.Value_ListValue{ListValue: &qdrant.ListValue{Values: values}}}
+	}
+	panic(fmt.Sprintf("payload: cannot encode a field of type %s", v.Type()))
+}
+
+func decodeStruct(payload map[string]*qdrant.Value, v reflect.Value) {
+	for _, f := range fields(v.Type()) {
+		if value, ok := payload[f.key]; ok && value != nil {
+			decode(value, v.Field(f.index), f.date)
+		}
+	}
+}
+
+// decode sets target from value when value holds the kind target needs
+func decode(value *qdrant.Value, target reflect.Value, date bool) {
+	if target.Kind() == reflect.Pointer {
+		decoded := reflect.New(target.Type().Elem())
+		if decode(value, decoded.Elem(), date); !decoded.Elem().IsZero() {
+			target.Set(decoded)
+		}
+		return
+	}
+	if target.Type() == timeType {
+		layout := time.RFC3339
+		if date {
+			layout = DateLayout
+		}
+		if parsed, err := time.Parse(layout, value.GetStringValue()); err == nil {
+			target.Set(reflect.ValueOf(parsed))
+		}
+		return
+	}
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		if target.Kind() == reflect.String {
+			target.SetString(kind.StringValue)
+		}
+	case *qdrant.Value_BoolValue:
+		if target.Kind() == reflect.Bool {
+			target.SetBool(kind.BoolValue)
+		}
+	case *qdrant.Value_IntegerValue:
+		switch target.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			target.SetInt(kind.IntegerValue)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			target.SetUint(uint64(kind.IntegerValue))
+		case reflect.Float32, reflect.Float64:
+			target.SetFloat(float64(kind.IntegerValue))
+		}
+	case *qdrant.Value_DoubleValue:
+		if target.Kind() == reflect.Float32 || target.Kind() == reflect.Float64 {
+			target.SetFloat(kind.DoubleValue)
+		}
+	case *qdrant.Value_StructValue:
+		if target.Kind() == reflect.Struct {
+			decodeStruct(kind.StructValue.GetFields(), target)
+		}
+	case *qdrant.Value_ListValue:
+		if target.Kind() != reflect.Slice {
+			return
+		}
+		items := reflect.MakeSlice(target.Type(), 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			decoded := reflect.New(target.Type().Elem()).Elem()
+			decode(item, decoded, date)
+			if !decoded.IsZero() {
+				items = reflect.Append(items, decoded)
+			}
+		}
+		if items.Len() > 0 {
+			target.Set(items)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Package points defines the reads of qdrant.PointsClient that the clients
+// layered over it implement. Metrics, snapshots, tiering, the content and
+// document stores, exclusions, screening and access labels each wrap a
+// Reader and are one, so the servers stack them around the gRPC client.
+package points
+
+import (
+	"context"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Reader is the part of qdrant.PointsClient the wrapping clients pass on
+type Reader interface {
+	Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error)
+	Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error)
+	Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error)
+	Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error)
+	Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error)
+}
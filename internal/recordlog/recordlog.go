@@ -0,0 +1,306 @@
+package recordlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/encryption"
+)
+
+// Default locations for the logs kept by the servers
+const (
+	DefaultQueryLogPath   = "data/logs/query_log.jsonl"
+	DefaultTranscriptPath = "data/logs/chat_transcripts.jsonl"
+	DefaultShadowLogPath  = "data/logs/shadow_diffs.jsonl"
+)
+
+// Record is one logged entry, e.g. a chat exchange or a search query
+type Record struct {
+	Time   time.Time       `json:"time"`
+	UserID string          `json:"user_id,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// sealedLine is the on-disk form of a record when encryption is enabled
+type sealedLine struct {
+	KeyID  string `json:"key_id"`
+	Sealed []byte `json:"sealed"`
+}
+
+// sealContext binds sealed lines to this format so they cannot be replayed
+// into other encrypted stores
+var sealContext = []byte("recordlog/v1")
+
+// Log is an append-mostly JSONL file of records that supports the deletes
+// needed for retention and user erasure. Records are kept in memory; the
+// retention policy bounds their number. It is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	keys    *encryption.Keyring
+	records []Record
+	Clock   clock.Clock
+}
+
+// Open loads path, creating it if needed. With keys, records are written
+// encrypted; existing plaintext lines and lines sealed with a rotated-out
+// key are re-encrypted with the active key. A nil keyring writes plaintext.
+func Open(path string, keys *encryption.Keyring) (*Log, error) {
+	l := &Log{path: path, keys: keys, Clock: clock.System}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create record log directory: %w", err)
+	}
+
+	stale, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	if stale > 0 && keys != nil {
+		log.Printf("🔐 Re-encrypting %d records in %s with key %q", stale, path, keys.ActiveKeyID())
+		if err := l.rewrite(l.records); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Read returns the records in path without opening it for writing, for
+// tools that analyse a log the servers are still appending to. keys must
+// be supplied when the log is encrypted.
+func Read(path string, keys *encryption.Keyring) ([]Record, error) {
+	l := &Log{path: path, keys: keys}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := l.load(); err != nil {
+		return nil, err
+	}
+	return l.records, nil
+}
+
+// load reads the records in l.path, if it exists, and returns how many of
+// them are stale (see decode)
+func (l *Log) load() (stale int, err error) {
+	existing, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer existing.Close()
+
+	scanner := bufio.NewScanner(existing)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		record, current, err := l.decode(scanner.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s line %d: %w", l.path, line, err)
+		}
+		if !current {
+			stale++
+		}
+		l.records = append(l.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", l.path, err)
+	}
+	return stale, nil
+}
+
+// encode returns the on-disk line for record, sealed when encryption is enabled
+func (l *Log) encode(record Record) ([]byte, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal record: %w", err)
+	}
+	if l.keys == nil {
+		return line, nil
+	}
+	keyID, sealed, err := l.keys.Seal(line, sealContext)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedLine{KeyID: keyID, Sealed: sealed})
+}
+
+// decode parses one on-disk line. current is false when the line should be
+// rewritten: it is plaintext or sealed with a key that is no longer active.
+func (l *Log) decode(line []byte) (record Record, current bool, err error) {
+	var sealed sealedLine
+	if err := json.Unmarshal(line, &sealed); err != nil {
+		return Record{}, false, err
+	}
+	if len(sealed.Sealed) == 0 {
+		err := json.Unmarshal(line, &record)
+		return record, l.keys == nil, err
+	}
+
+	if l.keys == nil {
+		return Record{}, false, fmt.Errorf("record is encrypted but no encryption keys are configured")
+	}
+	plaintext, err := l.keys.Open(sealed.KeyID, sealed.Sealed, sealContext)
+	if err != nil {
+		return Record{}, false, err
+	}
+	err = json.Unmarshal(plaintext, &record)
+	return record, sealed.KeyID == l.keys.ActiveKeyID(), err
+}
+
+// Append stores data for userID ("" for anonymous requests). A nil *Log discards it.
+func (l *Log) Append(userID string, data any) error {
+	if l == nil {
+		return nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := Record{Time: l.Clock.Now().UTC(), UserID: userID, Data: raw}
+	line, err := l.encode(record)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", l.path, err)
+	}
+	l.records = append(l.records, record)
+	return nil
+}
+
+// PurgeBefore deletes records older than cutoff and returns how many were removed
+func (l *Log) PurgeBefore(cutoff time.Time) (int, error) {
+	return l.removeWhere(func(r Record) bool { return r.Time.Before(cutoff) })
+}
+
+// DeleteUser deletes every record belonging to userID
+func (l *Log) DeleteUser(userID string) (int, error) {
+	if userID == "" {
+		return 0, nil
+	}
+	return l.removeWhere(func(r Record) bool { return r.UserID == userID })
+}
+
+// Find returns the newest record for which match is true
+func (l *Log) Find(match func(Record) bool) (Record, bool) {
+	if l == nil {
+		return Record{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.records) - 1; i >= 0; i-- {
+		if match(l.records[i]) {
+			return l.records[i], true
+		}
+	}
+	return Record{}, false
+}
+
+// Len returns the number of stored records
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.records)
+}
+
+// Close closes the underlying file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// removeWhere rewrites the file without the matching records
+func (l *Log) removeWhere(match func(Record) bool) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := make([]Record, 0, len(l.records))
+	for _, record := range l.records {
+		if !match(record) {
+			kept = append(kept, record)
+		}
+	}
+	removed := len(l.records) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := l.rewrite(kept); err != nil {
+		return 0, err
+	}
+	l.records = kept
+	return removed, nil
+}
+
+// rewrite replaces the file with records via a temp file and reopens it for
+// appending. Callers must hold l.mu.
+func (l *Log) rewrite(records []Record) error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to rewrite %s: %w", l.path, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	writer := bufio.NewWriter(tmp)
+	for _, record := range records {
+		line, err := l.encode(record)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite %s: %w", l.path, err)
+	}
+	if err := tmp.Chmod(0640); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite %s: %w", l.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", l.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite %s: %w", l.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", l.path, err)
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, err = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to reopen %s: %w", l.path, err)
+	}
+	return nil
+}
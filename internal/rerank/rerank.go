@@ -0,0 +1,66 @@
+// Package rerank re-scores retrieved points against the query with a
+// cross-encoder. Vector search ranks by embedding similarity, computed for
+// query and article separately; a cross-encoder reads the query and the
+// passage together and orders the top of the list more reliably, at the
+// cost of a model call per search.
+package rerank
+
+import (
+	"context"
+	"sort"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/proto"
+)
+
+// Scorer scores passages for relevance to a query, higher first.
+// *embeddingClient.Client implements it with the embedding service's
+// /rerank endpoint.
+type Scorer interface {
+	Rerank(ctx context.Context, query string, passages []string) ([]float32, error)
+}
+
+// CandidatesPerResult is how many retrieved points per wanted result are
+// scored, so the reranker can promote points vector search ranked lower
+const CandidatesPerResult = 3
+
+// MaxCandidates bounds the points scored per search; cross-encoder latency
+// grows with every passage
+const MaxCandidates = 100
+
+// Candidates returns how many points to retrieve for n reranked results
+func Candidates(n int) int {
+	return max(n, min(n*CandidatesPerResult, MaxCandidates))
+}
+
+// PayloadFields are the payload fields Passage reads
+var PayloadFields = []string{"title", "abstract"}
+
+// Passage is the text of a point the reranker scores: its title and abstract
+func Passage(payload map[string]*qdrant.Value) string {
+	return payload["title"].GetStringValue() + ". " + payload["abstract"].GetStringValue()
+}
+
+// Points scores points against query and returns copies of them ordered by
+// the scorer, best first, each scored by the scorer. Ties keep their
+// retrieval order. The points are left untouched when scoring fails.
+func Points(ctx context.Context, scorer Scorer, query string, points []*qdrant.ScoredPoint) ([]*qdrant.ScoredPoint, error) {
+	if len(points) == 0 {
+		return points, nil
+	}
+	passages := make([]string, len(points))
+	for i, point := range points {
+		passages[i] = Passage(point.Payload)
+	}
+	scores, err := scorer.Rerank(ctx, query, passages)
+	if err != nil {
+		return nil, err
+	}
+	reranked := make([]*qdrant.ScoredPoint, len(points))
+	for i, point := range points {
+		reranked[i] = proto.Clone(point).(*qdrant.ScoredPoint)
+		reranked[i].Score = scores[i]
+	}
+	sort.SliceStable(reranked, func(i, j int) bool { return reranked[i].Score > reranked[j].Score })
+	return reranked, nil
+}
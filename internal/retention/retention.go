@@ -0,0 +1,104 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"MedAtlasAIServer/internal/clock"
+)
+
+// PurgeInterval is how often servers apply their retention policies
+const PurgeInterval = time.Hour
+
+// Purger deletes records older than a cutoff
+type Purger interface {
+	PurgeBefore(cutoff time.Time) (int, error)
+}
+
+// Eraser deletes everything stored about one user
+type Eraser interface {
+	DeleteUser(userID string) (int, error)
+}
+
+// EraserFunc adapts a function into an Eraser
+type EraserFunc func(userID string) (int, error)
+
+func (f EraserFunc) DeleteUser(userID string) (int, error) { return f(userID) }
+
+// Policy purges one kind of record once it is older than MaxAge.
+// MaxAge is read on every run so reloaded config takes effect; zero disables it.
+type Policy struct {
+	Name   string
+	Target Purger
+	MaxAge func() time.Duration
+}
+
+// Days converts a retention period in days to a duration
+func Days(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Scheduler applies retention policies periodically
+type Scheduler struct {
+	Policies []Policy
+	Clock    clock.Clock
+}
+
+func NewScheduler(policies ...Policy) *Scheduler {
+	return &Scheduler{Policies: policies, Clock: clock.System}
+}
+
+// RunOnce applies every policy and returns the number of records purged per policy
+func (s *Scheduler) RunOnce() map[string]int {
+	purged := make(map[string]int, len(s.Policies))
+	now := s.Clock.Now()
+	for _, policy := range s.Policies {
+		maxAge := policy.MaxAge()
+		if maxAge <= 0 {
+			continue
+		}
+		n, err := policy.Target.PurgeBefore(now.Add(-maxAge))
+		if err != nil {
+			log.Printf("⚠️  Retention purge of %s failed: %v", policy.Name, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("🧹 Purged %d %s older than %s", n, policy.Name, maxAge)
+		}
+		purged[policy.Name] = n
+	}
+	return purged
+}
+
+// Run applies the policies immediately and then every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	s.RunOnce()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce()
+		}
+	}
+}
+
+// EraseUser runs every eraser for userID. It keeps going after a failure so
+// as much data as possible is removed, and reports all failures together.
+func EraseUser(userID string, erasers map[string]Eraser) (map[string]int, error) {
+	deleted := make(map[string]int, len(erasers))
+	var errs []error
+	for name, eraser := range erasers {
+		n, err := eraser.DeleteUser(userID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		deleted[name] = n
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"MedAtlasAIServer/internal/config"
+)
+
+// RiskExcluded is the risk level of messages about a topic the deployment
+// does not cover
+const RiskExcluded = "excluded"
+
+// excludedTopic is a config.ExcludedTopic with its terms compiled
+type excludedTopic struct {
+	name    string
+	terms   []*regexp.Regexp
+	longest int // bound in bytes on text matching the longest term
+}
+
+// SetExcludedTopics replaces the topics the checker declines
+func (msc *MedicalSafetyChecker) SetExcludedTopics(topics []config.ExcludedTopic) {
+	compiled := compileTopics(topics)
+	msc.mu.Lock()
+	defer msc.mu.Unlock()
+	msc.excluded = compiled
+}
+
+// CheckGenerated checks text the model generated, or retrieved, for
+// excluded topics; the other rules only apply to what users ask
+func (msc *MedicalSafetyChecker) CheckGenerated(text string) SafetyResult {
+	msc.mu.RLock()
+	defer msc.mu.RUnlock()
+	if name, ok := matchTopic(msc.excluded, text); ok {
+		return excludedResult(name)
+	}
+	return SafetyResult{IsSafe: true, RiskLevel: "low"}
+}
+
+// LongestExcludedTerm bounds the length in bytes of text matching an
+// excluded term, whatever its case, or returns 0 without excluded topics.
+// Text further than that, plus one byte for the word boundary, from the end
+// of a streamed answer has already been checked whole by CheckGenerated.
+func (msc *MedicalSafetyChecker) LongestExcludedTerm() int {
+	msc.mu.RLock()
+	defer msc.mu.RUnlock()
+	longest := 0
+	for _, topic := range msc.excluded {
+		longest = max(longest, topic.longest)
+	}
+	return longest
+}
+
+// ExcludedTopicIn returns the name of the first topic text names, matching
+// terms as whole words regardless of case
+func ExcludedTopicIn(text string, topics []config.ExcludedTopic) (string, bool) {
+	return matchTopic(compileTopics(topics), text)
+}
+
+func excludedResult(name string) SafetyResult {
+	return SafetyResult{IsSafe: false, Reasons: []string{"excluded_topic"}, RiskLevel: RiskExcluded, Topic: name}
+}
+
+func compileTopics(topics []config.ExcludedTopic) []excludedTopic {
+	compiled := make([]excludedTopic, 0, len(topics))
+	for _, topic := range topics {
+		entry := excludedTopic{name: topic.Name}
+		for _, term := range topic.Terms {
+			if term = strings.TrimSpace(term); term != "" {
+				entry.terms = append(entry.terms, regexp.MustCompile(`(?i)(^|[^\pL\pN])`+regexp.QuoteMeta(term)+`($|[^\pL\pN])`))
+				entry.longest = max(entry.longest, utf8.UTFMax*utf8.RuneCountInString(term))
+			}
+		}
+		compiled = append(compiled, entry)
+	}
+	return compiled
+}
+
+func matchTopic(topics []excludedTopic, text string) (string, bool) {
+	for _, topic := range topics {
+		for _, term := range topic.terms {
+			if term.MatchString(text) {
+				return topic.name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -1,20 +1,36 @@
 package safety
 
-import "strings"
+import (
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/locale"
+	"fmt"
+	"strings"
+	"sync"
+)
 
 type SafetyResult struct {
 	IsSafe    bool     `json:"is_safe"`
 	Reasons   []string `json:"reasons,omitempty"`
-	RiskLevel string   `json:"risk_level"` //Low, medium and high
+	RiskLevel string   `json:"risk_level"`      //Low, medium and high, or excluded
+	Topic     string   `json:"topic,omitempty"` // the excluded topic named, for RiskExcluded
 }
 
 type MedicalSafetyChecker struct {
+	mu                 sync.RWMutex
 	BlockedTopics      []string
 	HighRiskKeywords   []string
 	MediumRiskKeywords []string
+	defaults           *MedicalSafetyChecker
+	excluded           []excludedTopic
 }
 
 func NewMedicalSafetyChecker() *MedicalSafetyChecker {
+	checker := newDefaultRules()
+	checker.defaults = newDefaultRules()
+	return checker
+}
+
+func newDefaultRules() *MedicalSafetyChecker {
 	return &MedicalSafetyChecker{
 		BlockedTopics: []string{
 			"emergency", "911", "suicide", "self-harm", "overdose",
@@ -32,9 +48,50 @@ func NewMedicalSafetyChecker() *MedicalSafetyChecker {
 	}
 }
 
+// SetRules replaces the keyword lists at runtime. An empty list restores
+// the built-in rules for that category.
+func (msc *MedicalSafetyChecker) SetRules(blocked, highRisk, mediumRisk []string) {
+	msc.mu.Lock()
+	defer msc.mu.Unlock()
+
+	if msc.defaults == nil {
+		msc.defaults = newDefaultRules()
+	}
+	msc.BlockedTopics = pickRules(blocked, msc.defaults.BlockedTopics)
+	msc.HighRiskKeywords = pickRules(highRisk, msc.defaults.HighRiskKeywords)
+	msc.MediumRiskKeywords = pickRules(mediumRisk, msc.defaults.MediumRiskKeywords)
+}
+
+func pickRules(configured, defaults []string) []string {
+	if len(configured) == 0 {
+		return defaults
+	}
+	rules := make([]string, len(configured))
+	for i, rule := range configured {
+		rules[i] = strings.ToLower(rule)
+	}
+	return rules
+}
+
+// Err returns nil for safe results and an ErrUnsafeContent-wrapped error otherwise
+func (sr SafetyResult) Err() error {
+	if sr.IsSafe {
+		return nil
+	}
+	return fmt.Errorf("%w: %s risk (%s)", apperrors.ErrUnsafeContent, sr.RiskLevel, strings.Join(sr.Reasons, ", "))
+}
+
 func (msc *MedicalSafetyChecker) CheckMessage(message string) SafetyResult {
 	lowerMessage := strings.ToLower(message)
 
+	msc.mu.RLock()
+	defer msc.mu.RUnlock()
+
+	// Topics the deployment does not cover are declined whatever the risk
+	if name, ok := matchTopic(msc.excluded, message); ok {
+		return excludedResult(name)
+	}
+
 	// Check for emergency situations
 
 	for _, topic := range msc.BlockedTopics {
@@ -79,12 +136,16 @@ func (msc *MedicalSafetyChecker) CheckMessage(message string) SafetyResult {
 	}
 }
 
-func (msc *MedicalSafetyChecker) GenerateSafetyResponse(riskLevel string, reasons []string) string {
-	switch riskLevel {
+// GenerateSafetyResponse returns the canned reply for a result in the
+// localizer's language (English when loc is nil)
+func (msc *MedicalSafetyChecker) GenerateSafetyResponse(result SafetyResult, loc *locale.Localizer) string {
+	switch result.RiskLevel {
+	case RiskExcluded:
+		return loc.TData(locale.SafetyResponseExcluded, map[string]string{"Topic": result.Topic})
 	case "high":
-		return "I'm sorry, I cannot provide specific medical advice or emergency guidance. Please contact emergency services (911) or your healthcare provider immediately for urgent medical concerns."
+		return loc.T(locale.SafetyResponseHigh)
 	case "medium":
-		return "I can provide general information about medical topics, but I cannot recommend specific treatments or medications. It's important to consult with a healthcare professional for personalized medical advice."
+		return loc.T(locale.SafetyResponseMedium)
 	default:
 		return ""
 	}
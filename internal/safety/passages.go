@@ -0,0 +1,104 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Categories of sensitive content in retrieved passages
+const (
+	CategoryGraphic       = "graphic"
+	CategorySelfHarm      = "self_harm_method"
+	CategoryDrugSynthesis = "drug_synthesis"
+)
+
+// MaskedSentence replaces the sentences of a passage that were masked
+const MaskedSentence = "[sensitive content removed]"
+
+// passageRules flag sentences, not whole abstracts: research on suicide or
+// drug use is legitimate, instructions and method detail are not
+var passageRules = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{CategoryGraphic, regexp.MustCompile(`(?i)\b(decapitat|dismember|disembowel|eviscerat)\w*|\b(mutilated|charred|mangled) (body|bodies|corpse|corpses|remains)\b|\b(brain matter|spilled entrails)\b`)},
+	{CategorySelfHarm, regexp.MustCompile(`(?i)\b(how to|instructions? (for|to)|step[- ]by[- ]step|method(s)? (for|of) (committing|completing))\b.{0,60}\b(suicide|kill (yourself|oneself|themselves)|self[- ]harm|hang(ing)? (yourself|oneself)|overdos\w*)`)},
+	{CategorySelfHarm, regexp.MustCompile(`(?i)\b(lethal|fatal) (dose|amount|quantity)\b.{0,40}\b\d+(\.\d+)?\s*(mg|g|grams|tablets|pills|capsules)\b`)},
+	{CategoryDrugSynthesis, regexp.MustCompile(`(?i)\b(synthes[ie]s|synthesi[sz]ed?|synthesi[sz]ing|cook(ing|ed)?|manufactur\w*|extract(ing|ion|ed)?)\b.{0,80}\b(methamphetamine|meth|fentanyl|carfentanil|heroin|mdma|lsd|crack cocaine|ghb|nitazene\w*)\b.{0,120}\b(precursor|reagent|pseudoephedrine|ephedrine|red phosphorus|anhydrous ammonia|reductive amination|reflux|yield of|acetic anhydride)`)},
+}
+
+// PassageResult is the sensitive content found in one retrieved text
+type PassageResult struct {
+	Categories []string // distinct categories, in rule order; empty when clean
+	Masked     string   // the text with flagged sentences replaced by MaskedSentence
+}
+
+// Sensitive reports whether anything was flagged
+func (pr PassageResult) Sensitive() bool {
+	return len(pr.Categories) > 0
+}
+
+// ClassifyPassage checks retrieved text, such as an abstract or passage, for
+// graphic descriptions, self-harm methods and illicit drug synthesis. The
+// input checks of MedicalSafetyChecker only see what users ask; this covers
+// what the corpus returns.
+func ClassifyPassage(text string) PassageResult {
+	result := PassageResult{Masked: text}
+	if strings.TrimSpace(text) == "" {
+		return result
+	}
+	seen := make(map[string]bool)
+	sentences := splitSentences(text)
+	masked := false
+	for i, sentence := range sentences {
+		for _, rule := range passageRules {
+			if !rule.pattern.MatchString(sentence) {
+				continue
+			}
+			if !seen[rule.category] {
+				seen[rule.category] = true
+				result.Categories = append(result.Categories, rule.category)
+			}
+			sentences[i] = MaskedSentence
+			masked = true
+			break
+		}
+	}
+	if masked {
+		result.Masked = strings.Join(collapseMasks(sentences), " ")
+	}
+	return result
+}
+
+// splitSentences splits text after ".", "!" or "?" followed by whitespace
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(text)-1; i++ {
+		switch text[i] {
+		case '.', '!', '?':
+			if text[i+1] == ' ' || text[i+1] == '\n' || text[i+1] == '\t' {
+				if sentence := strings.TrimSpace(text[start : i+1]); sentence != "" {
+					sentences = append(sentences, sentence)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if sentence := strings.TrimSpace(text[start:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// collapseMasks keeps one placeholder for a run of masked sentences
+func collapseMasks(sentences []string) []string {
+	collapsed := sentences[:0]
+	for _, sentence := range sentences {
+		if sentence == MaskedSentence && len(collapsed) > 0 && collapsed[len(collapsed)-1] == MaskedSentence {
+			continue
+		}
+		collapsed = append(collapsed, sentence)
+	}
+	return collapsed
+}
@@ -0,0 +1,141 @@
+package savedsearch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where saved searches are kept when SAVED_SEARCHES_FILE is unset
+const DefaultPath = "data/saved_searches.json"
+
+// SavedSearch is a query a user can re-run or export later
+type SavedSearch struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Owner     string    `json:"owner,omitempty"` // empty for searches saved anonymously
+	Query     string    `json:"query"`
+	Limit     int       `json:"limit"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps saved searches in memory and persists them to a JSON file.
+// It is safe for concurrent use.
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	searches map[string]*SavedSearch
+	Clock    clock.Clock
+	IDs      ids.Generator
+}
+
+// NewStore loads path if it exists. An empty path keeps searches in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		searches: make(map[string]*SavedSearch),
+		Clock:    clock.System,
+		IDs:      ids.UUIDGenerator{Prefix: "search_"},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	var searches []*SavedSearch
+	if _, err := jsonfile.Read(path, &searches); err != nil {
+		return nil, fmt.Errorf("failed to load saved searches: %w", err)
+	}
+	for _, search := range searches {
+		s.searches[search.ID] = search
+	}
+	return s, nil
+}
+
+// Save stores a new search for owner and returns it with its assigned ID
+func (s *Store) Save(owner, name, query string, limit int) (*SavedSearch, error) {
+	if query == "" {
+		return nil, fmt.Errorf("%w: query is required", apperrors.ErrInvalidInput)
+	}
+
+	search := &SavedSearch{
+		ID:        s.IDs.New(),
+		Name:      name,
+		Owner:     owner,
+		Query:     query,
+		Limit:     limit,
+		CreatedAt: s.Clock.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches[search.ID] = search
+	if err := s.persist(); err != nil {
+		delete(s.searches, search.ID)
+		return nil, err
+	}
+	return search, nil
+}
+
+// Get returns the saved search with the given ID
+func (s *Store) Get(id string) (*SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search, ok := s.searches[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: saved search %s", apperrors.ErrNotFound, id)
+	}
+	copied := *search
+	return &copied, nil
+}
+
+// DeleteUser removes every search saved by owner and returns their IDs
+func (s *Store) DeleteUser(owner string) ([]string, error) {
+	if owner == "" {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]*SavedSearch)
+	for id, search := range s.searches {
+		if search.Owner == owner {
+			removed[id] = search
+			delete(s.searches, id)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := s.persist(); err != nil {
+		for id, search := range removed {
+			s.searches[id] = search
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(removed))
+	for id := range removed {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// persist writes all searches to disk. Callers must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	searches := make([]*SavedSearch, 0, len(s.searches))
+	for _, search := range s.searches {
+		searches = append(searches, search)
+	}
+	return jsonfile.WriteAtomic(s.path, searches)
+}
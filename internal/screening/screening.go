@@ -0,0 +1,143 @@
+// Package screening checks the texts Qdrant returns for sensitive content
+// (safety.ClassifyPassage) before they reach a prompt or a response, and
+// masks the offending sentences or drops the article as the
+// safety.sensitive_passages tunable says.
+package screening
+
+import (
+	"context"
+	"log"
+	"maps"
+
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/metrics"
+	"MedAtlasAIServer/internal/points"
+	"MedAtlasAIServer/internal/safety"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// textFields are the payload fields screened: article titles and abstracts,
+// and the passage text of chunk points
+var textFields = []string{"title", "abstract", "text"}
+
+// Client screens the payloads of searched, fetched and scrolled points.
+// Counts pass through, so with filtering they may include dropped points.
+type Client struct {
+	Points points.Reader
+	Config *config.Store
+}
+
+func NewClient(points points.Reader, cfg *config.Store) *Client {
+	return &Client{Points: points, Config: cfg}
+}
+
+func (c *Client) action() string {
+	if c.Config == nil {
+		return config.PassagesMask
+	}
+	return c.Config.Current().Safety.SensitivePassages
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	resp, err := c.Points.Search(ctx, in, opts...)
+	action := c.action()
+	if err != nil || action == config.PassagesAllow {
+		return resp, err
+	}
+	kept := resp.Result[:0]
+	for _, point := range resp.Result {
+		if payload, keep := Screen(point.Payload, action); keep {
+			point.Payload = payload
+			kept = append(kept, point)
+		}
+	}
+	resp.Result = kept
+	return resp, nil
+}
+
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	resp, err := c.Points.Get(ctx, in, opts...)
+	action := c.action()
+	if err != nil || action == config.PassagesAllow {
+		return resp, err
+	}
+	resp.Result = screenRetrieved(resp.Result, action)
+	return resp, nil
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	resp, err := c.Points.Scroll(ctx, in, opts...)
+	action := c.action()
+	if err != nil || action == config.PassagesAllow {
+		return resp, err
+	}
+	resp.Result = screenRetrieved(resp.Result, action)
+	return resp, nil
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	resp, err := c.Points.Query(ctx, in, opts...)
+	action := c.action()
+	if err != nil || action == config.PassagesAllow {
+		return resp, err
+	}
+	kept := resp.Result[:0]
+	for _, point := range resp.Result {
+		if payload, keep := Screen(point.Payload, action); keep {
+			point.Payload = payload
+			kept = append(kept, point)
+		}
+	}
+	resp.Result = kept
+	return resp, nil
+}
+
+func screenRetrieved(points []*qdrant.RetrievedPoint, action string) []*qdrant.RetrievedPoint {
+	kept := points[:0]
+	for _, point := range points {
+		if payload, keep := Screen(point.Payload, action); keep {
+			point.Payload = payload
+			kept = append(kept, point)
+		}
+	}
+	return kept
+}
+
+// Screen classifies the text fields of payload. It returns the payload to
+// use, a masked copy when sentences were masked, and whether the point is
+// kept; with config.PassagesFilter sensitive points are not.
+func Screen(payload map[string]*qdrant.Value, action string) (map[string]*qdrant.Value, bool) {
+	screened, copied := payload, false
+	for _, field := range textFields {
+		text := payload[field].GetStringValue()
+		if text == "" {
+			continue
+		}
+		result := safety.ClassifyPassage(text)
+		if !result.Sensitive() {
+			continue
+		}
+		for _, category := range result.Categories {
+			metrics.SensitivePassages.Inc(category, action)
+		}
+		id := payload["id"].GetStringValue()
+		if action == config.PassagesFilter {
+			log.Printf("🛡️  Dropped retrieved article %s: %v in %s", id, result.Categories, field)
+			return nil, false
+		}
+		log.Printf("🛡️  Masked %v in %s of retrieved article %s", result.Categories, field, id)
+		if !copied {
+			// Payloads may be shared with a cache, so masking works on a copy
+			screened = maps.Clone(payload)
+			copied = true
+		}
+		screened[field] = qdrant.NewValueString(result.Masked)
+	}
+	return screened, true
+}
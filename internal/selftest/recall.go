@@ -0,0 +1,131 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"MedAtlasAIServer/internal/alert"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultCasesPath holds the query→expected-PMID pairs
+const DefaultCasesPath = "data/selftest/recall_cases.json"
+
+// DefaultReportPath holds the last report, the baseline for the next run
+const DefaultReportPath = "data/selftest/recall_report.json"
+
+// Case is a query and the articles the index must return for it
+type Case struct {
+	Query    string   `json:"query"`
+	Expected []string `json:"expected"` // PMIDs
+}
+
+// Search returns the IDs of the top k articles for query
+type Search func(ctx context.Context, query string, k int) ([]string, error)
+
+// CaseResult is how one case fared
+type CaseResult struct {
+	Query   string   `json:"query"`
+	Recall  float64  `json:"recall"`
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Report is the outcome of one self-test run
+type Report struct {
+	Ran    time.Time    `json:"ran"`
+	K      int          `json:"k"`
+	Recall float64      `json:"recall"` // mean recall@K over the cases
+	Cases  []CaseResult `json:"cases"`
+	Failed int          `json:"failed"` // cases whose search errored, counted as zero recall
+}
+
+// LoadCases reads the cases at path
+func LoadCases(path string) ([]Case, error) {
+	var cases []Case
+	found, err := jsonfile.Read(path, &cases)
+	if err != nil {
+		return nil, err
+	}
+	if !found || len(cases) == 0 {
+		return nil, fmt.Errorf("no self-test cases in %s", path)
+	}
+	return cases, nil
+}
+
+// Run searches every case and measures recall@k: the share of each case's
+// expected articles among the first k results
+func Run(ctx context.Context, cases []Case, search Search, k int) *Report {
+	report := &Report{Ran: time.Now(), K: k}
+	total := 0.0
+	for _, c := range cases {
+		result := CaseResult{Query: c.Query}
+		ids, err := search(ctx, c.Query, k)
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			returned := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				returned[id] = true
+			}
+			for _, expected := range c.Expected {
+				if !returned[expected] {
+					result.Missing = append(result.Missing, expected)
+				}
+			}
+			if len(c.Expected) > 0 {
+				result.Recall = float64(len(c.Expected)-len(result.Missing)) / float64(len(c.Expected))
+			}
+		}
+		total += result.Recall
+		report.Cases = append(report.Cases, result)
+	}
+	if len(cases) > 0 {
+		report.Recall = total / float64(len(cases))
+	}
+	return report
+}
+
+// Thresholds decide when a run alerts: recall below MinRecall, or more than
+// MaxDrop below the previous run
+type Thresholds struct {
+	MinRecall float64
+	MaxDrop   float64
+}
+
+// Problem describes why report should alert given the previous report, or
+// returns "" when it should not. previous may be nil.
+func (t Thresholds) Problem(report, previous *Report) string {
+	if report.Recall < t.MinRecall {
+		return fmt.Sprintf("recall@%d is %.3f, below the minimum %.3f", report.K, report.Recall, t.MinRecall)
+	}
+	if previous != nil && previous.K == report.K && previous.Recall-report.Recall > t.MaxDrop {
+		return fmt.Sprintf("recall@%d dropped from %.3f to %.3f since %s", report.K, previous.Recall, report.Recall, previous.Ran.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// Check compares report with the baseline saved at reportPath and alerts
+// through alerts when it breaches t. A healthy report replaces the
+// baseline; a failing one does not, so every run alerts until recall
+// recovers. Delete the baseline to accept a deliberate change, e.g. after
+// editing the cases. It returns the alert summary, or "" when recall is
+// healthy.
+func Check(ctx context.Context, report *Report, reportPath string, t Thresholds, alerts *alert.Notifier) (string, error) {
+	var previous Report
+	found, err := jsonfile.Read(reportPath, &previous)
+	if err != nil {
+		return "", err
+	}
+	var baseline *Report
+	if found {
+		baseline = &previous
+	}
+	problem := t.Problem(report, baseline)
+	if problem != "" {
+		return problem, alerts.Notify(ctx, alert.Alert{Source: "recall-self-test", Summary: problem, Details: report, Time: report.Ran})
+	}
+	return "", jsonfile.WriteAtomic(reportPath, report)
+}
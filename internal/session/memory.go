@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+)
+
+// MemoryStore keeps sessions in process memory
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	ttl      time.Duration
+	Clock    clock.Clock
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session), ttl: ttl, Clock: clock.System}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || m.Clock.Now().Sub(s.Updated) > m.ttl {
+		return nil, fmt.Errorf("%w: session %s", apperrors.ErrNotFound, id)
+	}
+	copied := *s
+	copied.Messages = append([]ai.ChatMessage(nil), s.Messages...)
+	return &copied, nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, s *Session) error {
+	copied := *s
+	copied.Messages = append([]ai.ChatMessage(nil), s.Messages...)
+	copied.Updated = m.Clock.Now()
+	m.mu.Lock()
+	m.sessions[s.ID] = &copied
+	m.mu.Unlock()
+	s.Updated = copied.Updated
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// PurgeBefore drops sessions idle since before cutoff
+func (m *MemoryStore) PurgeBefore(cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := 0
+	for id, s := range m.sessions {
+		if s.Updated.Before(cutoff) {
+			delete(m.sessions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// DeleteUser drops every session of userID
+func (m *MemoryStore) DeleteUser(userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,257 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/encryption"
+)
+
+// redisKeyPrefix namespaces the keys this service writes
+const redisKeyPrefix = "medatlas:session:"
+
+// redisUserPrefix keys the set of session IDs of each signed-in user, so
+// their sessions can be erased on request
+const redisUserPrefix = "medatlas:user_sessions:"
+
+// redisTimeout bounds one command round trip
+const redisTimeout = 3 * time.Second
+
+// RedisStore keeps each session as a JSON string that Redis expires after
+// the TTL, sealed when it has keys. It speaks the Redis protocol over a
+// single connection, which is re-dialled after errors.
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	ttl      time.Duration
+	keys     *encryption.Keyring
+	Clock    clock.Clock
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStore creates a store that connects on first use. A nil keyring
+// stores sessions in plaintext.
+func NewRedisStore(addr, password string, db int, ttl time.Duration, keys *encryption.Keyring) *RedisStore {
+	return &RedisStore{addr: addr, password: password, db: db, ttl: ttl, keys: keys, Clock: clock.System}
+}
+
+// errRedisNil is a nil bulk reply: the key does not exist
+var errRedisNil = errors.New("redis: nil")
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	reply, err := r.do(ctx, "GET", redisKeyPrefix+id)
+	if errors.Is(err, errRedisNil) {
+		return nil, fmt.Errorf("%w: session %s", apperrors.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	stored, _ := reply.(string)
+	raw, err := unseal(r.keys, id, []byte(stored))
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+func (r *RedisStore) Save(ctx context.Context, session *Session) error {
+	saved := *session
+	saved.Updated = r.Clock.Now()
+	raw, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if raw, err = seal(r.keys, session.ID, raw); err != nil {
+		return err
+	}
+	seconds := strconv.Itoa(int(r.ttl.Seconds()))
+	if _, err := r.do(ctx, "SET", redisKeyPrefix+session.ID, string(raw), "EX", seconds); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if session.UserID != "" {
+		userKey := redisUserPrefix + session.UserID
+		if _, err := r.do(ctx, "SADD", userKey, session.ID); err != nil {
+			return fmt.Errorf("failed to index session: %w", err)
+		}
+		if _, err := r.do(ctx, "EXPIRE", userKey, seconds); err != nil {
+			return fmt.Errorf("failed to index session: %w", err)
+		}
+	}
+	session.Updated = saved.Updated
+	return nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	if _, err := r.do(ctx, "DEL", redisKeyPrefix+id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// PurgeBefore is a no-op: Redis expires idle sessions itself
+func (r *RedisStore) PurgeBefore(cutoff time.Time) (int, error) {
+	return 0, nil
+}
+
+// DeleteUser deletes every session of userID
+func (r *RedisStore) DeleteUser(userID string) (int, error) {
+	ctx := context.Background()
+	userKey := redisUserPrefix + userID
+	reply, err := r.do(ctx, "SMEMBERS", userKey)
+	if err != nil {
+		return 0, err
+	}
+	members, _ := reply.([]any)
+	if len(members) == 0 {
+		return 0, nil
+	}
+	args := []string{"DEL", userKey}
+	for _, member := range members {
+		if id, ok := member.(string); ok {
+			args = append(args, redisKeyPrefix+id)
+		}
+	}
+	reply, err = r.do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, _ := reply.(int64)
+	return max(int(deleted)-1, 0), nil // the index key itself
+}
+
+// do sends one command and reads its reply: a string, int64, []any or nil
+func (r *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := r.roundTrip(ctx, args)
+	var protocolErr redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &protocolErr) {
+		// The connection is in an unknown state; start afresh next time
+		r.conn.Close()
+		r.conn = nil
+	}
+	return reply, err
+}
+
+func (r *RedisStore) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: redisTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	r.conn, r.reader = conn, bufio.NewReader(conn)
+	var setup [][]string
+	if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, command := range setup {
+		if _, err := r.roundTrip(ctx, command); err != nil {
+			conn.Close()
+			r.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RedisStore) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	r.conn.SetDeadline(deadline)
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, command.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readReply(r.reader)
+}
+
+// redisError is an error reply; the connection remains usable
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func readReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer reply %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk reply %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array reply %q", line)
+		}
+		items := make([]any, 0, max(n, 0))
+		for i := 0; i < n; i++ {
+			item, err := readReply(reader)
+			if err != nil && !errors.Is(err, errRedisNil) {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
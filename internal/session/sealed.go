@@ -0,0 +1,49 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"MedAtlasAIServer/internal/encryption"
+)
+
+// sealedValue is the stored form of conversation content when encryption
+// is enabled, as recordlog seals its lines
+type sealedValue struct {
+	KeyID  string `json:"key_id"`
+	Sealed []byte `json:"sealed"`
+}
+
+// sealContext binds sealed values to this format and, with the session ID,
+// to their session, so they cannot be moved between sessions or stores
+const sealContext = "session/v1:"
+
+// seal returns the stored form of plaintext belonging to session id:
+// plaintext itself without keys, a sealedValue with them
+func seal(keys *encryption.Keyring, id string, plaintext []byte) ([]byte, error) {
+	if keys == nil {
+		return plaintext, nil
+	}
+	keyID, sealed, err := keys.Seal(plaintext, []byte(sealContext+id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal session %s: %w", id, err)
+	}
+	return json.Marshal(sealedValue{KeyID: keyID, Sealed: sealed})
+}
+
+// unseal reverses seal. Values stored before encryption was enabled are
+// returned as they are, and are sealed the next time the session is saved.
+func unseal(keys *encryption.Keyring, id string, stored []byte) ([]byte, error) {
+	var value sealedValue
+	if err := json.Unmarshal(stored, &value); err != nil || len(value.Sealed) == 0 {
+		return stored, nil
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("session %s is encrypted but no encryption keys are configured", id)
+	}
+	plaintext, err := keys.Open(value.KeyID, value.Sealed, []byte(sealContext+id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session %s: %w", id, err)
+	}
+	return plaintext, nil
+}
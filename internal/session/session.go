@@ -0,0 +1,154 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/encryption"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// DefaultTTL is how long an idle conversation is kept
+const DefaultTTL = 24 * time.Hour
+
+// MaxMessages bounds the history kept per session; older turns are dropped
+// first. The prompt only uses the last few turns anyway.
+const MaxMessages = 50
+
+// Session is one conversation. UserID is empty for anonymous sessions;
+// sessions of signed-in users are only returned to them.
+type Session struct {
+	ID       string           `json:"id"`
+	UserID   string           `json:"user_id,omitempty"`
+	Messages []ai.ChatMessage `json:"messages"`
+	// Summary condenses the first Summarized messages, which prompts no
+	// longer quote; see Summarize
+	Summary    string    `json:"summary,omitempty"`
+	Summarized int       `json:"summarized,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// SummaryBatch is how many messages must have aged out of the ones prompts
+// quote before Summarize rolls the summary forward. Until then prompts
+// summarize them locally, so the model is asked once every few turns.
+const SummaryBatch = 6
+
+// SummaryTimeout bounds a summarizer call
+const SummaryTimeout = 5 * time.Second
+
+// Append adds messages to the history, dropping the oldest beyond MaxMessages
+func (s *Session) Append(messages ...ai.ChatMessage) {
+	s.Messages = append(s.Messages, messages...)
+	if dropped := len(s.Messages) - MaxMessages; dropped > 0 {
+		s.Messages = append([]ai.ChatMessage(nil), s.Messages[dropped:]...)
+		s.Summarized = max(s.Summarized-dropped, 0)
+	}
+}
+
+// Unsummarized returns the messages the summary does not cover yet
+func (s *Session) Unsummarized() []ai.ChatMessage {
+	return s.Messages[min(s.Summarized, len(s.Messages)):]
+}
+
+// Summarize folds the messages older than the latest ai.RecentMessages into
+// the summary once SummaryBatch of them are not covered. It asks summarizer
+// when set, and summarizes locally without it or when it fails.
+func (s *Session) Summarize(ctx context.Context, summarizer ai.Summarizer) {
+	older := len(s.Messages) - ai.RecentMessages
+	if older-s.Summarized < SummaryBatch {
+		return
+	}
+	aged := s.Messages[s.Summarized:older]
+	var summary string
+	if summarizer != nil {
+		ctx, cancel := context.WithTimeout(ctx, SummaryTimeout)
+		defer cancel()
+		var err error
+		if summary, err = summarizer.SummarizeConversation(ctx, s.Summary, aged); err != nil {
+			log.Printf("⚠️  Conversation summary failed, summarizing locally: %v", err)
+		}
+	}
+	if summary == "" {
+		summary = ai.SummarizeLocally(s.Summary, aged)
+	}
+	s.Summary, s.Summarized = summary, older
+}
+
+// Store keeps conversations server-side. Get returns an error wrapping
+// apperrors.ErrNotFound for unknown or expired sessions. Implementations
+// are safe for concurrent use and also satisfy retention.Purger and
+// retention.Eraser.
+type Store interface {
+	Get(ctx context.Context, id string) (*Session, error)
+	Save(ctx context.Context, s *Session) error
+	Delete(ctx context.Context, id string) error
+	PurgeBefore(cutoff time.Time) (int, error)
+	DeleteUser(userID string) (int, error)
+}
+
+// FromEnv opens the backend named by SESSION_STORE:
+//
+//   - "memory" (default): lost on restart, one process only
+//   - "sqlite": SESSION_SQL_DSN (default data/sessions.db) through the
+//     database/sql driver named by SESSION_SQL_DRIVER (default "sqlite",
+//     the pure-Go driver linked in here)
+//   - "redis": REDIS_ADDR (default localhost:6379), REDIS_PASSWORD, REDIS_DB
+//
+// SESSION_TTL_HOURS overrides DefaultTTL. The sqlite and redis backends
+// seal conversations with keys, which may be nil to store them in plaintext.
+func FromEnv(keys *encryption.Keyring) (Store, error) {
+	ttl, err := TTLFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	switch backend := os.Getenv("SESSION_STORE"); backend {
+	case "", "memory":
+		return NewMemoryStore(ttl), nil
+	case "sqlite":
+		driver := envOr("SESSION_SQL_DRIVER", "sqlite")
+		db, err := sql.Open(driver, envOr("SESSION_SQL_DSN", "data/sessions.db"))
+		if err != nil {
+			return nil, fmt.Errorf("session store: %w", err)
+		}
+		return NewSQLStore(db, ttl, keys)
+	case "redis":
+		db := 0
+		if raw := os.Getenv("REDIS_DB"); raw != "" {
+			var err error
+			if db, err = strconv.Atoi(raw); err != nil {
+				return nil, fmt.Errorf("REDIS_DB must be a number")
+			}
+		}
+		return NewRedisStore(envOr("REDIS_ADDR", "localhost:6379"), os.Getenv("REDIS_PASSWORD"), db, ttl, keys), nil
+	default:
+		return nil, fmt.Errorf("SESSION_STORE must be memory, sqlite or redis, got %q", backend)
+	}
+}
+
+// TTLFromEnv returns SESSION_TTL_HOURS, or DefaultTTL when unset
+func TTLFromEnv() (time.Duration, error) {
+	raw := os.Getenv("SESSION_TTL_HOURS")
+	if raw == "" {
+		return DefaultTTL, nil
+	}
+	hours, err := strconv.Atoi(raw)
+	if err != nil || hours < 1 {
+		return 0, fmt.Errorf("SESSION_TTL_HOURS must be a positive number of hours")
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/encryption"
+)
+
+const createSessionsTable = `CREATE TABLE IF NOT EXISTS chat_sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL DEFAULT '',
+	messages TEXT NOT NULL,
+	summary TEXT NOT NULL DEFAULT '',
+	summarized INTEGER NOT NULL DEFAULT 0,
+	updated_at INTEGER NOT NULL
+)`
+
+// addedSessionColumns were added after the table was first released, and
+// are added to tables created without them
+var addedSessionColumns = []struct{ name, definition string }{
+	{"summary", "TEXT NOT NULL DEFAULT ''"},
+	{"summarized", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// SQLStore keeps sessions in a SQLite database (any database/sql driver
+// accepting SQLite syntax), one row per session with the history as JSON.
+// With keys, the history and summary are stored sealed.
+type SQLStore struct {
+	db    *sql.DB
+	ttl   time.Duration
+	keys  *encryption.Keyring
+	Clock clock.Clock
+}
+
+// NewSQLStore creates the sessions table in db if needed. A nil keyring
+// stores conversations in plaintext.
+func NewSQLStore(db *sql.DB, ttl time.Duration, keys *encryption.Keyring) (*SQLStore, error) {
+	if _, err := db.Exec(createSessionsTable); err != nil {
+		return nil, fmt.Errorf("failed to create sessions table: %w", err)
+	}
+	for _, column := range addedSessionColumns {
+		if _, err := db.Exec(`SELECT ` + column.name + ` FROM chat_sessions LIMIT 0`); err == nil {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE chat_sessions ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return nil, fmt.Errorf("failed to add sessions column %s: %w", column.name, err)
+		}
+	}
+	return &SQLStore{db: db, ttl: ttl, keys: keys, Clock: clock.System}, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (*Session, error) {
+	var (
+		session  = Session{ID: id}
+		messages string
+		summary  string
+		updated  int64
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, messages, summary, summarized, updated_at FROM chat_sessions WHERE id = ?`, id).
+		Scan(&session.UserID, &messages, &summary, &session.Summarized, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: session %s", apperrors.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	session.Updated = time.Unix(updated, 0)
+	if s.Clock.Now().Sub(session.Updated) > s.ttl {
+		return nil, fmt.Errorf("%w: session %s", apperrors.ErrNotFound, id)
+	}
+	rawMessages, err := unseal(s.keys, id, []byte(messages))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawMessages, &session.Messages); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	if summary != "" {
+		rawSummary, err := unseal(s.keys, id, []byte(summary))
+		if err != nil {
+			return nil, err
+		}
+		session.Summary = string(rawSummary)
+	}
+	return &session, nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, session *Session) error {
+	messages, err := json.Marshal(session.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if messages, err = seal(s.keys, session.ID, messages); err != nil {
+		return err
+	}
+	var summary []byte
+	if session.Summary != "" {
+		if summary, err = seal(s.keys, session.ID, []byte(session.Summary)); err != nil {
+			return err
+		}
+	}
+	updated := s.Clock.Now()
+	_, err = s.db.ExecContext(ctx, `INSERT INTO chat_sessions (id, user_id, messages, summary, summarized, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET user_id = excluded.user_id, messages = excluded.messages,
+			summary = excluded.summary, summarized = excluded.summarized, updated_at = excluded.updated_at`,
+		session.ID, session.UserID, string(messages), string(summary), session.Summarized, updated.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	session.Updated = updated
+	return nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM chat_sessions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// PurgeBefore deletes sessions idle since before cutoff
+func (s *SQLStore) PurgeBefore(cutoff time.Time) (int, error) {
+	return s.exec(`DELETE FROM chat_sessions WHERE updated_at < ?`, cutoff.Unix())
+}
+
+// DeleteUser deletes every session of userID
+func (s *SQLStore) DeleteUser(userID string) (int, error) {
+	return s.exec(`DELETE FROM chat_sessions WHERE user_id = ?`, userID)
+}
+
+func (s *SQLStore) exec(query string, args ...any) (int, error) {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
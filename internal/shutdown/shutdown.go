@@ -0,0 +1,70 @@
+// Package shutdown stops servers gracefully on deploys: on SIGINT or SIGTERM
+// a server stops accepting connections and lets the requests in flight,
+// such as chats waiting on the LLM, finish before the process exits.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is how long requests in flight are given to finish when
+// SHUTDOWN_TIMEOUT is unset. It should stay below the orchestrator's grace
+// period (30s on Kubernetes) minus the time cleanup takes.
+const DefaultTimeout = 25 * time.Second
+
+// Context returns a context cancelled by SIGINT or SIGTERM. Background work
+// started with it stops when the server starts shutting down.
+func Context() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// TimeoutFromEnv returns SHUTDOWN_TIMEOUT (e.g. "40s"), or DefaultTimeout
+func TimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("SHUTDOWN_TIMEOUT must be a positive duration, got %q", value)
+	}
+	return timeout, nil
+}
+
+// Serve runs listen, srv.ListenAndServe or srv.ListenAndServeTLS, until ctx
+// is done, then shuts srv down, waiting up to timeout for requests in
+// flight. Connections still open after timeout are closed and reported in
+// the returned error.
+func Serve(ctx context.Context, srv *http.Server, listen func() error, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() { errs <- listen() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("🛑 Shutting down; waiting up to %v for requests in flight", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		srv.Close()
+		err = fmt.Errorf("requests still in flight after %v were dropped: %w", timeout, err)
+	}
+	if listenErr := <-errs; !errors.Is(listenErr, http.ErrServerClosed) {
+		return listenErr
+	}
+	if err == nil {
+		log.Printf("✅ All requests finished")
+	}
+	return err
+}
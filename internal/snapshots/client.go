@@ -0,0 +1,96 @@
+package snapshots
+
+import (
+	"context"
+
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type snapshotKey struct{}
+
+// WithSnapshot makes reads with ctx go to the collections frozen in snapshot
+func WithSnapshot(ctx context.Context, snapshot *Snapshot) context.Context {
+	return context.WithValue(ctx, snapshotKey{}, snapshot)
+}
+
+// FromContext returns the snapshot set by WithSnapshot, or nil
+func FromContext(ctx context.Context) *Snapshot {
+	snapshot, _ := ctx.Value(snapshotKey{}).(*Snapshot)
+	return snapshot
+}
+
+// Client sends the reads of requests made WithSnapshot to the snapshot's
+// aliases. A collection the snapshot does not hold did not exist when it was
+// taken, so reads of it find nothing. Other requests pass through. Wrap it
+// inside the tiering client, so each tier is redirected on its own.
+type Client struct {
+	Points points.Reader
+}
+
+func NewClient(points points.Reader) *Client {
+	return &Client{Points: points}
+}
+
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	if snapshot := FromContext(ctx); snapshot != nil {
+		alias, ok := snapshot.Alias(in.CollectionName)
+		if !ok {
+			return &qdrant.SearchResponse{}, nil
+		}
+		in = proto.Clone(in).(*qdrant.SearchPoints)
+		in.CollectionName = alias
+	}
+	return c.Points.Search(ctx, in, opts...)
+}
+
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	if snapshot := FromContext(ctx); snapshot != nil {
+		alias, ok := snapshot.Alias(in.CollectionName)
+		if !ok {
+			return &qdrant.GetResponse{}, nil
+		}
+		in = proto.Clone(in).(*qdrant.GetPoints)
+		in.CollectionName = alias
+	}
+	return c.Points.Get(ctx, in, opts...)
+}
+
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	if snapshot := FromContext(ctx); snapshot != nil {
+		alias, ok := snapshot.Alias(in.CollectionName)
+		if !ok {
+			return &qdrant.ScrollResponse{}, nil
+		}
+		in = proto.Clone(in).(*qdrant.ScrollPoints)
+		in.CollectionName = alias
+	}
+	return c.Points.Scroll(ctx, in, opts...)
+}
+
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	if snapshot := FromContext(ctx); snapshot != nil {
+		alias, ok := snapshot.Alias(in.CollectionName)
+		if !ok {
+			return &qdrant.CountResponse{Result: &qdrant.CountResult{}}, nil
+		}
+		in = proto.Clone(in).(*qdrant.CountPoints)
+		in.CollectionName = alias
+	}
+	return c.Points.Count(ctx, in, opts...)
+}
+
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	if snapshot := FromContext(ctx); snapshot != nil {
+		alias, ok := snapshot.Alias(in.CollectionName)
+		if !ok {
+			return &qdrant.QueryResponse{}, nil
+		}
+		in = proto.Clone(in).(*qdrant.QueryPoints)
+		in.CollectionName = alias
+	}
+	return c.Points.Query(ctx, in, opts...)
+}
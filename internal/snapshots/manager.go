@@ -0,0 +1,231 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// DefaultRESTURL is Qdrant's REST API when QDRANT_HTTP_URL is unset.
+// Recovering a snapshot into a new collection is only offered over REST.
+const DefaultRESTURL = "http://localhost:6333"
+
+// RESTURLFromEnv returns QDRANT_HTTP_URL, or DefaultRESTURL
+func RESTURLFromEnv() string {
+	if value := os.Getenv("QDRANT_HTTP_URL"); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	return DefaultRESTURL
+}
+
+// FrozenCollection is the collection collection is recovered into for the
+// snapshot named name
+func FrozenCollection(collection, name string) string {
+	return collection + "_asof_" + name
+}
+
+// AliasName is the alias through which the frozen copy of collection in
+// the snapshot named name is searched
+func AliasName(collection, name string) string {
+	return collection + "@" + name
+}
+
+// SnapshotService is the part of qdrant.SnapshotsClient a Manager uses
+type SnapshotService interface {
+	Create(ctx context.Context, in *qdrant.CreateSnapshotRequest, opts ...grpc.CallOption) (*qdrant.CreateSnapshotResponse, error)
+	Delete(ctx context.Context, in *qdrant.DeleteSnapshotRequest, opts ...grpc.CallOption) (*qdrant.DeleteSnapshotResponse, error)
+}
+
+// CollectionService is the part of qdrant.CollectionsClient a Manager uses
+type CollectionService interface {
+	CollectionExists(ctx context.Context, in *qdrant.CollectionExistsRequest, opts ...grpc.CallOption) (*qdrant.CollectionExistsResponse, error)
+	Delete(ctx context.Context, in *qdrant.DeleteCollection, opts ...grpc.CallOption) (*qdrant.CollectionOperationResponse, error)
+	UpdateAliases(ctx context.Context, in *qdrant.ChangeAliases, opts ...grpc.CallOption) (*qdrant.CollectionOperationResponse, error)
+}
+
+// Manager takes and deletes snapshots in Qdrant and records them in Store
+type Manager struct {
+	Store       *Store
+	Snapshots   SnapshotService
+	Collections CollectionService
+	RESTURL     string
+	HTTPClient  *http.Client
+	Clock       clock.Clock
+}
+
+// NewManager manages snapshots on the Qdrant server behind conn, whose REST
+// API is at restURL
+func NewManager(store *Store, conn grpc.ClientConnInterface, restURL string) *Manager {
+	return &Manager{
+		Store:       store,
+		Snapshots:   qdrant.NewSnapshotsClient(conn),
+		Collections: qdrant.NewCollectionsClient(conn),
+		RESTURL:     restURL,
+		// Recovering copies the whole collection
+		HTTPClient: &http.Client{Timeout: 30 * time.Minute},
+		Clock:      clock.System,
+	}
+}
+
+// Create snapshots each of collections that exists, recovers the snapshots
+// into frozen collections, points an alias at each and records them as the
+// snapshot named name. On failure the collections already frozen are
+// removed again.
+func (m *Manager) Create(ctx context.Context, name, note string, collections []string) (*Snapshot, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if m.Store.Contains(name) {
+		return nil, apperrors.Invalid("name", "snapshot %q already exists", name)
+	}
+
+	snapshot := Snapshot{Name: name, Note: note, CreatedAt: m.Clock.Now().UTC()}
+	for _, collection := range collections {
+		exists, err := m.Collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: collection})
+		if err != nil {
+			m.discard(&snapshot)
+			return nil, fmt.Errorf("%w: %s: %w", apperrors.ErrSearchUnavailable, collection, err)
+		}
+		if !exists.GetResult().GetExists() {
+			continue
+		}
+		source, err := m.freeze(ctx, collection, name)
+		if source != nil {
+			snapshot.Sources = append(snapshot.Sources, *source)
+		}
+		if err != nil {
+			m.discard(&snapshot)
+			return nil, err
+		}
+		log.Printf("📸 Froze %s as %s for snapshot %s", collection, source.Alias, name)
+	}
+	if len(snapshot.Sources) == 0 {
+		return nil, fmt.Errorf("%w: none of %v exists", apperrors.ErrNotFound, collections)
+	}
+	if err := m.Store.Add(snapshot); err != nil {
+		m.discard(&snapshot)
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// freeze snapshots collection and recovers it under its alias. The returned
+// source lists what was created so far, also on error.
+func (m *Manager) freeze(ctx context.Context, collection, name string) (*Source, error) {
+	created, err := m.Snapshots.Create(ctx, &qdrant.CreateSnapshotRequest{CollectionName: collection})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to snapshot %s: %w", apperrors.ErrSearchUnavailable, collection, err)
+	}
+	source := &Source{
+		Collection: collection,
+		Snapshot:   created.GetSnapshotDescription().GetName(),
+		Frozen:     FrozenCollection(collection, name), // set first, so a partial recovery is cleaned up
+	}
+	if err := m.recover(ctx, source.Collection, source.Snapshot, source.Frozen); err != nil {
+		return source, err
+	}
+	if _, err := m.Collections.UpdateAliases(ctx, &qdrant.ChangeAliases{
+		Actions: []*qdrant.AliasOperations{qdrant.NewAliasCreate(AliasName(collection, name), source.Frozen)},
+	}); err != nil {
+		return source, fmt.Errorf("%w: failed to alias %s: %w", apperrors.ErrSearchUnavailable, source.Frozen, err)
+	}
+	source.Alias = AliasName(collection, name)
+	return source, nil
+}
+
+// recover creates target from the snapshot of collection named snapshot,
+// which Qdrant downloads from its own REST API
+func (m *Manager) recover(ctx context.Context, collection, snapshot, target string) error {
+	location := fmt.Sprintf("%s/collections/%s/snapshots/%s", m.RESTURL, url.PathEscape(collection), url.PathEscape(snapshot))
+	body, err := json.Marshal(map[string]string{"location": location})
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/collections/%s/snapshots/recover?wait=true", m.RESTURL, url.PathEscape(target))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to recover %s: %w", apperrors.ErrSearchUnavailable, target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: failed to recover %s: status %d: %s", apperrors.ErrSearchUnavailable, target, resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// Delete removes the snapshot named name: its aliases, frozen collections
+// and Qdrant snapshots, then its record
+func (m *Manager) Delete(ctx context.Context, name string) error {
+	snapshot, err := m.Store.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := m.remove(ctx, snapshot); err != nil {
+		return err
+	}
+	return m.Store.Remove(name)
+}
+
+// remove deletes what snapshot created in Qdrant. Parts already gone are
+// skipped, so a failed removal can be retried.
+func (m *Manager) remove(ctx context.Context, snapshot *Snapshot) error {
+	for _, source := range snapshot.Sources {
+		if source.Alias != "" {
+			// Errors are ignored: deleting the collection below drops the alias too
+			m.Collections.UpdateAliases(ctx, &qdrant.ChangeAliases{
+				Actions: []*qdrant.AliasOperations{qdrant.NewAliasDelete(source.Alias)},
+			})
+		}
+		if source.Frozen != "" && m.exists(ctx, source.Frozen) {
+			if _, err := m.Collections.Delete(ctx, &qdrant.DeleteCollection{CollectionName: source.Frozen}); err != nil {
+				return fmt.Errorf("%w: failed to delete %s: %w", apperrors.ErrSearchUnavailable, source.Frozen, err)
+			}
+		}
+		if source.Snapshot != "" {
+			if _, err := m.Snapshots.Delete(ctx, &qdrant.DeleteSnapshotRequest{
+				CollectionName: source.Collection,
+				SnapshotName:   source.Snapshot,
+			}); err != nil {
+				log.Printf("⚠️  Could not delete snapshot %s of %s: %v", source.Snapshot, source.Collection, err)
+			}
+		}
+	}
+	return nil
+}
+
+// exists reports whether collection exists, assuming it does when Qdrant
+// cannot tell
+func (m *Manager) exists(ctx context.Context, collection string) bool {
+	exists, err := m.Collections.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: collection})
+	return err != nil || exists.GetResult().GetExists()
+}
+
+// discard undoes a partly created snapshot, logging what could not be
+// removed
+func (m *Manager) discard(snapshot *Snapshot) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := m.remove(ctx, snapshot); err != nil {
+		log.Printf("⚠️  Could not clean up snapshot %s: %v", snapshot.Name, err)
+	}
+}
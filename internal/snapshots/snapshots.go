@@ -0,0 +1,200 @@
+// Package snapshots keeps named, frozen copies of the article collections
+// so searches can be run "as of" an earlier date. Each copy is a Qdrant
+// snapshot recovered into its own collection and reached through an alias;
+// this package records which aliases make up each named snapshot.
+package snapshots
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where the snapshot registry is kept when SNAPSHOTS_FILE is
+// unset
+const DefaultPath = "data/snapshots.json"
+
+// validName limits snapshot names to what is safe in Qdrant collection and
+// alias names
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Snapshot is the state of the searched collections at CreatedAt
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Sources   []Source  `json:"sources"`
+}
+
+// Source is one collection frozen in a snapshot
+type Source struct {
+	Collection string `json:"collection"` // the live collection
+	Snapshot   string `json:"snapshot"`   // the Qdrant snapshot taken of it
+	Frozen     string `json:"frozen"`     // the collection recovered from the snapshot
+	Alias      string `json:"alias"`      // searched in place of Collection
+}
+
+// Alias returns the alias that stands in for collection in s. Collections
+// that did not exist when s was taken have none.
+func (s *Snapshot) Alias(collection string) (string, bool) {
+	for _, source := range s.Sources {
+		if source.Collection == collection {
+			return source.Alias, true
+		}
+	}
+	return "", false
+}
+
+// ValidateName rejects names that cannot be used in Qdrant aliases
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return apperrors.Invalid("name", "must be 1-64 lowercase letters, digits, '-' or '_', starting with a letter or digit")
+	}
+	return nil
+}
+
+// Store is the registry of snapshots, persisted to a JSON file. It is safe
+// for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	path      string
+	snapshots map[string]*Snapshot
+}
+
+// PathFromEnv returns SNAPSHOTS_FILE, or DefaultPath
+func PathFromEnv() string {
+	if path := os.Getenv("SNAPSHOTS_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// NewStore loads path if it exists. An empty path keeps the registry in
+// memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, snapshots: make(map[string]*Snapshot)}
+	if path == "" {
+		return s, nil
+	}
+	var list []*Snapshot
+	if _, err := jsonfile.Read(path, &list); err != nil {
+		return nil, fmt.Errorf("failed to load snapshots: %w", err)
+	}
+	for _, snapshot := range list {
+		s.snapshots[snapshot.Name] = snapshot
+	}
+	return s, nil
+}
+
+// List returns every snapshot, oldest first
+func (s *Store) List() []Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted()
+}
+
+// sorted copies the snapshots, oldest first. Callers must hold s.mu.
+func (s *Store) sorted() []Snapshot {
+	list := make([]Snapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		list = append(list, *snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Get returns the snapshot named name
+func (s *Store) Get(name string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, ok := s.snapshots[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: snapshot %s", apperrors.ErrNotFound, name)
+	}
+	copied := *snapshot
+	return &copied, nil
+}
+
+// Add records snapshot, whose name must be unused
+func (s *Store) Add(snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.snapshots[snapshot.Name]; ok {
+		return apperrors.Invalid("name", "snapshot %q already exists", snapshot.Name)
+	}
+	s.snapshots[snapshot.Name] = &snapshot
+	if err := s.persist(); err != nil {
+		delete(s.snapshots, snapshot.Name)
+		return err
+	}
+	return nil
+}
+
+// Remove forgets the snapshot named name
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.snapshots[name]
+	if !ok {
+		return fmt.Errorf("%w: snapshot %s", apperrors.ErrNotFound, name)
+	}
+	delete(s.snapshots, name)
+	if err := s.persist(); err != nil {
+		s.snapshots[name] = snapshot
+		return err
+	}
+	return nil
+}
+
+// Contains reports whether a snapshot named name is registered
+func (s *Store) Contains(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.snapshots[name]
+	return ok
+}
+
+// Resolve returns the snapshot asOf names: a snapshot name, or a date
+// ("2024-03-01") or time (RFC 3339), which selects the latest snapshot taken
+// by then. A date covers the whole day.
+func (s *Store) Resolve(asOf string) (*Snapshot, error) {
+	if snapshot, err := s.Get(asOf); err == nil {
+		return snapshot, nil
+	}
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		day, dayErr := time.Parse("2006-01-02", asOf)
+		if dayErr != nil {
+			return nil, apperrors.Invalid("as_of", "must name a snapshot or be a date (YYYY-MM-DD) or RFC 3339 time")
+		}
+		at = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found *Snapshot
+	for _, snapshot := range s.sorted() {
+		if snapshot.CreatedAt.After(at) {
+			break
+		}
+		found = &snapshot
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: no snapshot was taken by %s", apperrors.ErrNotFound, asOf)
+	}
+	return found, nil
+}
+
+// persist writes the snapshots to s.path. Callers must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	return jsonfile.WriteAtomic(s.path, s.sorted())
+}
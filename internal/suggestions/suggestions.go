@@ -0,0 +1,248 @@
+// Package suggestions builds the follow-up suggestions shown with chat
+// answers. They come from templates keyed by the question's intent and by
+// the type of entity it names, e.g. a drug or a condition, loaded from a
+// JSON file so they can be edited without a release. The built-in templates
+// apply when there is no file.
+package suggestions
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where templates are kept when SUGGESTIONS_FILE is unset
+const DefaultPath = "data/suggestions.json"
+
+// MaxSuggestions bounds the suggestions given with one answer
+const MaxSuggestions = 6
+
+// Template is one suggestion and when it applies
+type Template struct {
+	// Intent restricts the template to questions of one intent, e.g.
+	// "treatment_info"; empty matches every intent
+	Intent string `json:"intent,omitempty"`
+	// EntityType restricts the template to questions naming an entity of
+	// the type, which the text refers to as {{.Entity}}
+	EntityType string `json:"entity_type,omitempty"`
+	Text       string `json:"text"`
+}
+
+// Catalog is the content of a templates file. Templates are suggested in
+// the order listed, so put the most useful first.
+type Catalog struct {
+	// EntityTypes lists the terms that name an entity of each type
+	EntityTypes map[string][]string `json:"entity_types,omitempty"`
+	Templates   []Template          `json:"templates"`
+}
+
+//go:embed defaults.json
+var defaultCatalog []byte
+
+// builtin is the compiled default catalog
+var builtin = mustCompile(defaultCatalog)
+
+// compiled is a catalog ready to suggest from
+type compiled struct {
+	templates []*template.Template
+	catalog   Catalog
+	terms     map[string][]*regexp.Regexp // by entity type, in the order listed
+}
+
+func mustCompile(raw []byte) *compiled {
+	var catalog Catalog
+	if err := json.Unmarshal(raw, &catalog); err != nil {
+		panic(fmt.Sprintf("suggestions: invalid built-in templates: %v", err))
+	}
+	c, err := compile(catalog)
+	if err != nil {
+		panic(fmt.Sprintf("suggestions: invalid built-in templates: %v", err))
+	}
+	return c
+}
+
+// compile parses the templates of catalog and checks that each entity type
+// they name has terms
+func compile(catalog Catalog) (*compiled, error) {
+	c := &compiled{catalog: catalog, terms: make(map[string][]*regexp.Regexp)}
+	for entityType, terms := range catalog.EntityTypes {
+		for _, term := range terms {
+			if term = strings.TrimSpace(term); term == "" {
+				return nil, fmt.Errorf("entity type %q has an empty term", entityType)
+			}
+			c.terms[entityType] = append(c.terms[entityType],
+				regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(`+regexp.QuoteMeta(term)+`)(?:$|[^\pL\pN])`))
+		}
+	}
+	for i, t := range catalog.Templates {
+		if strings.TrimSpace(t.Text) == "" {
+			return nil, fmt.Errorf("template %d has no text", i+1)
+		}
+		if t.EntityType != "" && len(c.terms[t.EntityType]) == 0 {
+			return nil, fmt.Errorf("template %d names entity type %q, which has no terms", i+1, t.EntityType)
+		}
+		parsed, err := template.New("").Option("missingkey=error").Parse(t.Text)
+		if err != nil {
+			return nil, fmt.Errorf("template %d: %w", i+1, err)
+		}
+		c.templates = append(c.templates, parsed)
+	}
+	return c, nil
+}
+
+// suggest renders the templates matching intent and the entities message
+// names, in order and without repeats
+func (c *compiled) suggest(intent, message string) []string {
+	entities := make(map[string]string)
+	var suggestions []string
+	seen := make(map[string]bool)
+	for i, t := range c.catalog.Templates {
+		if len(suggestions) == MaxSuggestions {
+			break
+		}
+		if t.Intent != "" && t.Intent != intent {
+			continue
+		}
+		data := map[string]string{}
+		if t.EntityType != "" {
+			entity, ok := entities[t.EntityType]
+			if !ok {
+				entity = c.find(t.EntityType, message)
+				entities[t.EntityType] = entity
+			}
+			if entity == "" {
+				continue
+			}
+			data["Entity"] = entity
+		}
+		var text bytes.Buffer
+		if err := c.templates[i].Execute(&text, data); err != nil {
+			continue
+		}
+		suggestion := strings.TrimSpace(text.String())
+		if key := strings.ToLower(suggestion); !seen[key] {
+			seen[key] = true
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// find returns the first term of entityType that message names, lowercased,
+// or ""
+func (c *compiled) find(entityType, message string) string {
+	for _, pattern := range c.terms[entityType] {
+		if match := pattern.FindStringSubmatch(message); match != nil {
+			return strings.ToLower(match[1])
+		}
+	}
+	return ""
+}
+
+// Engine suggests from the templates in a JSON file, or the built-in ones
+// when the file does not exist. Servers sharing the file pick up edits with
+// Watch. It is safe for concurrent use, and a nil *Engine suggests from the
+// built-in templates.
+type Engine struct {
+	mu      sync.RWMutex
+	path    string
+	modTime time.Time
+	catalog *compiled
+}
+
+// PathFromEnv returns SUGGESTIONS_FILE, or DefaultPath
+func PathFromEnv() string {
+	if path := os.Getenv("SUGGESTIONS_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Open loads the templates in path if it exists. An empty path uses the
+// built-in templates.
+func Open(path string) (*Engine, error) {
+	e := &Engine{path: path, catalog: builtin}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// load replaces the templates with the file's. Callers must hold e.mu or
+// own e.
+func (e *Engine) load() error {
+	if e.path == "" {
+		return nil
+	}
+	info, statErr := os.Stat(e.path)
+	var catalog Catalog
+	found, err := jsonfile.Read(e.path, &catalog)
+	if err != nil {
+		return fmt.Errorf("failed to load suggestions: %w", err)
+	}
+	if !found {
+		e.catalog = builtin
+		return nil
+	}
+	c, err := compile(catalog)
+	if err != nil {
+		return fmt.Errorf("invalid suggestions in %s: %w", e.path, err)
+	}
+	e.catalog = c
+	if statErr == nil {
+		e.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Suggest returns the suggestions for a question of intent, at most
+// MaxSuggestions
+func (e *Engine) Suggest(intent, message string) []string {
+	if e == nil {
+		return builtin.suggest(intent, message)
+	}
+	e.mu.RLock()
+	c := e.catalog
+	e.mu.RUnlock()
+	return c.suggest(intent, message)
+}
+
+// Watch reloads the templates when the file changes, checking every
+// interval. A file that fails to load keeps the previous templates. It
+// returns when ctx is cancelled.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e == nil || e.path == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(e.path)
+			if err != nil {
+				continue
+			}
+			e.mu.Lock()
+			if !info.ModTime().Equal(e.modTime) {
+				if err := e.load(); err != nil {
+					log.Printf("⚠️  Suggestions reload rejected: %v", err)
+				}
+			}
+			e.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Package synonyms keeps the synonym groups admins define for query
+// rewriting, e.g. "heart attack" and "myocardial infarction". A query naming
+// one term of a group is searched with the group's other terms as well.
+package synonyms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where synonym groups are kept when SYNONYMS_FILE is unset
+const DefaultPath = "data/synonyms.json"
+
+// Limits on a group
+const (
+	MaxTerms      = 20
+	MaxTermLength = 100
+)
+
+// Group is a set of interchangeable terms
+type Group struct {
+	ID        string    `json:"id"`
+	Terms     []string  `json:"terms"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps synonym groups in memory and persists them to a JSON file.
+// Servers sharing the file pick up each other's edits with Watch. It is safe
+// for concurrent use, and a nil *Store expands nothing.
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	modTime  time.Time
+	groups   map[string]*Group
+	patterns map[string]*regexp.Regexp // by lowercased term
+	Clock    clock.Clock
+	IDs      ids.Generator
+}
+
+// PathFromEnv returns SYNONYMS_FILE, or DefaultPath
+func PathFromEnv() string {
+	if path := os.Getenv("SYNONYMS_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// NewStore loads path if it exists. An empty path keeps groups in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		groups:   make(map[string]*Group),
+		patterns: make(map[string]*regexp.Regexp),
+		Clock:    clock.System,
+		IDs:      ids.UUIDGenerator{Prefix: "syn_"},
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replaces the groups with the file's. Callers must hold s.mu or own s.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+	info, statErr := os.Stat(s.path)
+	var groups []*Group
+	if _, err := jsonfile.Read(s.path, &groups); err != nil {
+		return fmt.Errorf("failed to load synonyms: %w", err)
+	}
+	s.groups = make(map[string]*Group, len(groups))
+	s.patterns = make(map[string]*regexp.Regexp)
+	for _, group := range groups {
+		s.groups[group.ID] = group
+		s.compile(group)
+	}
+	if statErr == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// compile adds the match patterns of group's terms. Callers must hold s.mu.
+func (s *Store) compile(group *Group) {
+	for _, term := range group.Terms {
+		key := strings.ToLower(term)
+		if _, ok := s.patterns[key]; !ok {
+			s.patterns[key] = regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(term) + `($|[^\pL\pN])`)
+		}
+	}
+}
+
+// List returns every group, ordered by ID
+func (s *Store) List() []Group {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		list = append(list, *group)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Create adds a group of terms and returns it with its assigned ID
+func (s *Store) Create(terms []string) (*Group, error) {
+	terms, err := normalize(terms)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkConflicts("", terms); err != nil {
+		return nil, err
+	}
+	group := &Group{ID: s.IDs.New(), Terms: terms, UpdatedAt: s.Clock.Now()}
+	s.groups[group.ID] = group
+	if err := s.persist(); err != nil {
+		delete(s.groups, group.ID)
+		return nil, err
+	}
+	s.rebuildPatterns()
+	copied := *group
+	return &copied, nil
+}
+
+// Update replaces the terms of the group with id
+func (s *Store) Update(id string, terms []string) (*Group, error) {
+	terms, err := normalize(terms)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: synonym group %s", apperrors.ErrNotFound, id)
+	}
+	if err := s.checkConflicts(id, terms); err != nil {
+		return nil, err
+	}
+	group := &Group{ID: id, Terms: terms, UpdatedAt: s.Clock.Now()}
+	s.groups[id] = group
+	if err := s.persist(); err != nil {
+		s.groups[id] = previous
+		return nil, err
+	}
+	s.rebuildPatterns()
+	copied := *group
+	return &copied, nil
+}
+
+// Delete removes the group with id
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group, ok := s.groups[id]
+	if !ok {
+		return fmt.Errorf("%w: synonym group %s", apperrors.ErrNotFound, id)
+	}
+	delete(s.groups, id)
+	if err := s.persist(); err != nil {
+		s.groups[id] = group
+		return err
+	}
+	s.rebuildPatterns()
+	return nil
+}
+
+// Expand appends to query the synonyms of the terms it names, in the form
+// data.ExpandDrugNames uses: "heart attack (myocardial infarction)".
+// Terms are matched as whole words, ignoring case.
+func (s *Store) Expand(query string) string {
+	if s == nil {
+		return query
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var extra []string
+	added := make(map[string]bool)
+	for _, id := range s.sortedIDs() {
+		group := s.groups[id]
+		named := false
+		for _, term := range group.Terms {
+			if s.patterns[strings.ToLower(term)].MatchString(query) {
+				named = true
+				break
+			}
+		}
+		if !named {
+			continue
+		}
+		for _, term := range group.Terms {
+			key := strings.ToLower(term)
+			if added[key] || s.patterns[key].MatchString(query) {
+				continue
+			}
+			added[key] = true
+			extra = append(extra, term)
+		}
+	}
+	if len(extra) == 0 {
+		return query
+	}
+	return query + " (" + strings.Join(extra, ", ") + ")"
+}
+
+// Watch reloads the groups when the file changes, checking every interval,
+// so edits made through another server apply without a restart. It returns
+// when ctx is cancelled.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if s == nil || s.path == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				continue
+			}
+			s.mu.Lock()
+			if !info.ModTime().Equal(s.modTime) {
+				if err := s.load(); err != nil {
+					log.Printf("⚠️  Synonym reload rejected: %v", err)
+				} else {
+					log.Printf("🔄 Synonyms reloaded from %s (%d groups)", s.path, len(s.groups))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// normalize trims the terms and drops duplicates, and checks the group is
+// usable
+func normalize(terms []string) ([]string, error) {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		term = strings.Join(strings.Fields(term), " ")
+		if term == "" || seen[strings.ToLower(term)] {
+			continue
+		}
+		if len(term) > MaxTermLength {
+			return nil, apperrors.Invalid("terms", "terms must be at most %d characters", MaxTermLength)
+		}
+		seen[strings.ToLower(term)] = true
+		cleaned = append(cleaned, term)
+	}
+	if len(cleaned) < 2 || len(cleaned) > MaxTerms {
+		return nil, apperrors.Invalid("terms", "a synonym group needs between 2 and %d distinct terms", MaxTerms)
+	}
+	return cleaned, nil
+}
+
+// checkConflicts rejects terms that already belong to a group other than
+// id, which would make expansion depend on group order. Callers must hold
+// s.mu.
+func (s *Store) checkConflicts(id string, terms []string) error {
+	for _, group := range s.groups {
+		if group.ID == id {
+			continue
+		}
+		for _, existing := range group.Terms {
+			for _, term := range terms {
+				if strings.EqualFold(existing, term) {
+					return apperrors.Invalid("terms", "%q already belongs to synonym group %s", term, group.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) rebuildPatterns() {
+	s.patterns = make(map[string]*regexp.Regexp)
+	for _, group := range s.groups {
+		s.compile(group)
+	}
+}
+
+func (s *Store) sortedIDs() []string {
+	ids := make([]string, 0, len(s.groups))
+	for id := range s.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// persist writes all groups to disk. Callers must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	groups := make([]*Group, 0, len(s.groups))
+	for _, id := range s.sortedIDs() {
+		groups = append(groups, s.groups[id])
+	}
+	if err := jsonfile.WriteAtomic(s.path, groups); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
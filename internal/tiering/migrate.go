@@ -0,0 +1,104 @@
+package tiering
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// migrationPageSize is how many recent points are examined per scroll page
+const migrationPageSize = 256
+
+// MigrationPoints is the part of qdrant.PointsClient Migrate needs
+type MigrationPoints interface {
+	Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error)
+	Upsert(ctx context.Context, in *qdrant.UpsertPoints, opts ...grpc.CallOption) (*qdrant.PointsOperationResponse, error)
+	Delete(ctx context.Context, in *qdrant.DeletePoints, opts ...grpc.CallOption) (*qdrant.PointsOperationResponse, error)
+}
+
+// Migrate moves points that have aged out of the recent tier into the
+// historical tier and returns how many were moved. Each page is written to
+// the historical tier before it is deleted from the recent one, so an
+// interrupted run leaves duplicates (which searches collapse) rather than gaps.
+func Migrate(ctx context.Context, points MigrationPoints, router Router) (int, error) {
+	cutoff := router.Cutoff()
+	wait := true
+	moved := 0
+
+	var offset *qdrant.PointId
+	for {
+		limit := uint32(migrationPageSize)
+		page, err := points.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: RecentCollection(),
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+			WithVectors:    &qdrant.WithVectorsSelector{SelectorOptions: &qdrant.WithVectorsSelector_Enable{Enable: true}},
+		})
+		if err != nil {
+			return moved, fmt.Errorf("failed to scroll %s: %w", RecentCollection(), err)
+		}
+
+		var aging []*qdrant.PointStruct
+		var ids []*qdrant.PointId
+		for _, point := range page.GetResult() {
+			if publishedBefore(point.GetPayload(), cutoff) {
+				aging = append(aging, &qdrant.PointStruct{
+					Id:      point.GetId(),
+					Vectors: vectorsFromOutput(point.GetVectors()),
+					Payload: point.GetPayload(),
+				})
+				ids = append(ids, point.GetId())
+			}
+		}
+
+		if len(aging) > 0 {
+			if _, err := points.Upsert(ctx, &qdrant.UpsertPoints{
+				CollectionName: HistoricalCollection(),
+				Points:         aging,
+				Wait:           &wait,
+			}); err != nil {
+				return moved, fmt.Errorf("failed to copy points to %s: %w", HistoricalCollection(), err)
+			}
+			if _, err := points.Delete(ctx, &qdrant.DeletePoints{
+				CollectionName: RecentCollection(),
+				Wait:           &wait,
+				Points: &qdrant.PointsSelector{PointsSelectorOneOf: &qdrant.PointsSelector_Points{
+					Points: &qdrant.PointsIdsList{Ids: ids},
+				}},
+			}); err != nil {
+				return moved, fmt.Errorf("failed to remove migrated points from %s: %w", RecentCollection(), err)
+			}
+			moved += len(aging)
+		}
+
+		offset = page.GetNextPageOffset()
+		if offset == nil {
+			return moved, nil
+		}
+	}
+}
+
+// publishedBefore reports whether the payload's published_date is before
+// cutoff. Undated points are treated as historical, matching Router.
+func publishedBefore(payload map[string]*qdrant.Value, cutoff time.Time) bool {
+	published, err := time.Parse("2006-01-02", payload["published_date"].GetStringValue())
+	if err != nil || published.IsZero() {
+		return true
+	}
+	return published.Before(cutoff)
+}
+
+// vectorsFromOutput converts a scrolled point's dense vector back into the
+// form Upsert accepts
+func vectorsFromOutput(out *qdrant.VectorsOutput) *qdrant.Vectors {
+	vector := out.GetVector()
+	data := vector.GetData()
+	if dense := vector.GetDense(); dense != nil {
+		data = dense.GetData() // newer servers return dense vectors here
+	}
+	return &qdrant.Vectors{VectorsOptions: &qdrant.Vectors_Vector{Vector: &qdrant.Vector{Data: data}}}
+}
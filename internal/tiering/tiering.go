@@ -0,0 +1,281 @@
+package tiering
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/points"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// HistoricalCollection is the historical tier, which keeps the configured
+// article collection name so existing deployments and tools keep working
+func HistoricalCollection() string {
+	return config.ArticleCollection()
+}
+
+// RecentCollection holds recent literature in a small collection that is
+// searched first
+func RecentCollection() string {
+	return config.ArticleCollection() + "_recent"
+}
+
+// DefaultWindow is how long an article stays in the recent tier
+const DefaultWindow = 2 * 365 * 24 * time.Hour
+
+// DefaultMinScore is the similarity a recent hit needs to count towards
+// filling a page without consulting the historical tier
+const DefaultMinScore = 0.5
+
+// Router decides which tier an article belongs to
+type Router struct {
+	Window time.Duration
+	Clock  clock.Clock
+}
+
+// NewRouter routes articles published within DefaultWindow to the recent tier
+func NewRouter() Router {
+	return Router{Window: DefaultWindow, Clock: clock.System}
+}
+
+// Cutoff is the publication date before which articles are historical
+func (r Router) Cutoff() time.Time {
+	return r.Clock.Now().Add(-r.Window)
+}
+
+// CollectionFor returns the collection for an article published at published.
+// Undated articles are historical.
+func (r Router) CollectionFor(published time.Time) string {
+	if published.IsZero() || published.Before(r.Cutoff()) {
+		return HistoricalCollection()
+	}
+	return RecentCollection()
+}
+
+// Client searches the recent tier first and the historical tier only when
+// the recent one cannot fill the page with good enough hits, or when the
+// caller asked for it with WithHistorical. Requests for other collections
+// pass through unchanged.
+type Client struct {
+	Points   points.Reader
+	MinScore float32
+}
+
+// NewClient wraps points with the default tiering policy
+func NewClient(points points.Reader) *Client {
+	return &Client{Points: points, MinScore: DefaultMinScore}
+}
+
+type historicalKey struct{}
+
+// WithHistorical makes searches with ctx always include the historical tier
+func WithHistorical(ctx context.Context) context.Context {
+	return context.WithValue(ctx, historicalKey{}, true)
+}
+
+func historicalRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(historicalKey{}).(bool)
+	return requested
+}
+
+// Search implements ai.Searcher
+func (c *Client) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	if in.CollectionName != HistoricalCollection() {
+		return c.Points.Search(ctx, in, opts...)
+	}
+	if offset := in.GetOffset(); offset > 0 {
+		// Offsets only make sense within one tier, so fetch the pages
+		// before this one from both and skip them after merging
+		whole := proto.Clone(in).(*qdrant.SearchPoints)
+		whole.Limit += offset
+		whole.Offset = nil
+		result, err := c.Search(ctx, whole, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(result.Result)) <= offset {
+			result.Result = nil
+		} else {
+			result.Result = result.Result[offset:]
+		}
+		return result, nil
+	}
+	start := time.Now()
+
+	recentReq := proto.Clone(in).(*qdrant.SearchPoints)
+	recentReq.CollectionName = RecentCollection()
+	recent, err := c.Points.Search(ctx, recentReq, opts...)
+	if err != nil {
+		// A missing or unavailable recent tier must not hide the corpus
+		return c.Points.Search(ctx, in, opts...)
+	}
+	if !historicalRequested(ctx) && c.filled(recent.GetResult(), in.GetLimit()) {
+		return recent, nil
+	}
+
+	historical, err := c.Points.Search(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeByScore(recent.GetResult(), historical.GetResult(), int(in.GetLimit()))
+	return &qdrant.SearchResponse{Result: merged, Time: time.Since(start).Seconds()}, nil
+}
+
+// filled reports whether the recent hits alone make a full page
+func (c *Client) filled(hits []*qdrant.ScoredPoint, limit uint64) bool {
+	good := uint64(0)
+	for _, hit := range hits {
+		if hit.GetScore() >= c.MinScore {
+			good++
+		}
+	}
+	return good >= limit
+}
+
+// mergeByScore combines two result lists, best first, keeping one copy of
+// points present in both tiers (e.g. mid-migration)
+func mergeByScore(a, b []*qdrant.ScoredPoint, limit int) []*qdrant.ScoredPoint {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]*qdrant.ScoredPoint, 0, len(a)+len(b))
+	for _, hit := range append(append([]*qdrant.ScoredPoint{}, a...), b...) {
+		key := hit.GetId().String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, hit)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].GetScore() > merged[j].GetScore() })
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// Count adds up the matching points in both tiers. A missing recent tier
+// counts as empty.
+func (c *Client) Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error) {
+	if in.CollectionName != HistoricalCollection() {
+		return c.Points.Count(ctx, in, opts...)
+	}
+	historical, err := c.Points.Count(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	recentReq := proto.Clone(in).(*qdrant.CountPoints)
+	recentReq.CollectionName = RecentCollection()
+	if recent, err := c.Points.Count(ctx, recentReq, opts...); err == nil && historical.Result != nil {
+		historical.Result.Count += recent.GetResult().GetCount()
+	}
+	return historical, nil
+}
+
+// Query passes through: the collections queried, such as the multivector
+// one, are not tiered
+func (c *Client) Query(ctx context.Context, in *qdrant.QueryPoints, opts ...grpc.CallOption) (*qdrant.QueryResponse, error) {
+	return c.Points.Query(ctx, in, opts...)
+}
+
+// Get implements ai.PointGetter, looking in the recent tier first and the
+// historical tier for the points not found there
+func (c *Client) Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error) {
+	if in.CollectionName != HistoricalCollection() {
+		return c.Points.Get(ctx, in, opts...)
+	}
+
+	recentReq := proto.Clone(in).(*qdrant.GetPoints)
+	recentReq.CollectionName = RecentCollection()
+	recent, err := c.Points.Get(ctx, recentReq, opts...)
+	if err != nil {
+		return c.Points.Get(ctx, in, opts...)
+	}
+
+	found := make(map[string]bool, len(recent.GetResult()))
+	for _, point := range recent.GetResult() {
+		found[point.GetId().String()] = true
+	}
+	var missing []*qdrant.PointId
+	for _, id := range in.GetIds() {
+		if !found[id.String()] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return recent, nil
+	}
+
+	historicalReq := proto.Clone(in).(*qdrant.GetPoints)
+	historicalReq.Ids = missing
+	historical, err := c.Points.Get(ctx, historicalReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("historical tier: %w", err)
+	}
+	return &qdrant.GetResponse{Result: append(recent.GetResult(), historical.GetResult()...)}, nil
+}
+
+// Scroll reads matching points from the recent tier and then the historical
+// tier until Limit is reached. A point lives in only one tier, so a request
+// carrying an Offset resumes in the tier holding that point, and the returned
+// NextPageOffset always names the next point of whichever tier comes next.
+func (c *Client) Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error) {
+	if in.CollectionName != HistoricalCollection() || (in.Offset != nil && !c.inRecent(ctx, in.Offset, opts...)) {
+		return c.Points.Scroll(ctx, in, opts...)
+	}
+
+	recentReq := proto.Clone(in).(*qdrant.ScrollPoints)
+	recentReq.CollectionName = RecentCollection()
+	recent, err := c.Points.Scroll(ctx, recentReq, opts...)
+	if err != nil {
+		if in.Offset != nil {
+			return nil, fmt.Errorf("recent tier: %w", err)
+		}
+		return c.Points.Scroll(ctx, in, opts...)
+	}
+	if recent.NextPageOffset != nil {
+		return recent, nil
+	}
+
+	// The recent tier is exhausted, so the rest of the page, or just the
+	// cursor for the next one, comes from the start of the historical tier
+	historicalReq := proto.Clone(in).(*qdrant.ScrollPoints)
+	historicalReq.Offset = nil
+	peek := false
+	if in.Limit != nil {
+		remaining := in.GetLimit() - min(in.GetLimit(), uint32(len(recent.GetResult())))
+		if remaining == 0 {
+			remaining, peek = 1, true
+		}
+		historicalReq.Limit = &remaining
+	}
+	historical, err := c.Points.Scroll(ctx, historicalReq, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("historical tier: %w", err)
+	}
+	if peek {
+		var next *qdrant.PointId
+		if len(historical.GetResult()) > 0 {
+			next = historical.GetResult()[0].GetId()
+		}
+		return &qdrant.ScrollResponse{Result: recent.GetResult(), NextPageOffset: next}, nil
+	}
+	return &qdrant.ScrollResponse{
+		Result:         append(recent.GetResult(), historical.GetResult()...),
+		NextPageOffset: historical.GetNextPageOffset(),
+	}, nil
+}
+
+// inRecent reports whether id is stored in the recent tier
+func (c *Client) inRecent(ctx context.Context, id *qdrant.PointId, opts ...grpc.CallOption) bool {
+	resp, err := c.Points.Get(ctx, &qdrant.GetPoints{
+		CollectionName: RecentCollection(),
+		Ids:            []*qdrant.PointId{id},
+	}, opts...)
+	return err == nil && len(resp.GetResult()) > 0
+}
@@ -0,0 +1,277 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Export batching: spans are sent when batchSize are waiting or every
+// flushInterval, and dropped when queueSize are waiting
+const (
+	batchSize     = 512
+	queueSize     = 4096
+	flushInterval = 5 * time.Second
+)
+
+// finishedSpan is a span waiting for export
+type finishedSpan struct {
+	span *Span
+	end  time.Time
+	err  error
+}
+
+// otlpExporter posts spans as OTLP/HTTP JSON to a collector
+type otlpExporter struct {
+	endpoint string
+	service  string
+	ratio    float64
+	client   *http.Client
+	queue    chan finishedSpan
+	flush    chan chan struct{}
+	dropped  atomic.Int64
+}
+
+var current atomic.Pointer[otlpExporter]
+
+func exporter() *otlpExporter {
+	return current.Load()
+}
+
+// Setup turns tracing on when OTEL_EXPORTER_OTLP_ENDPOINT (e.g.
+// http://jaeger:4318) or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. Spans
+// are reported as OTEL_SERVICE_NAME, default service, and new traces are
+// sampled at OTEL_TRACES_SAMPLER_ARG, default 1 (every trace).
+func Setup(service string) error {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := strings.TrimRight(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+		if base == "" {
+			return nil
+		}
+		endpoint = base + "/v1/traces"
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		service = name
+	}
+	ratio := 1.0
+	if arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); arg != "" {
+		parsed, err := strconv.ParseFloat(arg, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a ratio between 0 and 1, got %q", arg)
+		}
+		ratio = parsed
+	}
+	e := &otlpExporter{
+		endpoint: endpoint,
+		service:  service,
+		ratio:    ratio,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan finishedSpan, queueSize),
+		flush:    make(chan chan struct{}),
+	}
+	current.Store(e)
+	go e.run()
+	log.Printf("🔭 Exporting traces of %s to %s (sampling %.0f%%)", service, endpoint, ratio*100)
+	return nil
+}
+
+func (e *otlpExporter) enqueue(span finishedSpan) {
+	select {
+	case e.queue <- span:
+	default:
+		if e.dropped.Add(1)%1000 == 1 {
+			log.Printf("⚠️  Trace export is falling behind; spans are being dropped")
+		}
+	}
+}
+
+// Flush exports the spans still waiting, so a server that is shutting down
+// does not lose its last traces. It returns once they are sent or ctx is done.
+func Flush(ctx context.Context) {
+	e := exporter()
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case e.flush <- done:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (e *otlpExporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]finishedSpan, 0, batchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case done := <-e.flush:
+			e.drain(batch)
+			batch = batch[:0]
+			close(done)
+			continue
+		}
+		e.export(batch)
+		batch = batch[:0]
+	}
+}
+
+// drain exports batch and everything queued behind it
+func (e *otlpExporter) drain(batch []finishedSpan) {
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) < batchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) == 0 {
+			return
+		}
+		full := len(batch) == batchSize
+		e.export(batch)
+		batch = batch[:0]
+		if !full {
+			return
+		}
+	}
+}
+
+func (e *otlpExporter) export(batch []finishedSpan) {
+	if err := e.send(batch); err != nil {
+		log.Printf("⚠️  Trace export failed: %v", err)
+	}
+}
+
+// OTLP JSON encoding of ExportTraceServiceRequest
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // unset, or 2 for errors
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func (e *otlpExporter) send(batch []finishedSpan) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, finished := range batch {
+		spans[i] = encodeSpan(finished)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{attribute("service.name", e.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "MedAtlasAIServer"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func encodeSpan(finished finishedSpan) otlpSpan {
+	s := finished.span
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(finished.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		encoded.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if finished.err != nil {
+		encoded.Status = otlpStatus{Code: 2, Message: finished.err.Error()}
+	}
+	s.mu.Lock()
+	for key, value := range s.attrs {
+		encoded.Attributes = append(encoded.Attributes, attribute(key, value))
+	}
+	s.mu.Unlock()
+	return encoded
+}
+
+// attribute encodes one attribute as an OTLP AnyValue
+func attribute(key string, value any) otlpAttribute {
+	var encoded map[string]any
+	switch v := value.(type) {
+	case string:
+		encoded = map[string]any{"stringValue": v}
+	case bool:
+		encoded = map[string]any{"boolValue": v}
+	case int:
+		encoded = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		encoded = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		encoded = map[string]any{"doubleValue": v}
+	default:
+		encoded = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpAttribute{Key: key, Value: encoded}
+}
@@ -0,0 +1,229 @@
+// Package tracing records request spans across the handlers, the embedding
+// service, Qdrant and the LLM provider and exports them to an OpenTelemetry
+// collector, such as Jaeger or Tempo, over OTLP/HTTP. Trace context crosses
+// process boundaries in the W3C traceparent header, so spans recorded by
+// the embedding service or a proxy join the same trace.
+//
+// Tracing is off until Setup finds OTEL_EXPORTER_OTLP_ENDPOINT; until then
+// Start returns a nil *Span, whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/middleware"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Kind is the OTLP span kind
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// TraceparentHeader carries the trace context between services
+const TraceparentHeader = "traceparent"
+
+// spanContext identifies a span within its trace
+type spanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// Span is one timed operation of a trace
+type Span struct {
+	context  spanContext
+	parentID [8]byte
+	name     string
+	kind     Kind
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	ended bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start begins a span named name as a child of the span in ctx, or of the
+// remote parent Extract found, and returns a context carrying it. Spans of
+// unsampled traces are not recorded, but still propagate their context.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if exporter() == nil {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.context.TraceID, span.context.Sampled, span.parentID = parent.context.TraceID, parent.context.Sampled, parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(spanContext); ok {
+		span.context.TraceID, span.context.Sampled, span.parentID = remote.TraceID, remote.Sampled, remote.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = sampled(span.context.TraceID)
+	}
+	rand.Read(span.context.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the current span of ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttr records an attribute of the span: a string, bool, integer or float
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+}
+
+// End finishes the span, marking it failed when err is not nil, and queues
+// it for export. Later calls do nothing.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.mu.Unlock()
+	if !s.context.Sampled {
+		return
+	}
+	exporter().enqueue(finishedSpan{span: s, end: end, err: err})
+}
+
+// traceparent formats the span's context as a traceparent header value
+func (sc spanContext) traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// parseTraceparent reads a version 00 traceparent header value
+func parseTraceparent(value string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || sc.TraceID == [16]byte{} {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// Inject adds the trace context of ctx to the headers of an outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set(TraceparentHeader, span.context.traceparent())
+	}
+}
+
+// Extract returns ctx with the remote parent named by the traceparent
+// header, if any, for Start to continue
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if remote, ok := parseTraceparent(header.Get(TraceparentHeader)); ok {
+		return context.WithValue(ctx, remoteKey{}, remote)
+	}
+	return ctx
+}
+
+// sampled decides whether a new trace is recorded, from its random ID
+func sampled(traceID [16]byte) bool {
+	ratio := exporter().ratio
+	if ratio >= 1 {
+		return true
+	}
+	var n uint64
+	for _, b := range traceID[8:] {
+		n = n<<8 | uint64(b)
+	}
+	return float64(n) < ratio*math.MaxUint64
+}
+
+// Middleware starts a server span for each request, continuing the caller's
+// trace. Use it on the mux router (router.Use) so spans are named after the
+// matched route template.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exporter() == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		ctx, span := Start(Extract(r.Context(), r.Header), r.Method+" "+route, KindServer)
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		rec := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttr("http.response.status_code", rec.Status)
+		var err error
+		if rec.Status >= http.StatusInternalServerError {
+			err = fmt.Errorf("status %d", rec.Status)
+		}
+		span.End(err)
+	})
+}
+
+// UnaryClientInterceptor records a client span for each gRPC call, such as
+// a Qdrant search, and passes the trace context on in the call metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if exporter() == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, span := Start(ctx, strings.TrimPrefix(method, "/"), KindClient)
+		span.SetAttr("rpc.system", "grpc")
+		span.SetAttr("rpc.method", method)
+		if collection, ok := req.(interface{ GetCollectionName() string }); ok {
+			span.SetAttr("db.collection.name", collection.GetCollectionName())
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, TraceparentHeader, span.context.traceparent())
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		span.End(err)
+		return err
+	}
+}
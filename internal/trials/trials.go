@@ -0,0 +1,139 @@
+package trials
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"MedAtlasAIServer/internal/ai"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/config"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/internal/payload"
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Collection holds ClinicalTrials.gov records. Points are keyed by
+// data.PointID of the NCT number and carry a StudyPayload.
+const Collection = "clinical_trials"
+
+// StudyPayload is the payload of a Collection point
+type StudyPayload struct {
+	NCTID      string   `payload:"nct_id"`
+	Title      string   `payload:"title"`
+	Status     string   `payload:"status"`
+	Phase      string   `payload:"phase"`
+	Enrollment int      `payload:"enrollment"`
+	Conditions []string `payload:"conditions"`
+}
+
+// maxLinkedArticles bounds the articles returned for one trial
+const maxLinkedArticles = 100
+
+// Points is the part of qdrant.PointsClient trial linking needs
+type Points interface {
+	Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error)
+	Scroll(ctx context.Context, in *qdrant.ScrollPoints, opts ...grpc.CallOption) (*qdrant.ScrollResponse, error)
+}
+
+// Trial is a registry record. Indexed is false when the trial is cited by an
+// article but not (yet) in the trials collection; URL is always set.
+type Trial struct {
+	NCTID      string   `json:"nct_id"`
+	URL        string   `json:"url"`
+	Indexed    bool     `json:"indexed"`
+	Title      string   `json:"title,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// RegistryURL returns the public ClinicalTrials.gov page for nctID
+func RegistryURL(nctID string) string {
+	return "https://clinicaltrials.gov/study/" + nctID
+}
+
+// NormalizeID validates nctID and returns it in canonical NCT########-form
+func NormalizeID(nctID string) (string, error) {
+	ids := data.ExtractNCTIDs(nctID)
+	if len(ids) != 1 || strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(nctID), " ", "")) != ids[0] {
+		return "", fmt.Errorf("%w: %q is not an NCT number", apperrors.ErrInvalidInput, nctID)
+	}
+	return ids[0], nil
+}
+
+// Get loads the trials for nctIDs, in order. Trials missing from the
+// collection are returned with Indexed false.
+func Get(ctx context.Context, points Points, nctIDs []string) ([]Trial, error) {
+	if len(nctIDs) == 0 {
+		return []Trial{}, nil
+	}
+	pointIDs := make([]*qdrant.PointId, len(nctIDs))
+	for i, id := range nctIDs {
+		pointIDs[i] = &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: data.PointID(id)}}
+	}
+	resp, err := points.Get(ctx, &qdrant.GetPoints{
+		CollectionName: Collection,
+		Ids:            pointIDs,
+		WithPayload:    &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+
+	byPoint := make(map[uint64]StudyPayload, len(resp.GetResult()))
+	for _, point := range resp.GetResult() {
+		var study StudyPayload
+		payload.Decode(point.Payload, &study)
+		byPoint[point.GetId().GetNum()] = study
+	}
+	result := make([]Trial, len(nctIDs))
+	for i, id := range nctIDs {
+		trial := Trial{NCTID: id, URL: RegistryURL(id)}
+		if study, ok := byPoint[data.PointID(id)]; ok {
+			trial.Indexed = true
+			trial.Title = study.Title
+			trial.Status = study.Status
+			trial.Conditions = study.Conditions
+		}
+		result[i] = trial
+	}
+	return result, nil
+}
+
+// ForArticle returns the trials an indexed article cites
+func ForArticle(ctx context.Context, points Points, articleID string) ([]Trial, error) {
+	article, err := ai.GetArticle(ctx, points, articleID)
+	if err != nil {
+		return nil, err
+	}
+	nctIDs := article.NCTIDs
+	if len(nctIDs) == 0 {
+		// Points indexed before nct_ids existed still have the abstract
+		nctIDs = data.ExtractNCTIDs(article.Title + " " + article.Abstract)
+	}
+	return Get(ctx, points, nctIDs)
+}
+
+// Articles returns the indexed articles that cite nctID
+func Articles(ctx context.Context, points Points, nctID string) ([]*models.MedicalArticle, error) {
+	limit := uint32(maxLinkedArticles)
+	resp, err := points.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: config.ArticleCollection(),
+		Filter: &qdrant.Filter{Must: []*qdrant.Condition{
+			qdrant.NewMatchKeyword("nct_ids", nctID),
+		}},
+		Limit:       &limit,
+		WithPayload: &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperrors.ErrSearchUnavailable, err)
+	}
+	articles := make([]*models.MedicalArticle, 0, len(resp.GetResult()))
+	for _, point := range resp.GetResult() {
+		articles = append(articles, ai.ArticleFromPayload(point.Payload))
+	}
+	return articles, nil
+}
@@ -0,0 +1,64 @@
+package warmup
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Step is one piece of work that makes the first real request fast, e.g.
+// opening the embedding connection or loading a dictionary
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Retry settings: dependencies started alongside the server (the embedding
+// service loading its model, Qdrant opening the collection) may need a while
+const (
+	DefaultTimeout = 2 * time.Minute
+	retryDelay     = 2 * time.Second
+)
+
+// Gate records whether warm-up has finished. Readiness checks consult it
+// so the server is not sent traffic while still cold.
+type Gate struct {
+	done atomic.Bool
+}
+
+// Done reports whether warm-up has finished
+func (g *Gate) Done() bool {
+	return g.done.Load()
+}
+
+// Run executes steps in order, retrying each until it succeeds or timeout
+// elapses, then opens the gate. A step that never succeeds is logged and
+// skipped: readiness then depends on the live dependency checks alone.
+func (g *Gate) Run(ctx context.Context, timeout time.Duration, steps ...Step) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, step := range steps {
+		stepStart := time.Now()
+		for attempt := 1; ; attempt++ {
+			err := step.Run(ctx)
+			if err == nil {
+				log.Printf("🔥 Warm-up %s done in %v", step.Name, time.Since(stepStart))
+				break
+			}
+			if ctx.Err() != nil {
+				log.Printf("⚠️  Warm-up %s gave up after %d attempts: %v", step.Name, attempt, err)
+				break
+			}
+			log.Printf("⏳ Warm-up %s failed (attempt %d), retrying: %v", step.Name, attempt, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+	g.done.Store(true)
+	log.Printf("✅ Warm-up finished in %v", time.Since(start))
+}
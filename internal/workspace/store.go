@@ -0,0 +1,443 @@
+package workspace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/clock"
+	"MedAtlasAIServer/internal/ids"
+	"MedAtlasAIServer/internal/jsonfile"
+)
+
+// DefaultPath is where workspaces are kept when WORKSPACES_FILE is unset
+const DefaultPath = "data/workspaces.json"
+
+// Role is a member's permission level. Each role includes the ones below it.
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // read bookmarks and saved searches
+	RoleEditor Role = "editor" // add and remove shared items
+	RoleOwner  Role = "owner"  // manage members and delete the workspace
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleEditor:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if role.rank() == 0 {
+		return "", fmt.Errorf("%w: unknown role %q (expected owner, editor or viewer)", apperrors.ErrInvalidInput, s)
+	}
+	return role, nil
+}
+
+// Bookmark is an article shared with the workspace
+type Bookmark struct {
+	ArticleID string    `json:"article_id"`
+	Note      string    `json:"note,omitempty"`
+	AddedBy   string    `json:"added_by"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// Workspace groups users who share research material
+type Workspace struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Members        map[string]Role `json:"members"`
+	Bookmarks      []Bookmark      `json:"bookmarks"`
+	SavedSearchIDs []string        `json:"saved_search_ids"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func (ws *Workspace) clone() *Workspace {
+	copied := *ws
+	copied.Members = make(map[string]Role, len(ws.Members))
+	for user, role := range ws.Members {
+		copied.Members[user] = role
+	}
+	copied.Bookmarks = append([]Bookmark(nil), ws.Bookmarks...)
+	copied.SavedSearchIDs = append([]string(nil), ws.SavedSearchIDs...)
+	return &copied
+}
+
+func (ws *Workspace) owners() int {
+	n := 0
+	for _, role := range ws.Members {
+		if role == RoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// Store keeps workspaces in memory and persists them to a JSON file.
+// Every method takes the acting user and enforces their role.
+// It is safe for concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	workspaces map[string]*Workspace
+	Clock      clock.Clock
+	IDs        ids.Generator
+}
+
+// NewStore loads path if it exists. An empty path keeps workspaces in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:       path,
+		workspaces: make(map[string]*Workspace),
+		Clock:      clock.System,
+		IDs:        ids.UUIDGenerator{Prefix: "ws_"},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	var workspaces []*Workspace
+	if _, err := jsonfile.Read(path, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to load workspaces: %w", err)
+	}
+	for _, ws := range workspaces {
+		s.workspaces[ws.ID] = ws
+	}
+	return s, nil
+}
+
+// Create makes a new workspace owned by userID
+func (s *Store) Create(userID, name string) (*Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", apperrors.ErrInvalidInput)
+	}
+
+	ws := &Workspace{
+		ID:        s.IDs.New(),
+		Name:      name,
+		Members:   map[string]Role{userID: RoleOwner},
+		CreatedAt: s.Clock.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workspaces[ws.ID] = ws
+	if err := s.persist(); err != nil {
+		delete(s.workspaces, ws.ID)
+		return nil, err
+	}
+	return ws.clone(), nil
+}
+
+// Get returns a workspace the user is a member of
+func (s *Store) Get(userID, id string) (*Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ws, err := s.authorize(userID, id, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return ws.clone(), nil
+}
+
+// ListForUser returns the user's workspaces sorted by name
+func (s *Store) ListForUser(userID string) []*Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Workspace
+	for _, ws := range s.workspaces {
+		if _, ok := ws.Members[userID]; ok {
+			result = append(result, ws.clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Delete removes a workspace; only owners may do this
+func (s *Store) Delete(userID, id string) error {
+	return s.update(userID, id, RoleOwner, func(ws *Workspace) error {
+		delete(s.workspaces, id)
+		return nil
+	})
+}
+
+// SetMember adds a member or changes their role; only owners may do this
+func (s *Store) SetMember(userID, id, memberID string, role Role) (*Workspace, error) {
+	if memberID == "" {
+		return nil, fmt.Errorf("%w: member ID is required", apperrors.ErrInvalidInput)
+	}
+	return s.updated(userID, id, RoleOwner, func(ws *Workspace) error {
+		if ws.Members[memberID] == RoleOwner && role != RoleOwner && ws.owners() == 1 {
+			return fmt.Errorf("%w: a workspace needs at least one owner", apperrors.ErrInvalidInput)
+		}
+		ws.Members[memberID] = role
+		return nil
+	})
+}
+
+// RemoveMember removes a member. Owners may remove anyone; members may remove themselves.
+func (s *Store) RemoveMember(userID, id, memberID string) (*Workspace, error) {
+	need := RoleOwner
+	if memberID == userID {
+		need = RoleViewer
+	}
+	return s.updated(userID, id, need, func(ws *Workspace) error {
+		if _, ok := ws.Members[memberID]; !ok {
+			return fmt.Errorf("%w: %s is not a member", apperrors.ErrNotFound, memberID)
+		}
+		if ws.Members[memberID] == RoleOwner && ws.owners() == 1 {
+			return fmt.Errorf("%w: a workspace needs at least one owner", apperrors.ErrInvalidInput)
+		}
+		delete(ws.Members, memberID)
+		return nil
+	})
+}
+
+// AddBookmark shares an article with the workspace
+func (s *Store) AddBookmark(userID, id, articleID, note string) (*Workspace, error) {
+	if articleID == "" {
+		return nil, fmt.Errorf("%w: article_id is required", apperrors.ErrInvalidInput)
+	}
+	return s.updated(userID, id, RoleEditor, func(ws *Workspace) error {
+		for i, b := range ws.Bookmarks {
+			if b.ArticleID == articleID {
+				ws.Bookmarks[i].Note = note
+				return nil
+			}
+		}
+		ws.Bookmarks = append(ws.Bookmarks, Bookmark{
+			ArticleID: articleID,
+			Note:      note,
+			AddedBy:   userID,
+			AddedAt:   s.Clock.Now(),
+		})
+		return nil
+	})
+}
+
+// RemoveBookmark unshares an article
+func (s *Store) RemoveBookmark(userID, id, articleID string) (*Workspace, error) {
+	return s.updated(userID, id, RoleEditor, func(ws *Workspace) error {
+		for i, b := range ws.Bookmarks {
+			if b.ArticleID == articleID {
+				ws.Bookmarks = append(ws.Bookmarks[:i], ws.Bookmarks[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: article %s is not bookmarked", apperrors.ErrNotFound, articleID)
+	})
+}
+
+// AddSavedSearch shares a saved search with the workspace
+func (s *Store) AddSavedSearch(userID, id, searchID string) (*Workspace, error) {
+	return s.updated(userID, id, RoleEditor, func(ws *Workspace) error {
+		for _, existing := range ws.SavedSearchIDs {
+			if existing == searchID {
+				return nil
+			}
+		}
+		ws.SavedSearchIDs = append(ws.SavedSearchIDs, searchID)
+		return nil
+	})
+}
+
+// RemoveSavedSearch unshares a saved search
+func (s *Store) RemoveSavedSearch(userID, id, searchID string) (*Workspace, error) {
+	return s.updated(userID, id, RoleEditor, func(ws *Workspace) error {
+		for i, existing := range ws.SavedSearchIDs {
+			if existing == searchID {
+				ws.SavedSearchIDs = append(ws.SavedSearchIDs[:i], ws.SavedSearchIDs[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: saved search %s is not shared", apperrors.ErrNotFound, searchID)
+	})
+}
+
+// DeleteUser removes a user from every workspace along with the bookmarks
+// they added. Workspaces left without members are deleted; if the user was
+// the only owner, the remaining member with the highest role is promoted
+// (ties go to the lowest user ID).
+func (s *Store) DeleteUser(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup := make(map[string]*Workspace, len(s.workspaces))
+	changed := 0
+	for id, ws := range s.workspaces {
+		_, member := ws.Members[userID]
+		added := false
+		for _, b := range ws.Bookmarks {
+			added = added || b.AddedBy == userID
+		}
+		if !member && !added {
+			continue
+		}
+		backup[id] = ws.clone()
+		changed++
+
+		kept := ws.Bookmarks[:0]
+		for _, b := range ws.Bookmarks {
+			if b.AddedBy != userID {
+				kept = append(kept, b)
+			}
+		}
+		ws.Bookmarks = kept
+
+		wasOwner := ws.Members[userID] == RoleOwner
+		delete(ws.Members, userID)
+		if len(ws.Members) == 0 {
+			delete(s.workspaces, id)
+			continue
+		}
+		if wasOwner && ws.owners() == 0 {
+			promoteSuccessor(ws)
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	if err := s.persist(); err != nil {
+		for id, ws := range backup {
+			s.workspaces[id] = ws
+		}
+		return 0, err
+	}
+	return changed, nil
+}
+
+// promoteSuccessor makes the highest-ranked remaining member an owner,
+// breaking ties by user ID so the choice is deterministic
+func promoteSuccessor(ws *Workspace) {
+	successor := ""
+	for member, role := range ws.Members {
+		if successor == "" || role.rank() > ws.Members[successor].rank() ||
+			(role.rank() == ws.Members[successor].rank() && member < successor) {
+			successor = member
+		}
+	}
+	ws.Members[successor] = RoleOwner
+}
+
+// ForgetSavedSearches drops references to deleted saved searches
+func (s *Store) ForgetSavedSearches(searchIDs []string) error {
+	if len(searchIDs) == 0 {
+		return nil
+	}
+	forget := make(map[string]bool, len(searchIDs))
+	for _, id := range searchIDs {
+		forget[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup := make(map[string][]string)
+	for id, ws := range s.workspaces {
+		kept := make([]string, 0, len(ws.SavedSearchIDs))
+		for _, searchID := range ws.SavedSearchIDs {
+			if !forget[searchID] {
+				kept = append(kept, searchID)
+			}
+		}
+		if len(kept) != len(ws.SavedSearchIDs) {
+			backup[id] = ws.SavedSearchIDs
+			ws.SavedSearchIDs = kept
+		}
+	}
+	if len(backup) == 0 {
+		return nil
+	}
+	if err := s.persist(); err != nil {
+		for id, searchIDs := range backup {
+			s.workspaces[id].SavedSearchIDs = searchIDs
+		}
+		return err
+	}
+	return nil
+}
+
+// updated runs update and returns a copy of the modified workspace
+func (s *Store) updated(userID, id string, need Role, fn func(ws *Workspace) error) (*Workspace, error) {
+	var result *Workspace
+	err := s.update(userID, id, need, func(ws *Workspace) error {
+		if err := fn(ws); err != nil {
+			return err
+		}
+		result = ws.clone()
+		return nil
+	})
+	return result, err
+}
+
+// update applies fn to a workspace after checking the user's role, and rolls
+// back if fn fails or the change cannot be persisted
+func (s *Store) update(userID, id string, need Role, fn func(ws *Workspace) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, err := s.authorize(userID, id, need)
+	if err != nil {
+		return err
+	}
+	previous := ws.clone()
+	if err := fn(ws); err != nil {
+		*ws = *previous
+		return err
+	}
+	if err := s.persist(); err != nil {
+		*ws = *previous
+		s.workspaces[id] = ws
+		return err
+	}
+	return nil
+}
+
+// authorize returns the workspace if userID holds at least role need.
+// Non-members get ErrNotFound so workspace IDs cannot be probed.
+// Callers must hold s.mu.
+func (s *Store) authorize(userID, id string, need Role) (*Workspace, error) {
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: workspace %s", apperrors.ErrNotFound, id)
+	}
+	role, member := ws.Members[userID]
+	if !member {
+		return nil, fmt.Errorf("%w: workspace %s", apperrors.ErrNotFound, id)
+	}
+	if role.rank() < need.rank() {
+		return nil, fmt.Errorf("%w: %s role required", apperrors.ErrForbidden, need)
+	}
+	return ws, nil
+}
+
+// persist writes all workspaces to disk. Callers must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	workspaces := make([]*Workspace, 0, len(s.workspaces))
+	for _, ws := range s.workspaces {
+		workspaces = append(workspaces, ws)
+	}
+	return jsonfile.WriteAtomic(s.path, workspaces)
+}
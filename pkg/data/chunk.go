@@ -0,0 +1,59 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChunksCollection holds one point per passage of long abstracts, whose
+// article point embeds only as much of the text as the model reads
+const ChunksCollection = "article_chunks"
+
+// Chunk sizes in words. The embedding model reads about 256 tokens, some
+// 180 words of biomedical text; the overlap keeps a finding that straddles
+// a boundary whole in one of the passages.
+const (
+	DefaultChunkWords   = 150
+	DefaultChunkOverlap = 30
+)
+
+// ChunkText splits text into passages of at most size words, each starting
+// overlap words before the previous one ended. Texts of size words or
+// fewer are one passage. Passages end at a sentence end when one falls in
+// their last third, so they read as whole statements.
+func ChunkText(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if size <= 0 || len(words) <= size {
+		return []string{strings.Join(words, " ")}
+	}
+	overlap = max(0, min(overlap, size/2))
+
+	var chunks []string
+	for start := 0; start < len(words); {
+		end := min(start+size, len(words))
+		if end < len(words) {
+			for cut := end; cut > start+size*2/3; cut-- {
+				if endsSentence(words[cut-1]) {
+					end = cut
+					break
+				}
+			}
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return chunks
+}
+
+func endsSentence(word string) bool {
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!")
+}
+
+// ChunkPointID returns the ChunksCollection point ID of a passage of an
+// article
+func ChunkPointID(articleID string, index int) uint64 {
+	return PointID(fmt.Sprintf("chunk-%d:%s", index, articleID))
+}
@@ -0,0 +1,407 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"MedAtlasAIServer/internal/models"
+)
+
+// Supported citation styles
+const (
+	CitationAMA    = "ama"
+	CitationAPA    = "apa"
+	CitationBibTeX = "bibtex"
+	CitationRIS    = "ris"
+	CitationCSL    = "csl-json" // Citation Style Language JSON, read by Zotero, Mendeley and pandoc
+)
+
+// CitationStyles lists the styles FormatCitation accepts
+var CitationStyles = []string{CitationAMA, CitationAPA, CitationBibTeX, CitationRIS, CitationCSL}
+
+// ParseCitationStyle validates a style name, defaulting to AMA
+func ParseCitationStyle(style string) (string, error) {
+	style = strings.ToLower(strings.TrimSpace(style))
+	if style == "" {
+		return CitationAMA, nil
+	}
+	for _, s := range CitationStyles {
+		if s == style {
+			return style, nil
+		}
+	}
+	return "", fmt.Errorf("unknown citation style %q (expected one of %s)", style, strings.Join(CitationStyles, ", "))
+}
+
+// CitationFileInfo returns the MIME type and file extension for exporting a style
+func CitationFileInfo(style string) (contentType, extension string) {
+	switch style {
+	case CitationBibTeX:
+		return "application/x-bibtex; charset=utf-8", "bib"
+	case CitationRIS:
+		return "application/x-research-info-systems; charset=utf-8", "ris"
+	case CitationCSL:
+		return "application/vnd.citationstyles.csl+json", "json"
+	default:
+		return "text/plain; charset=utf-8", "txt"
+	}
+}
+
+// FormatCitation renders one article in the given style
+func FormatCitation(article *models.MedicalArticle, style string) (string, error) {
+	switch style {
+	case CitationAMA:
+		return formatAMA(article), nil
+	case CitationAPA:
+		return formatAPA(article), nil
+	case CitationBibTeX:
+		return formatBibTeX(article), nil
+	case CitationRIS:
+		return formatRIS(article), nil
+	case CitationCSL:
+		raw, err := json.MarshalIndent(cslItem(article), "", "  ")
+		return string(raw), err
+	default:
+		return "", fmt.Errorf("unknown citation style %q", style)
+	}
+}
+
+// FormatCitations renders a result set as a single document, e.g. a .bib or .ris file
+func FormatCitations(articles []*models.MedicalArticle, style string) (string, error) {
+	if style == CitationCSL {
+		// CSL-JSON files hold a single array of items
+		items := make([]CSLItem, len(articles))
+		for i, article := range articles {
+			items[i] = cslItem(article)
+		}
+		raw, err := json.MarshalIndent(items, "", "  ")
+		return string(raw), err
+	}
+
+	separator := "\n"
+	if style == CitationBibTeX || style == CitationRIS {
+		separator = "" // entries already end with a blank line
+	}
+
+	var builder strings.Builder
+	for _, article := range articles {
+		citation, err := FormatCitation(article, style)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(citation)
+		builder.WriteString(separator)
+	}
+	return builder.String(), nil
+}
+
+// formatAMA follows AMA 11th edition: up to six authors, otherwise three and "et al"
+func formatAMA(article *models.MedicalArticle) string {
+	var parts []string
+
+	authors := citedAuthors(article.Authors)
+	if len(authors) > 0 {
+		shown := authors
+		if len(authors) > 6 {
+			shown = authors[:3]
+		}
+		names := make([]string, len(shown))
+		for i, author := range shown {
+			names[i] = strings.TrimSpace(authorLastName(author) + " " + authorInitials(author, ""))
+		}
+		list := strings.Join(names, ", ")
+		if len(authors) > 6 {
+			list += ", et al"
+		}
+		parts = append(parts, list+".")
+	}
+
+	if title := strings.TrimSpace(article.Title); title != "" {
+		parts = append(parts, withPeriod(title))
+	}
+	journal := article.JournalAbbr
+	if journal == "" {
+		journal = article.Journal
+	}
+	if journal != "" {
+		journal = strings.ReplaceAll(journal, ".", "")
+		if !article.PublishedDate.IsZero() {
+			parts = append(parts, fmt.Sprintf("%s. %d.", journal, article.PublishedDate.Year()))
+		} else {
+			parts = append(parts, journal+".")
+		}
+	} else if !article.PublishedDate.IsZero() {
+		parts = append(parts, fmt.Sprintf("%d.", article.PublishedDate.Year()))
+	}
+	if article.DOI != "" {
+		parts = append(parts, "doi:"+article.DOI)
+	}
+	if pmid := citationPMID(article); pmid != "" {
+		parts = append(parts, "PMID: "+pmid)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatAPA follows APA 7th edition: up to 20 authors, otherwise the first 19,
+// an ellipsis and the last author
+func formatAPA(article *models.MedicalArticle) string {
+	var builder strings.Builder
+
+	authors := citedAuthors(article.Authors)
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = authorLastName(author)
+		if initials := authorInitials(author, ". "); initials != "" {
+			names[i] += ", " + initials + "."
+		}
+	}
+	switch {
+	case len(names) == 1:
+		builder.WriteString(names[0])
+	case len(names) > 20:
+		builder.WriteString(strings.Join(names[:19], ", "))
+		builder.WriteString(", . . . ")
+		builder.WriteString(names[len(names)-1])
+	case len(names) > 1:
+		builder.WriteString(strings.Join(names[:len(names)-1], ", "))
+		builder.WriteString(", & ")
+		builder.WriteString(names[len(names)-1])
+	}
+	if builder.Len() > 0 {
+		builder.WriteString(" ")
+	}
+
+	if !article.PublishedDate.IsZero() {
+		builder.WriteString(fmt.Sprintf("(%d). ", article.PublishedDate.Year()))
+	} else {
+		builder.WriteString("(n.d.). ")
+	}
+	if title := strings.TrimSpace(article.Title); title != "" {
+		builder.WriteString(withPeriod(title))
+	}
+	if article.Journal != "" {
+		builder.WriteString(" " + withPeriod(article.Journal))
+	}
+	if article.DOI != "" {
+		builder.WriteString(" https://doi.org/" + article.DOI)
+	}
+	return builder.String()
+}
+
+func formatBibTeX(article *models.MedicalArticle) string {
+	authors := citedAuthors(article.Authors)
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = authorLastName(author)
+		if given := authorGivenName(author); given != "" {
+			names[i] += ", " + given
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString("@article{" + bibTeXKey(article, authors) + ",\n")
+	writeField := func(name, value string) {
+		if value != "" {
+			builder.WriteString(fmt.Sprintf("  %s = {%s},\n", name, escapeBibTeX(value)))
+		}
+	}
+	writeField("author", strings.Join(names, " and "))
+	writeField("title", article.Title)
+	writeField("journal", article.Journal)
+	if !article.PublishedDate.IsZero() {
+		writeField("year", fmt.Sprintf("%d", article.PublishedDate.Year()))
+	}
+	writeField("doi", article.DOI)
+	writeField("pmid", citationPMID(article))
+	builder.WriteString("}\n\n")
+	return builder.String()
+}
+
+func formatRIS(article *models.MedicalArticle) string {
+	var builder strings.Builder
+	writeTag := func(tag, value string) {
+		// RIS values must stay on one line
+		if value = strings.Join(strings.Fields(value), " "); value != "" {
+			builder.WriteString(tag + "  - " + value + "\r\n")
+		}
+	}
+
+	writeTag("TY", "JOUR")
+	for _, author := range citedAuthors(article.Authors) {
+		name := authorLastName(author)
+		if given := authorGivenName(author); given != "" {
+			name += ", " + given
+		}
+		writeTag("AU", name)
+	}
+	writeTag("TI", article.Title)
+	writeTag("T2", article.Journal)
+	writeTag("J2", article.JournalAbbr)
+	if !article.PublishedDate.IsZero() {
+		writeTag("PY", fmt.Sprintf("%d", article.PublishedDate.Year()))
+		writeTag("DA", article.PublishedDate.Format("2006/01/02"))
+	}
+	writeTag("DO", article.DOI)
+	if pmid := citationPMID(article); pmid != "" {
+		writeTag("AN", pmid)
+		writeTag("UR", "https://pubmed.ncbi.nlm.nih.gov/"+pmid+"/")
+	}
+	writeTag("AB", article.Abstract)
+	builder.WriteString("ER  - \r\n\r\n")
+	return builder.String()
+}
+
+// CSLName is a CSL-JSON personal name
+type CSLName struct {
+	Family string `json:"family"`
+	Given  string `json:"given,omitempty"`
+}
+
+// CSLDate is a CSL-JSON date, e.g. {"date-parts": [[2020, 3, 1]]}
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// CSLItem is one CSL-JSON bibliography entry
+type CSLItem struct {
+	ID                  string    `json:"id"`
+	Type                string    `json:"type"`
+	Title               string    `json:"title,omitempty"`
+	ContainerTitle      string    `json:"container-title,omitempty"`
+	ContainerTitleShort string    `json:"container-title-short,omitempty"`
+	Author              []CSLName `json:"author,omitempty"`
+	Issued              *CSLDate  `json:"issued,omitempty"`
+	DOI                 string    `json:"DOI,omitempty"`
+	PMID                string    `json:"PMID,omitempty"`
+	URL                 string    `json:"URL,omitempty"`
+	Abstract            string    `json:"abstract,omitempty"`
+}
+
+func cslItem(article *models.MedicalArticle) CSLItem {
+	item := CSLItem{
+		ID:                  article.ID,
+		Type:                "article-journal",
+		Title:               article.Title,
+		ContainerTitle:      article.Journal,
+		ContainerTitleShort: article.JournalAbbr,
+		DOI:                 article.DOI,
+		PMID:                citationPMID(article),
+		Abstract:            article.Abstract,
+	}
+	for _, author := range citedAuthors(article.Authors) {
+		item.Author = append(item.Author, CSLName{Family: authorLastName(author), Given: authorGivenName(author)})
+	}
+	if !article.PublishedDate.IsZero() {
+		date := article.PublishedDate
+		item.Issued = &CSLDate{DateParts: [][]int{{date.Year(), int(date.Month()), date.Day()}}}
+	}
+	if item.PMID != "" {
+		item.URL = "https://pubmed.ncbi.nlm.nih.gov/" + item.PMID + "/"
+	}
+	return item
+}
+
+// citedAuthors drops placeholder entries that carry no name
+func citedAuthors(authors []models.Author) []models.Author {
+	cited := make([]models.Author, 0, len(authors))
+	for _, author := range authors {
+		if authorLastName(author) != "" {
+			cited = append(cited, author)
+		}
+	}
+	return cited
+}
+
+func authorLastName(author models.Author) string {
+	if author.LastName != "" {
+		return author.LastName
+	}
+	fields := strings.Fields(author.FullName)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+func authorGivenName(author models.Author) string {
+	if author.ForeName != "" {
+		return author.ForeName
+	}
+	if author.LastName == "" {
+		fields := strings.Fields(author.FullName)
+		if len(fields) > 1 {
+			return strings.Join(fields[:len(fields)-1], " ")
+		}
+	}
+	return author.Initials
+}
+
+// authorInitials returns the author's initials joined by sep, e.g. "JA" or "J. A"
+func authorInitials(author models.Author, sep string) string {
+	var letters []string
+	if author.Initials != "" {
+		for _, r := range author.Initials {
+			letters = append(letters, string(r))
+		}
+	} else {
+		for _, word := range strings.FieldsFunc(authorGivenName(author), func(r rune) bool { return r == ' ' || r == '-' || r == '.' }) {
+			letters = append(letters, strings.ToUpper(string([]rune(word)[0])))
+		}
+	}
+	return strings.Join(letters, sep)
+}
+
+// citationPMID returns the article ID when it is a PubMed identifier
+func citationPMID(article *models.MedicalArticle) string {
+	if article.ID == "" || strings.Trim(article.ID, "0123456789") != "" {
+		return ""
+	}
+	return article.ID
+}
+
+func bibTeXKey(article *models.MedicalArticle, authors []models.Author) string {
+	var key strings.Builder
+	if len(authors) > 0 {
+		for _, r := range authorLastName(authors[0]) {
+			if r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				key.WriteRune(r)
+			}
+		}
+	}
+	if !article.PublishedDate.IsZero() {
+		key.WriteString(fmt.Sprintf("%d", article.PublishedDate.Year()))
+	}
+	// The ID keeps keys unique when one author has several papers in a year
+	for _, r := range article.ID {
+		if r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			key.WriteRune(r)
+		}
+	}
+	if key.Len() == 0 {
+		return "article"
+	}
+	return key.String()
+}
+
+var bibTeXEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"&", `\&`,
+	"%", `\%`,
+	"$", `\$`,
+	"#", `\#`,
+	"_", `\_`,
+)
+
+func escapeBibTeX(value string) string {
+	return bibTeXEscaper.Replace(value)
+}
+
+func withPeriod(s string) string {
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
@@ -0,0 +1,254 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/models"
+)
+
+// ClinicalTrialsSource is the source recorded on ClinicalTrials.gov studies
+const ClinicalTrialsSource = "clinicaltrials"
+
+// ClinicalTrialsJournal stands in for the journal of registry records, so
+// citations and filters name where they came from
+const ClinicalTrialsJournal = "ClinicalTrials.gov"
+
+// ClinicalTrialsClient pages through studies from the ClinicalTrials.gov
+// API v2 (https://clinicaltrials.gov/data-api/api)
+type ClinicalTrialsClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Delay      time.Duration // between page requests
+}
+
+func NewClinicalTrialsClient() *ClinicalTrialsClient {
+	return &ClinicalTrialsClient{
+		BaseURL:    "https://clinicaltrials.gov/api/v2/studies",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Delay:      500 * time.Millisecond,
+	}
+}
+
+// ctgovPageSize is the most studies the API returns per page
+const ctgovPageSize = 100
+
+// ctgovStudies is one page of the /studies response, reduced to the
+// modules the normalizer reads
+type ctgovStudies struct {
+	Studies       []ctgovStudy `json:"studies"`
+	NextPageToken string       `json:"nextPageToken"`
+}
+
+type ctgovDate struct {
+	Date string `json:"date"` // YYYY-MM-DD or YYYY-MM
+}
+
+type ctgovStudy struct {
+	ProtocolSection struct {
+		IdentificationModule struct {
+			NCTID         string `json:"nctId"`
+			BriefTitle    string `json:"briefTitle"`
+			OfficialTitle string `json:"officialTitle"`
+		} `json:"identificationModule"`
+		StatusModule struct {
+			OverallStatus            string    `json:"overallStatus"`
+			StudyFirstPostDateStruct ctgovDate `json:"studyFirstPostDateStruct"`
+			StartDateStruct          ctgovDate `json:"startDateStruct"`
+		} `json:"statusModule"`
+		SponsorCollaboratorsModule struct {
+			LeadSponsor struct {
+				Name string `json:"name"`
+			} `json:"leadSponsor"`
+		} `json:"sponsorCollaboratorsModule"`
+		DescriptionModule struct {
+			BriefSummary        string `json:"briefSummary"`
+			DetailedDescription string `json:"detailedDescription"`
+		} `json:"descriptionModule"`
+		ConditionsModule struct {
+			Conditions []string `json:"conditions"`
+			Keywords   []string `json:"keywords"`
+		} `json:"conditionsModule"`
+		DesignModule struct {
+			StudyType      string   `json:"studyType"`
+			Phases         []string `json:"phases"`
+			EnrollmentInfo struct {
+				Count int `json:"count"`
+			} `json:"enrollmentInfo"`
+		} `json:"designModule"`
+		ContactsLocationsModule struct {
+			OverallOfficials []struct {
+				Name        string `json:"name"`
+				Affiliation string `json:"affiliation"`
+			} `json:"overallOfficials"`
+			Locations []struct {
+				Country string `json:"country"`
+			} `json:"locations"`
+		} `json:"contactsLocationsModule"`
+	} `json:"protocolSection"`
+	DerivedSection struct {
+		ConditionBrowseModule struct {
+			Meshes []struct {
+				Term string `json:"term"`
+			} `json:"meshes"`
+		} `json:"conditionBrowseModule"`
+	} `json:"derivedSection"`
+}
+
+// SearchStudies returns up to maxResults studies matching term, following
+// the API's page tokens. Studies fetched before a failed page are returned
+// with the error.
+func (c *ClinicalTrialsClient) SearchStudies(term string, maxResults int) ([]models.MedicalArticle, error) {
+	var articles []models.MedicalArticle
+	pageToken := ""
+	for len(articles) < maxResults {
+		if pageToken != "" {
+			time.Sleep(c.Delay)
+		}
+		page, err := c.fetchPage(term, min(maxResults-len(articles), ctgovPageSize), pageToken)
+		if err != nil {
+			return articles, err
+		}
+		for _, study := range page.Studies {
+			articles = append(articles, normalizeStudy(study))
+		}
+		if page.NextPageToken == "" || len(page.Studies) == 0 {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return articles, nil
+}
+
+func (c *ClinicalTrialsClient) fetchPage(term string, pageSize int, pageToken string) (*ctgovStudies, error) {
+	params := url.Values{}
+	params.Set("query.term", term)
+	params.Set("pageSize", fmt.Sprint(pageSize))
+	params.Set("format", "json")
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	resp, err := c.HTTPClient.Get(c.BaseURL + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("ClinicalTrials.gov request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: ClinicalTrials.gov returned %s", apperrors.ErrRateLimited, resp.Status)
+	default:
+		return nil, fmt.Errorf("ClinicalTrials.gov returned %s", resp.Status)
+	}
+
+	var page ctgovStudies
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse ClinicalTrials.gov response: %w", err)
+	}
+	return &page, nil
+}
+
+// normalizeStudy converts a registry record into an article the indexer
+// accepts. The brief summary stands in for the abstract, the first-posted
+// date for the publication date and the lead sponsor for the affiliation.
+// NCTIDs stays empty: it lists the trials an article cites, and a
+// registration citing itself would show up as its own linked article.
+func normalizeStudy(study ctgovStudy) models.MedicalArticle {
+	protocol := study.ProtocolSection
+	id := protocol.IdentificationModule
+
+	title := strings.TrimSpace(id.BriefTitle)
+	if title == "" {
+		title = strings.TrimSpace(id.OfficialTitle)
+	}
+	abstract := strings.TrimSpace(protocol.DescriptionModule.BriefSummary)
+	if abstract == "" {
+		abstract = strings.TrimSpace(protocol.DescriptionModule.DetailedDescription)
+	}
+	published := parseStudyDate(protocol.StatusModule.StudyFirstPostDateStruct.Date)
+	if published.IsZero() {
+		published = parseStudyDate(protocol.StatusModule.StartDateStruct.Date)
+	}
+
+	article := models.MedicalArticle{
+		ID:               id.NCTID,
+		Title:            title,
+		Abstract:         abstract,
+		PublishedDate:    published,
+		Journal:          ClinicalTrialsJournal,
+		JournalAbbr:      ClinicalTrialsJournal,
+		Source:           ClinicalTrialsSource,
+		PublicationTypes: []string{"Clinical Trial Registration"},
+		Affiliation:      protocol.SponsorCollaboratorsModule.LeadSponsor.Name,
+		KeyConcepts:      protocol.ConditionsModule.Keywords,
+		Conditions:       protocol.ConditionsModule.Conditions,
+		Phase:            formatPhases(protocol.DesignModule.Phases),
+		TrialStatus:      protocol.StatusModule.OverallStatus,
+		Enrollment:       protocol.DesignModule.EnrollmentInfo.Count,
+	}
+	if studyType := protocol.DesignModule.StudyType; studyType != "" {
+		article.PublicationTypes = append(article.PublicationTypes, titleCase(studyType)+" Study")
+	}
+	for _, mesh := range study.DerivedSection.ConditionBrowseModule.Meshes {
+		article.MeshHeadings = append(article.MeshHeadings, mesh.Term)
+	}
+	for _, official := range protocol.ContactsLocationsModule.OverallOfficials {
+		article.Authors = append(article.Authors, models.Author{FullName: official.Name})
+		if official.Affiliation != "" {
+			article.Affiliations = append(article.Affiliations, official.Affiliation)
+		}
+	}
+	seenCountries := make(map[string]bool)
+	for _, location := range protocol.ContactsLocationsModule.Locations {
+		if location.Country != "" && !seenCountries[location.Country] {
+			seenCountries[location.Country] = true
+			article.Countries = append(article.Countries, location.Country)
+		}
+	}
+	return article
+}
+
+// parseStudyDate accepts the API's full and month-only dates
+func parseStudyDate(value string) time.Time {
+	for _, layout := range []string{"2006-01-02", "2006-01"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// formatPhases turns the API's phase codes into the usual labels, e.g.
+// ["PHASE2", "PHASE3"] into "Phase 2/Phase 3". Studies without phases
+// ("NA") get an empty phase.
+func formatPhases(phases []string) string {
+	var labels []string
+	for _, phase := range phases {
+		switch {
+		case phase == "NA" || phase == "":
+		case strings.HasPrefix(phase, "EARLY_PHASE"):
+			labels = append(labels, "Early Phase "+strings.TrimPrefix(phase, "EARLY_PHASE"))
+		case strings.HasPrefix(phase, "PHASE"):
+			labels = append(labels, "Phase "+strings.TrimPrefix(phase, "PHASE"))
+		default:
+			labels = append(labels, phase)
+		}
+	}
+	return strings.Join(labels, "/")
+}
+
+// titleCase turns an API enum such as "INTERVENTIONAL" into "Interventional"
+func titleCase(value string) string {
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(value, "_", " ")))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression extensions recognised on data files
+const (
+	ExtGzip = ".gz"
+	ExtZstd = ".zst"
+)
+
+// IsJSONLFile reports whether name is a JSONL data file, plain or compressed
+func IsJSONLFile(name string) bool {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ExtGzip), ExtZstd)
+	return strings.HasSuffix(name, ".jsonl")
+}
+
+// GlobJSONL returns the files matching pattern (e.g. "data/raw/pubmed_*")
+// that are JSONL data files, plain or compressed
+func GlobJSONL(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, match := range matches {
+		if IsJSONLFile(match) {
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+// OpenInput opens a data file for reading, decompressing .gz and .zst files
+func OpenInput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ExtGzip:
+		// Appending collectors write one gzip member per run; the reader
+		// reads them as a single stream
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return &stackedCloser{Reader: reader, closers: []io.Closer{reader, file}}, nil
+	case ExtZstd:
+		// Like gzip, appended zstd frames are read as a single stream
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return &stackedCloser{Reader: decoder, closers: []io.Closer{decoderCloser{decoder}, file}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// OpenOutput opens a data file for appending, creating it if needed, and
+// compresses what is written when path ends in .gz or .zst. Close must be
+// called to flush the compressed stream.
+func OpenOutput(path string) (io.WriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ExtGzip:
+		writer := gzip.NewWriter(file)
+		return &stackedCloser{Writer: writer, closers: []io.Closer{writer, file}}, nil
+	case ExtZstd:
+		encoder, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		return &stackedCloser{Writer: encoder, closers: []io.Closer{encoder, file}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// stackedCloser closes a compression layer and the file beneath it in order
+type stackedCloser struct {
+	io.Reader
+	io.Writer
+	closers []io.Closer
+}
+
+func (s *stackedCloser) Close() error {
+	var first error
+	for _, closer := range s.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// decoderCloser adapts zstd.Decoder, whose Close returns nothing
+type decoderCloser struct {
+	decoder *zstd.Decoder
+}
+
+func (d decoderCloser) Close() error {
+	d.decoder.Close()
+	return nil
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressedRoundTrip(t *testing.T) {
+	for _, ext := range []string{"", ExtGzip, ExtZstd} {
+		t.Run("jsonl"+ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "articles.jsonl"+ext)
+			// Two runs append two compressed streams to the same file
+			for _, line := range []string{"{\"pmid\":\"1\"}\n", "{\"pmid\":\"2\"}\n"} {
+				out, err := OpenOutput(path)
+				if err != nil {
+					t.Fatalf("OpenOutput: %v", err)
+				}
+				if _, err := io.WriteString(out, line); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				if err := out.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			in, err := OpenInput(path)
+			if err != nil {
+				t.Fatalf("OpenInput: %v", err)
+			}
+			got, err := io.ReadAll(in)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if err := in.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if want := "{\"pmid\":\"1\"}\n{\"pmid\":\"2\"}\n"; string(got) != want {
+				t.Errorf("read %q, want %q", got, want)
+			}
+		})
+	}
+}
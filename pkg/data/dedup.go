@@ -0,0 +1,157 @@
+package data
+
+import (
+	"strings"
+
+	"MedAtlasAIServer/internal/models"
+)
+
+// DefaultTitleThreshold is the trigram similarity above which two titles
+// are taken to name the same paper
+const DefaultTitleThreshold = 0.9
+
+// minDedupTitleWords keeps short generic titles ("Editorial", "Reply to
+// the letter") from being matched by title
+const minDedupTitleWords = 4
+
+// NormalizeDOI returns doi in lowercase without resolver prefixes, so
+// "https://doi.org/10.1000/ABC" and "doi:10.1000/abc" compare equal. It
+// returns "" for values that are not DOIs.
+func NormalizeDOI(doi string) string {
+	doi = strings.ToLower(strings.TrimSpace(doi))
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		doi = strings.TrimPrefix(doi, prefix)
+	}
+	doi = strings.TrimRight(strings.TrimSpace(doi), ".,;")
+	if !strings.HasPrefix(doi, "10.") || !strings.Contains(doi, "/") {
+		return ""
+	}
+	return doi
+}
+
+// Deduplicator detects articles already seen under a different ID, such as
+// the same paper fetched from PubMed and from a preprint server. Articles
+// match when their DOIs are equal or, when either has no DOI, their titles
+// are near-identical. It is not safe for concurrent use.
+type Deduplicator struct {
+	// TitleThreshold is the title trigram similarity counted as a match
+	TitleThreshold float64
+
+	byDOI   map[string]string          // normalized DOI -> article ID
+	titles  map[string]dedupTitle      // article ID -> title
+	byWords map[string]map[string]bool // word pair -> IDs of titles containing it
+}
+
+type dedupTitle struct {
+	normalized string
+	doi        string
+}
+
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{
+		TitleThreshold: DefaultTitleThreshold,
+		byDOI:          make(map[string]string),
+		titles:         make(map[string]dedupTitle),
+		byWords:        make(map[string]map[string]bool),
+	}
+}
+
+// Duplicate reports the ID of an earlier article that article duplicates
+// and whether it matched by "doi" or "title". Articles that are not
+// duplicates are remembered and return "", "". An article is never a
+// duplicate of its own ID, so reindexing a file is unaffected.
+func (d *Deduplicator) Duplicate(article models.MedicalArticle) (string, string) {
+	doi := NormalizeDOI(article.DOI)
+	if doi != "" {
+		if id, ok := d.byDOI[doi]; ok && id != article.ID {
+			return id, "doi"
+		}
+	}
+	title := NormalizeTitle(article.Title)
+	if id := d.matchTitle(article.ID, title, doi); id != "" {
+		return id, "title"
+	}
+
+	if doi != "" {
+		d.byDOI[doi] = article.ID
+	}
+	if len(strings.Fields(title)) >= minDedupTitleWords {
+		d.titles[article.ID] = dedupTitle{normalized: title, doi: doi}
+		for _, pair := range wordPairs(title) {
+			if d.byWords[pair] == nil {
+				d.byWords[pair] = make(map[string]bool)
+			}
+			d.byWords[pair][article.ID] = true
+		}
+	}
+	return "", ""
+}
+
+// matchTitle returns the ID of a remembered title near-identical to title.
+// Candidates share at least one pair of content words; titles whose DOIs
+// differ are distinct papers however alike they read.
+func (d *Deduplicator) matchTitle(id, title, doi string) string {
+	if len(strings.Fields(title)) < minDedupTitleWords {
+		return ""
+	}
+	grams := trigrams(title)
+	checked := make(map[string]bool)
+	for _, pair := range wordPairs(title) {
+		for candidate := range d.byWords[pair] {
+			if candidate == id || checked[candidate] {
+				continue
+			}
+			checked[candidate] = true
+			other := d.titles[candidate]
+			if doi != "" && other.doi != "" && doi != other.doi {
+				continue
+			}
+			if jaccard(grams, trigrams(other.normalized)) >= d.TitleThreshold {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// wordPairs returns the adjacent pairs of a normalized title's content
+// words. Words shorter than four letters are left out, so pairs such as
+// "of the" do not make every title a candidate for every other.
+func wordPairs(title string) []string {
+	var words []string
+	for _, word := range strings.Fields(title) {
+		if len([]rune(word)) >= 4 {
+			words = append(words, word)
+		}
+	}
+	pairs := make([]string, 0, len(words))
+	for i := 1; i < len(words); i++ {
+		pairs = append(pairs, words[i-1]+" "+words[i])
+	}
+	return pairs
+}
+
+// trigrams returns the character trigrams of a normalized title, which
+// tolerate the spelling and hyphenation differences between sources
+// better than whole words do
+func trigrams(title string) map[string]bool {
+	runes := []rune(title)
+	grams := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = true
+	}
+	return grams
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for gram := range a {
+		if b[gram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
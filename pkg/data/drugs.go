@@ -0,0 +1,205 @@
+package data
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// drug is one RxNorm ingredient with the other names it is written under:
+// international nonproprietary names and brand names
+type drug struct {
+	ingredient string // RxNorm ingredient name
+	synonyms   []string
+	brands     []string
+}
+
+// drugs is a bundled subset of RxNorm covering commonly searched drugs.
+// Combination products list each ingredient's brands under that ingredient.
+var drugs = []drug{
+	{"acetaminophen", []string{"paracetamol", "APAP"}, []string{"Tylenol", "Panadol", "Calpol", "Ofirmev"}},
+	{"ibuprofen", nil, []string{"Advil", "Motrin", "Nurofen"}},
+	{"naproxen", nil, []string{"Aleve", "Naprosyn", "Anaprox"}},
+	{"aspirin", []string{"acetylsalicylic acid"}, []string{"Bayer Aspirin", "Ecotrin", "Disprin"}},
+	{"celecoxib", nil, []string{"Celebrex"}},
+	{"diclofenac", nil, []string{"Voltaren", "Cataflam"}},
+	{"oxycodone", nil, []string{"OxyContin", "Roxicodone", "Percocet"}},
+	{"hydrocodone", nil, []string{"Vicodin", "Norco", "Zohydro"}},
+	{"tramadol", nil, []string{"Ultram"}},
+	{"morphine", nil, []string{"MS Contin", "Kadian"}},
+	{"amoxicillin", nil, []string{"Amoxil", "Augmentin"}},
+	{"clavulanate", []string{"clavulanic acid"}, []string{"Augmentin"}},
+	{"azithromycin", nil, []string{"Zithromax", "Z-Pak"}},
+	{"ciprofloxacin", nil, []string{"Cipro"}},
+	{"doxycycline", nil, []string{"Vibramycin", "Doryx"}},
+	{"metformin", nil, []string{"Glucophage", "Fortamet", "Glumetza"}},
+	{"semaglutide", nil, []string{"Ozempic", "Wegovy", "Rybelsus"}},
+	{"liraglutide", nil, []string{"Victoza", "Saxenda"}},
+	{"tirzepatide", nil, []string{"Mounjaro", "Zepbound"}},
+	{"empagliflozin", nil, []string{"Jardiance"}},
+	{"dapagliflozin", nil, []string{"Farxiga", "Forxiga"}},
+	{"insulin glargine", nil, []string{"Lantus", "Toujeo", "Basaglar"}},
+	{"atorvastatin", nil, []string{"Lipitor"}},
+	{"rosuvastatin", nil, []string{"Crestor"}},
+	{"simvastatin", nil, []string{"Zocor"}},
+	{"lisinopril", nil, []string{"Zestril", "Prinivil"}},
+	{"amlodipine", nil, []string{"Norvasc"}},
+	{"losartan", nil, []string{"Cozaar"}},
+	{"metoprolol", nil, []string{"Lopressor", "Toprol"}},
+	{"hydrochlorothiazide", []string{"HCTZ"}, []string{"Microzide"}},
+	{"furosemide", []string{"frusemide"}, []string{"Lasix"}},
+	{"warfarin", nil, []string{"Coumadin", "Jantoven"}},
+	{"apixaban", nil, []string{"Eliquis"}},
+	{"rivaroxaban", nil, []string{"Xarelto"}},
+	{"clopidogrel", nil, []string{"Plavix"}},
+	{"omeprazole", nil, []string{"Prilosec", "Losec"}},
+	{"esomeprazole", nil, []string{"Nexium"}},
+	{"pantoprazole", nil, []string{"Protonix"}},
+	{"sertraline", nil, []string{"Zoloft"}},
+	{"fluoxetine", nil, []string{"Prozac", "Sarafem"}},
+	{"escitalopram", nil, []string{"Lexapro", "Cipralex"}},
+	{"bupropion", nil, []string{"Wellbutrin", "Zyban"}},
+	{"alprazolam", nil, []string{"Xanax"}},
+	{"gabapentin", nil, []string{"Neurontin"}},
+	{"pregabalin", nil, []string{"Lyrica"}},
+	{"levothyroxine", []string{"L-thyroxine"}, []string{"Synthroid", "Levoxyl", "Euthyrox"}},
+	{"prednisone", nil, []string{"Deltasone"}},
+	{"montelukast", nil, []string{"Singulair"}},
+	{"albuterol", []string{"salbutamol"}, []string{"Ventolin", "ProAir", "Proventil"}},
+	{"cetirizine", nil, []string{"Zyrtec"}},
+	{"loratadine", nil, []string{"Claritin"}},
+	{"diphenhydramine", nil, []string{"Benadryl"}},
+	{"sildenafil", nil, []string{"Viagra", "Revatio"}},
+	{"tadalafil", nil, []string{"Cialis"}},
+	{"adalimumab", nil, []string{"Humira"}},
+	{"pembrolizumab", nil, []string{"Keytruda"}},
+	{"nivolumab", nil, []string{"Opdivo"}},
+	{"trastuzumab", nil, []string{"Herceptin"}},
+	{"imatinib", nil, []string{"Gleevec", "Glivec"}},
+	{"epinephrine", []string{"adrenaline"}, []string{"EpiPen", "Adrenalin"}},
+	{"ondansetron", nil, []string{"Zofran"}},
+}
+
+// drugName is one compiled name pattern
+type drugName struct {
+	name    string
+	brand   bool
+	pattern *regexp.Regexp
+	drugs   []*drug
+}
+
+var (
+	drugNamesOnce sync.Once
+	drugNames     []drugName
+)
+
+// compiledDrugNames builds case-insensitive patterns for every name, longest
+// first so "Bayer Aspirin" is read as a brand before "aspirin"
+func compiledDrugNames() []drugName {
+	drugNamesOnce.Do(func() {
+		byName := make(map[string]*drugName)
+		var order []string
+		add := func(name string, brand bool, d *drug) {
+			key := strings.ToLower(name)
+			if existing, ok := byName[key]; ok {
+				existing.drugs = append(existing.drugs, d)
+				return
+			}
+			byName[key] = &drugName{
+				name:    name,
+				brand:   brand,
+				pattern: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`),
+				drugs:   []*drug{d},
+			}
+			order = append(order, key)
+		}
+		for i := range drugs {
+			d := &drugs[i]
+			add(d.ingredient, false, d)
+			for _, synonym := range d.synonyms {
+				add(synonym, false, d)
+			}
+			for _, brand := range d.brands {
+				add(brand, true, d)
+			}
+		}
+		for _, key := range order {
+			drugNames = append(drugNames, *byName[key])
+		}
+		sort.SliceStable(drugNames, func(i, j int) bool { return len(drugNames[i].name) > len(drugNames[j].name) })
+	})
+	return drugNames
+}
+
+// DrugMentions are the drugs named in a text: the RxNorm ingredients and the
+// brand names they were mentioned under
+type DrugMentions struct {
+	Ingredients []string
+	Brands      []string
+}
+
+// ExtractDrugs finds the drugs named in text by ingredient, synonym
+// ("paracetamol") or brand ("Tylenol")
+func ExtractDrugs(text string) DrugMentions {
+	var mentions DrugMentions
+	seenIngredients := make(map[string]bool)
+	seenBrands := make(map[string]bool)
+	masked := []byte(text)
+	for _, name := range compiledDrugNames() {
+		locs := name.pattern.FindAllIndex(masked, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		for _, loc := range locs {
+			for i := loc[0]; i < loc[1]; i++ {
+				masked[i] = ' '
+			}
+		}
+		if name.brand && !seenBrands[name.name] {
+			seenBrands[name.name] = true
+			mentions.Brands = append(mentions.Brands, name.name)
+		}
+		for _, d := range name.drugs {
+			if !seenIngredients[d.ingredient] {
+				seenIngredients[d.ingredient] = true
+				mentions.Ingredients = append(mentions.Ingredients, d.ingredient)
+			}
+		}
+	}
+	sort.Strings(mentions.Ingredients)
+	sort.Strings(mentions.Brands)
+	return mentions
+}
+
+// ExpandDrugNames appends the ingredient and synonym names of the drugs in
+// query that it does not already spell out, so a search for "Tylenol"
+// also finds literature on acetaminophen and paracetamol
+func ExpandDrugNames(query string) string {
+	ingredients := ExtractDrugs(query).Ingredients
+	if len(ingredients) == 0 {
+		return query
+	}
+	lower := strings.ToLower(query)
+	var extra []string
+	for _, ingredient := range ingredients {
+		for i := range drugs {
+			if drugs[i].ingredient != ingredient {
+				continue
+			}
+			for _, name := range append([]string{ingredient}, drugs[i].synonyms...) {
+				// Abbreviations like "APAP" add noise rather than recall
+				if name == strings.ToUpper(name) {
+					continue
+				}
+				if !strings.Contains(lower, strings.ToLower(name)) {
+					extra = append(extra, name)
+				}
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return query
+	}
+	return query + " (" + strings.Join(extra, ", ") + ")"
+}
@@ -0,0 +1,299 @@
+package data
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/models"
+)
+
+// EuropePMCSource is the source recorded on Europe PMC articles
+const EuropePMCSource = "europepmc"
+
+// EuropePMCClient searches Europe PMC and fetches the full text of its
+// open-access articles (https://europepmc.org/RestfulWebService)
+type EuropePMCClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Delay      time.Duration // between requests
+}
+
+func NewEuropePMCClient() *EuropePMCClient {
+	return &EuropePMCClient{
+		BaseURL:    "https://www.ebi.ac.uk/europepmc/webservices/rest",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Delay:      200 * time.Millisecond,
+	}
+}
+
+// europePMCPageSize is the most results the search returns per page
+const europePMCPageSize = 100
+
+// europePMCSearch is one page of a resultType=core search
+type europePMCSearch struct {
+	NextCursorMark string `json:"nextCursorMark"`
+	ResultList     struct {
+		Result []europePMCResult `json:"result"`
+	} `json:"resultList"`
+}
+
+type europePMCResult struct {
+	ID                   string `json:"id"`
+	Source               string `json:"source"` // MED, PMC, PPR, ...
+	PMID                 string `json:"pmid"`
+	PMCID                string `json:"pmcid"`
+	DOI                  string `json:"doi"`
+	Title                string `json:"title"`
+	AbstractText         string `json:"abstractText"`
+	FirstPublicationDate string `json:"firstPublicationDate"`
+	AuthorList           struct {
+		Author []struct {
+			FullName  string `json:"fullName"`
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+			Initials  string `json:"initials"`
+
+			AuthorAffiliationDetailsList struct {
+				AuthorAffiliation []struct {
+					Affiliation string `json:"affiliation"`
+				} `json:"authorAffiliation"`
+			} `json:"authorAffiliationDetailsList"`
+		} `json:"author"`
+	} `json:"authorList"`
+	JournalInfo struct {
+		Journal struct {
+			Title           string `json:"title"`
+			ISOAbbreviation string `json:"isoabbreviation"`
+		} `json:"journal"`
+	} `json:"journalInfo"`
+	PubTypeList struct {
+		PubType []string `json:"pubType"`
+	} `json:"pubTypeList"`
+	MeshHeadingList struct {
+		MeshHeading []struct {
+			DescriptorName string `json:"descriptorName"`
+		} `json:"meshHeading"`
+	} `json:"meshHeadingList"`
+	KeywordList struct {
+		Keyword []string `json:"keyword"`
+	} `json:"keywordList"`
+}
+
+// Search returns up to maxResults articles matching query (Europe PMC query
+// syntax), following the cursor across pages. With fullText only
+// open-access articles are returned, each with the sections of its full
+// text; an article whose full text cannot be fetched keeps just its
+// abstract. Articles fetched before a failed page are returned with the
+// error.
+func (c *EuropePMCClient) Search(query string, maxResults int, fullText bool) ([]models.MedicalArticle, error) {
+	if fullText {
+		query = "(" + query + ") AND OPEN_ACCESS:y"
+	}
+	var articles []models.MedicalArticle
+	cursor := "*"
+	for len(articles) < maxResults {
+		if cursor != "*" {
+			time.Sleep(c.Delay)
+		}
+		params := url.Values{}
+		params.Set("query", query)
+		params.Set("resultType", "core")
+		params.Set("format", "json")
+		params.Set("pageSize", fmt.Sprint(min(maxResults-len(articles), europePMCPageSize)))
+		params.Set("cursorMark", cursor)
+
+		var page europePMCSearch
+		if err := c.getJSON(c.BaseURL+"/search?"+params.Encode(), &page); err != nil {
+			return articles, err
+		}
+		for _, result := range page.ResultList.Result {
+			article := normalizeEuropePMC(result)
+			if fullText && result.PMCID != "" {
+				time.Sleep(c.Delay)
+				if sections, err := c.FetchSections(result.PMCID); err == nil {
+					article.Sections = sections
+				}
+			}
+			articles = append(articles, article)
+		}
+		if len(page.ResultList.Result) == 0 || page.NextCursorMark == "" || page.NextCursorMark == cursor {
+			break
+		}
+		cursor = page.NextCursorMark
+	}
+	return articles, nil
+}
+
+// FetchSections downloads the JATS full text of an open-access article and
+// returns its body sections. Articles without open-access full text return
+// an error wrapping apperrors.ErrNotFound.
+func (c *EuropePMCClient) FetchSections(pmcid string) ([]models.ArticleSection, error) {
+	resp, err := c.HTTPClient.Get(fmt.Sprintf("%s/%s/fullTextXML", c.BaseURL, url.PathEscape(pmcid)))
+	if err != nil {
+		return nil, fmt.Errorf("Europe PMC request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkEuropePMCStatus(resp); err != nil {
+		return nil, fmt.Errorf("full text of %s: %w", pmcid, err)
+	}
+	return ParseJATSSections(resp.Body)
+}
+
+func (c *EuropePMCClient) getJSON(requestURL string, target interface{}) error {
+	resp, err := c.HTTPClient.Get(requestURL)
+	if err != nil {
+		return fmt.Errorf("Europe PMC request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkEuropePMCStatus(resp); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to parse Europe PMC response: %w", err)
+	}
+	return nil
+}
+
+func checkEuropePMCStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: Europe PMC returned %s", apperrors.ErrNotFound, resp.Status)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: Europe PMC returned %s", apperrors.ErrRateLimited, resp.Status)
+	default:
+		return fmt.Errorf("Europe PMC returned %s", resp.Status)
+	}
+}
+
+// normalizeEuropePMC converts a search result into an article. Records
+// that are also in PubMed keep their PMID as ID, so indexing them replaces
+// the abstract-only PubMed point rather than duplicating it.
+func normalizeEuropePMC(result europePMCResult) models.MedicalArticle {
+	id := result.PMID
+	if id == "" {
+		id = result.PMCID
+	}
+	if id == "" {
+		id = result.Source + ":" + result.ID
+	}
+	published, _ := time.Parse("2006-01-02", result.FirstPublicationDate)
+
+	article := models.MedicalArticle{
+		ID:               id,
+		Title:            CleanMedicalText(plainText(result.Title)),
+		Abstract:         CleanMedicalText(plainText(result.AbstractText)),
+		PublishedDate:    published,
+		DOI:              result.DOI,
+		Journal:          result.JournalInfo.Journal.Title,
+		JournalAbbr:      result.JournalInfo.Journal.ISOAbbreviation,
+		Source:           EuropePMCSource,
+		PublicationTypes: result.PubTypeList.PubType,
+		KeyConcepts:      result.KeywordList.Keyword,
+	}
+	for _, mesh := range result.MeshHeadingList.MeshHeading {
+		article.MeshHeadings = append(article.MeshHeadings, mesh.DescriptorName)
+	}
+	for _, author := range result.AuthorList.Author {
+		article.Authors = append(article.Authors, models.Author{
+			LastName: author.LastName,
+			ForeName: author.FirstName,
+			Initials: author.Initials,
+			FullName: author.FullName,
+		})
+		for _, affiliation := range author.AuthorAffiliationDetailsList.AuthorAffiliation {
+			if affiliation.Affiliation != "" {
+				article.Affiliations = append(article.Affiliations, affiliation.Affiliation)
+			}
+		}
+	}
+	if len(article.Affiliations) > 0 {
+		article.Affiliation = article.Affiliations[0]
+	}
+	return article
+}
+
+// jatsBody is the part of a JATS article the section parser reads
+type jatsBody struct {
+	Body struct {
+		Paragraphs []jatsText    `xml:"p"`
+		Sections   []jatsSection `xml:"sec"`
+	} `xml:"body"`
+}
+
+type jatsSection struct {
+	Type       string        `xml:"sec-type,attr"`
+	Title      jatsText      `xml:"title"`
+	Paragraphs []jatsText    `xml:"p"`
+	Sections   []jatsSection `xml:"sec"`
+}
+
+type jatsText struct {
+	Inner string `xml:",innerxml"`
+}
+
+// ParseJATSSections returns the top-level body sections of a JATS article,
+// each with the text of its paragraphs and subsections. Paragraphs before
+// the first section form an introduction.
+func ParseJATSSections(r io.Reader) ([]models.ArticleSection, error) {
+	var article jatsBody
+	if err := xml.NewDecoder(r).Decode(&article); err != nil {
+		return nil, fmt.Errorf("failed to parse JATS XML: %w", err)
+	}
+
+	var sections []models.ArticleSection
+	if lead := paragraphText(article.Body.Paragraphs); lead != "" {
+		sections = append(sections, models.ArticleSection{Kind: SectionIntroduction, Text: lead})
+	}
+	for _, sec := range article.Body.Sections {
+		heading := plainText(sec.Title.Inner)
+		text := sectionText(sec)
+		if text == "" {
+			continue
+		}
+		sections = append(sections, models.ArticleSection{
+			Kind:    ClassifySection(sec.Type, heading),
+			Heading: heading,
+			Text:    text,
+		})
+	}
+	return sections, nil
+}
+
+// sectionText joins a section's paragraphs and those of its subsections,
+// keeping subsection headings as lead-ins
+func sectionText(sec jatsSection) string {
+	parts := []string{}
+	if text := paragraphText(sec.Paragraphs); text != "" {
+		parts = append(parts, text)
+	}
+	for _, sub := range sec.Sections {
+		text := sectionText(sub)
+		if text == "" {
+			continue
+		}
+		if heading := plainText(sub.Title.Inner); heading != "" {
+			text = heading + ": " + text
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func paragraphText(paragraphs []jatsText) string {
+	var parts []string
+	for _, p := range paragraphs {
+		if text := plainText(p.Inner); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
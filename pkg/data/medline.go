@@ -0,0 +1,108 @@
+package data
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"MedAtlasAIServer/internal/models"
+)
+
+// MedlineFilter selects records from a MEDLINE bulk file. A record matches
+// when any term appears in its title or abstract, or any MeSH descriptor
+// name or unique ID ("D009369") is among its headings. An empty filter
+// matches every record.
+type MedlineFilter struct {
+	Terms []string
+	Mesh  []string
+}
+
+// Empty reports whether the filter matches every record
+func (f MedlineFilter) Empty() bool {
+	return len(f.Terms) == 0 && len(f.Mesh) == 0
+}
+
+// Match reports whether article passes the filter
+func (f MedlineFilter) Match(article models.PubMedArticle) bool {
+	if f.Empty() {
+		return true
+	}
+	for _, heading := range article.MedlineCitation.MeshHeadingList.MeshHeadings {
+		for _, mesh := range f.Mesh {
+			if strings.EqualFold(heading.DescriptorName.Text, mesh) || heading.DescriptorName.UI == mesh {
+				return true
+			}
+		}
+	}
+	if len(f.Terms) == 0 {
+		return false
+	}
+	citation := article.MedlineCitation.Article
+	var text strings.Builder
+	text.WriteString(strings.ToLower(citation.ArticleTitle))
+	for _, abstract := range citation.Abstract.AbstractText {
+		text.WriteString(" ")
+		text.WriteString(strings.ToLower(abstract.Text))
+	}
+	for _, term := range f.Terms {
+		if strings.Contains(text.String(), strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MedlineStats counts what a bulk file contained
+type MedlineStats struct {
+	Records int      // PubmedArticle elements read
+	Matched int      // records that passed the filter
+	Deleted []string // PMIDs listed in DeleteCitation (update files only)
+}
+
+// StreamMedline reads a MEDLINE/PubMed bulk XML file (a baseline or daily
+// update file, already decompressed) one record at a time, calling fn for
+// each record that passes filter. Bulk files hold around 30,000 records
+// each, so they are never loaded whole. It stops at the first error fn
+// returns.
+func StreamMedline(r io.Reader, filter MedlineFilter, fn func(models.PubMedArticle) error) (MedlineStats, error) {
+	var stats MedlineStats
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to parse MEDLINE XML after %d records: %w", stats.Records, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "PubmedArticle":
+			var article models.PubMedArticle
+			if err := decoder.DecodeElement(&article, &start); err != nil {
+				return stats, fmt.Errorf("failed to parse MEDLINE record %d: %w", stats.Records+1, err)
+			}
+			stats.Records++
+			if !filter.Match(article) {
+				continue
+			}
+			stats.Matched++
+			if err := fn(article); err != nil {
+				return stats, err
+			}
+		case "DeleteCitation":
+			var deleted struct {
+				PMIDs []string `xml:"PMID"`
+			}
+			if err := decoder.DecodeElement(&deleted, &start); err != nil {
+				return stats, fmt.Errorf("failed to parse DeleteCitation: %w", err)
+			}
+			stats.Deleted = append(stats.Deleted, deleted.PMIDs...)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/models"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
+
+// MedlinePlusSource is the source recorded on MedlinePlus health topics
+const MedlinePlusSource = "medlineplus"
+
+// MedlinePlusClient searches the MedlinePlus health topics web service
+// (https://medlineplus.gov/about/developers/webservices/)
+type MedlinePlusClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Delay      time.Duration // between requests; NLM asks for at most 85 a minute
+}
+
+func NewMedlinePlusClient() *MedlinePlusClient {
+	return &MedlinePlusClient{
+		BaseURL:    "https://wsearch.nlm.nih.gov/ws/query",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Delay:      time.Second,
+	}
+}
+
+// medlinePlusResult is the nlmSearchResult document the service returns.
+// Each document is a list of named content fields holding escaped HTML.
+type medlinePlusResult struct {
+	Documents []struct {
+		URL     string `xml:"url,attr"`
+		Content []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"content"`
+	} `xml:"list>document"`
+}
+
+// SearchHealthTopics returns up to maxResults English health topics matching
+// term, best match first
+func (c *MedlinePlusClient) SearchHealthTopics(term string, maxResults int) ([]models.HealthTopic, error) {
+	searchURL := fmt.Sprintf("%s?db=healthTopics&term=%s&retmax=%d&rettype=topic",
+		c.BaseURL, url.QueryEscape(term), maxResults)
+
+	resp, err := c.HTTPClient.Get(searchURL)
+	if err != nil {
+		return nil, fmt.Errorf("MedlinePlus request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: MedlinePlus returned %s", apperrors.ErrRateLimited, resp.Status)
+	default:
+		return nil, fmt.Errorf("MedlinePlus returned %s", resp.Status)
+	}
+
+	var result medlinePlusResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse MedlinePlus XML: %w", err)
+	}
+
+	topics := make([]models.HealthTopic, 0, len(result.Documents))
+	for _, doc := range result.Documents {
+		topic := models.HealthTopic{ID: doc.URL, URL: doc.URL, Source: MedlinePlusSource}
+		for _, content := range doc.Content {
+			text := plainText(content.Value)
+			if text == "" {
+				continue
+			}
+			switch content.Name {
+			case "title":
+				topic.Title = text
+			case "FullSummary":
+				topic.Summary = text
+			case "altTitle":
+				topic.AltTitles = append(topic.AltTitles, text)
+			case "mesh":
+				topic.MeshTerms = append(topic.MeshTerms, text)
+			case "groupName":
+				topic.Groups = append(topic.Groups, text)
+			}
+		}
+		if topic.Title != "" && topic.Summary != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// plainText strips the markup (search-term highlighting, summary
+// paragraphs and lists) from a MedlinePlus field
+func plainText(s string) string {
+	s = strings.NewReplacer("</p>", "</p> ", "</li>", "</li> ").Replace(s)
+	return strings.Join(strings.Fields(html.UnescapeString(strip.StripTags(s))), " ")
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Numeric claim kinds
+const (
+	ClaimPercentage         = "percentage"
+	ClaimHazardRatio        = "hazard_ratio"
+	ClaimOddsRatio          = "odds_ratio"
+	ClaimRelativeRisk       = "relative_risk"
+	ClaimConfidenceInterval = "confidence_interval"
+	ClaimPValue             = "p_value"
+	ClaimDosage             = "dosage"
+)
+
+// NumericClaim is a number stated in a passage, with its unit normalized
+type NumericClaim struct {
+	Kind       string  `json:"kind"`
+	Value      float64 `json:"value"`
+	High       float64 `json:"high,omitempty"` // upper bound for intervals
+	Unit       string  `json:"unit,omitempty"`
+	Comparator string  `json:"comparator,omitempty"` // for p-values: "<", "=", ">"
+	Text       string  `json:"text"`                 // the matched source text
+}
+
+func (c NumericClaim) String() string {
+	switch c.Kind {
+	case ClaimPercentage:
+		return fmt.Sprintf("%s%%", formatNumber(c.Value))
+	case ClaimConfidenceInterval:
+		return fmt.Sprintf("95%% CI %s–%s", formatNumber(c.Value), formatNumber(c.High))
+	case ClaimPValue:
+		return fmt.Sprintf("p %s %s", c.Comparator, formatNumber(c.Value))
+	case ClaimDosage:
+		return fmt.Sprintf("%s %s", formatNumber(c.Value), c.Unit)
+	default:
+		return fmt.Sprintf("%s %s", strings.ReplaceAll(c.Kind, "_", " "), formatNumber(c.Value))
+	}
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+const number = `(\d+(?:\.\d+)?)`
+
+var (
+	ratioPatterns = []struct {
+		kind string
+		re   *regexp.Regexp
+	}{
+		{ClaimHazardRatio, regexp.MustCompile(`(?i)\b(?:hazard ratio|HR)\b[\s:=,(]*(?:of\s+)?` + number)},
+		{ClaimOddsRatio, regexp.MustCompile(`(?i)\b(?:odds ratio|aOR)\b[\s:=,(]*(?:of\s+)?` + number)},
+		{ClaimRelativeRisk, regexp.MustCompile(`(?i)\b(?:relative risk|risk ratio|RR)\b[\s:=,(]*(?:of\s+)?` + number)},
+	}
+	ciPattern      = regexp.MustCompile(`(?i)\b95\s*%?\s*(?:CI|confidence interval)\b[\s:=,]*` + number + `\s*(?:-|–|to|,)\s*` + number)
+	pValuePattern  = regexp.MustCompile(`(?i)\bp\s*(<|>|=|≤|≥)\s*(0?\.\d+)`)
+	percentPattern = regexp.MustCompile(`(?i)` + number + `\s*(?:%|percent\b|per cent\b)`)
+	dosagePattern  = regexp.MustCompile(`(?i)` + number + `\s*(mg|mcg|µg|μg|ug|g|kg|ml|l|iu|units?)(?:\s*/\s*(kg|day|d|m2|dose|h))?\b`)
+)
+
+// massToMg and volumeToML convert units to a canonical form
+var (
+	massToMg   = map[string]float64{"mg": 1, "mcg": 0.001, "µg": 0.001, "μg": 0.001, "ug": 0.001, "g": 1000}
+	volumeToML = map[string]float64{"ml": 1, "l": 1000}
+	perUnit    = map[string]string{"d": "day", "day": "day", "kg": "kg", "m2": "m²", "dose": "dose", "h": "h"}
+)
+
+// ExtractNumericClaims finds percentages, effect ratios, confidence intervals,
+// p-values and dosages in text. Dosages are normalized to mg, mL or IU.
+func ExtractNumericClaims(text string) []NumericClaim {
+	var claims []NumericClaim
+
+	for _, rp := range ratioPatterns {
+		for _, m := range rp.re.FindAllStringSubmatch(text, -1) {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+				claims = append(claims, NumericClaim{Kind: rp.kind, Value: v, Text: m[0]})
+			}
+		}
+	}
+
+	ciSpans := ciPattern.FindAllStringSubmatchIndex(text, -1)
+	for _, idx := range ciSpans {
+		low, errLow := strconv.ParseFloat(text[idx[2]:idx[3]], 64)
+		high, errHigh := strconv.ParseFloat(text[idx[4]:idx[5]], 64)
+		if errLow == nil && errHigh == nil {
+			claims = append(claims, NumericClaim{Kind: ClaimConfidenceInterval, Value: low, High: high, Text: text[idx[0]:idx[1]]})
+		}
+	}
+
+	for _, m := range pValuePattern.FindAllStringSubmatch(text, -1) {
+		if v, err := strconv.ParseFloat(m[2], 64); err == nil {
+			comparator := m[1]
+			switch comparator {
+			case "≤":
+				comparator = "<="
+			case "≥":
+				comparator = ">="
+			}
+			claims = append(claims, NumericClaim{Kind: ClaimPValue, Value: v, Comparator: comparator, Text: m[0]})
+		}
+	}
+
+	for _, idx := range percentPattern.FindAllStringSubmatchIndex(text, -1) {
+		// "95% CI" is part of an interval, not a finding
+		if insideSpan(idx[0], ciSpans) {
+			continue
+		}
+		if v, err := strconv.ParseFloat(text[idx[2]:idx[3]], 64); err == nil {
+			claims = append(claims, NumericClaim{Kind: ClaimPercentage, Value: v, Unit: "%", Text: text[idx[0]:idx[1]]})
+		}
+	}
+
+	for _, m := range dosagePattern.FindAllStringSubmatch(text, -1) {
+		if claim, ok := normalizeDosage(m); ok {
+			claims = append(claims, claim)
+		}
+	}
+
+	return claims
+}
+
+func normalizeDosage(m []string) (NumericClaim, bool) {
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return NumericClaim{}, false
+	}
+	unit := strings.ToLower(m[2])
+
+	switch {
+	case massToMg[unit] != 0:
+		v *= massToMg[unit]
+		unit = "mg"
+	case volumeToML[unit] != 0:
+		v *= volumeToML[unit]
+		unit = "mL"
+	case unit == "iu" || strings.HasPrefix(unit, "unit"):
+		unit = "IU"
+	case unit == "kg":
+		// body weight rather than a dose
+		return NumericClaim{}, false
+	}
+
+	if per := strings.ToLower(m[3]); per != "" {
+		unit += "/" + perUnit[per]
+	}
+	return NumericClaim{Kind: ClaimDosage, Value: v, Unit: unit, Text: m[0]}, true
+}
+
+func insideSpan(pos int, spans [][]int) bool {
+	for _, span := range spans {
+		if pos >= span[0] && pos < span[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/models"
+)
+
+// Stage is a single named enrichment step applied to an article in place
+type Stage interface {
+	Name() string
+	Process(article *models.MedicalArticle) error
+}
+
+// StageFunc adapts a plain function into a Stage
+type StageFunc struct {
+	StageName string
+	Fn        func(article *models.MedicalArticle) error
+}
+
+func (s StageFunc) Name() string { return s.StageName }
+
+func (s StageFunc) Process(article *models.MedicalArticle) error { return s.Fn(article) }
+
+// Built-in stage names
+const (
+	StageClean     = "clean"
+	StageNormalize = "normalize"
+	StageEnhance   = "enhance"
+)
+
+var (
+	stageRegistryMu sync.RWMutex
+	stageRegistry   = map[string]func() Stage{
+		StageClean: func() Stage {
+			return StageFunc{StageName: StageClean, Fn: func(article *models.MedicalArticle) error {
+				article.Title = CleanMedicalText(article.Title)
+				article.Abstract = CleanMedicalText(article.Abstract)
+				return nil
+			}}
+		},
+		StageNormalize: func() Stage {
+			return StageFunc{StageName: StageNormalize, Fn: func(article *models.MedicalArticle) error {
+				article.Abstract = NormalizeMedicalTerms(article.Abstract)
+				return nil
+			}}
+		},
+		StageEnhance: func() Stage {
+			return StageFunc{StageName: StageEnhance, Fn: func(article *models.MedicalArticle) error {
+				EnhanceArticle(article)
+				return nil
+			}}
+		},
+	}
+)
+
+// RegisterStage makes a custom stage (entity linking, summarization, ...)
+// available to pipeline configs under the given name
+func RegisterStage(name string, factory func() Stage) {
+	stageRegistryMu.Lock()
+	defer stageRegistryMu.Unlock()
+	stageRegistry[name] = factory
+}
+
+// NewStage builds a registered stage by name
+func NewStage(name string) (Stage, error) {
+	stageRegistryMu.RLock()
+	factory, ok := stageRegistry[name]
+	stageRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown enrichment stage: %s", name)
+	}
+	return factory(), nil
+}
+
+// StageMetrics holds timing information for one stage
+type StageMetrics struct {
+	Runs      int           `json:"runs"`
+	Errors    int           `json:"errors"`
+	TotalTime time.Duration `json:"total_time"`
+}
+
+// AverageTime returns the mean duration of a stage run
+func (m StageMetrics) AverageTime() time.Duration {
+	if m.Runs == 0 {
+		return 0
+	}
+	return m.TotalTime / time.Duration(m.Runs)
+}
+
+// Pipeline runs an ordered list of stages and records per-stage timings
+type Pipeline struct {
+	stages  []Stage
+	mu      sync.Mutex
+	metrics map[string]*StageMetrics
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{
+		stages:  stages,
+		metrics: make(map[string]*StageMetrics),
+	}
+}
+
+// DefaultPipeline returns the standard clean → enhance pipeline. Abstracts
+// keep their abbreviations, which chat expands for patients only; a config
+// can still add the normalize stage to expand them for everyone at ingest.
+func DefaultPipeline() *Pipeline {
+	pipeline, _ := NewPipelineFromNames([]string{StageClean, StageEnhance})
+	return pipeline
+}
+
+// NewPipelineFromNames builds a pipeline from registered stage names
+func NewPipelineFromNames(names []string) (*Pipeline, error) {
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		stage, err := NewStage(name)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return NewPipeline(stages...), nil
+}
+
+// Add appends a stage to the end of the pipeline
+func (p *Pipeline) Add(stage Stage) {
+	p.stages = append(p.stages, stage)
+}
+
+// Stages returns the names of the stages in execution order
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		names[i] = stage.Name()
+	}
+	return names
+}
+
+// Run applies every stage in order, stopping at the first error
+func (p *Pipeline) Run(article *models.MedicalArticle) error {
+	for _, stage := range p.stages {
+		start := time.Now()
+		err := stage.Process(article)
+		p.record(stage.Name(), time.Since(start), err)
+		if err != nil {
+			return fmt.Errorf("stage %s failed: %w", stage.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) record(name string, elapsed time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.metrics[name]
+	if !ok {
+		m = &StageMetrics{}
+		p.metrics[name] = m
+	}
+	m.Runs++
+	m.TotalTime += elapsed
+	if err != nil {
+		m.Errors++
+	}
+}
+
+// Metrics returns a copy of the per-stage timings
+func (p *Pipeline) Metrics() map[string]StageMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics := make(map[string]StageMetrics, len(p.metrics))
+	for name, m := range p.metrics {
+		metrics[name] = *m
+	}
+	return metrics
+}
+
+// SourcePipelines selects a pipeline per article source, falling back to a default
+type SourcePipelines struct {
+	Default  *Pipeline
+	BySource map[string]*Pipeline
+}
+
+// For returns the pipeline configured for source
+func (sp *SourcePipelines) For(source string) *Pipeline {
+	if pipeline, ok := sp.BySource[source]; ok {
+		return pipeline
+	}
+	return sp.Default
+}
+
+// Sources returns the configured source names, sorted
+func (sp *SourcePipelines) Sources() []string {
+	sources := make([]string, 0, len(sp.BySource))
+	for source := range sp.BySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// LoadSourcePipelines reads a JSON file mapping source names to stage lists,
+// e.g. {"default": ["clean", "normalize", "enhance"], "pubmed": ["clean", "enhance"]}.
+// An empty path yields the default pipeline for every source.
+func LoadSourcePipelines(path string) (*SourcePipelines, error) {
+	sp := &SourcePipelines{
+		Default:  DefaultPipeline(),
+		BySource: make(map[string]*Pipeline),
+	}
+	if path == "" {
+		return sp, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline config: %w", err)
+	}
+
+	var config map[string][]string
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline config: %w", err)
+	}
+
+	for source, names := range config {
+		pipeline, err := NewPipelineFromNames(names)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline for %s: %w", source, err)
+		}
+		if source == "default" {
+			sp.Default = pipeline
+			continue
+		}
+		sp.BySource[source] = pipeline
+	}
+	return sp, nil
+}
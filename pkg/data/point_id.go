@@ -0,0 +1,21 @@
+package data
+
+import "fmt"
+
+// PointID maps an article ID to the numeric Qdrant point ID used by the indexer.
+// Numeric IDs (PMIDs) are used as-is; anything else is hashed.
+func PointID(articleID string) uint64 {
+	// First try to parse as number
+	var idNum uint64
+	_, err := fmt.Sscanf(articleID, "%d", &idNum)
+	if err == nil {
+		return idNum
+	}
+
+	// If not numeric, create a hash-based ID
+	hash := uint64(0)
+	for _, char := range articleID {
+		hash = hash*31 + uint64(char)
+	}
+	return hash
+}
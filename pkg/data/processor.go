@@ -3,14 +3,31 @@ package data
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"MedAtlasAIServer/internal/access"
+	"MedAtlasAIServer/internal/clock"
 	"MedAtlasAIServer/internal/models"
 
 	strip "github.com/grokify/html-strip-tags-go"
 )
 
+// Clock is the time source for date validation and article age helpers.
+// Tests can replace it with a clock.Fixed.
+var Clock clock.Clock = clock.System
+
+var (
+	// disallowedChars keeps letters and digits of any script ("Guillain-Barré",
+	// "β-blocker"), hyphens, parentheses and the symbols numeric findings
+	// depend on (percentages, p-values, doses)
+	disallowedChars = regexp.MustCompile(`[^\p{L}\p{M}\p{N}_\s\-\.\,\(\)\&\/%<>=±≤≥×°]`)
+	whitespace      = regexp.MustCompile(`\s+`)
+)
+
 // CleanMedicalText removes HTML tags, special characters, and normalizes text
 func CleanMedicalText(text string) string {
 	if text == "" {
@@ -20,13 +37,192 @@ func CleanMedicalText(text string) string {
 	// Remove HTML tags
 	text = strip.StripTags(text)
 
-	// Remove special characters but keep medical terminology, hyphens, and parentheses
-	text = regexp.MustCompile(`[^\w\s\-\.\,\(\)\&\/]`).ReplaceAllString(text, " ")
+	// Variant notation ("c.68_69delAG", "HLA-B*57:01") needs the characters
+	// stripped below, so set it aside first
+	text, notation := protectNotation(text)
+
+	// Remove special characters but keep medical terminology
+	text = disallowedChars.ReplaceAllString(text, " ")
 
 	// Normalize whitespace
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
+	text = whitespace.ReplaceAllString(text, " ")
+
+	return strings.TrimSpace(restoreNotation(text, notation))
+}
+
+// medicalAbbreviations maps abbreviations to the terms NormalizeMedicalTerms expands them to
+var medicalAbbreviations = map[string]string{
+	"PT/INR":   "Prothrombin Time/International Normalized Ratio",
+	"MRI":      "Magnetic Resonance Imaging",
+	"CT":       "Computed Tomography",
+	"PET":      "Positron Emission Tomography",
+	"AI":       "Artificial Intelligence",
+	"NLP":      "Natural Language Processing",
+	"ER":       "Emergency Room",
+	"ED":       "Emergency Department",
+	"DNA":      "Deoxyribonucleic Acid",
+	"RNA":      "Ribonucleic Acid",
+	"COVID-19": "Coronavirus Disease 2019",
+	"HIV":      "Human Immunodeficiency Virus",
+	"AIDS":     "Acquired Immunodeficiency Syndrome",
+	"FDA":      "Food and Drug Administration",
+	"NIH":      "National Institutes of Health",
+	"WHO":      "World Health Organization",
+	"CDC":      "Centers for Disease Control and Prevention",
+	"ECG":      "Electrocardiogram",
+	"EEG":      "Electroencephalogram",
+	"EMG":      "Electromyography",
+	"ICU":      "Intensive Care Unit",
+	"OT":       "Occupational Therapy",
+	"Rx":       "Prescription",
+	"Dx":       "Diagnosis",
+	"Tx":       "Treatment",
+	"Hx":       "History",
+	"Sx":       "Symptoms",
+	"RO":       "Rule Out",
+	"SOB":      "Shortness of Breath",
+	"CP":       "Chest Pain",
+	"HA":       "Headache",
+	"HTN":      "Hypertension",
+	"DM":       "Diabetes Mellitus",
+	"CAD":      "Coronary Artery Disease",
+	"CHF":      "Congestive Heart Failure",
+	"COPD":     "Chronic Obstructive Pulmonary Disease",
+	"ARDS":     "Acute Respiratory Distress Syndrome",
+	"DVT":      "Deep Vein Thrombosis",
+	"PE":       "Pulmonary Embolism",
+	"MI":       "Myocardial Infarction",
+	"CVA":      "Cerebrovascular Accident",
+	"TIA":      "Transient Ischemic Attack",
+	"GBS":      "Guillain-Barré Syndrome",
+	"MS":       "Multiple Sclerosis",
+	"ALS":      "Amyotrophic Lateral Sclerosis",
+	"PD":       "Parkinson's Disease",
+	"AD":       "Alzheimer's Disease",
+	"RA":       "Rheumatoid Arthritis",
+	"SLE":      "Systemic Lupus Erythematosus",
+	"IBD":      "Inflammatory Bowel Disease",
+	"IBS":      "Irritable Bowel Syndrome",
+	"GERD":     "Gastroesophageal Reflux Disease",
+	"PUD":      "Peptic Ulcer Disease",
+	"CKD":      "Chronic Kidney Disease",
+	"ESRD":     "End Stage Renal Disease",
+	"UTI":      "Urinary Tract Infection",
+	"STI":      "Sexually Transmitted Infection",
+	"PID":      "Pelvic Inflammatory Disease",
+	"OCP":      "Oral Contraceptive Pill",
+	"IUD":      "Intrauterine Device",
+	"HRT":      "Hormone Replacement Therapy",
+	"BRCA":     "Breast Cancer gene",
+	"PSA":      "Prostate-Specific Antigen",
+	"CEA":      "Carcinoembryonic Antigen",
+	"AFP":      "Alpha-Fetoprotein",
+	"CA":       "Cancer",
+	"CA-125":   "Cancer Antigen 125",
+	"CA-19-9":  "Cancer Antigen 19-9",
+	"WBC":      "White Blood Cell",
+	"RBC":      "Red Blood Cell",
+	"HGB":      "Hemoglobin",
+	"HCT":      "Hematocrit",
+	"PLT":      "Platelet",
+	"INR":      "International Normalized Ratio",
+	"PTT":      "Partial Thromboplastin Time",
+	"ALT":      "Alanine Aminotransferase",
+	"AST":      "Aspartate Aminotransferase",
+	"ALP":      "Alkaline Phosphatase",
+	"GGT":      "Gamma-Glutamyl Transferase",
+	"BUN":      "Blood Urea Nitrogen",
+	"Cr":       "Creatinine",
+	"Na":       "Sodium",
+	"Cl":       "Chloride",
+	"CO2":      "Carbon Dioxide",
+	"Ca":       "Calcium",
+	"Mg":       "Magnesium",
+	"PO4":      "Phosphate",
+	"LFT":      "Liver Function Test",
+	"BMP":      "Basic Metabolic Panel",
+	"CMP":      "Comprehensive Metabolic Panel",
+	"CBC":      "Complete Blood Count",
+	"ABG":      "Arterial Blood Gas",
+	"VQ":       "Ventilation-Perfusion",
+	"CPR":      "Cardiopulmonary Resuscitation",
+	"ACLS":     "Advanced Cardiac Life Support",
+	"PALS":     "Pediatric Advanced Life Support",
+	"BLS":      "Basic Life Support",
+	"CCU":      "Coronary Care Unit",
+	"PICU":     "Pediatric Intensive Care Unit",
+	"NICU":     "Neonatal Intensive Care Unit",
+	"SICU":     "Surgical Intensive Care Unit",
+	"MICU":     "Medical Intensive Care Unit",
+	"ERCP":     "Endoscopic Retrograde Cholangiopancreatography",
+	"EGD":      "Esophagogastroduodenoscopy",
+	"COLON":    "Colonoscopy",
+	"EUS":      "Endoscopic Ultrasound",
+	"USG":      "Ultrasonography",
+}
+
+// contextualExpansion expands an ambiguous abbreviation when the text it
+// appears in matches context
+type contextualExpansion struct {
+	context  *regexp.Regexp
+	expanded string
+}
+
+// contextualAbbreviations are abbreviations whose meaning depends on the
+// surrounding text. The first expansion whose context matches is used; with
+// no match the abbreviation is left as written.
+var contextualAbbreviations = map[string][]contextualExpansion{
+	"PT": {
+		{regexp.MustCompile(`(?i)\b(INR|coagulation|anticoagula\w*|warfarin|prothrombin|clotting)\b`), "Prothrombin Time"},
+		{regexp.MustCompile(`(?i)\b(rehabilitation|physiotherap\w*|exercises?|mobility|therapists?)\b`), "Physical Therapy"},
+	},
+	"OR": {
+		{regexp.MustCompile(`(?i)\b(odds|CI|confidence intervals?|regression)\b`), "Odds Ratio"},
+		{regexp.MustCompile(`(?i)\b(surgery|surgical|operative|an(a)?esthesia)\b`), "Operating Room"},
+	},
+	"US": {
+		{regexp.MustCompile(`(?i)\b(ultrasound|ultrasonograph\w*|sonograph\w*|doppler|echogenic|guided|imaging)\b`), "Ultrasound"},
+	},
+	"K": {
+		{regexp.MustCompile(`(?i)\b(serum|plasma|potassium|electrolytes?|mmol|meq|hyperkal\w*|hypokal\w*)\b`), "Potassium"},
+	},
+}
+
+var (
+	abbreviationPatternOnce sync.Once
+	abbreviationPattern     *regexp.Regexp
+)
+
+// compiledAbbreviations compiles one pattern matching every abbreviation,
+// longest first, so "CA-125" and "PT/INR" are not read as "CA" and "PT" and
+// the text is scanned once: expansions are never expanded again
+func compiledAbbreviations() *regexp.Regexp {
+	abbreviationPatternOnce.Do(func() {
+		abbrs := make([]string, 0, len(medicalAbbreviations)+len(contextualAbbreviations))
+		for abbr := range medicalAbbreviations {
+			abbrs = append(abbrs, abbr)
+		}
+		for abbr := range contextualAbbreviations {
+			abbrs = append(abbrs, abbr)
+		}
+		sort.Slice(abbrs, func(i, j int) bool {
+			if len(abbrs[i]) != len(abbrs[j]) {
+				return len(abbrs[i]) > len(abbrs[j])
+			}
+			return abbrs[i] < abbrs[j]
+		})
+		for i, abbr := range abbrs {
+			abbrs[i] = regexp.QuoteMeta(abbr)
+		}
+		abbreviationPattern = regexp.MustCompile(`\b(?:` + strings.Join(abbrs, "|") + `)\b`)
+	})
+	return abbreviationPattern
+}
 
-	return strings.TrimSpace(text)
+// WarmUp builds the lazily compiled term dictionaries so the first request
+// does not pay for it
+func WarmUp() {
+	compiledAbbreviations()
 }
 
 // NormalizeMedicalTerms expands common medical abbreviations
@@ -35,132 +231,36 @@ func NormalizeMedicalTerms(text string) string {
 		return ""
 	}
 
-	// Common medical abbreviations expansion
-	abbreviations := map[string]string{
-		"PT( therapy)?": "Physical Therapy", // Regex for context
-		"PT( time)?":    "Prothrombin Time", // Regex for context
-		"PT/INR":        "Prothrombin Time/International Normalized Ratio",
-		"MRI":           "Magnetic Resonance Imaging",
-		"CT":            "Computed Tomography",
-		"PET":           "Positron Emission Tomography",
-		"AI":            "Artificial Intelligence",
-		"NLP":           "Natural Language Processing",
-		"ER":            "Emergency Room",
-		"ED":            "Emergency Department",
-		"DNA":           "Deoxyribonucleic Acid",
-		"RNA":           "Ribonucleic Acid",
-		"COVID-19":      "Coronavirus Disease 2019",
-		"HIV":           "Human Immunodeficiency Virus",
-		"AIDS":          "Acquired Immunodeficiency Syndrome",
-		"FDA":           "Food and Drug Administration",
-		"NIH":           "National Institutes of Health",
-		"WHO":           "World Health Organization",
-		"CDC":           "Centers for Disease Control and Prevention",
-		"ECG":           "Electrocardiogram",
-		"EEG":           "Electroencephalogram",
-		"EMG":           "Electromyography",
-		"ICU":           "Intensive Care Unit",
-		"OR":            "Operating Room",
-		"OT":            "Occupational Therapy",
-		"Rx":            "Prescription",
-		"Dx":            "Diagnosis",
-		"Tx":            "Treatment",
-		"Hx":            "History",
-		"Sx":            "Symptoms",
-		"RO":            "Rule Out",
-		"SOB":           "Shortness of Breath",
-		"CP":            "Chest Pain",
-		"HA":            "Headache",
-		"HTN":           "Hypertension",
-		"DM":            "Diabetes Mellitus",
-		"CAD":           "Coronary Artery Disease",
-		"CHF":           "Congestive Heart Failure",
-		"COPD":          "Chronic Obstructive Pulmonary Disease",
-		"ARDS":          "Acute Respiratory Distress Syndrome",
-		"DVT":           "Deep Vein Thrombosis",
-		"PE":            "Pulmonary Embolism",
-		"MI":            "Myocardial Infarction",
-		"CVA":           "Cerebrovascular Accident",
-		"TIA":           "Transient Ischemic Attack",
-		"GBS":           "Guillain-Barré Syndrome",
-		"MS":            "Multiple Sclerosis",
-		"ALS":           "Amyotrophic Lateral Sclerosis",
-		"PD":            "Parkinson's Disease",
-		"AD":            "Alzheimer's Disease",
-		"RA":            "Rheumatoid Arthritis",
-		"SLE":           "Systemic Lupus Erythematosus",
-		"IBD":           "Inflammatory Bowel Disease",
-		"IBS":           "Irritable Bowel Syndrome",
-		"GERD":          "Gastroesophageal Reflux Disease",
-		"PUD":           "Peptic Ulcer Disease",
-		"CKD":           "Chronic Kidney Disease",
-		"ESRD":          "End Stage Renal Disease",
-		"UTI":           "Urinary Tract Infection",
-		"STI":           "Sexually Transmitted Infection",
-		"PID":           "Pelvic Inflammatory Disease",
-		"OCP":           "Oral Contraceptive Pill",
-		"IUD":           "Intrauterine Device",
-		"HRT":           "Hormone Replacement Therapy",
-		"BRCA":          "Breast Cancer gene",
-		"PSA":           "Prostate-Specific Antigen",
-		"CEA":           "Carcinoembryonic Antigen",
-		"AFP":           "Alpha-Fetoprotein",
-		"CA":            "Cancer",
-		"CA-125":        "Cancer Antigen 125",
-		"CA-19-9":       "Cancer Antigen 19-9",
-		"WBC":           "White Blood Cell",
-		"RBC":           "Red Blood Cell",
-		"HGB":           "Hemoglobin",
-		"HCT":           "Hematocrit",
-		"PLT":           "Platelet",
-		"INR":           "International Normalized Ratio",
-		"PTT":           "Partial Thromboplastin Time",
-		"ALT":           "Alanine Aminotransferase",
-		"AST":           "Aspartate Aminotransferase",
-		"ALP":           "Alkaline Phosphatase",
-		"GGT":           "Gamma-Glutamyl Transferase",
-		"BUN":           "Blood Urea Nitrogen",
-		"Cr":            "Creatinine",
-		"Na":            "Sodium",
-		"K":             "Potassium",
-		"Cl":            "Chloride",
-		"CO2":           "Carbon Dioxide",
-		"Ca":            "Calcium",
-		"Mg":            "Magnesium",
-		"PO4":           "Phosphate",
-		"LFT":           "Liver Function Test",
-		"BMP":           "Basic Metabolic Panel",
-		"CMP":           "Comprehensive Metabolic Panel",
-		"CBC":           "Complete Blood Count",
-		"ABG":           "Arterial Blood Gas",
-		"VQ":            "Ventilation-Perfusion",
-		"CPR":           "Cardiopulmonary Resuscitation",
-		"ACLS":          "Advanced Cardiac Life Support",
-		"PALS":          "Pediatric Advanced Life Support",
-		"BLS":           "Basic Life Support",
-		"CCU":           "Coronary Care Unit",
-		"PICU":          "Pediatric Intensive Care Unit",
-		"NICU":          "Neonatal Intensive Care Unit",
-		"SICU":          "Surgical Intensive Care Unit",
-		"MICU":          "Medical Intensive Care Unit",
-		"ERCP":          "Endoscopic Retrograde Cholangiopancreatography",
-		"EGD":           "Esophagogastroduodenoscopy",
-		"COLON":         "Colonoscopy",
-		"EUS":           "Endoscopic Ultrasound",
-		"US":            "Ultrasound",
-		"USG":           "Ultrasonography",
-	}
-
-	// Replace abbreviations
-	for abbr, full := range abbreviations {
-		re := regexp.MustCompile(`\b` + abbr + `\b`)
-		text = re.ReplaceAllString(text, full)
-	}
-
-	return text
+	// Gene symbols and variants are not abbreviations ("PD-L1", "p.K27M")
+	text, notation := protectNotation(text)
+
+	// Resolve ambiguous abbreviations once for the whole text
+	resolved := make(map[string]string, len(contextualAbbreviations))
+	for abbr, expansions := range contextualAbbreviations {
+		for _, expansion := range expansions {
+			if expansion.context.MatchString(text) {
+				resolved[abbr] = expansion.expanded
+				break
+			}
+		}
+	}
+
+	text = compiledAbbreviations().ReplaceAllStringFunc(text, func(abbr string) string {
+		if expanded, ok := medicalAbbreviations[abbr]; ok {
+			return expanded
+		}
+		if expanded, ok := resolved[abbr]; ok {
+			return expanded
+		}
+		return abbr
+	})
+
+	return restoreNotation(text, notation)
 }
 
-// EnhanceArticle extracts key concepts and detects medical terminology
+// EnhanceArticle extracts key concepts, trial registrations, genes, variants
+// and drugs, detects medical terminology and locates the study population by
+// country and region
 func EnhanceArticle(article *models.MedicalArticle) *models.MedicalArticle {
 	if article == nil {
 		return nil
@@ -171,6 +271,27 @@ func EnhanceArticle(article *models.MedicalArticle) *models.MedicalArticle {
 	article.KeyConcepts = ExtractKeyConcepts(fullText)
 	article.HasMedicalTerms = ContainsMedicalTerm(fullText)
 
+	// Locate the study population from affiliations and the text itself
+	affiliations := article.Affiliations
+	if len(affiliations) == 0 && article.Affiliation != "" {
+		affiliations = []string{article.Affiliation}
+	}
+	article.Countries = ExtractCountries(studyPopulationText(affiliations, article.Title, article.Abstract)...)
+	article.Regions = RegionsForCountries(article.Countries)
+
+	// Trial registrations link the article to its ClinicalTrials.gov record
+	article.NCTIDs = ExtractNCTIDs(fullText)
+
+	// Genes and variants are matched exactly at search time
+	article.Genes = ExtractGeneSymbols(fullText)
+	article.Variants = ExtractVariants(fullText)
+
+	// Brand names resolve to their ingredients so "Tylenol" papers are found
+	// by "acetaminophen" and vice versa
+	drugMentions := ExtractDrugs(fullText)
+	article.Drugs = drugMentions.Ingredients
+	article.DrugBrands = drugMentions.Brands
+
 	return article
 }
 
@@ -212,7 +333,7 @@ func ValidateArticle(article models.MedicalArticle) bool {
 	// Validate publication date - be more lenient with dates
 	if !article.PublishedDate.IsZero() {
 		// Shouldn't be too far in future
-		if article.PublishedDate.After(time.Now().AddDate(2, 0, 0)) {
+		if article.PublishedDate.After(Clock.Now().AddDate(2, 0, 0)) {
 			return false
 		}
 
@@ -283,61 +404,70 @@ func FormatAuthors(authors []models.Author) string {
 	return builder.String()
 }
 
+// medicalTerms is the concept dictionary ExtractKeyConcepts matches against.
+// Multi-word terms are matched as phrases.
+var medicalTerms = map[string]bool{
+	// Diseases and Conditions
+	"diabetes": true, "cancer": true, "hypertension": true, "arthritis": true,
+	"asthma": true, "migraine": true, "depression": true, "anxiety": true,
+	"osteoporosis": true, "alzheimer": true, "parkinson": true,
+	"epilepsy": true, "schizophrenia": true, "fibromyalgia": true, "lupus": true,
+	"multiple sclerosis": true, "crohn": true, "colitis": true, "hepatitis": true,
+	"hiv": true, "aids": true, "tuberculosis": true, "malaria": true,
+	"pneumonia": true, "bronchitis": true, "emphysema": true, "copd": true,
+
+	// Symptoms
+	"pain": true, "fever": true, "fatigue": true, "nausea": true, "vomiting": true,
+	"headache": true, "dizziness": true, "rash": true, "swelling": true,
+	"inflammation": true, "bleeding": true, "shortness of breath": true,
+	"chest pain": true, "palpitations": true, "numbness": true, "weakness": true,
+
+	// Treatments and Procedures
+	"surgery": true, "chemotherapy": true, "radiotherapy": true, "immunotherapy": true,
+	"medication": true, "antibiotics": true, "antiviral": true, "antifungal": true,
+	"vaccine": true, "transplant": true, "dialysis": true, "biopsy": true,
+	"endoscopy": true, "colonoscopy": true, "mri": true, "ct scan": true,
+	"x-ray": true, "ultrasound": true, "blood test": true, "genetic testing": true,
+
+	// Body Systems and Anatomy
+	"cardiac": true, "pulmonary": true, "neurological": true, "gastrointestinal": true,
+	"renal": true, "hepatic": true, "endocrine": true, "musculoskeletal": true,
+	"dermatological": true, "ophthalmological": true, "otolaryngological": true,
+	"psychological": true, "immunological": true, "hematological": true,
+
+	// Medical Specialties
+	"oncology": true, "cardiology": true, "neurology": true, "psychiatry": true,
+	"pediatrics": true, "geriatrics": true, "radiology": true,
+	"pathology": true, "pharmacology": true, "epidemiology": true, "toxicology": true,
+	"dermatology": true, "endocrinology": true, "gastroenterology": true,
+	"nephrology": true, "pulmonology": true, "rheumatology": true, "urology": true,
+}
+
+// maxConceptWords is the length of the longest phrase in medicalTerms
+const maxConceptWords = 3
+
 // ExtractKeyConcepts identifies important medical terms from text
 func ExtractKeyConcepts(text string) []string {
 	if text == "" {
 		return nil
 	}
 
-	// Comprehensive medical terminology dictionary
-	medicalTerms := map[string]bool{
-		// Diseases and Conditions
-		"diabetes": true, "cancer": true, "hypertension": true, "arthritis": true,
-		"asthma": true, "migraine": true, "depression": true, "anxiety": true,
-		"osteoporosis": true, "alzheimer": true, "parkinson": true,
-		"epilepsy": true, "schizophrenia": true, "fibromyalgia": true, "lupus": true,
-		"multiple sclerosis": true, "crohn": true, "colitis": true, "hepatitis": true,
-		"hiv": true, "aids": true, "tuberculosis": true, "malaria": true,
-		"pneumonia": true, "bronchitis": true, "emphysema": true, "copd": true,
-
-		// Symptoms
-		"pain": true, "fever": true, "fatigue": true, "nausea": true, "vomiting": true,
-		"headache": true, "dizziness": true, "rash": true, "swelling": true,
-		"inflammation": true, "bleeding": true, "shortness of breath": true,
-		"chest pain": true, "palpitations": true, "numbness": true, "weakness": true,
-
-		// Treatments and Procedures
-		"surgery": true, "chemotherapy": true, "radiotherapy": true, "immunotherapy": true,
-		"medication": true, "antibiotics": true, "antiviral": true, "antifungal": true,
-		"vaccine": true, "transplant": true, "dialysis": true, "biopsy": true,
-		"endoscopy": true, "colonoscopy": true, "mri": true, "ct scan": true,
-		"x-ray": true, "ultrasound": true, "blood test": true, "genetic testing": true,
-
-		// Body Systems and Anatomy
-		"cardiac": true, "pulmonary": true, "neurological": true, "gastrointestinal": true,
-		"renal": true, "hepatic": true, "endocrine": true, "musculoskeletal": true,
-		"dermatological": true, "ophthalmological": true, "otolaryngological": true,
-		"psychological": true, "immunological": true, "hematological": true,
-
-		// Medical Specialties
-		"oncology": true, "cardiology": true, "neurology": true, "psychiatry": true,
-		"pediatrics": true, "geriatrics": true, "radiology": true,
-		"pathology": true, "pharmacology": true, "epidemiology": true, "toxicology": true,
-		"dermatology": true, "endocrinology": true, "gastroenterology": true,
-		"nephrology": true, "pulmonology": true, "rheumatology": true, "urology": true,
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = strings.Trim(word, ".,!?;:\"'()[]{}")
 	}
 
-	words := strings.Fields(text)
 	var concepts []string
-	seen := make(map[string]bool)
-
-	for _, word := range words {
-		cleanWord := strings.ToLower(strings.Trim(word, ".,!?;:\"'()[]{}"))
-
-		// Check for multi-word concepts first
-		if len(cleanWord) > 3 && medicalTerms[cleanWord] && !seen[cleanWord] {
-			concepts = append(concepts, word) // Preserve original case
-			seen[cleanWord] = true
+	for i := 0; i < len(words); i++ {
+		// Prefer the longest phrase starting here ("multiple sclerosis"
+		// over "multiple")
+		for n := min(maxConceptWords, len(words)-i); n >= 1; n-- {
+			phrase := strings.Join(words[i:i+n], " ")
+			if medicalTerms[strings.ToLower(phrase)] {
+				concepts = append(concepts, phrase) // Preserve original case
+				i += n - 1
+				break
+			}
 		}
 	}
 
@@ -360,12 +490,16 @@ func removeDuplicates(slice []string) []string {
 	return result
 }
 
+// doiPattern is Crossref's recommended DOI pattern. DOIs are case-insensitive
+// and most are registered in lowercase.
+var doiPattern = regexp.MustCompile(`(?i)^10\.\d{4,9}/[-._;()/:a-z0-9<>]+$`)
+
 // IsValidDOI validates DOI format
 func IsValidDOI(doi string) bool {
 	if doi == "" {
 		return false
 	}
-	return regexp.MustCompile(`^10\.\d{4,9}/[-._;()/:A-Z0-9]+$`).MatchString(doi)
+	return doiPattern.MatchString(doi)
 }
 
 // IsValidDate validates date format (YYYY-MM-DD)
@@ -373,28 +507,37 @@ func IsValidDate(dateStr string) bool {
 	if dateStr == "" {
 		return false
 	}
-	return regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(dateStr)
+	_, err := time.Parse("2006-01-02", dateStr)
+	return err == nil
 }
 
-// TruncateText truncates text to specified length with ellipsis
+// TruncateText shortens text to at most maxLength characters, ending it with
+// "..." at a sentence or word boundary where possible. Lengths count runes,
+// so multi-byte characters are never split.
 func TruncateText(text string, maxLength int) string {
-	if len(text) <= maxLength {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
 		return text
 	}
+	if maxLength <= 3 {
+		// No room for an ellipsis
+		return string(runes[:max(maxLength, 0)])
+	}
 
-	// Find a good breaking point (end of sentence or word)
-	if maxLength > 3 {
-		// Try to break at sentence end
-		if pos := strings.LastIndex(text[:maxLength-3], ". "); pos != -1 && pos > maxLength/2 {
-			return text[:pos+1] + "..."
-		}
-		// Try to break at word boundary
-		if pos := strings.LastIndex(text[:maxLength-3], " "); pos != -1 && pos > maxLength/2 {
-			return text[:pos] + "..."
-		}
+	// Find a good breaking point (end of sentence or word). Everything kept
+	// plus the ellipsis must fit: the candidate window includes the space
+	// that follows the last character kept.
+	window := string(runes[:maxLength-2])
+	// Try to break at sentence end
+	if pos := strings.LastIndex(window, ". "); pos != -1 && utf8.RuneCountInString(window[:pos]) > maxLength/2 {
+		return window[:pos+1] + "..."
+	}
+	// Try to break at word boundary
+	if pos := strings.LastIndex(window, " "); pos != -1 && utf8.RuneCountInString(window[:pos]) > maxLength/2 {
+		return window[:pos] + "..."
 	}
 
-	return text[:maxLength-3] + "..."
+	return string(runes[:maxLength-3]) + "..."
 }
 
 // ExtractYearFromDate extracts year from time.Time
@@ -410,7 +553,7 @@ func CalculateArticleAge(publishedDate time.Time) int {
 	if publishedDate.IsZero() {
 		return 0
 	}
-	age := time.Since(publishedDate)
+	age := Clock.Now().Sub(publishedDate)
 	return int(age.Hours() / 24 / 365.25)
 }
 
@@ -419,7 +562,7 @@ func IsRecentArticle(publishedDate time.Time, years int) bool {
 	if publishedDate.IsZero() {
 		return false
 	}
-	return time.Since(publishedDate) <= time.Duration(years)*365*24*time.Hour
+	return Clock.Now().Sub(publishedDate) <= time.Duration(years)*365*24*time.Hour
 }
 
 // ContainsMedicalTerm checks if text contains medical terminology - NOW USED!
@@ -492,7 +635,7 @@ func ExtractMedicalEntities(text string) map[string][]string {
 
 	for entityType, regexPatterns := range patterns {
 		for _, pattern := range regexPatterns {
-			re := regexp.MustCompile(pattern)
+			re := regexp.MustCompile(`(?i)` + pattern)
 			matches := re.FindAllString(text, -1)
 			entities[entityType] = append(entities[entityType], matches...)
 		}
@@ -527,8 +670,9 @@ func CalculateMedicalRelevance(text string) float64 {
 		}
 	}
 
-	// Calculate ratio of medical terms to total words
-	return float64(medicalWords) / float64(len(words))
+	// Calculate ratio of medical terms to total words. Terms are counted
+	// as substrings, so one word can contain several ("patienttreatment").
+	return min(float64(medicalWords)/float64(len(words)), 1.0)
 }
 
 // IsClinicalStudy checks if article appears to be a clinical study - NEW!
@@ -563,47 +707,69 @@ func IsClinicalStudy(article models.MedicalArticle) bool {
 	return false
 }
 
+// Reasons returned by ValidateArticleWithReason. They are stable so callers
+// can aggregate rejections per category.
+const (
+	ReasonMissingID     = "missing ID"
+	ReasonMissingTitle  = "missing title"
+	ReasonTitleTooShort = "title too short"
+	ReasonInvalidTitle  = "invalid title"
+	ReasonNoAbstract    = "no abstract and title too short"
+	ReasonAbstractShort = "abstract too short"
+	ReasonFutureDate    = "future date"
+	ReasonDateTooOld    = "date too old"
+	ReasonLowQuality    = "low quality article"
+	ReasonNonMedical    = "non-medical content"
+	ReasonInvalidAccess = "invalid access label"
+)
+
 func ValidateArticleWithReason(article models.MedicalArticle) (bool, string) {
 	if article.ID == "" {
-		return false, "missing ID"
+		return false, ReasonMissingID
+	}
+
+	// An unknown label could be a typo for a restricted one, so the article
+	// is not indexed as public instead
+	if !access.Valid(article.Access) {
+		return false, ReasonInvalidAccess
 	}
 
 	if article.Title == "" {
-		return false, "missing title"
+		return false, ReasonMissingTitle
 	}
 
 	cleanTitle := strings.TrimSpace(article.Title)
 	if len(cleanTitle) < 5 {
-		return false, fmt.Sprintf("title too short: '%s'", cleanTitle)
+		return false, ReasonTitleTooShort
 	}
 
 	// Check for placeholder titles
 	invalidTitlePatterns := []string{"undefined", "null", "none", "unknown"}
 	for _, pattern := range invalidTitlePatterns {
 		if strings.EqualFold(cleanTitle, pattern) {
-			return false, fmt.Sprintf("invalid title: '%s'", cleanTitle)
+			return false, ReasonInvalidTitle
 		}
 	}
 
 	if article.Abstract == "" && len(cleanTitle) < 15 {
-		return false, "no abstract and title too short"
+		return false, ReasonNoAbstract
 	}
 
 	if len(strings.TrimSpace(article.Abstract)) < 30 && article.Abstract != "" {
-		return false, "abstract too short"
+		return false, ReasonAbstractShort
 	}
 
 	if !article.PublishedDate.IsZero() {
-		if article.PublishedDate.After(time.Now().AddDate(2, 0, 0)) {
-			return false, "future date"
+		if article.PublishedDate.After(Clock.Now().AddDate(2, 0, 0)) {
+			return false, ReasonFutureDate
 		}
 		if article.PublishedDate.Before(time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)) {
-			return false, "date too old"
+			return false, ReasonDateTooOld
 		}
 	}
 
 	if isLowQualityArticle(article) {
-		return false, "low quality article"
+		return false, ReasonLowQuality
 	}
 
 	return true, ""
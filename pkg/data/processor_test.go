@@ -0,0 +1,150 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeMedicalTerms(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"PT with coagulation context", "PT was prolonged on warfarin", "Prothrombin Time was prolonged on warfarin"},
+		{"PT with rehabilitation context", "PT improved mobility after stroke", "Physical Therapy improved mobility after stroke"},
+		{"PT without context", "PT 4 was enrolled", "PT 4 was enrolled"},
+		{"PT/INR is not PT", "PT/INR was measured", "Prothrombin Time/International Normalized Ratio was measured"},
+		{"OR as odds ratio", "OR 1.8 (95% CI 1.2-2.6)", "Odds Ratio 1.8 (95% CI 1.2-2.6)"},
+		{"OR as operating room", "time in the OR during surgery", "time in the Operating Room during surgery"},
+		{"OR without context", "OR is an abbreviation", "OR is an abbreviation"},
+		{"US with imaging context", "US guided biopsy", "Ultrasound guided biopsy"},
+		{"US as a country", "adults in the US", "adults in the US"},
+		{"K with electrolyte context", "serum K was 5.9 mmol/L", "serum Potassium was 5.9 mmol/L"},
+		{"K without context", "K cells were counted", "K cells were counted"},
+		{"CA-125 is not CA", "CA-125 was elevated", "Cancer Antigen 125 was elevated"},
+		{"CA alone", "CA of the ovary", "Cancer of the ovary"},
+		{"expansions are not expanded again", "MRI and CT", "Magnetic Resonance Imaging and Computed Tomography"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeMedicalTerms(tt.text); got != tt.want {
+				t.Errorf("NormalizeMedicalTerms(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{"short text is kept", "Short text", 20, "Short text"},
+		{"exact length is kept", "abcdef", 6, "abcdef"},
+		{"breaks at a sentence", "First sentence here. Second one follows", 30, "First sentence here...."},
+		{"breaks at a word", "alpha beta gamma delta epsilon", 20, "alpha beta gamma..."},
+		{"cuts without a boundary", "abcdefghijklmnopqrstuvwxyz", 10, "abcdefg..."},
+		{"counts runes not bytes", "ééééé", 5, "ééééé"},
+		{"never splits a rune", "ééééééééééé", 6, "ééé..."},
+		{"no room for an ellipsis", "ééééé", 3, "ééé"},
+		{"negative length", "abc", -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateText(tt.text, tt.maxLength)
+			if got != tt.want {
+				t.Errorf("TruncateText(%q, %d) = %q, want %q", tt.text, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidDOI(t *testing.T) {
+	tests := []struct {
+		doi  string
+		want bool
+	}{
+		{"10.1000/xyz123", true},
+		{"10.1056/NEJMoa2034577", true},
+		{"10.1002/(SICI)1097-0258(19980815/30)17:15/16<1661::AID-SIM968>3.0.CO;2-2", true},
+		{"10.12345/a.b_c-d", true},
+		{"", false},
+		{"10.123/too-short-prefix", false},
+		{"11.1000/xyz", false},
+		{"10.1000/", false},
+		{"doi:10.1000/xyz", false},
+		{"10.1000/with space", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidDOI(tt.doi); got != tt.want {
+			t.Errorf("IsValidDOI(%q) = %v, want %v", tt.doi, got, tt.want)
+		}
+	}
+}
+
+func TestCleanMedicalText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"strips tags", "<p>Aspirin <b>reduces</b> risk</p>", "Aspirin reduces risk"},
+		{"keeps other scripts", "Guillain-Barré and β-blocker", "Guillain-Barré and β-blocker"},
+		{"keeps findings", "HR 0.8 (p<0.05), 10 mg ±2%", "HR 0.8 (p<0.05), 10 mg ±2%"},
+		{"keeps variant notation", "carriers of HLA-B*57:01", "carriers of HLA-B*57:01"},
+		{"normalizes whitespace", "  a \n\t b  ", "a b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanMedicalText(tt.text); got != tt.want {
+				t.Errorf("CleanMedicalText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzCleanMedicalText(f *testing.F) {
+	for _, seed := range []string{
+		"<p>Aspirin <b>reduces</b> risk</p>",
+		"Guillain-Barré and β-blocker",
+		"c.68_69delAG in HLA-B*57:01 carriers",
+		"p<0.05 ±2% ≥ 10 mg",
+		"\xff\xfe invalid",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		got := CleanMedicalText(text)
+		if utf8.ValidString(text) && !utf8.ValidString(got) {
+			t.Errorf("CleanMedicalText(%q) = %q, not valid UTF-8", text, got)
+		}
+		if got != strings.TrimSpace(got) {
+			t.Errorf("CleanMedicalText(%q) = %q, not trimmed", text, got)
+		}
+	})
+}
+
+func FuzzTruncateText(f *testing.F) {
+	f.Add("First sentence here. Second one follows", 30)
+	f.Add("alpha beta gamma delta epsilon", 20)
+	f.Add("ééééééééééé", 6)
+	f.Add("日本語のテキスト。次の文。", 8)
+	f.Add("abc", 0)
+	f.Fuzz(func(t *testing.T, text string, maxLength int) {
+		got := TruncateText(text, maxLength)
+		if utf8.ValidString(text) && !utf8.ValidString(got) {
+			t.Errorf("TruncateText(%q, %d) = %q, not valid UTF-8", text, maxLength, got)
+		}
+		if utf8.RuneCountInString(text) <= maxLength {
+			if got != text {
+				t.Errorf("TruncateText(%q, %d) = %q, want the text unchanged", text, maxLength, got)
+			}
+		} else if n := utf8.RuneCountInString(got); n > max(maxLength, 0) {
+			t.Errorf("TruncateText(%q, %d) = %q, %d runes", text, maxLength, got, n)
+		}
+	})
+}
@@ -1,16 +1,20 @@
 package data
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"MedAtlasAIServer/internal/apperrors"
 	"MedAtlasAIServer/internal/models"
 )
 
@@ -18,104 +22,302 @@ type PubMedClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	BatchSize  int
-	Delay      time.Duration
+	APIKey     string       // NCBI API key, sent with every request
+	Limiter    *TokenBucket // paces every E-utilities request
+	Workers    int          // EFetch batches requested concurrently
 }
 
+// NewPubMedClient returns a client using the API key in NCBI_API_KEY, if
+// any, paced to the rate NCBI allows for it: 3 requests a second without a
+// key and 10 with one
 func NewPubMedClient() *PubMedClient {
+	apiKey := strings.TrimSpace(os.Getenv("NCBI_API_KEY"))
+	rate := EutilsRate
+	if apiKey != "" {
+		rate = EutilsRateWithKey
+	}
 	return &PubMedClient{
 		BaseURL:    "https://eutils.ncbi.nlm.nih.gov/entrez/eutils",
 		HTTPClient: &http.Client{Timeout: 30 * time.Second},
-		BatchSize:  100,                    // PubMed API limit per request
-		Delay:      200 * time.Millisecond, // Respect API rate limits
+		BatchSize:  100, // PubMed API limit per request
+		APIKey:     apiKey,
+		// NCBI counts requests over any one second, so the bucket starts
+		// with a second's worth at most
+		Limiter: NewTokenBucket(float64(rate), rate),
+		Workers: rate,
 	}
 }
 
-func (c *PubMedClient) SearchArticles(query string, maxResults int) ([]string, error) {
-	// ESearch: Get article IDs
-	esearchURL := fmt.Sprintf("%s/esearch.fcgi?db=pubmed&term=%s&retmax=%d&retmode=xml",
-		c.BaseURL, url.QueryEscape(query), maxResults)
+func (c *PubMedClient) SearchArticles(ctx context.Context, query string, maxResults int) ([]string, error) {
+	params := url.Values{}
+	params.Set("term", query)
+	params.Set("retmax", strconv.Itoa(maxResults))
+	result, err := c.esearch(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return result.IdList.IDs, nil
+}
 
-	resp, err := c.HTTPClient.Get(esearchURL)
+// eutilsDate is the date format of ESearch's mindate and maxdate
+const eutilsDate = "2006/01/02"
+
+// maxESearchPage is the most IDs ESearch returns per request
+const maxESearchPage = 10000
+
+// SearchArticlesAddedSince returns the IDs of up to maxResults articles
+// matching query whose Entrez date (when PubMed added them) falls between
+// since and until, both inclusive and with day precision. It also returns
+// how many articles matched in total, so callers can tell when maxResults
+// cut the window short.
+func (c *PubMedClient) SearchArticlesAddedSince(ctx context.Context, query string, since, until time.Time, maxResults int) ([]string, int, error) {
+	var ids []string
+	total := 0
+	for len(ids) < maxResults {
+		params := url.Values{}
+		params.Set("term", query)
+		params.Set("datetype", "edat")
+		params.Set("mindate", since.Format(eutilsDate))
+		params.Set("maxdate", until.Format(eutilsDate))
+		params.Set("retstart", strconv.Itoa(len(ids)))
+		params.Set("retmax", strconv.Itoa(min(maxResults-len(ids), maxESearchPage)))
+		result, err := c.esearch(ctx, params)
+		if err != nil {
+			return ids, total, err
+		}
+		total = result.Count
+		ids = append(ids, result.IdList.IDs...)
+		if len(result.IdList.IDs) == 0 || len(ids) >= total {
+			break
+		}
+	}
+	return ids, total, nil
+}
+
+type esearchResult struct {
+	Count    int    `xml:"Count"`
+	WebEnv   string `xml:"WebEnv"`
+	QueryKey string `xml:"QueryKey"`
+	IdList   struct {
+		IDs []string `xml:"Id"`
+	} `xml:"IdList"`
+}
+
+// SearchHistory is a search kept on the E-utilities history server, whose
+// records can be fetched page by page, across runs, until NCBI expires it
+// (after some hours)
+type SearchHistory struct {
+	WebEnv   string `json:"web_env"`
+	QueryKey string `json:"query_key"`
+	Count    int    `json:"count"` // records matching the search
+}
+
+// SearchToHistory runs query on the history server. With non-zero since
+// and until, only articles whose Entrez date falls between them, both
+// inclusive, match.
+func (c *PubMedClient) SearchToHistory(ctx context.Context, query string, since, until time.Time) (*SearchHistory, error) {
+	params := url.Values{}
+	params.Set("term", query)
+	params.Set("usehistory", "y")
+	params.Set("retmax", "0")
+	if !since.IsZero() && !until.IsZero() {
+		params.Set("datetype", "edat")
+		params.Set("mindate", since.Format(eutilsDate))
+		params.Set("maxdate", until.Format(eutilsDate))
+	}
+	result, err := c.esearch(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("ESearch request failed: %w", err)
+		return nil, err
+	}
+	if result.WebEnv == "" || result.QueryKey == "" {
+		return nil, fmt.Errorf("ESearch returned no history for %q", query)
+	}
+	return &SearchHistory{WebEnv: result.WebEnv, QueryKey: result.QueryKey, Count: result.Count}, nil
+}
+
+// FetchFromHistory fetches up to count records of history, starting at the
+// zero-based offset start
+func (c *PubMedClient) FetchFromHistory(ctx context.Context, history SearchHistory, start, count int) ([]models.PubMedArticle, error) {
+	params := url.Values{}
+	params.Set("db", "pubmed")
+	params.Set("WebEnv", history.WebEnv)
+	params.Set("query_key", history.QueryKey)
+	params.Set("retstart", strconv.Itoa(start))
+	params.Set("retmax", strconv.Itoa(count))
+	params.Set("retmode", "xml")
+	resp, err := c.get(ctx, "efetch.fcgi", params)
+	if err != nil {
+		return nil, fmt.Errorf("EFetch request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if err := checkEutilsStatus(resp); err != nil {
+		return nil, fmt.Errorf("EFetch: %w", err)
+	}
+	body, err := readEutilsBody(resp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ESearch response: %w", err)
+		return nil, fmt.Errorf("failed to read EFetch response: %w", err)
+	}
+	return ParseEFetchXML(body)
+}
+
+// esearch runs an ESearch request against the pubmed database
+func (c *PubMedClient) esearch(ctx context.Context, params url.Values) (*esearchResult, error) {
+	params.Set("db", "pubmed")
+	params.Set("retmode", "xml")
+	resp, err := c.get(ctx, "esearch.fcgi", params)
+	if err != nil {
+		return nil, fmt.Errorf("ESearch request failed: %w", err)
 	}
+	defer resp.Body.Close()
 
-	var esearchResult struct {
-		IdList struct {
-			IDs []string `xml:"Id"`
-		} `xml:"IdList"`
+	if err := checkEutilsStatus(resp); err != nil {
+		return nil, fmt.Errorf("ESearch: %w", err)
 	}
 
-	if err := xml.Unmarshal(body, &esearchResult); err != nil {
-		return nil, fmt.Errorf("failed to parse ESearch XML: %w", err)
+	body, err := readEutilsBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ESearch response: %w", err)
 	}
 
-	return esearchResult.IdList.IDs, nil
+	var result esearchResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse ESearch XML: %w", err)
+	}
+	return &result, nil
 }
 
-func (c *PubMedClient) FetchArticleDetails(articleIDs []string) ([]models.PubMedArticle, error) {
-	var allArticles []models.PubMedArticle
+// get sends an E-utilities request once the limiter allows it, adding the
+// API key to params
+func (c *PubMedClient) get(ctx context.Context, endpoint string, params url.Values) (*http.Response, error) {
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		params.Set("api_key", c.APIKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/"+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.HTTPClient.Do(req)
+}
 
-	// Process in batches to respect API limits
-	for i := 0; i < len(articleIDs); i += c.BatchSize {
-		end := i + c.BatchSize
-		if end > len(articleIDs) {
-			end = len(articleIDs)
-		}
+// FetchArticleDetails fetches the records of articleIDs in batches of
+// BatchSize, up to Workers batches at a time as the limiter allows, and
+// returns them in the order of articleIDs. Failed batches are logged and
+// skipped. When ctx is cancelled it stops requesting batches and returns
+// the records fetched with ctx's error.
+func (c *PubMedClient) FetchArticleDetails(ctx context.Context, articleIDs []string) ([]models.PubMedArticle, error) {
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	var batches [][]string
+	for i := 0; i < len(articleIDs); i += batchSize {
+		batches = append(batches, articleIDs[i:min(i+batchSize, len(articleIDs))])
+	}
 
-		batchIDs := articleIDs[i:end]
-		articles, err := c.fetchBatch(batchIDs)
-		if err != nil {
-			log.Printf("Failed to fetch batch %d-%d: %v", i, end, err)
-			continue
+	results := make([][]models.PubMedArticle, len(batches))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(c.Workers, 1), len(batches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				articles, err := c.fetchBatch(ctx, batches[i])
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("Failed to fetch batch %d-%d: %v", i*batchSize, i*batchSize+len(batches[i]), err)
+					}
+					continue
+				}
+				results[i] = articles
+			}
+		}()
+	}
+	for i := range batches {
+		if ctx.Err() != nil {
+			break
 		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
 
+	var allArticles []models.PubMedArticle
+	for _, articles := range results {
 		allArticles = append(allArticles, articles...)
-
-		// Respect API rate limits
-		if i+c.BatchSize < len(articleIDs) {
-			time.Sleep(c.Delay)
-		}
 	}
-
-	return allArticles, nil
+	return allArticles, ctx.Err()
 }
 
-func (c *PubMedClient) fetchBatch(articleIDs []string) ([]models.PubMedArticle, error) {
+func (c *PubMedClient) fetchBatch(ctx context.Context, articleIDs []string) ([]models.PubMedArticle, error) {
 	if len(articleIDs) == 0 {
 		return nil, nil
 	}
 
-	idParam := strings.Join(articleIDs, ",")
-	efetchURL := fmt.Sprintf("%s/efetch.fcgi?db=pubmed&id=%s&retmode=xml",
-		c.BaseURL, idParam)
-
-	resp, err := c.HTTPClient.Get(efetchURL)
+	params := url.Values{}
+	params.Set("db", "pubmed")
+	params.Set("id", strings.Join(articleIDs, ","))
+	params.Set("retmode", "xml")
+	resp, err := c.get(ctx, "efetch.fcgi", params)
 	if err != nil {
 		return nil, fmt.Errorf("EFetch request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if err := checkEutilsStatus(resp); err != nil {
+		return nil, fmt.Errorf("EFetch: %w", err)
+	}
+
+	body, err := readEutilsBody(resp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read EFetch response: %w", err)
 	}
+	return ParseEFetchXML(body)
+}
 
-	// Parse XML response
+// ParseEFetchXML parses a PubmedArticleSet document as returned by EFetch
+func ParseEFetchXML(body []byte) ([]models.PubMedArticle, error) {
 	var result models.PubMedResult
 	if err := xml.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse EFetch XML: %w", err)
 	}
-
 	return result.Articles, nil
 }
 
+// maxEutilsResponseBytes bounds how much of an E-utilities response is
+// read. A batch of 100 full records is a few megabytes.
+const maxEutilsResponseBytes = 64 << 20
+
+// readEutilsBody reads resp's body, refusing responses over the size bound
+func readEutilsBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEutilsResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxEutilsResponseBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxEutilsResponseBytes)
+	}
+	return body, nil
+}
+
+// checkEutilsStatus converts non-200 E-utilities responses into errors
+func checkEutilsStatus(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: E-utilities returned %s", apperrors.ErrRateLimited, resp.Status)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: E-utilities returned %s", apperrors.ErrNotFound, resp.Status)
+	default:
+		return fmt.Errorf("E-utilities returned %s", resp.Status)
+	}
+}
+
 func (c *PubMedClient) NormalizeArticle(pubmedArticle models.PubMedArticle) models.MedicalArticle {
 	article := pubmedArticle.MedlineCitation.Article
 
@@ -185,6 +387,7 @@ func (c *PubMedClient) NormalizeArticle(pubmedArticle models.PubMedArticle) mode
 		MeshHeadings:     meshHeadings,
 		PublicationTypes: pubTypes,
 		Affiliation:      getFirstAffiliation(article.AuthorList.Authors),
+		Affiliations:     getAffiliations(article.AuthorList.Authors),
 	}
 }
 
@@ -217,16 +420,28 @@ func parsePubMedDate(yearStr, monthStr, dayStr string) time.Time {
 	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
 }
 
-func getFirstAffiliation(authors []struct {
-	LastName    string `xml:"LastName"`
-	ForeName    string `xml:"ForeName"`
-	Initials    string `xml:"Initials"`
-	Affiliation string `xml:"Affiliation"`
-}) string {
+func getFirstAffiliation(authors []models.PubMedAuthor) string {
 	for _, author := range authors {
-		if author.Affiliation != "" {
-			return author.Affiliation
+		if affiliations := author.Affiliations(); len(affiliations) > 0 {
+			return affiliations[0]
 		}
 	}
 	return ""
 }
+
+// getAffiliations returns the distinct affiliations of all authors in order
+func getAffiliations(authors []models.PubMedAuthor) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, author := range authors {
+		for _, affiliation := range author.Affiliations() {
+			affiliation = strings.TrimSpace(affiliation)
+			if affiliation == "" || seen[affiliation] {
+				continue
+			}
+			seen[affiliation] = true
+			result = append(result, affiliation)
+		}
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+const efetchSample = `<?xml version="1.0"?>
+<PubmedArticleSet>
+  <PubmedArticle>
+    <MedlineCitation>
+      <PMID>12345678</PMID>
+      <Article>
+        <Journal><Title>The Journal</Title><ISOAbbreviation>J</ISOAbbreviation></Journal>
+        <ArticleTitle>Statins and <i>dementia</i></ArticleTitle>
+        <Abstract>
+          <AbstractText Label="BACKGROUND">Background text.</AbstractText>
+          <AbstractText Label="RESULTS">OR 0.8 (95% CI 0.7-0.9).</AbstractText>
+        </Abstract>
+        <AuthorList><Author><LastName>Doe</LastName><ForeName>Jane</ForeName><Initials>J</Initials></Author></AuthorList>
+        <PublicationTypeList><PublicationType>Journal Article</PublicationType></PublicationTypeList>
+      </Article>
+      <ArticleDate><Year>2023</Year><Month>02</Month><Day>30</Day></ArticleDate>
+    </MedlineCitation>
+    <PubmedData>
+      <ArticleIdList><ArticleId IdType="doi">10.1000/xyz123</ArticleId></ArticleIdList>
+    </PubmedData>
+  </PubmedArticle>
+</PubmedArticleSet>`
+
+func FuzzParseEFetchXML(f *testing.F) {
+	f.Add([]byte(efetchSample))
+	f.Add([]byte(`<PubmedArticleSet></PubmedArticleSet>`))
+	f.Add([]byte(`<PubmedArticleSet><PubmedArticle><MedlineCitation><Article><AuthorList><Author/></AuthorList></Article></MedlineCitation></PubmedArticle></PubmedArticleSet>`))
+	f.Add([]byte(`<PubmedArticleSet><PubmedArticle><MedlineCitation><ArticleDate><Year>99999</Year><Month>Feb</Month></ArticleDate></MedlineCitation></PubmedArticle></PubmedArticleSet>`))
+	f.Add([]byte(`<eSearchResult><ERROR>Invalid query</ERROR></eSearchResult>`))
+	client := NewPubMedClient()
+	f.Fuzz(func(t *testing.T, body []byte) {
+		articles, err := ParseEFetchXML(body)
+		if err != nil {
+			return
+		}
+		for _, article := range articles {
+			normalized := client.NormalizeArticle(article)
+			if utf8.Valid(body) && !utf8.ValidString(normalized.Abstract) {
+				t.Errorf("abstract %q is not valid UTF-8", normalized.Abstract)
+			}
+		}
+	})
+}
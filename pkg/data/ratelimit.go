@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// E-utilities request rates NCBI allows per second, without and with an
+// API key
+const (
+	EutilsRate        = 3
+	EutilsRateWithKey = 10
+)
+
+// TokenBucket paces requests to an upstream API: it holds up to burst
+// tokens, refills perSec of them a second, and each request takes one. It
+// is safe for concurrent use.
+type TokenBucket struct {
+	mu     sync.Mutex
+	perSec float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full bucket
+func NewTokenBucket(perSec float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{perSec: perSec, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait takes a token, sleeping until one is available. It returns ctx's
+// error if ctx is done first. A nil bucket never waits.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b == nil || b.perSec <= 0 {
+		return ctx.Err()
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSec)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return ctx.Err()
+		}
+		wait := time.Duration((1 - b.tokens) / b.perSec * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
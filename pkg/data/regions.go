@@ -0,0 +1,225 @@
+package data
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Regions used to group evidence geographically (World Bank groupings)
+const (
+	RegionEastAsiaPacific    = "East Asia & Pacific"
+	RegionEuropeCentralAsia  = "Europe & Central Asia"
+	RegionLatinAmerica       = "Latin America & Caribbean"
+	RegionMiddleEastNorthAfr = "Middle East & North Africa"
+	RegionNorthAmerica       = "North America"
+	RegionSouthAsia          = "South Asia"
+	RegionSubSaharanAfrica   = "Sub-Saharan Africa"
+	RegionUnknown            = "Unknown"
+)
+
+// country describes one country: its region and the names and demonyms
+// that identify it in affiliations and abstracts
+type country struct {
+	name    string
+	region  string
+	aliases []string
+}
+
+var countries = []country{
+	{"United States", RegionNorthAmerica, []string{"United States", "USA", "U.S.A.", "U.S."}},
+	{"Canada", RegionNorthAmerica, []string{"Canada", "Canadian"}},
+	{"Mexico", RegionLatinAmerica, []string{"Mexico", "Mexican"}},
+	{"Brazil", RegionLatinAmerica, []string{"Brazil", "Brasil", "Brazilian"}},
+	{"Argentina", RegionLatinAmerica, []string{"Argentina", "Argentinian", "Argentine"}},
+	{"Chile", RegionLatinAmerica, []string{"Chile", "Chilean"}},
+	{"Colombia", RegionLatinAmerica, []string{"Colombia", "Colombian"}},
+	{"Peru", RegionLatinAmerica, []string{"Peru", "Peruvian"}},
+	{"Cuba", RegionLatinAmerica, []string{"Cuba", "Cuban"}},
+	{"United Kingdom", RegionEuropeCentralAsia, []string{"United Kingdom", "UK", "U.K.", "England", "Scotland", "Wales", "Northern Ireland", "British"}},
+	{"Ireland", RegionEuropeCentralAsia, []string{"Ireland", "Irish"}},
+	{"France", RegionEuropeCentralAsia, []string{"France", "French"}},
+	{"Germany", RegionEuropeCentralAsia, []string{"Germany", "German"}},
+	{"Italy", RegionEuropeCentralAsia, []string{"Italy", "Italian"}},
+	{"Spain", RegionEuropeCentralAsia, []string{"Spain", "Spanish"}},
+	{"Portugal", RegionEuropeCentralAsia, []string{"Portugal", "Portuguese"}},
+	{"Netherlands", RegionEuropeCentralAsia, []string{"Netherlands", "The Netherlands", "Dutch"}},
+	{"Belgium", RegionEuropeCentralAsia, []string{"Belgium", "Belgian"}},
+	{"Switzerland", RegionEuropeCentralAsia, []string{"Switzerland", "Swiss"}},
+	{"Austria", RegionEuropeCentralAsia, []string{"Austria", "Austrian"}},
+	{"Sweden", RegionEuropeCentralAsia, []string{"Sweden", "Swedish"}},
+	{"Norway", RegionEuropeCentralAsia, []string{"Norway", "Norwegian"}},
+	{"Denmark", RegionEuropeCentralAsia, []string{"Denmark", "Danish"}},
+	{"Finland", RegionEuropeCentralAsia, []string{"Finland", "Finnish"}},
+	{"Poland", RegionEuropeCentralAsia, []string{"Poland", "Polish"}},
+	{"Greece", RegionEuropeCentralAsia, []string{"Greece", "Greek"}},
+	{"Turkey", RegionEuropeCentralAsia, []string{"Turkey", "Türkiye", "Turkish"}},
+	{"Russia", RegionEuropeCentralAsia, []string{"Russia", "Russian Federation", "Russian"}},
+	{"China", RegionEastAsiaPacific, []string{"China", "P.R. China", "PR China", "Chinese"}},
+	{"Japan", RegionEastAsiaPacific, []string{"Japan", "Japanese"}},
+	{"South Korea", RegionEastAsiaPacific, []string{"South Korea", "Republic of Korea", "Korea", "Korean"}},
+	{"Taiwan", RegionEastAsiaPacific, []string{"Taiwan", "Taiwanese"}},
+	{"Australia", RegionEastAsiaPacific, []string{"Australia", "Australian"}},
+	{"New Zealand", RegionEastAsiaPacific, []string{"New Zealand"}},
+	{"Singapore", RegionEastAsiaPacific, []string{"Singapore", "Singaporean"}},
+	{"Thailand", RegionEastAsiaPacific, []string{"Thailand", "Thai"}},
+	{"Vietnam", RegionEastAsiaPacific, []string{"Vietnam", "Viet Nam", "Vietnamese"}},
+	{"Malaysia", RegionEastAsiaPacific, []string{"Malaysia", "Malaysian"}},
+	{"Indonesia", RegionEastAsiaPacific, []string{"Indonesia", "Indonesian"}},
+	{"Philippines", RegionEastAsiaPacific, []string{"Philippines", "Filipino"}},
+	{"India", RegionSouthAsia, []string{"India", "Indian"}},
+	{"Pakistan", RegionSouthAsia, []string{"Pakistan", "Pakistani"}},
+	{"Bangladesh", RegionSouthAsia, []string{"Bangladesh", "Bangladeshi"}},
+	{"Nepal", RegionSouthAsia, []string{"Nepal", "Nepalese", "Nepali"}},
+	{"Sri Lanka", RegionSouthAsia, []string{"Sri Lanka", "Sri Lankan"}},
+	{"Iran", RegionMiddleEastNorthAfr, []string{"Iran", "Iranian"}},
+	{"Israel", RegionMiddleEastNorthAfr, []string{"Israel", "Israeli"}},
+	{"Saudi Arabia", RegionMiddleEastNorthAfr, []string{"Saudi Arabia", "Saudi"}},
+	{"Egypt", RegionMiddleEastNorthAfr, []string{"Egypt", "Egyptian"}},
+	{"Lebanon", RegionMiddleEastNorthAfr, []string{"Lebanon", "Lebanese"}},
+	{"Jordan", RegionMiddleEastNorthAfr, []string{"Jordan", "Jordanian"}},
+	{"Qatar", RegionMiddleEastNorthAfr, []string{"Qatar", "Qatari"}},
+	{"United Arab Emirates", RegionMiddleEastNorthAfr, []string{"United Arab Emirates", "UAE", "Emirati"}},
+	{"Morocco", RegionMiddleEastNorthAfr, []string{"Morocco", "Moroccan"}},
+	{"Tunisia", RegionMiddleEastNorthAfr, []string{"Tunisia", "Tunisian"}},
+	{"Nigeria", RegionSubSaharanAfrica, []string{"Nigeria", "Nigerian"}},
+	{"South Africa", RegionSubSaharanAfrica, []string{"South Africa", "South African"}},
+	{"Kenya", RegionSubSaharanAfrica, []string{"Kenya", "Kenyan"}},
+	{"Ethiopia", RegionSubSaharanAfrica, []string{"Ethiopia", "Ethiopian"}},
+	{"Ghana", RegionSubSaharanAfrica, []string{"Ghana", "Ghanaian"}},
+	{"Uganda", RegionSubSaharanAfrica, []string{"Uganda", "Ugandan"}},
+	{"Tanzania", RegionSubSaharanAfrica, []string{"Tanzania", "Tanzanian"}},
+	{"Malawi", RegionSubSaharanAfrica, []string{"Malawi", "Malawian"}},
+	{"Rwanda", RegionSubSaharanAfrica, []string{"Rwanda", "Rwandan"}},
+	{"Cameroon", RegionSubSaharanAfrica, []string{"Cameroon", "Cameroonian"}},
+}
+
+// RegionCentroids are representative [longitude, latitude] points for
+// plotting regions on a map
+var RegionCentroids = map[string][2]float64{
+	RegionEastAsiaPacific:    {125.0, 15.0},
+	RegionEuropeCentralAsia:  {30.0, 52.0},
+	RegionLatinAmerica:       {-65.0, -10.0},
+	RegionMiddleEastNorthAfr: {35.0, 28.0},
+	RegionNorthAmerica:       {-100.0, 45.0},
+	RegionSouthAsia:          {78.0, 22.0},
+	RegionSubSaharanAfrica:   {22.0, -2.0},
+}
+
+// countryAlias is one compiled alias pattern
+type countryAlias struct {
+	alias   string
+	pattern *regexp.Regexp
+	country *country
+}
+
+var (
+	countryAliasesOnce sync.Once
+	countryAliases     []countryAlias
+	regionByCountry    map[string]string
+)
+
+// compiledCountries builds the alias patterns, longest alias first so
+// "South Korea" wins over "Korea" and "Northern Ireland" over "Ireland"
+func compiledCountries() []countryAlias {
+	countryAliasesOnce.Do(func() {
+		regionByCountry = make(map[string]string, len(countries))
+		for i := range countries {
+			c := &countries[i]
+			regionByCountry[c.name] = c.region
+			for _, alias := range c.aliases {
+				pattern := `\b` + regexp.QuoteMeta(alias)
+				if !strings.HasSuffix(alias, ".") {
+					pattern += `\b`
+				}
+				countryAliases = append(countryAliases, countryAlias{
+					alias:   alias,
+					pattern: regexp.MustCompile(pattern),
+					country: c,
+				})
+			}
+		}
+		sort.SliceStable(countryAliases, func(i, j int) bool {
+			return len(countryAliases[i].alias) > len(countryAliases[j].alias)
+		})
+	})
+	return countryAliases
+}
+
+// ExtractCountries finds the countries named in texts (affiliations first,
+// then the abstract, where demonyms such as "Japanese adults" describe the
+// study population). Countries are returned in order of first mention.
+func ExtractCountries(texts ...string) []string {
+	type hit struct {
+		country string
+		order   int
+	}
+	first := make(map[string]int)
+	offset := 0
+	for _, text := range texts {
+		// Blank out each match so a shorter alias cannot match inside it
+		masked := []byte(text)
+		for _, alias := range compiledCountries() {
+			for _, loc := range alias.pattern.FindAllIndex(masked, -1) {
+				if _, seen := first[alias.country.name]; !seen || offset+loc[0] < first[alias.country.name] {
+					first[alias.country.name] = offset + loc[0]
+				}
+				for i := loc[0]; i < loc[1]; i++ {
+					masked[i] = ' '
+				}
+			}
+		}
+		offset += len(text) + 1
+	}
+
+	hits := make([]hit, 0, len(first))
+	for name, order := range first {
+		hits = append(hits, hit{name, order})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].order < hits[j].order })
+	result := make([]string, len(hits))
+	for i, h := range hits {
+		result[i] = h.country
+	}
+	return result
+}
+
+// RegionsForCountries maps countries to their regions, without duplicates
+func RegionsForCountries(names []string) []string {
+	compiledCountries()
+	seen := make(map[string]bool)
+	var regions []string
+	for _, name := range names {
+		region, ok := regionByCountry[name]
+		if !ok || seen[region] {
+			continue
+		}
+		seen[region] = true
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// studyPopulationText joins the text that can name where a study took place
+func studyPopulationText(affiliations []string, title, abstract string) []string {
+	texts := make([]string, 0, len(affiliations)+2)
+	for _, affiliation := range affiliations {
+		if strings.TrimSpace(affiliation) != "" {
+			texts = append(texts, affiliation)
+		}
+	}
+	return append(texts, title, abstract)
+}
+
+// RegionCountries returns the countries in names that belong to region
+func RegionCountries(region string, names []string) []string {
+	compiledCountries()
+	var result []string
+	for _, name := range names {
+		if regionByCountry[name] == region {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SectionsCollection holds one point per section of full-text articles, so
+// methods, results and conclusions can be searched on their own. Points are
+// keyed by SectionPointID and carry the article's "id" and "title" with the
+// section's "section", "heading" and "text".
+const SectionsCollection = "article_sections"
+
+// Section kinds of full-text articles
+const (
+	SectionIntroduction = "introduction"
+	SectionMethods      = "methods"
+	SectionResults      = "results"
+	SectionDiscussion   = "discussion"
+	SectionConclusions  = "conclusions"
+	SectionOther        = "other"
+)
+
+// sectionKeywords map heading words to kinds, checked in order so
+// "Results and Discussion" counts as results
+var sectionKeywords = []struct {
+	keyword string
+	kind    string
+}{
+	{"conclusion", SectionConclusions},
+	{"result", SectionResults},
+	{"finding", SectionResults},
+	{"method", SectionMethods},
+	{"material", SectionMethods},
+	{"study design", SectionMethods},
+	{"patients and", SectionMethods},
+	{"discussion", SectionDiscussion},
+	{"introduction", SectionIntroduction},
+	{"background", SectionIntroduction},
+	{"intro", SectionIntroduction},
+}
+
+// ClassifySection returns the kind of a section from its JATS sec-type
+// (e.g. "materials|methods") or, failing that, its heading
+func ClassifySection(secType, heading string) string {
+	for _, label := range []string{secType, heading} {
+		lower := strings.ToLower(label)
+		for _, entry := range sectionKeywords {
+			if strings.Contains(lower, entry.keyword) {
+				return entry.kind
+			}
+		}
+	}
+	return SectionOther
+}
+
+// SectionPointID is the point ID of the index-th section of an article. The
+// key starts with a letter so numeric PMIDs are hashed rather than parsed.
+func SectionPointID(articleID string, index int) uint64 {
+	return PointID(fmt.Sprintf("section-%d:%s", index, articleID))
+}
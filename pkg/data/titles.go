@@ -0,0 +1,43 @@
+package data
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeTitle reduces a title to lowercase words so that records of the
+// same study that differ only in case, punctuation or spacing compare equal
+func NormalizeTitle(title string) string {
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// TitleSimilarity is the Jaccard similarity of the words in two normalized titles
+func TitleSimilarity(a, b string) float64 {
+	wordsA := strings.Fields(a)
+	wordsB := strings.Fields(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(wordsA))
+	for _, w := range wordsA {
+		set[w] = true
+	}
+	union := len(set)
+	shared := 0
+	seenB := make(map[string]bool, len(wordsB))
+	for _, w := range wordsB {
+		if seenB[w] {
+			continue
+		}
+		seenB[w] = true
+		if set[w] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,22 @@
+package data
+
+import "regexp"
+
+// nctPattern matches ClinicalTrials.gov registration numbers such as
+// NCT01234567, including the "NCT 01234567" spacing seen in some abstracts
+var nctPattern = regexp.MustCompile(`(?i)\bNCT\s?(\d{8})\b`)
+
+// ExtractNCTIDs returns the distinct trial registration numbers in text, in
+// canonical NCT########-form and order of first mention
+func ExtractNCTIDs(text string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, match := range nctPattern.FindAllStringSubmatch(text, -1) {
+		id := "NCT" + match[1]
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
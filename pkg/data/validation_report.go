@@ -0,0 +1,138 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxReasonSamples caps how many article IDs are kept per reject reason
+const maxReasonSamples = 10
+
+// ReasonStats holds the number of rejections for one reason and a few example IDs
+type ReasonStats struct {
+	Count     int      `json:"count"`
+	SampleIDs []string `json:"sample_ids"`
+}
+
+// MissingDocument is an article an indexing run sent to Qdrant that is not
+// there. Reason is "upload_failed" when its batch was given up on, or
+// "not_found" when the batch succeeded but the point cannot be retrieved.
+type MissingDocument struct {
+	ID         string `json:"id"`
+	Collection string `json:"collection"`
+	Reason     string `json:"reason"`
+}
+
+// Reconciliation compares the articles a run uploaded with what Qdrant
+// holds. Checked counts the uploaded points looked up by ID.
+type Reconciliation struct {
+	Uploaded int               `json:"uploaded"`
+	Checked  int               `json:"checked"`
+	Missing  []MissingDocument `json:"missing"`
+}
+
+// ValidationReport aggregates validation outcomes for a single indexing run.
+// It is safe for concurrent use.
+type ValidationReport struct {
+	mu         sync.Mutex
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at,omitempty"`
+	Accepted   int                     `json:"accepted"`
+	Rejected   int                     `json:"rejected"`
+	Reasons    map[string]*ReasonStats `json:"reasons"`
+
+	Reconciliation *Reconciliation `json:"reconciliation,omitempty"`
+}
+
+func NewValidationReport() *ValidationReport {
+	return &ValidationReport{
+		StartedAt: Clock.Now(),
+		Reasons:   make(map[string]*ReasonStats),
+	}
+}
+
+// RecordAccepted counts an article that passed validation
+func (r *ValidationReport) RecordAccepted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Accepted++
+}
+
+// RecordRejected counts an article rejected for the given reason
+func (r *ValidationReport) RecordRejected(articleID, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Rejected++
+	stats, ok := r.Reasons[reason]
+	if !ok {
+		stats = &ReasonStats{}
+		r.Reasons[reason] = stats
+	}
+	stats.Count++
+	if len(stats.SampleIDs) < maxReasonSamples {
+		stats.SampleIDs = append(stats.SampleIDs, articleID)
+	}
+}
+
+// Finish marks the end of the run
+func (r *ValidationReport) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = Clock.Now()
+}
+
+// AddReconciliation merges the outcome of checking one set of uploads
+func (r *ValidationReport) AddReconciliation(rc Reconciliation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Reconciliation == nil {
+		r.Reconciliation = &Reconciliation{Missing: []MissingDocument{}}
+	}
+	r.Reconciliation.Uploaded += rc.Uploaded
+	r.Reconciliation.Checked += rc.Checked
+	r.Reconciliation.Missing = append(r.Reconciliation.Missing, rc.Missing...)
+}
+
+// Snapshot returns a copy of the report that can be read without locking
+func (r *ValidationReport) Snapshot() *ValidationReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := &ValidationReport{
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		Accepted:   r.Accepted,
+		Rejected:   r.Rejected,
+		Reasons:    make(map[string]*ReasonStats, len(r.Reasons)),
+	}
+	for reason, stats := range r.Reasons {
+		snapshot.Reasons[reason] = &ReasonStats{
+			Count:     stats.Count,
+			SampleIDs: append([]string(nil), stats.SampleIDs...),
+		}
+	}
+	if r.Reconciliation != nil {
+		rc := *r.Reconciliation
+		rc.Missing = append([]MissingDocument{}, rc.Missing...)
+		snapshot.Reconciliation = &rc
+	}
+	return snapshot
+}
+
+// WriteJSON writes the report to path, creating parent directories as needed
+func (r *ValidationReport) WriteJSON(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	jsonData, err := json.MarshalIndent(r.Snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return os.WriteFile(path, jsonData, 0644)
+}
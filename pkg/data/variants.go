@@ -0,0 +1,227 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hgvsPattern matches HGVS sequence variants such as "c.68_69delAG",
+// "c.1799T>A", "p.V600E", "p.(Val600Glu)" or "c.[76A>C;83G>C]", optionally
+// qualified by a reference sequence ("NM_007294.3:") or a gene ("BRCA1:")
+var hgvsPattern = regexp.MustCompile(
+	`(?:\b(?:[A-Z]{2}_\d+(?:\.\d+)?(?:\([A-Za-z0-9-]+\))?|[A-Z][A-Z0-9-]{1,9}):)?` + // reference or gene
+		`\b[cgmnopr]\.` + // coordinate type
+		`(?:\([^\s()]+\)|\[[^\s\]]+\]|[A-Za-z0-9_*+>?=-])+`) // change
+
+// starAllelePattern matches pharmacogenomic and HLA alleles such as
+// "CYP2D6*4" or "HLA-B*57:01"
+var starAllelePattern = regexp.MustCompile(`\b[A-Z][A-Z0-9-]{1,9}\*\d+[A-Z]?(?::\d+)*`)
+
+// rsPattern matches dbSNP identifiers
+var rsPattern = regexp.MustCompile(`\brs\d{3,}\b`)
+
+// coordinatePrefix finds the start of the change in an HGVS variant
+var coordinatePrefix = regexp.MustCompile(`\b[cgmnopr]\.`)
+
+// geneSymbols are gene symbols common in the clinical literature, including
+// the ones that collide with the abbreviations NormalizeMedicalTerms expands
+// ("PD-1" is not Parkinson's disease)
+var geneSymbols = []string{
+	"ABL1", "AKT1", "ALK", "APC", "APOE", "AR", "ARID1A", "ATM", "BCL2", "BCR",
+	"BRAF", "BRCA1", "BRCA2", "CDH1", "CDK4", "CDK6", "CDKN2A", "CFTR", "CHEK2",
+	"CTLA-4", "CTNNB1", "CYP2C19", "CYP2C9", "CYP2D6", "CYP3A4", "DPYD", "EGFR",
+	"ERBB2", "ESR1", "EZH2", "FBN1", "FGFR1", "FGFR2", "FGFR3", "FLT3", "FMR1",
+	"G6PD", "GBA", "HBB", "HER2", "HFE", "HLA-A", "HLA-B", "HLA-DQ2", "HLA-DRB1",
+	"HTT", "IDH1", "IDH2", "JAK2", "KIT", "KRAS", "LDLR", "MET", "MLH1", "MSH2",
+	"MSH6", "MTHFR", "MYC", "NF1", "NOTCH1", "NPM1", "NRAS", "NTRK1", "PALB2",
+	"PCSK9", "PD-1", "PD-L1", "PDGFRA", "PIK3CA", "PMS2", "PTEN", "RB1", "RET",
+	"ROS1", "SCN5A", "SLCO1B1", "SMAD4", "SMN1", "STK11", "TERT", "TP53", "TPMT",
+	"TSC1", "TSC2", "UGT1A1", "VHL", "VKORC1",
+}
+
+var (
+	genePatternOnce sync.Once
+	genePattern     *regexp.Regexp
+)
+
+// compiledGenePattern matches any of geneSymbols, longest first so
+// "PD-L1" is not read as "PD"
+func compiledGenePattern() *regexp.Regexp {
+	genePatternOnce.Do(func() {
+		symbols := append([]string(nil), geneSymbols...)
+		sort.Slice(symbols, func(i, j int) bool { return len(symbols[i]) > len(symbols[j]) })
+		for i, symbol := range symbols {
+			symbols[i] = regexp.QuoteMeta(symbol)
+		}
+		// Case-sensitive: "MET" and "RET" are genes, "met" and "ret" are not
+		genePattern = regexp.MustCompile(`\b(?:` + strings.Join(symbols, "|") + `)\b`)
+	})
+	return genePattern
+}
+
+// aminoAcids maps three-letter amino acid codes to the one-letter codes
+// variants are stored with
+var aminoAcids = map[string]string{
+	"Ala": "A", "Arg": "R", "Asn": "N", "Asp": "D", "Cys": "C", "Gln": "Q",
+	"Glu": "E", "Gly": "G", "His": "H", "Ile": "I", "Leu": "L", "Lys": "K",
+	"Met": "M", "Phe": "F", "Pro": "P", "Ser": "S", "Thr": "T", "Trp": "W",
+	"Tyr": "Y", "Val": "V", "Ter": "*",
+}
+
+var threeLetterCode = regexp.MustCompile(`Ala|Arg|Asn|Asp|Cys|Gln|Glu|Gly|His|Ile|Leu|Lys|Met|Phe|Pro|Ser|Thr|Trp|Tyr|Val|Ter`)
+
+// notationSpans returns the [start, end) byte ranges of variant notation and
+// gene symbols in text, without overlaps
+func notationSpans(text string) [][]int {
+	var spans [][]int
+	for _, pattern := range []*regexp.Regexp{hgvsPattern, starAllelePattern, rsPattern, compiledGenePattern()} {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			if pattern == hgvsPattern && !variantLike(text[loc[0]:loc[1]]) {
+				continue // "e.g.", page numbers and friends
+			}
+			spans = append(spans, loc)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i][0] != spans[j][0] {
+			return spans[i][0] < spans[j][0]
+		}
+		return spans[i][1] > spans[j][1]
+	})
+
+	kept := spans[:0]
+	end := -1
+	for _, span := range spans {
+		if span[0] >= end {
+			kept = append(kept, span)
+			end = span[1]
+		}
+	}
+	return kept
+}
+
+// variantLike reports whether an HGVS match names an actual change: a
+// position and a letter, as in "c.68_69delAG" or "p.V600E"
+func variantLike(token string) bool {
+	loc := coordinatePrefix.FindStringIndex(token)
+	if loc == nil {
+		return false
+	}
+	change := token[loc[1]:]
+	return strings.ContainsAny(change, "0123456789") &&
+		strings.IndexFunc(change, func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' }) >= 0
+}
+
+// notationPlaceholder is the token protected notation is swapped for. It is
+// lowercase word characters only, so cleaning and abbreviation expansion
+// leave it alone.
+func notationPlaceholder(i int) string {
+	return fmt.Sprintf("zqnotation%dzq", i)
+}
+
+var placeholderPattern = regexp.MustCompile(`zqnotation(\d+)zq`)
+
+// protectNotation swaps variant notation and gene symbols in text for
+// placeholders; restoreNotation puts them back
+func protectNotation(text string) (string, []string) {
+	spans := notationSpans(text)
+	if len(spans) == 0 {
+		return text, nil
+	}
+	var builder strings.Builder
+	tokens := make([]string, 0, len(spans))
+	last := 0
+	for i, span := range spans {
+		builder.WriteString(text[last:span[0]])
+		builder.WriteString(notationPlaceholder(i))
+		tokens = append(tokens, text[span[0]:span[1]])
+		last = span[1]
+	}
+	builder.WriteString(text[last:])
+	return builder.String(), tokens
+}
+
+func restoreNotation(text string, tokens []string) string {
+	if len(tokens) == 0 {
+		return text
+	}
+	text = placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, err := strconv.Atoi(placeholderPattern.FindStringSubmatch(placeholder)[1])
+		if err == nil && i < len(tokens) {
+			return tokens[i]
+		}
+		return placeholder
+	})
+	return text
+}
+
+// ExtractVariants returns the distinct variants named in text in the form
+// they are stored and matched in: without reference sequence or gene
+// prefix, protein changes in one-letter code ("p.(Val600Glu)" -> "p.V600E")
+func ExtractVariants(text string) []string {
+	seen := make(map[string]bool)
+	var variants []string
+	add := func(variant string) {
+		if variant != "" && !seen[variant] {
+			seen[variant] = true
+			variants = append(variants, variant)
+		}
+	}
+	for _, span := range notationSpans(text) {
+		token := text[span[0]:span[1]]
+		switch {
+		case hgvsPattern.MatchString(token):
+			add(NormalizeVariant(token))
+		case starAllelePattern.MatchString(token), rsPattern.MatchString(token):
+			add(token)
+		}
+	}
+	return variants
+}
+
+// NormalizeVariant canonicalizes one HGVS variant for exact matching
+func NormalizeVariant(variant string) string {
+	loc := coordinatePrefix.FindStringIndex(variant)
+	if loc == nil {
+		return variant
+	}
+	variant = variant[loc[0]:]
+	if !strings.HasPrefix(variant, "p.") {
+		return variant
+	}
+	change := strings.TrimSuffix(strings.TrimPrefix(variant[2:], "("), ")")
+	change = threeLetterCode.ReplaceAllStringFunc(change, func(code string) string { return aminoAcids[code] })
+	return "p." + change
+}
+
+// ExtractGeneSymbols returns the distinct gene symbols named in text: known
+// symbols plus genes used to qualify a variant ("BRCA1:c.68_69delAG",
+// "CYP2D6*4")
+func ExtractGeneSymbols(text string) []string {
+	seen := make(map[string]bool)
+	var genes []string
+	add := func(gene string) {
+		if gene != "" && !seen[gene] {
+			seen[gene] = true
+			genes = append(genes, gene)
+		}
+	}
+	for _, span := range notationSpans(text) {
+		token := text[span[0]:span[1]]
+		switch {
+		case compiledGenePattern().FindString(token) == token:
+			add(token)
+		case starAllelePattern.MatchString(token):
+			add(token[:strings.Index(token, "*")])
+		default:
+			if prefix, _, ok := strings.Cut(token, ":"); ok && !strings.Contains(prefix, "_") && !strings.Contains(prefix, ".") {
+				add(prefix)
+			}
+		}
+	}
+	return genes
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"context"
+	"sort"
+
+	"MedAtlasAIServer/pkg/data"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Searcher is the Search method of qdrant.PointsClient
+type Searcher interface {
+	Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error)
+}
+
+// Getter is the Get method of qdrant.PointsClient
+type Getter interface {
+	Get(ctx context.Context, in *qdrant.GetPoints, opts ...grpc.CallOption) (*qdrant.GetResponse, error)
+}
+
+// Chunked adds the passages of long abstracts, in data.ChunksCollection, to
+// searches of Collection and groups their hits back into articles. An
+// article scores its best hit, whether its own point or one of its
+// passages, and is returned once, as its article point. Articles found by
+// a passage only are loaded with Getter. Searches of other collections
+// pass through.
+type Chunked struct {
+	Searcher        Searcher
+	Getter          Getter
+	Collection      string
+	ChunkCollection string
+}
+
+func NewChunked(searcher Searcher, getter Getter, collection string) *Chunked {
+	return &Chunked{Searcher: searcher, Getter: getter, Collection: collection, ChunkCollection: data.ChunksCollection}
+}
+
+// Search implements ai.Searcher
+func (c *Chunked) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	if in.CollectionName != c.Collection {
+		return c.Searcher.Search(ctx, in, opts...)
+	}
+
+	// Both searches return every hit up to the end of the page, which is cut
+	// from the grouped ranking
+	offset := int(in.GetOffset())
+	articles := proto.Clone(in).(*qdrant.SearchPoints)
+	articles.Offset = nil
+	articles.Limit = uint64(offset + int(in.GetLimit()))
+	articleResult, err := c.Searcher.Search(ctx, articles, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := proto.Clone(articles).(*qdrant.SearchPoints)
+	chunks.CollectionName = c.ChunkCollection
+	chunks.WithPayload = &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+		Include: &qdrant.PayloadIncludeSelector{Fields: []string{"parent_id"}},
+	}}
+	chunks.WithVectors = nil
+	chunkResult, err := c.Searcher.Search(ctx, chunks, opts...)
+	if err != nil {
+		// Abstracts too short to chunk are all in the article hits
+		return page(articleResult, offset, int(in.GetLimit())), nil
+	}
+
+	grouped := c.group(ctx, in, articleResult.GetResult(), chunkResult.GetResult(), opts...)
+	return page(&qdrant.SearchResponse{Result: grouped, Time: articleResult.GetTime() + chunkResult.GetTime()}, offset, int(in.GetLimit())), nil
+}
+
+// group merges passage hits into the article hits by parent_id, keeping
+// each article's best score, and loads the articles only passages found
+func (c *Chunked) group(ctx context.Context, in *qdrant.SearchPoints, articleHits, chunkHits []*qdrant.ScoredPoint, opts ...grpc.CallOption) []*qdrant.ScoredPoint {
+	byArticle := make(map[uint64]*qdrant.ScoredPoint, len(articleHits))
+	grouped := make([]*qdrant.ScoredPoint, 0, len(articleHits))
+	for _, hit := range articleHits {
+		hit = proto.Clone(hit).(*qdrant.ScoredPoint)
+		byArticle[hit.GetId().GetNum()] = hit
+		grouped = append(grouped, hit)
+	}
+
+	missing := make(map[uint64]float32)
+	var missingIDs []*qdrant.PointId
+	for _, hit := range chunkHits {
+		parent := hit.Payload["parent_id"].GetStringValue()
+		if parent == "" {
+			continue
+		}
+		id := data.PointID(parent)
+		if article, ok := byArticle[id]; ok {
+			article.Score = max(article.Score, hit.Score)
+			continue
+		}
+		if score, ok := missing[id]; ok {
+			missing[id] = max(score, hit.Score)
+			continue
+		}
+		missing[id] = hit.Score
+		missingIDs = append(missingIDs, &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: id}})
+	}
+
+	if len(missingIDs) > 0 && c.Getter != nil {
+		resp, err := c.Getter.Get(ctx, &qdrant.GetPoints{
+			CollectionName: c.Collection,
+			Ids:            missingIDs,
+			WithPayload:    in.WithPayload,
+			WithVectors:    in.WithVectors,
+		}, opts...)
+		if err == nil {
+			for _, point := range resp.GetResult() {
+				grouped = append(grouped, &qdrant.ScoredPoint{
+					Id:      point.Id,
+					Payload: point.Payload,
+					Score:   missing[point.GetId().GetNum()],
+					Vectors: point.Vectors,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].Score > grouped[j].Score })
+	return grouped
+}
@@ -1 +1,308 @@
+// Package search holds retrieval strategies layered over the Qdrant
+// points client.
 package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultRRFK is the rank constant of reciprocal rank fusion; 60 is the
+// value from the original paper and damps the influence of the top ranks
+const DefaultRRFK = 60
+
+// DefaultCandidatePool is how many hits each retriever contributes to the
+// fusion, at least
+const DefaultCandidatePool = 50
+
+// KeywordFields are the payload fields keyword matching looks at. With
+// content store references (-payload-refs) the abstract field holds the
+// beginning of the abstract only.
+var KeywordFields = []string{"title", "abstract"}
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopwords are left out of keyword matching
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "that": true, "this": true,
+	"are": true, "was": true, "were": true, "what": true, "which": true, "how": true, "does": true,
+	"can": true, "its": true, "into": true, "about": true, "between": true, "after": true, "patients": true,
+	"effect": true, "effects": true, "treatment": true, "use": true, "study": true, "studies": true,
+}
+
+// Points is the part of qdrant.PointsClient hybrid search needs
+type Points interface {
+	Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error)
+	Count(ctx context.Context, in *qdrant.CountPoints, opts ...grpc.CallOption) (*qdrant.CountResponse, error)
+}
+
+type keywordsKey struct{}
+
+// WithKeywords makes hybrid searches with ctx match the terms of query
+// alongside the query vector. Searches without keywords are dense only.
+func WithKeywords(ctx context.Context, query string) context.Context {
+	return context.WithValue(ctx, keywordsKey{}, query)
+}
+
+func keywordsFrom(ctx context.Context) string {
+	query, _ := ctx.Value(keywordsKey{}).(string)
+	return query
+}
+
+// Hybrid merges vector hits with keyword hits by reciprocal rank fusion,
+// so exact drug names and gene symbols the embedding blurs still surface.
+// Keyword hits are the points of Collection whose KeywordFields contain a
+// query term, ranked by BM25. Only searches of Collection whose context
+// carries keywords are fused; the others pass through. Fused results are
+// scored by RRF, so their scores are not similarities.
+type Hybrid struct {
+	Points        Points
+	Collection    string
+	K             int
+	CandidatePool int
+}
+
+func NewHybrid(points Points, collection string) *Hybrid {
+	return &Hybrid{Points: points, Collection: collection, K: DefaultRRFK, CandidatePool: DefaultCandidatePool}
+}
+
+// Search implements ai.Searcher
+func (h *Hybrid) Search(ctx context.Context, in *qdrant.SearchPoints, opts ...grpc.CallOption) (*qdrant.SearchResponse, error) {
+	terms := Terms(keywordsFrom(ctx))
+	if in.CollectionName != h.Collection || len(terms) == 0 {
+		return h.Points.Search(ctx, in, opts...)
+	}
+
+	// Both retrievers return a deeper list than the page, which is cut from
+	// the fused ranking
+	offset := int(in.GetOffset())
+	pool := uint64(max(offset+int(in.GetLimit()), h.CandidatePool))
+	dense := proto.Clone(in).(*qdrant.SearchPoints)
+	dense.Offset = nil
+	dense.Limit = pool
+	denseResult, err := h.Points.Search(ctx, dense, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	keyword := proto.Clone(dense).(*qdrant.SearchPoints)
+	keyword.Filter = andFilter(in.Filter, termFilter(terms))
+	keyword.WithPayload = withKeywordFields(in.WithPayload)
+	keywordResult, err := h.Points.Search(ctx, keyword, opts...)
+	if err != nil {
+		// Keyword matching is an addition; the vector hits still answer
+		return page(denseResult, offset, int(in.GetLimit())), nil
+	}
+	ranked := h.rankBM25(ctx, terms, keywordResult.GetResult(), opts...)
+
+	fused := &qdrant.SearchResponse{
+		Result: FuseRRF(h.K, denseResult.GetResult(), ranked),
+		Time:   denseResult.GetTime() + keywordResult.GetTime(),
+	}
+	return page(fused, offset, int(in.GetLimit())), nil
+}
+
+// rankBM25 orders the keyword candidates by BM25 over KeywordFields. Term
+// document frequencies are counted in the collection; when counting
+// fails every term weighs the same.
+func (h *Hybrid) rankBM25(ctx context.Context, terms []string, candidates []*qdrant.ScoredPoint, opts ...grpc.CallOption) []*qdrant.ScoredPoint {
+	if len(candidates) == 0 {
+		return nil
+	}
+	idf := make(map[string]float64, len(terms))
+	total, err := h.count(ctx, nil, opts...)
+	for _, term := range terms {
+		idf[term] = 1
+		if err != nil {
+			continue
+		}
+		if df, err := h.count(ctx, termFilter([]string{term}), opts...); err == nil {
+			idf[term] = math.Log(1 + (float64(total)-float64(df)+0.5)/(float64(df)+0.5))
+		}
+	}
+
+	docs := make([][]string, len(candidates))
+	totalLength := 0
+	for i, point := range candidates {
+		var text []string
+		for _, field := range KeywordFields {
+			text = append(text, point.Payload[field].GetStringValue())
+		}
+		docs[i] = tokenize(strings.Join(text, " "))
+		totalLength += len(docs[i])
+	}
+	avgLength := float64(totalLength) / float64(len(docs))
+
+	scores := make(map[*qdrant.ScoredPoint]float64, len(candidates))
+	for i, point := range candidates {
+		tf := make(map[string]int)
+		for _, token := range docs[i] {
+			tf[token]++
+		}
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(len(docs[i]))/max(avgLength, 1))
+		for _, term := range terms {
+			if f := float64(tf[term]); f > 0 {
+				scores[point] += idf[term] * f * (bm25K1 + 1) / (f + norm)
+			}
+		}
+	}
+	ranked := append([]*qdrant.ScoredPoint(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i]] > scores[ranked[j]] })
+	return ranked
+}
+
+func (h *Hybrid) count(ctx context.Context, filter *qdrant.Filter, opts ...grpc.CallOption) (uint64, error) {
+	resp, err := h.Points.Count(ctx, &qdrant.CountPoints{CollectionName: h.Collection, Filter: filter}, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetResult().GetCount(), nil
+}
+
+// FuseRRF merges ranked lists by reciprocal rank fusion: each point scores
+// the sum of 1/(k+rank) over the lists it appears in. A point keeps the
+// payload of the first list it appears in.
+func FuseRRF(k int, lists ...[]*qdrant.ScoredPoint) []*qdrant.ScoredPoint {
+	scores := make(map[string]float64)
+	points := make(map[string]*qdrant.ScoredPoint)
+	var order []string
+	for _, list := range lists {
+		for rank, point := range list {
+			id := point.GetId().String()
+			if _, seen := points[id]; !seen {
+				points[id] = proto.Clone(point).(*qdrant.ScoredPoint)
+				order = append(order, id)
+			}
+			scores[id] += 1 / float64(k+rank+1)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	fused := make([]*qdrant.ScoredPoint, len(order))
+	for i, id := range order {
+		fused[i] = points[id]
+		fused[i].Score = float32(scores[id])
+	}
+	return fused
+}
+
+// Terms returns the distinct lowercase words of query worth matching
+// exactly, leaving out stopwords and words shorter than three characters
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if len(token) < 3 || stopwords[token] || seen[token] {
+			continue
+		}
+		seen[token] = true
+		terms = append(terms, token)
+	}
+	return terms
+}
+
+// tokenize splits text into lowercase words the way Qdrant's word
+// tokenizer does, keeping hyphenated names such as "il-6" whole
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+}
+
+// termFilter matches points whose KeywordFields contain any of terms
+func termFilter(terms []string) *qdrant.Filter {
+	var should []*qdrant.Condition
+	for _, term := range terms {
+		for _, field := range KeywordFields {
+			should = append(should, qdrant.NewMatchText(field, term))
+		}
+	}
+	return &qdrant.Filter{Should: should}
+}
+
+func andFilter(a, b *qdrant.Filter) *qdrant.Filter {
+	if a == nil {
+		return b
+	}
+	return &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewFilterAsCondition(a), qdrant.NewFilterAsCondition(b)}}
+}
+
+// withKeywordFields extends a payload selector with KeywordFields, which
+// BM25 ranking reads
+func withKeywordFields(selector *qdrant.WithPayloadSelector) *qdrant.WithPayloadSelector {
+	include := selector.GetInclude()
+	if include == nil {
+		if selector.GetEnable() {
+			return selector
+		}
+		return &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: KeywordFields},
+		}}
+	}
+	fields := append([]string(nil), include.GetFields()...)
+	for _, field := range KeywordFields {
+		if !contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return &qdrant.WithPayloadSelector{SelectorOptions: &qdrant.WithPayloadSelector_Include{
+		Include: &qdrant.PayloadIncludeSelector{Fields: fields},
+	}}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func page(resp *qdrant.SearchResponse, offset, limit int) *qdrant.SearchResponse {
+	result := resp.GetResult()
+	if offset >= len(result) {
+		result = nil
+	} else {
+		result = result[offset:min(len(result), offset+limit)]
+	}
+	return &qdrant.SearchResponse{Result: result, Time: resp.GetTime()}
+}
+
+// IndexCreator is the part of qdrant.PointsClient that creates payload
+// indexes
+type IndexCreator interface {
+	CreateFieldIndex(ctx context.Context, in *qdrant.CreateFieldIndexCollection, opts ...grpc.CallOption) (*qdrant.PointsOperationResponse, error)
+}
+
+// EnsureTextIndexes creates full-text payload indexes on KeywordFields of
+// collection, which keyword matching needs to be fast. Creating an index
+// that exists does nothing.
+func EnsureTextIndexes(ctx context.Context, points IndexCreator, collection string) error {
+	lowercase := true
+	for _, field := range KeywordFields {
+		_, err := points.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: collection,
+			FieldName:      field,
+			FieldType:      qdrant.FieldType_FieldTypeText.Enum(),
+			FieldIndexParams: qdrant.NewPayloadIndexParamsText(&qdrant.TextIndexParams{
+				Tokenizer: qdrant.TokenizerType_Word,
+				Lowercase: &lowercase,
+			}),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// FilterIndex is a payload index that filtered searches use
+type FilterIndex struct {
+	Field string
+	Type  qdrant.FieldType
+}
+
+// FilterIndexes are the fields search filters match on. Without an index,
+// Qdrant checks a filter against the payload of every point.
+var FilterIndexes = []FilterIndex{
+	{"journal", qdrant.FieldType_FieldTypeKeyword},
+	{"source", qdrant.FieldType_FieldTypeKeyword},
+	{"mesh_headings", qdrant.FieldType_FieldTypeKeyword},
+	{"publication_types", qdrant.FieldType_FieldTypeKeyword},
+	{"published_date", qdrant.FieldType_FieldTypeDatetime},
+	{"published_ts", qdrant.FieldType_FieldTypeInteger},
+	{"published_year", qdrant.FieldType_FieldTypeInteger},
+	{"access_label", qdrant.FieldType_FieldTypeKeyword},
+}
+
+// EnsureFilterIndexes creates the FilterIndexes of collection. Creating an
+// index that exists does nothing, so it is safe to run on every start.
+func EnsureFilterIndexes(ctx context.Context, points IndexCreator, collection string) error {
+	for _, index := range FilterIndexes {
+		_, err := points.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: collection,
+			FieldName:      index.Field,
+			FieldType:      index.Type.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", index.Field, err)
+		}
+	}
+	return nil
+}
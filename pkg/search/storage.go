@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"MedAtlasAIServer/internal/config"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// VectorParams returns the params of a collection of size-dimensional
+// cosine vectors stored as storage says
+func VectorParams(size int, storage config.VectorStorage) *qdrant.VectorParams {
+	params := &qdrant.VectorParams{
+		Size:               uint64(size),
+		Distance:           qdrant.Distance_Cosine,
+		QuantizationConfig: quantizationConfig(storage.Quantization),
+	}
+	if storage.OnDisk {
+		params.OnDisk = qdrant.PtrOf(true)
+	}
+	return params
+}
+
+// quantizationConfig returns the quantization of mode, nil for none. The
+// quantized vectors stay in RAM even when the originals are on disk.
+func quantizationConfig(mode string) *qdrant.QuantizationConfig {
+	switch mode {
+	case config.QuantizationScalar:
+		return &qdrant.QuantizationConfig{Quantization: &qdrant.QuantizationConfig_Scalar{Scalar: scalarQuantization()}}
+	case config.QuantizationProduct:
+		return &qdrant.QuantizationConfig{Quantization: &qdrant.QuantizationConfig_Product{Product: productQuantization()}}
+	}
+	return nil
+}
+
+func scalarQuantization() *qdrant.ScalarQuantization {
+	// The quantile drops the outlying 1% of values from the int8 range,
+	// which keeps the rest of the range finely divided
+	return &qdrant.ScalarQuantization{Type: qdrant.QuantizationType_Int8, Quantile: qdrant.PtrOf(float32(0.99)), AlwaysRam: qdrant.PtrOf(true)}
+}
+
+func productQuantization() *qdrant.ProductQuantization {
+	return &qdrant.ProductQuantization{Compression: qdrant.CompressionRatio_x16, AlwaysRam: qdrant.PtrOf(true)}
+}
+
+// VectorStorageOf returns how the vectors of the collection described by
+// info are stored
+func VectorStorageOf(info *qdrant.CollectionInfo) config.VectorStorage {
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	quantization := params.GetQuantizationConfig()
+	if quantization == nil {
+		quantization = info.GetConfig().GetQuantizationConfig()
+	}
+	storage := config.VectorStorage{Quantization: config.QuantizationNone, OnDisk: params.GetOnDisk()}
+	switch {
+	case quantization.GetScalar() != nil:
+		storage.Quantization = config.QuantizationScalar
+	case quantization.GetProduct() != nil:
+		storage.Quantization = config.QuantizationProduct
+	}
+	return storage
+}
+
+// SameVectorStorage reports whether a and b store vectors alike, an empty
+// quantization being none
+func SameVectorStorage(a, b config.VectorStorage) bool {
+	if a.Quantization == "" {
+		a.Quantization = config.QuantizationNone
+	}
+	if b.Quantization == "" {
+		b.Quantization = config.QuantizationNone
+	}
+	return a == b
+}
+
+// ApplyVectorStorage changes how an existing collection stores its vectors.
+// Qdrant rebuilds the collection's segments in the background and keeps
+// serving searches meanwhile, so no reindexing or downtime is needed.
+func ApplyVectorStorage(ctx context.Context, collections qdrant.CollectionsClient, name string, storage config.VectorStorage) error {
+	diff := &qdrant.QuantizationConfigDiff{Quantization: &qdrant.QuantizationConfigDiff_Disabled{Disabled: &qdrant.Disabled{}}}
+	switch storage.Quantization {
+	case config.QuantizationScalar:
+		diff.Quantization = &qdrant.QuantizationConfigDiff_Scalar{Scalar: scalarQuantization()}
+	case config.QuantizationProduct:
+		diff.Quantization = &qdrant.QuantizationConfigDiff_Product{Product: productQuantization()}
+	}
+	_, err := collections.Update(ctx, &qdrant.UpdateCollection{
+		CollectionName: name,
+		VectorsConfig: &qdrant.VectorsConfigDiff{Config: &qdrant.VectorsConfigDiff_Params{
+			Params: &qdrant.VectorParamsDiff{OnDisk: qdrant.PtrOf(storage.OnDisk), QuantizationConfig: diff},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the vector storage of %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/pkg/data"
+)
+
+func init() {
+	Register("pubmed", newPubMed)
+	Register("clinicaltrials", newClinicalTrials)
+	Register("europepmc", newEuropePMC)
+	Register("files", newFiles)
+}
+
+// pubMed searches PubMed with ESearch and fetches records with EFetch
+type pubMed struct {
+	client *data.PubMedClient
+}
+
+func newPubMed(cfg Config) (DataSource, error) {
+	return &pubMed{client: data.NewPubMedClient()}, nil
+}
+
+func (s *pubMed) Name() string { return "pubmed" }
+
+func (s *pubMed) Search(ctx context.Context, query string, maxResults int) ([]string, error) {
+	return s.client.SearchArticles(ctx, query, maxResults)
+}
+
+func (s *pubMed) Fetch(ctx context.Context, ids []string) ([]Record, error) {
+	articles, err := s.client.FetchArticleDetails(ctx, ids)
+	records := make([]Record, 0, len(articles))
+	for _, article := range articles {
+		records = append(records, article)
+	}
+	return records, err
+}
+
+func (s *pubMed) Normalize(record Record) (models.MedicalArticle, error) {
+	pubmedArticle, ok := record.(models.PubMedArticle)
+	if !ok {
+		return models.MedicalArticle{}, fmt.Errorf("pubmed: unexpected record type %T", record)
+	}
+	article := s.client.NormalizeArticle(pubmedArticle)
+	article.Title = data.CleanMedicalText(article.Title)
+	article.Abstract = data.CleanMedicalText(article.Abstract)
+	return article, nil
+}
+
+// searched adapts APIs whose searches return whole records: Search keeps
+// the records it finds and Fetch serves them from memory
+type searched struct {
+	name   string
+	search func(query string, maxResults int) ([]models.MedicalArticle, error)
+
+	mu    sync.Mutex
+	found map[string]models.MedicalArticle
+}
+
+func (s *searched) Name() string { return s.name }
+
+// Search returns the IDs of the records found before an error with it
+func (s *searched) Search(ctx context.Context, query string, maxResults int) ([]string, error) {
+	articles, err := s.search(query, maxResults)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.found == nil {
+		s.found = make(map[string]models.MedicalArticle)
+	}
+	ids := make([]string, 0, len(articles))
+	for _, article := range articles {
+		s.found[article.ID] = article
+		ids = append(ids, article.ID)
+	}
+	return ids, err
+}
+
+// Fetch returns the records Search found with ids, forgetting them; IDs it
+// did not find are an error
+func (s *searched) Fetch(ctx context.Context, ids []string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]Record, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		article, ok := s.found[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		delete(s.found, id)
+		records = append(records, article)
+	}
+	if len(missing) > 0 {
+		return records, fmt.Errorf("%w: %s has no search results for %s", apperrors.ErrNotFound, s.name, strings.Join(missing, ", "))
+	}
+	return records, nil
+}
+
+// Normalize returns the record, which the client normalized already
+func (s *searched) Normalize(record Record) (models.MedicalArticle, error) {
+	article, ok := record.(models.MedicalArticle)
+	if !ok {
+		return models.MedicalArticle{}, fmt.Errorf("%s: unexpected record type %T", s.name, record)
+	}
+	return article, nil
+}
+
+func newClinicalTrials(cfg Config) (DataSource, error) {
+	return &searched{name: "clinicaltrials", search: data.NewClinicalTrialsClient().SearchStudies}, nil
+}
+
+// newEuropePMC takes the option full_text ("true" collects only open-access
+// articles, with their sections)
+func newEuropePMC(cfg Config) (DataSource, error) {
+	fullText := false
+	if value, ok := cfg.Options["full_text"]; ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, apperrors.Invalid("options.full_text", "must be true or false")
+		}
+		fullText = parsed
+	}
+	client := data.NewEuropePMCClient()
+	return &searched{name: "europepmc", search: func(query string, maxResults int) ([]models.MedicalArticle, error) {
+		return client.Search(query, maxResults, fullText)
+	}}, nil
+}
+
+// files collects articles from local JSONL files, such as exports from
+// another system. Its option files is the glob of the files to read; a
+// query matches the articles whose title or abstract holds all its words,
+// and "*" matches every article.
+type files struct {
+	pattern string
+
+	once     sync.Once
+	articles map[string]models.MedicalArticle
+	order    []string
+	err      error
+}
+
+func newFiles(cfg Config) (DataSource, error) {
+	pattern := strings.TrimSpace(cfg.Options["files"])
+	if pattern == "" {
+		return nil, apperrors.Invalid("options.files", "must name the files to read")
+	}
+	return &files{pattern: pattern}, nil
+}
+
+func (s *files) Name() string { return "files" }
+
+func (s *files) Search(ctx context.Context, query string, maxResults int) ([]string, error) {
+	s.once.Do(s.load)
+	if s.err != nil {
+		return nil, s.err
+	}
+	words := strings.Fields(strings.ToLower(query))
+	if query == "*" {
+		words = nil
+	}
+	var ids []string
+	for _, id := range s.order {
+		if len(ids) >= maxResults {
+			break
+		}
+		article := s.articles[id]
+		text := strings.ToLower(article.Title + " " + article.Abstract)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *files) Fetch(ctx context.Context, ids []string) ([]Record, error) {
+	s.once.Do(s.load)
+	records := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		if article, ok := s.articles[id]; ok {
+			records = append(records, article)
+		}
+	}
+	return records, s.err
+}
+
+func (s *files) Normalize(record Record) (models.MedicalArticle, error) {
+	article, ok := record.(models.MedicalArticle)
+	if !ok {
+		return models.MedicalArticle{}, fmt.Errorf("files: unexpected record type %T", record)
+	}
+	return article, nil
+}
+
+// load reads every article in the files, keeping the first of each ID
+func (s *files) load() {
+	s.articles = make(map[string]models.MedicalArticle)
+	paths, err := data.GlobJSONL(s.pattern)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if len(paths) == 0 {
+		s.err = fmt.Errorf("%w: no JSONL files match %s", apperrors.ErrNotFound, s.pattern)
+		return
+	}
+	for _, path := range paths {
+		if err := s.loadFile(path); err != nil {
+			s.err = err
+			return
+		}
+	}
+}
+
+func (s *files) loadFile(path string) error {
+	in, err := data.OpenInput(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var article models.MedicalArticle
+		if err := json.Unmarshal(scanner.Bytes(), &article); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if _, ok := s.articles[article.ID]; ok || article.ID == "" {
+			continue
+		}
+		s.articles[article.ID] = article
+		s.order = append(s.order, article.ID)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,251 @@
+// Package sources puts the databases articles are collected from behind one
+// interface. Each source searches its database for record IDs, fetches the
+// records and normalizes them into articles; sources register a constructor
+// by name, and a JSON config lists which of them to run with which queries.
+// The collect command and the indexer's -collect mode run the configured
+// sources and write their articles to data/raw as <name>_<time>.jsonl.
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MedAtlasAIServer/internal/access"
+	"MedAtlasAIServer/internal/apperrors"
+	"MedAtlasAIServer/internal/jsonfile"
+	"MedAtlasAIServer/internal/models"
+	"MedAtlasAIServer/pkg/data"
+)
+
+// DefaultPath is where the source config is read from when SOURCES_FILE is
+// unset
+const DefaultPath = "data/sources.json"
+
+// DefaultMaxPerQuery is how many records a query collects when its source
+// config does not say
+const DefaultMaxPerQuery = 100
+
+// Record is a record in its source's own format, as Fetch returns it
+type Record any
+
+// DataSource is a database articles are collected from
+type DataSource interface {
+	// Name is the source's registered name, which also prefixes its files
+	Name() string
+	// Search returns the IDs of up to maxResults records matching query
+	Search(ctx context.Context, query string, maxResults int) ([]string, error)
+	// Fetch returns the records with ids. Records fetched before an error
+	// are returned with it.
+	Fetch(ctx context.Context, ids []string) ([]Record, error)
+	// Normalize converts a record Fetch returned into an article
+	Normalize(record Record) (models.MedicalArticle, error)
+}
+
+// Config configures one source of a collection run. Options are specific to
+// the source, such as full_text for europepmc. Access labels the articles
+// collected that carry no label of their own, e.g. licensed for a
+// subscription's full text.
+type Config struct {
+	Name        string            `json:"name"`
+	Queries     []string          `json:"queries"`
+	MaxPerQuery int               `json:"max_per_query,omitempty"`
+	Access      string            `json:"access,omitempty"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+// File lists the sources a collection run uses
+type File struct {
+	Sources []Config `json:"sources"`
+}
+
+// Factory builds a source from its config
+type Factory func(cfg Config) (DataSource, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a source available under name. It panics when name is
+// registered twice, which is a programming error.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("sources: " + name + " registered twice")
+	}
+	registry[name] = factory
+}
+
+// Names returns the registered source names, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilePatterns returns the glob patterns of the files the registered
+// sources write, such as pubmed_*
+func FilePatterns() []string {
+	var patterns []string
+	for _, name := range Names() {
+		patterns = append(patterns, name+"_*")
+	}
+	return patterns
+}
+
+// New builds the source cfg names
+func New(cfg Config) (DataSource, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, apperrors.Invalid("name", "unknown source %q, expected one of %s", cfg.Name, strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
+
+// PathFromEnv returns SOURCES_FILE, or DefaultPath
+func PathFromEnv() string {
+	if path := os.Getenv("SOURCES_FILE"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Load reads the source config at path and checks every entry names a
+// registered source with at least one query
+func Load(path string) (File, error) {
+	var file File
+	found, err := jsonfile.Read(path, &file)
+	if err != nil {
+		return File{}, err
+	}
+	if !found {
+		return File{}, fmt.Errorf("%w: source config %s does not exist", apperrors.ErrNotFound, path)
+	}
+	for i, cfg := range file.Sources {
+		if _, err := New(cfg); err != nil {
+			return File{}, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		if len(cfg.Queries) == 0 {
+			return File{}, fmt.Errorf("sources[%d]: %w", i, apperrors.Invalid("queries", "must list at least one query"))
+		}
+		if cfg.MaxPerQuery < 0 {
+			return File{}, fmt.Errorf("sources[%d]: %w", i, apperrors.Invalid("max_per_query", "must not be negative"))
+		}
+		if !access.Valid(cfg.Access) {
+			return File{}, fmt.Errorf("sources[%d]: %w", i, apperrors.Invalid("access", "must be %s", strings.Join(access.Labels, ", ")))
+		}
+	}
+	return file, nil
+}
+
+// Collect runs every query of cfg against its source and returns the valid
+// articles found, each once. A failed query is logged and skipped; Collect
+// only fails when the source cannot be built or ctx is cancelled.
+func Collect(ctx context.Context, cfg Config) ([]models.MedicalArticle, error) {
+	source, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	maxPerQuery := cfg.MaxPerQuery
+	if maxPerQuery == 0 {
+		maxPerQuery = DefaultMaxPerQuery
+	}
+
+	seen := make(map[string]bool)
+	var articles []models.MedicalArticle
+	for _, query := range cfg.Queries {
+		if err := ctx.Err(); err != nil {
+			return articles, err
+		}
+		ids, err := source.Search(ctx, query, maxPerQuery)
+		if err != nil {
+			log.Printf("❌ %s: search for %q failed: %v", cfg.Name, query, err)
+			continue
+		}
+		records, err := source.Fetch(ctx, ids)
+		if err != nil {
+			log.Printf("⚠️  %s: fetched %d of %d records for %q: %v", cfg.Name, len(records), len(ids), query, err)
+		}
+		added := 0
+		for _, record := range records {
+			article, err := source.Normalize(record)
+			if err != nil {
+				log.Printf("⚠️  %s: skipping record: %v", cfg.Name, err)
+				continue
+			}
+			if article.Access == "" {
+				article.Access = cfg.Access
+			}
+			if seen[article.ID] || !data.ValidateArticle(article) {
+				continue
+			}
+			seen[article.ID] = true
+			articles = append(articles, article)
+			added++
+		}
+		log.Printf("✅ %s: %q found %d records, %d new articles", cfg.Name, query, len(ids), added)
+	}
+	return articles, nil
+}
+
+// Run collects the articles of cfg into a new file in dir, named after the
+// source and now, with extension (".jsonl", ".jsonl.gz" or ".jsonl.zst").
+// It returns the path written and how many articles it holds; when nothing
+// was collected no file is written and path is "".
+func Run(ctx context.Context, cfg Config, dir, extension string, now time.Time) (path string, count int, err error) {
+	articles, err := Collect(ctx, cfg)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(articles) == 0 {
+		return "", 0, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, err
+	}
+	path = filepath.Join(dir, fmt.Sprintf("%s_%s%s", cfg.Name, now.Format("20060102_150405"), extension))
+	if err := WriteArticles(path, articles); err != nil {
+		return "", 0, err
+	}
+	return path, len(articles), nil
+}
+
+// WriteArticles writes articles as JSON lines under a hidden name and
+// renames the file when complete, so a watching indexer never reads a
+// partial file
+func WriteArticles(path string, articles []models.MedicalArticle) error {
+	partial := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	out, err := data.OpenOutput(partial)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	for _, article := range articles {
+		if err := encoder.Encode(article); err != nil {
+			out.Close()
+			os.Remove(partial)
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
@@ -1,17 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
+	"MedAtlasAIServer/internal/jsonfile"
 	"MedAtlasAIServer/internal/models"
 	"MedAtlasAIServer/pkg/data"
 )
 
 func main() {
+	compress := flag.String("compress", "", "compress output files: gz or zst")
+	update := flag.Bool("update", false, "only collect articles PubMed added since the last successful run of each topic, into new files")
+	statePath := flag.String("state", defaultStatePath, "file recording each topic's last successful update")
+	maxUpdate := flag.Int("max-per-topic", 1000, "in -update mode, most new articles collected per topic")
+	progressPath := flag.String("progress", defaultProgressPath, "file recording the progress of the current run, for -resume")
+	resume := flag.Bool("resume", false, "continue the interrupted run recorded in -progress instead of starting over")
+	flag.Parse()
+	extension := ".jsonl"
+	switch *compress {
+	case "":
+	case "gz", "zst":
+		extension += "." + *compress
+	default:
+		log.Fatalf("-compress must be gz or zst")
+	}
+
 	log.Println("Starting PubMed data collection...")
 
 	// Create output directory
@@ -36,51 +59,82 @@ func main() {
 	client := data.NewPubMedClient()
 	totalArticles := 0
 
-	for _, topic := range medicalTopics {
-		fmt.Printf("\n🔍 Searching PubMed for: %s\n", topic)
-
-		// Search for articles
-		articleIDs, err := client.SearchArticles(topic, 50) // Get 50 articles per topic
-		if err != nil {
-			log.Printf("❌ Search failed for '%s': %v", topic, err)
-			continue
+	state := &updateState{Topics: map[string]string{}}
+	if *update {
+		if _, err := jsonfile.Read(*statePath, state); err != nil {
+			log.Fatalf("Failed to read update state: %v", err)
+		}
+		if state.Topics == nil {
+			state.Topics = map[string]string{}
 		}
+	}
 
-		fmt.Printf("   Found %d articles\n", len(articleIDs))
+	run, err := startRun(*progressPath, *resume, *update)
+	if err != nil {
+		log.Fatalf("Failed to start the run: %v", err)
+	}
+	// Today is included: PubMed's date filters have day precision, and
+	// articles seen twice are deduplicated by the indexer
+	runDate := run.Started
 
-		if len(articleIDs) == 0 {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for _, topic := range medicalTopics {
+		progress := run.topic(topic)
+		if progress.Done {
+			fmt.Printf("\n⏭️  %s was collected before the interruption\n", topic)
 			continue
 		}
+		fmt.Printf("\n🔍 Searching PubMed for: %s\n", topic)
 
-		// Fetch article details
-		articles, err := client.FetchArticleDetails(articleIDs)
+		limit := 50 // articles per topic in a full run
+		var since time.Time
+		if *update {
+			limit = *maxUpdate
+			if since, err = updateWindow(topic, state, runDate); err != nil {
+				log.Printf("❌ Search failed for '%s': %v", topic, err)
+				continue
+			}
+		}
+		collected, err := collectTopic(ctx, client, run, topic, since, limit, extension)
+		totalArticles += collected
+		if ctx.Err() != nil {
+			log.Printf("⏸️  Interrupted; resume the run with -resume")
+			return
+		}
 		if err != nil {
-			log.Printf("❌ Failed to fetch details for '%s': %v", topic, err)
+			log.Printf("❌ Collection failed for '%s' after %d articles: %v", topic, collected, err)
 			continue
 		}
+		progress.Done = true
+		run.save()
+		if *update {
+			state.advance(*statePath, topic, runDate)
+		}
 
-		// Process and save articles
-		processed := processAndSaveArticles(articles, client, topic)
-		totalArticles += processed
-
-		fmt.Printf("   ✅ Processed %d articles for %s\n", processed, topic)
+		fmt.Printf("   ✅ Processed %d articles for %s\n", collected, topic)
 
 		// Be respectful to PubMed API
 		time.Sleep(1 * time.Second)
 	}
+	run.finish()
 
 	fmt.Printf("\n🎉 Collection complete! Total articles processed: %d\n", totalArticles)
 }
 
-func processAndSaveArticles(pubmedArticles []models.PubMedArticle, client *data.PubMedClient, topic string) int {
-	outputFile := fmt.Sprintf("data/raw/pubmed_%s.jsonl", sanitizeFilename(topic))
+// processAndSaveArticles writes the valid articles to data/raw under a
+// hidden name and renames the file when complete, so a watching indexer
+// never reads a partial file
+func processAndSaveArticles(pubmedArticles []models.PubMedArticle, client *data.PubMedClient, name, extension string) (int, error) {
+	outputFile := fmt.Sprintf("data/raw/pubmed_%s%s", name, extension)
+	partial := filepath.Join(filepath.Dir(outputFile), "."+filepath.Base(outputFile))
 
-	file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// OpenOutput appends, and an interrupted run leaves its partial file
+	os.Remove(partial)
+	file, err := data.OpenOutput(partial)
 	if err != nil {
-		log.Printf("❌ Failed to open file %s: %v", outputFile, err)
-		return 0
+		return 0, err
 	}
-	defer file.Close()
 
 	processed := 0
 
@@ -93,10 +147,10 @@ func processAndSaveArticles(pubmedArticles []models.PubMedArticle, client *data.
 			continue
 		}
 
-		// Clean and enhance the data
+		// Clean the data; abbreviations are kept for clinicians and expanded
+		// for patients when chat quotes the abstract
 		article.Title = data.CleanMedicalText(article.Title)
 		article.Abstract = data.CleanMedicalText(article.Abstract)
-		article.Abstract = data.NormalizeMedicalTerms(article.Abstract)
 
 		// Convert to JSON and save
 		jsonData, err := json.Marshal(article)
@@ -105,12 +159,19 @@ func processAndSaveArticles(pubmedArticles []models.PubMedArticle, client *data.
 			continue
 		}
 
-		file.Write(jsonData)
-		file.WriteString("\n")
+		if _, err := file.Write(append(jsonData, '\n')); err != nil {
+			file.Close()
+			os.Remove(partial)
+			return 0, err
+		}
 		processed++
 	}
 
-	return processed
+	if err := file.Close(); err != nil {
+		os.Remove(partial)
+		return 0, err
+	}
+	return processed, os.Rename(partial, outputFile)
 }
 
 func sanitizeFilename(name string) string {
@@ -125,3 +186,220 @@ func sanitizeFilename(name string) string {
 	}
 	return string(result)
 }
+
+// defaultStatePath is where -update keeps its watermarks
+const defaultStatePath = "data/state/pubmed_update.json"
+
+// updateState is the -update state file: the date (YYYY-MM-DD, UTC) of
+// each topic's last successful update. Topics are tracked separately so a
+// topic that failed is retried from its own watermark.
+type updateState struct {
+	Topics map[string]string `json:"topics"`
+}
+
+// updateWindow returns the date from which to collect the articles PubMed
+// added for topic: its watermark, or for a topic without one a year before
+// runDate, as in a full run
+func updateWindow(topic string, state *updateState, runDate time.Time) (time.Time, error) {
+	since := runDate.AddDate(-1, 0, 0)
+	if watermark, ok := state.Topics[topic]; ok {
+		parsed, err := time.Parse("2006-01-02", watermark)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid watermark %q in state file", watermark)
+		}
+		since = parsed
+	}
+	fmt.Printf("   Updates added %s to %s\n", since.Format("2006-01-02"), runDate.Format("2006-01-02"))
+	return since, nil
+}
+
+// advance records runDate as topic's watermark and saves the state, so an
+// interrupted run keeps the topics it finished
+func (s *updateState) advance(path, topic string, runDate time.Time) {
+	s.Topics[topic] = runDate.Format("2006-01-02")
+	if err := jsonfile.WriteAtomic(path, s); err != nil {
+		log.Printf("❌ Failed to save update state: %v", err)
+	}
+}
+
+// defaultProgressPath is where a run records its progress for -resume
+const defaultProgressPath = "data/state/pubmed_progress.json"
+
+// runProgress is the -progress file: how far each topic of a run got. It is
+// saved after every page of records written, and removed when the run
+// completes.
+type runProgress struct {
+	Started time.Time                 `json:"started"` // names the run's files and dates -update windows
+	Update  bool                      `json:"update"`
+	Topics  map[string]*topicProgress `json:"topics"`
+
+	path string
+}
+
+// topicProgress is how far a topic got. History is the topic's search on
+// the E-utilities history server, which pages are fetched from.
+type topicProgress struct {
+	History *data.SearchHistory `json:"history,omitempty"`
+	Total   int                 `json:"total"`  // records to collect
+	Offset  int                 `json:"offset"` // records fetched and written
+	Parts   int                 `json:"parts"`  // files written
+	Done    bool                `json:"done"`
+}
+
+// startRun resumes the run recorded at path, or starts a new one
+func startRun(path string, resume, update bool) (*runProgress, error) {
+	run := &runProgress{}
+	found, err := jsonfile.Read(path, run)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resume && found && run.Update != update:
+		return nil, fmt.Errorf("the interrupted run has -update=%t; resume it with the same mode", run.Update)
+	case resume && found:
+		log.Printf("⏯️  Resuming the run started %s", run.Started.Format(time.RFC3339))
+	default:
+		if found {
+			log.Printf("⚠️  Starting over; the interrupted run in %s is discarded", path)
+		}
+		run = &runProgress{Started: time.Now().UTC(), Update: update}
+	}
+	if run.Topics == nil {
+		run.Topics = make(map[string]*topicProgress)
+	}
+	run.path = path
+	run.save()
+	return run, nil
+}
+
+func (r *runProgress) topic(name string) *topicProgress {
+	if r.Topics[name] == nil {
+		r.Topics[name] = &topicProgress{}
+	}
+	return r.Topics[name]
+}
+
+func (r *runProgress) save() {
+	if err := jsonfile.WriteAtomic(r.path, r); err != nil {
+		log.Printf("❌ Failed to save run progress: %v", err)
+	}
+}
+
+// finish removes the progress file once every topic is done, so the next
+// run starts afresh
+func (r *runProgress) finish() {
+	for _, progress := range r.Topics {
+		if !progress.Done {
+			log.Printf("💡 Some topics failed; retry them with -resume")
+			return
+		}
+	}
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("⚠️  Failed to remove %s: %v", r.path, err)
+	}
+}
+
+// collectTopic fetches the records of topic from where its progress
+// stopped, up to limit, several pages at a time, writing each page to its
+// own file in order and saving the progress after each. A search the
+// history server has expired is run again and fetching goes on from the
+// same offset; the indexer drops any article that comes twice as results
+// shift. It returns how many articles it wrote.
+func collectTopic(ctx context.Context, client *data.PubMedClient, run *runProgress, topic string, since time.Time, limit int, extension string) (int, error) {
+	progress := run.topic(topic)
+	var until time.Time
+	if !since.IsZero() {
+		until = run.Started
+	}
+	search := func() error {
+		history, err := client.SearchToHistory(ctx, topic, since, until)
+		if err != nil {
+			return err
+		}
+		progress.History = history
+		if progress.Total == 0 {
+			progress.Total = min(history.Count, limit)
+			fmt.Printf("   Found %d articles, collecting %d\n", history.Count, progress.Total)
+			if history.Count > limit && run.Update {
+				log.Printf("⚠️  %d articles added for '%s', collecting %d; raise -max-per-topic to collect them all", history.Count, topic, limit)
+			}
+		}
+		run.save()
+		return nil
+	}
+	if progress.History == nil {
+		if err := search(); err != nil {
+			return 0, err
+		}
+	} else {
+		fmt.Printf("   Resuming at %d of %d articles\n", progress.Offset, progress.Total)
+	}
+
+	written, researched := 0, false
+	for progress.Offset < progress.Total {
+		var fetchErr error
+		for _, page := range fetchPages(ctx, client, *progress.History, progress.Offset, progress.Total) {
+			if page.err == nil && len(page.articles) == 0 {
+				page.err = fmt.Errorf("no records at offset %d", page.start)
+			}
+			if page.err != nil {
+				fetchErr = page.err
+				break
+			}
+			researched = false
+
+			name := fmt.Sprintf("%s_%s_%03d", sanitizeFilename(topic), run.Started.Format("20060102_150405"), progress.Parts+1)
+			processed, err := processAndSaveArticles(page.articles, client, name, extension)
+			if err != nil {
+				return written, err
+			}
+			written += processed
+			progress.Offset = page.start + len(page.articles)
+			progress.Parts++
+			run.save()
+			if len(page.articles) < page.count {
+				break // the pages fetched after it start past the missing records
+			}
+		}
+		if fetchErr == nil {
+			continue
+		}
+		if ctx.Err() != nil || researched {
+			return written, fetchErr
+		}
+		// The history server may have expired the search
+		log.Printf("⚠️  Fetching '%s' failed (%v); searching again", topic, fetchErr)
+		if err := search(); err != nil {
+			return written, err
+		}
+		researched = true
+	}
+	return written, nil
+}
+
+// historyPage is one EFetch page of a search on the history server
+type historyPage struct {
+	start, count int
+	articles     []models.PubMedArticle
+	err          error
+}
+
+// fetchPages fetches the pages of history from offset up to total, as many
+// at once as the client has workers, each request waiting on the client's
+// limiter. The pages are returned in order.
+func fetchPages(ctx context.Context, client *data.PubMedClient, history data.SearchHistory, offset, total int) []historyPage {
+	var pages []historyPage
+	for start := offset; start < total && len(pages) < max(client.Workers, 1); start += client.BatchSize {
+		pages = append(pages, historyPage{start: start, count: min(client.BatchSize, total-start)})
+	}
+	var wg sync.WaitGroup
+	for i := range pages {
+		wg.Add(1)
+		go func(page *historyPage) {
+			defer wg.Done()
+			page.articles, page.err = client.FetchFromHistory(ctx, history, page.start, page.count)
+		}(&pages[i])
+	}
+	wg.Wait()
+	return pages
+}